@@ -3,7 +3,7 @@ import { InputManager } from "../utils/inputManager";
 import { NetworkManager } from "../network/networkManager";
 import { AnimationController, PlayerState } from "./animationController";
 import { CoordinateConverter } from "../utils/coordinateConverter";
-import { MOVEMENT } from "../../shared/gameConfig";
+import { MOVEMENT, NETWORK } from "../../shared/gameConfig";
 
 const RECONCILE_SOFT_ALPHA = 0.45;
 const RECONCILE_MAX_STEP = 18;
@@ -18,8 +18,15 @@ export class MovementController {
 
     private _virtualPosition = { x: 0, y: 0 };
 
+    // Множитель скорости от событий мира (шторм), в процентах — как на сервере
+    private _speedPercent = 100;
+    private _frozen = false;
+
     private _currentMovementVector = { dx: 0, dy: 0 };
 
+    // Скорость за шаг предсказания (units/tick) — физика движения как на сервере
+    private _velocity = { x: 0, y: 0 };
+
     private _inputSequence = 0;
     private _pendingInputs: Array<{sequence: number, dx: number, dy: number, timestamp: number}> = [];
 
@@ -41,18 +48,58 @@ export class MovementController {
         return { ...this._virtualPosition };
     }
 
+    /**
+     * Установить множитель скорости (WORLD_EVENT storm); 100 = норма
+     */
+    setSpeedPercent(percent: number): void {
+        this._speedPercent = percent;
+    }
+
+    /**
+     * Заморозить предсказание движения (MAINTENANCE paused: сервер не тикает)
+     */
+    setFrozen(frozen: boolean): void {
+        this._frozen = frozen;
+    }
+
+    /**
+     * Дистанция за тик — та же целочисленная формула, что в updatePlayerPosition на сервере
+     */
+    private get moveDistance(): number {
+        if (this._frozen) return 0;
+        const base = MOVEMENT.playerSpeedPerTick;
+        if (this._speedPercent === 100) return base;
+        return Math.max(Math.trunc(base * this._speedPercent / 100), 1);
+    }
+
+    /**
+     * Скорость на шаг ближе к target — physics.approach на сервере: разгон на
+     * MOVEMENT.acceleration, торможение (меньшая цель, остановка, разворот) на
+     * MOVEMENT.friction, units/s²; 0 = сразу
+     */
+    private approach(v: number, target: number): number {
+        const tickRate = Math.max(NETWORK.tickRate, 1);
+        const speedingUp = target !== 0 && (v === 0 || Math.sign(v) === Math.sign(target)) && Math.abs(target) > Math.abs(v);
+        const rule = (speedingUp ? MOVEMENT.acceleration : MOVEMENT.friction) ?? 0;
+        if (rule <= 0) return target;
+        const step = rule / (tickRate * tickRate);
+        return v < target ? Math.min(v + step, target) : Math.max(v - step, target);
+    }
+
     /**
      * Применить движение локально (client-side prediction)
      */
     private applyMovement(dx: number, dy: number): void {
-        const moveDistance = MOVEMENT.playerSpeedPerTick;
+        const moveDistance = this.moveDistance;
 
-        if (dx !== 0) {
-            this._virtualPosition.x += dx * moveDistance;
-        }
-        if (dy !== 0) {
-            this._virtualPosition.y += dy * moveDistance;
+        if (this._frozen) {
+            this._velocity = { x: 0, y: 0 };
+        } else {
+            this._velocity.x = this.approach(this._velocity.x, dx * moveDistance);
+            this._velocity.y = this.approach(this._velocity.y, dy * moveDistance);
         }
+        this._virtualPosition.x += this._velocity.x;
+        this._virtualPosition.y += this._velocity.y;
 
         if (this._coordinateConverter) {
             const clampedPos = this._coordinateConverter.clampToVirtualBounds(
@@ -101,7 +148,7 @@ export class MovementController {
 
         if (futureInputs.length > 0) {
             for (const input of futureInputs) {
-                const moveDistance = MOVEMENT.playerSpeedPerTick;
+                const moveDistance = this.moveDistance;
                 reconciledTarget.x += input.dx * moveDistance;
                 reconciledTarget.y += input.dy * moveDistance;
 
@@ -260,6 +307,11 @@ export class MovementController {
         } else {
             this._isMoving = false;
 
+            // Glide to a stop with friction, as the server does
+            if (this._velocity.x !== 0 || this._velocity.y !== 0) {
+                this.applyMovement(0, 0);
+            }
+
             // Send stop command only if not during attack
             if (this.vectorChanged(desiredVector) &&
                 !(this._animationController && this._animationController.playerState === PlayerState.ATTACKING)) {
@@ -1,13 +1,14 @@
-import { Application, Container, Graphics } from "pixi.js";
+import { Application, Container, Graphics, Text } from "pixi.js";
 import { SpriteLoader } from "./utils/spriteLoader";
 import { FpsDisplay } from "./utils/fpsDisplay";
+import { ChatBox } from "./utils/chatBox";
 import { InputManager } from "./utils/inputManager";
 import { MovementController } from "./controllers/movementController";
 import { AnimationController, PlayerState } from "./controllers/animationController";
 import { NetworkManager } from "./network/networkManager";
 import { PlayerManager } from "./game/playerManager";
-import { TICK_RATE } from "./network/protocol/messages";
-import { PLAYER, COLORS } from "../shared/gameConfig";
+import { WorldEventKind, MaintenancePhase, AnnounceSeverity, MessageType } from "./network/protocol/messages";
+import { PLAYER, COLORS, NETWORK } from "../shared/gameConfig";
 import { BinaryProtocol } from "./network/protocol/binaryProtocol";
 import { CoordinateConverter } from "./utils/coordinateConverter";
 
@@ -81,6 +82,24 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
     const playerContainer = new Container();
     app.stage.addChild(playerContainer);
 
+    // Night overlay and announcement banner (WORLD_EVENT), drawn above the players
+    const nightOverlay = new Graphics();
+    nightOverlay.visible = false;
+    app.stage.addChild(nightOverlay);
+    const drawNightOverlay = () => {
+        nightOverlay.clear();
+        nightOverlay.rect(0, 0, app.screen.width, app.screen.height);
+        nightOverlay.fill({ color: 0x0a0a30, alpha: 0.45 });
+    };
+    drawNightOverlay();
+
+    const announcement = new Text({ text: "", style: { fill: 0xffffff, fontSize: 24 } });
+    announcement.anchor.set(0.5, 0);
+    announcement.position.set(app.screen.width / 2, 16);
+    announcement.visible = false;
+    app.stage.addChild(announcement);
+    let announcementTimer: ReturnType<typeof setTimeout> | undefined;
+
     // Initialize modules
     const input = new InputManager(app.canvas);
 
@@ -93,6 +112,9 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
     // Connect FPS display to network manager for ping tracking
     networkManager.setFpsDisplay(fpsDisplay);
 
+    // Чат и консольные команды (/help)
+    new ChatBox(networkManager);
+
     // Set up F3 key to toggle detailed stats
     input.setF3Callback(() => {
         fpsDisplay.toggleDetailedStats();
@@ -146,6 +168,90 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
         movementController.handleMovementAcknowledgment(position, inputSequence);
     });
 
+    // Серверный CONFIG мог поменять размер мира — пересчитать масштаб
+    networkManager.onConfig(() => {
+        coordinateConverter.refreshWorldSize();
+    });
+    networkManager.onWorldUpdate(() => {
+        coordinateConverter.refreshWorldSize();
+    });
+
+    // Глобальные события мира: ночь, шторм (скорость), объявления
+    networkManager.onWorldEvent((event) => {
+        switch (event.kind) {
+            case WorldEventKind.NIGHT:
+                nightOverlay.visible = event.active;
+                break;
+            case WorldEventKind.STORM:
+                movementController.setSpeedPercent(event.active ? event.value : 100);
+                break;
+        }
+        if (event.active && event.text) {
+            announcement.text = event.text;
+            announcement.style.fill = 0xffffff;
+            announcement.visible = true;
+            clearTimeout(announcementTimer);
+            announcementTimer = setTimeout(() => { announcement.visible = false; }, 5000);
+        }
+    });
+
+    // MOTD и объявления администратора: цвет по важности, критические висят дольше
+    const announceColors: Record<number, number> = {
+        [AnnounceSeverity.INFO]: 0xffffff,
+        [AnnounceSeverity.WARNING]: 0xffd24a,
+        [AnnounceSeverity.CRITICAL]: 0xff5a5a,
+    };
+    networkManager.onAnnounce((event) => {
+        announcement.text = event.text;
+        announcement.style.fill = announceColors[event.severity] ?? 0xffffff;
+        announcement.visible = true;
+        clearTimeout(announcementTimer);
+        const shownMs = event.severity === AnnounceSeverity.CRITICAL ? 15000 : 8000;
+        announcementTimer = setTimeout(() => { announcement.visible = false; }, shownMs);
+    });
+
+    // Режим обслуживания: обратный отсчёт, затем мир на паузе до окончания работ
+    let maintenanceTimer: ReturnType<typeof setInterval> | undefined;
+    networkManager.onMaintenance((event) => {
+        clearInterval(maintenanceTimer);
+        clearTimeout(announcementTimer);
+        announcement.style.fill = 0xffffff;
+        movementController.setFrozen(event.phase === MaintenancePhase.PAUSED);
+
+        switch (event.phase) {
+            case MaintenancePhase.SCHEDULED: {
+                const pauseAt = Date.now() + event.countdownMs;
+                const update = () => {
+                    const left = Math.max(Math.ceil((pauseAt - Date.now()) / 1000), 0);
+                    announcement.text = `Server maintenance in ${left}s`;
+                };
+                update();
+                maintenanceTimer = setInterval(update, 1000);
+                announcement.visible = true;
+                break;
+            }
+            case MaintenancePhase.PAUSED:
+                announcement.text = "Server maintenance — game paused";
+                announcement.visible = true;
+                break;
+            default:
+                announcement.visible = false;
+        }
+    });
+
+    // Очередь на вход: сервер полон, показываем место и оценку ожидания
+    networkManager.onLoginQueue((event) => {
+        clearTimeout(announcementTimer);
+        announcement.style.fill = 0xffffff;
+        if (event.type === "queueAdmitted") {
+            announcement.visible = false;
+            return;
+        }
+        const wait = event.waitSec > 0 ? ` — about ${Math.ceil(event.waitSec / 60)} min` : "";
+        announcement.text = `Server full: you are ${event.position} of ${event.length} in the queue${wait}`;
+        announcement.visible = true;
+    });
+
     // Обработчик изменения размеров окна
     const handleResize = () => {
         const newWidth = app.screen.width;
@@ -159,6 +265,9 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
         worldBackground.rect(0, 0, newWidth, newHeight);
         worldBackground.fill(parseInt(COLORS.worldBackground.replace('#', ''), 16));
 
+        drawNightOverlay();
+        announcement.position.set(newWidth / 2, 16);
+
         // Обновляем позиции всех игроков
         playerManager.updateAllPlayerPositions();
     };
@@ -194,22 +303,33 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
         }
     });
 
+    // A refused attack: hold further clicks until the server would accept one
+    let attackBlockedUntil = 0;
+    networkManager.onActionRejected((rejection) => {
+        if (rejection.action === MessageType.ATTACK) {
+            attackBlockedUntil = performance.now() + rejection.retryAfterTicks * 1000 / NETWORK.tickRate;
+        }
+    });
+
         // Attack handling — no prediction, server-authoritative animation
     app.canvas.addEventListener("mousedown", (e) => {
-        if (e.button === 0 && animationController.playerState !== PlayerState.ATTACKING) {
+        if (e.button === 0 && animationController.playerState !== PlayerState.ATTACKING &&
+            performance.now() >= attackBlockedUntil) {
             // Gate: don't spam while animation is still playing locally
-            const position = { x: playerSprite.position.x, y: playerSprite.position.y };
+            // Aim at the cursor; the server resolves the hits in that direction
+            const origin = playerSprite.getGlobalPosition();
+            const dx = e.offsetX - origin.x, dy = e.offsetY - origin.y;
+            const scale = 127 / Math.max(Math.abs(dx), Math.abs(dy), 1);
             const attackMsg = {
                 type: 'attack' as const,
-                position
+                aim: { x: Math.round(dx * scale), y: Math.round(dy * scale) }
             };
             const binaryData = BinaryProtocol.encodeAttack(attackMsg);
             networkManager.sendAttack(binaryData);
         }
     });
 
-    // Fixed timestep for physics updates
-    const fixedTimeStep = 1 / TICK_RATE;
+    // Fixed timestep for physics updates (tick rate may change with the server's CONFIG)
     let accumulator = 0;
 
     // Game loop
@@ -221,6 +341,7 @@ import { CoordinateConverter } from "./utils/coordinateConverter";
 
         // Accumulate time
         accumulator += deltaTime;
+        const fixedTimeStep = 1 / NETWORK.tickRate;
 
         // Process physics at fixed time steps
         while (accumulator >= fixedTimeStep) {
@@ -1,8 +1,52 @@
 import { BinaryProtocol } from "./protocol/binaryProtocol";
 import {
     PlayerState,
-    PlayerPosition
+    PlayerPosition,
+    WorldEventMessage,
+    AnnounceMessage,
+    ChatMessage,
+    CommandResultMessage,
+    HitMessage,
+    ActionRejectedMessage,
+    MaintenanceMessage,
+    QueueStatusMessage,
+    QueueAdmittedMessage,
+    ConfigMessage,
+    MinimapMessage,
+    LeaderboardMessage,
+    PartyInviteMessage,
+    PartyRosterMessage,
+    PartyChatMessage,
+    FriendUpdateMessage,
+    InventoryMessage,
+    TradeStateMessage,
+    PlayerEmoteMessage,
+    WorldUpdateMessage,
+    SEQ_HEADER_SIZE,
+    CHECKSUM_SIZE,
+    SEQUENCE_REPORT_RESYNC,
+    ClientCapability,
+    MessageType,
+    PartyAction,
+    FriendAction,
+    TradeAction
 } from "./protocol/messages";
+import { WIRE_CLOSE_RECONNECT, WIRE_SUBPROTOCOL, WireCloseCode, decodeCipherInit, encodeReliableAck, encodeViewportUpdate } from "./protocol/generated";
+import { SessionSealer, sessionKeyFromLocation } from "./protocol/sealer";
+import { WORLD, applyServerConfig, applyWorldSize } from "../../shared/gameConfig";
+
+// How often the client reports outbound sequence loss when no gap forces a report
+const SEQUENCE_REPORT_INTERVAL_MS = 5000;
+
+// RELIABLE ids remembered to drop retransmitted copies; the server gives up long before
+const RELIABLE_SEEN_LIMIT = 256;
+
+// Reconnect backoff after a close the server marks as recoverable (or a dropped socket)
+const RECONNECT_BASE_DELAY_MS = 1000;
+const RECONNECT_MAX_DELAY_MS = 30000;
+// WebSocket close codes that mean the socket just dropped (no server close frame)
+const CLOSE_ABNORMAL = 1006;
+const CLOSE_GOING_AWAY = 1001;
 
 // Callback types
 export type OnPlayerJoinedCallback = (player: PlayerState) => void;
@@ -22,6 +66,25 @@ export type OnGameStateCallback = (
 ) => void;
 export type OnCorrectionCallback = (position: PlayerPosition) => void;
 export type OnMovementAckCallback = (position: PlayerPosition, inputSequence: number) => void;
+export type OnWorldEventCallback = (event: WorldEventMessage) => void;
+export type OnAnnounceCallback = (announce: AnnounceMessage) => void;
+export type OnChatCallback = (chat: ChatMessage) => void;
+export type OnPartyInviteCallback = (invite: PartyInviteMessage) => void;
+export type OnPartyRosterCallback = (roster: PartyRosterMessage) => void;
+export type OnPartyChatCallback = (chat: PartyChatMessage) => void;
+export type OnFriendUpdateCallback = (update: FriendUpdateMessage) => void;
+export type OnInventoryCallback = (inventory: InventoryMessage) => void;
+export type OnTradeStateCallback = (trade: TradeStateMessage) => void;
+export type OnPlayerEmoteCallback = (emote: PlayerEmoteMessage) => void;
+export type OnCommandResultCallback = (result: CommandResultMessage) => void;
+export type OnHitCallback = (hit: HitMessage) => void;
+export type OnActionRejectedCallback = (rejection: ActionRejectedMessage) => void;
+export type OnMaintenanceCallback = (event: MaintenanceMessage) => void;
+export type OnLoginQueueCallback = (event: QueueStatusMessage | QueueAdmittedMessage) => void;
+export type OnConfigCallback = (config: ConfigMessage) => void;
+export type OnMinimapCallback = (minimap: MinimapMessage) => void;
+export type OnLeaderboardCallback = (leaderboard: LeaderboardMessage) => void;
+export type OnWorldUpdateCallback = (update: WorldUpdateMessage) => void;
 export type OnPlayerAttackCallback = (
     playerId: string,
     position: PlayerPosition
@@ -36,6 +99,27 @@ export class NetworkManager {
     private players: Record<string, PlayerState> = {};
     private lastStateSequence: number = 0;
 
+    // Outbound sequence tracking (server stamps every frame, drops show up as gaps)
+    private lastOutSequence: number = 0;
+    private missedSinceReport: number = 0;
+    private lastSequenceReportAt: number = 0;
+
+    // Message checksums (?checksum in the page URL): messages failing theirs are dropped
+    private checksums: boolean = false;
+    private corruptedSinceReport: number = 0;
+
+    // Cell streaming (?stream in the page URL): players arrive by CELL_LOAD as cells come into view
+    private streaming: boolean = false;
+
+    // RELIABLE ids already handled on this connection (oldest first)
+    private reliableSeen: Set<number> = new Set();
+
+    // Sealed messages: session key from the page URL (#key=...), sealer after CIPHER_INIT
+    private sessionKey: Uint8Array | null = sessionKeyFromLocation();
+    private sealer: SessionSealer | null = null;
+    private inbound: Promise<void> | null = null;  // message being opened; later ones queue behind
+    private outbound: Promise<void> = Promise.resolve(); // sealed sends, in order
+
     // Callback handlers
     private onPlayerJoinedCallbacks: OnPlayerJoinedCallback[] = [];
     private onPlayerLeftCallbacks: OnPlayerLeftCallback[] = [];
@@ -45,6 +129,30 @@ export class NetworkManager {
     private onCorrectionCallbacks: OnCorrectionCallback[] = [];
     private onMovementAckCallbacks: OnMovementAckCallback[] = [];
     private onPlayerAttackCallbacks: OnPlayerAttackCallback[] = [];
+    private onWorldEventCallbacks: OnWorldEventCallback[] = [];
+    private onAnnounceCallbacks: OnAnnounceCallback[] = [];
+    private onChatCallbacks: OnChatCallback[] = [];
+    private onPartyInviteCallbacks: OnPartyInviteCallback[] = [];
+    private onPartyRosterCallbacks: OnPartyRosterCallback[] = [];
+    private onPartyChatCallbacks: OnPartyChatCallback[] = [];
+    private onFriendUpdateCallbacks: OnFriendUpdateCallback[] = [];
+    private onInventoryCallbacks: OnInventoryCallback[] = [];
+    private onTradeStateCallbacks: OnTradeStateCallback[] = [];
+    private onPlayerEmoteCallbacks: OnPlayerEmoteCallback[] = [];
+    private onCommandResultCallbacks: OnCommandResultCallback[] = [];
+    private onHitCallbacks: OnHitCallback[] = [];
+    private onActionRejectedCallbacks: OnActionRejectedCallback[] = [];
+    private onMaintenanceCallbacks: OnMaintenanceCallback[] = [];
+    private onLoginQueueCallbacks: OnLoginQueueCallback[] = [];
+    private onConfigCallbacks: OnConfigCallback[] = [];
+    private onMinimapCallbacks: OnMinimapCallback[] = [];
+    private onLeaderboardCallbacks: OnLeaderboardCallback[] = [];
+    private onWorldUpdateCallbacks: OnWorldUpdateCallback[] = [];
+
+    // Reconnect state: attempts since the last successful open, stop after a final close
+    private reconnectAttempts: number = 0;
+    private reconnectTimer: ReturnType<typeof setTimeout> | null = null;
+    private sessionTakenOver: boolean = false; // SESSION_TAKEOVER arrived before the close
 
     // Reference to FPS display for ping tracking
     private fpsDisplay: any = null;
@@ -71,7 +179,7 @@ export class NetworkManager {
                         this.handleServerMessage(msg.data);
                         break;
                     case 'close':
-                        this.onSocketClose();
+                        this.onSocketClose(msg.code, msg.reason);
                         break;
                     case 'error':
                         this.onSocketError();
@@ -86,10 +194,7 @@ export class NetworkManager {
                 this.initDirectSocket();
             };
 
-            const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
-            const wsUrl = `${protocol}//${window.location.host}/ws`;
-
-            this.worker.postMessage({ type: 'connect', url: wsUrl });
+            this.worker.postMessage({ type: 'connect', url: this.socketUrl() });
         } catch (error) {
             console.warn('Failed to initialize Web Worker, falling back to direct WebSocket:', error);
             this.useWorker = false;
@@ -98,16 +203,103 @@ export class NetworkManager {
     }
 
     private initDirectSocket() {
+        this.socket = new WebSocket(this.socketUrl(), WIRE_SUBPROTOCOL);
+        this.setupSocketEvents();
+    }
+
+    // The login page hands over the session token as ?token=...; without one the
+    // server treats us as an anonymous player (if it allows them). lang picks the
+    // translation of announcements, key the tenant's world on a shared server.
+    private socketUrl(): string {
         const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
+        const query = new URLSearchParams({ lang: navigator.language });
+        const page = new URLSearchParams(window.location.search);
+        for (const name of ["token", "key"]) {
+            const value = page.get(name);
+            if (value) {
+                query.set(name, value);
+            }
+        }
+        return `${protocol}//${window.location.host}/ws?${query}`;
+    }
 
-        this.socket = new WebSocket(wsUrl);
-        this.setupSocketEvents();
+    // The server allocates our player only after JOIN and closes the connection
+    // if it does not arrive within the handshake timeout.
+    private onSocketOpen() {
+        this.reconnectAttempts = 0;
+        let capabilities = ClientCapability.DELTA_UPDATES | ClientCapability.COMPRESSION | ClientCapability.RELIABLE;
+        if (this.onMinimapCallbacks.length > 0) {
+            capabilities |= ClientCapability.MINIMAP;
+        }
+        if (this.onLeaderboardCallbacks.length > 0) {
+            capabilities |= ClientCapability.LEADERBOARD;
+        }
+        const query = new URLSearchParams(window.location.search);
+        const checksums = query.has("checksum");
+        if (checksums) {
+            capabilities |= ClientCapability.CHECKSUM;
+        }
+        // The session key is only good together with the token it was issued for.
+        if (this.sessionKey && query.get("token")) {
+            capabilities |= ClientCapability.ENCRYPTION;
+        }
+        this.streaming = query.has("stream");
+        if (this.streaming) {
+            capabilities |= ClientCapability.CELL_STREAMING;
+        }
+        this.sealer = null;
+        this.inbound = null;
+        this.reliableSeen.clear();
+        // JOIN itself goes out without a checksum; everything after it carries one.
+        this.checksums = false;
+        this.send(BinaryProtocol.encodeJoin(capabilities));
+        this.checksums = checksums;
     }
 
-    private onSocketOpen() {}
+    // The server sends a WireCloseCode before every deliberate disconnect; only the
+    // codes in WIRE_CLOSE_RECONNECT (and plain socket drops) are worth reconnecting after.
+    private onSocketClose(code?: number, reason?: string) {
+        switch (code) {
+            case WireCloseCode.UNSUPPORTED_SUBPROTOCOL:
+                console.error(`Server rejected the protocol version (${reason}); reload to update the client`);
+                return;
+            case WireCloseCode.KICKED:
+            case WireCloseCode.BANNED:
+                console.error(`Disconnected by the server: ${reason}`);
+                return;
+            case WireCloseCode.DUPLICATE_SESSION:
+                console.warn(this.sessionTakenOver
+                    ? 'Disconnected: this account connected from another tab or device'
+                    : 'This account is already playing in another tab or device');
+                return;
+            case WireCloseCode.PROTOCOL_VIOLATION:
+                console.error(`Server closed the connection: protocol violation (${reason})`);
+                return;
+        }
+        if (code !== undefined && (WIRE_CLOSE_RECONNECT.has(code) || code === CLOSE_ABNORMAL || code === CLOSE_GOING_AWAY)) {
+            this.scheduleReconnect();
+        }
+    }
 
-    private onSocketClose() {}
+    private scheduleReconnect() {
+        if (this.reconnectTimer !== null) return;
+        const delay = Math.min(RECONNECT_BASE_DELAY_MS * 2 ** this.reconnectAttempts, RECONNECT_MAX_DELAY_MS);
+        this.reconnectAttempts++;
+        this.reconnectTimer = setTimeout(() => {
+            this.reconnectTimer = null;
+            // A new connection gets a fresh player and outbound sequence from the server
+            this.playerId = "";
+            this.lastOutSequence = 0;
+            this.missedSinceReport = 0;
+            this.corruptedSinceReport = 0;
+            this.lastStateSequence = 0;
+            if (this.worker) {
+                this.worker.postMessage({ type: 'connect', url: this.socketUrl() });
+            } else {
+                this.initDirectSocket();
+            }
+        }, delay);
+    }
 
     private onSocketError() {
         // Handle connection error
@@ -127,7 +319,7 @@ export class NetworkManager {
         if (!this.socket) return;
 
         // Connection established
-        this.socket.addEventListener("open", () => {});
+        this.socket.addEventListener("open", () => this.onSocketOpen());
 
         // Receive messages from server
         this.socket.addEventListener("message", async (event) => {
@@ -146,7 +338,9 @@ export class NetworkManager {
         });
 
         // Connection closed
-        this.socket.addEventListener("close", () => {});
+        this.socket.addEventListener("close", (event) => {
+            this.onSocketClose(event.code, event.reason);
+        });
 
         // Connection error
         this.socket.addEventListener("error", () => {
@@ -154,161 +348,398 @@ export class NetworkManager {
         });
     }
 
+    // Messages are handled in arrival order: while a CIPHER_INIT or SEALED message is
+    // being processed (WebCrypto is async), later ones wait behind it.
     private handleServerMessage(data: string | ArrayBuffer) {
+        if (this.inbound) {
+            this.trackInbound(this.inbound.then(() => this.receiveServerMessage(data)));
+            return;
+        }
+        const pending = this.receiveServerMessage(data);
+        if (pending) {
+            this.trackInbound(pending);
+        }
+    }
+
+    private trackInbound(pending: Promise<void>) {
+        this.inbound = pending;
+        pending.finally(() => {
+            if (this.inbound === pending) this.inbound = null;
+        });
+    }
+
+    // Strips the sequence (and checksum) header; returns a promise while a sealed
+    // message is being set up or opened.
+    private receiveServerMessage(data: string | ArrayBuffer): Promise<void> | void {
+        // Handle binary message
+        if (!(data instanceof ArrayBuffer)) {
+            return;
+        }
+        const headerSize = this.checksums ? SEQ_HEADER_SIZE + CHECKSUM_SIZE : SEQ_HEADER_SIZE;
+        if (data.byteLength <= headerSize) {
+            return;
+        }
+        const view = new DataView(data);
+        const body = new Uint8Array(data, headerSize);
+        if (this.checksums && view.getUint32(SEQ_HEADER_SIZE, true) !== BinaryProtocol.checksum(body)) {
+            // Not even the sequence can be trusted: skip it, the next good
+            // message shows up as a gap and triggers a resync.
+            this.corruptedSinceReport++;
+            return;
+        }
+        this.trackOutSequence(view.getUint32(0, true));
+
+        switch (body[0]) {
+            case MessageType.CIPHER_INIT:
+                return this.startSealing(body);
+            case MessageType.SEALED:
+                return this.openSealed(body);
+            case MessageType.RELIABLE:
+                return this.receiveReliable(body);
+        }
+        this.dispatchMessage(body);
+    }
+
+    // [type u8][id u32][length u32][message]: ack every copy, handle each id once.
+    private receiveReliable(body: Uint8Array) {
+        if (body.length < 9) return;
+        const view = new DataView(body.buffer, body.byteOffset, body.byteLength);
+        const id = view.getUint32(1, true);
+        const length = view.getUint32(5, true);
+        if (length === 0 || 9 + length > body.length) return;
+        this.send(encodeReliableAck({ id }));
+        if (this.reliableSeen.has(id)) return;
+        this.reliableSeen.add(id);
+        if (this.reliableSeen.size > RELIABLE_SEEN_LIMIT) {
+            this.reliableSeen.delete(this.reliableSeen.values().next().value as number);
+        }
+        this.dispatchMessage(body.subarray(9, 9 + length));
+    }
+
+    private async startSealing(body: Uint8Array): Promise<void> {
+        const init = decodeCipherInit(body);
+        if (!init || !this.sessionKey) return;
+        const salt = body.slice(1, 17);
         try {
-            // Handle binary message
-            if (data instanceof ArrayBuffer) {
-                const message = BinaryProtocol.decodeMessage(
-                    new Uint8Array(data)
-                );
-
-                if (!message) {
-                    return;
-                }
+            this.sealer = await SessionSealer.create(this.sessionKey, salt, init.types.map((t) => t.type));
+        } catch (error) {
+            console.error("Cannot set up sealed messages:", error);
+        }
+    }
 
-                switch (message.type) {
-                    case "playerMovement":
+    private async openSealed(body: Uint8Array): Promise<void> {
+        const inner = this.sealer ? await this.sealer.open(body) : null;
+        if (!inner) {
+            console.error("Dropped a sealed message that failed to open");
+            return;
+        }
+        this.dispatchMessage(inner);
+    }
 
+    private dispatchMessage(body: Uint8Array) {
+        try {
+            const message = BinaryProtocol.decodeMessage(body);
 
-                        if (
-                            message.movementVector &&
-                            message.playerId !== this.playerId
-                        ) {
-                            this.onPlayerMovementCallbacks.forEach((callback) =>
-                                callback(
-                                    message.playerId,
-                                    message.movementVector.dx,
-                                    message.movementVector.dy
-                                )
-                            );
-                        }
-                        break;
+            if (!message) {
+                return;
+            }
 
-                    case "playerDirection":
-                        // Only process direction updates for other players, not ourselves
-                        if (message.playerId !== this.playerId) {
-                            this.onPlayerDirectionCallbacks.forEach(
-                                (callback) =>
-                                    callback(
-                                        message.playerId,
-                                        message.direction
-                                    )
-                            );
-                        }
-                        break;
+            switch (message.type) {
+                case "playerMovement":
 
-                    // case "initialState":
-                    //     console.log("🌍 Received initialState:", message);
-                    //     this.playerId = message.player.id;
-                    //     this.initialPosition = message.player.position;
-                    //     this.players = message.players;
-
-                    //     console.log("📋 Player ID set to:", this.playerId);
-                    //     console.log("📋 Initial position:", this.initialPosition);
-                    //     console.log("📋 All players:", this.players);
-
-                    //     // Notify about initial game state
-                    //     this.onGameStateCallbacks.forEach((callback) =>
-                    //         callback(message.players)
-                    //     );
-                    //     break;
-
-                    case "playerJoined":
-                        this.players[message.player.id] = message.player;
-                        this.onPlayerJoinedCallbacks.forEach((callback) =>
-                            callback(message.player)
-                        );
-                        break;
 
-                    case "playerLeft":
-                        delete this.players[message.playerId];
-                        this.onPlayerLeftCallbacks.forEach((callback) =>
-                            callback(message.playerId)
+                    if (
+                        message.movementVector &&
+                        message.playerId !== this.playerId
+                    ) {
+                        this.onPlayerMovementCallbacks.forEach((callback) =>
+                            callback(
+                                message.playerId,
+                                message.movementVector.dx,
+                                message.movementVector.dy
+                            )
                         );
-                        break;
-
-                    case "gameState":
-                    case "deltaGameState":
-                        if (typeof message.stateSequence === "number") {
-                            const sequence = message.stateSequence >>> 0;
-                            if (!this.isNewerStateSequence(sequence, this.lastStateSequence)) {
-                                break;
-                            }
-                            this.lastStateSequence = sequence;
+                    }
+                    break;
+
+                case "playerDirection":
+                    // Only process direction updates for other players, not ourselves
+                    if (message.playerId !== this.playerId) {
+                        this.onPlayerDirectionCallbacks.forEach(
+                            (callback) =>
+                                callback(
+                                    message.playerId,
+                                    message.direction
+                                )
+                        );
+                    }
+                    break;
+
+                // case "initialState":
+                //     console.log("🌍 Received initialState:", message);
+                //     this.playerId = message.player.id;
+                //     this.initialPosition = message.player.position;
+                //     this.players = message.players;
+
+                //     console.log("📋 Player ID set to:", this.playerId);
+                //     console.log("📋 Initial position:", this.initialPosition);
+                //     console.log("📋 All players:", this.players);
+
+                //     // Notify about initial game state
+                //     this.onGameStateCallbacks.forEach((callback) =>
+                //         callback(message.players)
+                //     );
+                //     break;
+
+                case "playerJoined":
+                    this.players[message.player.id] = message.player;
+                    this.onPlayerJoinedCallbacks.forEach((callback) =>
+                        callback(message.player)
+                    );
+                    break;
+
+                case "playerLeft":
+                    delete this.players[message.playerId];
+                    this.onPlayerLeftCallbacks.forEach((callback) =>
+                        callback(message.playerId)
+                    );
+                    break;
+
+                case "gameState":
+                case "deltaGameState":
+                    if (typeof message.stateSequence === "number") {
+                        const sequence = message.stateSequence >>> 0;
+                        // A world state split to fit maxMessageSize continues as deltas
+                        // with the same sequence.
+                        const continuation = message.type === "deltaGameState" && sequence === this.lastStateSequence;
+                        if (!continuation && !this.isNewerStateSequence(sequence, this.lastStateSequence)) {
+                            break;
                         }
+                        this.lastStateSequence = sequence;
+                    }
 
-                        // If we don't have a player ID yet, determine it from the game state
-                        if (!this.playerId && message.players) {
-                            const playerIds = Object.keys(message.players);
-                            if (playerIds.length > 0) {
-                                this.playerId = playerIds[playerIds.length - 1];
+                    // If we don't have a player ID yet, determine it from the game state
+                    if (!this.playerId && message.players) {
+                        const playerIds = Object.keys(message.players);
+                        if (playerIds.length > 0) {
+                            this.playerId = playerIds[playerIds.length - 1];
 
-                                if (message.players[this.playerId]) {
-                                    this.initialPosition = message.players[this.playerId].position;
-                                }
+                            if (message.players[this.playerId]) {
+                                this.initialPosition = message.players[this.playerId].position;
                             }
                         }
+                    }
 
-                        const prevPlayers = this.players;
+                    const prevPlayers = this.players;
 
-                        if (message.type === "deltaGameState") {
-                            // Delta: merge changed players into existing state
-                            this.players = { ...this.players };
-                            for (const [id, player] of Object.entries(message.players as Record<string, PlayerState>)) {
-                                this.players[id] = player;
-                            }
-                        } else {
-                            // Full state: replace entirely
-                            this.players = message.players;
+                    if (message.type === "deltaGameState") {
+                        // Delta: merge changed players into existing state
+                        this.players = { ...this.players };
+                        for (const [id, player] of Object.entries(message.players as Record<string, PlayerState>)) {
+                            this.players[id] = player;
                         }
+                    } else {
+                        // Full state: replace entirely
+                        this.players = message.players;
+                    }
+
+                    // Fire animation callbacks based on state changes
+                    Object.entries(message.players as Record<string, PlayerState>).forEach(([id, player]) => {
+                        const isLocalPlayer = id === this.playerId;
+                        const prev = prevPlayers[id];
+
+                        // Movement: skip local player (handled by MovementController)
+                        if (!isLocalPlayer && player.moving !== prev?.moving) {
+                            this.onPlayerMovementCallbacks.forEach((cb) =>
+                                cb(id, player.vx ?? 0, player.vy ?? 0)
+                            );
+                        }
+                        // Attack: include local player — server is authoritative, no prediction
+                        if (player.attacking && !prev?.attacking) {
+                            this.onPlayerAttackCallbacks.forEach((cb) =>
+                                cb(id, player.position)
+                            );
+                        }
+                    });
+
+                    this.onGameStateCallbacks.forEach((callback) =>
+                        callback(this.players, message.stateSequence)
+                    );
+                    break;
+
+                case "worldEvent":
+                    this.onWorldEventCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "announce":
+                    this.onAnnounceCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "chat":
+                    this.onChatCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "partyInvite":
+                    this.onPartyInviteCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "partyRoster":
+                    this.onPartyRosterCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "partyChat":
+                    this.onPartyChatCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "friendUpdate":
+                    this.onFriendUpdateCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "inventory":
+                    this.onInventoryCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "tradeState":
+                    this.onTradeStateCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "playerEmote":
+                    this.onPlayerEmoteCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "commandResult":
+                    this.onCommandResultCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "hit":
+                    this.onHitCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "actionRejected":
+                    this.onActionRejectedCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "maintenance":
+                    this.onMaintenanceCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "queueStatus":
+                case "queueAdmitted":
+                    this.onLoginQueueCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "config":
+                    // Arrives before the first GAME_STATE: our ID and the server's constants
+                    this.playerId = message.playerId;
+                    applyServerConfig(message);
+                    this.sendStreamViewport();
+                    this.onConfigCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "worldUpdate":
+                    applyWorldSize(message.worldWidth, message.worldHeight);
+                    this.sendStreamViewport();
+                    this.onWorldUpdateCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
+
+                case "cellLoad":
+                    this.players = { ...this.players, ...message.players };
+                    this.onGameStateCallbacks.forEach((callback) =>
+                        callback(this.players)
+                    );
+                    break;
+
+                case "cellUnload": {
+                    // Our own player stays even if its cell is dropped
+                    const size = message.cellSize;
+                    const unloaded = new Set<number>(
+                        message.cells.map((cell: { cellX: number; cellY: number }) => cell.cellY * 65536 + cell.cellX)
+                    );
+                    this.players = { ...this.players };
+                    for (const [id, player] of Object.entries(this.players)) {
+                        const key = Math.floor(player.position.y / size) * 65536 + Math.floor(player.position.x / size);
+                        if (id !== this.playerId && unloaded.has(key)) {
+                            delete this.players[id];
+                        }
+                    }
+                    this.onGameStateCallbacks.forEach((callback) =>
+                        callback(this.players)
+                    );
+                    break;
+                }
 
-                        // Fire animation callbacks based on state changes
-                        Object.entries(message.players as Record<string, PlayerState>).forEach(([id, player]) => {
-                            const isLocalPlayer = id === this.playerId;
-                            const prev = prevPlayers[id];
-
-                            // Movement: skip local player (handled by MovementController)
-                            if (!isLocalPlayer && player.moving !== prev?.moving) {
-                                this.onPlayerMovementCallbacks.forEach((cb) =>
-                                    cb(id, player.vx ?? 0, player.vy ?? 0)
-                                );
-                            }
-                            // Attack: include local player — server is authoritative, no prediction
-                            if (player.attacking && !prev?.attacking) {
-                                this.onPlayerAttackCallbacks.forEach((cb) =>
-                                    cb(id, player.position)
-                                );
-                            }
-                        });
+                case "minimap":
+                    this.onMinimapCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
 
-                        this.onGameStateCallbacks.forEach((callback) =>
-                            callback(this.players, message.stateSequence)
-                        );
-                        break;
+                case "leaderboard":
+                    this.onLeaderboardCallbacks.forEach((callback) =>
+                        callback(message)
+                    );
+                    break;
 
-                    case "movementAck":
+                case "sessionTakeover":
+                    this.sessionTakenOver = true;
+                    break;
 
-                        if (message.playerId === this.playerId) {
-                            this.onMovementAckCallbacks.forEach((callback) =>
-                                callback(message.position, message.inputSequence)
-                            );
-                        }
-                        break;
+                case "movementAck":
 
-                    // case "correction":
-                    //     if (message.playerId === this.playerId) {
-                    //         this.onCorrectionCallbacks.forEach((callback) =>
-                    //             callback(message.position)
-                    //         );
-                    //     }
-                    //     break;
-
-                    case "playerAttack":
-                        this.onPlayerAttackCallbacks.forEach((callback) =>
-                            callback(message.playerId, message.position)
+                    if (message.playerId === this.playerId) {
+                        this.onMovementAckCallbacks.forEach((callback) =>
+                            callback(message.position, message.inputSequence)
                         );
-                        break;
-                }
+                    }
+                    break;
+
+                // case "correction":
+                //     if (message.playerId === this.playerId) {
+                //         this.onCorrectionCallbacks.forEach((callback) =>
+                //             callback(message.position)
+                //         );
+                //     }
+                //     break;
+
+                case "playerAttack":
+                    this.onPlayerAttackCallbacks.forEach((callback) =>
+                        callback(message.playerId, message.position)
+                    );
+                    break;
             }
         } catch (error) {
             // Handle any errors in message processing
@@ -348,11 +779,177 @@ export class NetworkManager {
         this.onPlayerAttackCallbacks.push(callback);
     }
 
+    public onWorldEvent(callback: OnWorldEventCallback): void {
+        this.onWorldEventCallbacks.push(callback);
+    }
+
+    // Message of the day after each (re)connect and admin announcements
+    public onAnnounce(callback: OnAnnounceCallback): void {
+        this.onAnnounceCallbacks.push(callback);
+    }
+
+    // Chat lines of every player, ours included
+    public onChat(callback: OnChatCallback): void {
+        this.onChatCallbacks.push(callback);
+    }
+
+    // Party invites, roster changes and party chat; refused party actions arrive
+    // through onCommandResult
+    public onPartyInvite(callback: OnPartyInviteCallback): void {
+        this.onPartyInviteCallbacks.push(callback);
+    }
+
+    public onPartyRoster(callback: OnPartyRosterCallback): void {
+        this.onPartyRosterCallbacks.push(callback);
+    }
+
+    public onPartyChat(callback: OnPartyChatCallback): void {
+        this.onPartyChatCallbacks.push(callback);
+    }
+
+    // Our friend list, entry by entry, and our friends' presence; signed-in players
+    // only. Refused friend actions arrive through onCommandResult
+    public onFriendUpdate(callback: OnFriendUpdateCallback): void {
+        this.onFriendUpdateCallbacks.push(callback);
+    }
+
+    // Our items, whole, after JOIN and every change; signed-in players only
+    public onInventory(callback: OnInventoryCallback): void {
+        this.onInventoryCallbacks.push(callback);
+    }
+
+    // Trade requests to answer and our trade after every change. Refused trade
+    // actions arrive through onCommandResult
+    public onTradeState(callback: OnTradeStateCallback): void {
+        this.onTradeStateCallbacks.push(callback);
+    }
+
+    // Emotes of the players near us, ours included. A refused emote arrives through
+    // onActionRejected (action = MessageType.EMOTE)
+    public onPlayerEmote(callback: OnPlayerEmoteCallback): void {
+        this.onPlayerEmoteCallbacks.push(callback);
+    }
+
+    // Answers to console commands (chat lines starting with "/") and refused chat lines
+    public onCommandResult(callback: OnCommandResultCallback): void {
+        this.onCommandResultCallbacks.push(callback);
+    }
+
+    // Attacks that landed, ours on others and others' on us (damage numbers, hit effects)
+    public onHit(callback: OnHitCallback): void {
+        this.onHitCallbacks.push(callback);
+    }
+
+    // Inputs the server refused (attack in cooldown, stunned, dead, rate limited)
+    public onActionRejected(callback: OnActionRejectedCallback): void {
+        this.onActionRejectedCallbacks.push(callback);
+    }
+
+    public onMaintenance(callback: OnMaintenanceCallback): void {
+        this.onMaintenanceCallbacks.push(callback);
+    }
+
+    // The server is full and our JOIN waits in its login queue: QUEUE_STATUS updates
+    // until QUEUE_ADMITTED, after which the game starts as after any JOIN
+    public onLoginQueue(callback: OnLoginQueueCallback): void {
+        this.onLoginQueueCallbacks.push(callback);
+    }
+
+    // Called after the server's CONFIG has been applied to the shared gameConfig
+    public onConfig(callback: OnConfigCallback): void {
+        this.onConfigCallbacks.push(callback);
+    }
+
+    // Called after a runtime WORLD_UPDATE has been applied to the shared gameConfig
+    public onWorldUpdate(callback: OnWorldUpdateCallback): void {
+        this.onWorldUpdateCallbacks.push(callback);
+    }
+
+    // Subscribes to the ~1 Hz MINIMAP density grid. The subscription is a JOIN flag,
+    // so register before connect() (it also applies to every reconnect).
+    public onMinimap(callback: OnMinimapCallback): void {
+        this.onMinimapCallbacks.push(callback);
+    }
+
+    // Subscribes to the periodic LEADERBOARD of every metric; a JOIN flag like onMinimap,
+    // so register before connect().
+    public onLeaderboard(callback: OnLeaderboardCallback): void {
+        this.onLeaderboardCallbacks.push(callback);
+    }
+
+    // Sends a chat line; a line starting with "/" runs a console command instead
+    public sendChat(text: string): void {
+        const line = text.trim();
+        if (!line) return;
+        this.send(BinaryProtocol.encodeChat(line));
+    }
+
+    public invitePlayer(playerId: string): void {
+        this.send(BinaryProtocol.encodeParty(PartyAction.INVITE, Number(playerId)));
+    }
+
+    public acceptPartyInvite(inviterId: string): void {
+        this.send(BinaryProtocol.encodeParty(PartyAction.ACCEPT, Number(inviterId)));
+    }
+
+    public leaveParty(): void {
+        this.send(BinaryProtocol.encodeParty(PartyAction.LEAVE));
+    }
+
+    // Sends a chat line to our party only
+    public sendPartyChat(text: string): void {
+        const line = text.trim();
+        if (!line) return;
+        this.send(BinaryProtocol.encodePartyChat(line));
+    }
+
+    // Asks an account to be friends, or accepts its request
+    public addFriend(account: string): void {
+        this.send(BinaryProtocol.encodeFriend(FriendAction.ADD, account));
+    }
+
+    // Ends a friendship, or declines / withdraws a request
+    public removeFriend(account: string): void {
+        this.send(BinaryProtocol.encodeFriend(FriendAction.REMOVE, account));
+    }
+
+    // Asks a player to trade
+    public requestTrade(playerId: string): void {
+        this.send(BinaryProtocol.encodeTrade(TradeAction.REQUEST, Number(playerId)));
+    }
+
+    // Opens the trade a player asked for
+    public acceptTrade(playerId: string): void {
+        this.send(BinaryProtocol.encodeTrade(TradeAction.ACCEPT, Number(playerId)));
+    }
+
+    // Sets how many of an item we offer (0 withdraws it); withdraws both confirmations
+    public offerTradeItem(item: number, count: number): void {
+        this.send(BinaryProtocol.encodeTrade(TradeAction.OFFER, 0, item, count));
+    }
+
+    // Agrees to both offers as they are; the swap happens once the partner agrees too
+    public confirmTrade(): void {
+        this.send(BinaryProtocol.encodeTrade(TradeAction.CONFIRM));
+    }
+
+    // Plays an emote of the gameConfig catalog; the server answers with PLAYER_EMOTE
+    public sendEmote(emoteId: number): void {
+        this.send(BinaryProtocol.encodeEmote(emoteId));
+    }
+
+    // Ends our trade, or declines the request of playerId
+    public cancelTrade(playerId = "0"): void {
+        this.send(BinaryProtocol.encodeTrade(TradeAction.CANCEL, Number(playerId)));
+    }
+
     // Send movement to server
-    public sendMovement(dx: number, dy: number, inputSequence?: number): void {
+    // axis: analog input (-127..127 per axis), e.g. from a gamepad stick; omitted = digital
+    public sendMovement(dx: number, dy: number, inputSequence?: number, axis?: { x: number; y: number }): void {
         const moveMsg = {
             type: "move" as const,
             movementVector: { dx, dy },
+            axis,
             inputSequence: inputSequence || 0,
             position: { x: 0, y: 0 },
         };
@@ -365,11 +962,7 @@ export class NetworkManager {
         // Use binary protocol for frequent updates
         const binaryData = BinaryProtocol.encodeMove(moveMsg);
 
-        if (this.worker) {
-            this.worker.postMessage({ type: 'send', data: binaryData });
-        } else if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-            this.socket.send(binaryData as Uint8Array<ArrayBuffer>);
-        }
+        this.send(binaryData);
     }
 
     // Send direction change to server
@@ -382,6 +975,70 @@ export class NetworkManager {
         // Use binary protocol for frequent updates
         const binaryData = BinaryProtocol.encodeDirection(dirMsg);
 
+        this.send(binaryData);
+    }
+
+    // Detect gaps in the server's outbound sequence. A gap means a delta/join/leave
+    // was lost, so local state may be stale: ask for a full state right away.
+    // Otherwise report periodically so the server can track loss.
+    private trackOutSequence(sequence: number): void {
+        const now = Date.now();
+        let gap = 0;
+        if (this.lastOutSequence !== 0) {
+            const delta = (sequence - this.lastOutSequence) >>> 0;
+            if (delta === 0 || delta >= 0x80000000) return; // duplicate / stale
+            gap = delta - 1;
+        }
+        this.lastOutSequence = sequence;
+        this.missedSinceReport += gap;
+
+        if (gap > 0) {
+            // Accept the resync GAME_STATE even if its stateSequence is not newer.
+            this.lastStateSequence = 0;
+            this.sendSequenceReport(SEQUENCE_REPORT_RESYNC, now);
+        } else if (now - this.lastSequenceReportAt >= SEQUENCE_REPORT_INTERVAL_MS) {
+            this.sendSequenceReport(0, now);
+        }
+    }
+
+    private sendSequenceReport(flags: number, now: number): void {
+        const binaryData = BinaryProtocol.encodeSequenceReport(
+            this.lastOutSequence, this.missedSinceReport, flags, this.corruptedSinceReport);
+        this.missedSinceReport = 0;
+        this.corruptedSinceReport = 0;
+        this.lastSequenceReportAt = now;
+
+        this.send(binaryData);
+    }
+
+    // A streaming client asks for a world-sized view; the server centres it on us
+    // (clamped to the world) and loads the cells under it as we move.
+    private sendStreamViewport(): void {
+        if (!this.streaming) return;
+        this.send(encodeViewportUpdate({
+            width: WORLD.virtualSize.width,
+            height: WORLD.virtualSize.height,
+        }));
+    }
+
+    // Sends one message, prefixed with its checksum once CHECKSUM was negotiated
+    // and sealed if CIPHER_INIT listed its type.
+    private send(binaryData: Uint8Array): void {
+        const sealer = this.sealer;
+        if (sealer && sealer.types.has(binaryData[0])) {
+            this.outbound = this.outbound
+                .then(() => sealer.seal(binaryData))
+                .then((sealed) => this.transmit(sealed))
+                .catch((error) => console.error("Cannot seal message:", error));
+            return;
+        }
+        this.transmit(binaryData);
+    }
+
+    private transmit(binaryData: Uint8Array): void {
+        if (this.checksums) {
+            binaryData = BinaryProtocol.withChecksum(binaryData);
+        }
         if (this.worker) {
             this.worker.postMessage({ type: 'send', data: binaryData });
         } else if (this.socket && this.socket.readyState === WebSocket.OPEN) {
@@ -391,22 +1048,14 @@ export class NetworkManager {
 
     // Send attack to server
     public sendAttack(binaryData: Uint8Array): void {
-        if (this.worker) {
-            this.worker.postMessage({ type: 'send', data: binaryData });
-        } else if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-            this.socket.send(binaryData as Uint8Array<ArrayBuffer>);
-        }
+        this.send(binaryData);
     }
 
     // Send attack end to server
     public sendAttackEnd(): void {
         const binaryData = BinaryProtocol.encodeAttackEnd();
 
-        if (this.worker) {
-            this.worker.postMessage({ type: 'send', data: binaryData });
-        } else if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-            this.socket.send(binaryData as Uint8Array<ArrayBuffer>);
-        }
+        this.send(binaryData);
     }
 
     // Get player ID
@@ -1,5 +1,7 @@
 // Web Worker for handling WebSocket to avoid blocking main thread
 
+import { WIRE_SUBPROTOCOL } from './protocol/generated';
+
 interface WorkerMessage {
     type: 'connect' | 'send' | 'disconnect';
     url?: string;
@@ -10,6 +12,8 @@ interface SocketMessage {
     type: 'message' | 'open' | 'close' | 'error';
     data?: any;
     event?: any;
+    code?: number;
+    reason?: string;
 }
 
 let socket: WebSocket | null = null;
@@ -38,7 +42,7 @@ self.onmessage = (e: MessageEvent<WorkerMessage>) => {
 };
 
 function connect(url: string) {
-    socket = new WebSocket(url);
+    socket = new WebSocket(url, WIRE_SUBPROTOCOL);
 
     socket.onopen = () => {
         postMessage({ type: 'open' });
@@ -55,8 +59,8 @@ function connect(url: string) {
         postMessage({ type: 'message', data });
     };
 
-    socket.onclose = () => {
-        postMessage({ type: 'close' });
+    socket.onclose = (event) => {
+        postMessage({ type: 'close', code: event.code, reason: event.reason });
     };
 
     socket.onerror = (error) => {
@@ -10,9 +10,74 @@ import {
     PlayerLeftMessage,
     AttackMessage,
     PlayerAttackMessage,
+    WorldEventMessage,
+    AnnounceMessage,
+    ChatMessage,
+    CommandResultMessage,
+    HitMessage,
+    ActionRejectedMessage,
+    MaintenanceMessage,
+    SessionTakeoverMessage,
+    ConfigMessage,
+    MinimapMessage,
+    LeaderboardMessage,
+    PartyInviteMessage,
+    PartyRosterMessage,
+    PartyChatMessage,
+    FriendUpdateMessage,
+    InventoryMessage,
+    TradeStateMessage,
+    PlayerEmoteMessage,
+    OriginMessage,
+    QueueStatusMessage,
+    QueueAdmittedMessage,
+    WorldUpdateMessage,
+    CellLoadMessage,
+    CellUnloadMessage,
+    CHECKSUM_SIZE,
 } from "./messages";
+import {
+    decodeAnnounce,
+    decodeCellLoad,
+    decodeCellUnload,
+    decodeChatMessage,
+    decodeCommandResult,
+    decodeConfig,
+    decodeHit,
+    decodeActionRejected,
+    decodeMinimap,
+    decodeLeaderboard,
+    decodeOrigin,
+    decodeQueueStatus,
+    decodeQueueAdmitted,
+    decodePartyInvite,
+    decodePartyRoster,
+    decodePartyChatMessage,
+    decodeFriendUpdate,
+    decodeInventory,
+    decodeTradeState,
+    decodePlayerEmote,
+    decodeWorldEvent,
+    decodeWorldUpdate,
+    encodeChat,
+    encodeParty,
+    encodePartyChat,
+    encodeFriend,
+    encodeTrade,
+    encodeEmote,
+} from "./generated";
 
 export class BinaryProtocol {
+    // Positions arrive in 1/2^positionBits world units (CONFIG); 1 until CONFIG says otherwise.
+    private static positionScale = 1;
+    // Large worlds: the ORIGIN point positions are relative to, world units.
+    private static originX = 0;
+    private static originY = 0;
+
+    private static position(x: number, y: number): { x: number; y: number } {
+        return { x: this.originX + x * this.positionScale, y: this.originY + y * this.positionScale };
+    }
+
     // Helper methods for common operations
     private static packMovement(dx: number, dy: number): number {
         let packed = 0;
@@ -36,16 +101,25 @@ export class BinaryProtocol {
 
     // Encode client messages
     static encodeMove(moveMsg: MoveMessage): Uint8Array {
-        const buffer = new ArrayBuffer(6);
+        const axis = moveMsg.axis;
+        const buffer = new ArrayBuffer(axis ? 8 : 6);
         const view = new DataView(buffer);
         view.setUint8(0, MessageType.MOVE);
 
-        const dx = Math.sign(moveMsg.movementVector.dx) || 0;
-        const dy = Math.sign(moveMsg.movementVector.dy) || 0;
+        // With analog axes the packed direction is their sign, as the server requires
+        const clampAxis = (v: number) => Math.max(-127, Math.min(127, Math.trunc(v)));
+        const ax = axis ? clampAxis(axis.x) : 0;
+        const ay = axis ? clampAxis(axis.y) : 0;
+        const dx = (axis ? Math.sign(ax) : Math.sign(moveMsg.movementVector.dx)) || 0;
+        const dy = (axis ? Math.sign(ay) : Math.sign(moveMsg.movementVector.dy)) || 0;
         const packed = this.packMovement(dx, dy);
 
         view.setUint8(1, packed);
         view.setUint32(2, moveMsg.inputSequence, true);
+        if (axis) {
+            view.setInt8(6, ax);
+            view.setInt8(7, ay);
+        }
 
         return new Uint8Array(buffer);
     }
@@ -59,14 +133,99 @@ export class BinaryProtocol {
     }
 
     static encodeAttack(msg: AttackMessage): Uint8Array {
-        const buffer = new ArrayBuffer(9);
+        const buffer = new ArrayBuffer(3);
         const view = new DataView(buffer);
         view.setUint8(0, MessageType.ATTACK);
-        view.setFloat32(1, msg.position.x, true);
-        view.setFloat32(5, msg.position.y, true);
+        view.setInt8(1, msg.aim?.x ?? 0);
+        view.setInt8(2, msg.aim?.y ?? 0);
+        return new Uint8Array(buffer);
+    }
+
+    // JOIN handshake: must be the first message after the socket opens.
+    // maxMessageSize 0 = no limit on world-state messages.
+    static encodeJoin(capabilities: number, maxMessageSize = 0): Uint8Array {
+        const buffer = new ArrayBuffer(6);
+        const view = new DataView(buffer);
+        view.setUint8(0, MessageType.JOIN);
+        view.setUint8(1, capabilities);
+        view.setUint32(2, maxMessageSize, true);
+        return new Uint8Array(buffer);
+    }
+
+    // CHAT: layout in the generated codec, which cuts the text to its limit on a character boundary
+    static encodeChat(text: string): Uint8Array {
+        return encodeChat({ text });
+    }
+
+    // PARTY: action is a PartyAction; playerId is ignored for LEAVE
+    static encodeParty(action: number, playerId = 0): Uint8Array {
+        return encodeParty({ action, playerId });
+    }
+
+    // PARTY_CHAT: cut to the chat limit like CHAT
+    static encodePartyChat(text: string): Uint8Array {
+        return encodePartyChat({ text });
+    }
+
+    // FRIEND: action is a FriendAction; the account is at most 64 bytes
+    static encodeFriend(action: number, account: string): Uint8Array {
+        return encodeFriend({ action, account });
+    }
+
+    // TRADE: action is a TradeAction; playerId for REQUEST / ACCEPT / CANCEL, item and
+    // count for OFFER
+    static encodeTrade(action: number, playerId = 0, item = 0, count = 0): Uint8Array {
+        return encodeTrade({ action, playerId, item, count });
+    }
+
+    // EMOTE: the id of a gameConfig "emotes" entry
+    static encodeEmote(emoteId: number): Uint8Array {
+        return encodeEmote({ emoteId });
+    }
+
+    // Outbound-sequence loss report; flags bit 0 asks the server for a full GAME_STATE.
+    // corrupted counts messages dropped for a bad checksum (CHECKSUM clients).
+    static encodeSequenceReport(lastSequence: number, missed: number, flags: number, corrupted = 0): Uint8Array {
+        const buffer = new ArrayBuffer(14);
+        const view = new DataView(buffer);
+        view.setUint8(0, MessageType.SEQUENCE_REPORT);
+        view.setUint32(1, lastSequence >>> 0, true);
+        view.setUint32(5, missed >>> 0, true);
+        view.setUint8(9, flags);
+        view.setUint32(10, corrupted >>> 0, true);
         return new Uint8Array(buffer);
     }
 
+    // CRC-32C (Castagnoli) of data, as the server computes it (protocol/checksum.go)
+    static checksum(data: Uint8Array): number {
+        const table = BinaryProtocol.crcTable;
+        let crc = 0xffffffff;
+        for (let i = 0; i < data.length; i++) {
+            crc = table[(crc ^ data[i]) & 0xff] ^ (crc >>> 8);
+        }
+        return (crc ^ 0xffffffff) >>> 0;
+    }
+
+    // [crc u32][message]: how CHECKSUM clients send every message after JOIN
+    static withChecksum(data: Uint8Array): Uint8Array {
+        const out = new Uint8Array(CHECKSUM_SIZE + data.length);
+        new DataView(out.buffer).setUint32(0, BinaryProtocol.checksum(data), true);
+        out.set(data, CHECKSUM_SIZE);
+        return out;
+    }
+
+    private static readonly crcTable: Uint32Array = (() => {
+        const table = new Uint32Array(256);
+        for (let n = 0; n < 256; n++) {
+            let c = n;
+            for (let k = 0; k < 8; k++) {
+                c = c & 1 ? 0x82f63b78 ^ (c >>> 1) : c >>> 1;
+            }
+            table[n] = c >>> 0;
+        }
+        return table;
+    })();
+
     static encodeAttackEnd(): Uint8Array {
         const buffer = new ArrayBuffer(1);
         const view = new DataView(buffer);
@@ -78,7 +237,8 @@ export class BinaryProtocol {
     static decodeMessage(data: Uint8Array): any {
         if (data.length === 0) return null;
 
-        const view = new DataView(data.buffer);
+        // data may be a view past the sequence header — respect its offset
+        const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
         const messageType = view.getUint8(0);
 
         switch (messageType) {
@@ -91,6 +251,30 @@ export class BinaryProtocol {
             case MessageType.PLAYER_JOINED: return this.decodePlayerJoined(data, view);
             case MessageType.PLAYER_LEFT: return this.decodePlayerLeft(data, view);
             case MessageType.MOVEMENT_ACK: return this.decodeMovementAck(data, view);
+            case MessageType.WORLD_EVENT: return this.decodeWorldEvent(data);
+            case MessageType.MAINTENANCE: return this.decodeMaintenance(data, view);
+            case MessageType.SESSION_TAKEOVER: return this.decodeSessionTakeover(data, view);
+            case MessageType.CONFIG: return this.decodeConfig(data);
+            case MessageType.MINIMAP: return this.decodeMinimap(data);
+            case MessageType.WORLD_UPDATE: return this.decodeWorldUpdate(data);
+            case MessageType.CELL_LOAD: return this.decodeCellLoad(data);
+            case MessageType.CELL_UNLOAD: return this.decodeCellUnload(data);
+            case MessageType.ANNOUNCE: return this.decodeAnnounce(data);
+            case MessageType.CHAT_MESSAGE: return this.decodeChatMessage(data);
+            case MessageType.COMMAND_RESULT: return this.decodeCommandResult(data);
+            case MessageType.HIT: return this.decodeHit(data);
+            case MessageType.ACTION_REJECTED: return this.decodeActionRejected(data);
+            case MessageType.LEADERBOARD: return this.decodeLeaderboard(data);
+            case MessageType.PARTY_INVITE: return this.decodePartyInvite(data);
+            case MessageType.PARTY_ROSTER: return this.decodePartyRoster(data);
+            case MessageType.PARTY_CHAT_MESSAGE: return this.decodePartyChatMessage(data);
+            case MessageType.FRIEND_UPDATE: return this.decodeFriendUpdate(data);
+            case MessageType.INVENTORY: return this.decodeInventory(data);
+            case MessageType.TRADE_STATE: return this.decodeTradeState(data);
+            case MessageType.PLAYER_EMOTE: return this.decodePlayerEmote(data);
+            case MessageType.ORIGIN: return this.decodeOrigin(data);
+            case MessageType.QUEUE_STATUS: return this.decodeQueueStatus(data);
+            case MessageType.QUEUE_ADMITTED: return this.decodeQueueAdmitted(data);
 
             // Broadcast message types from server
             case 255: return this.decodePlayerMovementBroadcast(data, view);
@@ -164,6 +348,254 @@ export class BinaryProtocol {
         };
     }
 
+    // WORLD_EVENT: layout in the generated codec
+    private static decodeWorldEvent(data: Uint8Array): WorldEventMessage | null {
+        const wire = decodeWorldEvent(data);
+        if (!wire) return null;
+        return { type: 'worldEvent', ...wire, active: wire.active === 1 };
+    }
+
+    // ANNOUNCE: layout in the generated codec
+    private static decodeAnnounce(data: Uint8Array): AnnounceMessage | null {
+        const wire = decodeAnnounce(data);
+        if (!wire) return null;
+        return { type: 'announce', ...wire };
+    }
+
+    // CHAT_MESSAGE: layout in the generated codec
+    private static decodeChatMessage(data: Uint8Array): ChatMessage | null {
+        const wire = decodeChatMessage(data);
+        if (!wire) return null;
+        return { type: 'chat', playerId: wire.playerId.toString(), text: wire.text };
+    }
+
+    // COMMAND_RESULT: layout in the generated codec
+    private static decodeCommandResult(data: Uint8Array): CommandResultMessage | null {
+        const wire = decodeCommandResult(data);
+        if (!wire) return null;
+        return { type: 'commandResult', ...wire };
+    }
+
+    // HIT: layout in the generated codec
+    private static decodeHit(data: Uint8Array): HitMessage | null {
+        const wire = decodeHit(data);
+        if (!wire) return null;
+        return {
+            type: 'hit',
+            ...wire,
+            attackerId: wire.attackerId.toString(),
+            victimId: wire.victimId.toString(),
+        };
+    }
+
+    // ACTION_REJECTED: layout in the generated codec
+    private static decodeActionRejected(data: Uint8Array): ActionRejectedMessage | null {
+        const wire = decodeActionRejected(data);
+        if (!wire) return null;
+        return { type: 'actionRejected', ...wire };
+    }
+
+    // PLAYER_EMOTE: layout in the generated codec
+    private static decodePlayerEmote(data: Uint8Array): PlayerEmoteMessage | null {
+        const wire = decodePlayerEmote(data);
+        if (!wire) return null;
+        return { type: 'playerEmote', playerId: wire.playerId.toString(), emoteId: wire.emoteId };
+    }
+
+    // MAINTENANCE: [18][phase u8][countdownMs u32]
+    private static decodeMaintenance(data: Uint8Array, view: DataView): MaintenanceMessage | null {
+        if (data.length < 6) return null;
+        return {
+            type: 'maintenance',
+            phase: view.getUint8(1),
+            countdownMs: view.getUint32(2, true),
+        };
+    }
+
+    // SESSION_TAKEOVER: [19][playerId u32]
+    private static decodeSessionTakeover(data: Uint8Array, view: DataView): SessionTakeoverMessage | null {
+        if (data.length < 5) return null;
+        return {
+            type: 'sessionTakeover',
+            playerId: view.getUint32(1, true).toString(),
+        };
+    }
+
+    // CONFIG: layout in the generated codec
+    private static decodeConfig(data: Uint8Array): ConfigMessage | null {
+        const wire = decodeConfig(data);
+        if (!wire) return null;
+        this.positionScale = 1 / (1 << (wire.positionBits ?? 0));
+        this.originX = this.originY = 0; // a new session; ORIGIN follows in large worlds
+        return {
+            type: 'config',
+            ...wire,
+            playerId: wire.playerId.toString(),
+            worldWidth: wire.worldWidth + (wire.worldWidthHigh ?? 0) * 65536,
+            worldHeight: wire.worldHeight + (wire.worldHeightHigh ?? 0) * 65536,
+        };
+    }
+
+    // WORLD_UPDATE: layout in the generated codec
+    private static decodeWorldUpdate(data: Uint8Array): WorldUpdateMessage | null {
+        const wire = decodeWorldUpdate(data);
+        if (!wire) return null;
+        return {
+            type: 'worldUpdate',
+            ...wire,
+            worldWidth: wire.worldWidth + (wire.worldWidthHigh ?? 0) * 65536,
+            worldHeight: wire.worldHeight + (wire.worldHeightHigh ?? 0) * 65536,
+        };
+    }
+
+    // ORIGIN: layout in the generated codec; moves the point later positions decode from
+    private static decodeOrigin(data: Uint8Array): OriginMessage | null {
+        const wire = decodeOrigin(data);
+        if (!wire) return null;
+        this.originX = wire.originX;
+        this.originY = wire.originY;
+        return { type: 'origin', ...wire };
+    }
+
+    // QUEUE_STATUS / QUEUE_ADMITTED: layouts in the generated codec
+    private static decodeQueueStatus(data: Uint8Array): QueueStatusMessage | null {
+        const wire = decodeQueueStatus(data);
+        if (!wire) return null;
+        return { type: 'queueStatus', ...wire };
+    }
+
+    private static decodeQueueAdmitted(data: Uint8Array): QueueAdmittedMessage | null {
+        const wire = decodeQueueAdmitted(data);
+        if (!wire) return null;
+        return { type: 'queueAdmitted', ...wire };
+    }
+
+    // CELL_LOAD: layout in the generated codec; entries are world-state player entries
+    private static decodeCellLoad(data: Uint8Array): CellLoadMessage | null {
+        const wire = decodeCellLoad(data);
+        if (!wire) return null;
+        const players: Record<string, PlayerState> = {};
+        for (const { id, x, y, vx, vy, flags } of wire.players) {
+            const playerId = id.toString();
+            players[playerId] = {
+                id: playerId,
+                direction: (flags & 0x80) ? 1 : -1,
+                moving: vx !== 0 || vy !== 0,
+                attacking: (flags & 0x1F) === 1, // server: 1=attack
+                spawnProtected: (flags & 0x40) !== 0,
+                safeZone: (flags & 0x20) !== 0,
+                position: this.position(x, y),
+                vx,
+                vy,
+            };
+        }
+        return { type: 'cellLoad', cellSize: wire.cellSize, cells: wire.cells, players };
+    }
+
+    // CELL_UNLOAD: layout in the generated codec
+    private static decodeCellUnload(data: Uint8Array): CellUnloadMessage | null {
+        const wire = decodeCellUnload(data);
+        if (!wire) return null;
+        return { type: 'cellUnload', ...wire };
+    }
+
+    // MINIMAP: layout in the generated codec; the run-length encoded cells are expanded
+    private static decodeMinimap(data: Uint8Array): MinimapMessage | null {
+        const wire = decodeMinimap(data);
+        if (!wire) return null;
+        const counts = new Uint8Array(wire.cols * wire.rows);
+        let cell = 0;
+        for (const { run, count } of wire.runs) {
+            const end = Math.min(cell + run, counts.length);
+            counts.fill(count, cell, end);
+            cell = end;
+        }
+        return {
+            type: 'minimap',
+            cols: wire.cols,
+            rows: wire.rows,
+            cellWidth: wire.cellWidth,
+            cellHeight: wire.cellHeight,
+            counts,
+        };
+    }
+
+    // LEADERBOARD: layout in the generated codec
+    private static decodeLeaderboard(data: Uint8Array): LeaderboardMessage | null {
+        const wire = decodeLeaderboard(data);
+        if (!wire) return null;
+        return {
+            type: 'leaderboard',
+            metric: wire.metric,
+            entries: wire.entries.map(({ playerId, score }) => ({
+                playerId: playerId === 0 ? null : playerId.toString(),
+                score,
+            })),
+        };
+    }
+
+    // PARTY_INVITE: layout in the generated codec
+    private static decodePartyInvite(data: Uint8Array): PartyInviteMessage | null {
+        const wire = decodePartyInvite(data);
+        if (!wire) return null;
+        return { type: 'partyInvite', inviterId: wire.inviterId.toString() };
+    }
+
+    // PARTY_ROSTER: layout in the generated codec
+    private static decodePartyRoster(data: Uint8Array): PartyRosterMessage | null {
+        const wire = decodePartyRoster(data);
+        if (!wire) return null;
+        return {
+            type: 'partyRoster',
+            partyId: wire.partyId,
+            leaderId: wire.partyId === 0 ? null : wire.leaderId.toString(),
+            members: wire.members.map(({ playerId }) => playerId.toString()),
+        };
+    }
+
+    // PARTY_CHAT_MESSAGE: layout in the generated codec
+    private static decodePartyChatMessage(data: Uint8Array): PartyChatMessage | null {
+        const wire = decodePartyChatMessage(data);
+        if (!wire) return null;
+        return { type: 'partyChat', playerId: wire.playerId.toString(), text: wire.text };
+    }
+
+    // FRIEND_UPDATE: layout in the generated codec; 255 = zone unknown
+    private static decodeFriendUpdate(data: Uint8Array): FriendUpdateMessage | null {
+        const wire = decodeFriendUpdate(data);
+        if (!wire) return null;
+        return {
+            type: 'friendUpdate',
+            status: wire.status,
+            account: wire.account,
+            playerId: wire.playerId === 0 ? null : wire.playerId.toString(),
+            zone: wire.zoneRow === 255 ? null : { row: wire.zoneRow, col: wire.zoneCol },
+        };
+    }
+
+    // INVENTORY: layout in the generated codec
+    private static decodeInventory(data: Uint8Array): InventoryMessage | null {
+        const wire = decodeInventory(data);
+        if (!wire) return null;
+        return { type: 'inventory', items: wire.items };
+    }
+
+    // TRADE_STATE: layout in the generated codec; side 1 = offered by the partner
+    private static decodeTradeState(data: Uint8Array): TradeStateMessage | null {
+        const wire = decodeTradeState(data);
+        if (!wire) return null;
+        return {
+            type: 'tradeState',
+            tradeId: wire.tradeId,
+            status: wire.status,
+            partnerId: wire.partnerId.toString(),
+            confirmed: (wire.confirmed & 0x01) !== 0,
+            partnerConfirmed: (wire.confirmed & 0x02) !== 0,
+            offers: wire.offers.filter(({ side }) => side === 0).map(({ item, count }) => ({ item, count })),
+            partnerOffers: wire.offers.filter(({ side }) => side === 1).map(({ item, count }) => ({ item, count })),
+        };
+    }
+
     private static decodeGameState(data: Uint8Array, view: DataView): GameStateMessage {
         const { players, stateSequence } = this.decodePlayerBlock(data, view);
         return {
@@ -210,7 +642,7 @@ export class BinaryProtocol {
             offset++;
 
             const direction = (flags & 0x80) ? 1 : -1;
-            const state = flags & 0x7F;
+            const state = flags & 0x1F; // bit 5 = safe zone, bit 6 = spawn protection
             const moving = vx !== 0 || vy !== 0;
             const attacking = state === 1; // server: 1=attack
 
@@ -219,7 +651,9 @@ export class BinaryProtocol {
                 direction,
                 moving,
                 attacking,
-                position: { x, y },
+                spawnProtected: (flags & 0x40) !== 0,
+                safeZone: (flags & 0x20) !== 0,
+                position: this.position(x, y),
                 vx,
                 vy,
             };
@@ -264,7 +698,7 @@ export class BinaryProtocol {
 
         const flags = view.getUint8(offset);
         const direction = (flags & 0x80) ? 1 : -1;
-        const state = flags & 0x7F;
+        const state = flags & 0x1F; // bit 5 = safe zone, bit 6 = spawn protection
         const moving = vx !== 0 || vy !== 0;
         const attacking = state === 1; // server: 1=attack
 
@@ -275,7 +709,9 @@ export class BinaryProtocol {
                 direction,
                 moving,
                 attacking,
-                position: { x, y },
+                spawnProtected: (flags & 0x40) !== 0,
+                safeZone: (flags & 0x20) !== 0,
+                position: this.position(x, y),
                 vx,
                 vy,
             },
@@ -299,7 +735,7 @@ export class BinaryProtocol {
         return {
             type: 'movementAck',
             playerId,
-            position: { x, y },
+            position: this.position(x, y),
             inputSequence,
         };
     }
@@ -0,0 +1,1694 @@
+// Code generated by cmd/protogen from internal/protocol/schema.go. DO NOT EDIT.
+
+export const WireMessageType = {
+    JOIN: 1,
+    MOVE: 3,
+    DIRECTION: 4,
+    ATTACK: 5,
+    ATTACK_END: 6,
+    VIEWPORT_UPDATE: 13,
+    INPUT_BATCH: 15,
+    SEQUENCE_REPORT: 16,
+    SEALED_CLIENT: 24,
+    RELIABLE_ACK: 29,
+    CHAT: 31,
+    PARTY: 37,
+    PARTY_CHAT: 38,
+    FRIEND: 42,
+    TRADE: 45,
+    EMOTE: 47,
+    GAME_STATE: 7,
+    MOVEMENT_ACK: 8,
+    PLAYER_JOINED: 11,
+    PLAYER_LEFT: 12,
+    DELTA_GAME_STATE: 14,
+    WORLD_EVENT: 17,
+    MAINTENANCE: 18,
+    SESSION_TAKEOVER: 19,
+    CONFIG: 20,
+    MINIMAP: 21,
+    CIPHER_INIT: 22,
+    SEALED: 23,
+    WORLD_UPDATE: 25,
+    CELL_LOAD: 26,
+    CELL_UNLOAD: 27,
+    RELIABLE: 28,
+    ANNOUNCE: 30,
+    CHAT_MESSAGE: 32,
+    COMMAND_RESULT: 33,
+    HIT: 34,
+    ACTION_REJECTED: 35,
+    LEADERBOARD: 36,
+    PARTY_INVITE: 39,
+    PARTY_ROSTER: 40,
+    PARTY_CHAT_MESSAGE: 41,
+    INVENTORY: 44,
+    TRADE_STATE: 46,
+    PLAYER_EMOTE: 48,
+    ORIGIN: 49,
+    QUEUE_STATUS: 50,
+    QUEUE_ADMITTED: 51,
+    FRIEND_UPDATE: 43,
+} as const;
+
+export const WIRE_SUBPROTOCOL = "pixi.game.v3";
+
+export const WireCloseCode = {
+    UNSUPPORTED_SUBPROTOCOL: 4000,
+    PROTOCOL_VIOLATION: 4001,
+    KICKED: 4002,
+    BANNED: 4003,
+    SERVER_SHUTDOWN: 4004,
+    IDLE_TIMEOUT: 4005,
+    DUPLICATE_SESSION: 4006,
+    INTERNAL_ERROR: 4007,
+} as const;
+
+// Close codes after which the client may reconnect automatically.
+export const WIRE_CLOSE_RECONNECT: ReadonlySet<number> = new Set([WireCloseCode.SERVER_SHUTDOWN, WireCloseCode.IDLE_TIMEOUT, WireCloseCode.INTERNAL_ERROR]);
+
+export interface WireMovement {
+    dx: number;
+    dy: number;
+}
+
+function packMovement(m: WireMovement): number {
+    return ((m.dx + 1) & 0x03) | (((m.dy + 1) & 0x03) << 2);
+}
+
+function unpackMovement(packed: number): WireMovement {
+    return { dx: (packed & 0x03) - 1, dy: ((packed >> 2) & 0x03) - 1 };
+}
+
+const wireTextEncoder = new TextEncoder();
+const wireTextDecoder = new TextDecoder();
+
+// UTF-8 bytes of text, cut to maxLen bytes on a character boundary.
+function encodeText(text: string, maxLen: number): Uint8Array {
+    const bytes = wireTextEncoder.encode(text);
+    if (bytes.length <= maxLen) return bytes;
+    let end = maxLen;
+    while (end > 0 && (bytes[end] & 0xc0) === 0x80) end--;
+    return bytes.subarray(0, end);
+}
+
+/** Handshake: must be the first message after the WebSocket upgrade. The server allocates the player and replies with GAME_STATE; anything else first closes the connection. A bare 1-byte JOIN (clients without capability flags) means capabilities = 0x01, no size limit. */
+export interface JoinWire {
+    capabilities?: number;
+    maxMessageSize?: number;
+}
+
+export function encodeJoin(msg: JoinWire): Uint8Array {
+    const buffer = new ArrayBuffer(6);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.JOIN);
+    view.setUint8(1, msg.capabilities ?? 0);
+    view.setUint32(2, msg.maxMessageSize ?? 0, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeJoin(data: Uint8Array): JoinWire | null {
+    if (data.length < 1 || data[0] !== WireMessageType.JOIN) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        capabilities: data.length >= 2 ? view.getUint8(1) : undefined,
+        maxMessageSize: data.length >= 6 ? view.getUint32(2, true) : undefined,
+    };
+}
+
+/** Movement input. The server applies the vector every tick until the next MOVE: the velocity of each axis approaches axis/127 × the player speed at the configured acceleration and friction. Without the analog axes the input is digital, a full axis in the packed direction. */
+export interface MoveWire {
+    movement: WireMovement;
+    inputSequence: number;
+    axisX?: number;
+    axisY?: number;
+}
+
+export function encodeMove(msg: MoveWire): Uint8Array {
+    const buffer = new ArrayBuffer(8);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.MOVE);
+    view.setUint8(1, packMovement(msg.movement));
+    view.setUint32(2, msg.inputSequence, true);
+    view.setInt8(6, msg.axisX ?? 0);
+    view.setInt8(7, msg.axisY ?? 0);
+    return new Uint8Array(buffer);
+}
+
+export function decodeMove(data: Uint8Array): MoveWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.MOVE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        movement: unpackMovement(view.getUint8(1)),
+        inputSequence: view.getUint32(2, true),
+        axisX: data.length >= 7 ? view.getInt8(6) : undefined,
+        axisY: data.length >= 8 ? view.getInt8(7) : undefined,
+    };
+}
+
+/** Facing change. */
+export interface DirectionWire {
+    direction: number;
+}
+
+export function encodeDirection(msg: DirectionWire): Uint8Array {
+    const buffer = new ArrayBuffer(2);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.DIRECTION);
+    view.setInt8(1, msg.direction);
+    return new Uint8Array(buffer);
+}
+
+export function decodeDirection(data: Uint8Array): DirectionWire | null {
+    if (data.length < 2 || data[0] !== WireMessageType.DIRECTION) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        direction: view.getInt8(1),
+    };
+}
+
+/** Attack request; the server uses its own position and resolves the hits. Without an aim the attack hits in front of the attacker. With one it hits within ATTACK_RANGE and ATTACK_ARC/2 degrees of the aim, and the attacker turns towards it. The 9-byte form of older clients (x, y as float32) carries no aim. */
+export interface AttackWire {
+    aimX?: number;
+    aimY?: number;
+}
+
+export function encodeAttack(msg: AttackWire): Uint8Array {
+    const buffer = new ArrayBuffer(3);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.ATTACK);
+    view.setInt8(1, msg.aimX ?? 0);
+    view.setInt8(2, msg.aimY ?? 0);
+    return new Uint8Array(buffer);
+}
+
+export function decodeAttack(data: Uint8Array): AttackWire | null {
+    if (data.length < 1 || data[0] !== WireMessageType.ATTACK) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        aimX: data.length >= 2 ? view.getInt8(1) : undefined,
+        aimY: data.length >= 3 ? view.getInt8(2) : undefined,
+    };
+}
+
+/** Ignored: attack duration is server-authoritative. */
+export interface AttackEndWire {
+}
+
+export function encodeAttackEnd(_msg: AttackEndWire): Uint8Array {
+    const buffer = new ArrayBuffer(1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.ATTACK_END);
+    return new Uint8Array(buffer);
+}
+
+export function decodeAttackEnd(data: Uint8Array): AttackEndWire | null {
+    if (data.length < 1 || data[0] !== WireMessageType.ATTACK_END) return null;
+    return {};
+}
+
+/** Client viewport size in world units. The server centres it on the player (plus a margin) and only sends world state inside it. The size is capped at MAX_VIEWPORT_WIDTH×MAX_VIEWPORT_HEIGHT and rounded up to whole visibility cells; updates over VIEWPORT_RATE_LIMIT per second are deferred and only the latest applies. */
+export interface ViewportUpdateWire {
+    width: number;
+    height: number;
+}
+
+export function encodeViewportUpdate(msg: ViewportUpdateWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.VIEWPORT_UPDATE);
+    view.setUint16(1, msg.width, true);
+    view.setUint16(3, msg.height, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeViewportUpdate(data: Uint8Array): ViewportUpdateWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.VIEWPORT_UPDATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        width: view.getUint16(1, true),
+        height: view.getUint16(3, true),
+    };
+}
+
+export interface InputBatchEntry {
+    movement: WireMovement;
+}
+
+/** Several MOVE inputs sent in one frame (e.g. accumulated during a frame hiccup). Entry i gets input sequence baseSequence+i; at most 32 entries. */
+export interface InputBatchWire {
+    baseSequence: number;
+    inputs: InputBatchEntry[];
+}
+
+export function encodeInputBatch(msg: InputBatchWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.inputs.length * 1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.INPUT_BATCH);
+    view.setUint32(1, msg.baseSequence, true);
+    view.setUint32(5, msg.inputs.length, true);
+    let offset = 9;
+    for (const entry of msg.inputs) {
+        view.setUint8(offset + 0, packMovement(entry.movement));
+        offset += 1;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeInputBatch(data: Uint8Array): InputBatchWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.INPUT_BATCH) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 1) return null;
+    const inputs: InputBatchEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 1) {
+        inputs[i] = {
+            movement: unpackMovement(view.getUint8(offset + 0)),
+        };
+    }
+    return {
+        baseSequence: view.getUint32(1, true),
+        inputs,
+    };
+}
+
+/** Periodic report of server → client sequence gaps seen by the client. With the resync flag set the server answers with a full GAME_STATE (rate-limited per connection). */
+export interface SequenceReportWire {
+    lastSequence: number;
+    missed: number;
+    flags: number;
+    corrupted?: number;
+}
+
+export function encodeSequenceReport(msg: SequenceReportWire): Uint8Array {
+    const buffer = new ArrayBuffer(14);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.SEQUENCE_REPORT);
+    view.setUint32(1, msg.lastSequence, true);
+    view.setUint32(5, msg.missed, true);
+    view.setUint8(9, msg.flags);
+    view.setUint32(10, msg.corrupted ?? 0, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeSequenceReport(data: Uint8Array): SequenceReportWire | null {
+    if (data.length < 10 || data[0] !== WireMessageType.SEQUENCE_REPORT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        lastSequence: view.getUint32(1, true),
+        missed: view.getUint32(5, true),
+        flags: view.getUint8(9),
+        corrupted: data.length >= 14 ? view.getUint32(10, true) : undefined,
+    };
+}
+
+export interface SealedClientEntry {
+    byte: number;
+}
+
+/** Encrypted envelope around a client message of a type listed in CIPHER_INIT (see Sealed messages). The server drops those types when they arrive unsealed. */
+export interface SealedClientWire {
+    counter: number;
+    ciphertext: SealedClientEntry[];
+}
+
+export function encodeSealedClient(msg: SealedClientWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.ciphertext.length * 1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.SEALED_CLIENT);
+    view.setUint32(1, msg.counter, true);
+    view.setUint32(5, msg.ciphertext.length, true);
+    let offset = 9;
+    for (const entry of msg.ciphertext) {
+        view.setUint8(offset + 0, entry.byte);
+        offset += 1;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeSealedClient(data: Uint8Array): SealedClientWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.SEALED_CLIENT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 1) return null;
+    const ciphertext: SealedClientEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 1) {
+        ciphertext[i] = {
+            byte: view.getUint8(offset + 0),
+        };
+    }
+    return {
+        counter: view.getUint32(1, true),
+        ciphertext,
+    };
+}
+
+/** Acknowledges a RELIABLE message; sent for every copy received, retransmissions included. */
+export interface ReliableAckWire {
+    id: number;
+}
+
+export function encodeReliableAck(msg: ReliableAckWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.RELIABLE_ACK);
+    view.setUint32(1, msg.id, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeReliableAck(data: Uint8Array): ReliableAckWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.RELIABLE_ACK) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        id: view.getUint32(1, true),
+    };
+}
+
+/** Chat line. The server relays it to everyone as CHAT_MESSAGE, unless it starts with /: then it is a console command (/help lists them) answered with COMMAND_RESULT. */
+export interface ChatWire {
+    text: string;
+}
+
+export function encodeChat(msg: ChatWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 256);
+    const buffer = new ArrayBuffer(5 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CHAT);
+    view.setUint32(1, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 5);
+    return new Uint8Array(buffer);
+}
+
+export function decodeChat(data: Uint8Array): ChatWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.CHAT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(1, true);
+    if (data.length < 5 + textLength) return null;
+    return {
+        text: wireTextDecoder.decode(data.subarray(5, 5 + textLength)),
+    };
+}
+
+/** Party membership. A player not in a party, or the leader of one, invites a player not in a party; the invitee accepts within PARTY_INVITE_TTL_SEC and every member gets PARTY_ROSTER. A refused action is answered with COMMAND_RESULT. */
+export interface PartyWire {
+    action: number;
+    playerId: number;
+}
+
+export function encodeParty(msg: PartyWire): Uint8Array {
+    const buffer = new ArrayBuffer(6);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PARTY);
+    view.setUint8(1, msg.action);
+    view.setUint32(2, msg.playerId, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeParty(data: Uint8Array): PartyWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.PARTY) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        action: view.getUint8(1),
+        playerId: view.getUint32(2, true),
+    };
+}
+
+/** Chat line to the sender's party, relayed to its members as PARTY_CHAT_MESSAGE. */
+export interface PartyChatWire {
+    text: string;
+}
+
+export function encodePartyChat(msg: PartyChatWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 256);
+    const buffer = new ArrayBuffer(5 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PARTY_CHAT);
+    view.setUint32(1, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 5);
+    return new Uint8Array(buffer);
+}
+
+export function decodePartyChat(data: Uint8Array): PartyChatWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.PARTY_CHAT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(1, true);
+    if (data.length < 5 + textLength) return null;
+    return {
+        text: wireTextDecoder.decode(data.subarray(5, 5 + textLength)),
+    };
+}
+
+/** Friend list change of a signed-in player. Adding an account asks it to be friends, or accepts its request; removing ends the friendship or declines / withdraws a request. Both sides get FRIEND_UPDATE; a refused action is answered with COMMAND_RESULT. */
+export interface FriendWire {
+    action: number;
+    account: string;
+}
+
+export function encodeFriend(msg: FriendWire): Uint8Array {
+    const textBytes = encodeText(msg.account, 64);
+    const buffer = new ArrayBuffer(6 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.FRIEND);
+    view.setUint8(1, msg.action);
+    view.setUint32(2, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 6);
+    return new Uint8Array(buffer);
+}
+
+export function decodeFriend(data: Uint8Array): FriendWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.FRIEND) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(2, true);
+    if (data.length < 6 + textLength) return null;
+    return {
+        action: view.getUint8(1),
+        account: wireTextDecoder.decode(data.subarray(6, 6 + textLength)),
+    };
+}
+
+/** Trade between two signed-in players, mediated by the server. One asks (request), the other accepts; each side then offers items of its inventory and confirms. Changing an offer withdraws both confirmations; once both confirmed the same offers the server swaps them in one step, or cancels the trade if a side no longer has its offer. Both get TRADE_STATE after every change; a refused action is answered with COMMAND_RESULT. */
+export interface TradeWire {
+    action: number;
+    playerId: number;
+    item: number;
+    count: number;
+}
+
+export function encodeTrade(msg: TradeWire): Uint8Array {
+    const buffer = new ArrayBuffer(12);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.TRADE);
+    view.setUint8(1, msg.action);
+    view.setUint32(2, msg.playerId, true);
+    view.setUint16(6, msg.item, true);
+    view.setUint32(8, msg.count, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeTrade(data: Uint8Array): TradeWire | null {
+    if (data.length < 12 || data[0] !== WireMessageType.TRADE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        action: view.getUint8(1),
+        playerId: view.getUint32(2, true),
+        item: view.getUint16(6, true),
+        count: view.getUint32(8, true),
+    };
+}
+
+/** Plays an emote of the "emotes" catalog of gameConfig.json. The server sends PLAYER_EMOTE to the players within EMOTE_RADIUS, the client included; an emote missing from the catalog, or sent before the cooldown of the previous one ran out, is answered with ACTION_REJECTED. Emotes have no effect on the game. */
+export interface EmoteWire {
+    emoteId: number;
+}
+
+export function encodeEmote(msg: EmoteWire): Uint8Array {
+    const buffer = new ArrayBuffer(2);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.EMOTE);
+    view.setUint8(1, msg.emoteId);
+    return new Uint8Array(buffer);
+}
+
+export function decodeEmote(data: Uint8Array): EmoteWire | null {
+    if (data.length < 2 || data[0] !== WireMessageType.EMOTE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        emoteId: view.getUint8(1),
+    };
+}
+
+export interface GameStateEntry {
+    id: number;
+    x: number;
+    y: number;
+    vx: number;
+    vy: number;
+    flags: number;
+}
+
+/** Full world state (initial state and periodic full sync). */
+export interface GameStateWire {
+    stateSequence: number;
+    players: GameStateEntry[];
+}
+
+export function encodeGameState(msg: GameStateWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.players.length * 11);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.GAME_STATE);
+    view.setUint32(1, msg.stateSequence, true);
+    view.setUint32(5, msg.players.length, true);
+    let offset = 9;
+    for (const entry of msg.players) {
+        view.setUint32(offset + 0, entry.id, true);
+        view.setUint16(offset + 4, entry.x, true);
+        view.setUint16(offset + 6, entry.y, true);
+        view.setInt8(offset + 8, entry.vx);
+        view.setInt8(offset + 9, entry.vy);
+        view.setUint8(offset + 10, entry.flags);
+        offset += 11;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeGameState(data: Uint8Array): GameStateWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.GAME_STATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 11) return null;
+    const players: GameStateEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 11) {
+        players[i] = {
+            id: view.getUint32(offset + 0, true),
+            x: view.getUint16(offset + 4, true),
+            y: view.getUint16(offset + 6, true),
+            vx: view.getInt8(offset + 8),
+            vy: view.getInt8(offset + 9),
+            flags: view.getUint8(offset + 10),
+        };
+    }
+    return {
+        stateSequence: view.getUint32(1, true),
+        players,
+    };
+}
+
+/** Authoritative position for the given input sequence. */
+export interface MovementAckWire {
+    playerId: number;
+    x: number;
+    y: number;
+    inputSequence: number;
+}
+
+export function encodeMovementAck(msg: MovementAckWire): Uint8Array {
+    const buffer = new ArrayBuffer(13);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.MOVEMENT_ACK);
+    view.setUint32(1, msg.playerId, true);
+    view.setUint16(5, msg.x, true);
+    view.setUint16(7, msg.y, true);
+    view.setUint32(9, msg.inputSequence, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeMovementAck(data: Uint8Array): MovementAckWire | null {
+    if (data.length < 13 || data[0] !== WireMessageType.MOVEMENT_ACK) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        playerId: view.getUint32(1, true),
+        x: view.getUint16(5, true),
+        y: view.getUint16(7, true),
+        inputSequence: view.getUint32(9, true),
+    };
+}
+
+/** A player entered the world. */
+export interface PlayerJoinedWire {
+    id: number;
+    x: number;
+    y: number;
+    vx: number;
+    vy: number;
+    flags: number;
+}
+
+export function encodePlayerJoined(msg: PlayerJoinedWire): Uint8Array {
+    const buffer = new ArrayBuffer(12);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PLAYER_JOINED);
+    view.setUint32(1, msg.id, true);
+    view.setUint16(5, msg.x, true);
+    view.setUint16(7, msg.y, true);
+    view.setInt8(9, msg.vx);
+    view.setInt8(10, msg.vy);
+    view.setUint8(11, msg.flags);
+    return new Uint8Array(buffer);
+}
+
+export function decodePlayerJoined(data: Uint8Array): PlayerJoinedWire | null {
+    if (data.length < 12 || data[0] !== WireMessageType.PLAYER_JOINED) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        id: view.getUint32(1, true),
+        x: view.getUint16(5, true),
+        y: view.getUint16(7, true),
+        vx: view.getInt8(9),
+        vy: view.getInt8(10),
+        flags: view.getUint8(11),
+    };
+}
+
+/** A player left the world. */
+export interface PlayerLeftWire {
+    playerId: number;
+}
+
+export function encodePlayerLeft(msg: PlayerLeftWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PLAYER_LEFT);
+    view.setUint32(1, msg.playerId, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodePlayerLeft(data: Uint8Array): PlayerLeftWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.PLAYER_LEFT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        playerId: view.getUint32(1, true),
+    };
+}
+
+export interface DeltaGameStateEntry {
+    id: number;
+    x: number;
+    y: number;
+    vx: number;
+    vy: number;
+    flags: number;
+}
+
+/** Only players whose state changed since the previous tick; merged into client state. */
+export interface DeltaGameStateWire {
+    stateSequence: number;
+    players: DeltaGameStateEntry[];
+}
+
+export function encodeDeltaGameState(msg: DeltaGameStateWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.players.length * 11);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.DELTA_GAME_STATE);
+    view.setUint32(1, msg.stateSequence, true);
+    view.setUint32(5, msg.players.length, true);
+    let offset = 9;
+    for (const entry of msg.players) {
+        view.setUint32(offset + 0, entry.id, true);
+        view.setUint16(offset + 4, entry.x, true);
+        view.setUint16(offset + 6, entry.y, true);
+        view.setInt8(offset + 8, entry.vx);
+        view.setInt8(offset + 9, entry.vy);
+        view.setUint8(offset + 10, entry.flags);
+        offset += 11;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeDeltaGameState(data: Uint8Array): DeltaGameStateWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.DELTA_GAME_STATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 11) return null;
+    const players: DeltaGameStateEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 11) {
+        players[i] = {
+            id: view.getUint32(offset + 0, true),
+            x: view.getUint16(offset + 4, true),
+            y: view.getUint16(offset + 6, true),
+            vx: view.getInt8(offset + 8),
+            vy: view.getInt8(offset + 9),
+            flags: view.getUint8(offset + 10),
+        };
+    }
+    return {
+        stateSequence: view.getUint32(1, true),
+        players,
+    };
+}
+
+/** Global world event from the schedule (also sent to newcomers for events still active). kind: 1 = night (inactive = day), 2 = storm, 3 = announcement. */
+export interface WorldEventWire {
+    kind: number;
+    active: number;
+    value: number;
+    durationMs: number;
+    text: string;
+}
+
+export function encodeWorldEvent(msg: WorldEventWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 512);
+    const buffer = new ArrayBuffer(13 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.WORLD_EVENT);
+    view.setUint8(1, msg.kind);
+    view.setUint8(2, msg.active);
+    view.setUint16(3, msg.value, true);
+    view.setUint32(5, msg.durationMs, true);
+    view.setUint32(9, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 13);
+    return new Uint8Array(buffer);
+}
+
+export function decodeWorldEvent(data: Uint8Array): WorldEventWire | null {
+    if (data.length < 13 || data[0] !== WireMessageType.WORLD_EVENT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(9, true);
+    if (data.length < 13 + textLength) return null;
+    return {
+        kind: view.getUint8(1),
+        active: view.getUint8(2),
+        value: view.getUint16(3, true),
+        durationMs: view.getUint32(5, true),
+        text: wireTextDecoder.decode(data.subarray(13, 13 + textLength)),
+    };
+}
+
+/** Maintenance mode. While active the server rejects new connections; after the countdown the simulation pauses until maintenance is over. */
+export interface MaintenanceWire {
+    phase: number;
+    countdownMs: number;
+}
+
+export function encodeMaintenance(msg: MaintenanceWire): Uint8Array {
+    const buffer = new ArrayBuffer(6);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.MAINTENANCE);
+    view.setUint8(1, msg.phase);
+    view.setUint32(2, msg.countdownMs, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeMaintenance(data: Uint8Array): MaintenanceWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.MAINTENANCE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        phase: view.getUint8(1),
+        countdownMs: view.getUint32(2, true),
+    };
+}
+
+/** The same account connected again and took over this session's player. Sent right before the close frame with DUPLICATE_SESSION; the client should not reconnect. */
+export interface SessionTakeoverWire {
+    playerId: number;
+}
+
+export function encodeSessionTakeover(msg: SessionTakeoverWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.SESSION_TAKEOVER);
+    view.setUint32(1, msg.playerId, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeSessionTakeover(data: Uint8Array): SessionTakeoverWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.SESSION_TAKEOVER) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        playerId: view.getUint32(1, true),
+    };
+}
+
+/** Authoritative gameplay constants, sent once after JOIN ahead of the first GAME_STATE. The client uses these instead of its bundled gameConfig.json, which is only a fallback for older servers. */
+export interface ConfigWire {
+    playerId: number;
+    tickRate: number;
+    playerSpeedPerTick: number;
+    worldWidth: number;
+    worldHeight: number;
+    boundaryMode: number;
+    acceleration?: number;
+    friction?: number;
+    positionBits?: number;
+    worldWidthHigh?: number;
+    worldHeightHigh?: number;
+    worldSeed?: number;
+}
+
+export function encodeConfig(msg: ConfigWire): Uint8Array {
+    const buffer = new ArrayBuffer(24);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CONFIG);
+    view.setUint32(1, msg.playerId, true);
+    view.setUint8(5, msg.tickRate);
+    view.setUint16(6, msg.playerSpeedPerTick, true);
+    view.setUint16(8, msg.worldWidth, true);
+    view.setUint16(10, msg.worldHeight, true);
+    view.setUint8(12, msg.boundaryMode);
+    view.setUint16(13, msg.acceleration ?? 0, true);
+    view.setUint16(15, msg.friction ?? 0, true);
+    view.setUint8(17, msg.positionBits ?? 0);
+    view.setUint8(18, msg.worldWidthHigh ?? 0);
+    view.setUint8(19, msg.worldHeightHigh ?? 0);
+    view.setUint32(20, msg.worldSeed ?? 0, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeConfig(data: Uint8Array): ConfigWire | null {
+    if (data.length < 13 || data[0] !== WireMessageType.CONFIG) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        playerId: view.getUint32(1, true),
+        tickRate: view.getUint8(5),
+        playerSpeedPerTick: view.getUint16(6, true),
+        worldWidth: view.getUint16(8, true),
+        worldHeight: view.getUint16(10, true),
+        boundaryMode: view.getUint8(12),
+        acceleration: data.length >= 15 ? view.getUint16(13, true) : undefined,
+        friction: data.length >= 17 ? view.getUint16(15, true) : undefined,
+        positionBits: data.length >= 18 ? view.getUint8(17) : undefined,
+        worldWidthHigh: data.length >= 19 ? view.getUint8(18) : undefined,
+        worldHeightHigh: data.length >= 20 ? view.getUint8(19) : undefined,
+        worldSeed: data.length >= 24 ? view.getUint32(20, true) : undefined,
+    };
+}
+
+export interface MinimapEntry {
+    run: number;
+    count: number;
+}
+
+/** Coarse player density grid for a minimap, about once a second, only to clients that set capability bit 2 in JOIN. Cells are row-major from the top-left corner and run-length encoded: each run repeats count for run consecutive cells. Counts saturate at 255. */
+export interface MinimapWire {
+    cols: number;
+    rows: number;
+    cellWidth: number;
+    cellHeight: number;
+    runs: MinimapEntry[];
+}
+
+export function encodeMinimap(msg: MinimapWire): Uint8Array {
+    const buffer = new ArrayBuffer(11 + msg.runs.length * 2);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.MINIMAP);
+    view.setUint8(1, msg.cols);
+    view.setUint8(2, msg.rows);
+    view.setUint16(3, msg.cellWidth, true);
+    view.setUint16(5, msg.cellHeight, true);
+    view.setUint32(7, msg.runs.length, true);
+    let offset = 11;
+    for (const entry of msg.runs) {
+        view.setUint8(offset + 0, entry.run);
+        view.setUint8(offset + 1, entry.count);
+        offset += 2;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeMinimap(data: Uint8Array): MinimapWire | null {
+    if (data.length < 11 || data[0] !== WireMessageType.MINIMAP) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(7, true);
+    if (data.length < 11 + count * 2) return null;
+    const runs: MinimapEntry[] = new Array(count);
+    for (let i = 0, offset = 11; i < count; i++, offset += 2) {
+        runs[i] = {
+            run: view.getUint8(offset + 0),
+            count: view.getUint8(offset + 1),
+        };
+    }
+    return {
+        cols: view.getUint8(1),
+        rows: view.getUint8(2),
+        cellWidth: view.getUint16(3, true),
+        cellHeight: view.getUint16(5, true),
+        runs,
+    };
+}
+
+export interface CipherInitEntry {
+    type: number;
+}
+
+/** Sent right after JOIN to clients that set capability bit 4 and connected with a session token. Carries the salt of the connection key and the message types that travel sealed from now on, in both directions (see Sealed messages). */
+export interface CipherInitWire {
+    salt0: number;
+    salt1: number;
+    salt2: number;
+    salt3: number;
+    types: CipherInitEntry[];
+}
+
+export function encodeCipherInit(msg: CipherInitWire): Uint8Array {
+    const buffer = new ArrayBuffer(21 + msg.types.length * 1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CIPHER_INIT);
+    view.setUint32(1, msg.salt0, true);
+    view.setUint32(5, msg.salt1, true);
+    view.setUint32(9, msg.salt2, true);
+    view.setUint32(13, msg.salt3, true);
+    view.setUint32(17, msg.types.length, true);
+    let offset = 21;
+    for (const entry of msg.types) {
+        view.setUint8(offset + 0, entry.type);
+        offset += 1;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeCipherInit(data: Uint8Array): CipherInitWire | null {
+    if (data.length < 21 || data[0] !== WireMessageType.CIPHER_INIT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(17, true);
+    if (data.length < 21 + count * 1) return null;
+    const types: CipherInitEntry[] = new Array(count);
+    for (let i = 0, offset = 21; i < count; i++, offset += 1) {
+        types[i] = {
+            type: view.getUint8(offset + 0),
+        };
+    }
+    return {
+        salt0: view.getUint32(1, true),
+        salt1: view.getUint32(5, true),
+        salt2: view.getUint32(9, true),
+        salt3: view.getUint32(13, true),
+        types,
+    };
+}
+
+export interface SealedEntry {
+    byte: number;
+}
+
+/** Encrypted envelope around a server message of a type listed in CIPHER_INIT (see Sealed messages). */
+export interface SealedWire {
+    counter: number;
+    ciphertext: SealedEntry[];
+}
+
+export function encodeSealed(msg: SealedWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.ciphertext.length * 1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.SEALED);
+    view.setUint32(1, msg.counter, true);
+    view.setUint32(5, msg.ciphertext.length, true);
+    let offset = 9;
+    for (const entry of msg.ciphertext) {
+        view.setUint8(offset + 0, entry.byte);
+        offset += 1;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeSealed(data: Uint8Array): SealedWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.SEALED) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 1) return null;
+    const ciphertext: SealedEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 1) {
+        ciphertext[i] = {
+            byte: view.getUint8(offset + 0),
+        };
+    }
+    return {
+        counter: view.getUint32(1, true),
+        ciphertext,
+    };
+}
+
+/** The world was resized at runtime; replaces worldWidth and worldHeight of CONFIG. Players left outside the new bounds have already been moved inside and get a MOVEMENT_ACK correction. */
+export interface WorldUpdateWire {
+    worldWidth: number;
+    worldHeight: number;
+    boundaryMode: number;
+    worldWidthHigh?: number;
+    worldHeightHigh?: number;
+}
+
+export function encodeWorldUpdate(msg: WorldUpdateWire): Uint8Array {
+    const buffer = new ArrayBuffer(8);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.WORLD_UPDATE);
+    view.setUint16(1, msg.worldWidth, true);
+    view.setUint16(3, msg.worldHeight, true);
+    view.setUint8(5, msg.boundaryMode);
+    view.setUint8(6, msg.worldWidthHigh ?? 0);
+    view.setUint8(7, msg.worldHeightHigh ?? 0);
+    return new Uint8Array(buffer);
+}
+
+export function decodeWorldUpdate(data: Uint8Array): WorldUpdateWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.WORLD_UPDATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        worldWidth: view.getUint16(1, true),
+        worldHeight: view.getUint16(3, true),
+        boundaryMode: view.getUint8(5),
+        worldWidthHigh: data.length >= 7 ? view.getUint8(6) : undefined,
+        worldHeightHigh: data.length >= 8 ? view.getUint8(7) : undefined,
+    };
+}
+
+export interface CellLoadEntry {
+    id: number;
+    x: number;
+    y: number;
+    vx: number;
+    vy: number;
+    flags: number;
+}
+
+/** Cell streaming (capability bit 5): the players inside the cells that entered the client's viewport this tick, to merge into its state. Many players may come in several CELL_LOADs. */
+export interface CellLoadWire {
+    cellSize: number;
+    cells: number;
+    players: CellLoadEntry[];
+}
+
+export function encodeCellLoad(msg: CellLoadWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.players.length * 11);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CELL_LOAD);
+    view.setUint16(1, msg.cellSize, true);
+    view.setUint16(3, msg.cells, true);
+    view.setUint32(5, msg.players.length, true);
+    let offset = 9;
+    for (const entry of msg.players) {
+        view.setUint32(offset + 0, entry.id, true);
+        view.setUint16(offset + 4, entry.x, true);
+        view.setUint16(offset + 6, entry.y, true);
+        view.setInt8(offset + 8, entry.vx);
+        view.setInt8(offset + 9, entry.vy);
+        view.setUint8(offset + 10, entry.flags);
+        offset += 11;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeCellLoad(data: Uint8Array): CellLoadWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.CELL_LOAD) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 11) return null;
+    const players: CellLoadEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 11) {
+        players[i] = {
+            id: view.getUint32(offset + 0, true),
+            x: view.getUint16(offset + 4, true),
+            y: view.getUint16(offset + 6, true),
+            vx: view.getInt8(offset + 8),
+            vy: view.getInt8(offset + 9),
+            flags: view.getUint8(offset + 10),
+        };
+    }
+    return {
+        cellSize: view.getUint16(1, true),
+        cells: view.getUint16(3, true),
+        players,
+    };
+}
+
+export interface CellUnloadEntry {
+    cellX: number;
+    cellY: number;
+}
+
+/** Cell streaming: loaded cells that left the viewport; the client drops the other players inside them. Cell (x, y) covers [x·cellSize, (x+1)·cellSize) × [y·cellSize, (y+1)·cellSize). */
+export interface CellUnloadWire {
+    cellSize: number;
+    cells: CellUnloadEntry[];
+}
+
+export function encodeCellUnload(msg: CellUnloadWire): Uint8Array {
+    const buffer = new ArrayBuffer(7 + msg.cells.length * 4);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CELL_UNLOAD);
+    view.setUint16(1, msg.cellSize, true);
+    view.setUint32(3, msg.cells.length, true);
+    let offset = 7;
+    for (const entry of msg.cells) {
+        view.setUint16(offset + 0, entry.cellX, true);
+        view.setUint16(offset + 2, entry.cellY, true);
+        offset += 4;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeCellUnload(data: Uint8Array): CellUnloadWire | null {
+    if (data.length < 7 || data[0] !== WireMessageType.CELL_UNLOAD) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(3, true);
+    if (data.length < 7 + count * 4) return null;
+    const cells: CellUnloadEntry[] = new Array(count);
+    for (let i = 0, offset = 7; i < count; i++, offset += 4) {
+        cells[i] = {
+            cellX: view.getUint16(offset + 0, true),
+            cellY: view.getUint16(offset + 2, true),
+        };
+    }
+    return {
+        cellSize: view.getUint16(1, true),
+        cells,
+    };
+}
+
+export interface ReliableEntry {
+    byte: number;
+}
+
+/** Envelope around a critical message (PLAYER_JOINED, PLAYER_LEFT, movement corrections) for clients that set capability bit 6. The client answers RELIABLE_ACK with the id and handles each id once: unacked messages are sent again with backoff (see Reliable messages). */
+export interface ReliableWire {
+    id: number;
+    message: ReliableEntry[];
+}
+
+export function encodeReliable(msg: ReliableWire): Uint8Array {
+    const buffer = new ArrayBuffer(9 + msg.message.length * 1);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.RELIABLE);
+    view.setUint32(1, msg.id, true);
+    view.setUint32(5, msg.message.length, true);
+    let offset = 9;
+    for (const entry of msg.message) {
+        view.setUint8(offset + 0, entry.byte);
+        offset += 1;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeReliable(data: Uint8Array): ReliableWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.RELIABLE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(5, true);
+    if (data.length < 9 + count * 1) return null;
+    const message: ReliableEntry[] = new Array(count);
+    for (let i = 0, offset = 9; i < count; i++, offset += 1) {
+        message[i] = {
+            byte: view.getUint8(offset + 0),
+        };
+    }
+    return {
+        id: view.getUint32(1, true),
+        message,
+    };
+}
+
+/** Message of the day (right after JOIN) or an announcement from the admin API, to everyone, one zone or one player. The text is already in the client's language when the server has it (see the lang query parameter in Handshake). */
+export interface AnnounceWire {
+    kind: number;
+    severity: number;
+    text: string;
+}
+
+export function encodeAnnounce(msg: AnnounceWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 1024);
+    const buffer = new ArrayBuffer(7 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.ANNOUNCE);
+    view.setUint8(1, msg.kind);
+    view.setUint8(2, msg.severity);
+    view.setUint32(3, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 7);
+    return new Uint8Array(buffer);
+}
+
+export function decodeAnnounce(data: Uint8Array): AnnounceWire | null {
+    if (data.length < 7 || data[0] !== WireMessageType.ANNOUNCE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(3, true);
+    if (data.length < 7 + textLength) return null;
+    return {
+        kind: view.getUint8(1),
+        severity: view.getUint8(2),
+        text: wireTextDecoder.decode(data.subarray(7, 7 + textLength)),
+    };
+}
+
+/** Chat line of a player, relayed to everyone (the sender included). */
+export interface ChatMessageWire {
+    playerId: number;
+    text: string;
+}
+
+export function encodeChatMessage(msg: ChatMessageWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 256);
+    const buffer = new ArrayBuffer(9 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.CHAT_MESSAGE);
+    view.setUint32(1, msg.playerId, true);
+    view.setUint32(5, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 9);
+    return new Uint8Array(buffer);
+}
+
+export function decodeChatMessage(data: Uint8Array): ChatMessageWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.CHAT_MESSAGE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(5, true);
+    if (data.length < 9 + textLength) return null;
+    return {
+        playerId: view.getUint32(1, true),
+        text: wireTextDecoder.decode(data.subarray(9, 9 + textLength)),
+    };
+}
+
+/** Answer to a console command sent in CHAT, to the issuing client only; also refuses chat lines of a muted player. */
+export interface CommandResultWire {
+    status: number;
+    text: string;
+}
+
+export function encodeCommandResult(msg: CommandResultWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 4096);
+    const buffer = new ArrayBuffer(6 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.COMMAND_RESULT);
+    view.setUint8(1, msg.status);
+    view.setUint32(2, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 6);
+    return new Uint8Array(buffer);
+}
+
+export function decodeCommandResult(data: Uint8Array): CommandResultWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.COMMAND_RESULT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(2, true);
+    if (data.length < 6 + textLength) return null;
+    return {
+        status: view.getUint8(1),
+        text: wireTextDecoder.decode(data.subarray(6, 6 + textLength)),
+    };
+}
+
+/** An attack landed, decided by the server: sent to the attacker as confirmation and to the victim to apply, ahead of the next state frame (RELIABLE for capability bit 6). The victim's position already includes the knockback; the victim also gets a MOVEMENT_ACK correction to it. */
+export interface HitWire {
+    attackerId: number;
+    victimId: number;
+    damage: number;
+    hp: number;
+    knockbackX: number;
+    knockbackY: number;
+}
+
+export function encodeHit(msg: HitWire): Uint8Array {
+    const buffer = new ArrayBuffer(15);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.HIT);
+    view.setUint32(1, msg.attackerId, true);
+    view.setUint32(5, msg.victimId, true);
+    view.setUint16(9, msg.damage, true);
+    view.setUint16(11, msg.hp, true);
+    view.setInt8(13, msg.knockbackX);
+    view.setInt8(14, msg.knockbackY);
+    return new Uint8Array(buffer);
+}
+
+export function decodeHit(data: Uint8Array): HitWire | null {
+    if (data.length < 15 || data[0] !== WireMessageType.HIT) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        attackerId: view.getUint32(1, true),
+        victimId: view.getUint32(5, true),
+        damage: view.getUint16(9, true),
+        hp: view.getUint16(11, true),
+        knockbackX: view.getInt8(13),
+        knockbackY: view.getInt8(14),
+    };
+}
+
+/** The server refused an input, so the client can roll back what it showed optimistically. Sent for ATTACK (cooldown, stunned, dead, frozen, safe zone) and for any message dropped by the rate limit. MOVE is never rejected this way: MOVEMENT_ACK already carries the authoritative position. */
+export interface ActionRejectedWire {
+    action: number;
+    reason: number;
+    retryAfterTicks: number;
+}
+
+export function encodeActionRejected(msg: ActionRejectedWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.ACTION_REJECTED);
+    view.setUint8(1, msg.action);
+    view.setUint8(2, msg.reason);
+    view.setUint16(3, msg.retryAfterTicks, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeActionRejected(data: Uint8Array): ActionRejectedWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.ACTION_REJECTED) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        action: view.getUint8(1),
+        reason: view.getUint8(2),
+        retryAfterTicks: view.getUint16(3, true),
+    };
+}
+
+export interface LeaderboardEntry {
+    playerId: number;
+    score: number;
+}
+
+/** Top players of one metric, best first, every LEADERBOARD_INTERVAL_MS per metric, only to clients that set capability bit 7 in JOIN. Scores span every server sharing the leaderboard store; deeper ranks, account IDs and a player's own neighbourhood are at GET /leaderboard. */
+export interface LeaderboardWire {
+    metric: number;
+    entries: LeaderboardEntry[];
+}
+
+export function encodeLeaderboard(msg: LeaderboardWire): Uint8Array {
+    const buffer = new ArrayBuffer(6 + msg.entries.length * 8);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.LEADERBOARD);
+    view.setUint8(1, msg.metric);
+    view.setUint32(2, msg.entries.length, true);
+    let offset = 6;
+    for (const entry of msg.entries) {
+        view.setUint32(offset + 0, entry.playerId, true);
+        view.setUint32(offset + 4, entry.score, true);
+        offset += 8;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeLeaderboard(data: Uint8Array): LeaderboardWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.LEADERBOARD) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(2, true);
+    if (data.length < 6 + count * 8) return null;
+    const entries: LeaderboardEntry[] = new Array(count);
+    for (let i = 0, offset = 6; i < count; i++, offset += 8) {
+        entries[i] = {
+            playerId: view.getUint32(offset + 0, true),
+            score: view.getUint32(offset + 4, true),
+        };
+    }
+    return {
+        metric: view.getUint8(1),
+        entries,
+    };
+}
+
+/** Another player invites the client to its party; answer with PARTY accept. */
+export interface PartyInviteWire {
+    inviterId: number;
+}
+
+export function encodePartyInvite(msg: PartyInviteWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PARTY_INVITE);
+    view.setUint32(1, msg.inviterId, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodePartyInvite(data: Uint8Array): PartyInviteWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.PARTY_INVITE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        inviterId: view.getUint32(1, true),
+    };
+}
+
+export interface PartyRosterEntry {
+    playerId: number;
+}
+
+/** The client's party after every change (RELIABLE for capability bit 6), and again after a session takeover. No members = not in a party (left, or the party broke up). With PARTY_ALWAYS_VISIBLE the members are in the world state whatever the viewport. */
+export interface PartyRosterWire {
+    partyId: number;
+    leaderId: number;
+    members: PartyRosterEntry[];
+}
+
+export function encodePartyRoster(msg: PartyRosterWire): Uint8Array {
+    const buffer = new ArrayBuffer(13 + msg.members.length * 4);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PARTY_ROSTER);
+    view.setUint32(1, msg.partyId, true);
+    view.setUint32(5, msg.leaderId, true);
+    view.setUint32(9, msg.members.length, true);
+    let offset = 13;
+    for (const entry of msg.members) {
+        view.setUint32(offset + 0, entry.playerId, true);
+        offset += 4;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodePartyRoster(data: Uint8Array): PartyRosterWire | null {
+    if (data.length < 13 || data[0] !== WireMessageType.PARTY_ROSTER) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(9, true);
+    if (data.length < 13 + count * 4) return null;
+    const members: PartyRosterEntry[] = new Array(count);
+    for (let i = 0, offset = 13; i < count; i++, offset += 4) {
+        members[i] = {
+            playerId: view.getUint32(offset + 0, true),
+        };
+    }
+    return {
+        partyId: view.getUint32(1, true),
+        leaderId: view.getUint32(5, true),
+        members,
+    };
+}
+
+/** Party chat line of a member, to every member (the sender included). */
+export interface PartyChatMessageWire {
+    playerId: number;
+    text: string;
+}
+
+export function encodePartyChatMessage(msg: PartyChatMessageWire): Uint8Array {
+    const textBytes = encodeText(msg.text, 256);
+    const buffer = new ArrayBuffer(9 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PARTY_CHAT_MESSAGE);
+    view.setUint32(1, msg.playerId, true);
+    view.setUint32(5, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 9);
+    return new Uint8Array(buffer);
+}
+
+export function decodePartyChatMessage(data: Uint8Array): PartyChatMessageWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.PARTY_CHAT_MESSAGE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(5, true);
+    if (data.length < 9 + textLength) return null;
+    return {
+        playerId: view.getUint32(1, true),
+        text: wireTextDecoder.decode(data.subarray(9, 9 + textLength)),
+    };
+}
+
+export interface InventoryEntry {
+    item: number;
+    count: number;
+}
+
+/** The client's items, by item number, after JOIN and after every change (RELIABLE for capability bit 6, latest wins). Signed-in players only. */
+export interface InventoryWire {
+    items: InventoryEntry[];
+}
+
+export function encodeInventory(msg: InventoryWire): Uint8Array {
+    const buffer = new ArrayBuffer(5 + msg.items.length * 6);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.INVENTORY);
+    view.setUint32(1, msg.items.length, true);
+    let offset = 5;
+    for (const entry of msg.items) {
+        view.setUint16(offset + 0, entry.item, true);
+        view.setUint32(offset + 2, entry.count, true);
+        offset += 6;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeInventory(data: Uint8Array): InventoryWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.INVENTORY) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(1, true);
+    if (data.length < 5 + count * 6) return null;
+    const items: InventoryEntry[] = new Array(count);
+    for (let i = 0, offset = 5; i < count; i++, offset += 6) {
+        items[i] = {
+            item: view.getUint16(offset + 0, true),
+            count: view.getUint32(offset + 2, true),
+        };
+    }
+    return {
+        items,
+    };
+}
+
+export interface TradeStateEntry {
+    side: number;
+    item: number;
+    count: number;
+}
+
+/** The client's trade after every change: a request to answer, the offers of both sides while open, then completed or cancelled. */
+export interface TradeStateWire {
+    tradeId: number;
+    status: number;
+    partnerId: number;
+    confirmed: number;
+    offers: TradeStateEntry[];
+}
+
+export function encodeTradeState(msg: TradeStateWire): Uint8Array {
+    const buffer = new ArrayBuffer(15 + msg.offers.length * 7);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.TRADE_STATE);
+    view.setUint32(1, msg.tradeId, true);
+    view.setUint8(5, msg.status);
+    view.setUint32(6, msg.partnerId, true);
+    view.setUint8(10, msg.confirmed);
+    view.setUint32(11, msg.offers.length, true);
+    let offset = 15;
+    for (const entry of msg.offers) {
+        view.setUint8(offset + 0, entry.side);
+        view.setUint16(offset + 1, entry.item, true);
+        view.setUint32(offset + 3, entry.count, true);
+        offset += 7;
+    }
+    return new Uint8Array(buffer);
+}
+
+export function decodeTradeState(data: Uint8Array): TradeStateWire | null {
+    if (data.length < 15 || data[0] !== WireMessageType.TRADE_STATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const count = view.getUint32(11, true);
+    if (data.length < 15 + count * 7) return null;
+    const offers: TradeStateEntry[] = new Array(count);
+    for (let i = 0, offset = 15; i < count; i++, offset += 7) {
+        offers[i] = {
+            side: view.getUint8(offset + 0),
+            item: view.getUint16(offset + 1, true),
+            count: view.getUint32(offset + 3, true),
+        };
+    }
+    return {
+        tradeId: view.getUint32(1, true),
+        status: view.getUint8(5),
+        partnerId: view.getUint32(6, true),
+        confirmed: view.getUint8(10),
+        offers,
+    };
+}
+
+/** A player within EMOTE_RADIUS of the client, or the client itself, plays an emote. Not RELIABLE: a late emote is not worth a retransmission. */
+export interface PlayerEmoteWire {
+    playerId: number;
+    emoteId: number;
+}
+
+export function encodePlayerEmote(msg: PlayerEmoteWire): Uint8Array {
+    const buffer = new ArrayBuffer(6);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.PLAYER_EMOTE);
+    view.setUint32(1, msg.playerId, true);
+    view.setUint8(5, msg.emoteId);
+    return new Uint8Array(buffer);
+}
+
+export function decodePlayerEmote(data: Uint8Array): PlayerEmoteWire | null {
+    if (data.length < 6 || data[0] !== WireMessageType.PLAYER_EMOTE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        playerId: view.getUint32(1, true),
+        emoteId: view.getUint8(5),
+    };
+}
+
+/** Large worlds only (a side of 65536 / 2^positionBits world units or more, see CONFIG): the point that positions in all later messages are relative to, world x = originX + x / 2^positionBits. Sent after CONFIG, ahead of the first GAME_STATE, and again whenever the client's player leaves the middle half of the window the wire positions cover. Messages encoded for the old origin all arrive before it. Without one the origin is (0, 0). */
+export interface OriginWire {
+    originX: number;
+    originY: number;
+}
+
+export function encodeOrigin(msg: OriginWire): Uint8Array {
+    const buffer = new ArrayBuffer(9);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.ORIGIN);
+    view.setUint32(1, msg.originX, true);
+    view.setUint32(5, msg.originY, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeOrigin(data: Uint8Array): OriginWire | null {
+    if (data.length < 9 || data[0] !== WireMessageType.ORIGIN) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        originX: view.getUint32(1, true),
+        originY: view.getUint32(5, true),
+    };
+}
+
+/** The server is full and holds the client's JOIN in the login queue (LOGIN_QUEUE_SIZE). Sent right after JOIN and then every LOGIN_QUEUE_UPDATE_MS until QUEUE_ADMITTED. The client should keep the connection open and send nothing; messages other than JOIN are ignored while queued. */
+export interface QueueStatusWire {
+    position: number;
+    length: number;
+    waitSec: number;
+    tier: number;
+}
+
+export function encodeQueueStatus(msg: QueueStatusWire): Uint8Array {
+    const buffer = new ArrayBuffer(14);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.QUEUE_STATUS);
+    view.setUint32(1, msg.position, true);
+    view.setUint32(5, msg.length, true);
+    view.setUint32(9, msg.waitSec, true);
+    view.setUint8(13, msg.tier);
+    return new Uint8Array(buffer);
+}
+
+export function decodeQueueStatus(data: Uint8Array): QueueStatusWire | null {
+    if (data.length < 14 || data[0] !== WireMessageType.QUEUE_STATUS) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        position: view.getUint32(1, true),
+        length: view.getUint32(5, true),
+        waitSec: view.getUint32(9, true),
+        tier: view.getUint8(13),
+    };
+}
+
+/** A slot freed up: the queued JOIN goes ahead. CONFIG and the initial state follow as after any JOIN. */
+export interface QueueAdmittedWire {
+    waitedMs: number;
+}
+
+export function encodeQueueAdmitted(msg: QueueAdmittedWire): Uint8Array {
+    const buffer = new ArrayBuffer(5);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.QUEUE_ADMITTED);
+    view.setUint32(1, msg.waitedMs, true);
+    return new Uint8Array(buffer);
+}
+
+export function decodeQueueAdmitted(data: Uint8Array): QueueAdmittedWire | null {
+    if (data.length < 5 || data[0] !== WireMessageType.QUEUE_ADMITTED) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    return {
+        waitedMs: view.getUint32(1, true),
+    };
+}
+
+/** One entry of the client's friend list: all of them after JOIN, then on every change and when a friend comes online, goes offline or moves to another zone (FRIEND_PRESENCE_INTERVAL_MS). Presence covers the players of this server. */
+export interface FriendUpdateWire {
+    status: number;
+    playerId: number;
+    zoneRow: number;
+    zoneCol: number;
+    account: string;
+}
+
+export function encodeFriendUpdate(msg: FriendUpdateWire): Uint8Array {
+    const textBytes = encodeText(msg.account, 64);
+    const buffer = new ArrayBuffer(12 + textBytes.length);
+    const view = new DataView(buffer);
+    view.setUint8(0, WireMessageType.FRIEND_UPDATE);
+    view.setUint8(1, msg.status);
+    view.setUint32(2, msg.playerId, true);
+    view.setUint8(6, msg.zoneRow);
+    view.setUint8(7, msg.zoneCol);
+    view.setUint32(8, textBytes.length, true);
+    new Uint8Array(buffer).set(textBytes, 12);
+    return new Uint8Array(buffer);
+}
+
+export function decodeFriendUpdate(data: Uint8Array): FriendUpdateWire | null {
+    if (data.length < 12 || data[0] !== WireMessageType.FRIEND_UPDATE) return null;
+    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+    const textLength = view.getUint32(8, true);
+    if (data.length < 12 + textLength) return null;
+    return {
+        status: view.getUint8(1),
+        playerId: view.getUint32(2, true),
+        zoneRow: view.getUint8(6),
+        zoneCol: view.getUint8(7),
+        account: wireTextDecoder.decode(data.subarray(12, 12 + textLength)),
+    };
+}
@@ -14,6 +14,8 @@ export interface PlayerState {
     direction: -1 | 1;  // -1 for left, 1 for right
     moving: boolean;
     attacking?: boolean;
+    spawnProtected?: boolean;
+    safeZone?: boolean; // in a safe zone: cannot attack or be hit
     vx?: number;
     vy?: number;
     movementVector?: { dx: number; dy: number };
@@ -34,6 +36,11 @@ export interface MoveMessage extends ClientMessage {
         dx: number;
         dy: number;
     };
+    // Analog stick, -127..127 per axis with the signs of dx/dy; absent = digital (full axes)
+    axis?: {
+        x: number;
+        y: number;
+    };
     inputSequence: number;
     position: PlayerPosition; // Позиция клиента в момент отправки
 }
@@ -45,7 +52,7 @@ export interface DirectionChangeMessage extends ClientMessage {
 
 export interface AttackMessage extends ClientMessage {
     type: 'attack';
-    position: PlayerPosition;
+    aim?: { x: number; y: number }; // направление удара, -127..127 по осям; без него — по направлению взгляда
 }
 
 export interface AttackEndMessage extends ClientMessage {
@@ -116,6 +123,200 @@ export interface ServerCorrectionMessage extends ServerMessage {
     position: PlayerPosition;
 }
 
+export interface WorldEventMessage extends ServerMessage {
+    type: 'worldEvent';
+    kind: number;
+    active: boolean;
+    value: number;
+    durationMs: number;
+    text: string;
+}
+
+// Message of the day or admin announcement, already in our language if the server has it
+export interface AnnounceMessage extends ServerMessage {
+    type: 'announce';
+    kind: number;
+    severity: number;
+    text: string;
+}
+
+// Chat line of a player (ours included: the server relays it back)
+export interface ChatMessage extends ServerMessage {
+    type: 'chat';
+    playerId: string;
+    text: string;
+}
+
+// Answer to a console command we sent as chat ("/who")
+export interface CommandResultMessage extends ServerMessage {
+    type: 'commandResult';
+    status: number;
+    text: string;
+}
+
+// An attack landed: we hit someone (confirmation) or were hit (apply)
+export interface HitMessage extends ServerMessage {
+    type: 'hit';
+    attackerId: string;
+    victimId: string;
+    damage: number;
+    hp: number; // victim's health after the hit; 0 = killed
+    knockbackX: number; // already applied to the victim's position
+    knockbackY: number;
+}
+
+// The server refused one of our inputs: undo whatever we showed for it
+export interface ActionRejectedMessage extends ServerMessage {
+    type: 'actionRejected';
+    action: number; // MessageType of the refused input
+    reason: number; // RejectReason
+    retryAfterTicks: number; // 0 = retry any time
+}
+
+// A player near us (or we) plays an emote; the name is in the gameConfig catalog
+export interface PlayerEmoteMessage extends ServerMessage {
+    type: 'playerEmote';
+    playerId: string;
+    emoteId: number;
+}
+
+export interface MaintenanceMessage extends ServerMessage {
+    type: 'maintenance';
+    phase: number;
+    countdownMs: number;
+}
+
+export interface ConfigMessage extends ServerMessage {
+    type: 'config';
+    playerId: string;
+    tickRate: number;
+    playerSpeedPerTick: number;
+    worldWidth: number; // worldWidthHigh already merged in
+    worldHeight: number;
+    boundaryMode: number;
+    acceleration?: number; // units/s²; absent from older servers
+    friction?: number;
+    positionBits?: number; // positions in 1/2^positionBits units; absent = whole units
+    worldSeed?: number; // the layout was generated from this seed (WORLD_SEED); 0 or absent = not generated
+}
+
+// The world was resized at runtime (replaces CONFIG's world size)
+// Large worlds: positions in later messages are relative to this point (applied by
+// BinaryProtocol, already included in decoded positions)
+export interface OriginMessage extends ServerMessage {
+    type: 'origin';
+    originX: number;
+    originY: number;
+}
+
+// The server is full: our JOIN waits in the login queue (again every few seconds)
+export interface QueueStatusMessage extends ServerMessage {
+    type: 'queueStatus';
+    position: number; // 1 = next
+    length: number;
+    waitSec: number;  // estimate; 0 = none yet
+    tier: number;     // QueueTier
+}
+
+// We left the login queue; CONFIG and the game state follow
+export interface QueueAdmittedMessage extends ServerMessage {
+    type: 'queueAdmitted';
+    waitedMs: number;
+}
+
+export interface WorldUpdateMessage extends ServerMessage {
+    type: 'worldUpdate';
+    worldWidth: number;
+    worldHeight: number;
+    boundaryMode: number;
+}
+
+// Player density grid, row-major from the top-left corner (counts saturate at 255)
+export interface MinimapMessage extends ServerMessage {
+    type: 'minimap';
+    cols: number;
+    rows: number;
+    cellWidth: number;  // world units per cell
+    cellHeight: number;
+    counts: Uint8Array; // cols * rows
+}
+
+// Top players of one metric, best first (scores span every server of the deployment)
+export interface LeaderboardMessage extends ServerMessage {
+    type: 'leaderboard';
+    metric: number; // LeaderboardMetric
+    entries: { playerId: string | null; score: number }[]; // playerId null = not online here
+}
+
+// Another player invites us to its party (answer with NetworkManager.acceptPartyInvite)
+export interface PartyInviteMessage extends ServerMessage {
+    type: 'partyInvite';
+    inviterId: string;
+}
+
+// Our party after every change; no members = not in a party
+export interface PartyRosterMessage extends ServerMessage {
+    type: 'partyRoster';
+    partyId: number; // 0 = not in a party
+    leaderId: string | null;
+    members: string[];
+}
+
+// Party chat line of a member (ours included)
+export interface PartyChatMessage extends ServerMessage {
+    type: 'partyChat';
+    playerId: string;
+    text: string;
+}
+
+// One entry of our friend list (all of them after JOIN, then every change and presence)
+export interface FriendUpdateMessage extends ServerMessage {
+    type: 'friendUpdate';
+    status: number; // FriendStatus
+    account: string;
+    playerId: string | null; // online friends only
+    zone: { row: number; col: number } | null; // online friends, when the server has zones
+}
+
+// Our items, by item number (after JOIN and every change); signed-in players only
+export interface InventoryMessage extends ServerMessage {
+    type: 'inventory';
+    items: { item: number; count: number }[];
+}
+
+// Our trade after every change
+export interface TradeStateMessage extends ServerMessage {
+    type: 'tradeState';
+    tradeId: number; // 0 while requested
+    status: number; // TradeStatus
+    partnerId: string;
+    confirmed: boolean; // by us
+    partnerConfirmed: boolean;
+    offers: { item: number; count: number }[]; // ours
+    partnerOffers: { item: number; count: number }[];
+}
+
+// Cell streaming: players of the cells that came into view (cells = cells loaded;
+// a load split over several messages counts them on the first one only)
+export interface CellLoadMessage extends ServerMessage {
+    type: 'cellLoad';
+    cellSize: number;
+    cells: number;
+    players: Record<string, PlayerState>;
+}
+
+// Cell streaming: cells that left the view; drop the players standing in them
+export interface CellUnloadMessage extends ServerMessage {
+    type: 'cellUnload';
+    cellSize: number;
+    cells: { cellX: number; cellY: number }[];
+}
+
+export interface SessionTakeoverMessage extends ServerMessage {
+    type: 'sessionTakeover';
+    playerId: string;
+}
+
 export enum MessageType {
     JOIN = 1,
     LEAVE = 2,
@@ -130,4 +331,156 @@ export enum MessageType {
     PLAYER_JOINED = 11,
     PLAYER_LEFT = 12,
     DELTA_GAME_STATE = 14,
-}
\ No newline at end of file
+    SEQUENCE_REPORT = 16,
+    WORLD_EVENT = 17,
+    MAINTENANCE = 18,
+    SESSION_TAKEOVER = 19,
+    CONFIG = 20,
+    MINIMAP = 21,
+    CIPHER_INIT = 22,
+    SEALED = 23,
+    SEALED_CLIENT = 24,
+    WORLD_UPDATE = 25,
+    CELL_LOAD = 26,
+    CELL_UNLOAD = 27,
+    RELIABLE = 28,
+    ANNOUNCE = 30,
+    CHAT = 31,
+    CHAT_MESSAGE = 32,
+    COMMAND_RESULT = 33,
+    HIT = 34,
+    ACTION_REJECTED = 35,
+    LEADERBOARD = 36,
+    PARTY = 37,
+    PARTY_CHAT = 38,
+    PARTY_INVITE = 39,
+    PARTY_ROSTER = 40,
+    PARTY_CHAT_MESSAGE = 41,
+    FRIEND = 42,
+    FRIEND_UPDATE = 43,
+    INVENTORY = 44,
+    TRADE = 45,
+    TRADE_STATE = 46,
+    EMOTE = 47,
+    PLAYER_EMOTE = 48,
+    ORIGIN = 49,
+    QUEUE_STATUS = 50,
+    QUEUE_ADMITTED = 51,
+}
+
+// WORLD_EVENT kinds
+export const WorldEventKind = {
+    NIGHT: 1,        // active = night, inactive = day
+    STORM: 2,        // value = movement speed percent while active
+    ANNOUNCEMENT: 3, // text only
+} as const;
+
+// ANNOUNCE kinds and severities
+export const AnnounceKind = {
+    MESSAGE: 0, // admin announcement
+    MOTD: 1,    // message of the day, right after JOIN
+} as const;
+
+export const AnnounceSeverity = {
+    INFO: 0,
+    WARNING: 1,
+    CRITICAL: 2,
+} as const;
+
+// COMMAND_RESULT statuses
+export const CommandStatus = {
+    OK: 0,
+    ERROR: 1,   // bad arguments or the command failed
+    DENIED: 2,  // our role may not run it
+    UNKNOWN: 3, // no such command
+    MUTED: 4,   // a plain chat line refused: we are muted
+} as const;
+
+// ACTION_REJECTED reasons
+export const RejectReason = {
+    COOLDOWN: 1,
+    STUNNED: 2,
+    DEAD: 3,
+    FROZEN: 4,       // stopped by an admin
+    RATE_LIMITED: 5, // we sent too many messages; the rest of the burst was dropped
+    UNKNOWN: 6,      // never allowed, e.g. an emote missing from the catalog
+    SAFE_ZONE: 7,    // attacks are off while we stand in a safe zone
+} as const;
+
+// PARTY actions
+export const PartyAction = {
+    INVITE: 0, // playerId = the invitee
+    ACCEPT: 1, // playerId = the inviter
+    LEAVE: 2,
+} as const;
+
+// FRIEND actions
+export const FriendAction = {
+    ADD: 0,    // ask the account, or accept its request
+    REMOVE: 1, // end the friendship, or decline / withdraw a request
+} as const;
+
+// FRIEND_UPDATE statuses
+export const FriendStatus = {
+    OFFLINE: 0,
+    ONLINE: 1,
+    INCOMING: 2, // the account asks us to be friends
+    OUTGOING: 3, // we asked, not answered yet
+    REMOVED: 4,
+} as const;
+
+// TRADE actions
+export const TradeAction = {
+    REQUEST: 0, // playerId = the partner
+    ACCEPT: 1,  // playerId = the requester
+    OFFER: 2,   // item, count; count 0 withdraws the item
+    CONFIRM: 3,
+    CANCEL: 4,  // ends our trade, or declines the request of playerId
+} as const;
+
+// TRADE_STATE statuses
+export const TradeStatus = {
+    REQUESTED: 0, // partnerId asks us to trade
+    OPEN: 1,
+    COMPLETED: 2,
+    CANCELLED: 3, // cancelled, declined, or a side no longer had its offer
+} as const;
+
+// LEADERBOARD metrics (also the metric names of GET /leaderboard, lowercased)
+export const LeaderboardMetric = {
+    KILLS: 0,
+    DAMAGE: 1, // damage dealt
+} as const;
+
+// MAINTENANCE phases
+// QUEUE_STATUS tiers, admitted highest first
+export const QueueTier = {
+    STANDARD: 0,
+    RETURNING: 1, // we played on this server a short time ago
+    VIP: 2,       // our session token carries the vip flag
+} as const;
+
+export const MaintenancePhase = {
+    OVER: 0,      // back to normal
+    SCHEDULED: 1, // simulation pauses in countdownMs
+    PAUSED: 2,    // simulation paused until maintenance is over
+} as const;
+
+// JOIN capability flags
+export const ClientCapability = {
+    DELTA_UPDATES: 0x01, // we merge DELTA_GAME_STATE
+    COMPRESSION: 0x02,   // permessage-deflate welcome (the browser negotiates it)
+    MINIMAP: 0x04,       // send the periodic MINIMAP density grid
+    CHECKSUM: 0x08,      // CRC-32C on every message, both directions
+    ENCRYPTION: 0x10,    // we hold the session key: sensitive messages go sealed
+    CELL_STREAMING: 0x20, // join with ourselves only, the rest arrives as CELL_LOAD / CELL_UNLOAD
+    RELIABLE: 0x40, // joins, leaves and corrections arrive in RELIABLE envelopes we ack
+    LEADERBOARD: 0x80, // send the periodic LEADERBOARD of every metric
+} as const;
+
+// Every server → client frame starts with a u32 (LE) per-connection outbound sequence.
+export const SEQ_HEADER_SIZE = 4;
+// With ClientCapability.CHECKSUM a u32 (LE) CRC-32C of the message follows the sequence,
+// and every message we send after JOIN starts with one.
+export const CHECKSUM_SIZE = 4;
+export const SEQUENCE_REPORT_RESYNC = 0x01;
\ No newline at end of file
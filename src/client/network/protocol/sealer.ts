@@ -0,0 +1,90 @@
+import { MessageType } from "./messages";
+
+// Sealed messages (docs/protocol.md, "Sealed messages"): the message types listed in
+// CIPHER_INIT travel AES-256-GCM encrypted under a key derived from the session key
+// and the CIPHER_INIT salt. The login page hands the session key over in the URL
+// fragment (#key=...), which the browser never sends, so a TLS-terminating edge that
+// sees the token cannot derive it.
+
+const SEALED_HEADER_SIZE = 9; // type u8, counter u32, length u32
+const KEY_LABEL = new TextEncoder().encode("pixi.cipher\0");
+const DIR_CLIENT_TO_SERVER = 0;
+const DIR_SERVER_TO_CLIENT = 1;
+
+export class SessionSealer {
+    private sent = 0;     // last counter sealed
+    private received = 0; // last counter opened
+
+    private constructor(private readonly key: CryptoKey, readonly types: ReadonlySet<number>) {}
+
+    static async create(sessionKey: Uint8Array, salt: Uint8Array, types: number[]): Promise<SessionSealer> {
+        const hmacKey = await crypto.subtle.importKey(
+            "raw", sessionKey as Uint8Array<ArrayBuffer>, { name: "HMAC", hash: "SHA-256" }, false, ["sign"]);
+        const info = new Uint8Array(KEY_LABEL.length + salt.length);
+        info.set(KEY_LABEL);
+        info.set(salt, KEY_LABEL.length);
+        const raw = await crypto.subtle.sign("HMAC", hmacKey, info);
+        const key = await crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["encrypt", "decrypt"]);
+        return new SessionSealer(key, new Set(types));
+    }
+
+    // Opens a SEALED envelope; null if it fails authentication or replays a counter.
+    async open(data: Uint8Array): Promise<Uint8Array | null> {
+        if (data.length < SEALED_HEADER_SIZE || data[0] !== MessageType.SEALED) return null;
+        const view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+        const counter = view.getUint32(1, true);
+        if (view.getUint32(5, true) !== data.length - SEALED_HEADER_SIZE || counter <= this.received) return null;
+        try {
+            const plain = await crypto.subtle.decrypt(
+                { name: "AES-GCM", iv: nonce(DIR_SERVER_TO_CLIENT, counter), additionalData: data.slice(0, SEALED_HEADER_SIZE) },
+                this.key,
+                data.slice(SEALED_HEADER_SIZE),
+            );
+            // Messages are opened in arrival order (see NetworkManager), so this still holds.
+            if (counter <= this.received) return null;
+            this.received = counter;
+            return new Uint8Array(plain);
+        } catch {
+            return null;
+        }
+    }
+
+    // Seals message into a SEALED_CLIENT envelope. Call in send order: counters follow it.
+    async seal(message: Uint8Array): Promise<Uint8Array> {
+        const counter = ++this.sent;
+        const header = new Uint8Array(SEALED_HEADER_SIZE);
+        const view = new DataView(header.buffer);
+        view.setUint8(0, MessageType.SEALED_CLIENT);
+        view.setUint32(1, counter, true);
+        view.setUint32(5, message.length + 16, true); // + GCM tag
+        const cipher = await crypto.subtle.encrypt(
+            { name: "AES-GCM", iv: nonce(DIR_CLIENT_TO_SERVER, counter), additionalData: header },
+            this.key,
+            message as Uint8Array<ArrayBuffer>,
+        );
+        const out = new Uint8Array(SEALED_HEADER_SIZE + cipher.byteLength);
+        out.set(header);
+        out.set(new Uint8Array(cipher), SEALED_HEADER_SIZE);
+        return out;
+    }
+}
+
+// nonce = [direction u8][7 zero bytes][counter u32 LE]
+function nonce(direction: number, counter: number): Uint8Array<ArrayBuffer> {
+    const iv = new Uint8Array(12);
+    iv[0] = direction;
+    new DataView(iv.buffer).setUint32(8, counter, true);
+    return iv;
+}
+
+// Decodes the session key from the page URL fragment (#key=<base64url>), if any.
+export function sessionKeyFromLocation(): Uint8Array | null {
+    const encoded = new URLSearchParams(window.location.hash.slice(1)).get("key");
+    if (!encoded) return null;
+    try {
+        const binary = atob(encoded.replace(/-/g, "+").replace(/_/g, "/"));
+        return Uint8Array.from(binary, (c) => c.charCodeAt(0));
+    } catch {
+        return null;
+    }
+}
@@ -0,0 +1,79 @@
+import { NetworkManager } from "../network/networkManager";
+import { CommandStatus } from "../network/protocol/messages";
+
+// Lines kept in the chat log; older ones are dropped
+const CHAT_LOG_LIMIT = 50;
+
+const STATUS_COLORS: Record<number, string> = {
+    [CommandStatus.OK]: "#9be59b",
+    [CommandStatus.ERROR]: "#ffd24a",
+    [CommandStatus.DENIED]: "#ff5a5a",
+    [CommandStatus.UNKNOWN]: "#ffd24a",
+    [CommandStatus.MUTED]: "#ff5a5a",
+};
+
+// Chat overlay (DOM, above the canvas): Enter opens the input, Enter sends, Escape closes.
+// Lines starting with "/" are console commands; their results show only to us.
+export class ChatBox {
+    private log: HTMLDivElement;
+    private input: HTMLInputElement;
+
+    constructor(private networkManager: NetworkManager) {
+        const root = document.createElement("div");
+        root.style.cssText = "position:fixed;left:10px;bottom:10px;width:360px;font:13px Arial,sans-serif;color:#fff;z-index:10;";
+
+        this.log = document.createElement("div");
+        this.log.style.cssText = "max-height:180px;overflow-y:auto;white-space:pre-wrap;text-shadow:0 0 2px #000;pointer-events:none;";
+
+        this.input = document.createElement("input");
+        this.input.type = "text";
+        this.input.placeholder = "Say something or /help";
+        this.input.style.cssText = "display:none;width:100%;box-sizing:border-box;margin-top:4px;padding:4px;background:rgba(0,0,0,0.7);color:#fff;border:1px solid #555;";
+
+        root.append(this.log, this.input);
+        document.body.appendChild(root);
+
+        window.addEventListener("keydown", (e) => {
+            if (e.key === "Enter" && document.activeElement !== this.input) {
+                e.preventDefault();
+                this.open();
+            }
+        });
+        this.input.addEventListener("keydown", (e) => {
+            if (e.key === "Enter") {
+                e.preventDefault();
+                this.networkManager.sendChat(this.input.value);
+                this.close();
+            } else if (e.key === "Escape") {
+                this.close();
+            }
+        });
+
+        networkManager.onChat((chat) => this.append(`${chat.playerId}: ${chat.text}`, "#fff"));
+        networkManager.onCommandResult((result) =>
+            this.append(result.text, STATUS_COLORS[result.status] ?? "#fff")
+        );
+    }
+
+    private open() {
+        this.input.style.display = "block";
+        this.input.focus();
+    }
+
+    private close() {
+        this.input.value = "";
+        this.input.blur();
+        this.input.style.display = "none";
+    }
+
+    private append(text: string, color: string) {
+        const line = document.createElement("div");
+        line.textContent = text;
+        line.style.color = color;
+        this.log.appendChild(line);
+        while (this.log.childElementCount > CHAT_LOG_LIMIT) {
+            this.log.firstElementChild?.remove();
+        }
+        this.log.scrollTop = this.log.scrollHeight;
+    }
+}
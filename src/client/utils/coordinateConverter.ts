@@ -107,6 +107,13 @@ export class CoordinateConverter {
         }
     }
 
+    /**
+     * Пересчитать коэффициенты после смены размера мира (CONFIG от сервера)
+     */
+    refreshWorldSize(): void {
+        this.calculateScales();
+    }
+
     /**
      * Получить текущие размеры экрана
      */
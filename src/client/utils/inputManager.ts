@@ -12,6 +12,10 @@ export class InputManager {
 
     private init() {
         window.addEventListener("keydown", (e) => {
+            // Typing in the chat box does not move the player
+            if (e.target instanceof HTMLInputElement || e.target instanceof HTMLTextAreaElement) {
+                return;
+            }
             this.keysPressed[e.key.toLowerCase()] = true;
 
             // Handle F3 key specifically
@@ -33,6 +37,13 @@ export class InputManager {
             }
         });
 
+        // Focus moving to the chat box would swallow the keyup of a held key
+        window.addEventListener("focusin", (e) => {
+            if (e.target instanceof HTMLInputElement || e.target instanceof HTMLTextAreaElement) {
+                this.keysPressed = {};
+            }
+        });
+
         this.canvas.addEventListener("mousemove", (e) => {
             const rect = this.canvas.getBoundingClientRect();
             this.mousePosition.x = e.clientX - rect.left;
@@ -0,0 +1,226 @@
+// bench runs the hot-path Go benchmarks (protocol codec, broadcast fan-out, spatial
+// grid) and prints ns/op and allocs/op per benchmark. With -save and -baseline two
+// runs can be compared, e.g. before and after a performance redesign.
+//
+// Usage (from src/server):
+//
+//	go run ./cmd/bench -save before.json
+//	go run ./cmd/bench -baseline before.json
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// hotPathPackages hold the *_bench_test.go files this harness is for.
+var hotPathPackages = []string{
+	"./internal/protocol",
+	"./internal/server",
+	"./internal/systems",
+}
+
+// Result is one benchmark line of `go test -bench -benchmem` output.
+type Result struct {
+	Name        string  `json:"name"`
+	Package     string  `json:"package"`
+	Iterations  int64   `json:"iterations"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+func (r Result) key() string { return r.Package + "." + r.Name }
+
+func main() {
+	bench := flag.String("bench", ".", "benchmark regexp passed to go test -bench")
+	benchtime := flag.String("benchtime", "", "go test -benchtime (default: go test's 1s)")
+	count := flag.Int("count", 1, "go test -count")
+	cpu := flag.String("cpu", "", "go test -cpu list")
+	savePath := flag.String("save", "", "write results as JSON to this path")
+	baselinePath := flag.String("baseline", "", "compare against results saved earlier with -save")
+	flag.Parse()
+
+	pkgs := flag.Args()
+	if len(pkgs) == 0 {
+		pkgs = hotPathPackages
+	}
+
+	var baseline map[string]Result
+	if *baselinePath != "" {
+		var err error
+		if baseline, err = loadResults(*baselinePath); err != nil {
+			slog.Error("failed to read baseline", "path", *baselinePath, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	args := []string{"test", "-run", "^$", "-bench", *bench, "-benchmem", "-count", strconv.Itoa(*count)}
+	if *benchtime != "" {
+		args = append(args, "-benchtime", *benchtime)
+	}
+	if *cpu != "" {
+		args = append(args, "-cpu", *cpu)
+	}
+	args = append(args, pkgs...)
+
+	// The servers under benchmark log through slog; only the go test stdout is parsed.
+	cmd := exec.Command("go", args...)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		slog.Error("failed to start go test", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("running benchmarks", "cmd", "go "+strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		slog.Error("failed to start go test", "error", err)
+		os.Exit(1)
+	}
+	results := parse(stdout)
+	if err := cmd.Wait(); err != nil {
+		slog.Error("go test failed", "error", err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		slog.Error("no benchmarks matched", "bench", *bench)
+		os.Exit(1)
+	}
+
+	printResults(os.Stdout, results, baseline)
+
+	if *savePath != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			slog.Error("failed to encode results", "error", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(*savePath, append(data, '\n'), 0o644); err != nil {
+			slog.Error("failed to write results", "path", *savePath, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("results saved", "path", *savePath)
+	}
+}
+
+// benchLine matches "BenchmarkX/sub-8   1000   1234 ns/op   56 B/op   2 allocs/op",
+// ignoring extra metrics such as MB/s in between.
+var benchLine = regexp.MustCompile(`^(Benchmark\S+)\s+(\d+)\s+([\d.]+) ns/op(.*)$`)
+
+var (
+	bytesMetric  = regexp.MustCompile(`(\d+) B/op`)
+	allocsMetric = regexp.MustCompile(`(\d+) allocs/op`)
+)
+
+// parse reads go test -bench output. With -count > 1 a benchmark appears several
+// times; the runs are averaged.
+func parse(r io.Reader) []Result {
+	var (
+		results []Result
+		runs    = map[string]int{}
+		index   = map[string]int{}
+		pkg     string
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		m := benchLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		res := Result{Name: m[1], Package: pkg}
+		res.Iterations, _ = strconv.ParseInt(m[2], 10, 64)
+		res.NsPerOp, _ = strconv.ParseFloat(m[3], 64)
+		if b := bytesMetric.FindStringSubmatch(m[4]); b != nil {
+			res.BytesPerOp, _ = strconv.ParseInt(b[1], 10, 64)
+		}
+		if a := allocsMetric.FindStringSubmatch(m[4]); a != nil {
+			res.AllocsPerOp, _ = strconv.ParseInt(a[1], 10, 64)
+		}
+
+		i, seen := index[res.key()]
+		if !seen {
+			index[res.key()] = len(results)
+			runs[res.key()] = 1
+			results = append(results, res)
+			continue
+		}
+		n := runs[res.key()]
+		prev := &results[i]
+		prev.Iterations += res.Iterations
+		prev.NsPerOp = (prev.NsPerOp*float64(n) + res.NsPerOp) / float64(n+1)
+		prev.BytesPerOp = (prev.BytesPerOp*int64(n) + res.BytesPerOp) / int64(n+1)
+		prev.AllocsPerOp = (prev.AllocsPerOp*int64(n) + res.AllocsPerOp) / int64(n+1)
+		runs[res.key()] = n + 1
+	}
+	return results
+}
+
+func loadResults(path string) (map[string]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]Result, len(results))
+	for _, r := range results {
+		byKey[r.key()] = r
+	}
+	return byKey, nil
+}
+
+func printResults(w io.Writer, results []Result, baseline map[string]Result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "benchmark\tns/op\tB/op\tallocs/op"
+	if baseline != nil {
+		header = "benchmark\tns/op\tΔ ns/op\tB/op\tallocs/op\tΔ allocs/op"
+	}
+	fmt.Fprintln(tw, header)
+	pkg := ""
+	for _, r := range results {
+		if r.Package != pkg {
+			pkg = r.Package
+			fmt.Fprintln(tw, pkg+strings.Repeat("\t", strings.Count(header, "\t")))
+		}
+		name := "  " + strings.TrimPrefix(r.Name, "Benchmark")
+		if baseline == nil {
+			fmt.Fprintf(tw, "%s\t%.1f\t%d\t%d\n", name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+			continue
+		}
+		base, ok := baseline[r.key()]
+		nsDelta, allocDelta := "new", "new"
+		if ok {
+			nsDelta = percentDelta(base.NsPerOp, r.NsPerOp)
+			allocDelta = percentDelta(float64(base.AllocsPerOp), float64(r.AllocsPerOp))
+		}
+		fmt.Fprintf(tw, "%s\t%.1f\t%s\t%d\t%d\t%s\n", name, r.NsPerOp, nsDelta, r.BytesPerOp, r.AllocsPerOp, allocDelta)
+	}
+	tw.Flush()
+}
+
+func percentDelta(before, after float64) string {
+	switch {
+	case before == after:
+		return "~"
+	case before == 0:
+		return "+inf"
+	}
+	return fmt.Sprintf("%+.1f%%", (after-before)/before*100)
+}
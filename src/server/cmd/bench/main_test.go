@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	out := `goos: linux
+pkg: pixi_game_server/internal/protocol
+BenchmarkEncodeGameState/players=100-8   	  500000	      2100 ns/op	 523.81 MB/s	    1152 B/op	       1 allocs/op
+2026/10/15 20:36:14 INFO gameworld stopped
+BenchmarkEncodeGameState/players=100-8   	  500000	      1900 ns/op	 578.95 MB/s	    1152 B/op	       3 allocs/op
+pkg: pixi_game_server/internal/systems
+BenchmarkCellPopulation-8   	 3000000	       310.0 ns/op	       0 B/op	       0 allocs/op
+PASS
+`
+	got := parse(strings.NewReader(out))
+	want := []Result{
+		{Name: "BenchmarkEncodeGameState/players=100-8", Package: "pixi_game_server/internal/protocol",
+			Iterations: 1000000, NsPerOp: 2000, BytesPerOp: 1152, AllocsPerOp: 2},
+		{Name: "BenchmarkCellPopulation-8", Package: "pixi_game_server/internal/systems",
+			Iterations: 3000000, NsPerOp: 310},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parse returned %d results, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
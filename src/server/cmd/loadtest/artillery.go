@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+)
+
+// Artillery export: a scenario config and a processor module rendered from the
+// Scenario (the client flow) and protocol.Messages (the wire format), so the
+// Artillery stack in utils/testing/artillery never drifts from the Go clients.
+
+const (
+	artilleryConfigFile    = "artillery-config.yml"
+	artilleryProcessorFile = "artillery-processor.cjs"
+
+	artilleryHeader       = "Code generated by cmd/loadtest -export-artillery from internal/protocol/schema.go. DO NOT EDIT."
+	artilleryConfigHeader = "Code generated by cmd/loadtest -export-artillery. DO NOT EDIT."
+)
+
+// artilleryLoad — load shape of the exported config.
+type artilleryLoad struct {
+	target  string
+	clients int
+	ramp    time.Duration
+	hold    time.Duration
+}
+
+// exportArtillery writes the config/processor pair into dir.
+func exportArtillery(dir string, sc *Scenario, load artilleryLoad) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data string
+	}{
+		{artilleryConfigFile, renderArtilleryConfig(sc, load)},
+		{artilleryProcessorFile, renderArtilleryProcessor(sc, protocol.Messages)},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), []byte(f.data), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderArtilleryConfig(sc *Scenario, load artilleryLoad) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", artilleryConfigHeader)
+	fmt.Fprintf(&b, "# go run ./cmd/loadtest -export-artillery <dir> -url %s -clients %d -ramp %s -duration %s\n",
+		load.target, load.clients, load.ramp, load.hold)
+	b.WriteString("config:\n")
+	fmt.Fprintf(&b, "  target: '%s'\n", load.target)
+	fmt.Fprintf(&b, "  processor: './%s'\n", artilleryProcessorFile)
+	b.WriteString("  phases:\n")
+	rampSeconds := max(int(math.Ceil(load.ramp.Seconds())), 1)
+	arrivalRate := max(int(math.Ceil(float64(load.clients)/float64(rampSeconds))), 1)
+	fmt.Fprintf(&b, "    - duration: %d\n      arrivalRate: %d\n      name: \"Ramp to %d clients\"\n",
+		rampSeconds, arrivalRate, load.clients)
+	if load.hold > 0 {
+		fmt.Fprintf(&b, "    - pause: %d\n      name: \"%d clients sustained\"\n",
+			int(math.Ceil(load.hold.Seconds())), load.clients)
+	}
+	fmt.Fprintf(&b, "  ws:\n    timeout: 30\n    subprotocols: ['%s']\n", protocol.Subprotocol)
+	b.WriteString("  plugins:\n    metrics-by-endpoint:\n      useOnlyRequestNames: true\n\n")
+
+	b.WriteString("scenarios:\n")
+	b.WriteString("  - name: \"Game Client\"\n    engine: \"ws\"\n    weight: 100\n    flow:\n")
+	b.WriteString("      - function: \"initializeClient\"\n")
+	b.WriteString("      # JOIN handshake (server closes connections that skip it)\n")
+	b.WriteString("      - function: \"sendJoin\"\n")
+	fmt.Fprintf(&b, "      - think: %s\n", seconds(sc.JoinDelay))
+	b.WriteString("      - loop:\n")
+	for _, step := range sc.Loop {
+		fmt.Fprintf(&b, "          - function: \"%s\"\n", step.Action.artilleryFunction())
+		fmt.Fprintf(&b, "          - think: %s\n", seconds(step.Think))
+	}
+	// Every VU stays connected until the end of the hold phase, like the Go clients.
+	fmt.Fprintf(&b, "        count: %d\n", sc.LoopsFor(load.ramp+load.hold))
+	b.WriteString("      - function: \"logDisconnect\"\n")
+	return b.String()
+}
+
+// seconds formats d for Artillery think steps, e.g. 0.5.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%g", d.Seconds())
+}
+
+func renderArtilleryProcessor(sc *Scenario, msgs []protocol.MessageSchema) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\n\n", artilleryHeader)
+	b.WriteString("// Bandwidth control: set MOVE_SEND_RATE=0.1 to send only 10% of moves.\n")
+	b.WriteString("// Useful for local → VPS testing to avoid saturating your own upload channel.\n")
+	b.WriteString("// Example: MOVE_SEND_RATE=0.1 bun artillery run artillery-config.yml\n")
+	b.WriteString("const MOVE_SEND_RATE = parseFloat(process.env.MOVE_SEND_RATE || '1.0');\n")
+	b.WriteString("const DIR_SEND_RATE  = parseFloat(process.env.DIR_SEND_RATE  || '1.0');\n\n")
+
+	b.WriteString("const MessageType = {\n")
+	for i := range msgs {
+		fmt.Fprintf(&b, "  %s: %d,\n", msgs[i].ConstName(), msgs[i].Type)
+	}
+	b.WriteString("};\n\n")
+
+	fmt.Fprintf(&b, "const CAP_DELTA_UPDATES = 0x%02x;\n\n", protocol.CapDeltaUpdates)
+
+	b.WriteString("function packMovement(m) {\n")
+	b.WriteString("  return ((m.dx + 1) & 0x03) | (((m.dy + 1) & 0x03) << 2);\n}\n\n")
+	b.WriteString("// UTF-8 bytes of text, cut to maxLen bytes on a character boundary.\n")
+	b.WriteString("function encodeText(text, maxLen) {\n")
+	b.WriteString("  const bytes = new TextEncoder().encode(text);\n")
+	b.WriteString("  if (bytes.length <= maxLen) return bytes;\n")
+	b.WriteString("  let end = maxLen;\n")
+	b.WriteString("  while (end > 0 && (bytes[end] & 0xc0) === 0x80) end--;\n")
+	b.WriteString("  return bytes.subarray(0, end);\n}\n")
+	for i := range msgs {
+		if msgs[i].Direction == protocol.ClientToServer {
+			b.WriteString("\n")
+			renderJSEncoder(&b, &msgs[i])
+		}
+	}
+
+	b.WriteString("\nconst MOVE_PATTERNS = [\n")
+	for _, p := range movePatterns {
+		fmt.Fprintf(&b, "  { dx: %d, dy: %d },\n", p[0], p[1])
+	}
+	b.WriteString("];\n\n")
+
+	b.WriteString("function send(context, data) {\n")
+	b.WriteString("  if (context.ws && context.ws.readyState === 1) { // WebSocket.OPEN\n")
+	b.WriteString("    context.ws.send(data);\n    context.vars.messagesSent++;\n  }\n}\n\n")
+
+	b.WriteString("module.exports = {\n")
+	b.WriteString(`  initializeClient: function(context, events, done) {
+    context.vars.inputSequence = 1;
+    context.vars.direction = 1;
+    context.vars.attacking = false;
+    context.vars.lastAttackTime = 0;
+    context.vars.sessionStart = Date.now();
+    context.vars.messagesSent = 0;
+    return done();
+  },
+
+  // JOIN handshake: the server allocates the player only after this message
+  sendJoin: function(context, events, done) {
+    send(context, encodeJoin({ capabilities: CAP_DELTA_UPDATES, maxMessageSize: 0 }));
+    return done();
+  },
+
+  generateAndSendMovement: function(context, events, done) {
+    let movement = MOVE_PATTERNS[Math.floor(Math.random() * MOVE_PATTERNS.length)];
+    if (context.vars.attacking && Date.now() - context.vars.lastAttackTime < 500) {
+      movement = { dx: 0, dy: 0 };
+    }
+    if (Math.random() > MOVE_SEND_RATE) {
+      return done();
+    }
+    send(context, encodeMove({ movement, inputSequence: context.vars.inputSequence++ }));
+    return done();
+  },
+
+`)
+	fmt.Fprintf(&b, `  maybeChangeAndSendDirection: function(context, events, done) {
+    if (Math.random() > %g * DIR_SEND_RATE) {
+      return done();
+    }
+    context.vars.direction = context.vars.direction === 1 ? -1 : 1;
+    send(context, encodeDirection({ direction: context.vars.direction }));
+    return done();
+  },
+
+  maybeAttackAndSend: function(context, events, done) {
+    const now = Date.now();
+    if (context.vars.attacking || now - context.vars.lastAttackTime < %d || Math.random() > %g) {
+      return done();
+    }
+    context.vars.attacking = true;
+    context.vars.lastAttackTime = now;
+    send(context, encodeAttack({}));
+    return done();
+  },
+
+  maybeAttackEndAndSend: function(context, events, done) {
+    if (!context.vars.attacking || Date.now() - context.vars.lastAttackTime < %d) {
+      return done();
+    }
+    context.vars.attacking = false;
+    send(context, encodeAttackEnd({}));
+    return done();
+  },
+
+`, sc.DirectionChance, sc.AttackCooldown.Milliseconds(), sc.AttackChance, sc.AttackMinLength.Milliseconds())
+	b.WriteString(`  logDisconnect: function(context, events, done) {
+    const sessionDuration = Date.now() - context.vars.sessionStart;
+    events.emit('counter', 'game.session.completed', 1);
+    events.emit('counter', 'game.session.total_messages', context.vars.messagesSent);
+    if (sessionDuration > 0) {
+      events.emit('rate', 'game.session.messages_per_second', context.vars.messagesSent / (sessionDuration / 1000));
+    }
+    events.emit('histogram', 'game.session.duration_ms', sessionDuration);
+    return done();
+  },
+};
+`)
+	return b.String()
+}
+
+// renderJSEncoder renders encode<Name>(msg) for a client message, byte for byte the
+// layout the server decodes (see the TypeScript encoder in cmd/protogen).
+func renderJSEncoder(b *strings.Builder, m *protocol.MessageSchema) {
+	fmt.Fprintf(b, "// %s\n", m.Doc)
+	fmt.Fprintf(b, "function encode%s(msg) {\n", m.Name)
+	text, hasText := m.Text()
+	if hasText {
+		fmt.Fprintf(b, "  const textBytes = encodeText(msg.%s, %d);\n", text.Name, text.MaxLen)
+		fmt.Fprintf(b, "  const buffer = new ArrayBuffer(%d + textBytes.length);\n", m.Size(0))
+	} else if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "  const buffer = new ArrayBuffer(%d + msg.%s.length * %d);\n", m.Size(0), m.RepeatedName, m.EntrySize())
+	} else {
+		fmt.Fprintf(b, "  const buffer = new ArrayBuffer(%d);\n", m.Size(0))
+	}
+	b.WriteString("  const view = new DataView(buffer);\n")
+	fmt.Fprintf(b, "  view.setUint8(0, MessageType.%s);\n", m.ConstName())
+	offset := 1
+	for _, f := range m.Fields {
+		value := "msg." + f.Name
+		if f.Type == protocol.FieldCount {
+			value = "msg." + m.RepeatedName + ".length"
+		} else if f.Type == protocol.FieldString {
+			value = "textBytes.length"
+		} else if f.Optional {
+			value += " ?? 0"
+		}
+		b.WriteString("  " + jsWrite(f.Type, fmt.Sprint(offset), value) + "\n")
+		offset += f.Type.Width()
+	}
+	if hasText {
+		fmt.Fprintf(b, "  new Uint8Array(buffer).set(textBytes, %d);\n", offset)
+	}
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "  let offset = %d;\n", offset)
+		fmt.Fprintf(b, "  for (const entry of msg.%s) {\n", m.RepeatedName)
+		entryOffset := 0
+		for _, f := range m.Repeated {
+			b.WriteString("    " + jsWrite(f.Type, fmt.Sprintf("offset + %d", entryOffset), "entry."+f.Name) + "\n")
+			entryOffset += f.Type.Width()
+		}
+		fmt.Fprintf(b, "    offset += %d;\n  }\n", m.EntrySize())
+	}
+	b.WriteString("  return new Uint8Array(buffer);\n}\n")
+}
+
+func jsWrite(t protocol.FieldType, offset, value string) string {
+	switch t {
+	case protocol.FieldI8:
+		return fmt.Sprintf("view.setInt8(%s, %s);", offset, value)
+	case protocol.FieldU16:
+		return fmt.Sprintf("view.setUint16(%s, %s, true);", offset, value)
+	case protocol.FieldU32, protocol.FieldCount, protocol.FieldString:
+		return fmt.Sprintf("view.setUint32(%s, %s, true);", offset, value)
+	case protocol.FieldMovement:
+		return fmt.Sprintf("view.setUint8(%s, packMovement(%s));", offset, value)
+	default:
+		return fmt.Sprintf("view.setUint8(%s, %s);", offset, value)
+	}
+}
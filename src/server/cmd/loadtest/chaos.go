@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"pixi_game_server/internal/protocol"
+)
+
+// Chaos mode: -chaos N makes N% of the clients hostile. Each of them is assigned
+// one chaosKind and, once per scenario loop, misbehaves that way instead of taking
+// the first step. The summary shows how often the server dropped such clients;
+// the server itself must stay up and keep serving the well-behaved ones.
+
+type chaosKind uint8
+
+const (
+	chaosMalformed chaosKind = iota // truncated or inconsistent messages
+	chaosOversized                  // one huge binary frame
+	chaosWrongType                  // server→client or unknown message types, text frames
+	chaosReset                      // RST instead of a close handshake
+	chaosStall                      // stops reading, keeps sending
+	chaosDuplicate                  // re-sends old input sequences
+	numChaosKinds
+)
+
+// chaosOversizedBytes — payload of a chaosOversized frame.
+const chaosOversizedBytes = 1 << 20
+
+var chaosKindNames = [numChaosKinds]string{
+	chaosMalformed: "malformed",
+	chaosOversized: "oversized",
+	chaosWrongType: "wrong-type",
+	chaosReset:     "reset",
+	chaosStall:     "stall",
+	chaosDuplicate: "duplicate",
+}
+
+func (k chaosKind) String() string {
+	if k < numChaosKinds {
+		return chaosKindNames[k]
+	}
+	return "unknown"
+}
+
+// parseChaosKinds parses a comma-separated list of kind names; "all" or "" enables every kind.
+func parseChaosKinds(s string) ([]chaosKind, error) {
+	if s == "" || s == "all" {
+		kinds := make([]chaosKind, numChaosKinds)
+		for i := range kinds {
+			kinds[i] = chaosKind(i)
+		}
+		return kinds, nil
+	}
+	var kinds []chaosKind
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for k, n := range chaosKindNames {
+			if n == name {
+				kinds = append(kinds, chaosKind(k))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown chaos kind %q (want one of %s)", name, strings.Join(chaosKindNames[:], ", "))
+		}
+	}
+	return kinds, nil
+}
+
+// chaosCounters — per-kind outcome counters.
+type chaosCounters struct {
+	clients      atomic.Int64 // clients assigned this kind
+	acts         atomic.Int64 // misbehaviours performed
+	serverClosed atomic.Int64 // clients the server disconnected after misbehaving
+}
+
+// chaosAct performs the client's misbehaviour once. It returns false when the
+// client is done (the connection was reset or a write failed).
+func (c *client) chaosAct() bool {
+	c.stats.chaos[c.chaos].acts.Add(1)
+	c.misbehaved = true
+	switch c.chaos {
+	case chaosMalformed:
+		return c.sendRaw(ws.OpBinary, malformedMessages[c.rng.Intn(len(malformedMessages))])
+	case chaosOversized:
+		msg := make([]byte, chaosOversizedBytes)
+		msg[0] = protocol.MessageMove
+		return c.sendRaw(ws.OpBinary, msg)
+	case chaosWrongType:
+		switch c.rng.Intn(3) {
+		case 0:
+			return c.sendRaw(ws.OpBinary, []byte{protocol.MessageGameState, 0, 0, 0, 0, 0, 0, 0, 0})
+		case 1:
+			return c.sendRaw(ws.OpBinary, []byte{0xFF, 0x01, 0x02})
+		default:
+			return c.sendRaw(ws.OpText, []byte(`{"type":"move","dx":1}`))
+		}
+	case chaosReset:
+		if tcp, ok := c.conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0) // Close sends RST
+		}
+		c.conn.Close()
+		c.selfClosed = true
+		return false
+	case chaosStall:
+		c.stalled.Store(true)
+		return true
+	case chaosDuplicate:
+		last := max(c.inputSequence-1, 1)
+		seq := last - min(uint32(c.rng.Intn(3)), last-1)
+		msg := []byte{protocol.MessageMove, protocol.PackMovement(1, 0), 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(msg[2:], seq)
+		for i := 0; i < 3; i++ {
+			if !c.sendRaw(ws.OpBinary, msg) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sendRaw writes one masked client frame with the given opcode.
+func (c *client) sendRaw(op ws.OpCode, payload []byte) bool {
+	if err := wsutil.WriteClientMessage(c.conn, op, payload); err != nil {
+		slog.Debug("write failed", "client", c.id, "error", err)
+		return false
+	}
+	c.stats.sent.Add(1)
+	return true
+}
+
+// malformedMessages — frames the decoder must reject without taking the server down.
+var malformedMessages = [][]byte{
+	{},                                  // empty frame
+	{protocol.MessageMove},              // MOVE without payload
+	{protocol.MessageMove, 0x05, 0x01},  // truncated input sequence
+	{protocol.MessageViewportUpdate, 1}, // truncated viewport
+	// INPUT_BATCH claiming more entries than allowed and than present
+	{protocol.MessageInputBatch, 1, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0x05},
+	// INPUT_BATCH with a count past the end of the frame
+	{protocol.MessageInputBatch, 1, 0, 0, 0, 4, 0, 0, 0, 0x05},
+	{protocol.MessageSequenceReport, 1, 2}, // truncated report
+}
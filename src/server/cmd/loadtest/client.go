@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+
+	"pixi_game_server/internal/protocol"
+)
+
+// serverHeaderSize — per-connection u32 sequence in front of every server message.
+const serverHeaderSize = 4
+
+// stats — counters shared by all clients, read by the reporter.
+type stats struct {
+	connected    atomic.Int64 // currently open
+	connects     atomic.Int64
+	dialErrors   atomic.Int64
+	disconnects  atomic.Int64 // closed by the server or the network before the end
+	sent         atomic.Int64
+	received     atomic.Int64
+	bytesIn      atomic.Int64
+	movementAcks atomic.Int64
+	fullStates   atomic.Int64
+	deltaStates  atomic.Int64
+
+	chaos [numChaosKinds]chaosCounters
+}
+
+// clientOptions — per-run settings shared by all clients.
+type clientOptions struct {
+	url          string
+	scenario     *Scenario
+	moveSendRate float64 // fraction of MOVE steps actually sent (upload bandwidth control)
+	dirSendRate  float64
+	dialTimeout  time.Duration
+	verifier     *verifier // nil unless -verify
+}
+
+// client — one simulated player running the scenario over a WebSocket.
+type client struct {
+	id    int
+	opts  *clientOptions
+	stats *stats
+	rng   *rand.Rand
+	conn  net.Conn
+
+	inputSequence  uint32
+	facingRight    bool
+	attacking      bool
+	lastAttackTime time.Time
+	leaveAt        time.Time // -verify-churn: close the connection at this time
+
+	verify *clientVerify // nil unless -verify
+	stayed bool          // connected until the end of the run
+
+	// Chaos mode (chaos.go).
+	hostile    bool
+	chaos      chaosKind
+	misbehaved bool        // chaosAct ran at least once
+	selfClosed bool        // chaosReset closed the connection on purpose
+	stalled    atomic.Bool // chaosStall: readLoop stops reading
+}
+
+func newClient(id int, opts *clientOptions, st *stats) *client {
+	c := &client{
+		id:            id,
+		opts:          opts,
+		stats:         st,
+		rng:           rand.New(rand.NewSource(int64(id) + time.Now().UnixNano())),
+		inputSequence: 1,
+		facingRight:   true,
+	}
+	if opts.verifier != nil {
+		c.verify = newClientVerify()
+	}
+	return c
+}
+
+// run connects, joins and loops over the scenario until ctx is done or the
+// connection drops.
+func (c *client) run(ctx context.Context) {
+	dialer := ws.Dialer{Timeout: c.opts.dialTimeout, Protocols: []string{protocol.Subprotocol}}
+	conn, _, _, err := dialer.Dial(ctx, c.opts.url)
+	if err != nil {
+		c.stats.dialErrors.Add(1)
+		slog.Debug("dial failed", "client", c.id, "error", err)
+		return
+	}
+	c.conn = conn
+	c.stats.connects.Add(1)
+	c.stats.connected.Add(1)
+	defer c.stats.connected.Add(-1)
+
+	stop := make(chan struct{})
+	readDone := make(chan struct{})
+	go c.readLoop(stop, readDone)
+	defer func() {
+		close(stop)
+		conn.Close()
+		<-readDone
+	}()
+
+	if c.hostile {
+		c.stats.chaos[c.chaos].clients.Add(1)
+	}
+	c.loop(ctx, readDone)
+	c.stayed = ctx.Err() != nil
+	if c.verify != nil {
+		c.verifyEnd(time.Now(), !c.stayed)
+	}
+	if c.stayed || c.selfClosed {
+		return
+	}
+	c.stats.disconnects.Add(1)
+	if c.misbehaved {
+		c.stats.chaos[c.chaos].serverClosed.Add(1)
+	}
+}
+
+// loop runs the scenario until ctx is done or the connection fails. A hostile
+// client replaces the first step of every loop with its misbehaviour.
+func (c *client) loop(ctx context.Context, readDone <-chan struct{}) {
+	if !c.send(encodeJoin()) || !c.sleep(ctx, readDone, c.opts.scenario.JoinDelay) {
+		return
+	}
+	for {
+		for i, step := range c.opts.scenario.Loop {
+			if !c.leaveAt.IsZero() && !time.Now().Before(c.leaveAt) {
+				c.leave()
+				return
+			}
+			var ok bool
+			if i == 0 && c.hostile {
+				ok = c.chaosAct()
+			} else {
+				ok = c.step(step.Action)
+			}
+			if !ok || !c.sleep(ctx, readDone, step.Think) {
+				return
+			}
+		}
+	}
+}
+
+// sleep waits d; false when the run is over or the server closed the connection.
+func (c *client) sleep(ctx context.Context, readDone <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-readDone:
+		return false
+	}
+}
+
+// step performs one scenario action with the same odds as the Artillery processor.
+func (c *client) step(a Action) bool {
+	sc := c.opts.scenario
+	switch a {
+	case ActionMove:
+		mv := movePatterns[c.rng.Intn(len(movePatterns))]
+		if c.attacking && time.Since(c.lastAttackTime) < 500*time.Millisecond {
+			mv = [2]int8{0, 0}
+		}
+		if c.rng.Float64() > c.opts.moveSendRate {
+			return true
+		}
+		msg := []byte{protocol.MessageMove, protocol.PackMovement(mv[0], mv[1]), 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(msg[2:], c.inputSequence)
+		if c.verify != nil {
+			c.verify.sentMove(c.inputSequence) // before the write: the ACK may race it
+		}
+		c.inputSequence++
+		return c.send(msg)
+	case ActionDirection:
+		if c.rng.Float64() > sc.DirectionChance*c.opts.dirSendRate {
+			return true
+		}
+		c.facingRight = !c.facingRight
+		dir := int8(-1)
+		if c.facingRight {
+			dir = 1
+		}
+		return c.send([]byte{protocol.MessageDirection, byte(dir)})
+	case ActionAttack:
+		if c.attacking || time.Since(c.lastAttackTime) < sc.AttackCooldown || c.rng.Float64() > sc.AttackChance {
+			return true
+		}
+		c.attacking = true
+		c.lastAttackTime = time.Now()
+		return c.send([]byte{protocol.MessageAttack})
+	case ActionAttackEnd:
+		if !c.attacking || time.Since(c.lastAttackTime) < sc.AttackMinLength {
+			return true
+		}
+		c.attacking = false
+		return c.send([]byte{protocol.MessageAttackEnd})
+	}
+	return true
+}
+
+func (c *client) send(msg []byte) bool {
+	return c.sendRaw(ws.OpBinary, msg)
+}
+
+// leave closes the connection with a normal close handshake.
+func (c *client) leave() {
+	body := ws.NewCloseFrameBody(ws.StatusNormalClosure, "")
+	wsutil.WriteClientMessage(c.conn, ws.OpClose, body)
+	c.selfClosed = true
+}
+
+// readLoop counts server messages until the connection closes. A stalled client
+// stops reading until the run is over.
+func (c *client) readLoop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		if c.stalled.Load() {
+			<-stop
+			return
+		}
+		data, err := wsutil.ReadServerBinary(c.conn)
+		if err != nil {
+			return
+		}
+		c.stats.received.Add(1)
+		c.stats.bytesIn.Add(int64(len(data)))
+		if len(data) <= serverHeaderSize {
+			if c.verify != nil {
+				c.opts.verifier.fail(checkMalformedMessage, "client %d got a %d-byte frame", c.id, len(data))
+			}
+			continue
+		}
+		if c.verify != nil {
+			c.verifyMessage(data[serverHeaderSize:])
+		}
+		switch data[serverHeaderSize] {
+		case protocol.MessageMovementAck:
+			c.stats.movementAcks.Add(1)
+		case protocol.MessageGameState:
+			c.stats.fullStates.Add(1)
+		case protocol.MessageDeltaGameState:
+			c.stats.deltaStates.Add(1)
+		}
+	}
+}
+
+// encodeJoin — JOIN with the capabilities of the browser client (delta updates,
+// no compression, no size limit).
+func encodeJoin() []byte {
+	return []byte{protocol.MessageJoin, protocol.CapDeltaUpdates, 0, 0, 0, 0}
+}
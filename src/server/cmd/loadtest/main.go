@@ -0,0 +1,195 @@
+// loadtest drives the game server with simulated players over real WebSockets.
+// Each client joins, then loops over the scenario in scenario.go (moves, facing
+// changes, attacks). With -export-artillery it instead writes an Artillery
+// config/processor pair for the same scenario and the current wire format.
+// With -chaos a share of the clients also sends hostile input (chaos.go); with
+// -verify the clients check the server's replies and the exit code reports the
+// result (verify.go).
+//
+// Usage (from src/server):
+//
+//	go run ./cmd/loadtest -url ws://localhost:8108/ws -clients 1000 -ramp 60s -duration 2m
+//	go run ./cmd/loadtest -clients 500 -chaos 10 -chaos-kinds malformed,reset
+//	go run ./cmd/loadtest -clients 200 -ramp 5s -duration 30s -verify
+//	go run ./cmd/loadtest -export-artillery ../../utils/testing/artillery -clients 1200 -ramp 2m
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"pixi_game_server/internal/config"
+)
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	gameCfg := config.Load()
+
+	url := flag.String("url", "ws://localhost:8108/ws", "game server WebSocket URL")
+	clients := flag.Int("clients", 100, "number of simulated players")
+	ramp := flag.Duration("ramp", 10*time.Second, "time over which clients connect")
+	duration := flag.Duration("duration", time.Minute, "how long all clients stay connected after the ramp")
+	moveSendRate := flag.Float64("move-send-rate", 1.0, "fraction of MOVE steps actually sent")
+	dirSendRate := flag.Float64("dir-send-rate", 1.0, "fraction of DIRECTION steps actually sent")
+	dialTimeout := flag.Duration("dial-timeout", 10*time.Second, "WebSocket connect timeout")
+	reportEvery := flag.Duration("report", 5*time.Second, "progress report interval")
+	chaosPercent := flag.Float64("chaos", 0, "percentage of clients that misbehave (0-100)")
+	chaosKindsFlag := flag.String("chaos-kinds", "all", "comma-separated misbehaviours: malformed, oversized, wrong-type, reset, stall, duplicate")
+	verify := flag.Bool("verify", false, "check server replies and exit non-zero on protocol violations")
+	verifyChurn := flag.Float64("verify-churn", 10, "with -verify: percentage of clients that leave halfway (PLAYER_LEFT check)")
+	ackTimeout := flag.Duration("ack-timeout", 2*time.Second, "with -verify: a MOVE without MOVEMENT_ACK after this long is missing")
+	leftTimeout := flag.Duration("left-timeout", 3*time.Second, "with -verify: time for PLAYER_LEFT to reach everyone after a disconnect")
+	worldWidth := flag.Int("world-width", int(gameCfg.World.Width), "with -verify: world width (default from gameConfig.json / WORLD_WIDTH)")
+	worldHeight := flag.Int("world-height", int(gameCfg.World.Height), "with -verify: world height (default from gameConfig.json / WORLD_HEIGHT)")
+	exportDir := flag.String("export-artillery", "", "write an Artillery scenario/processor pair to this directory and exit")
+	flag.Parse()
+
+	sc := &defaultScenario
+
+	if *exportDir != "" {
+		load := artilleryLoad{target: *url, clients: *clients, ramp: *ramp, hold: *duration}
+		if err := exportArtillery(*exportDir, sc, load); err != nil {
+			slog.Error("failed to export artillery scenario", "dir", *exportDir, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("artillery scenario written", "dir", *exportDir)
+		return
+	}
+
+	if *clients <= 0 {
+		fmt.Fprintln(os.Stderr, "loadtest: -clients must be positive")
+		os.Exit(2)
+	}
+	if *chaosPercent < 0 || *chaosPercent > 100 || *verifyChurn < 0 || *verifyChurn > 100 {
+		fmt.Fprintln(os.Stderr, "loadtest: -chaos and -verify-churn must be between 0 and 100")
+		os.Exit(2)
+	}
+	chaosKinds, err := parseChaosKinds(*chaosKindsFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadtest:", err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *ramp+*duration)
+	defer cancel()
+
+	opts := &clientOptions{
+		url:          *url,
+		scenario:     sc,
+		moveSendRate: *moveSendRate,
+		dirSendRate:  *dirSendRate,
+		dialTimeout:  *dialTimeout,
+	}
+	if *verify {
+		opts.verifier = newVerifier(uint32(*worldWidth), uint32(*worldHeight), *ackTimeout, *leftTimeout)
+	}
+	st := &stats{}
+	slog.Info("load test starting", "url", *url, "clients", *clients, "ramp", *ramp, "duration", *duration,
+		"chaos_percent", *chaosPercent, "verify", *verify)
+
+	go report(ctx, st, *reportEvery)
+
+	start := time.Now()
+	var end atomic.Int64 // when the run stopped, for the PLAYER_LEFT check
+	context.AfterFunc(ctx, func() { end.Store(time.Now().UnixNano()) })
+
+	var wg sync.WaitGroup
+	interval := *ramp / time.Duration(*clients)
+	all := make([]*client, 0, *clients)
+	hostile, churn := 0, 0
+	for i := 0; i < *clients && ctx.Err() == nil; i++ {
+		c := newClient(i, opts, st)
+		all = append(all, c)
+		// Spread hostile and churning clients evenly over the ramp, kinds round-robin.
+		if want := int(float64(i+1) * *chaosPercent / 100); want > hostile {
+			c.hostile = true
+			c.chaos = chaosKinds[hostile%len(chaosKinds)]
+			hostile++
+		} else if want := int(float64(i+1) * *verifyChurn / 100); *verify && want > churn {
+			c.leaveAt = start.Add(*ramp + time.Duration(rand.Int63n(int64(*duration/2)+1)))
+			churn++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.run(ctx)
+		}()
+		if interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	slog.Info("load test finished",
+		"elapsed", elapsed.Round(time.Millisecond),
+		"connects", st.connects.Load(),
+		"dial_errors", st.dialErrors.Load(),
+		"early_disconnects", st.disconnects.Load(),
+		"sent", st.sent.Load(),
+		"received", st.received.Load(),
+		"received_mb", float64(st.bytesIn.Load())/(1<<20),
+		"movement_acks", st.movementAcks.Load(),
+		"full_states", st.fullStates.Load(),
+		"delta_states", st.deltaStates.Load(),
+	)
+	for k := range st.chaos {
+		cc := &st.chaos[k]
+		if cc.clients.Load() == 0 {
+			continue
+		}
+		slog.Info("chaos outcome",
+			"kind", chaosKind(k),
+			"clients", cc.clients.Load(),
+			"acts", cc.acts.Load(),
+			"server_closed", cc.serverClosed.Load(),
+		)
+	}
+	if st.connects.Load() == 0 {
+		os.Exit(1)
+	}
+	if opts.verifier != nil {
+		opts.verifier.finish(all, time.Unix(0, end.Load()))
+		if !opts.verifier.report() {
+			slog.Error("verification FAILED")
+			os.Exit(1)
+		}
+		slog.Info("verification passed")
+	}
+}
+
+// report logs connection count and message rates every interval.
+func report(ctx context.Context, st *stats, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	var lastSent, lastReceived int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, received := st.sent.Load(), st.received.Load()
+			slog.Info("progress",
+				"connected", st.connected.Load(),
+				"dial_errors", st.dialErrors.Load(),
+				"sent_per_sec", float64(sent-lastSent)/every.Seconds(),
+				"received_per_sec", float64(received-lastReceived)/every.Seconds(),
+			)
+			lastSent, lastReceived = sent, received
+		}
+	}
+}
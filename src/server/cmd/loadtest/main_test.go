@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// TestArtilleryProcessorUpToDate fails when schema.go or the scenario changed without
+// re-exporting: Artillery runs would send a different wire format or load than the
+// Go clients.
+func TestArtilleryProcessorUpToDate(t *testing.T) {
+	const path = "../../../../utils/testing/artillery/" + artilleryProcessorFile
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != renderArtilleryProcessor(&defaultScenario, protocol.Messages) {
+		t.Errorf("%s is stale, run `make artillery-export`", path)
+	}
+}
+
+// TestArtilleryConfigFlow checks that every function the config calls is exported
+// by the processor.
+func TestArtilleryConfigFlow(t *testing.T) {
+	config := renderArtilleryConfig(&defaultScenario, artilleryLoad{
+		target: "ws://localhost:8108/ws", clients: 100, ramp: 10 * time.Second, hold: time.Minute,
+	})
+	processor := renderArtilleryProcessor(&defaultScenario, protocol.Messages)
+	for _, line := range strings.Split(config, "\n") {
+		name, ok := strings.CutPrefix(strings.TrimSpace(line), "- function: ")
+		if !ok {
+			continue
+		}
+		if fn := strings.Trim(name, `"`) + ": function("; !strings.Contains(processor, fn) {
+			t.Errorf("config calls %s, processor does not define it", name)
+		}
+	}
+}
+
+func TestLoopsFor(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want int
+	}{
+		{0, 1},
+		{9 * time.Second, 1},
+		{10 * time.Second, 2},
+		{3 * time.Minute, 23},
+	}
+	for _, tt := range tests {
+		if got := defaultScenario.LoopsFor(tt.d); got != tt.want {
+			t.Errorf("LoopsFor(%s) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParseChaosKinds(t *testing.T) {
+	all, err := parseChaosKinds("all")
+	if err != nil || len(all) != int(numChaosKinds) {
+		t.Fatalf(`parseChaosKinds("all") = %v, %v`, all, err)
+	}
+	kinds, err := parseChaosKinds("reset, stall")
+	if err != nil || len(kinds) != 2 || kinds[0] != chaosReset || kinds[1] != chaosStall {
+		t.Errorf(`parseChaosKinds("reset, stall") = %v, %v`, kinds, err)
+	}
+	if _, err := parseChaosKinds("reset,flood"); err == nil {
+		t.Error("unknown kind accepted")
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	v := newVerifier(2000, 2000, time.Second, time.Second)
+	c := newClient(1, &clientOptions{scenario: &defaultScenario, verifier: v}, &stats{})
+	bp := &protocol.BinaryProtocol{}
+
+	c.verify.sentMove(1)
+	c.verifyMessage(bp.EncodeMovementAck(1001, 10, 10, 1))
+	c.verifyMessage(bp.EncodeMovementAck(1001, 10, 10, 1))       // second ACK
+	c.verifyMessage(bp.EncodeMovementAck(1001, 10, 10, 7))       // never sent
+	c.verifyMessage(bp.EncodeMovementAck(1001, 2500, 10, 1))     // outside the world
+	c.verifyMessage([]byte{protocol.MessageMove, 0, 0, 0, 0, 0}) // client → server type
+	c.verifyMessage([]byte{protocol.MessagePlayerLeft, 1})       // truncated
+	c.verifyMessage((&protocol.BinaryProtocol{OriginX: 1990}).EncodeOrigin())
+	c.verifyMessage(bp.EncodeMovementAck(1001, 20, 10, 1)) // 2010: outside the world from the new origin
+
+	want := map[string]int64{
+		checkDuplicateAck:     3,
+		checkUnexpectedAck:    1,
+		checkOutOfBounds:      2,
+		checkUnknownMessage:   1,
+		checkMalformedMessage: 1,
+	}
+	for check, n := range want {
+		if got := v.failures[check]; got != n {
+			t.Errorf("%s failures = %d, want %d", check, got, n)
+		}
+	}
+	if c.verify.playerID != 1001 {
+		t.Errorf("playerID = %d, want 1001", c.verify.playerID)
+	}
+}
+
+func TestVerifyPlayerLeft(t *testing.T) {
+	v := newVerifier(2000, 2000, time.Second, time.Second)
+	opts := &clientOptions{scenario: &defaultScenario, verifier: v}
+	bp := &protocol.BinaryProtocol{}
+	start := time.Now()
+
+	observer, quiet := newClient(1, opts, &stats{}), newClient(2, opts, &stats{})
+	for _, c := range []*client{observer, quiet} {
+		c.stayed = true
+		c.verifyMessage(bp.EncodeGameState([]types.PlayerState{{ID: 1005, X: 5, Y: 5}}, 1))
+	}
+	observer.verifyMessage(bp.EncodePlayerLeft(1005))
+	v.departed(1005, start)
+	v.departed(1006, start) // nobody saw it
+
+	v.finish([]*client{observer, quiet}, start.Add(5*time.Second))
+	if got := v.failures[checkMissingPlayerLeft]; got != 1 {
+		t.Errorf("missing_player_left failures = %d, want 1", got)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import "time"
+
+// Action — one scripted client action of the scenario loop.
+type Action uint8
+
+const (
+	ActionMove Action = iota
+	ActionDirection
+	ActionAttack
+	ActionAttackEnd
+)
+
+// artilleryFunction returns the processor function that performs the action
+// (see artillery.go).
+func (a Action) artilleryFunction() string {
+	switch a {
+	case ActionMove:
+		return "generateAndSendMovement"
+	case ActionDirection:
+		return "maybeChangeAndSendDirection"
+	case ActionAttack:
+		return "maybeAttackAndSend"
+	default:
+		return "maybeAttackEndAndSend"
+	}
+}
+
+// Step — an action followed by a pause.
+type Step struct {
+	Action Action
+	Think  time.Duration
+}
+
+// Scenario describes how one simulated player behaves. The Go clients run it
+// directly; -export-artillery renders the same flow for Artillery, so both load
+// generators put the same load on the server.
+type Scenario struct {
+	JoinDelay time.Duration // pause between JOIN and the first loop
+	Loop      []Step
+
+	DirectionChance float64       // per DIRECTION step
+	AttackChance    float64       // per ATTACK step, once off cooldown
+	AttackCooldown  time.Duration // between two attacks
+	AttackMinLength time.Duration // ATTACK_END is sent no earlier than this
+}
+
+// defaultScenario — the "Optimized Game Client" flow: 2 moves/s, occasional facing
+// changes, rare attacks. One loop takes 8 s.
+var defaultScenario = Scenario{
+	JoinDelay: time.Second,
+	Loop: []Step{
+		{Action: ActionMove, Think: 500 * time.Millisecond},
+		{Action: ActionDirection, Think: 2 * time.Second},
+		{Action: ActionAttack, Think: 5 * time.Second},
+		{Action: ActionAttackEnd, Think: 500 * time.Millisecond},
+	},
+	DirectionChance: 0.15,
+	AttackChance:    0.05,
+	AttackCooldown:  2 * time.Second,
+	AttackMinLength: 200 * time.Millisecond,
+}
+
+// LoopDuration returns the time one pass over Loop takes.
+func (s *Scenario) LoopDuration() time.Duration {
+	var d time.Duration
+	for _, step := range s.Loop {
+		d += step.Think
+	}
+	return d
+}
+
+// LoopsFor returns how many loops a client needs to stay connected for d.
+func (s *Scenario) LoopsFor(d time.Duration) int {
+	loop := s.LoopDuration()
+	if loop <= 0 {
+		return 1
+	}
+	n := int((d - s.JoinDelay + loop - 1) / loop)
+	return max(n, 1)
+}
+
+// movePatterns — movement vectors picked uniformly by a MOVE step; standing still
+// is as likely as any single direction.
+var movePatterns = [][2]int8{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {-1, -1}, {0, 0},
+}
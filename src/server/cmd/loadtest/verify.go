@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+)
+
+// Verification mode: with -verify every client also checks what the server sends
+// back, and the run ends with a pass/fail summary (exit code 1 on failure):
+//
+//   - every MOVE gets exactly one MOVEMENT_ACK (none missing, duplicated or unsolicited);
+//   - no position in MOVEMENT_ACK, GAME_STATE, DELTA_GAME_STATE or PLAYER_JOINED
+//     lies outside the world;
+//   - only known server → client message types arrive, at least MinSize long;
+//   - a player that disconnects is followed by PLAYER_LEFT on every client that
+//     had seen it.
+//
+// To exercise the last check, -verify-churn makes a share of the clients leave
+// halfway through the run. Hostile (-chaos) clients are exempt from the ACK check.
+
+// Verification checks, as reported in the summary.
+const (
+	checkUnknownMessage    = "unknown_message"
+	checkMalformedMessage  = "malformed_message"
+	checkOutOfBounds       = "out_of_bounds"
+	checkUnexpectedAck     = "unexpected_ack"
+	checkDuplicateAck      = "duplicate_ack"
+	checkMissingAck        = "missing_ack"
+	checkMissingPlayerLeft = "missing_player_left"
+)
+
+var verifyChecks = []string{
+	checkUnknownMessage, checkMalformedMessage, checkOutOfBounds,
+	checkUnexpectedAck, checkDuplicateAck, checkMissingAck, checkMissingPlayerLeft,
+}
+
+// verifier collects failures from all clients.
+type verifier struct {
+	worldWidth  uint32
+	worldHeight uint32
+	ackTimeout  time.Duration // a MOVE without ACK after this long is missing
+	leftTimeout time.Duration // PLAYER_LEFT must arrive this long after a disconnect
+
+	mu         sync.Mutex
+	failures   map[string]int64
+	examples   map[string]string // first failure per check
+	departures []departure
+	checked    map[string]int64 // number of checks performed, for the summary
+}
+
+// departure — a client that left before the end of the run.
+type departure struct {
+	playerID uint32
+	at       time.Time
+}
+
+func newVerifier(worldWidth, worldHeight uint32, ackTimeout, leftTimeout time.Duration) *verifier {
+	return &verifier{
+		worldWidth:  worldWidth,
+		worldHeight: worldHeight,
+		ackTimeout:  ackTimeout,
+		leftTimeout: leftTimeout,
+		failures:    make(map[string]int64),
+		examples:    make(map[string]string),
+		checked:     make(map[string]int64),
+	}
+}
+
+func (v *verifier) fail(check, format string, args ...any) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.failures[check]++
+	if _, ok := v.examples[check]; !ok {
+		v.examples[check] = fmt.Sprintf(format, args...)
+	}
+}
+
+func (v *verifier) count(check string, n int64) {
+	v.mu.Lock()
+	v.checked[check] += n
+	v.mu.Unlock()
+}
+
+func (v *verifier) departed(playerID uint32, at time.Time) {
+	v.mu.Lock()
+	v.departures = append(v.departures, departure{playerID: playerID, at: at})
+	v.mu.Unlock()
+}
+
+// clientVerify — per-client verification state. The write side (sentMove) and the
+// read loop (verifyMessage) run on different goroutines.
+type clientVerify struct {
+	mu       sync.Mutex
+	playerID uint32               // from CONFIG (or MOVEMENT_ACK on older servers), 0 until then
+	posBits  uint8                // CONFIG positionBits: positions are in 1/2^posBits units
+	originX  uint32               // last ORIGIN, world units (large worlds)
+	originY  uint32               // likewise
+	lastSeq  uint32               // highest input sequence sent
+	pending  map[uint32]time.Time // sent, not acked yet
+	seen     map[uint32]struct{}  // player IDs seen in world state
+	left     map[uint32]struct{}  // PLAYER_LEFT received
+}
+
+func newClientVerify() *clientVerify {
+	return &clientVerify{
+		pending: make(map[uint32]time.Time),
+		seen:    make(map[uint32]struct{}),
+		left:    make(map[uint32]struct{}),
+	}
+}
+
+func (cv *clientVerify) sentMove(seq uint32) {
+	cv.mu.Lock()
+	cv.pending[seq] = time.Now()
+	cv.lastSeq = max(cv.lastSeq, seq)
+	cv.mu.Unlock()
+}
+
+// verifyMessage checks one server message (without the sequence header).
+func (c *client) verifyMessage(msg []byte) {
+	v, cv := c.opts.verifier, c.verify
+	schema := protocol.LookupSchema(msg[0])
+	v.count(checkUnknownMessage, 1)
+	v.count(checkMalformedMessage, 1)
+	if schema == nil || schema.Direction != protocol.ServerToClient {
+		v.fail(checkUnknownMessage, "client %d got message type %d", c.id, msg[0])
+		return
+	}
+	if len(msg) < schema.MinSize() {
+		v.fail(checkMalformedMessage, "client %d got %s of %d bytes, want >= %d",
+			c.id, schema.ConstName(), len(msg), schema.MinSize())
+		return
+	}
+
+	switch msg[0] {
+	case protocol.MessageMovementAck:
+		playerID := binary.LittleEndian.Uint32(msg[1:])
+		x, y := binary.LittleEndian.Uint16(msg[5:]), binary.LittleEndian.Uint16(msg[7:])
+		seq := binary.LittleEndian.Uint32(msg[9:])
+		c.verifyPosition("MOVEMENT_ACK", playerID, x, y)
+		cv.mu.Lock()
+		cv.playerID = playerID
+		cv.mu.Unlock()
+		if c.hostile {
+			return
+		}
+		v.count(checkDuplicateAck, 1)
+		v.count(checkUnexpectedAck, 1)
+		cv.mu.Lock()
+		_, pending := cv.pending[seq]
+		delete(cv.pending, seq)
+		lastSeq := cv.lastSeq
+		cv.mu.Unlock()
+		switch {
+		case pending:
+		case seq >= 1 && seq <= lastSeq:
+			v.fail(checkDuplicateAck, "client %d got a second ACK for input %d", c.id, seq)
+		default:
+			v.fail(checkUnexpectedAck, "client %d got an ACK for input %d it never sent", c.id, seq)
+		}
+
+	case protocol.MessageGameState, protocol.MessageDeltaGameState:
+		count := int(binary.LittleEndian.Uint32(msg[schema.Size(0)-4:]))
+		if len(msg) < schema.Size(count) {
+			v.fail(checkMalformedMessage, "client %d got %s with %d players in %d bytes",
+				c.id, schema.ConstName(), count, len(msg))
+			return
+		}
+		for off := schema.Size(0); off < schema.Size(count); off += schema.EntrySize() {
+			c.verifyPlayerEntry(schema.ConstName(), msg[off:])
+		}
+
+	case protocol.MessageConfig:
+		cv.mu.Lock()
+		cv.playerID = binary.LittleEndian.Uint32(msg[1:])
+		if off := fieldOffset(schema, "positionBits"); off > 0 && len(msg) > off {
+			cv.posBits = msg[off]
+		}
+		cv.mu.Unlock()
+
+	case protocol.MessageOrigin:
+		cv.mu.Lock()
+		cv.originX, cv.originY = binary.LittleEndian.Uint32(msg[1:]), binary.LittleEndian.Uint32(msg[5:])
+		cv.mu.Unlock()
+
+	case protocol.MessagePlayerJoined:
+		c.verifyPlayerEntry("PLAYER_JOINED", msg[1:])
+
+	case protocol.MessagePlayerLeft:
+		playerID := binary.LittleEndian.Uint32(msg[1:])
+		cv.mu.Lock()
+		cv.left[playerID] = struct{}{}
+		cv.mu.Unlock()
+	}
+}
+
+// fieldOffset returns the offset of the named field in schema's messages (type byte
+// included), or -1.
+func fieldOffset(schema *protocol.MessageSchema, name string) int {
+	off := 1
+	for _, f := range schema.Fields {
+		if f.Name == name {
+			return off
+		}
+		off += f.Type.Width()
+	}
+	return -1
+}
+
+// verifyPlayerEntry checks one 11-byte player record and remembers the ID.
+func (c *client) verifyPlayerEntry(msgName string, entry []byte) {
+	playerID := binary.LittleEndian.Uint32(entry)
+	c.verifyPosition(msgName, playerID, binary.LittleEndian.Uint16(entry[4:]), binary.LittleEndian.Uint16(entry[6:]))
+	cv := c.verify
+	cv.mu.Lock()
+	cv.seen[playerID] = struct{}{}
+	cv.mu.Unlock()
+}
+
+func (c *client) verifyPosition(msgName string, playerID uint32, wx, wy uint16) {
+	v, cv := c.opts.verifier, c.verify
+	cv.mu.Lock()
+	x, y := cv.originX+uint32(wx>>cv.posBits), cv.originY+uint32(wy>>cv.posBits)
+	cv.mu.Unlock()
+	v.count(checkOutOfBounds, 1)
+	if x > v.worldWidth || y > v.worldHeight {
+		v.fail(checkOutOfBounds, "client %d: %s puts player %d at (%d, %d), world is %dx%d",
+			c.id, msgName, playerID, x, y, v.worldWidth, v.worldHeight)
+	}
+}
+
+// verifyEnd runs the per-client checks that need the whole run: missing ACKs, and
+// registers an early departure for the PLAYER_LEFT check.
+func (c *client) verifyEnd(endedAt time.Time, early bool) {
+	v, cv := c.opts.verifier, c.verify
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	if !c.hostile {
+		v.count(checkMissingAck, int64(cv.lastSeq))
+		for seq, sentAt := range cv.pending {
+			if endedAt.Sub(sentAt) > v.ackTimeout {
+				v.fail(checkMissingAck, "client %d: no ACK for input %d after %s",
+					c.id, seq, endedAt.Sub(sentAt).Round(time.Millisecond))
+			}
+		}
+	}
+	if early && cv.playerID != 0 {
+		v.departed(cv.playerID, endedAt)
+	}
+}
+
+// finish checks PLAYER_LEFT for every departure against the clients that stayed
+// until the end (end = when the run stopped). Call after all clients returned.
+func (v *verifier) finish(clients []*client, end time.Time) {
+	for _, d := range v.departures {
+		if end.Sub(d.at) < v.leftTimeout {
+			continue // too close to the end to expect PLAYER_LEFT everywhere
+		}
+		for _, c := range clients {
+			// Hostile clients may have stopped reading (chaosStall).
+			if c.verify == nil || !c.stayed || c.hostile {
+				continue
+			}
+			if _, ok := c.verify.seen[d.playerID]; !ok {
+				continue
+			}
+			v.count(checkMissingPlayerLeft, 1)
+			if _, ok := c.verify.left[d.playerID]; !ok {
+				v.fail(checkMissingPlayerLeft, "client %d saw player %d but got no PLAYER_LEFT after it disconnected",
+					c.id, d.playerID)
+			}
+		}
+	}
+}
+
+// report logs the summary and returns whether every check passed.
+func (v *verifier) report() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	passed := true
+	for _, check := range verifyChecks {
+		failures := v.failures[check]
+		if failures == 0 {
+			slog.Info("verify PASS", "check", check, "checked", v.checked[check])
+			continue
+		}
+		passed = false
+		slog.Error("verify FAIL", "check", check, "checked", v.checked[check],
+			"failures", failures, "example", v.examples[check])
+	}
+	return passed
+}
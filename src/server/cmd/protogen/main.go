@@ -0,0 +1,386 @@
+// protogen renders the wire-format reference and the TypeScript codec from the
+// declarative message table in internal/protocol (schema.go).
+//
+// Usage (from src/server):
+//
+//	go run ./cmd/protogen -docs ../../docs/protocol.md -ts ../client/network/protocol/generated.ts
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"pixi_game_server/internal/protocol"
+)
+
+const generatedHeader = "Code generated by cmd/protogen from internal/protocol/schema.go. DO NOT EDIT."
+
+func main() {
+	docsPath := flag.String("docs", "", "output path for the markdown wire-format reference")
+	tsPath := flag.String("ts", "", "output path for the TypeScript encoder/decoder module")
+	flag.Parse()
+
+	if *docsPath == "" && *tsPath == "" {
+		fmt.Fprintln(os.Stderr, "protogen: nothing to do, pass -docs and/or -ts")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *docsPath != "" {
+		if err := os.WriteFile(*docsPath, []byte(renderDocs(protocol.Messages)), 0o644); err != nil {
+			slog.Error("failed to write docs", "path", *docsPath, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("protocol reference written", "path", *docsPath)
+	}
+
+	if *tsPath != "" {
+		if err := os.WriteFile(*tsPath, []byte(renderTS(protocol.Messages)), 0o644); err != nil {
+			slog.Error("failed to write typescript module", "path", *tsPath, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("typescript codec written", "path", *tsPath)
+	}
+}
+
+// ── Markdown reference ────────────────────────────────────────────────────────
+
+func renderDocs(msgs []protocol.MessageSchema) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!-- %s -->\n\n", generatedHeader)
+	b.WriteString("# Wire protocol reference\n\n")
+	b.WriteString("Binary WebSocket frames, one message per frame. Byte 0 is the message type; ")
+	b.WriteString("all multi-byte integers are little-endian.\n\n")
+	b.WriteString("Server → client frames carry a 4-byte header before the message: a per-connection ")
+	b.WriteString("u32 outbound sequence, starting at 1. Messages the server had to drop for that ")
+	b.WriteString("connection still consume a number, so a jump in the sequence is a loss ")
+	b.WriteString("(see SEQUENCE_REPORT). Offsets below are relative to the message, after the header.\n\n")
+	b.WriteString("Clients that set the checksum capability in JOIN get a CRC-32C (Castagnoli) of the ")
+	b.WriteString("message after the sequence, [seq u32][crc u32][message], and prefix every message they ")
+	b.WriteString("send after JOIN with one, [crc u32][message]. The checksum covers the message only. ")
+	b.WriteString("A message that fails it is dropped by the receiver: the client counts it as missed ")
+	b.WriteString("(and resyncs) and reports it in SEQUENCE_REPORT.corrupted.\n\n")
+	b.WriteString("| Type | Encoding |\n|---|---|\n")
+	b.WriteString("| `movement` | u8: bits 0-1 = dx+1, bits 2-3 = dy+1 |\n")
+	b.WriteString("| `flags` | u8: bit 7 = facingRight, bit 6 = spawn protection, bits 0-5 = state (0 idle, 1 attacking, 2 moving, 3 stunned, 4 dead) |\n")
+	b.WriteString("| `count` | u32 number of repeated entries that follow |\n")
+	b.WriteString("| `string` | u32 byte length, then that many bytes of UTF-8; always the last field. Senders cut longer text ")
+	b.WriteString("to the field's limit on a character boundary; the server refuses longer text, replaces invalid UTF-8 ")
+	b.WriteString("with U+FFFD and drops control characters |\n\n")
+	b.WriteString("## Sealed messages\n\n")
+	b.WriteString("Clients that connected with a session token, hold its session key (handed over by the login ")
+	b.WriteString("service outside the game connection) and set the encryption capability in JOIN get CIPHER_INIT ")
+	b.WriteString("first. The message types it lists then travel only inside SEALED (server → client) and ")
+	b.WriteString("SEALED_CLIENT (client → server) envelopes, AES-256-GCM encrypted:\n\n")
+	b.WriteString("- key = HMAC-SHA256(sessionKey, \"pixi.cipher\\0\" ‖ salt), salt = the 16 salt bytes of CIPHER_INIT;\n")
+	b.WriteString("- nonce = [direction u8: 0 client → server, 1 server → client][7 zero bytes][counter u32];\n")
+	b.WriteString("- additional data = the envelope header (type, counter, length); the plaintext is the whole inner message.\n\n")
+	b.WriteString("Counters start at 1 per direction and grow by one per sealed message; a receiver drops an ")
+	b.WriteString("envelope whose counter is not above the last one it opened, and the server drops a listed type ")
+	b.WriteString("that arrives unsealed.\n\n")
+	b.WriteString("## Reliable messages\n\n")
+	b.WriteString("The server drops messages for a connection whose queue is full. Clients that set the reliable ")
+	b.WriteString("capability in JOIN get PLAYER_JOINED, PLAYER_LEFT and movement corrections inside RELIABLE ")
+	b.WriteString("envelopes and answer each with RELIABLE_ACK. An unacked envelope is sent again, same id, after ")
+	b.WriteString("the retry delay, which doubles on every retransmission; after the last retry the server gives ")
+	b.WriteString("up. Copies can therefore arrive twice: the client acks every copy and handles an id only once. ")
+	b.WriteString("A correction that a newer MOVEMENT_ACK has replaced is no longer sent again.\n\n")
+
+	b.WriteString("## Handshake\n\n")
+	fmt.Fprintf(&b, "Clients offer the WebSocket subprotocol `%s` (`Sec-WebSocket-Protocol`); the server ", protocol.Subprotocol)
+	fmt.Fprintf(&b, "answers with it. Clients of the previous version, `%s`, are still served: their ", protocol.LegacySubprotocol)
+	b.WriteString("messages are the same except that positions are in whole world units, and CONFIG tells them positionBits 0. ")
+	b.WriteString("A client offering only other subprotocols is closed right after the upgrade ")
+	b.WriteString("with a close code from the table below. After the upgrade the client sends JOIN.\n\n")
+	b.WriteString("Query parameters of the upgrade URL: `token` — session token (when the server requires one); ")
+	b.WriteString("`lang` — the client's language (`ru`, `pt-BR`), which picks the translation of ANNOUNCE texts.\n\n")
+	b.WriteString("Whenever the server drops a connection on purpose it sends a close frame with one of these ")
+	b.WriteString("codes first; the client reconnects on its own only where the table says so.\n\n")
+	b.WriteString("| Close code | Name | Reconnect | Meaning |\n|---|---|---|---|\n")
+	for _, c := range protocol.CloseCodes {
+		reconnect := "no"
+		if c.Reconnect {
+			reconnect = "yes"
+		}
+		fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", c.Code, c.ConstName(), reconnect, c.Doc)
+	}
+	b.WriteString("\n")
+
+	for _, dir := range []protocol.Direction{protocol.ClientToServer, protocol.ServerToClient} {
+		if dir == protocol.ClientToServer {
+			b.WriteString("## Client → Server\n\n")
+		} else {
+			b.WriteString("## Server → Client\n\n")
+		}
+		for i := range msgs {
+			m := &msgs[i]
+			if m.Direction != dir {
+				continue
+			}
+			fmt.Fprintf(&b, "### %d — %s\n\n%s\n\n", m.Type, m.ConstName(), m.Doc)
+			if text, ok := m.Text(); ok {
+				fmt.Fprintf(&b, "Size: %d + %s bytes.\n\n", m.Size(0), text.Name)
+			} else if len(m.Repeated) > 0 {
+				fmt.Fprintf(&b, "Size: %d + %d × %s bytes.\n\n", m.Size(0), m.EntrySize(), m.RepeatedName)
+			} else if m.MinSize() < m.Size(0) {
+				fmt.Fprintf(&b, "Size: %d bytes (%d without the optional fields).\n\n", m.Size(0), m.MinSize())
+			} else {
+				fmt.Fprintf(&b, "Size: %d bytes.\n\n", m.Size(0))
+			}
+			if dir == protocol.ClientToServer && m.MaxSize() != m.Size(0) {
+				fmt.Fprintf(&b, "Largest accepted: %d bytes; longer messages are dropped.\n\n", m.MaxSize())
+			}
+			b.WriteString("| Offset | Field | Type | Notes |\n|---|---|---|---|\n")
+			b.WriteString("| 0 | type | u8 | |\n")
+			offset := 1
+			for _, f := range m.Fields {
+				doc := f.Doc
+				if f.Optional {
+					doc = "optional; " + doc
+				}
+				if f.Type == protocol.FieldString {
+					doc = strings.TrimSuffix(fmt.Sprintf("at most %d bytes; %s", f.MaxLen, doc), "; ")
+				}
+				fmt.Fprintf(&b, "| %d | %s | %s | %s |\n", offset, f.Name, f.Type, doc)
+				offset += f.Type.Width()
+			}
+			if len(m.Repeated) > 0 {
+				fmt.Fprintf(&b, "\nEach entry of `%s` (starting at offset %d):\n\n", m.RepeatedName, offset)
+				b.WriteString("| Offset | Field | Type | Notes |\n|---|---|---|---|\n")
+				entryOffset := 0
+				for _, f := range m.Repeated {
+					fmt.Fprintf(&b, "| +%d | %s | %s | %s |\n", entryOffset, f.Name, f.Type, f.Doc)
+					entryOffset += f.Type.Width()
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// ── TypeScript codec ──────────────────────────────────────────────────────────
+
+func renderTS(msgs []protocol.MessageSchema) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\n\n", generatedHeader)
+
+	b.WriteString("export const WireMessageType = {\n")
+	for i := range msgs {
+		fmt.Fprintf(&b, "    %s: %d,\n", msgs[i].ConstName(), msgs[i].Type)
+	}
+	b.WriteString("} as const;\n\n")
+
+	fmt.Fprintf(&b, "export const WIRE_SUBPROTOCOL = %q;\n\n", protocol.Subprotocol)
+	b.WriteString("export const WireCloseCode = {\n")
+	for _, c := range protocol.CloseCodes {
+		fmt.Fprintf(&b, "    %s: %d,\n", c.ConstName(), c.Code)
+	}
+	b.WriteString("} as const;\n\n")
+	b.WriteString("// Close codes after which the client may reconnect automatically.\n")
+	b.WriteString("export const WIRE_CLOSE_RECONNECT: ReadonlySet<number> = new Set([")
+	first := true
+	for _, c := range protocol.CloseCodes {
+		if !c.Reconnect {
+			continue
+		}
+		if !first {
+			b.WriteString(", ")
+		}
+		first = false
+		fmt.Fprintf(&b, "WireCloseCode.%s", c.ConstName())
+	}
+	b.WriteString("]);\n\n")
+
+	b.WriteString("export interface WireMovement {\n    dx: number;\n    dy: number;\n}\n\n")
+	b.WriteString("function packMovement(m: WireMovement): number {\n")
+	b.WriteString("    return ((m.dx + 1) & 0x03) | (((m.dy + 1) & 0x03) << 2);\n}\n\n")
+	b.WriteString("function unpackMovement(packed: number): WireMovement {\n")
+	b.WriteString("    return { dx: (packed & 0x03) - 1, dy: ((packed >> 2) & 0x03) - 1 };\n}\n\n")
+
+	b.WriteString("const wireTextEncoder = new TextEncoder();\n")
+	b.WriteString("const wireTextDecoder = new TextDecoder();\n\n")
+	b.WriteString("// UTF-8 bytes of text, cut to maxLen bytes on a character boundary.\n")
+	b.WriteString("function encodeText(text: string, maxLen: number): Uint8Array {\n")
+	b.WriteString("    const bytes = wireTextEncoder.encode(text);\n")
+	b.WriteString("    if (bytes.length <= maxLen) return bytes;\n")
+	b.WriteString("    let end = maxLen;\n")
+	b.WriteString("    while (end > 0 && (bytes[end] & 0xc0) === 0x80) end--;\n")
+	b.WriteString("    return bytes.subarray(0, end);\n}\n")
+
+	for i := range msgs {
+		m := &msgs[i]
+		b.WriteString("\n")
+		renderTSInterfaces(&b, m)
+		renderTSEncoder(&b, m)
+		renderTSDecoder(&b, m)
+	}
+	return b.String()
+}
+
+func renderTSInterfaces(b *strings.Builder, m *protocol.MessageSchema) {
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "export interface %sEntry {\n", m.Name)
+		for _, f := range m.Repeated {
+			fmt.Fprintf(b, "    %s: %s;\n", f.Name, tsType(f.Type))
+		}
+		b.WriteString("}\n\n")
+	}
+	fmt.Fprintf(b, "/** %s */\nexport interface %sWire {\n", m.Doc, m.Name)
+	for _, f := range m.Fields {
+		if f.Type == protocol.FieldCount {
+			continue
+		}
+		optional := ""
+		if f.Optional {
+			optional = "?"
+		}
+		fmt.Fprintf(b, "    %s%s: %s;\n", f.Name, optional, tsType(f.Type))
+	}
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "    %s: %sEntry[];\n", m.RepeatedName, m.Name)
+	}
+	b.WriteString("}\n\n")
+}
+
+func renderTSEncoder(b *strings.Builder, m *protocol.MessageSchema) {
+	msgArg := "msg"
+	if len(m.Fields) == 0 {
+		msgArg = "_msg"
+	}
+	fmt.Fprintf(b, "export function encode%s(%s: %sWire): Uint8Array {\n", m.Name, msgArg, m.Name)
+	text, hasText := m.Text()
+	if hasText {
+		fmt.Fprintf(b, "    const textBytes = encodeText(msg.%s, %d);\n", text.Name, text.MaxLen)
+		fmt.Fprintf(b, "    const buffer = new ArrayBuffer(%d + textBytes.length);\n", m.Size(0))
+	} else if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "    const buffer = new ArrayBuffer(%d + msg.%s.length * %d);\n", m.Size(0), m.RepeatedName, m.EntrySize())
+	} else {
+		fmt.Fprintf(b, "    const buffer = new ArrayBuffer(%d);\n", m.Size(0))
+	}
+	b.WriteString("    const view = new DataView(buffer);\n")
+	fmt.Fprintf(b, "    view.setUint8(0, WireMessageType.%s);\n", m.ConstName())
+	offset := 1
+	for _, f := range m.Fields {
+		value := "msg." + f.Name
+		if f.Type == protocol.FieldCount {
+			value = "msg." + m.RepeatedName + ".length"
+		} else if f.Type == protocol.FieldString {
+			value = "textBytes.length"
+		} else if f.Optional {
+			value += " ?? 0"
+		}
+		b.WriteString("    " + tsWrite(f.Type, fmt.Sprint(offset), value) + "\n")
+		offset += f.Type.Width()
+	}
+	if hasText {
+		fmt.Fprintf(b, "    new Uint8Array(buffer).set(textBytes, %d);\n", offset)
+	}
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "    let offset = %d;\n", offset)
+		fmt.Fprintf(b, "    for (const entry of msg.%s) {\n", m.RepeatedName)
+		entryOffset := 0
+		for _, f := range m.Repeated {
+			b.WriteString("        " + tsWrite(f.Type, fmt.Sprintf("offset + %d", entryOffset), "entry."+f.Name) + "\n")
+			entryOffset += f.Type.Width()
+		}
+		fmt.Fprintf(b, "        offset += %d;\n    }\n", m.EntrySize())
+	}
+	b.WriteString("    return new Uint8Array(buffer);\n}\n\n")
+}
+
+func renderTSDecoder(b *strings.Builder, m *protocol.MessageSchema) {
+	fmt.Fprintf(b, "export function decode%s(data: Uint8Array): %sWire | null {\n", m.Name, m.Name)
+	fmt.Fprintf(b, "    if (data.length < %d || data[0] !== WireMessageType.%s) return null;\n", m.MinSize(), m.ConstName())
+	if len(m.Fields) > 0 {
+		b.WriteString("    const view = new DataView(data.buffer, data.byteOffset, data.byteLength);\n")
+	}
+	offset := 1
+	for _, f := range m.Fields {
+		switch f.Type {
+		case protocol.FieldCount:
+			fmt.Fprintf(b, "    const count = %s;\n", tsRead(f.Type, fmt.Sprint(offset)))
+			fmt.Fprintf(b, "    if (data.length < %d + count * %d) return null;\n", m.Size(0), m.EntrySize())
+		case protocol.FieldString:
+			fmt.Fprintf(b, "    const textLength = %s;\n", tsRead(f.Type, fmt.Sprint(offset)))
+			fmt.Fprintf(b, "    if (data.length < %d + textLength) return null;\n", m.Size(0))
+		}
+		offset += f.Type.Width()
+	}
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "    const %s: %sEntry[] = new Array(count);\n", m.RepeatedName, m.Name)
+		fmt.Fprintf(b, "    for (let i = 0, offset = %d; i < count; i++, offset += %d) {\n", offset, m.EntrySize())
+		fmt.Fprintf(b, "        %s[i] = {\n", m.RepeatedName)
+		entryOffset := 0
+		for _, f := range m.Repeated {
+			fmt.Fprintf(b, "            %s: %s,\n", f.Name, tsRead(f.Type, fmt.Sprintf("offset + %d", entryOffset)))
+			entryOffset += f.Type.Width()
+		}
+		b.WriteString("        };\n    }\n")
+	}
+	if len(m.Fields) == 0 {
+		b.WriteString("    return {};\n}\n")
+		return
+	}
+	b.WriteString("    return {\n")
+	offset = 1
+	for _, f := range m.Fields {
+		if f.Optional {
+			fmt.Fprintf(b, "        %s: data.length >= %d ? %s : undefined,\n",
+				f.Name, offset+f.Type.Width(), tsRead(f.Type, fmt.Sprint(offset)))
+		} else if f.Type == protocol.FieldString {
+			end := offset + f.Type.Width()
+			fmt.Fprintf(b, "        %s: wireTextDecoder.decode(data.subarray(%d, %d + textLength)),\n", f.Name, end, end)
+		} else if f.Type != protocol.FieldCount {
+			fmt.Fprintf(b, "        %s: %s,\n", f.Name, tsRead(f.Type, fmt.Sprint(offset)))
+		}
+		offset += f.Type.Width()
+	}
+	if len(m.Repeated) > 0 {
+		fmt.Fprintf(b, "        %s,\n", m.RepeatedName)
+	}
+	b.WriteString("    };\n}\n")
+}
+
+func tsType(t protocol.FieldType) string {
+	switch t {
+	case protocol.FieldMovement:
+		return "WireMovement"
+	case protocol.FieldString:
+		return "string"
+	}
+	return "number"
+}
+
+func tsWrite(t protocol.FieldType, offset, value string) string {
+	switch t {
+	case protocol.FieldI8:
+		return fmt.Sprintf("view.setInt8(%s, %s);", offset, value)
+	case protocol.FieldU16:
+		return fmt.Sprintf("view.setUint16(%s, %s, true);", offset, value)
+	case protocol.FieldU32, protocol.FieldCount, protocol.FieldString:
+		return fmt.Sprintf("view.setUint32(%s, %s, true);", offset, value)
+	case protocol.FieldMovement:
+		return fmt.Sprintf("view.setUint8(%s, packMovement(%s));", offset, value)
+	default:
+		return fmt.Sprintf("view.setUint8(%s, %s);", offset, value)
+	}
+}
+
+func tsRead(t protocol.FieldType, offset string) string {
+	switch t {
+	case protocol.FieldI8:
+		return fmt.Sprintf("view.getInt8(%s)", offset)
+	case protocol.FieldU16:
+		return fmt.Sprintf("view.getUint16(%s, true)", offset)
+	case protocol.FieldU32, protocol.FieldCount, protocol.FieldString:
+		return fmt.Sprintf("view.getUint32(%s, true)", offset)
+	case protocol.FieldMovement:
+		return fmt.Sprintf("unpackMovement(view.getUint8(%s))", offset)
+	default:
+		return fmt.Sprintf("view.getUint8(%s)", offset)
+	}
+}
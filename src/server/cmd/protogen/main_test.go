@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+)
+
+// TestGeneratedUpToDate fails when schema.go changed without `make protogen`: the
+// committed client codec and reference would describe a different wire format.
+func TestGeneratedUpToDate(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"../../../../docs/protocol.md", renderDocs(protocol.Messages)},
+		{"../../../client/network/protocol/generated.ts", renderTS(protocol.Messages)},
+	}
+	for _, tt := range tests {
+		got, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s is stale, run `make protogen`", tt.path)
+		}
+	}
+}
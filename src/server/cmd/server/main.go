@@ -1,62 +1,181 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"io"
 	"log/slog"
 	"os"
 	"runtime"
 	"runtime/debug"
+	"strings"
 
 	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/runtimeopt"
 	"pixi_game_server/internal/server"
+	"pixi_game_server/internal/tenant"
+	"pixi_game_server/internal/worldmap"
 )
 
 func main() {
-	// Init structured JSON logger
+	os.Exit(run(os.Args[1:]))
+}
+
+// run starts the server and returns the process exit code (see service.go). Deferred
+// cleanup such as removing the PID file only runs because main exits after run returns.
+func run(args []string) (code int) {
+	// Init structured JSON logger; LOG_LEVEL and LOG_FILE apply once the config is loaded
 	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})))
 
-	// Optimize Go runtime for 10K connections
-	optimizeRuntime()
+	// ContinueOnError: a bad flag is a config error (78), not the flag package's exit 2.
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	profile := fs.String("profile", os.Getenv("CONFIG_PROFILE"),
+		"config profile layered under env: "+strings.Join(config.Profiles(), ", "))
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitConfig
+	}
+
+	// A panic on the main goroutine still gets logged and cleaned up; panics in other
+	// goroutines crash the process with the runtime's own exit code 2.
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("server panicked", "panic", r, "stack", string(debug.Stack()))
+			code = exitPanic
+		}
+	}()
 
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.LoadProfile(*profile)
+	if err != nil {
+		slog.Error("failed to load configuration", "profile", *profile, "error", err)
+		return exitConfig
+	}
+	if err := cfg.Validate(); err != nil {
+		slog.Error("invalid configuration", "profile", cfg.Profile, "error", err)
+		return exitConfig
+	}
+
+	level, _ := config.ParseLogLevel(cfg.Server.LogLevel) // checked by Validate
+	var out io.Writer = os.Stdout
+	var logs *logFile
+	if cfg.Server.LogFile != "" {
+		l, err := openLogFile(cfg.Server.LogFile)
+		if err != nil {
+			slog.Error("failed to open log file", "path", cfg.Server.LogFile, "error", err)
+			return exitConfig
+		}
+		defer l.Close()
+		logs, out = l, l
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})))
+	slog.Info("effective config", "profile", cfg.Profile, "settings", cfg.Settings)
+
+	if cfg.Server.PIDFile != "" {
+		if err := writePIDFile(cfg.Server.PIDFile, cfg.Server.HandoverSocket != ""); err != nil {
+			slog.Error("failed to write pid file", "path", cfg.Server.PIDFile, "error", err)
+			return exitConfig
+		}
+		defer removePIDFile(cfg.Server.PIDFile)
+	}
+
+	// Optimize Go runtime for 10K connections
+	optimizeRuntime(cfg.Runtime)
 
 	slog.Info("server starting",
 		"port", cfg.Server.Port,
 		"tick_rate_hz", cfg.Game.TickRate,
 		"workers", cfg.Server.Workers,
 		"max_connections", cfg.Net.MaxConnections,
+		"pid", os.Getpid(),
 	)
 
+	// Optional world layout exported from Tiled or generated from WORLD_SEED
+	var worldMap *worldmap.Map
+	if cfg.World.MapFile != "" {
+		m, err := worldmap.Load(cfg.World.MapFile)
+		if err != nil {
+			slog.Error("failed to load world map", "path", cfg.World.MapFile, "error", err)
+			return exitConfig
+		}
+		if m.Width != cfg.World.Width || m.Height != cfg.World.Height {
+			slog.Warn("world map size differs from world size",
+				"map_width", m.Width, "map_height", m.Height,
+				"world_width", cfg.World.Width, "world_height", cfg.World.Height)
+		}
+		slog.Info("world map loaded",
+			"path", cfg.World.MapFile,
+			"spawn_areas", len(m.SpawnAreas),
+			"portals", len(m.Portals))
+		worldMap = m
+	} else if cfg.World.Seed != 0 {
+		m, err := worldmap.Generate(uint32(cfg.World.Seed), cfg.World.Width, cfg.World.Height)
+		if err != nil {
+			slog.Error("failed to generate world map", "seed", cfg.World.Seed, "error", err)
+			return exitConfig
+		}
+		slog.Info("world map generated",
+			"seed", m.Seed,
+			"safe_zones", len(m.SafeZones),
+			"item_spawns", len(m.ItemSpawns))
+		worldMap = m
+	}
+
+	// Other games hosted next to this one (internal/tenant)
+	var tenants []tenant.Tenant
+	var tenantConfigs []*config.Config
+	if cfg.Server.TenantsFile != "" {
+		if tenants, err = tenant.Load(cfg.Server.TenantsFile); err != nil {
+			slog.Error("failed to load tenants", "path", cfg.Server.TenantsFile, "error", err)
+			return exitConfig
+		}
+		for _, t := range tenants {
+			tcfg, err := t.ServerConfig(cfg)
+			if err != nil {
+				slog.Error("invalid tenant config", "tenant", t.Name, "error", err)
+				return exitConfig
+			}
+			tenantConfigs = append(tenantConfigs, tcfg)
+		}
+		slog.Info("tenants loaded", "path", cfg.Server.TenantsFile, "tenants", len(tenants))
+	}
+
 	// Create and start game server
-	gameServer := server.New(cfg)
+	gameServer := server.New(cfg, worldMap)
+	for i, t := range tenants {
+		gameServer.AddTenant(t, tenantConfigs[i], worldMap)
+	}
+	handleSignals(gameServer.Shutdown, logs)
 	if err := gameServer.Start(); err != nil {
 		slog.Error("failed to start server", "error", err)
-		os.Exit(1)
+		return exitCodeFor(err)
+	}
+	if code := gameServer.ExitCode(); code != 0 {
+		slog.Info("server stopped for a scheduled restart", "exit_code", code)
+		return code
 	}
+	slog.Info("server stopped")
+	return exitOK
 }
 
-func optimizeRuntime() {
+// optimizeRuntime sizes GOMAXPROCS and applies the GC settings from config.
+func optimizeRuntime(cfg config.RuntimeConfig) {
 	// Set GOMAXPROCS to CPU count if not set
 	if os.Getenv("GOMAXPROCS") == "" {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
-	// Optimize GC for high throughput
-	if os.Getenv("GOGC") == "" {
-		os.Setenv("GOGC", "400")
+	settings := runtimeopt.Current() // keeps GOMEMLIMIT when MEMORY_LIMIT_MB is unset
+	settings.GCPercent = cfg.GCPercent
+	if cfg.MemoryLimitMB > 0 {
+		settings.MemoryLimit = int64(cfg.MemoryLimitMB) << 20
 	}
+	settings.BallastBytes = int64(cfg.BallastMB) << 20
+	runtimeopt.Apply(settings)
 
-	// GOMEMLIMIT is read automatically by the Go runtime from the env var.
-	// Log the current value so it's visible in structured logs.
-	memLimit := debug.SetMemoryLimit(-1) // -1 = read current without changing
-	if memLimit != 9223372036854775807 { // math.MaxInt64 = no limit
-		slog.Info("memory limit active", "limit_mb", memLimit/1024/1024)
-	}
-
-	slog.Info("runtime optimized",
-		"gomaxprocs", runtime.GOMAXPROCS(0),
-		"gogc", os.Getenv("GOGC"),
-	)
+	slog.Info("runtime optimized", "gomaxprocs", runtime.GOMAXPROCS(0))
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Exit codes, so systemd/supervisor can tell failures apart (sysexits.h where one
+// fits). server.ExitRestart (75) is returned after a scheduled restart.
+const (
+	exitOK          = 0
+	exitError       = 1  // any other runtime error
+	exitPanic       = 2  // recovered panic in main; same code the Go runtime uses
+	exitUnavailable = 69 // EX_UNAVAILABLE: could not bind the listen address
+	exitConfig      = 78 // EX_CONFIG: invalid config or map, PID file held by a live process
+)
+
+// exitCodeFor maps an error returned by Server.Start to an exit code.
+func exitCodeFor(err error) int {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "listen" {
+		return exitUnavailable
+	}
+	return exitError
+}
+
+// writePIDFile writes our PID to path. A file left by a process that is still alive
+// is an error (two servers sharing one PID file) unless handover is set: during a
+// blue/green handover the old process is expected to be running. A stale file is
+// overwritten.
+func writePIDFile(path string, handover bool) error {
+	if data, err := os.ReadFile(path); err == nil && !handover {
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if pid > 0 && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("pid file %s belongs to running process %d", path, pid)
+		}
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// removePIDFile removes path if it still holds our PID (the process that took over
+// the world may have already replaced it).
+func removePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	os.Remove(path)
+}
+
+// processAlive reports whether pid exists. Signal 0 checks without delivering
+// anything; EPERM means the process exists but belongs to someone else.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// logFile is the LOG_FILE writer. Reopen (SIGUSR2) switches to a fresh file after
+// logrotate has moved the old one away.
+type logFile struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+func openLogFile(path string) (*logFile, error) {
+	l := &logFile{path: path}
+	if err := l.Reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Write(p)
+}
+
+// Reopen opens path again and closes the previous file. On error the old file stays
+// in use, so no log lines are lost.
+func (l *logFile) Reopen() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	old := l.f
+	l.f = f
+	l.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.pid")
+	self := strconv.Itoa(os.Getpid())
+
+	// Stale file (no such process) is overwritten.
+	os.WriteFile(path, []byte("2147483646\n"), 0o644)
+	if err := writePIDFile(path, false); err != nil {
+		t.Fatalf("stale pid file: %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != self {
+		t.Fatalf("pid file = %q, want %s", data, self)
+	}
+
+	// A live process (the test's parent) holds the file: refused unless handing over.
+	parent := []byte(strconv.Itoa(os.Getppid()))
+	os.WriteFile(path, parent, 0o644)
+	if err := writePIDFile(path, false); err == nil {
+		t.Fatal("pid file of a running process was overwritten")
+	}
+	removePIDFile(path) // not ours: kept
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("foreign pid file removed: %v", err)
+	}
+	if err := writePIDFile(path, true); err != nil {
+		t.Fatalf("handover: %v", err)
+	}
+	removePIDFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("own pid file not removed: %v", err)
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, bindErr := net.Listen("tcp", ln.Addr().String())
+	if bindErr == nil {
+		t.Fatal("second listen on the same address succeeded")
+	}
+	if got := exitCodeFor(bindErr); got != exitUnavailable {
+		t.Errorf("bind failure: exit code %d, want %d", got, exitUnavailable)
+	}
+	if got := exitCodeFor(os.ErrClosed); got != exitError {
+		t.Errorf("other error: exit code %d, want %d", got, exitError)
+	}
+}
+
+func TestLogFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	l, err := openLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Write([]byte("before\n"))
+
+	// logrotate: move the file away, then SIGUSR2 reopens the original path.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Write([]byte("after\n"))
+
+	if data, _ := os.ReadFile(path + ".1"); string(data) != "before\n" {
+		t.Errorf("rotated file = %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "after\n" {
+		t.Errorf("new file = %q", data)
+	}
+}
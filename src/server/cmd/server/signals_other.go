@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+)
+
+// handleSignals: only interrupt is portable; there are no SIGUSR1/SIGUSR2 here.
+func handleSignals(shutdown func(reason string), logs *logFile) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		sig := <-ch
+		slog.Info("shutdown signal received", "signal", sig.String())
+		signal.Stop(ch)
+		shutdown("server shutting down")
+	}()
+}
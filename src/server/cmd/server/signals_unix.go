@@ -0,0 +1,44 @@
+//go:build unix
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+)
+
+// handleSignals serves supervisor signals until the process exits:
+//
+//	SIGTERM, SIGINT  graceful shutdown (Start returns, exit code 0)
+//	SIGUSR1          dump all goroutine stacks to stderr
+//	SIGUSR2          reopen LOG_FILE after rotation (no-op when logging to stdout)
+func handleSignals(shutdown func(reason string), logs *logFile) {
+	ch := make(chan os.Signal, 4)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range ch {
+			switch sig {
+			case syscall.SIGUSR1:
+				slog.Info("dumping goroutine stacks to stderr")
+				pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+			case syscall.SIGUSR2:
+				if logs == nil {
+					continue
+				}
+				if err := logs.Reopen(); err != nil {
+					slog.Error("failed to reopen log file", "path", logs.path, "error", err)
+					continue
+				}
+				slog.Info("log file reopened", "path", logs.path)
+			default:
+				slog.Info("shutdown signal received", "signal", sig.String())
+				signal.Stop(ch)
+				shutdown("server shutting down")
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,175 @@
+// Package ai evaluates behaviour trees for server-side NPCs (the bots of internal/game).
+//
+// A tree is data: a JSON file lists named behaviours, each a tree of nodes:
+//
+//	{"behaviors": [
+//	  {"name": "guard", "root": {"type": "selector", "children": [
+//	    {"type": "flee", "belowHP": 30, "radius": 250},
+//	    {"type": "cooldown", "ms": 800, "children": [{"type": "attack", "range": 60}]},
+//	    {"type": "aggro", "radius": 300},
+//	    {"type": "patrol", "radius": 150}
+//	  ]}}
+//	]}
+//
+// Composites:
+//
+//	selector   runs its children in order until one does not fail
+//	sequence   runs its children in order until one does not succeed
+//	cooldown   runs its only child at most once per ms; fails while cooling down
+//
+// Leaves:
+//
+//	patrol     walks the points [[x,y],…], or the corners of a square of radius around
+//	           the spot the NPC first thought at; always running
+//	aggro      picks the nearest player within radius and walks at it, running; keeps
+//	           the target until it is further than leash (default 2×radius); fails
+//	           without one
+//	attack     attacks the aggro target when it is within range; fails otherwise
+//	flee       walks away from the nearest player within radius while HP is at or
+//	           below belowHP percent of the maximum; fails otherwise
+//	idle       stands still; succeeds
+//
+// A tree is stateless and shared; what one NPC remembers — its target, the next patrol
+// point, cooldowns — lives in its Memory. Trees act through the Agent the caller
+// implements and never block; patrol and aggro walk with Agent.MoveTo, so an agent that
+// finds paths goes around walls.
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Status — the outcome of a node.
+type Status uint8
+
+const (
+	Failure Status = iota
+	Success
+	Running
+)
+
+func (s Status) String() string {
+	switch s {
+	case Success:
+		return "success"
+	case Running:
+		return "running"
+	default:
+		return "failure"
+	}
+}
+
+// Agent is the NPC a tree drives. Coordinates are world units.
+type Agent interface {
+	Position() (x, y int)
+	Health() (hp, maxHP int)
+	// Nearest returns the nearest player within radius the NPC may target.
+	Nearest(radius int) (id uint32, x, y int, ok bool)
+	// Locate returns where player id is; false when it is gone or cannot be targeted.
+	Locate(id uint32) (x, y int, ok bool)
+	// Move sets the movement direction, each component -1, 0 or 1.
+	Move(vx, vy int8)
+	// MoveTo heads for (x, y), around obstacles when the agent knows the way.
+	MoveTo(x, y int)
+	// Attack turns towards (x, y) and attacks; false when the attack was refused.
+	Attack(x, y int) bool
+}
+
+// Memory — what one NPC remembers between evaluations. The zero value is ready.
+type Memory struct {
+	home         bool
+	homeX, homeY int
+	target       uint32
+	waypoint     int
+	readyAt      []int64 // UnixNano per cooldown node
+}
+
+// Target returns the player the NPC is after; 0 = none.
+func (m *Memory) Target() uint32 { return m.target }
+
+// Tree — one compiled behaviour.
+type Tree struct {
+	Name      string
+	root      node
+	cooldowns int
+}
+
+// Tick evaluates t once for the NPC a with memory m at nowNs (UnixNano).
+func (t *Tree) Tick(a Agent, m *Memory, nowNs int64) Status {
+	if !m.home {
+		m.homeX, m.homeY = a.Position()
+		m.home = true
+	}
+	if len(m.readyAt) < t.cooldowns {
+		m.readyAt = append(m.readyAt, make([]int64, t.cooldowns-len(m.readyAt))...)
+	}
+	return t.root.tick(&env{a: a, m: m, now: nowNs})
+}
+
+type env struct {
+	a   Agent
+	m   *Memory
+	now int64
+}
+
+type node interface {
+	tick(e *env) Status
+}
+
+// Load reads behaviour definitions from a JSON file.
+func Load(path string) ([]*Tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ai: %w", err)
+	}
+	trees, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("ai: %s: %w", path, err)
+	}
+	return trees, nil
+}
+
+// Parse compiles behaviour definitions (see the package comment). Unknown node types
+// and fields are errors, so a typo does not silently change a behaviour.
+func Parse(data []byte) ([]*Tree, error) {
+	var file struct {
+		Behaviors []struct {
+			Name string   `json:"name"`
+			Root *nodeDef `json:"root"`
+		} `json:"behaviors"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, err
+	}
+	if len(file.Behaviors) == 0 {
+		return nil, errors.New("no behaviors defined")
+	}
+	trees := make([]*Tree, 0, len(file.Behaviors))
+	seen := make(map[string]bool, len(file.Behaviors))
+	for i, b := range file.Behaviors {
+		if b.Name == "" {
+			return nil, fmt.Errorf("behaviors[%d]: no name", i)
+		}
+		if seen[b.Name] {
+			return nil, fmt.Errorf("behavior %q defined twice", b.Name)
+		}
+		seen[b.Name] = true
+		if b.Root == nil {
+			return nil, fmt.Errorf("behavior %q: no root", b.Name)
+		}
+		t := &Tree{Name: b.Name}
+		root, err := t.compile(b.Root, b.Name)
+		if err != nil {
+			return nil, err
+		}
+		t.root = root
+		trees = append(trees, t)
+	}
+	return trees, nil
+}
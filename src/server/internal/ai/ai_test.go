@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAgent is an NPC on an empty plane with the players in enemies.
+type fakeAgent struct {
+	x, y      int
+	hp, maxHP int
+	enemies   map[uint32][2]int
+	vx, vy    int8
+	attacks   int
+}
+
+func (a *fakeAgent) Position() (int, int) { return a.x, a.y }
+func (a *fakeAgent) Health() (int, int)   { return a.hp, a.maxHP }
+
+func (a *fakeAgent) Nearest(radius int) (uint32, int, int, bool) {
+	var best uint32
+	bd := radius*radius + 1
+	for id, p := range a.enemies {
+		if d := dist2(a.x, a.y, p[0], p[1]); d < bd {
+			best, bd = id, d
+		}
+	}
+	p := a.enemies[best]
+	return best, p[0], p[1], best != 0
+}
+
+func (a *fakeAgent) Locate(id uint32) (int, int, bool) {
+	p, ok := a.enemies[id]
+	return p[0], p[1], ok
+}
+
+func (a *fakeAgent) Move(vx, vy int8) { a.vx, a.vy = vx, vy }
+
+func (a *fakeAgent) MoveTo(x, y int) { a.Move(Toward(a.x, x), Toward(a.y, y)) }
+
+func (a *fakeAgent) Attack(int, int) bool {
+	a.attacks++
+	return true
+}
+
+const guard = `{"behaviors": [{"name": "guard", "root": {"type": "selector", "children": [
+	{"type": "flee", "belowHP": 30, "radius": 250},
+	{"type": "cooldown", "ms": 800, "children": [{"type": "attack", "range": 60}]},
+	{"type": "aggro", "radius": 300},
+	{"type": "patrol", "radius": 100}
+]}}]}`
+
+func mustParse(t *testing.T, src string) *Tree {
+	t.Helper()
+	trees, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trees[0]
+}
+
+func TestParseErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		`{"behaviors": []}`: "no behaviors",
+		`{"behaviors": [{"name": "a", "root": {"type": "selector"}}]}`:                                                            "selector: 0 children",
+		`{"behaviors": [{"name": "a", "root": {"type": "dance"}}]}`:                                                               "unknown node type",
+		`{"behaviors": [{"name": "a", "root": {"type": "aggro", "radius": 10, "radious": 5}}]}`:                                   "unknown field",
+		`{"behaviors": [{"name": "a", "root": {"type": "flee", "radius": 10, "belowHP": 150}}]}`:                                  "belowHP",
+		`{"behaviors": [{"name": "a", "root": {"type": "patrol"}}]}`:                                                              "radius must be positive",
+		`{"behaviors": [{"name": "a", "root": {"type": "idle"}}, {"name": "a", "root": {"type": "idle"}}]}`:                       "defined twice",
+		`{"behaviors": [{"name": "a", "root": {"type": "cooldown", "ms": 5, "children": [{"type": "idle"}, {"type": "idle"}]}}]}`: "2 children",
+	} {
+		if _, err := Parse([]byte(src)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%s) = %v, want an error with %q", src, err, want)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	tree := mustParse(t, guard)
+	a := &fakeAgent{x: 1000, y: 1000, hp: 100, maxHP: 100, enemies: map[uint32][2]int{}}
+	var m Memory
+	now := time.Now().UnixNano()
+
+	// Nobody around: patrol the corners of the square around home.
+	if st := tree.Tick(a, &m, now); st != Running || a.vx != -1 || a.vy != -1 {
+		t.Fatalf("patrol = %v (%d,%d), want running to the top left corner", st, a.vx, a.vy)
+	}
+	a.x, a.y = 900, 900
+	tree.Tick(a, &m, now)
+	if a.vx != 1 || a.vy != 0 {
+		t.Fatalf("at the first corner moving (%d,%d), want on to the top right", a.vx, a.vy)
+	}
+
+	// A player walks in: chase, then attack once per cooldown.
+	a.enemies[1001] = [2]int{1100, 900}
+	if st := tree.Tick(a, &m, now); st != Running || m.Target() != 1001 || a.vx != 1 {
+		t.Fatalf("aggro = %v, target %d, vx %d; want a chase of 1001", st, m.Target(), a.vx)
+	}
+	a.x = 1060
+	if st := tree.Tick(a, &m, now); st != Success || a.attacks != 1 {
+		t.Fatalf("in range = %v with %d attacks", st, a.attacks)
+	}
+	tree.Tick(a, &m, now+int64(100*time.Millisecond))
+	tree.Tick(a, &m, now+int64(900*time.Millisecond))
+	if a.attacks != 2 {
+		t.Fatalf("%d attacks, want 2 with an 800 ms cooldown", a.attacks)
+	}
+
+	// Hurt: run away and forget the target.
+	a.hp = 20
+	if st := tree.Tick(a, &m, now); st != Running || a.vx != -1 || m.Target() != 0 {
+		t.Fatalf("flee = %v, vx %d, target %d", st, a.vx, m.Target())
+	}
+
+	// The player leaves beyond the leash: back to patrol.
+	a.hp = 100
+	tree.Tick(a, &m, now)
+	a.enemies[1001] = [2]int{5000, 5000}
+	if st := tree.Tick(a, &m, now); st != Running || m.Target() != 0 {
+		t.Fatalf("after the target left = %v, target %d", st, m.Target())
+	}
+}
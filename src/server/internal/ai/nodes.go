@@ -0,0 +1,292 @@
+package ai
+
+import (
+	"fmt"
+	"time"
+)
+
+// nodeDef — one node of a definition file; which fields apply depends on Type.
+type nodeDef struct {
+	Type     string     `json:"type"`
+	Children []*nodeDef `json:"children"`
+	Radius   int        `json:"radius"`
+	Leash    int        `json:"leash"`
+	Range    int        `json:"range"`
+	BelowHP  int        `json:"belowHP"`
+	MS       int        `json:"ms"`
+	Points   [][2]int   `json:"points"`
+	Arrive   int        `json:"arrive"`
+}
+
+// defaultArrive — how close a patrol point counts as reached, world units.
+const defaultArrive = 16
+
+// deadZone — distance along an axis below which an NPC stops moving on it, so it
+// does not jitter around a point it cannot hit exactly.
+const deadZone = 8
+
+func (t *Tree) compile(d *nodeDef, path string) (node, error) {
+	if d == nil {
+		return nil, fmt.Errorf("%s: empty node", path)
+	}
+	path += "/" + d.Type
+	children := func(lo, hi int) ([]node, error) {
+		if len(d.Children) < lo || (hi > 0 && len(d.Children) > hi) {
+			return nil, fmt.Errorf("%s: %d children", path, len(d.Children))
+		}
+		nodes := make([]node, len(d.Children))
+		for i, c := range d.Children {
+			n, err := t.compile(c, path)
+			if err != nil {
+				return nil, err
+			}
+			nodes[i] = n
+		}
+		return nodes, nil
+	}
+	positive := func(name string, v int) error {
+		if v <= 0 {
+			return fmt.Errorf("%s: %s must be positive", path, name)
+		}
+		return nil
+	}
+
+	switch d.Type {
+	case "selector", "sequence":
+		nodes, err := children(1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if d.Type == "selector" {
+			return selector(nodes), nil
+		}
+		return sequence(nodes), nil
+	case "cooldown":
+		if err := positive("ms", d.MS); err != nil {
+			return nil, err
+		}
+		nodes, err := children(1, 1)
+		if err != nil {
+			return nil, err
+		}
+		t.cooldowns++
+		return &cooldown{slot: t.cooldowns - 1, period: time.Duration(d.MS) * time.Millisecond, child: nodes[0]}, nil
+	case "patrol":
+		if len(d.Points) == 0 {
+			if err := positive("radius", d.Radius); err != nil {
+				return nil, fmt.Errorf("%w (or give points)", err)
+			}
+		}
+		arrive := d.Arrive
+		if arrive <= 0 {
+			arrive = defaultArrive
+		}
+		return &patrol{points: d.Points, radius: d.Radius, arrive: arrive}, nil
+	case "aggro":
+		if err := positive("radius", d.Radius); err != nil {
+			return nil, err
+		}
+		leash := d.Leash
+		if leash <= 0 {
+			leash = 2 * d.Radius
+		}
+		return &aggro{radius: d.Radius, leash: leash}, nil
+	case "attack":
+		if err := positive("range", d.Range); err != nil {
+			return nil, err
+		}
+		return &attack{reach: d.Range}, nil
+	case "flee":
+		if err := positive("radius", d.Radius); err != nil {
+			return nil, err
+		}
+		if d.BelowHP <= 0 || d.BelowHP > 100 {
+			return nil, fmt.Errorf("%s: belowHP must be 1-100", path)
+		}
+		return &flee{radius: d.Radius, belowHP: d.BelowHP}, nil
+	case "idle":
+		return idle{}, nil
+	case "":
+		return nil, fmt.Errorf("%s: node without a type", path)
+	default:
+		return nil, fmt.Errorf("%s: unknown node type", path)
+	}
+}
+
+type selector []node
+
+func (s selector) tick(e *env) Status {
+	for _, n := range s {
+		if st := n.tick(e); st != Failure {
+			return st
+		}
+	}
+	return Failure
+}
+
+type sequence []node
+
+func (s sequence) tick(e *env) Status {
+	for _, n := range s {
+		if st := n.tick(e); st != Success {
+			return st
+		}
+	}
+	return Success
+}
+
+// cooldown starts its period when the child succeeds.
+type cooldown struct {
+	slot   int
+	period time.Duration
+	child  node
+}
+
+func (c *cooldown) tick(e *env) Status {
+	if e.now < e.m.readyAt[c.slot] {
+		return Failure
+	}
+	st := c.child.tick(e)
+	if st == Success {
+		e.m.readyAt[c.slot] = e.now + c.period.Nanoseconds()
+	}
+	return st
+}
+
+type patrol struct {
+	points [][2]int
+	radius int
+	arrive int
+}
+
+func (p *patrol) point(m *Memory, i int) (int, int) {
+	if len(p.points) > 0 {
+		pt := p.points[i%len(p.points)]
+		return pt[0], pt[1]
+	}
+	r := p.radius
+	switch i % 4 {
+	case 0:
+		return m.homeX - r, m.homeY - r
+	case 1:
+		return m.homeX + r, m.homeY - r
+	case 2:
+		return m.homeX + r, m.homeY + r
+	default:
+		return m.homeX - r, m.homeY + r
+	}
+}
+
+func (p *patrol) tick(e *env) Status {
+	x, y := e.a.Position()
+	tx, ty := p.point(e.m, e.m.waypoint)
+	if abs(tx-x) <= p.arrive && abs(ty-y) <= p.arrive {
+		e.m.waypoint++
+		tx, ty = p.point(e.m, e.m.waypoint)
+	}
+	e.a.MoveTo(tx, ty)
+	return Running
+}
+
+type aggro struct {
+	radius, leash int
+}
+
+func (g *aggro) tick(e *env) Status {
+	x, y := e.a.Position()
+	tx, ty, ok := 0, 0, false
+	if e.m.target != 0 {
+		tx, ty, ok = e.a.Locate(e.m.target)
+		if !ok || dist2(x, y, tx, ty) > g.leash*g.leash {
+			e.m.target, ok = 0, false
+		}
+	}
+	if !ok {
+		e.m.target, tx, ty, ok = e.a.Nearest(g.radius)
+		if !ok {
+			e.m.target = 0
+			return Failure
+		}
+	}
+	e.a.MoveTo(tx, ty)
+	return Running
+}
+
+// attack hits the target when it is within range in front (half of it up and down),
+// the shape game.Hit uses.
+type attack struct {
+	reach int
+}
+
+func (a *attack) tick(e *env) Status {
+	if e.m.target == 0 {
+		return Failure
+	}
+	tx, ty, ok := e.a.Locate(e.m.target)
+	if !ok {
+		return Failure
+	}
+	x, y := e.a.Position()
+	if abs(tx-x) > a.reach || abs(ty-y) > a.reach/2 {
+		return Failure
+	}
+	if !e.a.Attack(tx, ty) {
+		return Failure
+	}
+	return Success
+}
+
+type flee struct {
+	radius, belowHP int
+}
+
+func (f *flee) tick(e *env) Status {
+	hp, maxHP := e.a.Health()
+	if maxHP <= 0 || hp*100 > f.belowHP*maxHP {
+		return Failure
+	}
+	_, tx, ty, ok := e.a.Nearest(f.radius)
+	if !ok {
+		return Failure
+	}
+	x, y := e.a.Position()
+	vx, vy := -Toward(x, tx), -Toward(y, ty)
+	if vx == 0 && vy == 0 {
+		vx = 1 // on top of the threat: any way out
+	}
+	e.m.target = 0
+	e.a.Move(vx, vy)
+	return Running
+}
+
+type idle struct{}
+
+func (idle) tick(e *env) Status {
+	e.a.Move(0, 0)
+	return Success
+}
+
+// Toward returns the step (-1, 0 or 1) from one coordinate towards another; 0 within
+// a few units of it.
+func Toward(from, to int) int8 {
+	switch d := to - from; {
+	case d > deadZone:
+		return 1
+	case d < -deadZone:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func dist2(x1, y1, x2, y2 int) int {
+	dx, dy := x2-x1, y2-y1
+	return dx*dx + dy*dy
+}
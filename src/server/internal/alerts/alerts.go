@@ -0,0 +1,195 @@
+// Package alerts watches a few health signals — tick duration, backlog, drop rate —
+// and tells registered hooks when one stays past its threshold for a sustained
+// period, so operators hear about degradation before players notice it.
+//
+// A Monitor checks every rule once per interval. A rule whose value has been above
+// its threshold for the whole Sustain period fires: every hook gets the Alert with
+// Firing set. When the value drops back under the threshold the alert resolves and
+// the hooks get it again with Firing cleared. Built-in hooks log (LogHook), POST to a
+// webhook (WebhookHook) and set the game_alert_firing gauge (always on).
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+)
+
+// Alert — состояние правила на момент срабатывания или снятия.
+type Alert struct {
+	Name      string    `json:"name"`
+	Firing    bool      `json:"firing"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"` // when the value first crossed the threshold
+	At        time.Time `json:"at"`    // when the alert fired or resolved
+}
+
+// Hook is told about every alert that fires or resolves. Hooks run on the monitor
+// goroutine, one after another: a slow hook delays the next check.
+type Hook func(Alert)
+
+// Rule — условие тревоги: Value выше Threshold дольше Sustain.
+type Rule struct {
+	Name      string
+	Threshold float64
+	Sustain   time.Duration
+	Value     func() float64 // called once per check from the monitor goroutine
+}
+
+type ruleState struct {
+	Rule
+	above  time.Time // first check above the threshold; zero = below
+	firing bool
+}
+
+// Monitor checks the rules and runs the hooks.
+type Monitor struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	rules []*ruleState
+	hooks []Hook
+}
+
+// NewMonitor checks the rules every interval.
+func NewMonitor(interval time.Duration) *Monitor {
+	return &Monitor{interval: interval}
+}
+
+// AddRule adds a rule. A rule with a non-positive threshold is ignored (disabled).
+func (m *Monitor) AddRule(r Rule) {
+	if r.Threshold <= 0 {
+		return
+	}
+	m.mu.Lock()
+	m.rules = append(m.rules, &ruleState{Rule: r})
+	m.mu.Unlock()
+	metrics.AlertFiring.WithLabelValues(r.Name).Set(0)
+}
+
+// AddHook registers a hook for the following alerts.
+func (m *Monitor) AddHook(h Hook) {
+	m.mu.Lock()
+	m.hooks = append(m.hooks, h)
+	m.mu.Unlock()
+}
+
+// Run checks the rules every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.check(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Firing returns the alerts currently firing.
+func (m *Monitor) Firing() []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Alert
+	for _, r := range m.rules {
+		if r.firing {
+			out = append(out, Alert{Name: r.Name, Firing: true, Threshold: r.Threshold, Since: r.above})
+		}
+	}
+	return out
+}
+
+// check evaluates every rule once and runs the hooks for those that changed state.
+func (m *Monitor) check(now time.Time) {
+	m.mu.Lock()
+	rules, hooks := m.rules, m.hooks
+	m.mu.Unlock()
+
+	for _, r := range rules {
+		v := r.Value()
+		var changed bool
+		m.mu.Lock()
+		switch {
+		case v <= r.Threshold:
+			changed = r.firing
+			r.above, r.firing = time.Time{}, false
+		case r.above.IsZero():
+			r.above = now
+			changed = r.Sustain <= 0
+			r.firing = changed
+		case !r.firing && now.Sub(r.above) >= r.Sustain:
+			r.firing, changed = true, true
+		}
+		alert := Alert{Name: r.Name, Firing: r.firing, Value: v, Threshold: r.Threshold, Since: r.above, At: now}
+		m.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if alert.Firing {
+			metrics.AlertFiring.WithLabelValues(r.Name).Set(1)
+			metrics.AlertsFired.WithLabelValues(r.Name).Inc()
+		} else {
+			metrics.AlertFiring.WithLabelValues(r.Name).Set(0)
+		}
+		for _, h := range hooks {
+			h(alert)
+		}
+	}
+}
+
+// LogHook logs fired alerts as warnings and resolved ones as info.
+func LogHook(a Alert) {
+	if a.Firing {
+		slog.Warn("alert firing", "alert", a.Name, "value", a.Value, "threshold", a.Threshold,
+			"for_s", a.At.Sub(a.Since).Seconds())
+		return
+	}
+	slog.Info("alert resolved", "alert", a.Name, "value", a.Value, "threshold", a.Threshold)
+}
+
+// WebhookHook POSTs every alert to url as JSON. The POST runs in its own goroutine so
+// a slow endpoint never delays the checks; it is abandoned after timeout or when ctx
+// is done, and failures are logged.
+func WebhookHook(ctx context.Context, url string, timeout time.Duration) Hook {
+	return func(a Alert) {
+		body, err := json.Marshal(a)
+		if err != nil {
+			return
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			if err := post(ctx, url, body); err != nil {
+				slog.Warn("alert webhook failed", "url", config.RedactURL(url), "alert", a.Name, "error", config.RedactURLError(err))
+			}
+		}()
+	}
+}
+
+func post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMonitorSustain(t *testing.T) {
+	value := 0.0
+	m := NewMonitor(time.Second)
+	m.AddRule(Rule{Name: "slow", Threshold: 10, Sustain: 3 * time.Second, Value: func() float64 { return value }})
+	m.AddRule(Rule{Name: "off", Threshold: 0, Value: func() float64 { t.Fatal("disabled rule evaluated"); return 0 }})
+	var got []Alert
+	m.AddHook(func(a Alert) { got = append(got, a) })
+
+	t0 := time.Unix(1000, 0)
+	for i, v := range []float64{5, 20, 20, 5, 20, 20, 20, 20, 20, 3} {
+		value = v
+		m.check(t0.Add(time.Duration(i) * time.Second))
+	}
+
+	// The first spike lasts two checks (under the 3 s sustain); the second fires
+	// 3 s after it started (check 7) and resolves at check 9.
+	if len(got) != 2 {
+		t.Fatalf("hook calls = %+v, want fire and resolve", got)
+	}
+	if a := got[0]; !a.Firing || a.Name != "slow" || !a.Since.Equal(t0.Add(4*time.Second)) || !a.At.Equal(t0.Add(7*time.Second)) {
+		t.Errorf("fired alert = %+v", a)
+	}
+	if a := got[1]; a.Firing || a.Value != 3 {
+		t.Errorf("resolved alert = %+v", a)
+	}
+	if f := m.Firing(); len(f) != 0 {
+		t.Errorf("firing after resolve: %+v", f)
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	posted := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		posted <- a
+	}))
+	defer srv.Close()
+
+	WebhookHook(t.Context(), srv.URL, time.Second)(Alert{Name: "drop_rate", Firing: true, Value: 250, Threshold: 100})
+	select {
+	case a := <-posted:
+		if a.Name != "drop_rate" || !a.Firing || a.Value != 250 {
+			t.Errorf("posted %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
@@ -0,0 +1,176 @@
+// Package assets serves the built client (Vite's dist/) over HTTP: client-side routes
+// fall back to index.html, content-hashed bundles are cached forever while everything
+// else is revalidated by a content ETag, and pre-compressed .br/.gz siblings are sent
+// to clients that accept them. The files come from a directory or, in a binary built
+// with -tags embedassets, from the binary itself (Embedded).
+package assets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache-Control values.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache" // may be stored, but is checked with If-None-Match every time
+)
+
+// hashedAsset matches Vite's build output, assets/<name>-<hash>.<ext>: the name changes
+// whenever the content does, so the file never needs revalidation.
+var hashedAsset = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// encodings are the pre-compressed variants in order of preference.
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type handler struct {
+	fsys  fs.FS
+	etags sync.Map // etagKey → quoted ETag
+}
+
+type etagKey struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// Handler serves the files of fsys; index.html is the SPA entry point.
+func Handler(fsys fs.FS) http.Handler {
+	return &handler{fsys: fsys}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err == nil && info.IsDir() {
+		name = path.Join(name, "index.html")
+		info, err = fs.Stat(h.fsys, name)
+	}
+	if err != nil {
+		// A missing file stays 404 (a stale bundle must not get HTML back); anything
+		// without an extension is a client-side route.
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name = "index.html"
+		if info, err = fs.Stat(h.fsys, name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	h.serveFile(w, r, name, info)
+}
+
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	header := w.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if hashedAsset.MatchString(name) {
+		header.Set("Cache-Control", cacheImmutable)
+	} else {
+		header.Set("Cache-Control", cacheRevalidate)
+	}
+	etag, err := h.etag(name, info)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	serve, serveInfo := name, info
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		header.Set("Content-Type", ctype)
+	}
+	for _, enc := range encodings {
+		if !acceptsEncoding(r, enc.name) {
+			continue
+		}
+		if ci, err := fs.Stat(h.fsys, name+enc.ext); err == nil && !ci.IsDir() {
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/octet-stream") // no sniffing compressed bytes
+			}
+			header.Set("Content-Encoding", enc.name)
+			serve, serveInfo = name+enc.ext, ci
+			etag = strings.TrimSuffix(etag, `"`) + "-" + enc.name + `"`
+			break
+		}
+	}
+	header.Set("ETag", etag)
+
+	f, err := h.fsys.Open(serve)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, name, serveInfo.ModTime(), content)
+}
+
+// etag returns the content hash of name, computed once per file version.
+func (h *handler) etag(name string, info fs.FileInfo) (string, error) {
+	key := etagKey{name, info.Size(), info.ModTime()}
+	if v, ok := h.etags.Load(key); ok {
+		return v.(string), nil
+	}
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(sum.Sum(nil)[:12]) + `"`
+	h.etags.Store(key, etag)
+	return etag, nil
+}
+
+// acceptsEncoding reports whether Accept-Encoding lists enc without q=0.
+func acceptsEncoding(r *http.Request, enc string) bool {
+	for _, field := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(field, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(coding), enc) {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				weight, err := strconv.ParseFloat(q, 64)
+				return err == nil && weight > 0
+			}
+			return true
+		}
+	}
+	return false
+}
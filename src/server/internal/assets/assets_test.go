@@ -0,0 +1,98 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":                  {Data: []byte("<html>game</html>")},
+		"favicon.ico":                 {Data: []byte("icon")},
+		"assets/index-BxY12_aZ.js":    {Data: []byte("console.log(1)")},
+		"assets/index-BxY12_aZ.js.br": {Data: []byte("brotli")},
+		"assets/index-BxY12_aZ.js.gz": {Data: []byte("gzip")},
+	}
+}
+
+func get(t *testing.T, h http.Handler, target string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestSPAFallback(t *testing.T) {
+	h := Handler(testFS())
+	for _, tc := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "<html>game</html>"},
+		{"/lobby/42", http.StatusOK, "<html>game</html>"}, // client-side route
+		{"/favicon.ico", http.StatusOK, "icon"},
+		{"/assets/index-OLDHASH1.js", http.StatusNotFound, ""},    // stale bundle: no HTML
+		{"/../../etc/passwd", http.StatusOK, "<html>game</html>"}, // cleaned, stays inside fsys
+	} {
+		w := get(t, h, tc.path, nil)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.path, w.Code, tc.status)
+			continue
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Errorf("%s: body %q, want %q", tc.path, w.Body.String(), tc.body)
+		}
+	}
+}
+
+func TestCacheHeaders(t *testing.T) {
+	h := Handler(testFS())
+
+	index := get(t, h, "/", nil)
+	if cc := index.Header().Get("Cache-Control"); cc != cacheRevalidate {
+		t.Errorf("index.html Cache-Control = %q, want %q", cc, cacheRevalidate)
+	}
+	etag := index.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("index.html has no ETag")
+	}
+	if w := get(t, h, "/", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("revalidation with the ETag: status %d, want 304", w.Code)
+	}
+
+	bundle := get(t, h, "/assets/index-BxY12_aZ.js", nil)
+	if cc := bundle.Header().Get("Cache-Control"); cc != cacheImmutable {
+		t.Errorf("hashed bundle Cache-Control = %q, want %q", cc, cacheImmutable)
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+	h := Handler(testFS())
+	const bundle = "/assets/index-BxY12_aZ.js"
+	for _, tc := range []struct {
+		accept, encoding, body string
+	}{
+		{"", "", "console.log(1)"},
+		{"gzip, deflate", "gzip", "gzip"},
+		{"gzip, br", "br", "brotli"},
+		{"br;q=0, gzip", "gzip", "gzip"},
+	} {
+		w := get(t, h, bundle, map[string]string{"Accept-Encoding": tc.accept})
+		if got := w.Header().Get("Content-Encoding"); got != tc.encoding {
+			t.Errorf("Accept-Encoding %q: Content-Encoding %q, want %q", tc.accept, got, tc.encoding)
+		}
+		if w.Body.String() != tc.body {
+			t.Errorf("Accept-Encoding %q: body %q, want %q", tc.accept, w.Body.String(), tc.body)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+			t.Errorf("Accept-Encoding %q: Content-Type %q", tc.accept, ct)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build embedassets
+
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is the client build, copied here by `make build-server-embedded`.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Embedded returns the client compiled into the binary.
+func Embedded() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err) // "dist" is a valid path; fs.Sub cannot fail here
+	}
+	return sub
+}
@@ -0,0 +1,11 @@
+//go:build !embedassets
+
+package assets
+
+import "io/fs"
+
+// Embedded returns nil: this binary was built without -tags embedassets and serves
+// STATIC_DIR.
+func Embedded() fs.FS {
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package auth verifies the session tokens players connect with. Tokens are minted
+// by the login service, which shares AUTH_SECRET with the game server:
+//
+//	<account>.<expires unix seconds>[.<flags>].<base64url HMAC-SHA256(secret, payload)>
+//
+// where payload is everything before the last dot. Flags are comma-separated words
+// the login service vouches for; "vip" puts the account ahead in the login queue.
+// Unknown flags are ignored, so the login service can add flags before servers know
+// them. The game server only checks the signature and expiry; it keeps no account
+// database. The account ID is what identifies a player across connections
+// (duplicate sessions, per-account limits).
+//
+// Along with a token the login service may hand the client its SessionKey, through a
+// channel the game traffic does not take (the URL fragment of the game page is never
+// sent over HTTP). Both ends derive the keys of sealed messages from it (see
+// protocol/sealed.go), so an edge that terminates TLS and sees the token cannot read them.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxAccountLen — upper bound on account ID length.
+const MaxAccountLen = 64
+
+// FlagVIP — token flag of accounts that skip ahead in the login queue.
+const FlagVIP = "vip"
+
+var (
+	ErrMalformed = errors.New("auth: malformed token")
+	ErrSignature = errors.New("auth: bad token signature")
+	ErrExpired   = errors.New("auth: token expired")
+)
+
+// Claims — what a verified token says about its holder.
+type Claims struct {
+	Account string
+	VIP     bool // FlagVIP
+}
+
+// Sign returns a token for account valid until expires. account must be 1..MaxAccountLen
+// characters of [A-Za-z0-9_-].
+func Sign(secret []byte, account string, expires time.Time) (string, error) {
+	return SignClaims(secret, Claims{Account: account}, expires)
+}
+
+// SignClaims returns a token for c valid until expires; flags are added for the
+// claims that are set.
+func SignClaims(secret []byte, c Claims, expires time.Time) (string, error) {
+	if !ValidAccount(c.Account) {
+		return "", ErrMalformed
+	}
+	payload := c.Account + "." + strconv.FormatInt(expires.Unix(), 10)
+	if c.VIP {
+		payload += "." + FlagVIP
+	}
+	return payload + "." + signature(secret, payload), nil
+}
+
+// Verify checks token against secret at now and returns its account ID.
+func Verify(secret []byte, token string, now time.Time) (string, error) {
+	c, err := VerifyClaims(secret, token, now)
+	return c.Account, err
+}
+
+// VerifyClaims checks token against secret at now and returns its claims.
+func VerifyClaims(secret []byte, token string, now time.Time) (Claims, error) {
+	dot := strings.LastIndexByte(token, '.')
+	if dot < 0 {
+		return Claims{}, ErrMalformed
+	}
+	payload, sig := token[:dot], token[dot+1:]
+	account, rest, ok := strings.Cut(payload, ".")
+	if !ok || !ValidAccount(account) {
+		return Claims{}, ErrMalformed
+	}
+	expiresStr, flags, _ := strings.Cut(rest, ".")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return Claims{}, ErrMalformed
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, payload))) {
+		return Claims{}, ErrSignature
+	}
+	if now.Unix() >= expires {
+		return Claims{}, ErrExpired
+	}
+	c := Claims{Account: account}
+	for flag := range strings.SplitSeq(flags, ",") {
+		if flag == FlagVIP {
+			c.VIP = true
+		}
+	}
+	return c, nil
+}
+
+// SessionKey returns the 32-byte key sealed messages of token's connections are derived
+// from. Only holders of secret can compute it; call it for verified tokens only.
+func SessionKey(secret []byte, token string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("pixi.session-key\x00"))
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+func signature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidAccount reports whether account is 1..MaxAccountLen bytes of [A-Za-z0-9_-].
+func ValidAccount(account string) bool {
+	if len(account) == 0 || len(account) > MaxAccountLen {
+		return false
+	}
+	for i := 0; i < len(account); i++ {
+		c := account[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Unix(1_800_000_000, 0)
+	token, err := Sign(secret, "player_42", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if account, err := Verify(secret, token, now); err != nil || account != "player_42" {
+		t.Fatalf("Verify = (%q, %v), want player_42", account, err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		want  error
+	}{
+		{"wrong secret", mustSign(t, []byte("other"), "player_42", now.Add(time.Hour)), now, ErrSignature},
+		{"expired", token, now.Add(time.Hour), ErrExpired},
+		{"forged account", "admin" + token[len("player_42"):], now, ErrSignature},
+		{"no signature", "player_42.1800003600", now, ErrMalformed},
+		{"bad account", "a b.1800003600.sig", now, ErrMalformed},
+		{"empty", "", now, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(secret, tt.token, tt.now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Unix(1_800_000_000, 0)
+	token, err := SignClaims(secret, Claims{Account: "player_42", VIP: true}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err := VerifyClaims(secret, token, now); err != nil || c != (Claims{Account: "player_42", VIP: true}) {
+		t.Fatalf("VerifyClaims = (%+v, %v), want a VIP player_42", c, err)
+	}
+	if account, err := Verify(secret, token, now); err != nil || account != "player_42" {
+		t.Errorf("Verify of a token with flags = (%q, %v)", account, err)
+	}
+
+	// Flags are signed, and ones the server does not know are ignored.
+	plain := mustSign(t, secret, "player_42", now.Add(time.Hour))
+	dot := strings.LastIndexByte(plain, '.')
+	if _, err := VerifyClaims(secret, plain[:dot]+".vip"+plain[dot:], now); !errors.Is(err, ErrSignature) {
+		t.Errorf("added flag: error = %v, want ErrSignature", err)
+	}
+	payload := "player_42.1800003600.beta,vip"
+	if c, err := VerifyClaims(secret, payload+"."+signature(secret, payload), now); err != nil || !c.VIP {
+		t.Errorf("unknown flag next to vip: (%+v, %v)", c, err)
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	key := SessionKey([]byte("s3cret"), "player_42.1800003600.sig")
+	if len(key) != 32 {
+		t.Fatalf("len = %d, want 32", len(key))
+	}
+	if string(key) == string(SessionKey([]byte("other"), "player_42.1800003600.sig")) ||
+		string(key) == string(SessionKey([]byte("s3cret"), "player_42.1800007200.sig")) {
+		t.Error("session key does not depend on both secret and token")
+	}
+}
+
+func mustSign(t *testing.T, secret []byte, account string, expires time.Time) string {
+	t.Helper()
+	token, err := Sign(secret, account, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
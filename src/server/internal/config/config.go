@@ -1,24 +1,160 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server ServerConfig
-	Game   GameConfig
-	World  WorldConfig
-	Net    NetworkConfig
+	Server  ServerConfig
+	Game    GameConfig
+	World   WorldConfig
+	Net     NetworkConfig
+	Runtime RuntimeConfig
+
+	Profile  string    // --profile the config was loaded with; empty = none
+	Settings []Setting // every value read from env/profile/defaults, secrets redacted
 }
 
+// Duplicate session policies (ServerConfig.DuplicateSessionPolicy).
+const (
+	SessionTakeover = "takeover" // the new connection takes over the player, the old one is closed
+	SessionReject   = "reject"   // the new connection is closed, the old one keeps playing
+)
+
 type ServerConfig struct {
-	Port      int
-	Host      string
-	Workers   int
-	StaticDir string
+	Port       int
+	Host       string
+	Workers    int
+	StaticDir  string
+	AdminToken string // bearer token for /admin/*; empty = admin API disabled
+	Roles      string // console roles by account: "alice=admin,bob=moderator"; others are players
+
+	// Client build compiled into the binary (-tags embedassets, see internal/assets)
+	StaticEmbedded bool // serve it instead of StaticDir; ignored by binaries built without it
+
+	// Service integration (see cmd/server)
+	PIDFile  string // written at startup, removed on a clean exit; empty = none
+	LogFile  string // JSON log destination, reopened on SIGUSR2; empty = stdout
+	LogLevel string // debug, info, warn or error
+
+	// Origin check and TLS (see server/origin.go)
+	AllowedOrigins string // comma-separated browser origins allowed on /ws; "*" = any, "self" = same host
+	TLSCertFile    string // serve HTTPS/WSS with this certificate; empty = plain HTTP
+	TLSKeyFile     string
+	RequireTLS     bool          // refuse to start without TLS_CERT_FILE/TLS_KEY_FILE
+	HSTSMaxAge     time.Duration // Strict-Transport-Security on TLS listeners; 0 = not sent
+
+	// Separate listeners (see server/listeners.go): "host:port", empty = served on
+	// HOST:PORT with the game. Each has its own TLS certificate; empty = plain HTTP.
+	AdminAddr         string // /admin, /metrics, /debug, /world; e.g. "127.0.0.1:9090"
+	AdminTLSCertFile  string
+	AdminTLSKeyFile   string
+	StaticAddr        string // the client build
+	StaticTLSCertFile string
+	StaticTLSKeyFile  string
+
+	// Accept scaling (see server/reuseport.go): the game listener bound this many times
+	// with SO_REUSEPORT, one accept loop each, so the kernel spreads new connections
+	// across them; 0 = one plain socket. Linux only; elsewhere one socket is bound.
+	ReusePortListeners int
+
+	// Player auth (internal/auth): session tokens minted by the login service
+	AuthSecret             string // HMAC key of session tokens; empty = auth disabled, anonymous players only
+	AuthRequired           bool   // reject connections without a valid token
+	DuplicateSessionPolicy string // SessionTakeover or SessionReject: an account connecting a second time
+
+	// Sealed messages (server/sealed.go): message types encrypted on top of TLS for
+	// clients that hold the session key, e.g. when TLS terminates at an untrusted edge
+	SealedMessages string // comma-separated message names, e.g. "config,session_takeover"; empty = none
+
+	// Moderation (/admin/bans, /admin/mutes, /admin/audit; see internal/moderation)
+	ModerationLog string // append-only audit log the bans and mutes are rebuilt from; empty = in memory only
+
+	// Maintenance mode (/admin/maintenance)
+	MaintenanceSnapshotPath string        // world snapshot written on pause; empty = no snapshot
+	MaintenanceRetryAfter   time.Duration // default Retry-After for rejected connections
+
+	// Scheduled restarts and maintenance windows (see server/scheduled.go)
+	RestartSchedule     string        // cron of restarts: warn, pause, snapshot, exit with server.ExitRestart; empty = none
+	MaintenanceSchedule string        // cron of maintenance windows; empty = none
+	MaintenanceWindow   time.Duration // how long a scheduled window keeps the world paused
+	ScheduleWarnings    string        // lead times of the warnings, e.g. "10m,5m,1m"; the last one is the maintenance countdown
+
+	// Blue/green handover between processes (see server/handover.go)
+	HandoverSocket   string // unix socket path; empty = handover disabled
+	HandoverListener bool   // inherit the listening socket FD from the old process
+
+	// Server browser (/info and directory registration)
+	Name              string
+	Region            string
+	PublicAddress     string        // host:port advertised to clients; empty = Host:Port
+	DirectoryURL      string        // directory endpoint to POST /info to; empty = no registration
+	DirectoryInterval time.Duration // registration refresh period
+	DirectoryTimeout  time.Duration // deadline of one registration POST
+
+	// Session summaries on disconnect (see server/sessionstats.go)
+	SessionWebhookURL     string        // endpoint each summary is POSTed to as JSON; empty = log and metrics only
+	SessionWebhookTimeout time.Duration // deadline of one summary POST
+
+	// Leaderboard (/leaderboard and LEADERBOARD; see internal/leaderboard)
+	LeaderboardRedisAddr     string        // Redis host:port holding the scores; empty = in memory, lost on restart
+	LeaderboardRedisPassword string        // AUTH password; empty = none
+	LeaderboardRedisPrefix   string        // key prefix of the sorted sets, one per metric
+	LeaderboardFlush         time.Duration // how often score changes are written to the store
+	LeaderboardTimeout       time.Duration // deadline of one store call: a flush, a query
+
+	// Friends (FRIEND, FRIEND_UPDATE, /admin/friends; see internal/social)
+	FriendsLog string // append-only log the friend lists are rebuilt from; empty = in memory only
+	FriendsMax int    // friends and pending requests per account; 0 = no limit
+
+	// Inventories and trades (INVENTORY, TRADE, /admin/inventory; see internal/inventory)
+	InventoryLog      string        // append-only log the inventories are rebuilt from; empty = in memory only
+	InventoryMaxSlots int           // different items per inventory
+	InventoryMaxStack int           // count of one item per inventory
+	TradeRequestTTL   time.Duration // a trade request must be accepted within this
+
+	// Player records of signed-in players (see internal/persist)
+	PlayerRecordsLog  string        // append-only log of the records; empty = in memory only
+	PersistFlush      time.Duration // write-behind flush period
+	PersistBatchSize  int           // records per write; this many pending flush early
+	PersistRetries    int           // failed writes before a record is dropped and logged
+	PersistTimeout    time.Duration // deadline of one batch write
+	PersistCheckpoint time.Duration // how often online players are queued; they are also queued on leave
+
+	// Tenants: API keys of other games hosted by this process (see internal/tenant)
+	TenantsFile   string        // JSON list of tenants; empty = this server's own world only
+	TenantDataDir string        // the logs of tenant T go to TenantDataDir/T
+	UsageReport   time.Duration // period of the per-tenant usage report and metrics; 0 = off
+
+	// Message of the day (see server/announce.go)
+	MOTD     string // default text sent after JOIN; empty = none
+	MOTDFile string // JSON {"<lang>": "<text>"} with translations; empty = MOTD only
+
+	// Live world view for dashboards and minimap pages (/world, /world/stream)
+	WorldView           bool          // serve the endpoints; they expose every player position
+	WorldViewInterval   time.Duration // /world/stream update period
+	WorldViewMaxStreams int           // concurrent /world/stream clients; 0 = unlimited
+
+	// In-memory metrics history for graphs without a TSDB (/metrics/history)
+	MetricsHistoryWindow   time.Duration // how far back it reaches; 0 = disabled
+	MetricsHistoryInterval time.Duration // sampling period
+
+	// Alerting (see internal/alerts): a threshold exceeded for AlertSustain fires the
+	// hooks (log, webhook, game_alert_firing gauge); a zero threshold disables its rule
+	AlertTickMs         float64       // mean tick duration in ms
+	AlertReadBacklog    int           // readable connections waiting for a read worker
+	AlertDropsPerSec    float64       // outbound messages dropped per second (full send queues)
+	AlertSustain        time.Duration // how long a threshold must be exceeded before firing
+	AlertWebhookURL     string        // alerts are POSTed here as JSON; empty = log and gauge only
+	AlertWebhookTimeout time.Duration // deadline of one alert POST
 }
 
 type GameConfig struct {
@@ -26,20 +162,142 @@ type GameConfig struct {
 	SyncInterval       time.Duration
 	BatchInterval      time.Duration
 	PlayerSpeedPerTick int
+	PlayerAcceleration int // units/s² a player speeds up by towards the input speed; 0 = at once
+	PlayerFriction     int // units/s² a player slows down by without input; 0 = stops at once
 	AttackDuration     time.Duration
+	InputTimeoutTicks  int                // ticks without MOVE before a moving player is stopped; 0 = disabled
+	SpawnProtection    time.Duration      // invulnerability after spawn; 0 = disabled
+	MaxHP              int                // health of a (re)spawned player
+	AttackDamage       int                // HP an attack takes from each player it hits; 0 = attacks never hit
+	AttackRange        int                // reach of an attack in front of the attacker, world units
+	AttackArc          int                // width of an aimed attack's sector, degrees
+	Knockback          int                // how far a hit pushes the victim away, world units (at most 127)
+	RespawnDelay       time.Duration      // a killed player stays dead this long
+	RegionSharding     bool               // tick workers own horizontal bands of the spatial grid
+	TickWorkers        int                // tick job system goroutines; 0 = GOMAXPROCS
+	TickChunkSize      int                // entity rows per tick job
+	MaxCatchUpTicks    int                // overdue ticks run back to back after a slow one; more are dropped
+	WorldEvents        []WorldEventConfig // scheduled global events; empty = none
+	Emotes             []EmoteConfig      // EMOTE catalog; empty = emotes off
+	EmoteCooldown      time.Duration      // wait after an emote whose entry sets no cooldown
+	EmoteRadius        int                // players within this distance of the emoter get PLAYER_EMOTE, world units
+	BotCount           int                // server-side bots spawned at startup
+	BotMax             int                // upper bound on live bots (at most 999)
+	BotThinkInterval   time.Duration      // how often bot behaviour is evaluated
+	BotBehaviorFile    string             // behaviour trees for bots (see internal/ai); empty = bots wander
+	BotAIBudget        time.Duration      // CPU time one pass over the behaviour trees may take; 0 = unlimited
+	BotAIEvery         int                // a bot's tree is evaluated every Nth pass, bots staggered by ID
+	ScriptsDir         string             // Lua gameplay scripts, reloaded on change (see internal/scripting); empty = none
+	ScriptBudget       time.Duration      // CPU time one script hook call may take
+	ScriptMemoryMB     int                // interpreter value stack, registry and string.rep limit; not a heap cap
+}
+
+// BatchConfig — write batching of one message class: the connection's write loop
+// holds queued messages back until the class has Size of them or the oldest has
+// waited Timeout, then writes everything it holds in one writev. Timeout 0 = never
+// delay (the loop still takes whatever is already queued).
+type BatchConfig struct {
+	Size    int
+	Timeout time.Duration
+}
+
+// RuntimeConfig — Go runtime tuning applied at startup (see internal/runtimeopt);
+// adjustable later through /admin/runtime.
+type RuntimeConfig struct {
+	GCPercent     int // GOGC; -1 = off
+	MemoryLimitMB int // soft memory limit; 0 = keep GOMEMLIMIT / no limit
+	BallastMB     int // heap ballast; 0 = none
+}
+
+// WorldEventConfig — one scheduled global event from gameConfig.json "worldEvents".
+type WorldEventConfig struct {
+	Name         string `json:"name"`
+	Schedule     string `json:"schedule"` // 5-field cron expression, server local time
+	Kind         string `json:"kind"`     // "night", "storm" or "announcement"
+	DurationSec  int    `json:"durationSec"`
+	SpeedPercent int    `json:"speedPercent"` // storm only: 1-1000, above 100 speeds players up
+	Text         string `json:"text"`         // shown to players when the event starts
+}
+
+// EmoteConfig — one emote from gameConfig.json "emotes". The client plays it by
+// name; the server only checks the ID and the cooldown.
+type EmoteConfig struct {
+	ID         int    `json:"id"` // 1..255
+	Name       string `json:"name"`
+	CooldownMs int    `json:"cooldownMs"` // wait before the next emote; 0 = EMOTE_COOLDOWN_MS
+}
+
+// SpawnArea — named rectangle new players spawn in, [MinX, MaxX) × [MinY, MaxY).
+// From gameConfig.json "world.spawnAreas" or SPAWN_AREAS.
+type SpawnArea struct {
+	Name string `json:"name"`
+	MinX uint32 `json:"minX"`
+	MinY uint32 `json:"minY"`
+	MaxX uint32 `json:"maxX"`
+	MaxY uint32 `json:"maxY"`
+}
+
+// SpawnPoint — named point new players spawn at; Disabled points are skipped until
+// the admin API enables them. From gameConfig.json "world.spawnPoints" or SPAWN_POINTS.
+type SpawnPoint struct {
+	Name     string `json:"name"`
+	X        uint32 `json:"x"`
+	Y        uint32 `json:"y"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// MaxWorldSize — the largest world side, in world units: positions are 24.8 fixed
+// point (types.Fixed).
+const MaxWorldSize = 1<<24 - 1
+
+// WireSpan returns how many world units a uint16 wire position covers with bits
+// fraction bits (POSITION_FRACTION_BITS).
+func WireSpan(bits int) uint32 {
+	return 1 << (16 - bits)
+}
+
+// LargeWorld reports whether a width×height world outgrows WireSpan(bits). Wire
+// positions in such a world are relative to an origin near each client (ORIGIN).
+func LargeWorld(width, height uint32, bits int) bool {
+	return max(width, height) >= WireSpan(bits)
 }
 
 type WorldConfig struct {
-	Width     uint16
-	Height    uint16
-	SpawnMinX uint16
-	SpawnMaxX uint16
-	SpawnMinY uint16
-	SpawnMaxY uint16
-	MinX      uint16
-	MaxX      uint16
-	MinY      uint16
-	MaxY      uint16
+	Width     uint32
+	Height    uint32
+	SpawnMinX uint32
+	SpawnMaxX uint32
+	SpawnMinY uint32
+	SpawnMaxY uint32
+	MinX      uint32
+	MaxX      uint32
+	MinY      uint32
+	MaxY      uint32
+
+	MapFile string // Tiled map export (.json/.tmj/.tmx); empty = open world
+	Seed    int    // without MapFile, generate the layout from this seed (worldmap.Generate); 0 = open world
+
+	// SpawnAreas replace the single SPAWN_MIN/MAX rectangle when set; a map's own
+	// spawn objects take precedence over both.
+	SpawnAreas []SpawnArea
+
+	// Enabled SpawnPoints take precedence over spawn areas (not over team bases).
+	SpawnPoints []SpawnPoint
+
+	// With TeamsEnabled every player joins the smallest team — one per TeamBases
+	// entry, named after it — and spawns inside its base.
+	TeamsEnabled bool
+	TeamBases    []SpawnArea
+
+	// SafeZones — areas where players neither attack nor get hit; a map's own safe
+	// objects take precedence.
+	SafeZones []SpawnArea
+
+	SpawnAttempts       int // points tried in the chosen grid cell to avoid blocked tiles; 0 = plain random spawns
+	SpawnCellMaxPlayers int // spawning into a cell with at least this many players counts as "crowded"
+
+	ZoneCols int // per-zone metrics grid (zone label "r<row>c<col>"); 0 = disabled
+	ZoneRows int
 }
 
 type NetworkConfig struct {
@@ -48,11 +306,61 @@ type NetworkConfig struct {
 	BurstLimit                     int
 	IPConnRate                     float64 // connections/sec per IP; 0 = disabled
 	IPConnBurst                    int
+	IPAuthConnRate                 float64 // authenticated connections/sec per IP (sized for CGNAT); 0 = disabled
+	IPAuthConnBurst                int
+	AccountConnRate                float64 // connections/sec per account; 0 = disabled
+	AccountConnBurst               int
+	ConnLimiterKeys                int           // IPs/accounts with a connection-attempt bucket kept per budget (least recently used evicted)
+	HandshakeTimeout               time.Duration // upgrade → JOIN deadline; 0 = no deadline
+	MaxPendingHandshakes           int           // half-open (upgraded, not joined) connection cap; 0 = unlimited
+	LoginQueueSize                 int           // JOINs held in the login queue while the server is full; 0 = a full server refuses with 503
+	LoginQueueUpdate               time.Duration // QUEUE_STATUS period for queued clients
+	LoginQueueReturnWindow         time.Duration // accounts that left this recently queue ahead of new ones
 	FanoutWorkers                  int
 	FanoutMaxBroadcastBytesPerTick int // 0 = unlimited
 	FanoutQueueShedDepth           int
+	ClientBandwidthCap             int // outbound bytes/sec per connection before its update tier drops; 0 = unlimited
+	ClientTickBudget               int // world-state delta bytes per client per tick; a bigger delta carries only the most relevant updates (server/interest.go); 0 = unlimited
 	FanoutDropStreak               int
 	WriteBatchSize                 int
+	StateBatch                     BatchConfig   // write batching of world-state frames
+	MovementBatch                  BatchConfig   // write batching of MOVEMENT_ACK
+	EventBatch                     BatchConfig   // write batching of joins, leaves and all other messages
+	WriteCoalesceBytes             int           // batches up to this size are copied into one buffer and written with one Write instead of writev; 0 = always writev
+	BroadcastWriteTimeout          time.Duration // write deadline for world-state frames
+	DirectWriteTimeout             time.Duration // write deadline for ACK, pong, initial state
+	WriteRetryOnTimeout            bool          // retry the rest of a timed-out write once before counting a failure
+	WSCompression                  bool          // offer permessage-deflate at upgrade (used only if the client asks in JOIN)
+	WSCompressionMinBytes          int           // smaller messages are sent uncompressed
+	RequireSubprotocol             bool          // reject clients that offer no WebSocket subprotocol (see protocol.Subprotocol)
+	PositionFractionBits           int           // bits of a unit in wire positions for current clients (0-8); worlds outgrowing uint16 get origin-relative positions (LargeWorld)
+	MaxWriteFailures               int           // consecutive write failures before the connection is dropped
+	ReadFrameTimeout               time.Duration // a frame must be complete this long after its first byte (slow-read watchdog)
+	ReadLimit                      int           // largest WebSocket frame accepted from a client, bytes
+	ReadBufferSize                 int           // client frames up to this size are read into pooled buffers, bytes; 0 = allocate every frame
+	WriteBufferPool                bool          // write loops borrow their batch scratch from a pool while writing instead of holding it per connection
+	PingInterval                   time.Duration
+	PongTimeout                    time.Duration // no frame from the client for this long = dead connection
+	CoalesceMoveAcks               bool          // send at most one MOVEMENT_ACK per player per tick
+	OutboundDedupe                 bool          // drop superseded MOVEMENT_ACK / MINIMAP still held by the write loop
+	ViewportMargin                 int           // world units added around the reported viewport for AOI filtering
+	MaxViewportWidth               int           // reported viewports are clamped to this many world units; 0 = world size
+	MaxViewportHeight              int
+	ViewportRateLimit              float64       // VIEWPORT_UPDATE messages applied per second per connection; 0 = unlimited
+	ViewportBurst                  int           // VIEWPORT_UPDATE messages allowed at once above the rate
+	MinimapInterval                time.Duration // MINIMAP period for subscribed clients; 0 = disabled
+	MinimapCols                    int           // minimap density grid, 1..255 cells per axis
+	MinimapRows                    int
+	LeaderboardInterval            time.Duration // LEADERBOARD period per metric for subscribed clients; 0 = disabled
+	LeaderboardSize                int           // players in a LEADERBOARD, 1..100
+	PartyMaxSize                   int           // members per party, 2..MaxPartySize; 0 = no parties
+	PartyInviteTTL                 time.Duration // a party invite must be accepted within this
+	PartyAlwaysVisible             bool          // party members are in each other's world state whatever the viewport
+	FriendPresenceInterval         time.Duration // how often online friends are checked for a zone change; 0 = online/offline only
+	StreamCellSize                 int           // cell side for CapCellStreaming clients (CELL_LOAD / CELL_UNLOAD), world units
+	ReliableRetry                  time.Duration // first retransmission of an unacked RELIABLE message, doubling after; 0 = no reliable layer
+	ReliableMaxRetries             int           // retransmissions before a RELIABLE message is given up
+	ReliableMaxPending             int           // unacked RELIABLE messages kept per connection; older ones are given up
 	FanoutFairDebtMax              int
 	FanoutFairDebtInc              int
 	FanoutFairDebtDec              int
@@ -66,6 +374,13 @@ type NetworkConfig struct {
 	WorldStateActiveStaleness      time.Duration
 	WorldStateIdleStaleness        time.Duration
 	WorldStateActiveWindow         time.Duration
+
+	// Abuse detection (server/abuse.go): sliding-window rates no legit client reaches
+	AbuseWindow            time.Duration // sliding window for per-player message rates
+	AbuseMaxMovesPerSec    float64       // MOVE rate (INPUT_BATCH entries included); 0 = no check
+	AbuseMaxMessagesPerSec float64       // all messages, including rate-limited ones; 0 = no check
+	AbuseMaxInvalidPerSec  float64       // undecodable messages; 0 = no check
+	AbuseStrikes           int           // anomalies before the player is kicked; 0 = report only
 }
 
 // JSONConfig mirrors the structure of gameConfig.json (shared with the TypeScript client).
@@ -78,6 +393,8 @@ type JSONConfig struct {
 	} `json:"network"`
 	Movement struct {
 		PlayerSpeedPerTick int `json:"playerSpeedPerTick"`
+		Acceleration       int `json:"acceleration"`
+		Friction           int `json:"friction"`
 	} `json:"movement"`
 	World struct {
 		VirtualSize struct {
@@ -90,7 +407,11 @@ type JSONConfig struct {
 			MinY int `json:"minY"`
 			MaxY int `json:"maxY"`
 		} `json:"spawnArea"`
-		Boundaries struct {
+		SpawnAreas  []SpawnArea  `json:"spawnAreas"`
+		SpawnPoints []SpawnPoint `json:"spawnPoints"`
+		TeamBases   []SpawnArea  `json:"teamBases"`
+		SafeZones   []SpawnArea  `json:"safeZones"`
+		Boundaries  struct {
 			MinX int `json:"minX"`
 			MaxX int `json:"maxX"`
 			MinY int `json:"minY"`
@@ -105,31 +426,160 @@ type JSONConfig struct {
 	Game struct {
 		DebugMode bool `json:"debugMode"`
 	} `json:"game"`
+	WorldEvents []WorldEventConfig `json:"worldEvents"`
+	Emotes      []EmoteConfig      `json:"emotes"`
 }
 
 // Load builds the server Config.
 //
 // Priority order (highest to lowest):
 //  1. Environment variables (from .env or system)
-//  2. Embedded gameConfig.json (game-rule defaults, shared with client)
-//  3. Hardcoded fallbacks for server-only infrastructure values
+//  2. The profile selected with --profile (LoadProfile, profiles.json)
+//  3. Embedded gameConfig.json (game-rule defaults, shared with client)
+//  4. Hardcoded fallbacks for server-only infrastructure values
 func Load() *Config {
-	jsonConfig, err := loadEmbeddedConfig()
+	cfg, err := LoadProfile("")
 	if err != nil {
 		fmt.Printf("Error: Could not load embedded config: %v\n", err)
 		os.Exit(1)
 	}
+	return cfg
+}
+
+func load() (*Config, error) {
+	jsonConfig, err := loadEmbeddedConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	syncIntervalSec := jsonConfig.Network.SyncInterval / 1000
+	writeBatchSize := getEnvInt("WRITE_BATCH_SIZE", 8) // default size of every message class
+
+	spawnAreas, err := getEnvAreas("SPAWN_AREAS", jsonConfig.World.SpawnAreas)
+	if err != nil {
+		return nil, err
+	}
+	teamBases, err := getEnvAreas("TEAM_BASES", jsonConfig.World.TeamBases)
+	if err != nil {
+		return nil, err
+	}
+	safeZones, err := getEnvAreas("SAFE_ZONES", jsonConfig.World.SafeZones)
+	if err != nil {
+		return nil, err
+	}
+	spawnPoints := jsonConfig.World.SpawnPoints
+	if value, source := lookupEnv("SPAWN_POINTS"); value != "" {
+		record("SPAWN_POINTS", value, source)
+		if spawnPoints, err = parseSpawnPoints(value); err != nil {
+			return nil, err
+		}
+	}
+
+	worldEvents := jsonConfig.WorldEvents
+	if getEnvInt("WORLD_EVENTS", 1) == 0 {
+		worldEvents = nil
+	}
 
 	return &Config{
 		// ── Server infrastructure ─────────────────────────────────────────────
 		// Defaults are hardcoded here; override via .env for deployment tuning.
 		Server: ServerConfig{
-			Port:      getEnvInt("PORT", 8108),
-			Host:      getEnvString("HOST", "0.0.0.0"),
-			Workers:   getEnvInt("WORKERS", 0),
-			StaticDir: getEnvString("STATIC_DIR", "../dist"),
+			Port:       getEnvInt("PORT", 8108),
+			Host:       getEnvString("HOST", "0.0.0.0"),
+			Workers:    getEnvInt("WORKERS", 0),
+			StaticDir:  getEnvString("STATIC_DIR", "../dist"),
+			AdminToken: getEnvString("ADMIN_TOKEN", ""),
+			Roles:      getEnvString("ROLES", ""),
+			PIDFile:    getEnvString("PID_FILE", ""),
+			LogFile:    getEnvString("LOG_FILE", ""),
+			LogLevel:   getEnvString("LOG_LEVEL", "debug"),
+
+			StaticEmbedded: getEnvInt("STATIC_EMBEDDED", 1) != 0,
+
+			AllowedOrigins: getEnvString("ALLOWED_ORIGINS", "*"),
+			TLSCertFile:    getEnvString("TLS_CERT_FILE", ""),
+			TLSKeyFile:     getEnvString("TLS_KEY_FILE", ""),
+			RequireTLS:     getEnvInt("REQUIRE_TLS", 0) != 0,
+			HSTSMaxAge:     time.Duration(getEnvInt("HSTS_MAX_AGE_SEC", 0)) * time.Second,
+
+			AdminAddr:         getEnvString("ADMIN_ADDR", ""),
+			AdminTLSCertFile:  getEnvString("ADMIN_TLS_CERT_FILE", ""),
+			AdminTLSKeyFile:   getEnvString("ADMIN_TLS_KEY_FILE", ""),
+			StaticAddr:        getEnvString("STATIC_ADDR", ""),
+			StaticTLSCertFile: getEnvString("STATIC_TLS_CERT_FILE", ""),
+			StaticTLSKeyFile:  getEnvString("STATIC_TLS_KEY_FILE", ""),
+
+			ReusePortListeners: getEnvInt("REUSEPORT_LISTENERS", 0),
+
+			AuthSecret:             getEnvString("AUTH_SECRET", ""),
+			AuthRequired:           getEnvInt("AUTH_REQUIRED", 0) != 0,
+			DuplicateSessionPolicy: getEnvString("SESSION_DUPLICATE_POLICY", SessionTakeover),
+
+			SealedMessages: getEnvString("SEALED_MESSAGES", "config,session_takeover"),
+
+			ModerationLog: getEnvString("MODERATION_LOG", "moderation.jsonl"),
+
+			MaintenanceSnapshotPath: getEnvString("MAINTENANCE_SNAPSHOT_PATH", "maintenance-snapshot.json"),
+			MaintenanceRetryAfter:   time.Duration(getEnvInt("MAINTENANCE_RETRY_AFTER_SEC", 60)) * time.Second,
+			RestartSchedule:         getEnvString("RESTART_SCHEDULE", ""),
+			MaintenanceSchedule:     getEnvString("MAINTENANCE_SCHEDULE", ""),
+			MaintenanceWindow:       time.Duration(getEnvInt("MAINTENANCE_WINDOW_MIN", 15)) * time.Minute,
+			ScheduleWarnings:        getEnvString("SCHEDULE_WARNINGS", "10m,5m,1m,10s"),
+
+			HandoverSocket:   getEnvString("HANDOVER_SOCKET", ""),
+			HandoverListener: getEnvInt("HANDOVER_LISTENER", 1) != 0,
+
+			Name:              getEnvString("SERVER_NAME", "pixi-game"),
+			Region:            getEnvString("SERVER_REGION", "local"),
+			PublicAddress:     getEnvString("PUBLIC_ADDRESS", ""),
+			DirectoryURL:      getEnvString("DIRECTORY_URL", ""),
+			DirectoryInterval: time.Duration(getEnvInt("DIRECTORY_INTERVAL_SEC", 30)) * time.Second,
+			DirectoryTimeout:  time.Duration(getEnvInt("DIRECTORY_TIMEOUT_MS", 5000)) * time.Millisecond,
+
+			SessionWebhookURL:     getEnvString("SESSION_WEBHOOK_URL", ""),
+			SessionWebhookTimeout: time.Duration(getEnvInt("SESSION_WEBHOOK_TIMEOUT_MS", 5000)) * time.Millisecond,
+
+			LeaderboardRedisAddr:     getEnvString("LEADERBOARD_REDIS_ADDR", ""),
+			LeaderboardRedisPassword: getEnvString("LEADERBOARD_REDIS_PASSWORD", ""),
+			LeaderboardRedisPrefix:   getEnvString("LEADERBOARD_REDIS_PREFIX", "leaderboard:"),
+			LeaderboardFlush:         time.Duration(getEnvInt("LEADERBOARD_FLUSH_MS", 1000)) * time.Millisecond,
+			LeaderboardTimeout:       time.Duration(getEnvInt("LEADERBOARD_TIMEOUT_MS", 2000)) * time.Millisecond,
+
+			FriendsLog: getEnvString("FRIENDS_LOG", "friends.jsonl"),
+			FriendsMax: getEnvInt("FRIENDS_MAX", 200),
+
+			InventoryLog:      getEnvString("INVENTORY_LOG", "inventory.jsonl"),
+			InventoryMaxSlots: getEnvInt("INVENTORY_MAX_SLOTS", 64),
+			InventoryMaxStack: getEnvInt("INVENTORY_MAX_STACK", 9999),
+			TradeRequestTTL:   time.Duration(getEnvInt("TRADE_REQUEST_TTL_SEC", 30)) * time.Second,
+
+			PlayerRecordsLog:  getEnvString("PLAYER_RECORDS_LOG", "players.jsonl"),
+			PersistFlush:      time.Duration(getEnvInt("PERSIST_FLUSH_MS", 1000)) * time.Millisecond,
+			PersistBatchSize:  getEnvInt("PERSIST_BATCH_SIZE", 256),
+			PersistRetries:    getEnvInt("PERSIST_RETRIES", 3),
+			PersistTimeout:    time.Duration(getEnvInt("PERSIST_TIMEOUT_MS", 2000)) * time.Millisecond,
+			PersistCheckpoint: time.Duration(getEnvInt("PERSIST_CHECKPOINT_SEC", 30)) * time.Second,
+
+			TenantsFile:   getEnvString("TENANTS_FILE", ""),
+			TenantDataDir: getEnvString("TENANT_DATA_DIR", "tenants"),
+			UsageReport:   time.Duration(getEnvInt("USAGE_REPORT_SEC", 60)) * time.Second,
+
+			MOTD:     getEnvString("MOTD", ""),
+			MOTDFile: getEnvString("MOTD_FILE", ""),
+
+			WorldView:           getEnvInt("WORLD_VIEW", 0) != 0,
+			WorldViewInterval:   time.Duration(getEnvInt("WORLD_VIEW_INTERVAL_MS", 1000)) * time.Millisecond,
+			WorldViewMaxStreams: getEnvInt("WORLD_VIEW_MAX_STREAMS", 16),
+
+			MetricsHistoryWindow:   time.Duration(getEnvInt("METRICS_HISTORY_MIN", 15)) * time.Minute,
+			MetricsHistoryInterval: time.Duration(getEnvInt("METRICS_HISTORY_INTERVAL_MS", 1000)) * time.Millisecond,
+
+			AlertTickMs:         getEnvFloat("ALERT_TICK_MS", 25),
+			AlertReadBacklog:    getEnvInt("ALERT_READ_BACKLOG", 1024),
+			AlertDropsPerSec:    getEnvFloat("ALERT_DROPS_PER_SEC", 100),
+			AlertSustain:        time.Duration(getEnvInt("ALERT_SUSTAIN_SEC", 30)) * time.Second,
+			AlertWebhookURL:     getEnvString("ALERT_WEBHOOK_URL", ""),
+			AlertWebhookTimeout: time.Duration(getEnvInt("ALERT_WEBHOOK_TIMEOUT_MS", 5000)) * time.Millisecond,
 		},
 		// ── Game rules ────────────────────────────────────────────────────────
 		// Defaults come from embedded gameConfig.json so they always match the client.
@@ -138,19 +588,61 @@ func Load() *Config {
 			SyncInterval:       time.Duration(getEnvInt("SYNC_INTERVAL_SEC", syncIntervalSec)) * time.Second,
 			BatchInterval:      time.Duration(getEnvInt("BATCH_INTERVAL_MS", jsonConfig.Network.BatchIntervalMs)) * time.Millisecond,
 			PlayerSpeedPerTick: getEnvInt("PLAYER_SPEED", jsonConfig.Movement.PlayerSpeedPerTick),
+			PlayerAcceleration: getEnvInt("PLAYER_ACCELERATION", jsonConfig.Movement.Acceleration),
+			PlayerFriction:     getEnvInt("PLAYER_FRICTION", jsonConfig.Movement.Friction),
 			AttackDuration:     time.Duration(getEnvInt("ATTACK_DURATION_MS", jsonConfig.Player.AttackDurationMs)) * time.Millisecond,
+			InputTimeoutTicks:  getEnvInt("INPUT_TIMEOUT_TICKS", 0),
+			SpawnProtection:    time.Duration(getEnvInt("SPAWN_PROTECTION_MS", 3000)) * time.Millisecond,
+			MaxHP:              getEnvInt("MAX_HP", 100),
+			AttackDamage:       getEnvInt("ATTACK_DAMAGE", 20),
+			AttackRange:        getEnvInt("ATTACK_RANGE", 60),
+			AttackArc:          getEnvInt("ATTACK_ARC", 90),
+			Knockback:          getEnvInt("KNOCKBACK", 30),
+			RespawnDelay:       time.Duration(getEnvInt("RESPAWN_DELAY_MS", 3000)) * time.Millisecond,
+			RegionSharding:     getEnvInt("TICK_REGION_SHARDING", 0) != 0,
+			TickWorkers:        getEnvInt("TICK_WORKERS", 0),
+			TickChunkSize:      getEnvInt("TICK_CHUNK_SIZE", 256),
+			MaxCatchUpTicks:    getEnvInt("TICK_MAX_CATCH_UP", 3),
+			WorldEvents:        worldEvents,
+			Emotes:             jsonConfig.Emotes,
+			EmoteCooldown:      time.Duration(getEnvInt("EMOTE_COOLDOWN_MS", 2000)) * time.Millisecond,
+			EmoteRadius:        getEnvInt("EMOTE_RADIUS", 800),
+			BotCount:           getEnvInt("BOT_COUNT", 0),
+			BotMax:             getEnvInt("BOT_MAX", 200),
+			BotThinkInterval:   time.Duration(getEnvInt("BOT_THINK_INTERVAL_MS", 250)) * time.Millisecond,
+			BotBehaviorFile:    getEnvString("BOT_BEHAVIOR_FILE", ""),
+			BotAIBudget:        time.Duration(getEnvInt("BOT_AI_BUDGET_MS", 2)) * time.Millisecond,
+			BotAIEvery:         getEnvInt("BOT_AI_EVERY", 1),
+			ScriptsDir:         getEnvString("SCRIPTS_DIR", ""),
+			ScriptBudget:       time.Duration(getEnvInt("SCRIPT_BUDGET_MS", 5)) * time.Millisecond,
+			ScriptMemoryMB:     getEnvInt("SCRIPT_MEMORY_MB", 16),
 		},
 		World: WorldConfig{
-			Width:     uint16(getEnvInt("WORLD_WIDTH", jsonConfig.World.VirtualSize.Width)),
-			Height:    uint16(getEnvInt("WORLD_HEIGHT", jsonConfig.World.VirtualSize.Height)),
-			SpawnMinX: uint16(getEnvInt("SPAWN_MIN_X", jsonConfig.World.SpawnArea.MinX)),
-			SpawnMaxX: uint16(getEnvInt("SPAWN_MAX_X", jsonConfig.World.SpawnArea.MaxX)),
-			SpawnMinY: uint16(getEnvInt("SPAWN_MIN_Y", jsonConfig.World.SpawnArea.MinY)),
-			SpawnMaxY: uint16(getEnvInt("SPAWN_MAX_Y", jsonConfig.World.SpawnArea.MaxY)),
+			Width:     uint32(getEnvInt("WORLD_WIDTH", jsonConfig.World.VirtualSize.Width)),
+			Height:    uint32(getEnvInt("WORLD_HEIGHT", jsonConfig.World.VirtualSize.Height)),
+			SpawnMinX: uint32(getEnvInt("SPAWN_MIN_X", jsonConfig.World.SpawnArea.MinX)),
+			SpawnMaxX: uint32(getEnvInt("SPAWN_MAX_X", jsonConfig.World.SpawnArea.MaxX)),
+			SpawnMinY: uint32(getEnvInt("SPAWN_MIN_Y", jsonConfig.World.SpawnArea.MinY)),
+			SpawnMaxY: uint32(getEnvInt("SPAWN_MAX_Y", jsonConfig.World.SpawnArea.MaxY)),
 			MinX:      0,
-			MaxX:      uint16(getEnvInt("WORLD_WIDTH", jsonConfig.World.VirtualSize.Width)),
+			MaxX:      uint32(getEnvInt("WORLD_WIDTH", jsonConfig.World.VirtualSize.Width)),
 			MinY:      0,
-			MaxY:      uint16(getEnvInt("WORLD_HEIGHT", jsonConfig.World.VirtualSize.Height)),
+			MaxY:      uint32(getEnvInt("WORLD_HEIGHT", jsonConfig.World.VirtualSize.Height)),
+
+			MapFile: getEnvString("MAP_FILE", ""),
+			Seed:    getEnvInt("WORLD_SEED", 0),
+
+			SpawnAreas:   spawnAreas,
+			SpawnPoints:  spawnPoints,
+			TeamsEnabled: getEnvInt("TEAMS_ENABLED", 0) != 0,
+			TeamBases:    teamBases,
+			SafeZones:    safeZones,
+
+			SpawnAttempts:       getEnvInt("SPAWN_ATTEMPTS", 5),
+			SpawnCellMaxPlayers: getEnvInt("SPAWN_CELL_MAX_PLAYERS", 4),
+
+			ZoneCols: getEnvInt("METRICS_ZONE_COLS", 4),
+			ZoneRows: getEnvInt("METRICS_ZONE_ROWS", 4),
 		},
 		// ── Network infrastructure ────────────────────────────────────────────
 		// All configurable via .env; hardcoded values are production-tested defaults.
@@ -160,11 +652,61 @@ func Load() *Config {
 			BurstLimit:                     getEnvInt("RATE_LIMIT_BURST", 20),
 			IPConnRate:                     getEnvFloat("IP_CONN_RATE", 10.0),
 			IPConnBurst:                    getEnvInt("IP_CONN_BURST", 20),
+			IPAuthConnRate:                 getEnvFloat("IP_AUTH_CONN_RATE", 100.0),
+			IPAuthConnBurst:                getEnvInt("IP_AUTH_CONN_BURST", 200),
+			AccountConnRate:                getEnvFloat("ACCOUNT_CONN_RATE", 0.5),
+			AccountConnBurst:               getEnvInt("ACCOUNT_CONN_BURST", 5),
+			ConnLimiterKeys:                getEnvInt("CONN_LIMITER_KEYS", 65536),
+			HandshakeTimeout:               time.Duration(getEnvInt("HANDSHAKE_TIMEOUT_MS", 5000)) * time.Millisecond,
+			MaxPendingHandshakes:           getEnvInt("MAX_PENDING_HANDSHAKES", 1024),
+			LoginQueueSize:                 getEnvInt("LOGIN_QUEUE_SIZE", 0),
+			LoginQueueUpdate:               time.Duration(getEnvInt("LOGIN_QUEUE_UPDATE_MS", 2000)) * time.Millisecond,
+			LoginQueueReturnWindow:         time.Duration(getEnvInt("LOGIN_QUEUE_RETURN_SEC", 300)) * time.Second,
 			FanoutWorkers:                  getEnvInt("FANOUT_WORKERS", 0),
 			FanoutMaxBroadcastBytesPerTick: getEnvInt("FANOUT_MAX_BROADCAST_BYTES_PER_TICK", 0),
 			FanoutQueueShedDepth:           getEnvInt("FANOUT_QUEUE_SHED_DEPTH", 6),
+			ClientBandwidthCap:             getEnvInt("CLIENT_BANDWIDTH_CAP_BPS", 0),
+			ClientTickBudget:               getEnvInt("CLIENT_TICK_BUDGET_BYTES", 0),
 			FanoutDropStreak:               getEnvInt("FANOUT_DROP_STREAK", 120),
-			WriteBatchSize:                 getEnvInt("WRITE_BATCH_SIZE", 8),
+			WriteBatchSize:                 writeBatchSize,
+			StateBatch:                     getEnvBatch("WRITE_BATCH_STATE", writeBatchSize, 0),
+			MovementBatch:                  getEnvBatch("WRITE_BATCH_MOVEMENT", writeBatchSize, 0),
+			EventBatch:                     getEnvBatch("WRITE_BATCH_EVENT", writeBatchSize, 0),
+			WriteCoalesceBytes:             getEnvInt("WRITE_COALESCE_BYTES", 4096),
+			BroadcastWriteTimeout:          time.Duration(getEnvInt("BROADCAST_WRITE_TIMEOUT_MS", 100)) * time.Millisecond,
+			DirectWriteTimeout:             time.Duration(getEnvInt("DIRECT_WRITE_TIMEOUT_MS", 30)) * time.Millisecond,
+			WriteRetryOnTimeout:            getEnvInt("WRITE_RETRY_ON_TIMEOUT", 1) != 0,
+			WSCompression:                  getEnvInt("WS_COMPRESSION", 0) != 0,
+			WSCompressionMinBytes:          getEnvInt("WS_COMPRESSION_MIN_BYTES", 256),
+			RequireSubprotocol:             getEnvInt("WS_REQUIRE_SUBPROTOCOL", 0) != 0,
+			PositionFractionBits:           getEnvInt("POSITION_FRACTION_BITS", 0),
+			MaxWriteFailures:               getEnvInt("WRITE_MAX_FAILURES", 150),
+			ReadFrameTimeout:               time.Duration(getEnvInt("READ_FRAME_TIMEOUT_MS", 100)) * time.Millisecond,
+			ReadLimit:                      getEnvInt("WS_READ_LIMIT_BYTES", 4096),
+			ReadBufferSize:                 getEnvInt("READ_BUFFER_SIZE", 512),
+			WriteBufferPool:                getEnvInt("WRITE_BUFFER_POOL", 1) != 0,
+			PingInterval:                   time.Duration(getEnvInt("PING_INTERVAL_SEC", 30)) * time.Second,
+			PongTimeout:                    time.Duration(getEnvInt("PONG_TIMEOUT_SEC", 90)) * time.Second,
+			CoalesceMoveAcks:               getEnvInt("COALESCE_MOVE_ACKS", 1) != 0,
+			OutboundDedupe:                 getEnvInt("OUTBOUND_DEDUPE", 1) != 0,
+			ViewportMargin:                 getEnvInt("VIEWPORT_MARGIN", 200),
+			MaxViewportWidth:               getEnvInt("MAX_VIEWPORT_WIDTH", 3840),
+			MaxViewportHeight:              getEnvInt("MAX_VIEWPORT_HEIGHT", 2160),
+			ViewportRateLimit:              getEnvFloat("VIEWPORT_RATE_LIMIT", 2),
+			ViewportBurst:                  getEnvInt("VIEWPORT_BURST", 4),
+			MinimapInterval:                time.Duration(getEnvInt("MINIMAP_INTERVAL_MS", 1000)) * time.Millisecond,
+			MinimapCols:                    getEnvInt("MINIMAP_COLS", 32),
+			MinimapRows:                    getEnvInt("MINIMAP_ROWS", 16),
+			LeaderboardInterval:            time.Duration(getEnvInt("LEADERBOARD_INTERVAL_MS", 5000)) * time.Millisecond,
+			LeaderboardSize:                getEnvInt("LEADERBOARD_SIZE", 10),
+			PartyMaxSize:                   getEnvInt("PARTY_MAX_SIZE", 4),
+			PartyInviteTTL:                 time.Duration(getEnvInt("PARTY_INVITE_TTL_SEC", 60)) * time.Second,
+			PartyAlwaysVisible:             getEnvInt("PARTY_ALWAYS_VISIBLE", 1) != 0,
+			FriendPresenceInterval:         time.Duration(getEnvInt("FRIEND_PRESENCE_INTERVAL_MS", 2000)) * time.Millisecond,
+			StreamCellSize:                 getEnvInt("STREAM_CELL_SIZE", 500),
+			ReliableRetry:                  time.Duration(getEnvInt("RELIABLE_RETRY_MS", 500)) * time.Millisecond,
+			ReliableMaxRetries:             getEnvInt("RELIABLE_MAX_RETRIES", 5),
+			ReliableMaxPending:             getEnvInt("RELIABLE_MAX_PENDING", 256),
 			FanoutFairDebtMax:              getEnvInt("FANOUT_FAIR_DEBT_MAX", 12),
 			FanoutFairDebtInc:              getEnvInt("FANOUT_FAIR_DEBT_INC", 1),
 			FanoutFairDebtDec:              getEnvInt("FANOUT_FAIR_DEBT_DEC", 2),
@@ -178,31 +720,396 @@ func Load() *Config {
 			WorldStateActiveStaleness:      time.Duration(getEnvInt("WORLD_STATE_ACTIVE_STALENESS_MS", 150)) * time.Millisecond,
 			WorldStateIdleStaleness:        time.Duration(getEnvInt("WORLD_STATE_IDLE_STALENESS_MS", 350)) * time.Millisecond,
 			WorldStateActiveWindow:         time.Duration(getEnvInt("WORLD_STATE_ACTIVE_WINDOW_MS", 1000)) * time.Millisecond,
+			// The browser sends at most one MOVE per frame: even 144 Hz displays stay below 200/s.
+			AbuseWindow:            time.Duration(getEnvInt("ABUSE_WINDOW_MS", 5000)) * time.Millisecond,
+			AbuseMaxMovesPerSec:    getEnvFloat("ABUSE_MAX_MOVES_SEC", 200),
+			AbuseMaxMessagesPerSec: getEnvFloat("ABUSE_MAX_MSG_SEC", 300),
+			AbuseMaxInvalidPerSec:  getEnvFloat("ABUSE_MAX_INVALID_SEC", 5),
+			AbuseStrikes:           getEnvInt("ABUSE_STRIKES", 3),
+		},
+		// ── Go runtime ────────────────────────────────────────────────────────
+		// GOGC=400 trades memory for fewer GC cycles (no mark-assist spikes at 10K players).
+		Runtime: RuntimeConfig{
+			GCPercent:     getEnvGCPercent(400),
+			MemoryLimitMB: getEnvInt("MEMORY_LIMIT_MB", 0),
+			BallastMB:     getEnvInt("HEAP_BALLAST_MB", 0),
 		},
+	}, nil
+}
+
+// Validate reports settings the server cannot run with. Env values that fail to
+// parse already fell back to their defaults in Load; this catches the parsed ones that
+// are out of range.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Port < 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT %d is out of range 0-65535", c.Server.Port))
+	}
+	if c.Game.TickRate <= 0 {
+		errs = append(errs, fmt.Errorf("TICK_RATE must be positive, got %d", c.Game.TickRate))
+	}
+	if c.Game.MaxCatchUpTicks < 0 {
+		errs = append(errs, fmt.Errorf("TICK_MAX_CATCH_UP must not be negative, got %d", c.Game.MaxCatchUpTicks))
 	}
+	if c.Game.PlayerAcceleration < 0 || c.Game.PlayerAcceleration > math.MaxUint16 || c.Game.PlayerFriction < 0 || c.Game.PlayerFriction > math.MaxUint16 {
+		errs = append(errs, fmt.Errorf("PLAYER_ACCELERATION and PLAYER_FRICTION must be 0-%d, got %d and %d", math.MaxUint16, c.Game.PlayerAcceleration, c.Game.PlayerFriction))
+	}
+	if c.World.Seed < 0 || c.World.Seed > math.MaxUint32 {
+		errs = append(errs, fmt.Errorf("WORLD_SEED must be 0-%d, got %d", uint32(math.MaxUint32), c.World.Seed))
+	}
+	if c.Game.MaxHP <= 0 || c.Game.MaxHP > math.MaxUint16 {
+		errs = append(errs, fmt.Errorf("MAX_HP must be 1-%d, got %d", math.MaxUint16, c.Game.MaxHP))
+	}
+	if c.Game.AttackDamage < 0 || c.Game.AttackDamage > math.MaxUint16 || c.Game.AttackRange < 0 {
+		errs = append(errs, fmt.Errorf("ATTACK_DAMAGE must be 0-%d and ATTACK_RANGE not negative, got %d and %d", math.MaxUint16, c.Game.AttackDamage, c.Game.AttackRange))
+	}
+	if c.Game.AttackArc < 1 || c.Game.AttackArc > 360 {
+		errs = append(errs, fmt.Errorf("ATTACK_ARC must be 1-360, got %d", c.Game.AttackArc))
+	}
+	if c.Game.Knockback < 0 || c.Game.Knockback > math.MaxInt8 {
+		errs = append(errs, fmt.Errorf("KNOCKBACK must be 0-%d, got %d", math.MaxInt8, c.Game.Knockback))
+	}
+	emoteIDs := make(map[int]bool, len(c.Game.Emotes))
+	for _, e := range c.Game.Emotes {
+		if e.ID < 1 || e.ID > math.MaxUint8 || e.Name == "" || e.CooldownMs < 0 || emoteIDs[e.ID] {
+			errs = append(errs, fmt.Errorf("emote %d %q needs a unique id 1-%d, a name and a cooldown not negative", e.ID, e.Name, math.MaxUint8))
+		}
+		emoteIDs[e.ID] = true
+	}
+	if c.Game.EmoteCooldown < 0 || c.Game.EmoteRadius < 0 {
+		errs = append(errs, fmt.Errorf("EMOTE_COOLDOWN_MS and EMOTE_RADIUS must not be negative, got %v and %d", c.Game.EmoteCooldown, c.Game.EmoteRadius))
+	}
+	if c.Game.BotAIEvery < 1 || c.Game.BotAIBudget < 0 {
+		errs = append(errs, fmt.Errorf("BOT_AI_EVERY must be at least 1 and BOT_AI_BUDGET_MS not negative, got %d and %v", c.Game.BotAIEvery, c.Game.BotAIBudget))
+	}
+	if c.Game.ScriptsDir != "" && (c.Game.ScriptBudget <= 0 || c.Game.ScriptMemoryMB <= 0) {
+		errs = append(errs, fmt.Errorf("SCRIPT_BUDGET_MS and SCRIPT_MEMORY_MB must be positive with SCRIPTS_DIR, got %v and %d", c.Game.ScriptBudget, c.Game.ScriptMemoryMB))
+	}
+	if c.Net.MaxConnections <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_CONNECTIONS must be positive, got %d", c.Net.MaxConnections))
+	}
+	if c.Net.LoginQueueSize < 0 || c.Net.LoginQueueReturnWindow < 0 {
+		errs = append(errs, fmt.Errorf("LOGIN_QUEUE_SIZE and LOGIN_QUEUE_RETURN_SEC must not be negative, got %d and %v", c.Net.LoginQueueSize, c.Net.LoginQueueReturnWindow))
+	}
+	if c.Net.ClientTickBudget < 0 {
+		errs = append(errs, fmt.Errorf("CLIENT_TICK_BUDGET_BYTES must not be negative, got %d", c.Net.ClientTickBudget))
+	}
+	if c.Net.WriteCoalesceBytes < 0 {
+		errs = append(errs, fmt.Errorf("WRITE_COALESCE_BYTES must not be negative, got %d", c.Net.WriteCoalesceBytes))
+	}
+	if c.Net.LoginQueueSize > 0 && c.Net.LoginQueueUpdate <= 0 {
+		errs = append(errs, fmt.Errorf("LOGIN_QUEUE_UPDATE_MS must be positive with LOGIN_QUEUE_SIZE, got %v", c.Net.LoginQueueUpdate))
+	}
+	if c.Net.ConnLimiterKeys <= 0 {
+		errs = append(errs, fmt.Errorf("CONN_LIMITER_KEYS must be positive, got %d", c.Net.ConnLimiterKeys))
+	}
+	if c.Net.MaxViewportWidth < 0 || c.Net.MaxViewportHeight < 0 {
+		errs = append(errs, fmt.Errorf("MAX_VIEWPORT_WIDTH/HEIGHT must not be negative, got %dx%d", c.Net.MaxViewportWidth, c.Net.MaxViewportHeight))
+	}
+	if c.Net.ViewportRateLimit > 0 && c.Net.ViewportBurst <= 0 {
+		errs = append(errs, fmt.Errorf("VIEWPORT_BURST must be positive when VIEWPORT_RATE_LIMIT is set, got %d", c.Net.ViewportBurst))
+	}
+	if c.World.Width == 0 || c.World.Height == 0 {
+		errs = append(errs, fmt.Errorf("world size %dx%d is empty", c.World.Width, c.World.Height))
+	} else if max(c.World.Width, c.World.Height) > MaxWorldSize {
+		errs = append(errs, fmt.Errorf("world size %dx%d is over the %d limit", c.World.Width, c.World.Height, MaxWorldSize))
+	}
+	if bits := c.Net.PositionFractionBits; bits < 0 || bits > 8 {
+		errs = append(errs, fmt.Errorf("POSITION_FRACTION_BITS must be 0-8, got %d", bits))
+	} else if LargeWorld(c.World.Width, c.World.Height, bits) {
+		// The client is kept in the middle half of its wire window (WireSpan), so
+		// everything it sees must fit into a quarter of the window on either side.
+		reach := int(WireSpan(bits) / 4)
+		if c.Net.MaxViewportWidth == 0 || c.Net.MaxViewportHeight == 0 ||
+			max(c.Net.MaxViewportWidth, c.Net.MaxViewportHeight)/2+c.Net.ViewportMargin > reach {
+			errs = append(errs, fmt.Errorf("a %dx%d world sends positions relative to each client: MAX_VIEWPORT_WIDTH/HEIGHT must be set and half of them plus VIEWPORT_MARGIN at most %d with POSITION_FRACTION_BITS=%d",
+				c.World.Width, c.World.Height, reach, bits))
+		}
+	}
+	if c.Net.MinimapInterval > 0 {
+		cols, rows := min(max(c.Net.MinimapCols, 1), 255), min(max(c.Net.MinimapRows, 1), 255)
+		if (int(c.World.Width)+cols-1)/cols > math.MaxUint16 || (int(c.World.Height)+rows-1)/rows > math.MaxUint16 {
+			errs = append(errs, fmt.Errorf("MINIMAP_COLS/ROWS %dx%d are too few for a %dx%d world: a cell side goes up to %d", cols, rows, c.World.Width, c.World.Height, math.MaxUint16))
+		}
+	}
+	if c.Net.StreamCellSize > 0 && max(c.World.Width, c.World.Height)/uint32(c.Net.StreamCellSize) > math.MaxUint16 {
+		errs = append(errs, fmt.Errorf("STREAM_CELL_SIZE %d is too small for a %dx%d world: cell indices go up to %d", c.Net.StreamCellSize, c.World.Width, c.World.Height, math.MaxUint16))
+	}
+	if c.World.MinX > c.World.MaxX || c.World.MinY > c.World.MaxY {
+		errs = append(errs, errors.New("world movement bounds are inverted"))
+	}
+	for _, a := range c.World.SpawnAreas {
+		if a.MinX >= a.MaxX || a.MinY >= a.MaxY || a.MaxX > c.World.Width || a.MaxY > c.World.Height {
+			errs = append(errs, fmt.Errorf("spawn area %q is empty or outside the %dx%d world", a.Name, c.World.Width, c.World.Height))
+		}
+	}
+	points := make(map[string]bool, len(c.World.SpawnPoints))
+	for _, p := range c.World.SpawnPoints {
+		if p.Name == "" || points[p.Name] {
+			errs = append(errs, fmt.Errorf("spawn point name %q is empty or not unique", p.Name))
+		}
+		points[p.Name] = true
+		if p.X >= c.World.Width || p.Y >= c.World.Height {
+			errs = append(errs, fmt.Errorf("spawn point %q is outside the %dx%d world", p.Name, c.World.Width, c.World.Height))
+		}
+	}
+	teams := make(map[string]bool, len(c.World.TeamBases))
+	for _, b := range c.World.TeamBases {
+		if b.Name == "" || teams[b.Name] {
+			errs = append(errs, fmt.Errorf("team base name %q is empty or not unique", b.Name))
+		}
+		teams[b.Name] = true
+		if b.MinX >= b.MaxX || b.MinY >= b.MaxY || b.MaxX > c.World.Width || b.MaxY > c.World.Height {
+			errs = append(errs, fmt.Errorf("team base %q is empty or outside the %dx%d world", b.Name, c.World.Width, c.World.Height))
+		}
+	}
+	for _, a := range c.World.SafeZones {
+		if a.MinX >= a.MaxX || a.MinY >= a.MaxY || a.MaxX > c.World.Width || a.MaxY > c.World.Height {
+			errs = append(errs, fmt.Errorf("safe zone %q is empty or outside the %dx%d world", a.Name, c.World.Width, c.World.Height))
+		}
+	}
+	if c.World.TeamsEnabled && len(c.World.TeamBases) == 0 {
+		errs = append(errs, errors.New("TEAMS_ENABLED is set but no TEAM_BASES are configured"))
+	} else if len(c.World.TeamBases) > maxTeams {
+		errs = append(errs, fmt.Errorf("%d team bases, at most %d teams are supported", len(c.World.TeamBases), maxTeams))
+	}
+	if c.Server.MetricsHistoryWindow > 0 && c.Server.MetricsHistoryInterval <= 0 {
+		errs = append(errs, errors.New("METRICS_HISTORY_INTERVAL_MS must be positive when METRICS_HISTORY_MIN is set"))
+	}
+	if p := c.Server.DuplicateSessionPolicy; p != SessionTakeover && p != SessionReject {
+		errs = append(errs, fmt.Errorf("SESSION_DUPLICATE_POLICY %q is not %q or %q", p, SessionTakeover, SessionReject))
+	}
+	if _, err := ParseLogLevel(c.Server.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if c.Server.RequireTLS && c.Server.TLSCertFile == "" {
+		errs = append(errs, errors.New("REQUIRE_TLS is set but TLS_CERT_FILE/TLS_KEY_FILE are not"))
+	}
+	for _, l := range []struct{ name, addr, cert, key string }{
+		{"ADMIN", c.Server.AdminAddr, c.Server.AdminTLSCertFile, c.Server.AdminTLSKeyFile},
+		{"STATIC", c.Server.StaticAddr, c.Server.StaticTLSCertFile, c.Server.StaticTLSKeyFile},
+	} {
+		if l.addr != "" {
+			if _, _, err := net.SplitHostPort(l.addr); err != nil {
+				errs = append(errs, fmt.Errorf("%s_ADDR %q is not host:port: %w", l.name, l.addr, err))
+			}
+		} else if l.cert != "" {
+			errs = append(errs, fmt.Errorf("%s_TLS_CERT_FILE is set but %s_ADDR is not", l.name, l.name))
+		}
+		if (l.cert == "") != (l.key == "") {
+			errs = append(errs, fmt.Errorf("%s_TLS_CERT_FILE and %s_TLS_KEY_FILE must be set together", l.name, l.name))
+		}
+	}
+	if c.Server.RequireTLS && c.Server.StaticAddr != "" && c.Server.StaticTLSCertFile == "" {
+		errs = append(errs, errors.New("REQUIRE_TLS is set but STATIC_TLS_CERT_FILE/STATIC_TLS_KEY_FILE are not"))
+	}
+	if c.Server.ReusePortListeners < 0 || c.Server.ReusePortListeners > MaxReusePortListeners {
+		errs = append(errs, fmt.Errorf("REUSEPORT_LISTENERS must be 0-%d, got %d", MaxReusePortListeners, c.Server.ReusePortListeners))
+	}
+	if c.Server.LeaderboardFlush <= 0 {
+		errs = append(errs, fmt.Errorf("LEADERBOARD_FLUSH_MS must be positive, got %v", c.Server.LeaderboardFlush))
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"DIRECTORY_TIMEOUT_MS", c.Server.DirectoryTimeout},
+		{"SESSION_WEBHOOK_TIMEOUT_MS", c.Server.SessionWebhookTimeout},
+		{"LEADERBOARD_TIMEOUT_MS", c.Server.LeaderboardTimeout},
+		{"ALERT_WEBHOOK_TIMEOUT_MS", c.Server.AlertWebhookTimeout},
+		{"PERSIST_FLUSH_MS", c.Server.PersistFlush},
+		{"PERSIST_TIMEOUT_MS", c.Server.PersistTimeout},
+		{"PERSIST_CHECKPOINT_SEC", c.Server.PersistCheckpoint},
+	} {
+		if t.d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %v", t.name, t.d))
+		}
+	}
+	if c.Server.TenantsFile != "" && c.Server.HandoverSocket != "" {
+		errs = append(errs, errors.New("HANDOVER_SOCKET cannot be used with TENANTS_FILE: tenant worlds are not handed over"))
+	}
+	if c.Server.UsageReport < 0 {
+		errs = append(errs, fmt.Errorf("USAGE_REPORT_SEC must not be negative, got %v", c.Server.UsageReport))
+	}
+	if c.Server.PersistBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("PERSIST_BATCH_SIZE must be positive, got %d", c.Server.PersistBatchSize))
+	}
+	if c.Server.PersistRetries < 1 {
+		errs = append(errs, fmt.Errorf("PERSIST_RETRIES must be positive, got %d", c.Server.PersistRetries))
+	}
+	if c.Net.LeaderboardSize < 1 || c.Net.LeaderboardSize > MaxLeaderboardSize {
+		errs = append(errs, fmt.Errorf("LEADERBOARD_SIZE must be 1-%d, got %d", MaxLeaderboardSize, c.Net.LeaderboardSize))
+	}
+	if addr := c.Server.LeaderboardRedisAddr; addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("LEADERBOARD_REDIS_ADDR %q is not host:port: %w", addr, err))
+		}
+	}
+	if n := c.Net.PartyMaxSize; n != 0 && (n < 2 || n > MaxPartySize) {
+		errs = append(errs, fmt.Errorf("PARTY_MAX_SIZE must be 0 or 2-%d, got %d", MaxPartySize, n))
+	}
+	if c.Net.PartyMaxSize > 0 && c.Net.PartyInviteTTL <= 0 {
+		errs = append(errs, fmt.Errorf("PARTY_INVITE_TTL_SEC must be positive, got %v", c.Net.PartyInviteTTL))
+	}
+	if c.Server.FriendsMax < 0 {
+		errs = append(errs, fmt.Errorf("FRIENDS_MAX must not be negative, got %d", c.Server.FriendsMax))
+	}
+	if c.Server.InventoryMaxSlots < 1 {
+		errs = append(errs, fmt.Errorf("INVENTORY_MAX_SLOTS must be positive, got %d", c.Server.InventoryMaxSlots))
+	}
+	if c.Server.InventoryMaxStack < 1 {
+		errs = append(errs, fmt.Errorf("INVENTORY_MAX_STACK must be positive, got %d", c.Server.InventoryMaxStack))
+	}
+	if c.Server.TradeRequestTTL <= 0 {
+		errs = append(errs, fmt.Errorf("TRADE_REQUEST_TTL_SEC must be positive, got %v", c.Server.TradeRequestTTL))
+	}
+	if c.Server.AuthRequired && c.Server.AuthSecret == "" {
+		errs = append(errs, errors.New("AUTH_REQUIRED is set but AUTH_SECRET is empty: nobody could join"))
+	}
+	return errors.Join(errs...)
+}
+
+// ParseLogLevel parses LOG_LEVEL (debug, info, warn, error).
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("LOG_LEVEL %q is not debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// MaxReusePortListeners — upper bound on REUSEPORT_LISTENERS; more sockets than
+// cores only add accept loops that compete for the same CPUs.
+const MaxReusePortListeners = 256
+
+// MaxLeaderboardSize — upper bound on LEADERBOARD_SIZE and on /leaderboard?limit.
+const MaxLeaderboardSize = 100
+
+// MaxPartySize — upper bound on PARTY_MAX_SIZE: every member is sent in full to the
+// others whatever their viewports (PARTY_ALWAYS_VISIBLE).
+const MaxPartySize = 32
+
+// maxTeams — team numbers are stored in one byte (0 = no team).
+const maxTeams = 255
+
+// getEnvAreas reads a list of named rectangles (SPAWN_AREAS, TEAM_BASES, SAFE_ZONES), falling back
+// to the gameConfig.json list.
+func getEnvAreas(key string, defaultValue []SpawnArea) ([]SpawnArea, error) {
+	value, source := lookupEnv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	record(key, value, source)
+	areas, err := parseAreas(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return areas, nil
+}
+
+// parseAreas parses "name:minX,minY,maxX,maxY" entries separated by ';'.
+func parseAreas(s string) ([]SpawnArea, error) {
+	var areas []SpawnArea
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, coords, ok := strings.Cut(entry, ":")
+		parts := strings.Split(coords, ",")
+		if !ok || len(parts) != 4 {
+			return nil, fmt.Errorf("entry %q is not name:minX,minY,maxX,maxY", entry)
+		}
+		var v [4]uint32
+		for i, part := range parts {
+			n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("entry %q: %w", entry, err)
+			}
+			v[i] = uint32(n)
+		}
+		areas = append(areas, SpawnArea{Name: strings.TrimSpace(name), MinX: v[0], MinY: v[1], MaxX: v[2], MaxY: v[3]})
+	}
+	return areas, nil
+}
+
+// parseSpawnPoints parses SPAWN_POINTS: "name:x,y" entries separated by ';'. A
+// trailing ":off" ("name:x,y:off") starts the point disabled.
+func parseSpawnPoints(s string) ([]SpawnPoint, error) {
+	var points []SpawnPoint
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) == 3 && fields[2] != "off" || len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("SPAWN_POINTS entry %q is not name:x,y[:off]", entry)
+		}
+		coords := strings.Split(fields[1], ",")
+		if len(coords) != 2 {
+			return nil, fmt.Errorf("SPAWN_POINTS entry %q is not name:x,y[:off]", entry)
+		}
+		var v [2]uint32
+		for i, part := range coords {
+			n, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("SPAWN_POINTS entry %q: %w", entry, err)
+			}
+			v[i] = uint32(n)
+		}
+		points = append(points, SpawnPoint{Name: strings.TrimSpace(fields[0]), X: v[0], Y: v[1], Disabled: len(fields) == 3})
+	}
+	return points, nil
+}
+
+// getEnvGCPercent reads GOGC the way the Go runtime does: a percentage or "off".
+func getEnvGCPercent(defaultValue int) int {
+	if value, source := lookupEnv("GOGC"); value == "off" {
+		record("GOGC", value, source)
+		return -1
+	}
+	return getEnvInt("GOGC", defaultValue)
 }
 
 func getEnvString(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value, source := lookupEnv(key); value != "" {
+		record(key, value, source)
 		return value
 	}
+	record(key, defaultValue, SourceDefault)
 	return defaultValue
 }
 
+// getEnvBatch reads <prefix>_SIZE and <prefix>_TIMEOUT_MS.
+func getEnvBatch(prefix string, size, timeoutMs int) BatchConfig {
+	return BatchConfig{
+		Size:    getEnvInt(prefix+"_SIZE", size),
+		Timeout: time.Duration(getEnvInt(prefix+"_TIMEOUT_MS", timeoutMs)) * time.Millisecond,
+	}
+}
+
 func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+	if value, source := lookupEnv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
+			record(key, value, source)
 			return intValue
 		}
 	}
+	record(key, strconv.Itoa(defaultValue), SourceDefault)
 	return defaultValue
 }
 
 func getEnvFloat(key string, defaultValue float64) float64 {
-	if value := os.Getenv(key); value != "" {
+	if value, source := lookupEnv(key); value != "" {
 		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			record(key, value, source)
 			return floatValue
 		}
 	}
+	record(key, strconv.FormatFloat(defaultValue, 'g', -1, 64), SourceDefault)
 	return defaultValue
 }
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpawnAreasEnv(t *testing.T) {
+	t.Setenv("SPAWN_AREAS", "west:0,0,400,300; east:1200,100,1600,500")
+	cfg, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SpawnArea{
+		{Name: "west", MinX: 0, MinY: 0, MaxX: 400, MaxY: 300},
+		{Name: "east", MinX: 1200, MinY: 100, MaxX: 1600, MaxY: 500},
+	}
+	if !slices.Equal(cfg.World.SpawnAreas, want) {
+		t.Errorf("spawn areas = %+v, want %+v", cfg.World.SpawnAreas, want)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid spawn areas rejected: %v", err)
+	}
+
+	cfg.World.SpawnAreas = append(cfg.World.SpawnAreas, SpawnArea{Name: "void", MinX: 10, MaxX: 10, MaxY: 10})
+	if cfg.Validate() == nil {
+		t.Error("empty spawn area passed validation")
+	}
+
+	for _, bad := range []string{"west:0,0,400", "0,0,400,300", "west:0,0,400,5000000000"} {
+		t.Setenv("SPAWN_AREAS", bad)
+		if _, err := LoadProfile(""); err == nil {
+			t.Errorf("SPAWN_AREAS=%q accepted", bad)
+		}
+	}
+}
+
+func TestSpawnPointsAndTeamBasesEnv(t *testing.T) {
+	t.Setenv("SPAWN_POINTS", "gate:100,200; tower:300,400:off")
+	t.Setenv("TEAM_BASES", "red:0,0,500,500;blue:1000,1000,1500,1500")
+	t.Setenv("TEAMS_ENABLED", "1")
+	cfg, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPoints := []SpawnPoint{{Name: "gate", X: 100, Y: 200}, {Name: "tower", X: 300, Y: 400, Disabled: true}}
+	if !slices.Equal(cfg.World.SpawnPoints, wantPoints) {
+		t.Errorf("spawn points = %+v, want %+v", cfg.World.SpawnPoints, wantPoints)
+	}
+	if !cfg.World.TeamsEnabled || len(cfg.World.TeamBases) != 2 || cfg.World.TeamBases[1].Name != "blue" {
+		t.Errorf("teams = %v, bases %+v", cfg.World.TeamsEnabled, cfg.World.TeamBases)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid spawn points and bases rejected: %v", err)
+	}
+
+	cfg.World.SpawnPoints = append(cfg.World.SpawnPoints, SpawnPoint{Name: "gate", X: 1, Y: 1})
+	cfg.World.TeamBases = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "not unique") || !strings.Contains(err.Error(), "TEAM_BASES") {
+		t.Errorf("duplicate point and teams without bases: %v", err)
+	}
+
+	for _, bad := range []string{"gate:100", "gate:100,200:on", "100,200"} {
+		t.Setenv("SPAWN_POINTS", bad)
+		if _, err := LoadProfile(""); err == nil {
+			t.Errorf("SPAWN_POINTS=%q accepted", bad)
+		}
+	}
+}
+
+func TestLargeWorldValidation(t *testing.T) {
+	cfg, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.World.Width, cfg.World.Height = 200000, 100000
+	cfg.World.MaxX, cfg.World.MaxY = cfg.World.Width, cfg.World.Height
+	cfg.World.SpawnAreas, cfg.World.SpawnPoints, cfg.World.TeamBases, cfg.World.SafeZones = nil, nil, nil, nil
+	cfg.Net.PositionFractionBits = 2
+	if !LargeWorld(cfg.World.Width, cfg.World.Height, 2) || LargeWorld(16383, 16383, 2) {
+		t.Fatal("LargeWorld does not follow WireSpan")
+	}
+
+	// WireSpan(2) = 16384: a quarter is 4096 units of reach around the client.
+	cfg.Net.MaxViewportWidth, cfg.Net.MaxViewportHeight, cfg.Net.ViewportMargin = 3840, 2160, 200
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("large world with a fitting viewport rejected: %v", err)
+	}
+	cfg.Net.PositionFractionBits = 3
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "relative to each client") {
+		t.Errorf("viewport over the wire window: %v", err)
+	}
+	cfg.Net.PositionFractionBits, cfg.Net.MaxViewportWidth = 2, 0
+	if cfg.Validate() == nil {
+		t.Error("unbounded viewport in a large world passed validation")
+	}
+
+	cfg.Net.MaxViewportWidth = 3840
+	cfg.World.Width = MaxWorldSize + 1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("world over MaxWorldSize: %v", err)
+	}
+}
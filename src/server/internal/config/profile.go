@@ -0,0 +1,177 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Config profiles: named sets of env overrides (profiles.json) selected with
+// `server --profile NAME`. Priority becomes env > profile > defaults, and every value
+// Load reads is recorded with its source for the startup log and /debug/config.
+// The config of a tenant (LoadTenant, see internal/tenant) puts the tenant's own
+// overrides on top of that.
+
+//go:embed profiles.json
+var embeddedProfiles []byte
+
+// Setting is one effective configuration value.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // "tenant", "env", "profile" or "default"
+}
+
+// Setting sources.
+const (
+	SourceTenant  = "tenant"
+	SourceEnv     = "env"
+	SourceProfile = "profile"
+	SourceDefault = "default"
+)
+
+// redacted is shown instead of the value of keys that hold credentials.
+const redacted = "<redacted>"
+
+// loadState is the profile and the settings recorded while one Load runs.
+type loadState struct {
+	tenant    map[string]string
+	overrides map[string]string
+	settings  []Setting
+	index     map[string]int
+}
+
+var (
+	loadMu sync.Mutex // the getEnv helpers read active; one Load at a time
+	active *loadState
+)
+
+// Profiles returns the names of the built-in profiles.
+func Profiles() []string {
+	profiles, err := parseProfiles()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func parseProfiles() (map[string]map[string]string, error) {
+	var profiles map[string]map[string]string
+	if err := json.Unmarshal(embeddedProfiles, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// LoadProfile is Load with the overrides of the named profile; "" = none.
+func LoadProfile(name string) (*Config, error) {
+	return LoadTenant(name, nil)
+}
+
+// LoadTenant is LoadProfile with tenant overrides, which win over the environment.
+func LoadTenant(name string, tenant map[string]string) (*Config, error) {
+	st := &loadState{tenant: tenant, index: make(map[string]int)}
+	if name != "" {
+		profiles, err := parseProfiles()
+		if err != nil {
+			return nil, err
+		}
+		overrides, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown config profile %q (have %s)", name, strings.Join(Profiles(), ", "))
+		}
+		st.overrides = overrides
+	}
+
+	loadMu.Lock()
+	active = st
+	cfg, err := load()
+	active = nil
+	loadMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Profile = name
+	cfg.Settings = st.settings
+	return cfg, nil
+}
+
+// lookupEnv returns the raw value of key: the tenant overrides, the environment, then
+// the active profile.
+func lookupEnv(key string) (string, string) {
+	if active != nil {
+		if value := active.tenant[key]; value != "" {
+			return value, SourceTenant
+		}
+	}
+	if value := os.Getenv(key); value != "" {
+		return value, SourceEnv
+	}
+	if active != nil {
+		if value := active.overrides[key]; value != "" {
+			return value, SourceProfile
+		}
+	}
+	return "", SourceDefault
+}
+
+// record notes the value key ended up with. A key read twice keeps its first entry.
+func record(key, value, source string) {
+	if active == nil {
+		return
+	}
+	if _, ok := active.index[key]; ok {
+		return
+	}
+	if isSecret(key) && value != "" {
+		value = redacted
+	} else {
+		value = RedactURL(value)
+	}
+	active.index[key] = len(active.settings)
+	active.settings = append(active.settings, Setting{Key: key, Value: value, Source: source})
+}
+
+func isSecret(key string) bool {
+	return strings.HasSuffix(key, "_SECRET") || strings.HasSuffix(key, "_TOKEN") || strings.HasSuffix(key, "_PASSWORD")
+}
+
+// RedactURL hides the userinfo and query of a URL value: webhook and directory URLs
+// often carry credentials there, and their key names do not say so. Values that are
+// not absolute URLs are returned as they are. Use it wherever such a URL is logged.
+func RedactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.User == nil && u.RawQuery == "") {
+		return value
+	}
+	out := u.Scheme + "://"
+	if u.User != nil {
+		out += redacted + "@"
+	}
+	out += u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		out += "?" + redacted
+	}
+	return out
+}
+
+// RedactURLError is err with its URL passed through RedactURL when it is a *url.Error,
+// which http.Client returns with the request URL in the message.
+func RedactURLError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	return &url.Error{Op: ue.Op, URL: RedactURL(ue.URL), Err: ue.Err}
+}
@@ -0,0 +1,239 @@
+// Package console runs the in-game console: chat lines starting with "/" are
+// commands, looked up in a Registry that subsystems add their commands to.
+//
+// Every command names the least role that may run it. Roles are ordered — player <
+// moderator < admin — and a caller's role comes from the server config (accounts
+// listed in Server.Roles); everyone else, anonymous players included, is a player.
+// A command answers with a Result: a status and text for the issuing client.
+package console
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Role — права вызывающего. Роли упорядочены: старшая может всё, что младшая.
+type Role uint8
+
+const (
+	RolePlayer Role = iota
+	RoleModerator
+	RoleAdmin
+)
+
+var roleNames = [...]string{RolePlayer: "player", RoleModerator: "moderator", RoleAdmin: "admin"}
+
+func (r Role) String() string {
+	if int(r) < len(roleNames) {
+		return roleNames[r]
+	}
+	return "unknown"
+}
+
+// ParseRole parses a role name.
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if strings.EqualFold(name, n) {
+			return Role(i), nil
+		}
+	}
+	return 0, fmt.Errorf("console: unknown role %q", name)
+}
+
+// ParseRoles parses "alice=admin,bob=moderator" into account → role.
+func ParseRoles(spec string) (map[string]Role, error) {
+	roles := make(map[string]Role)
+	for entry := range strings.SplitSeq(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		account, name, ok := strings.Cut(entry, "=")
+		if !ok || account == "" {
+			return nil, fmt.Errorf("console: role entry %q is not account=role", entry)
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		roles[account] = role
+	}
+	return roles, nil
+}
+
+// Status — итог команды, совпадает с protocol.Command*.
+type Status uint8
+
+const (
+	StatusOK Status = iota
+	StatusError
+	StatusDenied
+	StatusUnknown
+)
+
+// Result — ответ команды вызвавшему клиенту.
+type Result struct {
+	Status Status
+	Text   string
+}
+
+// OK returns a successful result with formatted output.
+func OK(format string, args ...any) Result {
+	return Result{Status: StatusOK, Text: fmt.Sprintf(format, args...)}
+}
+
+// Errorf returns a failed result; the text tells the caller why.
+func Errorf(format string, args ...any) Result {
+	return Result{Status: StatusError, Text: fmt.Sprintf(format, args...)}
+}
+
+// Caller — кто вызвал команду.
+type Caller struct {
+	PlayerID uint32
+	Account  string // "" = anonymous
+	Role     Role
+}
+
+// Command — консольная команда.
+type Command struct {
+	Name  string // without the slash, lowercase
+	Usage string // arguments, e.g. "<player> [reason]"
+	Help  string // one line for /help
+	Role  Role   // least role that may run it
+	Run   func(caller Caller, args []string) Result
+}
+
+var (
+	ErrNotCommand = errors.New("console: not a command")
+	ErrDuplicate  = errors.New("console: command already registered")
+)
+
+// Registry — зарегистрированные команды. Безопасен для конкурентного использования.
+type Registry struct {
+	mu   sync.RWMutex
+	cmds map[string]*Command
+}
+
+// NewRegistry returns a registry with /help already in it.
+func NewRegistry() *Registry {
+	r := &Registry{cmds: make(map[string]*Command)}
+	r.MustRegister(Command{
+		Name: "help",
+		Help: "lists the commands you may run",
+		Run:  r.help,
+	})
+	return r
+}
+
+// Register adds a command. Names are case-insensitive.
+func (r *Registry) Register(cmd Command) error {
+	cmd.Name = strings.ToLower(cmd.Name)
+	if cmd.Name == "" || strings.ContainsAny(cmd.Name, " /") || cmd.Run == nil {
+		return fmt.Errorf("console: bad command %q", cmd.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cmds[cmd.Name]; ok {
+		return fmt.Errorf("%w: /%s", ErrDuplicate, cmd.Name)
+	}
+	r.cmds[cmd.Name] = &cmd
+	return nil
+}
+
+// MustRegister is Register for built-in commands; it panics on error.
+func (r *Registry) MustRegister(cmd Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// IsCommand reports whether a chat line is a command.
+func IsCommand(line string) bool {
+	return strings.HasPrefix(line, "/")
+}
+
+// Dispatch runs the command in line for caller. The caller gets StatusUnknown for a
+// command that does not exist and StatusDenied for one above its role.
+func (r *Registry) Dispatch(caller Caller, line string) (string, Result) {
+	args, err := Split(line)
+	if err != nil {
+		return "", Errorf("%v", err)
+	}
+	if len(args) == 0 || args[0] == "" {
+		return "", Result{Status: StatusUnknown, Text: "type /help for the commands"}
+	}
+	name := strings.ToLower(args[0])
+	r.mu.RLock()
+	cmd := r.cmds[name]
+	r.mu.RUnlock()
+	if cmd == nil {
+		return name, Result{Status: StatusUnknown, Text: fmt.Sprintf("unknown command /%s, type /help", name)}
+	}
+	if caller.Role < cmd.Role {
+		return name, Result{Status: StatusDenied, Text: fmt.Sprintf("/%s needs role %s", name, cmd.Role)}
+	}
+	return name, cmd.Run(caller, args[1:])
+}
+
+// Split parses a command line into the command name and its arguments. Arguments are
+// separated by spaces; "double quotes" keep spaces inside one argument.
+func Split(line string) ([]string, error) {
+	line, ok := strings.CutPrefix(line, "/")
+	if !ok {
+		return nil, ErrNotCommand
+	}
+	if !utf8.ValidString(line) {
+		return nil, errors.New("command is not valid UTF-8")
+	}
+	var (
+		args    []string
+		cur     strings.Builder
+		inArg   bool
+		inQuote bool
+	)
+	for _, c := range line {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+			inArg = true
+		case c == ' ' && !inQuote:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func (r *Registry) help(caller Caller, _ []string) Result {
+	r.mu.RLock()
+	var lines []string
+	for _, cmd := range r.cmds {
+		if caller.Role < cmd.Role {
+			continue
+		}
+		line := "/" + cmd.Name
+		if cmd.Usage != "" {
+			line += " " + cmd.Usage
+		}
+		lines = append(lines, line+" — "+cmd.Help)
+	}
+	r.mu.RUnlock()
+	slices.Sort(lines)
+	return OK("%s", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,77 @@
+package console
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for line, want := range map[string][]string{
+		"/who":                         {"who"},
+		"/tp  10 20 ":                  {"tp", "10", "20"},
+		`/announce "restart soon" now`: {"announce", "restart soon", "now"},
+		`/say ""`:                      {"say", ""},
+		"/":                            nil,
+	} {
+		got, err := Split(line)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("Split(%q) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+	if _, err := Split(`/say "open`); err == nil {
+		t.Error("unterminated quote accepted")
+	}
+	if _, err := Split("hello"); !errors.Is(err, ErrNotCommand) {
+		t.Errorf("Split of a chat line = %v, want ErrNotCommand", err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Command{Name: "Echo", Usage: "<text>", Help: "repeats", Run: func(c Caller, args []string) Result {
+		return OK("%d: %s", c.PlayerID, strings.Join(args, " "))
+	}})
+	r.MustRegister(Command{Name: "kick", Help: "kicks", Role: RoleModerator, Run: func(Caller, []string) Result {
+		return OK("kicked")
+	}})
+	if err := r.Register(Command{Name: "echo", Run: func(Caller, []string) Result { return Result{} }}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate Register = %v", err)
+	}
+
+	player := Caller{PlayerID: 7, Role: RolePlayer}
+	mod := Caller{PlayerID: 8, Role: RoleModerator}
+	tests := []struct {
+		caller Caller
+		line   string
+		want   Result
+	}{
+		{player, "/ECHO a b", Result{StatusOK, "7: a b"}},
+		{player, "/kick 9", Result{StatusDenied, "/kick needs role moderator"}},
+		{mod, "/kick 9", Result{StatusOK, "kicked"}},
+		{player, "/nope", Result{StatusUnknown, "unknown command /nope, type /help"}},
+		{player, "/help", Result{StatusOK, "/echo <text> — repeats\n/help — lists the commands you may run"}},
+		{player, `/echo "x`, Result{StatusError, "unterminated quote"}},
+	}
+	for _, tt := range tests {
+		if _, got := r.Dispatch(tt.caller, tt.line); got != tt.want {
+			t.Errorf("Dispatch(%s, %q) = %+v, want %+v", tt.caller.Role, tt.line, got, tt.want)
+		}
+	}
+	if _, got := r.Dispatch(mod, "/help"); !strings.Contains(got.Text, "/kick") {
+		t.Errorf("moderator /help = %q, want /kick listed", got.Text)
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles(" alice=admin, bob=Moderator ,")
+	if err != nil || len(roles) != 2 || roles["alice"] != RoleAdmin || roles["bob"] != RoleModerator {
+		t.Fatalf("ParseRoles = %v, %v", roles, err)
+	}
+	for _, bad := range []string{"alice", "=admin", "alice=root"} {
+		if _, err := ParseRoles(bad); err == nil {
+			t.Errorf("ParseRoles(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package ecs is a minimal entity-component store for the tick loop.
+//
+// A Table assigns each entity a row; each Column holds one component type for all rows
+// of its table (struct of arrays), so a system that needs positions and velocities walks
+// two dense arrays instead of chasing a pointer per entity. Columns are allocated in
+// fixed pages that never move: a *T from Column.At stays valid for as long as the row
+// is owned, which lets entity handles (types.Player) point straight at their rows.
+//
+// A removed row is not handed out again until Recycle, so a system iterating the table
+// concurrently with Remove never writes a component of a different entity into it; the
+// owner calls Recycle between two passes (the game loop does it at the start of a tick).
+//
+// Add, Remove and Recycle must be serialised by the owner. Rows, Owner and Column.At are
+// safe to call concurrently with them.
+package ecs
+
+import (
+	"sync/atomic"
+)
+
+// PageSize — число строк в одной странице колонки.
+const PageSize = 256
+
+// Entity — идентификатор сущности (ID игрока или бота). 0 — «нет сущности».
+type Entity uint32
+
+// Row — индекс строки таблицы.
+type Row int32
+
+// column — колонка, которую Table расширяет при росте и чистит в Recycle.
+type column interface {
+	grow(pages int)
+	reset(r Row)
+}
+
+// Table — распределитель строк для сущностей одного набора компонентов.
+type Table struct {
+	rows    map[Entity]Row
+	owners  *Column[uint32] // Entity строки, 0 — строка свободна; atomic
+	free    []Row           // готовы к повторной выдаче
+	pending []Row           // удалены, ждут Recycle
+	next    atomic.Int32    // строк выдано за всё время (граница обхода)
+	pages   int
+	columns []column
+}
+
+// NewTable создаёт пустую таблицу.
+func NewTable() *Table {
+	t := &Table{rows: make(map[Entity]Row)}
+	t.owners = NewColumn[uint32](t)
+	return t
+}
+
+// Add выделяет строку под e и вызывает init (если не nil) до того, как строка станет
+// видна через Owner: обход таблицы не встречает недозаполненных сущностей. Компоненты
+// новой строки нулевые. false — e уже есть в таблице.
+func (t *Table) Add(e Entity, init func(Row)) (Row, bool) {
+	if _, ok := t.rows[e]; ok || e == 0 {
+		return 0, false
+	}
+	var r Row
+	if n := len(t.free); n > 0 {
+		r = t.free[n-1]
+		t.free = t.free[:n-1]
+	} else {
+		r = Row(t.next.Load())
+		if int(r) == t.pages*PageSize {
+			t.pages++
+			for _, c := range t.columns {
+				c.grow(t.pages)
+			}
+		}
+		t.next.Store(int32(r) + 1)
+	}
+	t.rows[e] = r
+	if init != nil {
+		init(r)
+	}
+	atomic.StoreUint32(t.owners.At(r), uint32(e))
+	return r, true
+}
+
+// Remove освобождает строку e. Строка выдаётся снова только после Recycle.
+func (t *Table) Remove(e Entity) (Row, bool) {
+	r, ok := t.rows[e]
+	if !ok {
+		return 0, false
+	}
+	delete(t.rows, e)
+	atomic.StoreUint32(t.owners.At(r), 0)
+	t.pending = append(t.pending, r)
+	return r, true
+}
+
+// Recycle обнуляет удалённые строки во всех колонках и делает их доступными для Add.
+// Никто не должен обходить таблицу во время Recycle.
+func (t *Table) Recycle() {
+	for _, r := range t.pending {
+		for _, c := range t.columns {
+			c.reset(r)
+		}
+	}
+	t.free = append(t.free, t.pending...)
+	t.pending = t.pending[:0]
+}
+
+// Lookup возвращает строку e.
+func (t *Table) Lookup(e Entity) (Row, bool) {
+	r, ok := t.rows[e]
+	return r, ok
+}
+
+// Len — число сущностей в таблице.
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Rows — верхняя граница номеров строк: обход идёт по [0, Rows()), пропуская строки
+// без владельца.
+func (t *Table) Rows() Row {
+	return Row(t.next.Load())
+}
+
+// Owner возвращает сущность строки r; 0 — строка свободна.
+func (t *Table) Owner(r Row) Entity {
+	return Entity(atomic.LoadUint32(t.owners.At(r)))
+}
+
+// Column — компоненты одного типа по строкам таблицы.
+type Column[T any] struct {
+	pages atomic.Pointer[[]*[PageSize]T]
+}
+
+// NewColumn добавляет к t колонку компонентов T.
+func NewColumn[T any](t *Table) *Column[T] {
+	c := &Column[T]{}
+	c.grow(t.pages)
+	t.columns = append(t.columns, c)
+	return c
+}
+
+// At возвращает компонент строки r. Адрес не меняется, пока таблица жива.
+func (c *Column[T]) At(r Row) *T {
+	pages := *c.pages.Load()
+	return &pages[r/PageSize][r%PageSize]
+}
+
+func (c *Column[T]) reset(r Row) {
+	var zero T
+	*c.At(r) = zero
+}
+
+// grow дополняет колонку до n страниц. Срез страниц заменяется целиком, поэтому
+// читатели At видят либо старый, либо новый срез, а сами страницы не копируются.
+func (c *Column[T]) grow(n int) {
+	var pages []*[PageSize]T
+	if old := c.pages.Load(); old != nil {
+		pages = *old
+	}
+	grown := make([]*[PageSize]T, len(pages), n)
+	copy(grown, pages)
+	for len(grown) < n {
+		grown = append(grown, new([PageSize]T))
+	}
+	c.pages.Store(&grown)
+}
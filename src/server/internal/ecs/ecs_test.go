@@ -0,0 +1,55 @@
+package ecs
+
+import (
+	"testing"
+)
+
+func TestTableReusesRowsAfterRecycle(t *testing.T) {
+	tbl := NewTable()
+	values := NewColumn[int](tbl)
+
+	a, _ := tbl.Add(1, func(r Row) { *values.At(r) = 10 })
+	b, _ := tbl.Add(2, nil)
+	if _, ok := tbl.Add(2, nil); ok {
+		t.Fatal("entity added twice")
+	}
+	if tbl.Owner(a) != 1 || tbl.Owner(b) != 2 || tbl.Len() != 2 {
+		t.Fatalf("owners = %d, %d (len %d), want 1, 2", tbl.Owner(a), tbl.Owner(b), tbl.Len())
+	}
+
+	tbl.Remove(1)
+	if tbl.Owner(a) != 0 {
+		t.Fatal("removed row still owned")
+	}
+	if c, _ := tbl.Add(3, nil); c == a {
+		t.Fatal("row reused before Recycle")
+	}
+	tbl.Recycle()
+	c, _ := tbl.Add(4, nil)
+	if c != a {
+		t.Fatalf("entity 4 got row %d, want recycled row %d", c, a)
+	}
+	if *values.At(c) != 0 {
+		t.Errorf("recycled row kept value %d", *values.At(c))
+	}
+	if tbl.Rows() != 3 {
+		t.Errorf("Rows() = %d, want 3", tbl.Rows())
+	}
+}
+
+func TestColumnAddressesAreStable(t *testing.T) {
+	tbl := NewTable()
+	values := NewColumn[int](tbl)
+	first, _ := tbl.Add(1, nil)
+	p := values.At(first)
+	*p = 42
+	for e := Entity(2); e <= 3*PageSize; e++ {
+		tbl.Add(e, nil)
+	}
+	if values.At(first) != p || *p != 42 {
+		t.Fatal("component moved when the table grew")
+	}
+	if r, ok := tbl.Lookup(3 * PageSize); !ok || tbl.Owner(r) != 3*PageSize {
+		t.Fatalf("lookup of the last entity = %d, %v", r, ok)
+	}
+}
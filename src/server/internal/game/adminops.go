@@ -0,0 +1,180 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/types"
+)
+
+// Операции администратора над живыми игроками: телепорт, заморозка, множитель
+// скорости, неуязвимость. Это те же GameEvent, что и ввод клиентов (handleEvent), но
+// приходят через ApplyAdminEvent, которая их проверяет и возвращает ошибку — admin API
+// и консоль показывают её оператору.
+//
+//   - Телепорт применяется в gameLoop в начале следующего тика, пока tick worker'ы
+//     стоят: иначе фаза collision перезаписала бы позицию целью, посчитанной от старой.
+//     Игрок останавливается и получает коррекцию позиции, как при input timeout. На
+//     паузе телепорт ждёт возобновления симуляции.
+//   - Заморозка обнуляет вектор и блокирует MOVE и ATTACK до разморозки.
+//   - Множитель скорости действует поверх шторма (worldevents.go).
+//   - Неуязвимость: Stun и Kill не действуют; клиентам уходит флагом защиты.
+
+// Допустимый множитель скорости, как у шторма.
+const (
+	MinSpeedPercent = 1
+	MaxSpeedPercent = 1000
+)
+
+var (
+	// ErrNoPlayer — игрока нет в мире.
+	ErrNoPlayer = errors.New("player is not in the world")
+	// ErrBlocked — точка телепорта занята стеной карты.
+	ErrBlocked = errors.New("destination is blocked by the map")
+)
+
+// AdminPlayerState — позиция и HP игрока и флаги администратора на нём.
+type AdminPlayerState struct {
+	X            uint32 `json:"x"`
+	Y            uint32 `json:"y"`
+	Frozen       bool   `json:"frozen"`
+	SpeedPercent int32  `json:"speed_percent"`
+	Invulnerable bool   `json:"invulnerable"`
+	HP           uint16 `json:"hp"`
+	Team         string `json:"team,omitempty"`
+}
+
+// teleportQueue — телепорты до начала следующего тика.
+type teleportQueue struct {
+	mu      sync.Mutex
+	pending []types.GameEvent
+}
+
+// ApplyAdminEvent проверяет и применяет операцию администратора (EventTeleport,
+// EventFreeze, EventSpeed, EventInvulnerable).
+func (gw *GameWorld) ApplyAdminEvent(event types.GameEvent) error {
+	if _, ok := gw.player(event.PlayerID); !ok {
+		return ErrNoPlayer
+	}
+	switch event.Type {
+	case types.EventTeleport:
+		b := gw.bounds.Load()
+		if !b.contains(event.X, event.Y) {
+			return fmt.Errorf("destination is outside the world (%d..%d, %d..%d)", b.MinX, b.MaxX, b.MinY, b.MaxY)
+		}
+		if gw.worldMap != nil && gw.worldMap.Blocked(event.X, event.Y) {
+			return ErrBlocked
+		}
+	case types.EventSpeed:
+		if event.Value < MinSpeedPercent || event.Value > MaxSpeedPercent {
+			return fmt.Errorf("speed must be %d-%d percent", MinSpeedPercent, MaxSpeedPercent)
+		}
+	case types.EventFreeze, types.EventInvulnerable:
+	default:
+		return fmt.Errorf("event type %d is not an admin operation", event.Type)
+	}
+	gw.handleEvent(event)
+	return nil
+}
+
+// AdminPlayerState возвращает позицию и флаги администратора игрока.
+func (gw *GameWorld) AdminPlayerState(playerID uint32) (AdminPlayerState, bool) {
+	player, ok := gw.player(playerID)
+	if !ok {
+		return AdminPlayerState{}, false
+	}
+	combat := player.Combat()
+	return AdminPlayerState{
+		X:            player.GetX(),
+		Y:            player.GetY(),
+		Frozen:       combat.GetFrozen(),
+		SpeedPercent: player.Velocity().GetSpeedPercent(),
+		Invulnerable: combat.GetInvulnerable(),
+		HP:           combat.GetHP(),
+		Team:         gw.TeamName(player.GetTeam()),
+	}, true
+}
+
+// moveSpeed — шаг игрока за тик: скорость из конфига с учётом шторма и множителя
+// игрока (полный ввод, без разгона).
+func (gw *GameWorld) moveSpeed(vel *types.Velocity) int32 {
+	// Storms scale the speed; never below 1 so players are slowed, not frozen.
+	speed := int32(gw.cfg.Game.PlayerSpeedPerTick)
+	if pct := atomic.LoadInt32(&gw.speedPercent); pct != 100 {
+		speed = max(speed*pct/100, 1)
+	}
+	if pct := vel.GetSpeedPercent(); pct != 100 {
+		speed = max(speed*pct/100, 1)
+	}
+	return speed
+}
+
+// handleAdminEvent — ветка handleEvent для операций администратора.
+func (gw *GameWorld) handleAdminEvent(player *types.Player, event types.GameEvent) {
+	switch event.Type {
+	case types.EventTeleport:
+		metrics.EventsProcessed.WithLabelValues("teleport").Inc()
+		gw.teleports.mu.Lock()
+		gw.teleports.pending = append(gw.teleports.pending, event)
+		gw.teleports.mu.Unlock()
+
+	case types.EventFreeze:
+		metrics.EventsProcessed.WithLabelValues("freeze").Inc()
+		frozen := event.Value != 0
+		player.Combat().SetFrozen(frozen)
+		if frozen {
+			player.SetVX(0)
+			player.SetVY(0)
+		}
+
+	case types.EventSpeed:
+		metrics.EventsProcessed.WithLabelValues("speed").Inc()
+		pct := event.Value
+		if pct == 100 {
+			pct = 0
+		}
+		player.Velocity().SetSpeedPercent(pct)
+
+	case types.EventInvulnerable:
+		metrics.EventsProcessed.WithLabelValues("invulnerable").Inc()
+		player.Combat().SetInvulnerable(event.Value != 0)
+	}
+}
+
+// applyTeleports переносит игроков из очереди телепортов. gameLoop goroutine, до фаз
+// тика: tick worker'ы стоят, поэтому сетку видимости можно менять напрямую и в
+// region-sharded режиме.
+func (gw *GameWorld) applyTeleports() {
+	gw.teleports.mu.Lock()
+	pending := gw.teleports.pending
+	gw.teleports.pending = nil
+	gw.teleports.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	b := gw.bounds.Load()
+	holder, hasTimeoutFn := gw.inputTimeoutFn.Load().(inputTimeoutFuncHolder)
+	for _, event := range pending {
+		player, ok := gw.player(event.PlayerID)
+		if !ok {
+			continue
+		}
+		// Resize мог сузить мир после проверки в ApplyAdminEvent.
+		x, y := b.clamp(event.X, event.Y)
+		player.SetVX(0)
+		player.SetVY(0)
+		player.Velocity().SetSubV(0, 0)
+		player.SetX(x)
+		player.SetY(y)
+		player.SetLastUpdate(gw.tickNowNano)
+		gw.visibility.Load().MovePlayer(player.ID, x, y)
+		gw.updateViewport(player)
+		if hasTimeoutFn {
+			holder.fn(player.ID, types.FixedOf(x), types.FixedOf(y), player.GetClientTick())
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package game
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/ai"
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/types"
+	"pixi_game_server/internal/worldmap"
+)
+
+// Server-side bots: ambient population for demo/dev environments.
+//
+// Bots are ordinary Players in playersMap — the tick moves them, deltas and snapshots
+// carry them — but nothing reads a socket for them. A single goroutine drives the
+// wander behaviour by writing movement vectors, like an EventMove would; the decision
+// timer lives in the bot's AI component.
+//
+// With BOT_BEHAVIOR_FILE the bots run behaviour trees (internal/ai) instead: patrol,
+// aggro on players, flee when hurt. Bot ID i gets the (i mod n)-th behaviour of the
+// file. So that a crowd of NPCs costs a bounded slice of CPU, each bot's tree runs only
+// every BOT_AI_EVERY think passes, and a pass stops after BOT_AI_BUDGET_MS; the bots it
+// did not reach are evaluated in the next one.
+//
+// Bot IDs come from [1, maxBotID], below the first real player ID (1000), so they are
+// easy to tell apart and can never be the highest ID in a client's first GAME_STATE
+// (the client takes that one as its own player).
+
+const (
+	maxBotID = 999
+
+	botMinDecision = 500 * time.Millisecond
+	botMaxDecision = 3 * time.Second
+	botIdleChance  = 0.25 // chance to stand still for one decision period
+	botAttackRate  = 0.1  // chance to attack at a decision point
+
+	botPathMaxAge = 2 * time.Second // a bot's path is searched again after this long
+)
+
+// BotManager spawns, drives and removes bot players in a GameWorld.
+type BotManager struct {
+	gw            *GameWorld
+	maxBots       int
+	thinkInterval time.Duration
+
+	mu      sync.Mutex
+	bots    []*types.Player
+	freeIDs []uint32 // stack of unused bot IDs
+	rng     *rand.Rand
+
+	onJoin  func(*types.Player)
+	onLeave func(playerID uint32)
+
+	// Behaviour trees; nil = bots wander.
+	trees  []*ai.Tree
+	brains map[uint32]*botBrain
+	budget time.Duration
+	every  uint64
+	pass   uint64
+	cursor int // index in bots the next pass starts at
+	agent  botAgent
+}
+
+// botBrain — what a bot with a behaviour tree remembers.
+type botBrain struct {
+	mem  ai.Memory
+	owed bool // due in a pass that ran out of budget
+
+	// Path to the last MoveTo goal (GameWorld.FindPath), next waypoint first.
+	path   []worldmap.Point
+	goal   worldmap.Point
+	pathAt int64 // UnixNano of the search
+}
+
+// NewBotManager creates a bot manager for gw. Call SetNotifiers before Spawn and
+// start Run in its own goroutine.
+func NewBotManager(gw *GameWorld, cfg *config.Config) *BotManager {
+	maxBots := min(max(cfg.Game.BotMax, 0), maxBotID)
+	think := cfg.Game.BotThinkInterval
+	if think <= 0 {
+		think = 250 * time.Millisecond
+	}
+
+	bm := &BotManager{
+		gw:            gw,
+		maxBots:       maxBots,
+		thinkInterval: think,
+		freeIDs:       make([]uint32, 0, maxBotID),
+		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+		budget:        cfg.Game.BotAIBudget,
+		every:         uint64(max(cfg.Game.BotAIEvery, 1)),
+	}
+	for id := uint32(maxBotID); id >= 1; id-- {
+		bm.freeIDs = append(bm.freeIDs, id)
+	}
+	if path := cfg.Game.BotBehaviorFile; path != "" {
+		trees, err := ai.Load(path)
+		if err != nil {
+			slog.Error("failed to load bot behaviours, bots wander", "path", path, "err", err)
+		} else {
+			bm.SetBehaviors(trees)
+			slog.Info("bot behaviours loaded", "path", path, "behaviours", len(trees))
+		}
+	}
+	bm.agent.gw = gw
+	return bm
+}
+
+// SetBehaviors switches the bots to behaviour trees; nil brings back wandering.
+func (bm *BotManager) SetBehaviors(trees []*ai.Tree) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.trees = trees
+	bm.brains = make(map[uint32]*botBrain, len(bm.bots))
+}
+
+// SetNotifiers registers the join/leave broadcasts used for bots, the same ones
+// real players trigger on connect and disconnect.
+func (bm *BotManager) SetNotifiers(onJoin func(*types.Player), onLeave func(playerID uint32)) {
+	bm.onJoin = onJoin
+	bm.onLeave = onLeave
+}
+
+// Spawn adds up to n bots, limited by Game.BotMax. Returns how many were added.
+func (bm *BotManager) Spawn(n int) int {
+	bm.mu.Lock()
+	n = min(n, bm.maxBots-len(bm.bots), len(bm.freeIDs))
+	if n <= 0 {
+		bm.mu.Unlock()
+		return 0
+	}
+	spawned := make([]*types.Player, 0, n)
+	for i := 0; i < n; i++ {
+		id := bm.freeIDs[len(bm.freeIDs)-1]
+		bm.freeIDs = bm.freeIDs[:len(bm.freeIDs)-1]
+		player := bm.gw.addPlayer(id)
+		bm.bots = append(bm.bots, player)
+		spawned = append(spawned, player)
+	}
+	metrics.BotsActive.Set(float64(len(bm.bots)))
+	bm.mu.Unlock()
+
+	for _, p := range spawned {
+		if bm.onJoin != nil {
+			bm.onJoin(p)
+		}
+	}
+	slog.Info("bots spawned", "count", n)
+	return n
+}
+
+// Adopt takes over an imported bot player (see GameWorld.ImportState). Returns false if
+// p is not a bot, its ID is already in use or BotMax is reached; the player is left as is.
+func (bm *BotManager) Adopt(p *types.Player) bool {
+	if !IsBotID(p.ID) {
+		return false
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if len(bm.bots) >= bm.maxBots {
+		return false
+	}
+	for i, id := range bm.freeIDs {
+		if id == p.ID {
+			bm.freeIDs = append(bm.freeIDs[:i], bm.freeIDs[i+1:]...)
+			bm.bots = append(bm.bots, p)
+			metrics.BotsActive.Set(float64(len(bm.bots)))
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes up to n bots (the most recently spawned first); n <= 0 removes all.
+// Returns how many were removed.
+func (bm *BotManager) Remove(n int) int {
+	bm.mu.Lock()
+	if n <= 0 || n > len(bm.bots) {
+		n = len(bm.bots)
+	}
+	removed := make([]uint32, 0, n)
+	for i := 0; i < n; i++ {
+		b := bm.bots[len(bm.bots)-1]
+		bm.bots[len(bm.bots)-1] = nil
+		bm.bots = bm.bots[:len(bm.bots)-1]
+		bm.gw.RemovePlayer(b.ID)
+		delete(bm.brains, b.ID)
+		bm.freeIDs = append(bm.freeIDs, b.ID)
+		removed = append(removed, b.ID)
+	}
+	metrics.BotsActive.Set(float64(len(bm.bots)))
+	bm.mu.Unlock()
+
+	for _, id := range removed {
+		if bm.onLeave != nil {
+			bm.onLeave(id)
+		}
+	}
+	if n > 0 {
+		slog.Info("bots removed", "count", n)
+	}
+	return n
+}
+
+// Count returns the number of live bots.
+func (bm *BotManager) Count() int {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return len(bm.bots)
+}
+
+// Run drives bot behaviour until ctx is cancelled.
+func (bm *BotManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(bm.thinkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bm.think(time.Now().UnixNano())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// think refreshes every bot's activity (bots never send MOVE, so they must not trip
+// the input timeout) and re-rolls the wander vector of bots whose decision is due.
+func (bm *BotManager) think(nowNs int64) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	if len(bm.trees) > 0 {
+		for _, p := range bm.bots {
+			p.SetLastActivity(nowNs)
+		}
+		bm.runTrees(nowNs)
+		return
+	}
+	for _, p := range bm.bots {
+		p.SetLastActivity(nowNs)
+		if !canAct(p.GetState()) {
+			continue
+		}
+		ai := p.AI()
+		if nowNs < ai.GetNextDecision() && !bm.blockedByEdge(p) {
+			continue
+		}
+		ai.SetNextDecision(nowNs + botMinDecision.Nanoseconds() +
+			bm.rng.Int63n((botMaxDecision - botMinDecision).Nanoseconds()))
+
+		vx, vy := int8(0), int8(0)
+		if bm.rng.Float64() >= botIdleChance {
+			for vx == 0 && vy == 0 {
+				vx = int8(bm.rng.Intn(3) - 1)
+				vy = int8(bm.rng.Intn(3) - 1)
+			}
+			vx, vy = awayFromEdge(bm.gw, p, vx, vy)
+		}
+		p.SetVX(vx)
+		p.SetVY(vy)
+		if vx != 0 {
+			p.SetFacingRight(vx > 0)
+		}
+		if bm.rng.Float64() < botAttackRate {
+			bm.gw.TryAttack(p.ID)
+		}
+	}
+}
+
+// runTrees evaluates the behaviour trees of the bots due in this pass: every bot
+// whose ID falls on the pass modulo BOT_AI_EVERY, and those a previous pass owes.
+// A pass evaluates at least one tree, however small the budget.
+func (bm *BotManager) runTrees(nowNs int64) {
+	bm.pass++
+	n := len(bm.bots)
+	if bm.cursor >= n {
+		bm.cursor = 0
+	}
+	start, ran := time.Now(), 0
+	for i := range n {
+		idx := (bm.cursor + i) % n
+		p := bm.bots[idx]
+		brain := bm.brains[p.ID]
+		if brain == nil {
+			brain = new(botBrain)
+			bm.brains[p.ID] = brain
+		}
+		if !brain.owed && (bm.pass+uint64(p.ID))%bm.every != 0 {
+			continue
+		}
+		if bm.budget > 0 && ran > 0 && time.Since(start) > bm.budget {
+			bm.deferFrom(i)
+			bm.cursor = idx
+			return
+		}
+		brain.owed = false
+		if !canAct(p.GetState()) {
+			continue
+		}
+		bm.agent.p, bm.agent.brain, bm.agent.now = p, brain, nowNs
+		bm.trees[int(p.ID)%len(bm.trees)].Tick(&bm.agent, &brain.mem, nowNs)
+		ran++
+	}
+	bm.cursor = 0
+}
+
+// deferFrom marks the bots due in this pass from the i-th one after the cursor on
+// as owed to the next.
+func (bm *BotManager) deferFrom(i int) {
+	n := len(bm.bots)
+	for ; i < n; i++ {
+		p := bm.bots[(bm.cursor+i)%n]
+		brain := bm.brains[p.ID]
+		if brain == nil {
+			brain = new(botBrain)
+			bm.brains[p.ID] = brain
+		}
+		if brain.owed || (bm.pass+uint64(p.ID))%bm.every == 0 {
+			brain.owed = true
+			metrics.BotAIDeferred.Inc()
+		}
+	}
+}
+
+// botAgent is the bot a behaviour tree drives (ai.Agent).
+type botAgent struct {
+	gw    *GameWorld
+	p     *types.Player
+	brain *botBrain
+	now   int64
+	near  []uint32 // scratch for Nearest
+}
+
+func (a *botAgent) Position() (int, int) {
+	return int(a.p.GetX()), int(a.p.GetY())
+}
+
+func (a *botAgent) Health() (int, int) {
+	return int(a.p.Combat().GetHP()), a.gw.cfg.Game.MaxHP
+}
+
+// Nearest returns the nearest live real player; bots do not fight each other.
+func (a *botAgent) Nearest(radius int) (uint32, int, int, bool) {
+	a.near = a.gw.AppendPlayersNear(a.near[:0], a.p.ID, radius)
+	x, y := a.Position()
+	var (
+		best       uint32
+		bx, by, bd int
+	)
+	for _, id := range a.near {
+		if IsBotID(id) {
+			continue
+		}
+		px, py, ok := a.Locate(id)
+		if !ok {
+			continue
+		}
+		if d := (px-x)*(px-x) + (py-y)*(py-y); best == 0 || d < bd {
+			best, bx, by, bd = id, px, py, d
+		}
+	}
+	return best, bx, by, best != 0
+}
+
+func (a *botAgent) Locate(id uint32) (int, int, bool) {
+	p, ok := a.gw.player(id)
+	if !ok || p.GetState() == types.StateDead {
+		return 0, 0, false
+	}
+	return int(p.GetX()), int(p.GetY()), true
+}
+
+func (a *botAgent) Move(vx, vy int8) {
+	vx, vy = awayFromEdge(a.gw, a.p, vx, vy)
+	a.p.SetVX(vx)
+	a.p.SetVY(vy)
+	if vx != 0 {
+		a.p.SetFacingRight(vx > 0)
+	}
+}
+
+// MoveTo follows a path on the collision grid. The path is searched again when the goal
+// moves to another tile or it is botPathMaxAge old; without one the bot heads straight
+// for the goal.
+func (a *botAgent) MoveTo(x, y int) {
+	px, py := a.Position()
+	goal := worldmap.Point{X: uint32(max(x, 0)), Y: uint32(max(y, 0))}
+	b := a.brain
+	if m := a.gw.worldMap; m != nil && a.gw.paths != nil {
+		if b.pathAt == 0 || a.now-b.pathAt > botPathMaxAge.Nanoseconds() ||
+			goal.X/m.TileWidth != b.goal.X/m.TileWidth || goal.Y/m.TileHeight != b.goal.Y/m.TileHeight {
+			b.path, _ = a.gw.FindPath(worldmap.Point{X: uint32(px), Y: uint32(py)}, goal)
+			b.goal, b.pathAt = goal, a.now
+		}
+	}
+	for len(b.path) > 1 && ai.Toward(px, int(b.path[0].X)) == 0 && ai.Toward(py, int(b.path[0].Y)) == 0 {
+		b.path = b.path[1:]
+	}
+	if len(b.path) > 0 {
+		x, y = int(b.path[0].X), int(b.path[0].Y)
+	}
+	a.Move(ai.Toward(px, x), ai.Toward(py, y))
+}
+
+func (a *botAgent) Attack(x, _ int) bool {
+	if px := int(a.p.GetX()); x != px {
+		a.p.SetFacingRight(x > px)
+	}
+	_, _, accepted := a.gw.TryAttack(a.p.ID)
+	return accepted
+}
+
+// blockedByEdge reports whether p is pushing against a world boundary.
+func (bm *BotManager) blockedByEdge(p *types.Player) bool {
+	vx, vy := awayFromEdge(bm.gw, p, p.GetVX(), p.GetVY())
+	return vx != p.GetVX() || vy != p.GetVY()
+}
+
+// awayFromEdge turns vector components that point out of the world back inwards.
+func awayFromEdge(gw *GameWorld, p *types.Player, vx, vy int8) (int8, int8) {
+	w := gw.bounds.Load()
+	x, y := p.GetX(), p.GetY()
+	if (x <= w.MinX && vx < 0) || (x >= w.MaxX && vx > 0) {
+		vx = -vx
+	}
+	if (y <= w.MinY && vy < 0) || (y >= w.MaxY && vy > 0) {
+		vy = -vy
+	}
+	return vx, vy
+}
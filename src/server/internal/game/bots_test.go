@@ -0,0 +1,119 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/ai"
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/worldmap"
+)
+
+// newBotWorld returns a paused world with player 1001 and n bots around it, all
+// running the aggro behaviour.
+func newBotWorld(t *testing.T, n int, every int) *BotManager {
+	t.Helper()
+	return newBotWorldOn(t, nil, n, every, func(id uint32) (uint32, uint32) { return 1100, 1000 + id*10 })
+}
+
+func newBotWorldOn(t *testing.T, m *worldmap.Map, n int, every int, botAt func(id uint32) (x, y uint32)) *BotManager {
+	t.Helper()
+	cfg := config.Load()
+	cfg.Game.TickRate = 1
+	cfg.Game.SpawnProtection = 0
+	cfg.Game.WorldEvents = nil
+	cfg.Game.BotAIEvery = every
+	cfg.Game.BotAIBudget = 0
+	gw := NewGameWorld(cfg, m)
+	gw.SetPaused(true)
+	t.Cleanup(gw.Stop)
+
+	place := func(id uint32, x, y uint32) {
+		p, _ := gw.player(id)
+		p.Position().SetX(x)
+		p.Position().SetY(y)
+		gw.visibility.Load().MovePlayer(id, x, y)
+	}
+	gw.addPlayer(1001)
+	place(1001, 1000, 1000)
+	bm := NewBotManager(gw, cfg)
+	if got := bm.Spawn(n); got != n {
+		t.Fatalf("spawned %d bots, want %d", got, n)
+	}
+	for id := uint32(1); id <= uint32(n); id++ {
+		x, y := botAt(id)
+		place(id, x, y)
+	}
+
+	trees, err := ai.Parse([]byte(`{"behaviors": [{"name": "hunter", "root": {"type": "aggro", "radius": 1000}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm.SetBehaviors(trees)
+	return bm
+}
+
+// hunting returns the IDs of the bots that target player 1001.
+func hunting(bm *BotManager) map[uint32]bool {
+	got := make(map[uint32]bool)
+	for id, b := range bm.brains {
+		if b.mem.Target() == 1001 {
+			got[id] = true
+		}
+	}
+	return got
+}
+
+func TestBotTreesDecimated(t *testing.T) {
+	bm := newBotWorld(t, 4, 2)
+	now := time.Now().UnixNano()
+
+	bm.think(now)
+	if got := hunting(bm); len(got) != 2 || !got[1] || !got[3] {
+		t.Fatalf("after the first pass hunting %v, want bots 1 and 3", got)
+	}
+	bm.think(now)
+	if got := hunting(bm); len(got) != 4 {
+		t.Fatalf("after the second pass hunting %v, want all 4", got)
+	}
+	for _, p := range bm.bots {
+		if p.GetVX() != -1 {
+			t.Fatalf("bot %d moves %d along x, want towards the player", p.ID, p.GetVX())
+		}
+	}
+}
+
+func TestBotTreesBudget(t *testing.T) {
+	bm := newBotWorld(t, 4, 1)
+	bm.budget = time.Nanosecond // one tree per pass
+	now := time.Now().UnixNano()
+
+	for pass := 1; pass <= 4; pass++ {
+		bm.think(now)
+		if got := hunting(bm); len(got) != pass {
+			t.Fatalf("after pass %d hunting %v, want %d bots", pass, got, pass)
+		}
+	}
+}
+
+func TestBotPathAroundWall(t *testing.T) {
+	// A wall at x = 1280..1344 from the top down to y = 1984; the player is behind it.
+	m := &worldmap.Map{Width: 2560, Height: 2560, TileWidth: 64, TileHeight: 64, Cols: 40, Rows: 40}
+	for row := range 31 {
+		m.SetBlocked(20, row, true)
+	}
+	bm := newBotWorldOn(t, m, 1, 1, func(uint32) (uint32, uint32) { return 1600 + 32, 15*64 + 32 })
+	now := time.Now().UnixNano()
+
+	bm.think(now)
+	bot := bm.bots[0]
+	if got := hunting(bm); !got[bot.ID] {
+		t.Fatal("the bot did not pick the player behind the wall")
+	}
+	if bot.GetVY() != 1 {
+		t.Errorf("bot moves (%d,%d), want downwards round the end of the wall", bot.GetVX(), bot.GetVY())
+	}
+	if path := bm.brains[bot.ID].path; len(path) < 2 || path[len(path)-1] != (worldmap.Point{X: 1000, Y: 1000}) {
+		t.Errorf("bot path %v, want waypoints ending at the player", path)
+	}
+}
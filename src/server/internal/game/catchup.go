@@ -0,0 +1,132 @@
+package game
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Catch-up after slow ticks.
+//
+// Every tick interval of wall-clock time is owed one simulation step. A time.Ticker
+// drops the fires its reader misses, so gameLoop keeps its own accumulator
+// (tickClock) and, once awake, runs every step that is due back to back — up to
+// 1+Game.MaxCatchUpTicks of them. Steps beyond that are dropped: the simulation slows
+// down instead of spending ever longer on catching up (spiral of death).
+//
+// A catch-up step does less than a regular one (Degradation): only the last step of a
+// wake-up builds the snapshot and broadcasts, the ones before it just simulate. The
+// snapshot phase of the last step compares against the last broadcast state, so
+// deltas still carry every change of the skipped steps.
+
+// Degradation — how far behind gameLoop is, reported to the handler registered with
+// SetDegradationHandler whenever it changes.
+type Degradation int32
+
+const (
+	DegradeNone      Degradation = iota // on time
+	DegradeSkipSnaps                    // catching up: steps before the last skip snapshot and broadcast
+	DegradeDropTicks                    // more than MaxCatchUpTicks behind: overdue ticks dropped
+)
+
+type degradationFuncHolder struct {
+	fn func(Degradation)
+}
+
+// tickClock paces the simulation against the wall clock. gameLoop goroutine only.
+type tickClock struct {
+	interval   time.Duration
+	maxCatchUp int       // extra steps per wake-up
+	next       time.Time // when the next step is due
+}
+
+func newTickClock(interval time.Duration, maxCatchUp int, now time.Time) *tickClock {
+	return &tickClock{interval: interval, maxCatchUp: max(maxCatchUp, 0), next: now.Add(interval)}
+}
+
+// advance returns how many steps to run at now, how many overdue ones are dropped
+// and how far behind the wall clock the simulation was.
+func (c *tickClock) advance(now time.Time) (steps, dropped int, behind time.Duration) {
+	if now.Before(c.next) {
+		return 0, 0, 0
+	}
+	behind = now.Sub(c.next)
+	due := 1 + int(behind/c.interval)
+	steps = min(due, 1+c.maxCatchUp)
+	c.next = c.next.Add(time.Duration(due) * c.interval)
+	return steps, due - steps, behind
+}
+
+// reset forgives everything owed: after a pause the simulation resumes from now.
+func (c *tickClock) reset(now time.Time) {
+	c.next = now.Add(c.interval)
+}
+
+// SetDegradationHandler регистрирует функцию, вызываемую из gameLoop, когда меняется
+// Degradation; сервер по ней откладывает необязательную рассылку. Вызывается из
+// server.New() до первого тика.
+func (gw *GameWorld) SetDegradationHandler(fn func(Degradation)) {
+	gw.degradationFn.Store(degradationFuncHolder{fn: fn})
+}
+
+// Degradation возвращает текущий уровень деградации gameLoop.
+func (gw *GameWorld) Degradation() Degradation {
+	return Degradation(atomic.LoadInt32(&gw.degradation))
+}
+
+// runDueTicks runs the steps clock says are due at now; the last one publishes.
+// Returns the number of dropped steps.
+func (gw *GameWorld) runDueTicks(clock *tickClock, now time.Time) int {
+	steps, dropped, behind := clock.advance(now)
+	metrics.SimulationBehind.Set(behind.Seconds())
+	level := DegradeNone
+	switch {
+	case dropped > 0:
+		level = DegradeDropTicks
+		metrics.TicksDropped.Add(float64(dropped))
+	case steps > 1:
+		level = DegradeSkipSnaps
+	}
+	gw.setDegradation(level)
+	if steps > 1 {
+		metrics.TicksCaughtUp.Add(float64(steps - 1))
+	}
+	gw.runSteps(steps)
+	return dropped
+}
+
+// CatchUp выполняет n тиков подряд, как gameLoop после отставания: снапшот и broadcast
+// только у последнего. Как и Step — только на остановленном мире (testutil.World).
+func (gw *GameWorld) CatchUp(n int) {
+	if !gw.Paused() {
+		panic("game: CatchUp on a running world")
+	}
+	gw.runSteps(n)
+}
+
+// runSteps runs n ticks back to back; only the last one publishes.
+func (gw *GameWorld) runSteps(n int) {
+	for i := range n {
+		start := time.Now()
+		gw.tick(i == n-1)
+		if holder, ok := gw.postTickFn.Load().(postTickFuncHolder); ok {
+			holder.fn()
+		}
+		duration := time.Since(start)
+		atomic.StoreInt64(&gw.tickDuration, duration.Nanoseconds())
+		atomic.AddInt64(&gw.tickTime, duration.Nanoseconds())
+		metrics.TickDuration.Observe(duration.Seconds())
+		metrics.TicksTotal.Inc()
+	}
+}
+
+func (gw *GameWorld) setDegradation(level Degradation) {
+	if Degradation(atomic.SwapInt32(&gw.degradation, int32(level))) == level {
+		return
+	}
+	metrics.TickDegradation.Set(float64(level))
+	if holder, ok := gw.degradationFn.Load().(degradationFuncHolder); ok {
+		holder.fn(level)
+	}
+}
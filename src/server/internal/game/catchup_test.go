@@ -0,0 +1,34 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickClock(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	start := time.Unix(0, 0)
+	c := newTickClock(interval, 2, start)
+
+	for _, tt := range []struct {
+		at             time.Duration // since start
+		steps, dropped int
+	}{
+		{9 * time.Millisecond, 0, 0},   // woken early: nothing due yet
+		{10 * time.Millisecond, 1, 0},  // on time
+		{21 * time.Millisecond, 1, 0},  // a little late, still one step
+		{55 * time.Millisecond, 3, 0},  // 30, 40 and 50 were due: caught up
+		{125 * time.Millisecond, 3, 4}, // 60..120 due, only 3 run
+		{130 * time.Millisecond, 1, 0}, // the dropped ones are not owed any more
+	} {
+		steps, dropped, _ := c.advance(start.Add(tt.at))
+		if steps != tt.steps || dropped != tt.dropped {
+			t.Errorf("at %v: advance = (%d, %d), want (%d, %d)", tt.at, steps, dropped, tt.steps, tt.dropped)
+		}
+	}
+
+	c.reset(start.Add(time.Second))
+	if steps, _, _ := c.advance(start.Add(time.Second + 5*time.Millisecond)); steps != 0 {
+		t.Errorf("%d steps owed right after reset", steps)
+	}
+}
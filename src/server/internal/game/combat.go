@@ -0,0 +1,239 @@
+package game
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/types"
+)
+
+// Попадания атак. Сервер сам решает, кого задела атака: TryAttack только запускает её
+// и ставит атакующего в очередь, а gameLoop разбирает очередь в начале следующего
+// тика (после ввода и телепортов), пока tick worker'ы стоят — как applyTeleports.
+//
+//   - Зона удара — прямоугольник перед атакующим по направлению взгляда: AttackRange
+//     вперёд и по AttackRange/2 вверх и вниз. Задеты все игроки в ней, кроме самого
+//     атакующего, мёртвых, защищённых (спавн, неуязвимость) и своей команды.
+//   - Прицельная атака (ATTACK с aim) бьёт в секторе: не дальше AttackRange от
+//     атакующего и не больше AttackArc/2 градусов от направления прицела. Атакующий
+//     поворачивается в сторону прицела. Кандидаты в обоих случаях берутся из
+//     spatial grid, клиент только указывает направление — попадания решает сервер.
+//   - Каждый задетый теряет AttackDamage HP и отлетает на Knockback от атакующего
+//     (в границах мира; в стену — не отлетает) с коррекцией позиции, как при телепорте.
+//   - На 0 HP игрок умирает (Kill) и через RespawnDelay возрождается на месте с MaxHP.
+//
+// Атакующие разбираются по возрастанию ID, а жертвы каждого — тоже по ID, так что тот
+// же ввод даёт те же попадания. О каждом попадании узнаёт hitFn (сервер шлёт HIT
+// атакующему и жертве).
+
+// Hit — попадание атаки, уже применённое к жертве.
+type Hit struct {
+	AttackerID uint32
+	VictimID   uint32
+	Damage     uint16
+	HP         uint16 // HP жертвы после удара; 0 = убита
+	KnockbackX int8   // на сколько жертва сдвинута отбрасыванием
+	KnockbackY int8
+}
+
+// hitFuncHolder оборачивает обработчик попаданий для хранения в atomic.Value.
+type hitFuncHolder struct {
+	fn func(Hit)
+}
+
+// attackQueue — атаки, начатые с прошлого тика. spare — буфер прошлого тика (только gameLoop).
+type attackQueue struct {
+	mu      sync.Mutex
+	pending []queuedAttack
+	spare   []queuedAttack
+}
+
+// queuedAttack — начатая атака; aim (0, 0) — без прицела, по направлению взгляда.
+type queuedAttack struct {
+	playerID   uint32
+	aimX, aimY int8
+}
+
+func (a queuedAttack) aimed() bool {
+	return a.aimX != 0 || a.aimY != 0
+}
+
+// SetHitHandler регистрирует обработчик попаданий. Вызывается синхронно из gameLoop,
+// поэтому fn не должна блокироваться.
+func (gw *GameWorld) SetHitHandler(fn func(Hit)) {
+	gw.hitFn.Store(hitFuncHolder{fn: fn})
+}
+
+// queueAttack ставит начатую атаку на разбор в следующем тике.
+func (gw *GameWorld) queueAttack(attack queuedAttack) {
+	if gw.cfg.Game.AttackDamage <= 0 {
+		return
+	}
+	q := &gw.attacks
+	q.mu.Lock()
+	q.pending = append(q.pending, attack)
+	q.mu.Unlock()
+}
+
+// resolveAttacks находит и применяет попадания атак из очереди. gameLoop goroutine,
+// до фаз тика.
+func (gw *GameWorld) resolveAttacks() {
+	q := &gw.attacks
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = q.spare[:0]
+	q.mu.Unlock()
+	defer func() { q.spare = pending }()
+	if len(pending) == 0 {
+		return
+	}
+
+	// Стабильная сортировка: из нескольких атак игрока за тик разбирается первая.
+	slices.SortStableFunc(pending, func(a, b queuedAttack) int { return cmp.Compare(a.playerID, b.playerID) })
+	pending = slices.CompactFunc(pending, func(a, b queuedAttack) bool { return a.playerID == b.playerID })
+	holder, hasHitFn := gw.hitFn.Load().(hitFuncHolder)
+	var candidates []uint32
+	for _, attack := range pending {
+		attacker, ok := gw.player(attack.playerID)
+		if !ok || !canAct(attacker.GetState()) {
+			continue // ушёл или убит атакой с меньшим ID в этом же тике
+		}
+		if attack.aimX != 0 {
+			attacker.SetFacingRight(attack.aimX > 0)
+		}
+		minX, minY, maxX, maxY := gw.attackArea(attacker, attack)
+		candidates = gw.visibility.Load().AppendPlayersIn(candidates[:0], minX, minY, maxX, maxY)
+		slices.Sort(candidates)
+		for _, victimID := range candidates {
+			if victimID == attack.playerID {
+				continue
+			}
+			victim, ok := gw.player(victimID)
+			if !ok || !gw.canBeHit(attacker, victim) {
+				continue
+			}
+			if x, y := victim.GetX(), victim.GetY(); x < minX || x >= maxX || y < minY || y >= maxY {
+				continue
+			}
+			if attack.aimed() && !gw.inArc(attacker, victim, attack) {
+				continue
+			}
+			hit := gw.applyHit(attacker, victim)
+			if hasHitFn {
+				holder.fn(hit)
+			}
+		}
+	}
+}
+
+// attackArea — зона удара атакующего, [minX, maxX) × [minY, maxY), в границах мира.
+// Для прицельной атаки — квадрат вокруг атакующего, сектор в нём отбирает inArc.
+func (gw *GameWorld) attackArea(attacker *types.Player, attack queuedAttack) (minX, minY, maxX, maxY uint32) {
+	reach := int32(gw.cfg.Game.AttackRange)
+	x, y := int32(attacker.GetX()), int32(attacker.GetY())
+	x0, x1 := x-reach, x+1
+	y0, y1 := y-reach/2, y+reach/2+1
+	switch {
+	case attack.aimed():
+		x0, x1, y0, y1 = x-reach, x+reach+1, y-reach, y+reach+1
+	case attacker.GetFacingRight():
+		x0, x1 = x, x+reach+1
+	}
+	b := gw.bounds.Load()
+	clampX := func(v int32) uint32 { return uint32(min(max(v, int32(b.MinX)), int32(b.MaxX)+1)) }
+	clampY := func(v int32) uint32 { return uint32(min(max(v, int32(b.MinY)), int32(b.MaxY)+1)) }
+	return clampX(x0), clampY(y0), clampX(x1), clampY(y1)
+}
+
+// inArc — задевает ли прицельная атака victim: не дальше AttackRange и не больше
+// AttackArc/2 от направления прицела. Жертва в той же точке задета всегда.
+func (gw *GameWorld) inArc(attacker, victim *types.Player, attack queuedAttack) bool {
+	dx := float64(int32(victim.GetX()) - int32(attacker.GetX()))
+	dy := float64(int32(victim.GetY()) - int32(attacker.GetY()))
+	dist := math.Hypot(dx, dy)
+	if dist > float64(gw.cfg.Game.AttackRange) {
+		return false
+	}
+	if dist == 0 {
+		return true
+	}
+	ax, ay := float64(attack.aimX), float64(attack.aimY)
+	cos := (dx*ax + dy*ay) / (dist * math.Hypot(ax, ay))
+	return cos >= math.Cos(float64(gw.cfg.Game.AttackArc)*math.Pi/360)
+}
+
+// canBeHit — может ли атака attacker задеть victim.
+func (gw *GameWorld) canBeHit(attacker, victim *types.Player) bool {
+	combat := victim.Combat()
+	if combat.GetState() == types.StateDead || combat.GetInvulnerable() || combat.GetSpawnProtectedUntil() != 0 {
+		return false
+	}
+	if combat.GetSafeZone() || attacker.Combat().GetSafeZone() {
+		return false
+	}
+	team := attacker.GetTeam()
+	return team == 0 || team != victim.GetTeam()
+}
+
+// applyHit снимает с жертвы AttackDamage HP, отбрасывает её и убивает на 0 HP.
+func (gw *GameWorld) applyHit(attacker, victim *types.Player) Hit {
+	combat := victim.Combat()
+	damage := uint16(gw.cfg.Game.AttackDamage)
+	hp := combat.GetHP() - min(damage, combat.GetHP())
+	combat.SetHP(hp)
+	hit := Hit{AttackerID: attacker.ID, VictimID: victim.ID, Damage: damage, HP: hp}
+	hit.KnockbackX, hit.KnockbackY = gw.knockback(attacker, victim)
+	metrics.AttackHits.Inc()
+	if hp == 0 && gw.Kill(victim.ID) {
+		combat.SetRespawnAt(gw.tickNowNano + gw.cfg.Game.RespawnDelay.Nanoseconds())
+		metrics.PlayerKills.Inc()
+	}
+	return hit
+}
+
+// knockback сдвигает жертву на Knockback от атакующего (вдоль взгляда атакующего,
+// если они в одной точке). Возвращает фактический сдвиг.
+func (gw *GameWorld) knockback(attacker, victim *types.Player) (dx, dy int8) {
+	dist := float64(gw.cfg.Game.Knockback)
+	if dist == 0 {
+		return 0, 0
+	}
+	fromX, fromY := int32(victim.GetX()), int32(victim.GetY())
+	vx, vy := float64(fromX-int32(attacker.GetX())), float64(fromY-int32(attacker.GetY()))
+	length := math.Hypot(vx, vy)
+	if length == 0 {
+		vx, vy, length = 1, 0, 1
+		if !attacker.GetFacingRight() {
+			vx = -1
+		}
+	}
+	b := gw.bounds.Load()
+	x := uint32(min(max(fromX+int32(math.Round(vx/length*dist)), int32(b.MinX)), int32(b.MaxX)))
+	y := uint32(min(max(fromY+int32(math.Round(vy/length*dist)), int32(b.MinY)), int32(b.MaxY)))
+	if gw.worldMap != nil && gw.worldMap.Blocked(x, y) {
+		return 0, 0
+	}
+	victim.SetX(x)
+	victim.SetY(y)
+	victim.SetLastUpdate(gw.tickNowNano)
+	gw.visibility.Load().MovePlayer(victim.ID, x, y)
+	gw.updateViewport(victim)
+	if holder, ok := gw.inputTimeoutFn.Load().(inputTimeoutFuncHolder); ok {
+		holder.fn(victim.ID, types.FixedOf(x), types.FixedOf(y), victim.GetClientTick())
+	}
+	return int8(int32(x) - fromX), int8(int32(y) - fromY)
+}
+
+// respawn возрождает убитого игрока, когда подошло его RespawnAt. Tick worker, фаза input.
+func (gw *GameWorld) respawn(combat *types.Combat, nowNano int64) {
+	if at := combat.GetRespawnAt(); at == 0 || nowNano < at {
+		return
+	}
+	if transition(combat, types.StateDead, types.StateIdle) {
+		combat.SetHP(uint16(gw.cfg.Game.MaxHP))
+	}
+	combat.SetRespawnAt(0)
+}
@@ -0,0 +1,73 @@
+package game
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/types"
+)
+
+// Ввод клиентов (MOVE, FACE) не применяется в момент, когда read-воркер его
+// декодировал: ProcessEvent кладёт его в буфер, а gameLoop применяет буфер в начале
+// следующего тика, пока tick worker'ы стоят. Порядок применения — по PlayerID, а для
+// одного игрока — в порядке прихода (соединение читает один воркер, см. ProcessEvent),
+// поэтому результат тика не зависит от планировщика горутин и тот же ввод даёт ту же
+// симуляцию при повторе. Атаки и операции администратора остаются синхронными: им
+// нужен ответ сразу (TryAttack, ApplyAdminEvent).
+
+// maxStagedInputs ограничивает буфер между тиками. Обычно в нём не больше пары
+// сообщений на игрока, но на паузе ввод копится до возобновления симуляции.
+const maxStagedInputs = 1 << 16
+
+// inputQueue — ввод до начала следующего тика. spare — буфер прошлого тика (только
+// gameLoop), переиспользуется, чтобы горячий путь не аллоцировал.
+type inputQueue struct {
+	mu      sync.Mutex
+	pending []types.GameEvent
+	spare   []types.GameEvent
+}
+
+// stagesInput сообщает, копится ли событие до тика (ввод клиента).
+func stagesInput(t types.EventType) bool {
+	return t == types.EventMove || t == types.EventFace
+}
+
+// stageInput добавляет ввод в буфер следующего тика.
+func (gw *GameWorld) stageInput(event types.GameEvent) {
+	q := &gw.inputs
+	q.mu.Lock()
+	if len(q.pending) >= maxStagedInputs {
+		q.mu.Unlock()
+		metrics.InputsDropped.Inc()
+		return
+	}
+	q.pending = append(q.pending, event)
+	q.mu.Unlock()
+}
+
+// applyInputs применяет накопленный ввод. gameLoop goroutine, до фаз тика: фаза input
+// уже видит новые векторы, а проверки (заморожен, оглушён) делаются на момент тика.
+func (gw *GameWorld) applyInputs() {
+	q := &gw.inputs
+	q.mu.Lock()
+	staged := q.pending
+	q.pending = q.spare[:0]
+	q.mu.Unlock()
+	if len(staged) == 0 {
+		q.spare = staged
+		return
+	}
+
+	// Стабильная сортировка сохраняет порядок прихода внутри одного игрока.
+	slices.SortStableFunc(staged, func(a, b types.GameEvent) int { return cmp.Compare(a.PlayerID, b.PlayerID) })
+	gw.playersMu.RLock()
+	for i := range staged {
+		if player, ok := gw.playersMap[staged[i].PlayerID]; ok {
+			gw.applyEvent(player, staged[i])
+		}
+	}
+	gw.playersMu.RUnlock()
+	q.spare = staged
+}
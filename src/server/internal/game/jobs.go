@@ -0,0 +1,121 @@
+package game
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"pixi_game_server/internal/ecs"
+)
+
+// Tick job system.
+//
+// A tick runs as a sequence of phases (tick.go); each phase is split into independent
+// tasks — chunks of entity rows, or region shards — that persistent worker goroutines
+// pull from a shared counter, so a worker that finishes its chunk early takes the next
+// one instead of idling behind a crowded chunk. run returns only when every task of
+// the phase is done: that is the barrier between phases, and what lets a phase read
+// whatever the previous one wrote without further synchronisation.
+//
+// Workers are created once (pattern from nbio TaskPool / nakama runtime pool); a phase
+// costs one channel send per active worker and a WaitGroup wait, and allocates nothing:
+// the job is reused from phase to phase.
+
+// tickJob — одна фаза тика: tasks задач, которые воркеры разбирают по счётчику next.
+// Задача — вызов fn, либо (rowFn != nil) чанк строк.
+type tickJob struct {
+	tasks int32
+	next  atomic.Int32
+	fn    func(worker, task int)
+	rowFn func(worker int, first, last ecs.Row)
+	rows  ecs.Row
+	chunk int
+}
+
+// jobSystem — пул tick worker'ов.
+type jobSystem struct {
+	chs []chan *tickJob
+	wg  sync.WaitGroup
+	job tickJob // текущая фаза; переиспользуется
+}
+
+// newJobSystem запускает n воркеров (минимум один).
+func newJobSystem(n int) *jobSystem {
+	js := &jobSystem{chs: make([]chan *tickJob, max(n, 1))}
+	for i := range js.chs {
+		// Buffered=1 so gameLoop never blocks on dispatch.
+		ch := make(chan *tickJob, 1)
+		js.chs[i] = ch
+		go js.worker(i, ch)
+	}
+	return js
+}
+
+// workers — число воркеров; индексы worker в fn лежат в [0, workers).
+func (js *jobSystem) workers() int {
+	return len(js.chs)
+}
+
+func (js *jobSystem) worker(index int, ch chan *tickJob) {
+	for job := range ch {
+		job.drain(index)
+		js.wg.Done()
+	}
+}
+
+// drain выполняет задачи job, пока они не кончатся.
+func (job *tickJob) drain(worker int) {
+	for {
+		task := job.next.Add(1) - 1
+		if task >= job.tasks {
+			return
+		}
+		if job.rowFn == nil {
+			job.fn(worker, int(task))
+			continue
+		}
+		first := ecs.Row(int(task) * job.chunk)
+		job.rowFn(worker, first, min(first+ecs.Row(job.chunk), job.rows))
+	}
+}
+
+// run выполняет tasks задач fn на воркерах и ждёт все (барьер фазы). Одна задача
+// выполняется в вызывающей горутине как воркер 0. Только gameLoop горутина.
+func (js *jobSystem) run(tasks int, fn func(worker, task int)) {
+	js.job.fn, js.job.rowFn = fn, nil
+	js.dispatch(tasks)
+}
+
+// runRows делит строки [0, rows) на чанки по chunk строк и выполняет fn над каждым.
+func (js *jobSystem) runRows(rows ecs.Row, chunk int, fn func(worker int, first, last ecs.Row)) {
+	chunk = max(chunk, 1)
+	js.job.fn, js.job.rowFn = nil, fn
+	js.job.rows, js.job.chunk = rows, chunk
+	js.dispatch((int(rows) + chunk - 1) / chunk)
+}
+
+func (js *jobSystem) dispatch(tasks int) {
+	if tasks <= 0 {
+		return
+	}
+	job := &js.job
+	job.tasks = int32(tasks)
+	job.next.Store(0)
+	if tasks == 1 {
+		job.drain(0)
+		return
+	}
+	active := min(tasks, len(js.chs))
+	// Add BEFORE any send — prevents Done() racing ahead of Add().
+	js.wg.Add(active)
+	for _, ch := range js.chs[:active] {
+		ch <- job
+	}
+	js.wg.Wait()
+}
+
+// stop завершает воркеров.
+func (js *jobSystem) stop() {
+	for _, ch := range js.chs {
+		close(ch)
+	}
+}
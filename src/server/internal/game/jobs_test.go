@@ -0,0 +1,48 @@
+package game
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"pixi_game_server/internal/ecs"
+)
+
+func TestJobSystemRunsEveryRowOnce(t *testing.T) {
+	js := newJobSystem(4)
+	defer js.stop()
+
+	for _, tc := range []struct {
+		rows  ecs.Row
+		chunk int
+	}{{0, 16}, {1, 16}, {16, 16}, {17, 16}, {1000, 7}, {1000, 0}} {
+		seen := make([]atomic.Int32, tc.rows)
+		js.runRows(tc.rows, tc.chunk, func(worker int, first, last ecs.Row) {
+			if worker < 0 || worker >= js.workers() {
+				t.Errorf("worker index %d out of range", worker)
+			}
+			for r := first; r < last; r++ {
+				seen[r].Add(1)
+			}
+		})
+		// runRows returned: the barrier guarantees every chunk is done.
+		for r := range seen {
+			if n := seen[r].Load(); n != 1 {
+				t.Fatalf("rows=%d chunk=%d: row %d visited %d times", tc.rows, tc.chunk, r, n)
+			}
+		}
+	}
+}
+
+func TestJobSystemRunTasks(t *testing.T) {
+	js := newJobSystem(3)
+	defer js.stop()
+
+	var sum atomic.Int64
+	for range 100 { // the job is reused from phase to phase
+		sum.Store(0)
+		js.run(10, func(_, task int) { sum.Add(int64(task)) })
+		if got := sum.Load(); got != 45 {
+			t.Fatalf("sum of tasks = %d, want 45", got)
+		}
+	}
+}
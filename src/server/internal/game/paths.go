@@ -0,0 +1,53 @@
+package game
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/pathfind"
+	"pixi_game_server/internal/worldmap"
+)
+
+// Pathfinding on the collision grid of the world map (internal/pathfind), for bots
+// with behaviour trees and /debug/path. An open world, or a map without a collision
+// layer, has no finder: every path is a straight line.
+
+func (gw *GameWorld) initPaths() {
+	m := gw.worldMap
+	if m == nil || !m.HasCollision() {
+		return
+	}
+	start := time.Now()
+	gw.paths = pathfind.New(m)
+	slog.Info("pathfinding grid ready",
+		"tiles", m.Cols*m.Rows,
+		"hierarchical", gw.paths.Hierarchical(),
+		"entrances", gw.paths.Entrances(),
+		"took_ms", time.Since(start).Milliseconds())
+}
+
+// FindPath returns the waypoints from `from` to `to` (pathfind.Finder.Path). Safe for
+// concurrent use.
+func (gw *GameWorld) FindPath(from, to worldmap.Point) ([]worldmap.Point, error) {
+	if gw.paths == nil {
+		return []worldmap.Point{to}, nil
+	}
+	start := time.Now()
+	path, err := gw.paths.Path(from, to)
+	result := "found"
+	switch {
+	case errors.Is(err, pathfind.ErrBlocked):
+		result = "blocked"
+	case err != nil:
+		result = "no_path"
+	}
+	metrics.PathSearchSeconds.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return path, err
+}
+
+// PathsHierarchical reports whether paths are searched over clusters of the map.
+func (gw *GameWorld) PathsHierarchical() bool {
+	return gw.paths != nil && gw.paths.Hierarchical()
+}
@@ -0,0 +1,105 @@
+package game
+
+import (
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/types"
+)
+
+// Физика движения.
+//
+// Movement is a fixed-timestep integrator run by the movement phase, the time step
+// being the tick. Each tick the velocity of each axis approaches its target — the input
+// direction VX/VY times its analog throttle (1..127 of 127) times the player speed —
+// and the position moves by the new velocity. The velocity speeds up by
+// PlayerAcceleration and slows down (to a lower target, a stop or a turn) by
+// PlayerFriction; a rule of 0 reaches the target at once. Velocity is kept in 1/SubUnits
+// of a unit per tick and positions in types.Fixed, so slow players still move.
+//
+// With both rules 0 (the default) and digital input, this is the old fixed step:
+// PlayerSpeedPerTick along each pressed axis, a stop on release.
+
+// physics — правила интегратора в 1/SubUnits единицы за тик².
+type physics struct {
+	accel    int32 // 0 = цель достигается сразу
+	friction int32
+}
+
+// newPhysics converts the units/s² of cfg into per-tick steps; a configured rule never
+// rounds down to 0 (instant).
+func newPhysics(cfg *config.GameConfig) physics {
+	rate := int64(max(cfg.TickRate, 1))
+	perTick := func(unitsPerSec2 int) int32 {
+		if unitsPerSec2 <= 0 {
+			return 0
+		}
+		return int32(max(int64(unitsPerSec2)*types.SubUnits/(rate*rate), 1))
+	}
+	return physics{accel: perTick(cfg.PlayerAcceleration), friction: perTick(cfg.PlayerFriction)}
+}
+
+// approach returns velocity v one tick closer to target.
+func (ph physics) approach(v, target int32) int32 {
+	step := ph.friction
+	if target != 0 && (v == 0 || (v > 0) == (target > 0)) && abs32(target) > abs32(v) {
+		step = ph.accel
+	}
+	if step == 0 {
+		return target
+	}
+	if v < target {
+		return min(v+step, target)
+	}
+	return max(v-step, target)
+}
+
+// stepAxis advances one axis by a tick: velocity v approaches target, and position p
+// moves by it, clamped to [lo, hi]. A bound stops the axis.
+func (ph physics) stepAxis(p types.Fixed, v, target int32, lo, hi uint32) (types.Fixed, int32) {
+	v = ph.approach(v, target)
+	at := int64(p) + int64(v)
+	switch {
+	case at < int64(types.FixedOf(lo)):
+		return types.FixedOf(lo), 0
+	case at > int64(types.FixedOf(hi)):
+		return types.FixedOf(hi), 0
+	}
+	return types.Fixed(at), v
+}
+
+// targetVelocity — целевая скорость оси с направлением dir (-1, 0, 1) и величиной
+// throttle (1..127) при скорости игрока speed, в 1/SubUnits за тик.
+func targetVelocity(dir int8, throttle, speed int32) int32 {
+	return int32(dir) * throttle * speed * types.SubUnits / 127
+}
+
+// PredictMove returns where player stands after the next tick if it moves with
+// direction dx, dy and throttles tx, ty (0 = full): the position MOVEMENT_ACK confirms.
+// The movement phase integrates the same way, and only map collision can differ.
+func (gw *GameWorld) PredictMove(player *types.Player, dx, dy int8, tx, ty uint8) (x, y types.Fixed) {
+	pos, vel := player.Position(), player.Velocity()
+	x, y = pos.GetFixed()
+	if player.Combat().GetFrozen() {
+		return x, y
+	}
+	speed := gw.moveSpeed(vel)
+	vx, vy := vel.GetSubV()
+	b := gw.bounds.Load()
+	x, _ = gw.physics.stepAxis(x, vx, targetVelocity(dx, throttle(tx), speed), b.MinX, b.MaxX)
+	y, _ = gw.physics.stepAxis(y, vy, targetVelocity(dy, throttle(ty), speed), b.MinY, b.MaxY)
+	return x, y
+}
+
+// throttle — величина оси 1..127 из поля Throttle (0 = полная).
+func throttle(t uint8) int32 {
+	if t == 0 {
+		return 127
+	}
+	return int32(t)
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
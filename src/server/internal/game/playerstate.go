@@ -0,0 +1,234 @@
+package game
+
+import (
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Серверная машина состояний игрока (types.State*):
+//
+//	idle ⇄ moving                      — по вектору движения, в tick worker'е (settleState)
+//	idle/moving → attacking → idle/moving — TryAttack; конец по ATTACK_END или через AttackDuration
+//	idle/moving/attacking → stunned → idle/moving — Stun; конец по StunnedUntil
+//	любое → dead → idle                — Kill (или 0 HP, combat.go) / Revive (или RespawnDelay)
+//
+// Все переходы — CAS по State с проверкой CanTransition, поэтому гонка epoll-потока
+// (ATTACK_END) с tick worker'ом (истечение атаки) не приводит к запрещённому переходу:
+// проигравший CAS просто не применяется. Состояние уходит клиентам младшими битами flags.
+
+const numPlayerStates = types.StateDead + 1
+
+var playerStateNames = [numPlayerStates]string{
+	types.StateIdle:      "idle",
+	types.StateAttacking: "attacking",
+	types.StateMoving:    "moving",
+	types.StateStunned:   "stunned",
+	types.StateDead:      "dead",
+}
+
+func stateBit(s uint8) uint8 { return 1 << s }
+
+// allowedTransitions[from] — битовая маска состояний, в которые можно перейти из from.
+// attacking → attacking — повторная атака после cooldown, пока tick ещё не снял прошлую.
+var allowedTransitions = [numPlayerStates]uint8{
+	types.StateIdle:      stateBit(types.StateMoving) | stateBit(types.StateAttacking) | stateBit(types.StateStunned) | stateBit(types.StateDead),
+	types.StateMoving:    stateBit(types.StateIdle) | stateBit(types.StateAttacking) | stateBit(types.StateStunned) | stateBit(types.StateDead),
+	types.StateAttacking: stateBit(types.StateIdle) | stateBit(types.StateMoving) | stateBit(types.StateAttacking) | stateBit(types.StateStunned) | stateBit(types.StateDead),
+	types.StateStunned:   stateBit(types.StateIdle) | stateBit(types.StateMoving) | stateBit(types.StateStunned) | stateBit(types.StateDead),
+	types.StateDead:      stateBit(types.StateIdle),
+}
+
+// CanTransition сообщает, разрешён ли переход from → to.
+func CanTransition(from, to uint8) bool {
+	return from < numPlayerStates && to < numPlayerStates && allowedTransitions[from]&stateBit(to) != 0
+}
+
+// StateName возвращает имя состояния для метрик и JSON ("idle", "moving", ...).
+func StateName(s uint8) string {
+	if s < numPlayerStates {
+		return playerStateNames[s]
+	}
+	return "unknown"
+}
+
+// transition переводит c из from в to. false — переход запрещён или состояние уже не from.
+func transition(c *types.Combat, from, to uint8) bool {
+	if !CanTransition(from, to) {
+		metrics.PlayerStateRejected.WithLabelValues(StateName(from), StateName(to)).Inc()
+		return false
+	}
+	return c.CompareAndSwapState(from, to)
+}
+
+// canAct — может ли игрок в состоянии s двигаться и атаковать.
+func canAct(s uint8) bool {
+	return s != types.StateStunned && s != types.StateDead
+}
+
+// Rejection — почему действие игрока не принято (protocol.Reject*) и через сколько его
+// имеет смысл повторить (0 = неизвестно). Нулевое значение — действие принято.
+type Rejection struct {
+	Reason     uint8
+	RetryAfter time.Duration
+}
+
+// Rejected сообщает, отказано ли в действии.
+func (r Rejection) Rejected() bool {
+	return r.Reason != 0
+}
+
+// startAttack запускает атаку, если cooldown прошёл и состояние это позволяет;
+// aim (0, 0) — без прицела.
+func (gw *GameWorld) startAttack(player *types.Player, now int64, aimX, aimY int8) Rejection {
+	combat := player.Combat()
+	if combat.GetFrozen() {
+		return Rejection{Reason: protocol.RejectFrozen}
+	}
+	if combat.GetSafeZone() {
+		return Rejection{Reason: protocol.RejectSafeZone}
+	}
+	start := combat.GetAttackStartTime()
+	if cooldown := gw.cfg.Game.AttackDuration.Nanoseconds(); start > 0 && now-start < cooldown {
+		return Rejection{Reason: protocol.RejectCooldown, RetryAfter: time.Duration(start + cooldown - now)}
+	}
+	from := combat.GetState()
+	if !CanTransition(from, types.StateAttacking) {
+		metrics.PlayerStateRejected.WithLabelValues(StateName(from), StateName(types.StateAttacking)).Inc()
+		return stateRejection(combat, from, now)
+	}
+	// Время старта пишется до CAS: tick worker, увидев attacking, сразу читает его.
+	combat.SetAttackStartTime(now)
+	if !combat.CompareAndSwapState(from, types.StateAttacking) {
+		combat.SetAttackStartTime(start)
+		return stateRejection(combat, combat.GetState(), now)
+	}
+	metrics.EventsProcessed.WithLabelValues("attack").Inc()
+	gw.queueAttack(queuedAttack{playerID: player.ID, aimX: aimX, aimY: aimY})
+	return Rejection{}
+}
+
+// stateRejection — отказ в атаке игроку в состоянии state: до конца оглушения, до
+// возрождения, а проигравшему гонку за State — повторить сразу.
+func stateRejection(combat *types.Combat, state uint8, now int64) Rejection {
+	switch state {
+	case types.StateStunned:
+		return Rejection{Reason: protocol.RejectStunned, RetryAfter: time.Duration(max(combat.GetStunnedUntil()-now, 0))}
+	case types.StateDead:
+		var wait time.Duration
+		if at := combat.GetRespawnAt(); at > 0 {
+			wait = time.Duration(max(at-now, 0))
+		}
+		return Rejection{Reason: protocol.RejectDead, RetryAfter: wait}
+	default:
+		return Rejection{Reason: protocol.RejectCooldown}
+	}
+}
+
+// EndAttack завершает атаку по ATTACK_END клиента (анимация доиграна). Cooldown
+// по-прежнему отсчитывается от начала атаки, так что ранний ATTACK_END не ускоряет
+// следующую. Возвращает false, если игрок не атакует.
+func (gw *GameWorld) EndAttack(playerID uint32) bool {
+	player, ok := gw.player(playerID)
+	if !ok {
+		return false
+	}
+	if !transition(player.Combat(), types.StateAttacking, restingState(player.Velocity())) {
+		return false
+	}
+	metrics.EventsProcessed.WithLabelValues("attack_end").Inc()
+	return true
+}
+
+// Stun оглушает игрока на d: он останавливается и не принимает MOVE/ATTACK до конца.
+// Повторное оглушение продлевает его. false — игрока нет, он мёртв или неуязвим.
+func (gw *GameWorld) Stun(playerID uint32, d time.Duration) bool {
+	player, ok := gw.player(playerID)
+	if !ok || player.Combat().GetInvulnerable() {
+		return false
+	}
+	from := player.GetState()
+	if !CanTransition(from, types.StateStunned) {
+		metrics.PlayerStateRejected.WithLabelValues(StateName(from), StateName(types.StateStunned)).Inc()
+		return false
+	}
+	player.SetStunnedUntil(time.Now().Add(d).UnixNano())
+	if !player.CompareAndSwapState(from, types.StateStunned) {
+		return false
+	}
+	player.SetVX(0)
+	player.SetVY(0)
+	return true
+}
+
+// Kill переводит игрока в dead: он стоит на месте до Revive. Неуязвимого — нет.
+func (gw *GameWorld) Kill(playerID uint32) bool {
+	player, ok := gw.player(playerID)
+	if !ok || player.Combat().GetInvulnerable() {
+		return false
+	}
+	for {
+		from := player.GetState()
+		if from == types.StateDead {
+			return false
+		}
+		if transition(player.Combat(), from, types.StateDead) {
+			break
+		}
+	}
+	player.SetVX(0)
+	player.SetVY(0)
+	player.SetStunnedUntil(0)
+	return true
+}
+
+// Revive возвращает мёртвого игрока в idle с полным HP.
+func (gw *GameWorld) Revive(playerID uint32) bool {
+	player, ok := gw.player(playerID)
+	if !ok || !transition(player.Combat(), types.StateDead, types.StateIdle) {
+		return false
+	}
+	player.Combat().SetRespawnAt(0)
+	player.Combat().SetHP(uint16(gw.cfg.Game.MaxHP))
+	return true
+}
+
+// settleState применяет переходы по времени и скорости: конец атаки через
+// AttackDuration, конец оглушения, idle ⇄ moving. Вызывается tick worker'ом до
+// обновления позиции.
+func settleState(combat *types.Combat, vel *types.Velocity, nowNano, attackDurNano int64) {
+	state := combat.GetState()
+	switch state {
+	case types.StateAttacking:
+		if start := combat.GetAttackStartTime(); start > 0 && nowNano-start < attackDurNano {
+			return
+		}
+	case types.StateStunned:
+		if nowNano < combat.GetStunnedUntil() {
+			return
+		}
+		combat.SetStunnedUntil(0)
+	case types.StateDead:
+		return
+	}
+	if next := restingState(vel); next != state {
+		transition(combat, state, next)
+	}
+}
+
+// restingState — idle или moving, смотря по вектору движения.
+func restingState(vel *types.Velocity) uint8 {
+	if vel.Moving() {
+		return types.StateMoving
+	}
+	return types.StateIdle
+}
+
+func (gw *GameWorld) player(playerID uint32) (*types.Player, bool) {
+	gw.playersMu.RLock()
+	player, ok := gw.playersMap[playerID]
+	gw.playersMu.RUnlock()
+	return player, ok
+}
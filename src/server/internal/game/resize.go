@@ -0,0 +1,189 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/systems"
+)
+
+// World resizing at runtime (admin API /admin/world).
+//
+// The world size from config is only the starting one: Resize grows or shrinks the
+// world between two ticks. The visibility grid is rebuilt for the new size (cells of a
+// shrunk-away region are dropped with the old grid), region shards are re-split and
+// the spawn area and safe zones are clamped into the new bounds. Players left outside are moved
+// inside — clamped to the nearest edge or respawned — and get the same position
+// correction as an input timeout. Zone metrics keep the startup layout: their labels
+// are Prometheus series, and points past the old size fall into the edge zones.
+//
+// A loaded map fixes the world size, so Resize refuses to run with one. Nor does it grow
+// the world past what wire positions with Net.PositionFractionBits can reach.
+
+// Minimum world side: one visibility cell.
+const minWorldSize = visibilityCellSize
+
+// Relocation of players outside a shrunk world (Resize relocate argument).
+const (
+	RelocateClamp = "clamp" // to the nearest point inside the new bounds
+	RelocateSpawn = "spawn" // to a random spawn point
+)
+
+// ErrResizeMap — размер мира задан загруженной картой.
+var ErrResizeMap = errors.New("world size is fixed by the loaded map")
+
+// Bounds — текущие размеры мира, границы движения и зона спавна.
+type Bounds struct {
+	Width     uint32 `json:"width"`
+	Height    uint32 `json:"height"`
+	MinX      uint32 `json:"min_x"`
+	MaxX      uint32 `json:"max_x"`
+	MinY      uint32 `json:"min_y"`
+	MaxY      uint32 `json:"max_y"`
+	SpawnMinX uint32 `json:"spawn_min_x"`
+	SpawnMaxX uint32 `json:"spawn_max_x"`
+	SpawnMinY uint32 `json:"spawn_min_y"`
+	SpawnMaxY uint32 `json:"spawn_max_y"`
+}
+
+// configBounds returns the startup bounds from config.
+func configBounds(cfg *config.WorldConfig) *Bounds {
+	return &Bounds{
+		Width: cfg.Width, Height: cfg.Height,
+		MinX: cfg.MinX, MaxX: cfg.MaxX, MinY: cfg.MinY, MaxY: cfg.MaxY,
+		SpawnMinX: cfg.SpawnMinX, SpawnMaxX: cfg.SpawnMaxX, SpawnMinY: cfg.SpawnMinY, SpawnMaxY: cfg.SpawnMaxY,
+	}
+}
+
+// resizedBounds returns the bounds of a width×height world: movement up to the new
+// edge (as config.Load derives MaxX/MaxY from the size) and the configured spawn area
+// clamped into it.
+func resizedBounds(cfg *config.WorldConfig, width, height uint32) *Bounds {
+	b := &Bounds{
+		Width:  width,
+		Height: height,
+		MinX:   min(cfg.MinX, width-1),
+		MaxX:   width,
+		MinY:   min(cfg.MinY, height-1),
+		MaxY:   height,
+	}
+	// The spawn range stays at least one unit wide (randomPointIn needs a non-empty range).
+	b.SpawnMaxX = max(min(cfg.SpawnMaxX, b.MaxX), b.MinX+1)
+	b.SpawnMinX = min(max(cfg.SpawnMinX, b.MinX), b.SpawnMaxX-1)
+	b.SpawnMaxY = max(min(cfg.SpawnMaxY, b.MaxY), b.MinY+1)
+	b.SpawnMinY = min(max(cfg.SpawnMinY, b.MinY), b.SpawnMaxY-1)
+	return b
+}
+
+// contains reports whether (x, y) is inside the movement bounds.
+func (b *Bounds) contains(x, y uint32) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY
+}
+
+// clamp moves (x, y) to the nearest point inside the movement bounds.
+func (b *Bounds) clamp(x, y uint32) (uint32, uint32) {
+	return min(max(x, b.MinX), b.MaxX), min(max(y, b.MinY), b.MaxY)
+}
+
+// ResizeResult — итог Resize: новые границы и перемещённые игроки.
+type ResizeResult struct {
+	Bounds    Bounds   `json:"bounds"`
+	Relocated []uint32 `json:"relocated"`
+}
+
+// resizeRequest — запрос Resize в gameLoop.
+type resizeRequest struct {
+	width, height uint32
+	relocate      string
+	done          chan ResizeResult
+}
+
+// Bounds возвращает текущие границы мира.
+func (gw *GameWorld) Bounds() Bounds {
+	return *gw.bounds.Load()
+}
+
+// Resize меняет размер мира. Выполняется в gameLoop между тиками (и на остановленном
+// мире); возвращается, когда новые границы применены.
+func (gw *GameWorld) Resize(width, height uint32, relocate string) (ResizeResult, error) {
+	if gw.worldMap != nil {
+		return ResizeResult{}, ErrResizeMap
+	}
+	if width < minWorldSize || height < minWorldSize {
+		return ResizeResult{}, fmt.Errorf("world must be at least %d×%d", minWorldSize, minWorldSize)
+	}
+	if max(width, height) > config.MaxWorldSize {
+		return ResizeResult{}, fmt.Errorf("world must be at most %d units a side", config.MaxWorldSize)
+	}
+	// Origin-relative positions (config.LargeWorld) are set up per connection at join,
+	// so a resize must not switch them on or off.
+	b, bits := gw.bounds.Load(), gw.cfg.Net.PositionFractionBits
+	if config.LargeWorld(width, height, bits) != config.LargeWorld(b.Width, b.Height, bits) {
+		return ResizeResult{}, fmt.Errorf("a resize cannot cross %d units a side with POSITION_FRACTION_BITS=%d (origin-relative positions); restart the server instead",
+			config.WireSpan(bits), bits)
+	}
+	if relocate != RelocateClamp && relocate != RelocateSpawn {
+		return ResizeResult{}, fmt.Errorf("unknown relocate mode %q", relocate)
+	}
+	req := resizeRequest{width: width, height: height, relocate: relocate, done: make(chan ResizeResult, 1)}
+	select {
+	case gw.resizeReq <- req:
+	case <-gw.stopChan:
+		return ResizeResult{}, errors.New("world stopped")
+	}
+	return <-req.done, nil
+}
+
+// resize applies req. gameLoop goroutine only, between ticks: tick workers are idle,
+// so the grid and shards can be replaced; joins and leaves are excluded by playersMu.
+func (gw *GameWorld) resize(req resizeRequest) ResizeResult {
+	b := resizedBounds(&gw.cfg.World, req.width, req.height)
+	vm := systems.NewVisibilityManager(b.Width, b.Height, visibilityCellSize)
+	result := ResizeResult{Bounds: *b}
+
+	gw.playersMu.Lock()
+	gw.bounds.Store(b)
+	for _, player := range gw.playersMap {
+		x, y := player.GetX(), player.GetY()
+		if !b.contains(x, y) {
+			if req.relocate == RelocateSpawn {
+				x, y = gw.pickSpawnPoint(player.GetTeam())
+			} else {
+				x, y = b.clamp(x, y)
+			}
+			player.SetX(x)
+			player.SetY(y)
+			result.Relocated = append(result.Relocated, player.ID)
+		}
+		vm.AddPlayer(player.ID, x, y)
+		w, h := player.GetViewSize()
+		player.SetViewSize(uint16(min(uint32(w), b.Width)), uint16(min(uint32(h), b.Height)))
+		gw.updateViewport(player)
+	}
+	gw.visibility.Store(vm)
+	gw.playersMu.Unlock()
+	gw.initSafeZones()
+
+	if gw.cfg.Game.RegionSharding {
+		gw.initRegionShards()
+	}
+
+	// Relocated players get the same correction as an input timeout.
+	if holder, ok := gw.inputTimeoutFn.Load().(inputTimeoutFuncHolder); ok {
+		for _, id := range result.Relocated {
+			if player, ok := gw.player(id); ok {
+				x, y := player.Position().GetFixed()
+				holder.fn(id, x, y, player.GetClientTick())
+			}
+		}
+	}
+	metrics.WorldResizes.Inc()
+	metrics.WorldRelocatedPlayers.Add(float64(len(result.Relocated)))
+	slog.Info("world resized",
+		"width", b.Width, "height", b.Height,
+		"relocate", req.relocate, "relocated", len(result.Relocated))
+	return result
+}
@@ -0,0 +1,129 @@
+package game
+
+import (
+	"math"
+
+	"pixi_game_server/internal/worldmap"
+)
+
+// Безопасные зоны.
+//
+// Safe zones — the "safe" objects of a loaded map or, without them, WorldConfig.SafeZones
+// — are rectangles where combat is off: a player inside cannot start an attack
+// (RejectSafeZone) and attacks neither hit it nor, started inside, hit anyone else.
+//
+// Membership is recomputed for every player each tick in the collision phase, once the
+// position is final. The zones are rasterised onto the visibility grid, so a cell fully
+// inside a zone or touching none answers at once and only the cells a zone edge crosses
+// test the rectangles. Clients see it as StateFlagSafeZone in the player flags.
+
+// Разметка ячейки сетки безопасных зон.
+const (
+	safeCellNone   uint8 = iota // ни одна зона ячейку не задевает
+	safeCellInside              // ячейка целиком внутри зоны
+	safeCellEdge                // через ячейку проходит граница зоны: проверять прямоугольники
+)
+
+// safeZoneGrid — безопасные зоны, разложенные по ячейкам сетки видимости.
+type safeZoneGrid struct {
+	zones      []worldmap.Rect
+	cellSize   int
+	cols, rows int
+	cells      []uint8 // cols × rows, row-major
+}
+
+// newSafeZoneGrid rasterises zones onto a width×height world of cellSize cells, laid
+// out as systems.VisibilityManager lays out its grid. nil without zones.
+func newSafeZoneGrid(zones []worldmap.Rect, width, height, cellSize uint32) *safeZoneGrid {
+	if len(zones) == 0 {
+		return nil
+	}
+	size := int(cellSize)
+	g := &safeZoneGrid{
+		zones:    zones,
+		cellSize: size,
+		cols:     max((int(width)+size-1)/size, 1),
+		rows:     max((int(height)+size-1)/size, 1),
+	}
+	g.cells = make([]uint8, g.cols*g.rows)
+	for _, z := range zones {
+		if z.MinX >= z.MaxX || z.MinY >= z.MaxY {
+			continue
+		}
+		gx0, gy0 := g.cell(z.MinX, z.MinY)
+		gx1, gy1 := g.cell(z.MaxX-1, z.MaxY-1)
+		for gy := gy0; gy <= gy1; gy++ {
+			y0, y1 := g.span(gy, g.rows)
+			for gx := gx0; gx <= gx1; gx++ {
+				x0, x1 := g.span(gx, g.cols)
+				i := gy*g.cols + gx
+				switch {
+				case int(z.MinX) <= x0 && int(z.MaxX) >= x1 && int(z.MinY) <= y0 && int(z.MaxY) >= y1:
+					g.cells[i] = safeCellInside
+				case g.cells[i] == safeCellNone:
+					g.cells[i] = safeCellEdge
+				}
+			}
+		}
+	}
+	return g
+}
+
+// cell returns the grid cell of (x, y); points past the last cell fall into it.
+func (g *safeZoneGrid) cell(x, y uint32) (gx, gy int) {
+	return min(int(x)/g.cellSize, g.cols-1), min(int(y)/g.cellSize, g.rows-1)
+}
+
+// span returns the world range [from, to) of cell i on an axis of n cells. The last
+// cell also holds every point past it, so it is never covered whole.
+func (g *safeZoneGrid) span(i, n int) (from, to int) {
+	if i == n-1 {
+		return i * g.cellSize, math.MaxInt
+	}
+	return i * g.cellSize, (i + 1) * g.cellSize
+}
+
+// contains reports whether (x, y) lies in a safe zone.
+func (g *safeZoneGrid) contains(x, y uint32) bool {
+	gx, gy := g.cell(x, y)
+	switch g.cells[gy*g.cols+gx] {
+	case safeCellInside:
+		return true
+	case safeCellEdge:
+		for _, z := range g.zones {
+			if z.Contains(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// initSafeZones builds the safe zone grid for the current bounds. Called at startup and
+// by resize, when tick workers are idle.
+func (gw *GameWorld) initSafeZones() {
+	b := gw.bounds.Load()
+	gw.safeZones.Store(newSafeZoneGrid(gw.safeZoneRects(b), b.Width, b.Height, visibilityCellSize))
+}
+
+// safeZoneRects возвращает безопасные зоны: из карты, если она их задаёт, иначе
+// SafeZones из конфига, обрезанные границами b.
+func (gw *GameWorld) safeZoneRects(b *Bounds) []worldmap.Rect {
+	if gw.worldMap != nil && len(gw.worldMap.SafeZones) > 0 {
+		return gw.worldMap.SafeZones
+	}
+	var zones []worldmap.Rect
+	for _, a := range gw.cfg.World.SafeZones {
+		r := worldmap.Rect{MinX: a.MinX, MinY: a.MinY, MaxX: min(a.MaxX, b.Width), MaxY: min(a.MaxY, b.Height)}
+		if r.MinX < r.MaxX && r.MinY < r.MaxY {
+			zones = append(zones, r)
+		}
+	}
+	return zones
+}
+
+// InSafeZone reports whether (x, y) lies in a safe zone.
+func (gw *GameWorld) InSafeZone(x, y uint32) bool {
+	g := gw.safeZones.Load()
+	return g != nil && g.contains(x, y)
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"pixi_game_server/internal/ecs"
+	"pixi_game_server/internal/metrics"
+)
+
+// visibilityCellSize — размер ячейки пространственной сетки (world units).
+const visibilityCellSize = 100
+
+// Region sharding of the tick.
+//
+// By default the collision phase hands tick workers chunks of entity rows, so every
+// worker can touch any grid cell and MovePlayer contends on cell mutexes. With
+// Game.RegionSharding each worker owns a horizontal band of grid rows: players are
+// partitioned by Y, and grid updates inside the band never touch another worker's
+// cells. A move that crosses into another band is recorded as a migration and
+// applied sequentially in the merge step after all workers finish.
+//
+// Bands are static, so a crowded band (e.g. the spawn area) lands on one worker —
+// that is why the mode is opt-in and meant for large, evenly populated worlds.
+
+// regionShard — строки сущностей одной полосы сетки на текущем тике. Трогает только свой воркер.
+type regionShard struct {
+	index      int
+	rows       []ecs.Row
+	migrations []shardMigration
+}
+
+// shardMigration — отложенное перемещение в сетке видимости в ячейку другого шарда.
+type shardMigration struct {
+	playerID uint32
+	x, y     uint32
+}
+
+// initRegionShards делит строки сетки поровну между tick worker'ами.
+func (gw *GameWorld) initRegionShards() {
+	gridRows := max((int(gw.bounds.Load().Height)+visibilityCellSize-1)/visibilityCellSize, 1)
+	gw.gridRows = gridRows
+	gw.shardRows = max((gridRows+gw.jobs.workers()-1)/gw.jobs.workers(), 1)
+	gw.shards = make([]regionShard, gw.jobs.workers())
+	for i := range gw.shards {
+		gw.shards[i].index = i
+	}
+}
+
+// shardForY возвращает индекс шарда, которому принадлежит строка сетки с координатой y.
+// Строка клампится так же, как в VisibilityManager.worldToGrid (y == Height → последняя строка).
+func (gw *GameWorld) shardForY(y uint32) int {
+	row := min(int(y)/visibilityCellSize, gw.gridRows-1)
+	return min(row/gw.shardRows, len(gw.shards)-1)
+}
+
+// runRegionShards — фаза collision в region-sharded режиме: раскладывает строки
+// [0, rows) по шардам, обрабатывает шарды параллельно и затем применяет межшардовые
+// перемещения (merge step).
+func (gw *GameWorld) runRegionShards(rows ecs.Row) {
+	for i := range gw.shards {
+		gw.shards[i].rows = gw.shards[i].rows[:0]
+		gw.shards[i].migrations = gw.shards[i].migrations[:0]
+	}
+	for r := range rows {
+		if gw.entities.Owner(r) == 0 {
+			continue
+		}
+		sh := &gw.shards[gw.shardForY(gw.entities.Position.At(r).GetY())]
+		sh.rows = append(sh.rows, r)
+	}
+
+	gw.jobs.run(len(gw.shards), gw.phases.shard)
+
+	// Merge step: gameLoop goroutine only, workers are idle.
+	migrated := 0
+	for i := range gw.shards {
+		for _, m := range gw.shards[i].migrations {
+			gw.visibility.Load().MovePlayer(m.playerID, m.x, m.y)
+		}
+		migrated += len(gw.shards[i].migrations)
+	}
+	metrics.TickShardMigrations.Observe(float64(migrated))
+}
@@ -0,0 +1,62 @@
+package game
+
+import (
+	"sync/atomic"
+
+	"pixi_game_server/internal/types"
+)
+
+// StateSnapshot — состояние всех игроков на конец тика. Пересобирается один раз за тик
+// в gameLoop; читатели (initial state, метрики) берут его через AcquireSnapshot вместо
+// обхода живого playersMap под локом с ToState на каждого игрока.
+//
+// Буферов два: тик пишет в тот, что сейчас не опубликован. Если свободный буфер ещё
+// удерживает читатель (readers > 0), тик выделяет новый — читатель никогда не видит
+// перезапись под собой, а в обычном случае аллокаций нет.
+type StateSnapshot struct {
+	Players []types.PlayerState
+	Tick    uint32
+
+	readers int32 // atomic
+}
+
+// Release возвращает снапшот. После Release Players читать нельзя.
+func (s *StateSnapshot) Release() {
+	atomic.AddInt32(&s.readers, -1)
+}
+
+// AcquireSnapshot возвращает последний опубликованный снапшот; вызывающий обязан
+// вызвать Release. До первого тика снапшот пустой.
+func (gw *GameWorld) AcquireSnapshot() *StateSnapshot {
+	for {
+		s := gw.snapshot.Load()
+		atomic.AddInt32(&s.readers, 1)
+		// Re-check: если тик успел сменить снапшот между Load и инкрементом, буфер мог уже
+		// уйти на перезапись — откатываемся и берём новый.
+		if gw.snapshot.Load() == s {
+			return s
+		}
+		atomic.AddInt32(&s.readers, -1)
+	}
+}
+
+// publishSnapshot копирует states в свободный буфер и публикует его.
+// Только gameLoop горутина.
+func (gw *GameWorld) publishSnapshot(states []types.PlayerState) {
+	next := gw.snapBufs[0]
+	if next == gw.snapshot.Load() {
+		next = gw.snapBufs[1]
+	}
+	if atomic.LoadInt32(&next.readers) != 0 {
+		// Медленный читатель всё ещё держит буфер — оставляем его ему.
+		next = &StateSnapshot{Players: make([]types.PlayerState, 0, len(states))}
+		if gw.snapBufs[0] == gw.snapshot.Load() {
+			gw.snapBufs[1] = next
+		} else {
+			gw.snapBufs[0] = next
+		}
+	}
+	next.Players = append(next.Players[:0], states...)
+	next.Tick = gw.tickCount
+	gw.snapshot.Store(next)
+}
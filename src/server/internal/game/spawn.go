@@ -0,0 +1,276 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/systems"
+	"pixi_game_server/internal/worldmap"
+)
+
+// Выбор точки спавна, по убыванию приоритета:
+//
+//   - С TeamsEnabled игрок вступает в самую малочисленную команду и появляется на её
+//     базе (WorldConfig.TeamBases).
+//   - Иначе — в одной из включённых точек спавна (WorldConfig.SpawnPoints), в той, чья
+//     ячейка сетки наименее населена. Admin API включает и выключает точки на ходу.
+//   - Иначе — в spawn-областях (spawnAreas), в наименее населённой ячейке сетки.
+
+// ErrNoSpawnPoint — точки спавна с таким именем нет в конфиге.
+var ErrNoSpawnPoint = errors.New("no such spawn point")
+
+// spawnPoint — точка спавна из конфига и её текущее состояние.
+type spawnPoint struct {
+	name    string
+	x, y    uint32
+	enabled atomic.Bool
+}
+
+// SpawnPointState — точка спавна для admin API.
+type SpawnPointState struct {
+	Name    string `json:"name"`
+	X       uint32 `json:"x"`
+	Y       uint32 `json:"y"`
+	Enabled bool   `json:"enabled"`
+}
+
+// teams — число игроков в каждой команде (sizes[team-1]).
+type teams struct {
+	mu    sync.Mutex
+	sizes []int
+}
+
+// TeamState — команда (имя и база из конфига) и число игроков для admin API.
+type TeamState struct {
+	config.SpawnArea
+	Players int `json:"players"`
+}
+
+func (gw *GameWorld) initSpawns() {
+	gw.spawnPoints = make([]spawnPoint, len(gw.cfg.World.SpawnPoints))
+	for i, p := range gw.cfg.World.SpawnPoints {
+		gw.spawnPoints[i].name, gw.spawnPoints[i].x, gw.spawnPoints[i].y = p.Name, p.X, p.Y
+		gw.spawnPoints[i].enabled.Store(!p.Disabled)
+	}
+	if gw.cfg.World.TeamsEnabled {
+		gw.teams.sizes = make([]int, len(gw.cfg.World.TeamBases))
+	}
+}
+
+// joinTeam записывает нового игрока в самую малочисленную команду и возвращает её
+// номер; 0 — команды выключены.
+func (gw *GameWorld) joinTeam() uint8 {
+	gw.teams.mu.Lock()
+	defer gw.teams.mu.Unlock()
+	if len(gw.teams.sizes) == 0 {
+		return 0
+	}
+	best := 0
+	for i, n := range gw.teams.sizes {
+		if n < gw.teams.sizes[best] {
+			best = i
+		}
+	}
+	gw.teams.sizes[best]++
+	return uint8(best + 1)
+}
+
+// restoreTeam засчитывает игрока, импортированного в команду team (ImportState), и
+// сообщает, существует ли она.
+func (gw *GameWorld) restoreTeam(team uint8) bool {
+	gw.teams.mu.Lock()
+	defer gw.teams.mu.Unlock()
+	if team == 0 || int(team) > len(gw.teams.sizes) {
+		return false
+	}
+	gw.teams.sizes[team-1]++
+	return true
+}
+
+// leaveTeam уменьшает команду ушедшего игрока.
+func (gw *GameWorld) leaveTeam(team uint8) {
+	gw.teams.mu.Lock()
+	if team > 0 && int(team) <= len(gw.teams.sizes) {
+		gw.teams.sizes[team-1]--
+	}
+	gw.teams.mu.Unlock()
+}
+
+// Teams возвращает команды с их базами и численностью; nil — команды выключены.
+func (gw *GameWorld) Teams() []TeamState {
+	gw.teams.mu.Lock()
+	defer gw.teams.mu.Unlock()
+	if len(gw.teams.sizes) == 0 {
+		return nil
+	}
+	out := make([]TeamState, len(gw.teams.sizes))
+	for i, base := range gw.cfg.World.TeamBases {
+		out[i] = TeamState{SpawnArea: base, Players: gw.teams.sizes[i]}
+	}
+	return out
+}
+
+// TeamName возвращает имя команды; "" — без команды.
+func (gw *GameWorld) TeamName(team uint8) string {
+	if team == 0 || int(team) > len(gw.cfg.World.TeamBases) {
+		return ""
+	}
+	return gw.cfg.World.TeamBases[team-1].Name
+}
+
+// SpawnPoints возвращает точки спавна в порядке конфига.
+func (gw *GameWorld) SpawnPoints() []SpawnPointState {
+	out := make([]SpawnPointState, len(gw.spawnPoints))
+	for i := range gw.spawnPoints {
+		p := &gw.spawnPoints[i]
+		out[i] = SpawnPointState{Name: p.name, X: p.x, Y: p.y, Enabled: p.enabled.Load()}
+	}
+	return out
+}
+
+// SetSpawnPointEnabled включает или выключает точку спавна. Действует на следующие
+// спавны; игроки, уже появившиеся в точке, остаются на месте.
+func (gw *GameWorld) SetSpawnPointEnabled(name string, enabled bool) error {
+	for i := range gw.spawnPoints {
+		if gw.spawnPoints[i].name == name {
+			gw.spawnPoints[i].enabled.Store(enabled)
+			return nil
+		}
+	}
+	return ErrNoSpawnPoint
+}
+
+// pickSpawnPoint выбирает точку спавна игрока команды team (0 — без команды).
+func (gw *GameWorld) pickSpawnPoint(team uint8) (x, y uint32) {
+	if team > 0 {
+		b := gw.bounds.Load()
+		base := gw.cfg.World.TeamBases[team-1]
+		r := worldmap.Rect{MinX: base.MinX, MinY: base.MinY, MaxX: min(base.MaxX, b.Width), MaxY: min(base.MaxY, b.Height)}
+		if r.MinX < r.MaxX && r.MinY < r.MaxY {
+			return gw.spawnIn([]worldmap.Rect{r})
+		}
+	}
+	if x, y, ok := gw.spawnAtPoint(); ok {
+		return x, y
+	}
+	return gw.spawnIn(gw.spawnAreas())
+}
+
+// spawnAtPoint выбирает включённую точку спавна в наименее населённой ячейке сетки
+// (при равенстве — случайную). Точки за пределами текущих границ или в стене карты
+// пропускаются; ok=false — подходящих нет.
+func (gw *GameWorld) spawnAtPoint() (x, y uint32, ok bool) {
+	vm := gw.visibility.Load()
+	b := gw.bounds.Load()
+	bestPlayers, ties := -1, 0
+	for i := range gw.spawnPoints {
+		p := &gw.spawnPoints[i]
+		if !p.enabled.Load() || p.x >= b.Width || p.y >= b.Height {
+			continue
+		}
+		if gw.worldMap != nil && gw.worldMap.Blocked(p.x, p.y) {
+			continue
+		}
+		n := vm.CellPopulation(p.x, p.y)
+		switch {
+		case bestPlayers >= 0 && n > bestPlayers:
+			continue
+		case n == bestPlayers:
+			ties++
+			if rand.Intn(ties) != 0 {
+				continue
+			}
+		default:
+			bestPlayers, ties = n, 1
+		}
+		x, y = p.x, p.y
+	}
+	return x, y, bestPlayers >= 0
+}
+
+// spawnIn выбирает точку в наименее населённой ячейке сетки среди областей areas
+// (при равенстве — случайную, пропорционально площади пересечения), чтобы тысячи
+// игроков нагрузочного теста не скапливались в одном углу. Внутри ячейки делается до
+// SpawnAttempts попыток обойти заблокированные тайлы; SpawnAttempts = 0 отключает
+// выбор ячейки.
+func (gw *GameWorld) spawnIn(areas []worldmap.Rect) (x, y uint32) {
+	if gw.cfg.World.SpawnAttempts <= 0 {
+		return randomPointIn(areas[rand.Intn(len(areas))])
+	}
+	var (
+		best        worldmap.Rect
+		bestPlayers = -1
+		weight      int
+	)
+	vm := gw.visibility.Load()
+	for _, area := range areas {
+		vm.CellsIn(area.MinX, area.MinY, area.MaxX, area.MaxY, func(c systems.CellLoad) {
+			if bestPlayers >= 0 && c.Players > bestPlayers {
+				return
+			}
+			r := worldmap.Rect{
+				MinX: max(area.MinX, c.X),
+				MinY: max(area.MinY, c.Y),
+				MaxX: uint32(min(int(area.MaxX), int(c.X)+int(c.Size))),
+				MaxY: uint32(min(int(area.MaxY), int(c.Y)+int(c.Size))),
+			}
+			if r.MinX >= r.MaxX || r.MinY >= r.MaxY {
+				return
+			}
+			size := int(r.MaxX-r.MinX) * int(r.MaxY-r.MinY)
+			if c.Players < bestPlayers || bestPlayers < 0 {
+				best, bestPlayers, weight = r, c.Players, size
+				return
+			}
+			weight += size
+			if rand.Intn(weight) < size {
+				best = r
+			}
+		})
+	}
+	if bestPlayers < 0 {
+		return randomPointIn(areas[rand.Intn(len(areas))])
+	}
+	if bestPlayers >= gw.cfg.World.SpawnCellMaxPlayers {
+		metrics.SpawnCrowdedFallbacks.Inc()
+	}
+	for i := 0; i < gw.cfg.World.SpawnAttempts; i++ {
+		x, y = randomPointIn(best)
+		if gw.worldMap == nil || !gw.worldMap.Blocked(x, y) {
+			return x, y
+		}
+	}
+	return randomPointIn(areas[rand.Intn(len(areas))])
+}
+
+// spawnAreas возвращает области спавна: из карты, если она их задаёт, иначе
+// SpawnAreas из конфига (обрезанные текущими границами), иначе SPAWN_MIN/MAX.
+// Результат всегда непуст.
+func (gw *GameWorld) spawnAreas() []worldmap.Rect {
+	if gw.worldMap != nil && len(gw.worldMap.SpawnAreas) > 0 {
+		return gw.worldMap.SpawnAreas
+	}
+	b := gw.bounds.Load()
+	areas := make([]worldmap.Rect, 0, len(gw.cfg.World.SpawnAreas))
+	for _, a := range gw.cfg.World.SpawnAreas {
+		r := worldmap.Rect{MinX: a.MinX, MinY: a.MinY, MaxX: min(a.MaxX, b.Width), MaxY: min(a.MaxY, b.Height)}
+		if r.MinX < r.MaxX && r.MinY < r.MaxY {
+			areas = append(areas, r)
+		}
+	}
+	if len(areas) == 0 {
+		areas = append(areas, worldmap.Rect{MinX: b.SpawnMinX, MinY: b.SpawnMinY, MaxX: b.SpawnMaxX, MaxY: b.SpawnMaxY})
+	}
+	return areas
+}
+
+// randomPointIn — равномерно случайная точка в непустом прямоугольнике r.
+func randomPointIn(r worldmap.Rect) (x, y uint32) {
+	x = r.MinX + uint32(rand.Intn(int(r.MaxX-r.MinX)))
+	y = r.MinY + uint32(rand.Intn(int(r.MaxY-r.MinY)))
+	return x, y
+}
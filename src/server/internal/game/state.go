@@ -0,0 +1,137 @@
+package game
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/types"
+)
+
+// ExportedStateVersion — версия формата ExportedState; ImportState отклоняет другие.
+const ExportedStateVersion = 1
+
+// ExportedState — переносимое состояние мира: снапшот обслуживания и blue/green handover
+// между процессами. Сокеты игроков в него не входят.
+type ExportedState struct {
+	Version      int              `json:"version"`
+	SavedAt      time.Time        `json:"saved_at"`
+	Tick         uint32           `json:"tick"`
+	NextPlayerID uint32           `json:"next_player_id"`
+	Players      []ExportedPlayer `json:"players"`
+}
+
+// ExportedPlayer — игрок в ExportedState.
+type ExportedPlayer struct {
+	ID          uint32 `json:"id"`
+	X           uint32 `json:"x"`
+	Y           uint32 `json:"y"`
+	VX          int8   `json:"vx"`
+	VY          int8   `json:"vy"`
+	FacingRight bool   `json:"facing_right"`
+	State       uint8  `json:"state"`
+	Bot         bool   `json:"bot,omitempty"`
+	Team        uint8  `json:"team,omitempty"`
+}
+
+// IsBotID сообщает, выдан ли ID боту (см. bots.go).
+func IsBotID(id uint32) bool {
+	return id >= 1 && id <= maxBotID
+}
+
+// ExportState возвращает состояние мира на конец последнего тика. Согласованный срез
+// получается на остановленном мире (SetPaused(true)); на живом — состояние прошлого тика.
+func (gw *GameWorld) ExportState() *ExportedState {
+	snap := gw.AcquireSnapshot()
+	defer snap.Release()
+
+	st := &ExportedState{
+		Version:      ExportedStateVersion,
+		SavedAt:      time.Now().UTC(),
+		Tick:         snap.Tick,
+		NextPlayerID: atomic.LoadUint32(&gw.nextPlayerID),
+		Players:      make([]ExportedPlayer, len(snap.Players)),
+	}
+	for i, p := range snap.Players {
+		st.Players[i] = ExportedPlayer{
+			ID:          p.ID,
+			X:           p.X,
+			Y:           p.Y,
+			VX:          p.VX,
+			VY:          p.VY,
+			FacingRight: p.FacingRight,
+			State:       p.State &^ types.StateFlags,
+			Bot:         IsBotID(p.ID),
+		}
+		if player, ok := gw.player(p.ID); ok {
+			st.Players[i].Team = player.GetTeam()
+		}
+	}
+	return st
+}
+
+// ImportState восстанавливает игроков и счётчик ID из st. Вызывается на пустом мире до
+// приёма подключений. Возвращает восстановленных игроков (ботов подхватывает BotManager.Adopt).
+func (gw *GameWorld) ImportState(st *ExportedState) ([]*types.Player, error) {
+	if st.Version != ExportedStateVersion {
+		return nil, fmt.Errorf("unsupported state version %d (want %d)", st.Version, ExportedStateVersion)
+	}
+	if n := gw.GetPlayerCount(); n != 0 {
+		return nil, fmt.Errorf("world is not empty (%d players)", n)
+	}
+
+	now := time.Now()
+	seen := make(map[uint32]struct{}, len(st.Players))
+	for _, ep := range st.Players {
+		if b := gw.bounds.Load(); ep.ID == 0 || ep.X >= b.Width || ep.Y >= b.Height {
+			return nil, fmt.Errorf("player %d out of world bounds (%d,%d)", ep.ID, ep.X, ep.Y)
+		}
+		if _, dup := seen[ep.ID]; dup {
+			return nil, fmt.Errorf("duplicate player %d", ep.ID)
+		}
+		seen[ep.ID] = struct{}{}
+	}
+
+	players := make([]*types.Player, 0, len(st.Players))
+	gw.playersMu.Lock()
+	vm := gw.visibility.Load()
+	for _, ep := range st.Players {
+		player := gw.entities.Spawn(ep.ID, func(p *types.Player) {
+			p.JoinTime = now
+			p.SetX(ep.X)
+			p.SetY(ep.Y)
+			p.SetVX(ep.VX)
+			p.SetVY(ep.VY)
+			p.SetFacingRight(ep.FacingRight)
+			p.SetState(ep.State)
+			switch ep.State {
+			case types.StateAttacking:
+				p.SetAttackStartTime(now.UnixNano()) // атака доигрывается с начала, иначе не завершится
+			case types.StateStunned:
+				p.SetStunnedUntil(now.UnixNano()) // длительность не сохраняется: снимается на первом тике
+			case types.StateDead:
+				p.Combat().SetRespawnAt(now.Add(gw.cfg.Game.RespawnDelay).UnixNano())
+			}
+			p.Combat().SetHP(uint16(gw.cfg.Game.MaxHP)) // HP не сохраняется
+			p.SetLastUpdate(now.UnixNano())
+			p.SetLastActivity(now.UnixNano())
+		})
+		// Команда сохраняется, если её база есть и здесь; иначе игрок остаётся без команды.
+		if gw.restoreTeam(ep.Team) {
+			player.SetTeam(ep.Team)
+		}
+		gw.playersMap[ep.ID] = player
+		vm.AddPlayer(ep.ID, ep.X, ep.Y)
+		players = append(players, player)
+	}
+	gw.playersMu.Unlock()
+	atomic.AddUint32(&gw.playerCountEstimate, uint32(len(players)))
+
+	for {
+		cur := atomic.LoadUint32(&gw.nextPlayerID)
+		if cur >= st.NextPlayerID || atomic.CompareAndSwapUint32(&gw.nextPlayerID, cur, st.NextPlayerID) {
+			break
+		}
+	}
+	return players, nil
+}
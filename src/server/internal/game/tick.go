@@ -0,0 +1,373 @@
+package game
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/ecs"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/types"
+)
+
+// Фазы тика. Каждая — проход по строкам сущностей, разбитый на чанки для jobSystem
+// (jobs.go); между фазами барьер:
+//
+//	input     — истечение состояний (атака, оглушение, смерть), idle ⇄ moving, защита после
+//	            спавна, input timeout: применяет к сущности накопленный ввод;
+//	movement  — целевая позиция по вектору и скорости, в границах мира (Motion);
+//	collision — цель против карты (скольжение вдоль стен, порталы), запись позиции,
+//	            сетка видимости и viewport; в region-sharded режиме — по шардам;
+//	snapshot  — PlayerState и признак изменения по строкам, без общих структур.
+//
+// Перед фазами gameLoop применяет ввод клиентов (inputs.go), телепорты (adminops.go) и
+// попадания атак (combat.go), накопленные с прошлого тика. Затем gameLoop последовательно собирает строки в
+// scratch-срезы (memcpy-проход), публикует снапшот и вызывает broadcast.
+
+// tickPhases — функции фаз, привязанные к миру один раз (без аллокаций на тик).
+type tickPhases struct {
+	input, movement, collision, snapshot func(worker int, first, last ecs.Row)
+	shard                                func(worker, shard int)
+}
+
+func (gw *GameWorld) initTickPhases() {
+	gw.phases = tickPhases{
+		input:     gw.phaseInput,
+		movement:  gw.phaseMovement,
+		collision: gw.phaseCollision,
+		snapshot:  gw.phaseSnapshot,
+		shard:     gw.phaseCollisionShard,
+	}
+	gw.zoneCounts = make([][]int, gw.jobs.workers())
+	for i := range gw.zoneCounts {
+		gw.zoneCounts[i] = make([]int, gw.zones.Len()) // empty when zone metrics are off
+	}
+}
+
+// tick выполняет один тик игрового цикла: фазы над строками сущностей, сбор
+// состояний и дельты, broadcast. Scratch-буферы переиспользуются между тиками — нет
+// аллокаций на горячем пути. publish=false — шаг догона (catchup.go): только
+// симуляция, без фазы snapshot, снапшота и broadcast.
+func (gw *GameWorld) tick(publish bool) {
+	// Reset scratch buffers without allocating.
+	gw.scratchStates = gw.scratchStates[:0]
+	gw.scratchChanged = gw.scratchChanged[:0]
+
+	nowNano := time.Now().UnixNano()
+	gw.tickNowNano = nowNano
+
+	gw.tickCount++
+	// Full sync is controlled by configured SyncInterval (usually tens of seconds),
+	// not by tick rate. Full-sync every second explodes outbound traffic.
+	lastSync := atomic.LoadInt64(&gw.lastSyncTime)
+	fullSync := publish && (lastSync == 0 || time.Duration(nowNano-lastSync) >= gw.cfg.Game.SyncInterval)
+	if fullSync {
+		atomic.StoreInt64(&gw.lastSyncTime, nowNano)
+		gw.lastFullSync = time.Unix(0, nowNano)
+	}
+
+	t0 := time.Now()
+	// Rows removed since the last tick become reusable only now, while no worker walks
+	// the table; the lock is held just for that and for reading the row bound. Rows
+	// added after this point are picked up next tick. Components are atomic, so the
+	// phases run without the lock.
+	gw.playersMu.Lock()
+	gw.entities.Recycle()
+	rows := gw.entities.Rows()
+	gw.playersMu.Unlock()
+	gw.applyInputs()
+	gw.applyTeleports()
+	gw.resolveAttacks()
+	if int(rows) > len(gw.rowStates) {
+		gw.rowStates = append(gw.rowStates, make([]types.PlayerState, int(rows)-len(gw.rowStates))...)
+		gw.prevRowStates = append(gw.prevRowStates, make([]types.PlayerState, int(rows)-len(gw.prevRowStates))...)
+		gw.rowChanged = append(gw.rowChanged, make([]bool, int(rows)-len(gw.rowChanged))...)
+	}
+
+	chunk := gw.cfg.Game.TickChunkSize
+	gw.jobs.runRows(rows, chunk, gw.phases.input)
+	t1 := time.Now()
+	gw.jobs.runRows(rows, chunk, gw.phases.movement)
+	t2 := time.Now()
+	if gw.shards != nil {
+		gw.runRegionShards(rows)
+	} else {
+		gw.jobs.runRows(rows, chunk, gw.phases.collision)
+	}
+	t3 := time.Now()
+	if !publish {
+		// prevRowStates stays the last published state: the next publishing tick
+		// reports the changes of this one too.
+		metrics.TickPhaseDuration.WithLabelValues("world_step").Observe(t3.Sub(t0).Seconds())
+		metrics.TickWorldStepDuration.Observe(t3.Sub(t0).Seconds())
+		return
+	}
+	gw.jobs.runRows(rows, chunk, gw.phases.snapshot)
+	t4 := time.Now()
+	metrics.TickPhaseDuration.WithLabelValues("input").Observe(t1.Sub(t0).Seconds())
+	metrics.TickPhaseDuration.WithLabelValues("movement").Observe(t2.Sub(t1).Seconds())
+	metrics.TickPhaseDuration.WithLabelValues("collision").Observe(t3.Sub(t2).Seconds())
+	metrics.TickPhaseDuration.WithLabelValues("snapshot").Observe(t4.Sub(t3).Seconds())
+	metrics.TickPhaseDuration.WithLabelValues("range").Observe(t4.Sub(t0).Seconds())
+	metrics.TickPhaseDuration.WithLabelValues("world_step").Observe(t4.Sub(t0).Seconds())
+	metrics.TickWorldStepDuration.Observe(t4.Sub(t0).Seconds())
+
+	// Sequential gather in row order; the per-row work is already done.
+	for r := range int(rows) {
+		st := &gw.rowStates[r]
+		if st.ID == 0 {
+			continue
+		}
+		gw.scratchStates = append(gw.scratchStates, *st)
+		if !fullSync && gw.rowChanged[r] {
+			gw.scratchChanged = append(gw.scratchChanged, *st)
+		}
+	}
+	gw.rowStates, gw.prevRowStates = gw.prevRowStates, gw.rowStates
+	gw.publishSnapshot(gw.scratchStates)
+	t5 := time.Now()
+	metrics.TickPhaseDuration.WithLabelValues("delta").Observe(t5.Sub(t4).Seconds())
+
+	if len(gw.scratchStates) == 0 {
+		return
+	}
+
+	// Delta metrics: how many players changed state this tick.
+	changedCount := len(gw.scratchChanged)
+	if fullSync {
+		changedCount = len(gw.scratchStates)
+	}
+	metrics.DeltaPlayersCount.Observe(float64(changedCount))
+	metrics.DeltaRatio.Set(float64(changedCount) / float64(len(gw.scratchStates)))
+
+	// No-op tick: avoid broadcasting identical state when no player changed.
+	if !fullSync && changedCount == 0 {
+		return
+	}
+
+	batchIntervalNano := gw.cfg.Game.BatchInterval.Nanoseconds()
+	shouldBroadcast := fullSync || gw.lastBroadcastNano == 0 ||
+		batchIntervalNano <= 0 || nowNano-gw.lastBroadcastNano >= batchIntervalNano
+
+	if !shouldBroadcast {
+		return
+	}
+
+	gw.lastBroadcastNano = nowNano
+
+	// Call broadcastFn synchronously — it enqueues one push() per connection (non-blocking
+	// lock+append), then returns in microseconds. No allCopy/changedCopy allocations needed:
+	// EncodeGameState serialises scratchStates into bytes before tick() returns.
+	if holder, ok := gw.broadcastFn.Load().(broadcastFuncHolder); ok {
+		if fullSync {
+			holder.fn(gw.scratchStates, nil, true)
+		} else {
+			holder.fn(gw.scratchStates, gw.scratchChanged, false)
+		}
+	}
+}
+
+// phaseInput: server-authoritative state expiry (attack, stun), idle ⇄ moving, spawn
+// protection expiry and the input timeout.
+func (gw *GameWorld) phaseInput(_ int, first, last ecs.Row) {
+	ents := gw.entities
+	nowNano := gw.tickNowNano
+	attackDurNano := gw.cfg.Game.AttackDuration.Nanoseconds()
+	for r := first; r < last; r++ {
+		id := ents.Owner(r)
+		if id == 0 {
+			continue
+		}
+		vel, combat := ents.Velocity.At(r), ents.Combat.At(r)
+		gw.respawn(combat, nowNano)
+		settleState(combat, vel, nowNano, attackDurNano)
+		// Spawn protection expiry — флаг уходит клиентам через delta (State меняется)
+		if until := combat.GetSpawnProtectedUntil(); until > 0 && nowNano >= until {
+			combat.SetSpawnProtectedUntil(0)
+		}
+		if gw.inputTimeoutNano > 0 && nowNano-vel.GetLastActivity() >= gw.inputTimeoutNano {
+			gw.stopTimedOut(id, ents.Position.At(r), vel)
+		}
+	}
+}
+
+// phaseMovement: target position from the movement vector and speed (storm and the
+// player's multiplier, see moveSpeed), clamped to the world bounds (matches client-side
+// behavior).
+func (gw *GameWorld) phaseMovement(_ int, first, last ecs.Row) {
+	ents := gw.entities
+	b := gw.bounds.Load()
+	for r := first; r < last; r++ {
+		motion := ents.Motion.At(r)
+		motion.Moving = false
+		if ents.Owner(r) == 0 {
+			continue
+		}
+		vel := ents.Velocity.At(r)
+		vx, vy := vel.GetVX(), vel.GetVY()
+		svx, svy := vel.GetSubV()
+		if vx == 0 && vy == 0 && svx == 0 && svy == 0 {
+			continue // Player not moving
+		}
+		// Bots set their vector directly; stunned and dead players do not glide.
+		if combat := ents.Combat.At(r); combat.GetFrozen() || !canAct(combat.GetState()) {
+			vel.SetSubV(0, 0)
+			continue
+		}
+		pos := ents.Position.At(r)
+		speed := gw.moveSpeed(vel)
+		tx, ty := vel.GetThrottle()
+		x, y := pos.GetFixed()
+		motion.ToX, svx = gw.physics.stepAxis(x, svx, targetVelocity(vx, throttle(tx), speed), b.MinX, b.MaxX)
+		motion.ToY, svy = gw.physics.stepAxis(y, svy, targetVelocity(vy, throttle(ty), speed), b.MinY, b.MaxY)
+		vel.SetSubV(svx, svy)
+		motion.Moving = true
+	}
+}
+
+// phaseCollision resolves the rows [first, last) without region shards.
+func (gw *GameWorld) phaseCollision(worker int, first, last ecs.Row) {
+	var start time.Time
+	if gw.zones != nil {
+		start = time.Now()
+	}
+	processed := 0
+	for r := first; r < last; r++ {
+		processed += gw.collide(r, nil, gw.zoneCounts[worker])
+	}
+	if gw.zones != nil {
+		gw.attributeZoneTick(gw.zoneCounts[worker], time.Since(start), processed)
+	}
+}
+
+// phaseCollisionShard resolves the rows of one region shard (shards.go).
+func (gw *GameWorld) phaseCollisionShard(worker, shard int) {
+	sh := &gw.shards[shard]
+	var start time.Time
+	if gw.zones != nil {
+		start = time.Now()
+	}
+	processed := 0
+	for _, r := range sh.rows {
+		processed += gw.collide(r, sh, gw.zoneCounts[worker])
+	}
+	if gw.zones != nil {
+		gw.attributeZoneTick(gw.zoneCounts[worker], time.Since(start), processed)
+	}
+}
+
+// collide applies the movement target of row r against the map and commits it: slide
+// along the blocked axis, portals, the visibility grid and the viewport. Zone metrics
+// are attributed here, where the position is final. Returns 1 if the row is owned.
+// В region-sharded режиме переход в ячейку другого шарда откладывается до merge-шага.
+func (gw *GameWorld) collide(r ecs.Row, shard *regionShard, zoneCounts []int) int {
+	ents := gw.entities
+	id := ents.Owner(r)
+	if id == 0 {
+		return 0
+	}
+	pos := ents.Position.At(r)
+	if motion := ents.Motion.At(r); motion.Moving {
+		fromX, fromY := pos.GetFixed()
+		toX, toY := motion.ToX, motion.ToY
+
+		if m := gw.worldMap; m != nil {
+			// Collision: slide along the blocked axis, stop if both are blocked. A blocked
+			// axis loses its velocity.
+			if m.Blocked(toX.Units(), toY.Units()) {
+				vel := ents.Velocity.At(r)
+				svx, svy := vel.GetSubV()
+				switch {
+				case !m.Blocked(toX.Units(), fromY.Units()):
+					toY, svy = fromY, 0
+				case !m.Blocked(fromX.Units(), toY.Units()):
+					toX, svx = fromX, 0
+				default:
+					toX, toY, svx, svy = fromX, fromY, 0, 0
+				}
+				vel.SetSubV(svx, svy)
+			}
+			if portal := m.PortalAt(toX.Units(), toY.Units()); portal != nil {
+				toX, toY = types.FixedOf(portal.DestX), types.FixedOf(portal.DestY)
+				metrics.PortalTeleports.Inc()
+			}
+		}
+
+		// Update position atomically
+		pos.SetFixed(toX, toY)
+		pos.SetLastUpdate(gw.tickNowNano)
+
+		currentX, currentY := fromX.Units(), fromY.Units()
+		newX, newY := toX.Units(), toY.Units()
+		if newX != currentX || newY != currentY {
+			if shard != nil && gw.shardForY(newY) != shard.index {
+				shard.migrations = append(shard.migrations, shardMigration{id, newX, newY})
+			} else {
+				gw.visibility.Load().MovePlayer(id, newX, newY)
+			}
+			gw.updateViewport(ents.Player(r))
+		}
+	}
+	// Safe zone membership is refreshed every tick: teleports and spawns move players
+	// outside this phase, and resize replaces the zones.
+	combat := ents.Combat.At(r)
+	if safe := gw.InSafeZone(pos.GetX(), pos.GetY()); safe != combat.GetSafeZone() {
+		combat.SetSafeZone(safe)
+	}
+	if gw.zones != nil {
+		zoneCounts[gw.zones.Index(pos.GetX(), pos.GetY())]++
+	}
+	return 1
+}
+
+// phaseSnapshot builds the PlayerState of every row and whether it changed since the
+// previous tick (a row that changed owner counts as changed). Free rows get ID 0.
+func (gw *GameWorld) phaseSnapshot(_ int, first, last ecs.Row) {
+	ents := gw.entities
+	for r := first; r < last; r++ {
+		id := ents.Owner(r)
+		if id == 0 {
+			gw.rowStates[r] = types.PlayerState{}
+			continue
+		}
+		st := ents.State(id, r)
+		prev := &gw.prevRowStates[r]
+		gw.rowChanged[r] = prev.ID != st.ID || st.X != prev.X || st.Y != prev.Y ||
+			(st.FracX^prev.FracX|st.FracY^prev.FracY)&gw.fracMask != 0 ||
+			st.VX != prev.VX || st.VY != prev.VY ||
+			st.State != prev.State || st.FacingRight != prev.FacingRight
+		gw.rowStates[r] = st
+	}
+}
+
+// Затухание после input timeout: throttle осей делится на inputTimeoutDecay каждый тик,
+// пока не станет меньше inputTimeoutEpsilon (из 127: 63, 31, 15 — стоп на третьем тике).
+const (
+	inputTimeoutDecay   = 2
+	inputTimeoutEpsilon = 16
+)
+
+// stopTimedOut гасит движение сущности, от которой перестали приходить MOVE (потеря
+// пакетов): VX/VY не обнуляются сразу, а throttle затухает за несколько тиков, чтобы
+// игрок не замирал на месте. Остановка уходит всем через delta broadcast, а самому
+// игроку — через inputTimeoutFn (коррекция позиции).
+func (gw *GameWorld) stopTimedOut(id uint32, pos *types.Position, vel *types.Velocity) {
+	if !vel.Moving() {
+		return
+	}
+	tx, ty := vel.GetThrottle()
+	tx32, ty32 := throttle(tx)/inputTimeoutDecay, throttle(ty)/inputTimeoutDecay
+	if max(tx32, ty32) >= inputTimeoutEpsilon {
+		// 0 значит «полная», поэтому ось не опускается ниже 1.
+		vel.SetThrottle(uint8(max(tx32, 1)), uint8(max(ty32, 1)))
+		return
+	}
+	vel.SetVX(0)
+	vel.SetVY(0)
+	vel.SetThrottle(0, 0)
+	metrics.InputTimeouts.Inc()
+
+	if holder, ok := gw.inputTimeoutFn.Load().(inputTimeoutFuncHolder); ok {
+		x, y := pos.GetFixed()
+		holder.fn(id, x, y, vel.GetClientTick())
+	}
+}
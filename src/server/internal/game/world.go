@@ -2,7 +2,7 @@ package game
 
 import (
 	"log/slog"
-	"math/rand"
+	"math"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -10,8 +10,10 @@ import (
 
 	"pixi_game_server/internal/config"
 	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/pathfind"
 	"pixi_game_server/internal/systems"
 	"pixi_game_server/internal/types"
+	"pixi_game_server/internal/worldmap"
 )
 
 // broadcastFuncHolder оборачивает функцию для хранения в atomic.Value.
@@ -19,54 +21,112 @@ type broadcastFuncHolder struct {
 	fn func(all []types.PlayerState, changed []types.PlayerState, fullSync bool)
 }
 
-// tickWorkerInput — chunk of player pointers dispatched to a persistent tick worker.
-// Workers do only the CPU-heavy part (position update + attack timeout).
-// State snapshot (ToState + delta) remains sequential in the gameLoop goroutine.
-type tickWorkerInput struct {
-	ptrs          []*types.Player
-	nowNano       int64
-	attackDurNano int64
+// postTickFuncHolder оборачивает post-tick hook для хранения в atomic.Value.
+type postTickFuncHolder struct {
+	fn func()
+}
+
+// inputTimeoutFuncHolder оборачивает обработчик input-timeout для хранения в atomic.Value.
+type inputTimeoutFuncHolder struct {
+	fn func(playerID uint32, x, y types.Fixed, clientTick uint32)
 }
 
 // GameWorld управляет состоянием игрового мира
 type GameWorld struct {
 	cfg        *config.Config
 	playersMu  sync.RWMutex
-	playersMap map[uint32]*types.Player
+	playersMap map[uint32]*types.Player // ручки по ID
+
+	// Компоненты всех сущностей (types.Entities); tick обходит их строки. Spawn и
+	// Despawn — под playersMu, Recycle — в начале тика под ним же.
+	entities *types.Entities
 
 	// Tick-driven broadcast: вызывается раз в тик с текущим состоянием всех игроков.
 	// Хранится в atomic.Value — записывается один раз из SetTickBroadcaster,
 	// читается из gameLoop горутины. Прямой вызов из tick() — никаких аллокаций.
 	broadcastFn atomic.Value // stores broadcastFuncHolder
 
-	// High-performance systems
-	visibilityManager *systems.VisibilityManager
+	// Input timeout (dead reckoning guard): вызывается из tick worker'а, когда движущийся
+	// игрок не присылал MOVE дольше InputTimeoutTicks. Сервер использует его для коррекции клиента.
+	inputTimeoutFn   atomic.Value // stores inputTimeoutFuncHolder
+	inputTimeoutNano int64        // InputTimeoutTicks × tick interval; 0 = disabled
+
+	// Post-tick hook: вызывается из gameLoop после каждого тика (включая no-op тики).
+	postTickFn atomic.Value // stores postTickFuncHolder
+
+	// High-performance systems. Replaced as a whole by Resize (resize.go).
+	visibility atomic.Pointer[systems.VisibilityManager]
+
+	// Current world bounds: cfg.World at start, then whatever Resize set.
+	bounds atomic.Pointer[Bounds]
+
+	// Правила физики движения (physics.go)
+	physics physics
+
+	// Биты доли единицы, которые видят клиенты (config Net.PositionFractionBits): сдвиг
+	// только в них делает запись игрока изменившейся.
+	fracMask uint8
+
+	// Безопасные зоны (safezones.go); nil — их нет. Заменяется вместе с visibility.
+	safeZones atomic.Pointer[safeZoneGrid]
+
+	// Loaded map layout (collision, spawn areas, portals); nil = open world.
+	worldMap *worldmap.Map
+	paths    *pathfind.Finder // on worldMap's collision grid (paths.go); nil = none
+
+	// Scheduled world events (worldevents.go) and their effect on movement.
+	worldEvents  worldEvents
+	worldEventFn atomic.Value // stores worldEventFuncHolder
+	speedPercent int32        // atomic; 100 = normal speed
+
+	// Spawn points and teams (spawn.go).
+	spawnPoints []spawnPoint
+	teams       teams
+
+	// Admin teleports and client input waiting for the next tick (adminops.go, inputs.go).
+	teleports teleportQueue
+	inputs    inputQueue
+
+	// Attacks waiting for hit resolution and who hears about the hits (combat.go).
+	attacks attackQueue
+	hitFn   atomic.Value // stores hitFuncHolder
 
-	// Delta tracking: previous tick state for each player
-	prevStates map[uint32]types.PlayerState
-	tickCount  uint32 // counts ticks for periodic full sync
+	tickCount uint32 // counts ticks for periodic full sync
 	// Reusable scratch buffers for tick() — only touched from gameLoop goroutine, no sync needed.
 	scratchStates  []types.PlayerState
 	scratchChanged []types.PlayerState
-	scratchSeenIDs map[uint32]struct{}
-	// scratchPtrs holds a snapshot of player pointers taken under a brief RLock each tick.
-	// Processing (position update + ToState) happens outside the lock since all Player
-	// fields are atomic — the lock only protects the map structure itself.
-	scratchPtrs []*types.Player
-	// Persistent tick worker pool (pattern from nbio/nakama).
-	// Workers are created once in NewGameWorld; each tick dispatches a chunk of players
-	// via a buffered channel. Workers do only the expensive part (updatePlayerPosition +
-	// attack timeout). State collection (ToState + delta) stays in gameLoop goroutine.
-	// Avoids per-tick goroutine spawn overhead (~2µs/goroutine × N workers).
-	nTickWorkers  int
-	tickWorkerChs []chan tickWorkerInput
-	tickWorkerWg  sync.WaitGroup // Performance metrics
-	tickDuration  int64          // atomic
-	lastSyncTime  int64          // atomic
+	// Delta tracking by entity row: the snapshot phase writes rowStates and rowChanged
+	// against prevRowStates (last tick), then the two state buffers swap.
+	rowStates     []types.PlayerState
+	prevRowStates []types.PlayerState
+	rowChanged    []bool
+	// Tick job system (jobs.go) and the phases it runs (tick.go). tickNowNano — время
+	// текущего тика, пишет gameLoop до запуска фаз.
+	jobs        *jobSystem
+	phases      tickPhases
+	tickNowNano int64
+	zoneCounts  [][]int // per tick worker, see attributeZoneTick
+	// Region sharding (Game.RegionSharding): one shard of grid rows per tick worker.
+	shards    []regionShard
+	shardRows int // grid rows per shard
+	gridRows  int
+
+	// Per-tick player state snapshot for readers outside gameLoop (see snapshot.go).
+	snapshot atomic.Pointer[StateSnapshot]
+	snapBufs [2]*StateSnapshot
+
+	// Performance metrics
+	tickDuration int64 // atomic
+	tickTime     int64 // atomic: ns spent in ticks since start (TickTime)
+	lastSyncTime int64 // atomic
 
 	// Tick management
-	ticker   *time.Ticker
-	stopChan chan struct{}
+	ticker    *time.Ticker
+	stopChan  chan struct{}
+	loopDone  chan struct{}      // закрывается, когда gameLoop вышел
+	pauseReq  chan bool          // SetPaused → gameLoop; небуферизованный, переключение строго между тиками
+	resizeReq chan resizeRequest // Resize → gameLoop, тоже между тиками
+	paused    int32              // atomic 0/1
 
 	// Player ID generation
 	nextPlayerID uint32 // atomic
@@ -80,10 +140,18 @@ type GameWorld struct {
 
 	// Throttled diagnostics
 	lastSlowTickLog int64 // atomic UnixNano timestamp
+
+	// Catch-up after slow ticks (catchup.go)
+	degradation   int32        // atomic Degradation
+	degradationFn atomic.Value // stores degradationFuncHolder
+
+	// Per-zone metrics (see zones.go); zones == nil when disabled
+	zones     *metrics.ZoneGrid
+	zoneState zoneState
 }
 
-// NewGameWorld создает новый игровой мир
-func NewGameWorld(cfg *config.Config) *GameWorld {
+// NewGameWorld создает новый игровой мир. worldMap may be nil (open world, spawn area from config).
+func NewGameWorld(cfg *config.Config, worldMap *worldmap.Map) *GameWorld {
 	initialCap := cfg.Net.MaxConnections
 	if initialCap < 256 {
 		initialCap = 256
@@ -97,70 +165,106 @@ func NewGameWorld(cfg *config.Config) *GameWorld {
 
 	gw := &GameWorld{
 		cfg:            cfg,
+		worldMap:       worldMap,
+		speedPercent:   100,
+		physics:        newPhysics(&cfg.Game),
+		fracMask:       ^uint8(0) << (types.FracBits - cfg.Net.PositionFractionBits),
 		playersMap:     make(map[uint32]*types.Player, 256),
+		entities:       types.NewEntities(),
 		stopChan:       make(chan struct{}),
+		loopDone:       make(chan struct{}),
+		pauseReq:       make(chan bool),
+		resizeReq:      make(chan resizeRequest),
 		nextPlayerID:   1000, // Start from 1000 for easy debugging
 		lastFullSync:   time.Now(),
-		prevStates:     make(map[uint32]types.PlayerState, initialCap),
 		scratchStates:  make([]types.PlayerState, 0, initialCap),
 		scratchChanged: make([]types.PlayerState, 0, changedCap),
-		scratchSeenIDs: make(map[uint32]struct{}, initialCap),
-		scratchPtrs:    make([]*types.Player, 0, initialCap),
+		zones:          metrics.NewZoneGrid(cfg.World.Width, cfg.World.Height, cfg.World.ZoneCols, cfg.World.ZoneRows),
 	}
 
-	// Spawn persistent tick workers — one per logical CPU.
-	// Pattern: nbio TaskPool / nakama runtime worker pool.
-	// Workers receive chunks of player pointers, process them, signal done via WaitGroup.
-	// Channels are buffered=1 so gameLoop never blocks on dispatch.
-	n := runtime.GOMAXPROCS(0)
-	gw.nTickWorkers = n
-	gw.tickWorkerChs = make([]chan tickWorkerInput, n)
-	for i := range gw.tickWorkerChs {
-		ch := make(chan tickWorkerInput, 1)
-		gw.tickWorkerChs[i] = ch
-		go gw.runTickWorker(ch)
+	gw.snapBufs[0] = &StateSnapshot{Players: make([]types.PlayerState, 0, initialCap)}
+	gw.snapBufs[1] = &StateSnapshot{Players: make([]types.PlayerState, 0, initialCap)}
+	gw.snapshot.Store(gw.snapBufs[0])
+
+	if cfg.Game.InputTimeoutTicks > 0 && cfg.Game.TickRate > 0 {
+		tickInterval := time.Second / time.Duration(cfg.Game.TickRate)
+		gw.inputTimeoutNano = int64(cfg.Game.InputTimeoutTicks) * tickInterval.Nanoseconds()
 	}
 
+	// Persistent tick workers (pattern: nbio TaskPool / nakama runtime worker pool),
+	// one per logical CPU unless TICK_WORKERS says otherwise.
+	n := cfg.Game.TickWorkers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	gw.jobs = newJobSystem(n)
+	gw.initTickPhases()
+
 	// Initialize high-performance systems
-	gw.visibilityManager = systems.NewVisibilityManager(
-		cfg.World.Width, cfg.World.Height, 100) // 100-unit grid cells
+	gw.bounds.Store(configBounds(&cfg.World))
+	gw.visibility.Store(systems.NewVisibilityManager(
+		cfg.World.Width, cfg.World.Height, visibilityCellSize))
+	if cfg.Game.RegionSharding {
+		gw.initRegionShards()
+	}
+	gw.initSafeZones()
+	gw.initPaths()
+
+	gw.initSpawns()
+	gw.initWorldEvents()
+	if gw.zones != nil {
+		go gw.runZoneReport()
+	}
 
 	// Start game loop
 	go gw.gameLoop()
 
 	slog.Info("gameworld initialized",
 		"tick_rate_hz", cfg.Game.TickRate,
-		"batch_interval_ms", cfg.Game.BatchInterval.Milliseconds())
+		"batch_interval_ms", cfg.Game.BatchInterval.Milliseconds(),
+		"input_timeout_ticks", cfg.Game.InputTimeoutTicks,
+		"spawn_protection_ms", cfg.Game.SpawnProtection.Milliseconds(),
+		"attack_damage", cfg.Game.AttackDamage,
+		"region_sharding", cfg.Game.RegionSharding,
+		"world_map", worldMap != nil)
 
 	return gw
 }
 
 // AddPlayer добавляет нового игрока (lock-free)
 func (gw *GameWorld) AddPlayer() *types.Player {
-	playerID := atomic.AddUint32(&gw.nextPlayerID, 1)
-
-	// Generate random spawn position within spawn area
-	spawnRangeX := gw.cfg.World.SpawnMaxX - gw.cfg.World.SpawnMinX
-	spawnRangeY := gw.cfg.World.SpawnMaxY - gw.cfg.World.SpawnMinY
-
-	spawnX := gw.cfg.World.SpawnMinX + uint16(rand.Intn(int(spawnRangeX)))
-	spawnY := gw.cfg.World.SpawnMinY + uint16(rand.Intn(int(spawnRangeY)))
-
-	player := &types.Player{
-		ID:       playerID,
-		JoinTime: time.Now(),
-	}
+	return gw.addPlayer(atomic.AddUint32(&gw.nextPlayerID, 1))
+}
 
-	player.SetX(spawnX)
-	player.SetY(spawnY)
-	player.SetFacingRight(true)
-	player.SetState(0) // idle state
-	player.SetLastUpdate(time.Now().UnixNano())
+// addPlayer создаёт игрока с заданным ID в точке спавна и регистрирует его в мире.
+func (gw *GameWorld) addPlayer(playerID uint32) *types.Player {
+	team := gw.joinTeam()
+	spawnX, spawnY := gw.pickSpawnPoint(team)
+	now := time.Now()
 
 	gw.playersMu.Lock()
+	// The world may have shrunk since the spawn point was picked; the grid is added to
+	// under the lock so a concurrent Resize never misses or duplicates the player.
+	if b := gw.bounds.Load(); !b.contains(spawnX, spawnY) {
+		spawnX, spawnY = b.clamp(spawnX, spawnY)
+	}
+	player := gw.entities.Spawn(playerID, func(p *types.Player) {
+		p.JoinTime = now
+		p.SetX(spawnX)
+		p.SetY(spawnY)
+		p.SetFacingRight(true)
+		p.SetState(types.StateIdle)
+		p.SetLastUpdate(now.UnixNano())
+		p.SetLastActivity(now.UnixNano())
+		p.SetTeam(team)
+		p.Combat().SetHP(uint16(gw.cfg.Game.MaxHP))
+		if gw.cfg.Game.SpawnProtection > 0 {
+			p.SetSpawnProtectedUntil(now.Add(gw.cfg.Game.SpawnProtection).UnixNano())
+		}
+	})
 	gw.playersMap[playerID] = player
+	gw.visibility.Load().AddPlayer(playerID, spawnX, spawnY)
 	gw.playersMu.Unlock()
-	gw.visibilityManager.AddPlayer(playerID, spawnX, spawnY)
 	atomic.AddUint32(&gw.playerCountEstimate, 1)
 
 	return player
@@ -169,32 +273,32 @@ func (gw *GameWorld) AddPlayer() *types.Player {
 // RemovePlayer удаляет игрока (lock-free)
 func (gw *GameWorld) RemovePlayer(playerID uint32) {
 	gw.playersMu.Lock()
-	_, loaded := gw.playersMap[playerID]
+	player, loaded := gw.playersMap[playerID]
 	if loaded {
 		delete(gw.playersMap, playerID)
+		gw.entities.Despawn(player)
+		gw.visibility.Load().RemovePlayer(playerID)
 	}
 	gw.playersMu.Unlock()
 	if loaded {
-		gw.visibilityManager.RemovePlayer(playerID)
+		gw.leaveTeam(player.GetTeam())
 		atomic.AddUint32(&gw.playerCountEstimate, ^uint32(0)) // decrement
 		metrics.EventsProcessed.WithLabelValues("disconnect").Inc()
 	}
 }
 
-// ProcessEvent обрабатывает событие инлайн (все операции atomic, нет нужды в канале/воркерах).
+// ProcessEvent принимает событие игрока. Ввод (MOVE, FACE) копится до следующего тика
+// (inputs.go), остальное обрабатывается инлайн (все операции atomic).
+// Вызывается из read-воркера соединения: EPOLLONESHOT (или своя горутина чтения вне Linux)
+// гарантирует, что события одного игрока приходят строго по порядку и никогда
+// параллельно, а всплеск одного игрока занимает только его воркер — общей очереди,
+// которую он мог бы забить остальным, нет.
 func (gw *GameWorld) ProcessEvent(event types.GameEvent) {
-	gw.handleEvent(event)
-}
-
-// GetAllPlayers возвращает всех игроков (для полной синхронизации)
-func (gw *GameWorld) GetAllPlayers() []types.PlayerState {
-	gw.playersMu.RLock()
-	allPlayers := make([]types.PlayerState, 0, len(gw.playersMap))
-	for _, player := range gw.playersMap {
-		allPlayers = append(allPlayers, player.ToState())
+	if stagesInput(event.Type) {
+		gw.stageInput(event)
+		return
 	}
-	gw.playersMu.RUnlock()
-	return allPlayers
+	gw.handleEvent(event)
 }
 
 // GetPlayerCount возвращает количество подключенных игроков
@@ -213,8 +317,10 @@ func (gw *GameWorld) gameLoop() {
 	// GOGC=-1 allowed memory to accumulate without incremental marking.
 
 	tickInterval := time.Second / time.Duration(gw.cfg.Game.TickRate)
+	clock := newTickClock(tickInterval, gw.cfg.Game.MaxCatchUpTicks, time.Now())
 	gw.ticker = time.NewTicker(tickInterval)
 	defer gw.ticker.Stop()
+	defer close(gw.loopDone)
 
 	slog.Info("game loop started",
 		"interval_ms", tickInterval.Milliseconds(),
@@ -222,15 +328,28 @@ func (gw *GameWorld) gameLoop() {
 
 	for {
 		select {
+		case paused := <-gw.pauseReq:
+			var v int32
+			if paused {
+				v = 1
+			}
+			atomic.StoreInt32(&gw.paused, v)
+			slog.Info("simulation pause changed", "paused", paused)
+
+		case req := <-gw.resizeReq:
+			req.done <- gw.resize(req)
+
 		case <-gw.ticker.C:
 			start := time.Now()
-			gw.tick()
+			if atomic.LoadInt32(&gw.paused) == 1 {
+				clock.reset(start)
+				continue
+			}
+			// Все шаги, которые задолжали часам, подряд (catchup.go).
+			dropped := gw.runDueTicks(clock, start)
 			duration := time.Since(start)
-			atomic.StoreInt64(&gw.tickDuration, duration.Nanoseconds())
-			metrics.TickDuration.Observe(duration.Seconds())
-			metrics.TicksTotal.Inc()
 
-			if duration > tickInterval {
+			if duration > tickInterval || dropped > 0 {
 				nowNano := time.Now().UnixNano()
 				prev := atomic.LoadInt64(&gw.lastSlowTickLog)
 				if nowNano-prev >= int64(5*time.Second) &&
@@ -238,6 +357,7 @@ func (gw *GameWorld) gameLoop() {
 					slog.Warn("slow tick detected",
 						"duration_ms", duration.Milliseconds(),
 						"budget_ms", tickInterval.Milliseconds(),
+						"dropped_ticks", dropped,
 						"players", gw.GetPlayerCount())
 				}
 			}
@@ -249,6 +369,37 @@ func (gw *GameWorld) gameLoop() {
 	}
 }
 
+// SetPaused останавливает или возобновляет симуляцию. Возвращается, когда gameLoop
+// принял запрос: после SetPaused(true) ни один тик не выполняется и снапшот стабилен.
+func (gw *GameWorld) SetPaused(paused bool) {
+	// Флаг ставится и до передачи: иначе Paused() сразу после возврата мог бы ещё
+	// видеть старое значение (gameLoop записывает его уже после приёма запроса).
+	var v int32
+	if paused {
+		v = 1
+	}
+	atomic.StoreInt32(&gw.paused, v)
+	select {
+	case gw.pauseReq <- paused:
+	case <-gw.stopChan:
+	}
+}
+
+// Paused сообщает, остановлена ли симуляция.
+func (gw *GameWorld) Paused() bool {
+	return atomic.LoadInt32(&gw.paused) == 1
+}
+
+// Step выполняет один тик синхронно в вызывающей горутине (вместе с post-tick hook и
+// учётом времени тика). Только на остановленном мире — иначе гонка с gameLoop; для
+// тестов (testutil.World).
+func (gw *GameWorld) Step() {
+	if !gw.Paused() {
+		panic("game: Step on a running world")
+	}
+	gw.runSteps(1)
+}
+
 // SetTickBroadcaster регистрирует функцию, вызываемую раз в тик со срезом
 // состояний всех игроков. Вызывается из server.New() до первого тика.
 // Функция вызывается синхронно из tick() — broadcastTick делает push() в
@@ -257,227 +408,139 @@ func (gw *GameWorld) SetTickBroadcaster(fn func(all []types.PlayerState, changed
 	gw.broadcastFn.Store(broadcastFuncHolder{fn: fn})
 }
 
-// TryAttack проверяет cooldown и запускает атаку если она разрешена.
-// Возвращает (x, y, true) если атака принята, (0, 0, false) если в cooldown.
-// Потокобезопасно: использует атомарный CAS на AttackStartTime.
-func (gw *GameWorld) TryAttack(playerID uint32) (x, y uint16, accepted bool) {
-	gw.playersMu.RLock()
-	player, ok := gw.playersMap[playerID]
-	gw.playersMu.RUnlock()
-	if !ok {
-		return 0, 0, false
-	}
+// SetPostTickHook регистрирует функцию, вызываемую синхронно из gameLoop после каждого тика.
+// Используется для работы, которую нужно выполнять не чаще раза в тик (коалесинг ACK).
+func (gw *GameWorld) SetPostTickHook(fn func()) {
+	gw.postTickFn.Store(postTickFuncHolder{fn: fn})
+}
 
-	now := time.Now().UnixNano()
-	cooldown := gw.cfg.Game.AttackDuration.Nanoseconds()
-	start := player.GetAttackStartTime()
+// SetInputTimeoutHandler регистрирует функцию, вызываемую когда игрок остановлен
+// по input timeout (и при других коррекциях позиции: телепорт, отбрасывание, resize). Вызывается из tick worker'ов параллельно — fn должна быть потокобезопасной.
+func (gw *GameWorld) SetInputTimeoutHandler(fn func(playerID uint32, x, y types.Fixed, clientTick uint32)) {
+	gw.inputTimeoutFn.Store(inputTimeoutFuncHolder{fn: fn})
+}
 
-	// Reject if still in attack cooldown
-	if start > 0 && now-start < cooldown {
+// TryAttack проверяет cooldown и состояние (см. playerstate.go) и запускает атаку,
+// если она разрешена; кого она задела, решает следующий тик (combat.go).
+// Возвращает (x, y, true) если атака принята, (0, 0, false) если
+// в cooldown, оглушён или мёртв. Потокобезопасно: переход — CAS по State.
+func (gw *GameWorld) TryAttack(playerID uint32) (x, y uint32, accepted bool) {
+	player, ok := gw.player(playerID)
+	if !ok || gw.startAttack(player, time.Now().UnixNano(), 0, 0).Rejected() {
 		return 0, 0, false
 	}
-
-	player.SetState(1)
-	player.SetAttackStartTime(now)
-	metrics.EventsProcessed.WithLabelValues("attack").Inc()
-
 	return player.GetX(), player.GetY(), true
 }
 
-// tick выполняет один тик игрового цикла.
-// За один Range обновляет позиции, собирает состояния и вычисляет дельту.
-// Scratch-буферы переиспользуются между тиками — нет аллокаций на горячем пути.
-func (gw *GameWorld) tick() {
-	// Reset scratch buffers without allocating.
-	gw.scratchStates = gw.scratchStates[:0]
-	gw.scratchChanged = gw.scratchChanged[:0]
-	clear(gw.scratchSeenIDs)
-
-	nowNano := time.Now().UnixNano()
-	attackDurNano := gw.cfg.Game.AttackDuration.Nanoseconds()
-
-	gw.tickCount++
-	// Full sync is controlled by configured SyncInterval (usually tens of seconds),
-	// not by tick rate. Full-sync every second explodes outbound traffic.
-	lastSync := atomic.LoadInt64(&gw.lastSyncTime)
-	fullSync := lastSync == 0 || time.Duration(nowNano-lastSync) >= gw.cfg.Game.SyncInterval
-	if fullSync {
-		atomic.StoreInt64(&gw.lastSyncTime, nowNano)
-		gw.lastFullSync = time.Unix(0, nowNano)
-	}
-
-	t0 := time.Now()
-	// Snapshot player pointers under a minimal RLock — only protects the map structure.
-	// All Player fields (X, Y, VX, VY, State, ...) are atomic and safe to read/write
-	// without holding the lock. Lock hold time: ~N×8ns (pointer copy) instead of ~N×200ns
-	// (atomic reads + position math), reducing contention with epoll movement writers.
-	gw.scratchPtrs = gw.scratchPtrs[:0]
-	gw.playersMu.RLock()
-	for _, p := range gw.playersMap {
-		gw.scratchPtrs = append(gw.scratchPtrs, p)
-	}
-	gw.playersMu.RUnlock()
+// Attack — TryAttack, которая говорит, почему атака не принята (ACTION_REJECTED).
+// Нулевой Rejection — принята или игрока уже нет.
+func (gw *GameWorld) Attack(playerID uint32) Rejection {
+	return gw.AttackToward(playerID, 0, 0)
+}
 
-	// Parallel position update: dispatch chunks to persistent workers (one per CPU).
-	// Workers do attack timeout + updatePlayerPosition (atomic writes to player fields).
-	// IMPORTANT: wg.Add(n) must be called BEFORE sending to channels, otherwise a fast
-	// worker could call wg.Done() before wg.Add(), causing a panic or missed wait.
-	n := gw.nTickWorkers
-	total := len(gw.scratchPtrs)
-	if total > 0 {
-		chunkSize := (total + n - 1) / n
-		activeWorkers := 0
-		for i := range gw.tickWorkerChs {
-			start := i * chunkSize
-			if start >= total {
-				break
-			}
-			activeWorkers++
-		}
-		// Add BEFORE any send — prevents Done() racing ahead of Add().
-		gw.tickWorkerWg.Add(activeWorkers)
-		for i, ch := range gw.tickWorkerChs {
-			start := i * chunkSize
-			if start >= total {
-				break
-			}
-			end := min(start+chunkSize, total)
-			ch <- tickWorkerInput{
-				ptrs:          gw.scratchPtrs[start:end],
-				nowNano:       nowNano,
-				attackDurNano: attackDurNano,
-			}
-		}
-		gw.tickWorkerWg.Wait()
+// AttackToward — Attack с прицелом: (aimX, aimY) — направление удара в мировых
+// координатах, любой длины; (0, 0) — без прицела, по направлению взгляда.
+// Зону удара проверяет следующий тик (combat.go).
+func (gw *GameWorld) AttackToward(playerID uint32, aimX, aimY int8) Rejection {
+	player, ok := gw.player(playerID)
+	if !ok {
+		return Rejection{}
 	}
+	return gw.startAttack(player, time.Now().UnixNano(), aimX, aimY)
+}
 
-	// Sequential state collection — ToState() is fast (atomic reads only).
-	// No synchronisation needed: only the gameLoop goroutine writes scratchStates.
-	for _, player := range gw.scratchPtrs {
-		st := player.ToState()
-		gw.scratchStates = append(gw.scratchStates, st)
-		gw.scratchSeenIDs[st.ID] = struct{}{}
-
-		// Delta: compare with previous tick
-		if !fullSync {
-			prev, exists := gw.prevStates[st.ID]
-			if !exists || st.X != prev.X || st.Y != prev.Y ||
-				st.VX != prev.VX || st.VY != prev.VY ||
-				st.State != prev.State || st.FacingRight != prev.FacingRight {
-				gw.scratchChanged = append(gw.scratchChanged, st)
-			}
+// AppendPlayersNear добавляет к dst ID игроков не дальше radius от игрока playerID,
+// его самого тоже; dst без изменений, если игрока нет. Потокобезопасно: позиции
+// читаются атомарно, снимок не согласован с тиком.
+func (gw *GameWorld) AppendPlayersNear(dst []uint32, playerID uint32, radius int) []uint32 {
+	player, ok := gw.player(playerID)
+	if !ok {
+		return dst
+	}
+	x, y := int(player.GetX()), int(player.GetY())
+	clamp := func(v int) uint32 { return uint32(min(max(v, 0), math.MaxUint32)) }
+	start := len(dst)
+	dst = gw.visibility.Load().AppendPlayersIn(dst, clamp(x-radius), clamp(y-radius), clamp(x+radius+1), clamp(y+radius+1))
+	near := dst[:start]
+	for _, id := range dst[start:] {
+		p, ok := gw.player(id)
+		if !ok {
+			continue
 		}
-	}
-	t1 := time.Now()
-	metrics.TickPhaseDuration.WithLabelValues("range").Observe(t1.Sub(t0).Seconds())
-	metrics.TickPhaseDuration.WithLabelValues("world_step").Observe(t1.Sub(t0).Seconds())
-	metrics.TickWorldStepDuration.Observe(t1.Sub(t0).Seconds())
-
-	// Update prevStates: remove departed players, update existing.
-	for id := range gw.prevStates {
-		if _, ok := gw.scratchSeenIDs[id]; !ok {
-			delete(gw.prevStates, id)
+		dx, dy := int(p.GetX())-x, int(p.GetY())-y
+		if dx*dx+dy*dy <= radius*radius {
+			near = append(near, id)
 		}
 	}
-	for _, st := range gw.scratchStates {
-		gw.prevStates[st.ID] = st
-	}
-	t2 := time.Now()
-	metrics.TickPhaseDuration.WithLabelValues("delta").Observe(t2.Sub(t1).Seconds())
-
-	if len(gw.scratchStates) == 0 {
-		return
-	}
-
-	// Delta metrics: how many players changed state this tick.
-	changedCount := len(gw.scratchChanged)
-	if fullSync {
-		changedCount = len(gw.scratchStates)
-	}
-	metrics.DeltaPlayersCount.Observe(float64(changedCount))
-	metrics.DeltaRatio.Set(float64(changedCount) / float64(len(gw.scratchStates)))
-
-	// No-op tick: avoid broadcasting identical state when no player changed.
-	if !fullSync && changedCount == 0 {
-		return
-	}
-
-	batchIntervalNano := gw.cfg.Game.BatchInterval.Nanoseconds()
-	shouldBroadcast := fullSync || gw.lastBroadcastNano == 0 ||
-		batchIntervalNano <= 0 || nowNano-gw.lastBroadcastNano >= batchIntervalNano
+	return near
+}
 
-	if !shouldBroadcast {
-		return
+// SetViewport сохраняет размер viewport, присланный клиентом, и пересчитывает границы.
+// Размер ограничен Net.MaxViewportWidth/Height (0 = без лимита) и размером мира, затем
+// округляется вверх до целых ячеек сетки видимости: подписка AOI меняется только
+// ячейками, и дрожание размера окна на пару пикселей её не трогает. 0×0 отключает
+// AOI-фильтр для игрока. Возвращает false, если подписка не изменилась.
+func (gw *GameWorld) SetViewport(playerID uint32, width, height uint16) bool {
+	player, ok := gw.player(playerID)
+	if !ok {
+		return false
 	}
-
-	gw.lastBroadcastNano = nowNano
-
-	// Call broadcastFn synchronously — it enqueues one push() per connection (non-blocking
-	// lock+append), then returns in microseconds. No allCopy/changedCopy allocations needed:
-	// EncodeGameState serialises scratchStates into bytes before tick() returns.
-	if holder, ok := gw.broadcastFn.Load().(broadcastFuncHolder); ok {
-		if fullSync {
-			holder.fn(gw.scratchStates, nil, true)
-		} else {
-			holder.fn(gw.scratchStates, gw.scratchChanged, false)
-		}
+	width, height = gw.clampViewSize(width, height)
+	if w, h := player.GetViewSize(); w == width && h == height {
+		metrics.ViewportUpdates.WithLabelValues("unchanged").Inc()
+		return false
 	}
-
+	player.SetViewSize(width, height)
+	gw.updateViewport(player)
+	metrics.ViewportUpdates.WithLabelValues("applied").Inc()
+	return true
 }
 
-// updatePlayerPosition обновляет позицию игрока на основе его векторов движения.
-// nowNano передаётся из tick() чтобы избежать лишних time.Now() на горячем пути.
-func (gw *GameWorld) updatePlayerPosition(player *types.Player, nowNano int64) {
-	vx := player.GetVX()
-	vy := player.GetVY()
-	if vx == 0 && vy == 0 {
-		return // Player not moving
-	}
-
-	currentX := player.GetX()
-	currentY := player.GetY()
-
-	// Calculate new position using int32 to handle negative values
-	newX32 := int32(currentX)
-	newY32 := int32(currentY)
-
-	if vx != 0 {
-		newX32 += int32(vx) * int32(gw.cfg.Game.PlayerSpeedPerTick)
+// clampViewSize приводит размер viewport клиента к тому, что сервер согласен
+// обслуживать: целые ячейки сетки видимости, но не больше лимита из конфига и мира.
+func (gw *GameWorld) clampViewSize(width, height uint16) (uint16, uint16) {
+	if width == 0 || height == 0 {
+		return 0, 0
 	}
-	if vy != 0 {
-		newY32 += int32(vy) * int32(gw.cfg.Game.PlayerSpeedPerTick)
+	b := gw.bounds.Load()
+	maxW, maxH := min(int(b.Width), math.MaxUint16), min(int(b.Height), math.MaxUint16)
+	if limit := gw.cfg.Net.MaxViewportWidth; limit > 0 {
+		maxW = min(maxW, limit)
 	}
-
-	// Apply world boundaries with clamping (matches client-side behavior)
-	maxX := int32(gw.cfg.World.MaxX)
-	minX := int32(gw.cfg.World.MinX)
-	maxY := int32(gw.cfg.World.MaxY)
-	minY := int32(gw.cfg.World.MinY)
-
-	if newX32 >= maxX {
-		newX32 = maxX
-	} else if newX32 < minX {
-		newX32 = minX
+	if limit := gw.cfg.Net.MaxViewportHeight; limit > 0 {
+		maxH = min(maxH, limit)
 	}
-
-	if newY32 >= maxY {
-		newY32 = maxY
-	} else if newY32 < minY {
-		newY32 = minY
+	if int(width) > maxW || int(height) > maxH {
+		metrics.ViewportClamped.Inc()
 	}
+	w, h := min(int(width), maxW), min(int(height), maxH)
 
-	// Convert back to uint16 after boundary checks
-	newX := uint16(newX32)
-	newY := uint16(newY32)
-
-	// Update position atomically
-	player.SetX(newX)
-	player.SetY(newY)
-	player.SetLastUpdate(nowNano)
+	vm := gw.visibility.Load()
+	cols, rows := vm.CellSpan(uint32(w), uint32(h))
+	cell := int(vm.CellSize())
+	return uint16(min(cols*cell, maxW)), uint16(min(rows*cell, maxH))
+}
 
-	if newX != currentX || newY != currentY {
-		gw.visibilityManager.MovePlayer(player.ID, newX, newY)
+// updateViewport пересчитывает границы viewport вокруг текущей позиции игрока:
+// половина размера + ViewportMargin в каждую сторону, с клампом к границам мира.
+func (gw *GameWorld) updateViewport(player *types.Player) {
+	w, h := player.GetViewSize()
+	if w == 0 {
+		return
 	}
+	margin := int32(gw.cfg.Net.ViewportMargin)
+	halfW := int32(w)/2 + margin
+	halfH := int32(h)/2 + margin
+	x := int32(player.GetX())
+	y := int32(player.GetY())
+	b := gw.bounds.Load()
+
+	player.SetViewport(types.ViewportBounds{
+		MinX: uint32(max(x-halfW, int32(b.MinX))),
+		MinY: uint32(max(y-halfH, int32(b.MinY))),
+		MaxX: uint32(min(x+halfW, int32(b.MaxX))),
+		MaxY: uint32(min(y+halfH, int32(b.MaxY))),
+	})
 }
 
 // handleEvent обрабатывает одно событие инлайн (atomic-операции, потокобезопасно)
@@ -488,16 +551,24 @@ func (gw *GameWorld) handleEvent(event types.GameEvent) {
 	if !exists {
 		return // Player no longer exists
 	}
+	gw.applyEvent(player, event)
+}
 
+// applyEvent применяет событие к игроку. MOVE и FACE приходят сюда только из
+// applyInputs, в начале тика.
+func (gw *GameWorld) applyEvent(player *types.Player, event types.GameEvent) {
 	switch event.Type {
 	case types.EventMove:
 		metrics.EventsProcessed.WithLabelValues("move").Inc()
-		// Validate movement (prevent cheating)
-		if abs(int(event.VectorX)) <= 1 && abs(int(event.VectorY)) <= 1 {
+		// Validate movement (prevent cheating); stunned, dead and frozen players cannot move.
+		if abs(int(event.VectorX)) <= 1 && abs(int(event.VectorY)) <= 1 && event.ThrottleX <= 127 && event.ThrottleY <= 127 &&
+			canAct(player.GetState()) && !player.Combat().GetFrozen() {
 			// Always update movement vectors, including stopping (0,0)
 			player.SetVX(event.VectorX)
 			player.SetVY(event.VectorY)
+			player.Velocity().SetThrottle(event.ThrottleX, event.ThrottleY)
 			player.SetClientTick(event.ClientTick)
+			player.SetLastActivity(gw.tickNowNano)
 		}
 
 	case types.EventFace:
@@ -505,14 +576,11 @@ func (gw *GameWorld) handleEvent(event types.GameEvent) {
 		player.SetFacingRight(event.FacingRight)
 
 	case types.EventAttack:
-		metrics.EventsProcessed.WithLabelValues("attack").Inc()
-		// Legacy path (via ProcessEvent queue) - TryAttack is now preferred.
-		// Guard against double-processing if called from old code paths.
-		if player.GetState() == 1 {
-			break
-		}
-		player.SetState(1)
-		player.SetAttackStartTime(time.Now().UnixNano())
+		// Legacy path (via ProcessEvent) - TryAttack is now preferred.
+		gw.startAttack(player, time.Now().UnixNano(), 0, 0)
+
+	default:
+		gw.handleAdminEvent(player, event)
 	}
 }
 
@@ -524,38 +592,23 @@ func (gw *GameWorld) GetMetrics() types.PerformanceMetrics {
 	}
 }
 
+// TickTime returns the time spent in ticks, post-tick hooks included, since the
+// world started.
+func (gw *GameWorld) TickTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&gw.tickTime))
+}
+
 // Stop останавливает игровой мир
 func (gw *GameWorld) Stop() {
 	close(gw.stopChan)
-	// Close worker channels so runTickWorker goroutines exit cleanly.
-	for _, ch := range gw.tickWorkerChs {
-		close(ch)
-	}
+	// gameLoop may be half-way through a tick: wait for it before closing the worker
+	// channels, or its next dispatch sends on a closed channel.
+	<-gw.loopDone
+	// Close worker channels so tick workers exit cleanly.
+	gw.jobs.stop()
 	slog.Info("gameworld stopped")
 }
 
-// runTickWorker is a persistent goroutine (one per logical CPU) that processes
-// a chunk of players per tick: attack timeout + position update.
-// Only the CPU-heavy atomic operations run here; state snapshot (ToState + delta)
-// stays sequential in the gameLoop goroutine to avoid synchronisation on scratch slices.
-// Pattern sourced from nbio TaskPool and nakama runtime worker pool.
-func (gw *GameWorld) runTickWorker(ch chan tickWorkerInput) {
-	for input := range ch {
-		for _, player := range input.ptrs {
-			// Server-authoritative attack timeout
-			if player.GetState() == 1 {
-				start := player.GetAttackStartTime()
-				if start > 0 && input.nowNano-start >= input.attackDurNano {
-					player.SetState(0)
-					player.SetAttackStartTime(0)
-				}
-			}
-			gw.updatePlayerPosition(player, input.nowNano)
-		}
-		gw.tickWorkerWg.Done()
-	}
-}
-
 // Helper function
 func abs(x int) int {
 	if x < 0 {
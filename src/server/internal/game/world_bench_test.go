@@ -0,0 +1,33 @@
+package game_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// BenchmarkTick runs whole ticks at the 10K-player target with two thirds of the
+// players moving, so most of them cross visibility cells now and then.
+func BenchmarkTick(b *testing.B) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(prev)
+
+	cfg := testutil.Config()
+	cfg.Game.SyncInterval = 0 // keep every tick comparable: full state each time
+	var players []game.ExportedPlayer
+	for _, p := range testutil.Players(10000, cfg.World.Width, cfg.World.Height) {
+		players = append(players, game.ExportedPlayer{ID: p.ID, X: p.X, Y: p.Y, VX: p.VX, VY: p.VY, FacingRight: p.FacingRight})
+	}
+	w := testutil.NewWorld(b, cfg, players...)
+	w.SetTickBroadcaster(func(all, changed []types.PlayerState, fullSync bool) {})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.GameWorld.Step()
+	}
+}
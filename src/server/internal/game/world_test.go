@@ -0,0 +1,873 @@
+package game_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestStepFullSyncThenDeltas(t *testing.T) {
+	w := testutil.NewWorld(t, nil,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, VX: 1, FacingRight: true},
+		game.ExportedPlayer{ID: 1002, X: 600, Y: 600},
+	)
+	speed := uint32(w.Config().Game.PlayerSpeedPerTick)
+
+	tick := w.Step()
+	if !tick.Broadcast || !tick.FullSync || len(tick.All) != 2 {
+		t.Fatalf("first tick = %+v, want a full sync of 2 players", tick)
+	}
+	if p := tick.All[0]; p.ID != 1001 || p.X != 500+speed {
+		t.Fatalf("moving player = %+v, want x=%d", p, 500+speed)
+	}
+
+	tick = w.Step()
+	if tick.FullSync || len(tick.Changed) != 1 || tick.Changed[0].ID != 1001 {
+		t.Fatalf("second tick = %+v, want a delta with player 1001 only", tick)
+	}
+
+	w.Move(1001, 0, 0)
+	tick = w.Step()
+	if len(tick.Changed) != 1 || tick.Changed[0].VX != 0 || tick.Changed[0].X != 500+2*speed {
+		t.Fatalf("stop tick = %+v, want 1001 stopped at x=%d", tick, 500+2*speed)
+	}
+
+	if tick = w.Step(); tick.Broadcast {
+		t.Fatalf("idle tick broadcast %+v", tick)
+	}
+}
+
+func TestCatchUpPublishesSkippedChanges(t *testing.T) {
+	w := testutil.NewWorld(t, nil, game.ExportedPlayer{ID: 1001, X: 500, Y: 500, VX: 1, FacingRight: true})
+	speed := uint32(w.Config().Game.PlayerSpeedPerTick)
+	w.Step()
+
+	// The stop happens in the first, unpublished step; the last one has nothing new
+	// against the step before it, yet must still report it.
+	w.Move(1001, 0, 0)
+	tick := w.CatchUp(3)
+	if !tick.Broadcast || len(tick.Changed) != 1 || tick.Changed[0].VX != 0 || tick.Changed[0].X != 500+speed {
+		t.Fatalf("catch-up = %+v, want 1001 stopped at x=%d", tick, 500+speed)
+	}
+	if p, _ := w.Player(1001); p.VX != 0 {
+		t.Fatalf("snapshot = %+v, want the stopped player", p)
+	}
+}
+
+func TestStepClampsToWorldBounds(t *testing.T) {
+	cfg := testutil.Config()
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: cfg.World.MaxX - 1, Y: cfg.World.MinY + 1, VX: 1, VY: -1},
+	)
+	for range 3 {
+		w.Step()
+	}
+	p, ok := w.Player(1001)
+	if !ok {
+		t.Fatal("player missing from snapshot")
+	}
+	if p.X != cfg.World.MaxX || p.Y != cfg.World.MinY {
+		t.Fatalf("position = (%d,%d), want clamped to (%d,%d)", p.X, p.Y, cfg.World.MaxX, cfg.World.MinY)
+	}
+}
+
+func TestMovementPhysics(t *testing.T) {
+	cfg := testutil.Config() // one tick per second: units/s² are units/tick²
+	cfg.Game.PlayerSpeedPerTick = 4
+	cfg.Game.PlayerAcceleration = 2
+	cfg.Game.PlayerFriction = 1
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: 1000, Y: 1000},
+		game.ExportedPlayer{ID: 1002, X: 1000, Y: 2000},
+	)
+	xs := func(id uint32, ticks int) []uint32 {
+		var out []uint32
+		for range ticks {
+			w.Step()
+			p, _ := w.Player(id)
+			out = append(out, p.X)
+		}
+		return out
+	}
+
+	w.Move(1001, 1, 0)
+	if got, want := xs(1001, 3), []uint32{1002, 1006, 1010}; !slices.Equal(got, want) {
+		t.Errorf("speeding up: x = %v, want %v", got, want)
+	}
+	w.Move(1001, 0, 0)
+	if got, want := xs(1001, 4), []uint32{1013, 1015, 1016, 1016}; !slices.Equal(got, want) {
+		t.Errorf("gliding to a stop: x = %v, want %v", got, want)
+	}
+	if p, _ := w.Player(1001); p.VX != 0 {
+		t.Errorf("stopped player has vx %d", p.VX)
+	}
+
+	// Half an axis: the speed tops out at 64/127 of 4 units a tick.
+	w.ProcessEvent(types.GameEvent{PlayerID: 1002, Type: types.EventMove, VectorX: -1, ThrottleX: 64})
+	if got, want := xs(1002, 4), []uint32{998, 995, 993, 991}; !slices.Equal(got, want) {
+		t.Errorf("analog input: x = %v, want %v", got, want)
+	}
+}
+
+func TestInputTimeoutDecay(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.TickRate = 1000 // the timeout is counted in wall-clock ticks: 50ms
+	cfg.Game.InputTimeoutTicks = 50
+	cfg.Game.PlayerSpeedPerTick = 8
+	cfg.Game.PlayerAcceleration = 0 // no ramp: the speed follows the throttle at once
+	w := testutil.NewWorld(t, cfg, game.ExportedPlayer{ID: 1001, X: 1000, Y: 1000})
+	var stopped []uint32
+	w.SetInputTimeoutHandler(func(id uint32, _, _ types.Fixed, _ uint32) { stopped = append(stopped, id) })
+
+	w.Move(1001, 1, 0)
+	w.Step()
+	time.Sleep(60 * time.Millisecond)
+
+	var dx []uint32
+	var vx []int8
+	x := uint32(1008)
+	for range 4 {
+		w.Step()
+		p, _ := w.Player(1001)
+		dx, vx = append(dx, p.X-x), append(vx, p.VX)
+		x = p.X
+	}
+	// Throttle 127 → 63 → 31 → stop: the speed (8 × throttle/127, fractions carried)
+	// halves each tick instead of dropping to 0.
+	if want := []uint32{3, 2, 0, 0}; !slices.Equal(dx, want) {
+		t.Errorf("after the timeout: dx = %v, want %v", dx, want)
+	}
+	if want := []int8{1, 1, 0, 0}; !slices.Equal(vx, want) {
+		t.Errorf("after the timeout: vx = %v, want %v", vx, want)
+	}
+	if !slices.Equal(stopped, []uint32{1001}) {
+		t.Errorf("input timeout reported for %v, want once for 1001", stopped)
+	}
+}
+
+func TestStormSpeed(t *testing.T) {
+	storms := func(pcts ...int) *config.Config {
+		cfg := testutil.Config()
+		for i, pct := range pcts {
+			cfg.Game.WorldEvents = append(cfg.Game.WorldEvents, config.WorldEventConfig{
+				Name: fmt.Sprint("storm", i), Schedule: "* * * * *", Kind: "storm", DurationSec: 60, SpeedPercent: pct,
+			})
+		}
+		return cfg
+	}
+	// The strongest storm wins in either direction; the scheduler starts them within a second.
+	for _, tc := range []struct {
+		pcts []int
+		want int32
+	}{
+		{[]int{150, 120}, 150},
+		{[]int{150, 40}, 40},
+		{[]int{90, 200}, 200},
+	} {
+		w := testutil.NewWorld(t, storms(tc.pcts...), game.ExportedPlayer{ID: 1001, X: 1000, Y: 1000})
+		for deadline := time.Now().Add(5 * time.Second); w.SpeedPercent() == 100; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("storms %v never started", tc.pcts)
+			}
+		}
+		if got := w.SpeedPercent(); got != tc.want {
+			t.Errorf("storms %v: speed %d%%, want %d%%", tc.pcts, got, tc.want)
+		}
+		w.Move(1001, 1, 0)
+		w.Step()
+		speed := int32(w.Config().Game.PlayerSpeedPerTick)
+		if p, _ := w.Player(1001); int32(p.X)-1000 != speed*tc.want/100 {
+			t.Errorf("storms %v: moved %d units, want %d", tc.pcts, int32(p.X)-1000, speed*tc.want/100)
+		}
+	}
+}
+
+func TestResizeRelocatesAndClamps(t *testing.T) {
+	w := testutil.NewWorld(t, nil,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, VX: 1},
+		game.ExportedPlayer{ID: 1002, X: 5000, Y: 2500},
+	)
+	w.Step()
+
+	res, err := w.Resize(1000, 800, game.RelocateClamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Relocated, []uint32{1002}) {
+		t.Fatalf("relocated = %v, want [1002]", res.Relocated)
+	}
+	if b := w.Bounds(); b.Width != 1000 || b.MaxX != 1000 || b.MaxY != 800 || b.SpawnMaxX > 1000 || b.SpawnMaxY > 800 {
+		t.Fatalf("bounds = %+v, want a 1000×800 world with the spawn area inside", b)
+	}
+	for range 200 {
+		w.Step()
+	}
+	if p, _ := w.Player(1002); p.X != 1000 || p.Y != 800 {
+		t.Errorf("player 1002 at (%d,%d), want clamped to (1000,800)", p.X, p.Y)
+	}
+	if p, _ := w.Player(1001); p.X != 1000 {
+		t.Errorf("moving player at x=%d, want stopped at the new edge 1000", p.X)
+	}
+	for _, cell := range w.ZoneReport(10).TopCells {
+		if cell.X >= 1000 || cell.Y >= 800 {
+			t.Errorf("cell %+v outside the new grid", cell)
+		}
+	}
+
+	if _, err := w.Resize(1000, 800, "teleport"); err == nil {
+		t.Error("unknown relocate mode accepted")
+	}
+	if _, err := w.Resize(10, 800, game.RelocateClamp); err == nil {
+		t.Error("world smaller than a grid cell accepted")
+	}
+}
+
+func TestResizeRespawns(t *testing.T) {
+	w := testutil.NewWorld(t, nil, game.ExportedPlayer{ID: 1001, X: 5000, Y: 2500})
+	res, err := w.Resize(2000, 1000, game.RelocateSpawn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Step()
+	b := res.Bounds
+	if p, _ := w.Player(1001); p.X < b.SpawnMinX || p.X > b.SpawnMaxX || p.Y < b.SpawnMinY || p.Y > b.SpawnMaxY {
+		t.Fatalf("player at (%d,%d), want inside the spawn area %+v", p.X, p.Y, b)
+	}
+}
+
+func TestSpawnSpreadsOverAreas(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.World.SpawnAreas = []config.SpawnArea{
+		{Name: "west", MinX: 0, MinY: 0, MaxX: 200, MaxY: 200},
+		{Name: "east", MinX: 1000, MinY: 1000, MaxX: 1200, MaxY: 1200},
+	}
+	w := testutil.NewWorld(t, cfg)
+
+	// Two areas of 2×2 grid cells: 16 joins put exactly two players in every cell.
+	perCell := map[[2]uint32]int{}
+	for range 16 {
+		p := w.AddPlayer()
+		x, y := p.GetX(), p.GetY()
+		if !(x < 200 && y < 200) && !(x >= 1000 && x < 1200 && y >= 1000 && y < 1200) {
+			t.Fatalf("player spawned at (%d,%d), outside both spawn areas", x, y)
+		}
+		perCell[[2]uint32{x / 100, y / 100}]++
+	}
+	if len(perCell) != 8 {
+		t.Fatalf("players in %d cells, want all 8: %v", len(perCell), perCell)
+	}
+	for cell, n := range perCell {
+		if n != 2 {
+			t.Errorf("cell %v has %d players, want 2", cell, n)
+		}
+	}
+}
+
+func TestTeamBases(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.World.TeamsEnabled = true
+	cfg.World.TeamBases = []config.SpawnArea{
+		{Name: "red", MinX: 0, MinY: 0, MaxX: 200, MaxY: 200},
+		{Name: "blue", MinX: 1000, MinY: 1000, MaxX: 1200, MaxY: 1200},
+	}
+	w := testutil.NewWorld(t, cfg)
+
+	var red []uint32
+	for range 4 {
+		p := w.AddPlayer()
+		base := cfg.World.TeamBases[p.GetTeam()-1]
+		if x, y := p.GetX(), p.GetY(); x < base.MinX || x >= base.MaxX || y < base.MinY || y >= base.MaxY {
+			t.Fatalf("team %s player at (%d,%d), outside its base", base.Name, x, y)
+		}
+		if p.GetTeam() == 1 {
+			red = append(red, p.ID)
+		}
+	}
+	if teams := w.Teams(); teams[0].Players != 2 || teams[1].Players != 2 {
+		t.Fatalf("teams = %+v, want 2 players each", teams)
+	}
+
+	w.RemovePlayer(red[0])
+	if p := w.AddPlayer(); p.GetTeam() != 1 {
+		t.Errorf("joined team %d, want the smaller team 1", p.GetTeam())
+	}
+	if st, _ := w.AdminPlayerState(red[1]); st.Team != "red" {
+		t.Errorf("admin state team = %q, want red", st.Team)
+	}
+}
+
+func TestSpawnPoints(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.World.SpawnPoints = []config.SpawnPoint{
+		{Name: "gate", X: 150, Y: 150},
+		{Name: "tower", X: 950, Y: 950, Disabled: true},
+	}
+	w := testutil.NewWorld(t, cfg)
+
+	if p := w.AddPlayer(); p.GetX() != 150 || p.GetY() != 150 {
+		t.Fatalf("spawned at (%d,%d), want the only enabled point gate", p.GetX(), p.GetY())
+	}
+	if err := w.SetSpawnPointEnabled("tower", true); err != nil {
+		t.Fatal(err)
+	}
+	if p := w.AddPlayer(); p.GetX() != 950 {
+		t.Errorf("spawned at (%d,%d), want the emptier point tower", p.GetX(), p.GetY())
+	}
+	if err := w.SetSpawnPointEnabled("moat", true); err != game.ErrNoSpawnPoint {
+		t.Errorf("unknown point: err = %v", err)
+	}
+
+	w.SetSpawnPointEnabled("gate", false)
+	w.SetSpawnPointEnabled("tower", false)
+	b := w.Bounds()
+	if p := w.AddPlayer(); p.GetX() < b.SpawnMinX || p.GetX() >= b.SpawnMaxX {
+		t.Errorf("all points disabled: spawned at x=%d, want the spawn area", p.GetX())
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := testutil.NewWorld(t, nil,
+		game.ExportedPlayer{ID: 5, X: 300, Y: 300, VX: -1, Bot: true},
+		game.ExportedPlayer{ID: 1001, X: 700, Y: 800, VY: 1, FacingRight: true},
+	)
+	src.Step()
+	st := src.ExportState()
+
+	dst := testutil.NewWorld(t, nil, st.Players...)
+	if got := dst.ExportState(); got.NextPlayerID < 1001 {
+		t.Fatalf("NextPlayerID = %d, want at least 1001", got.NextPlayerID)
+	}
+	want, got := src.Step().All, dst.Step().All
+	if !slices.Equal(got, want) {
+		t.Fatalf("imported world diverged:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestImportStateRejectsOutOfBounds(t *testing.T) {
+	w := testutil.NewWorld(t, nil)
+	cfg := w.Config()
+	_, err := w.ImportState(&game.ExportedState{
+		Version: game.ExportedStateVersion,
+		Players: []game.ExportedPlayer{{ID: 1, X: cfg.World.Width, Y: 0}},
+	})
+	if err == nil {
+		t.Fatal("ImportState accepted a player outside the world")
+	}
+}
+
+// TestSimulationTrace pins a scripted multi-tick run: any change to movement, delta
+// detection or full-sync behaviour shows up as a golden diff.
+func TestSimulationTrace(t *testing.T) {
+	w := testutil.NewWorld(t, nil,
+		game.ExportedPlayer{ID: 1001, X: 1000, Y: 1000},
+		game.ExportedPlayer{ID: 1002, X: 1100, Y: 1000, VX: -1, FacingRight: false},
+		game.ExportedPlayer{ID: 7, X: 900, Y: 900, VY: 1, Bot: true},
+	)
+	script := map[int]func(){
+		1: func() { w.Move(1001, 1, 1) },
+		3: func() { w.Move(1002, 0, 0) },
+		4: func() { w.Move(7, 0, 0); w.Move(1001, -1, 0) },
+	}
+
+	var b strings.Builder
+	for i := range 7 {
+		if step, ok := script[i]; ok {
+			step()
+		}
+		tick := w.Step()
+		fmt.Fprintf(&b, "tick %d broadcast=%v full=%v\n", i, tick.Broadcast, tick.FullSync)
+		players := tick.Changed
+		if tick.FullSync {
+			players = tick.All
+		}
+		for _, p := range players {
+			fmt.Fprintf(&b, "  %s\n", formatPlayer(p))
+		}
+	}
+	testutil.GoldenText(t, "simulation_trace", b.String())
+}
+
+func TestPlayerStateMachine(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.AttackDuration = 20 * time.Millisecond
+	w := testutil.NewWorld(t, cfg, game.ExportedPlayer{ID: 1001, X: 500, Y: 500})
+	state := func() uint8 {
+		t.Helper()
+		w.Step()
+		p, _ := w.Player(1001)
+		return p.State
+	}
+
+	w.Move(1001, 1, 0)
+	if got := state(); got != types.StateMoving {
+		t.Fatalf("state after MOVE = %d, want moving", got)
+	}
+	if _, _, ok := w.TryAttack(1001); !ok {
+		t.Fatal("attack rejected")
+	}
+	if got := state(); got != types.StateAttacking {
+		t.Fatalf("state after ATTACK = %d, want attacking", got)
+	}
+	if !w.EndAttack(1001) || state() != types.StateMoving {
+		t.Fatal("ATTACK_END did not return the player to moving")
+	}
+	if _, _, ok := w.TryAttack(1001); ok {
+		t.Error("ATTACK_END skipped the attack cooldown")
+	}
+	time.Sleep(cfg.Game.AttackDuration)
+	if _, _, ok := w.TryAttack(1001); !ok {
+		t.Fatal("attack after the cooldown rejected")
+	}
+	time.Sleep(cfg.Game.AttackDuration)
+	if got := state(); got != types.StateMoving {
+		t.Errorf("state after AttackDuration = %d, want the attack expired", got)
+	}
+
+	if !w.Stun(1001, 20*time.Millisecond) || state() != types.StateStunned {
+		t.Fatal("stun not applied")
+	}
+	w.Move(1001, 0, 1)
+	if _, _, ok := w.TryAttack(1001); ok {
+		t.Error("stunned player attacked")
+	}
+	if p, _ := w.Player(1001); p.VX != 0 || p.VY != 0 {
+		t.Errorf("stunned player moves with v=(%d,%d)", p.VX, p.VY)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := state(); got != types.StateIdle {
+		t.Errorf("state after the stun = %d, want idle", got)
+	}
+
+	if !w.Kill(1001) || state() != types.StateDead {
+		t.Fatal("kill not applied")
+	}
+	if w.Stun(1001, time.Second) || w.EndAttack(1001) {
+		t.Error("dead player changed state")
+	}
+	if !w.Revive(1001) || state() != types.StateIdle {
+		t.Error("revive did not return the player to idle")
+	}
+}
+
+func TestAttackHits(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.AttackDuration = time.Millisecond
+	cfg.Game.MaxHP, cfg.Game.AttackDamage, cfg.Game.AttackRange = 100, 60, 60
+	cfg.Game.Knockback = 30
+	cfg.Game.RespawnDelay = 20 * time.Millisecond
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, FacingRight: true},
+		game.ExportedPlayer{ID: 1002, X: 530, Y: 500},
+		game.ExportedPlayer{ID: 1003, X: 470, Y: 500}, // behind the attacker
+	)
+	var hits []game.Hit
+	w.SetHitHandler(func(h game.Hit) { hits = append(hits, h) })
+	attack := func() {
+		t.Helper()
+		time.Sleep(cfg.Game.AttackDuration)
+		if _, _, ok := w.TryAttack(1001); !ok {
+			t.Fatal("attack rejected")
+		}
+		w.Step()
+	}
+
+	attack()
+	want := game.Hit{AttackerID: 1001, VictimID: 1002, Damage: 60, HP: 40, KnockbackX: 30}
+	if len(hits) != 1 || hits[0] != want {
+		t.Fatalf("hits = %+v, want %+v", hits, want)
+	}
+	if p, _ := w.Player(1002); p.X != 560 || p.Y != 500 {
+		t.Errorf("victim at %d,%d after the knockback, want 560,500", p.X, p.Y)
+	}
+
+	attack() // 60 units away: the edge of the reach
+	if len(hits) != 2 || hits[1].HP != 0 {
+		t.Fatalf("hits = %+v, want the second one to kill", hits)
+	}
+	if p, _ := w.Player(1002); p.State != types.StateDead || p.X != 590 {
+		t.Errorf("victim after the kill = %+v, want dead at x=590", p)
+	}
+	if st, _ := w.AdminPlayerState(1003); st.HP != 100 {
+		t.Errorf("player behind the attacker has %d HP", st.HP)
+	}
+
+	time.Sleep(cfg.Game.RespawnDelay)
+	w.Step()
+	if p, _ := w.Player(1002); p.State != types.StateIdle {
+		t.Errorf("victim state %d after RespawnDelay, want idle", p.State)
+	}
+	if st, _ := w.AdminPlayerState(1002); st.HP != 100 {
+		t.Errorf("respawned with %d HP, want 100", st.HP)
+	}
+}
+
+func TestAimedAttack(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.AttackDuration = time.Millisecond
+	cfg.Game.MaxHP, cfg.Game.AttackDamage, cfg.Game.AttackRange, cfg.Game.AttackArc = 100, 10, 60, 90
+	cfg.Game.Knockback = 0
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, FacingRight: true},
+		game.ExportedPlayer{ID: 1002, X: 520, Y: 540}, // below, inside the sector
+		game.ExportedPlayer{ID: 1003, X: 540, Y: 500}, // in front, outside the sector
+		game.ExportedPlayer{ID: 1004, X: 500, Y: 570}, // below, out of reach
+		game.ExportedPlayer{ID: 1005, X: 460, Y: 490}, // behind
+	)
+	var hit []uint32
+	w.SetHitHandler(func(h game.Hit) { hit = append(hit, h.VictimID) })
+	attack := func(aimX, aimY int8) []uint32 {
+		t.Helper()
+		hit = nil
+		time.Sleep(cfg.Game.AttackDuration)
+		if rej := w.AttackToward(1001, aimX, aimY); rej.Rejected() {
+			t.Fatalf("attack rejected: %+v", rej)
+		}
+		w.Step()
+		return hit
+	}
+
+	if got := attack(0, 127); !slices.Equal(got, []uint32{1002}) {
+		t.Errorf("aimed down hit %v, want [1002]", got)
+	}
+	if p, _ := w.Player(1001); !p.FacingRight {
+		t.Error("an aim straight down turned the attacker")
+	}
+	if got := attack(-100, -20); !slices.Equal(got, []uint32{1005}) {
+		t.Errorf("aimed left hit %v, want [1005]", got)
+	}
+	if p, _ := w.Player(1001); p.FacingRight {
+		t.Error("the attacker does not face its aim")
+	}
+	if got := attack(0, 0); !slices.Equal(got, []uint32{1005}) {
+		t.Errorf("unaimed attack facing left hit %v, want [1005]", got)
+	}
+}
+
+func TestAttackRejections(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.AttackDuration = 100 * time.Millisecond
+	cfg.Game.AttackDamage = 0
+	w := testutil.NewWorld(t, cfg, game.ExportedPlayer{ID: 1001, X: 500, Y: 500})
+	reject := func(reason uint8, minWait, maxWait time.Duration) {
+		t.Helper()
+		rej := w.Attack(1001)
+		if rej.Reason != reason || rej.RetryAfter < minWait || rej.RetryAfter > maxWait {
+			t.Errorf("Attack = %+v, want reason %d retrying in [%v, %v]", rej, reason, minWait, maxWait)
+		}
+	}
+
+	if rej := w.Attack(1001); rej.Rejected() {
+		t.Fatalf("first attack rejected: %+v", rej)
+	}
+	reject(protocol.RejectCooldown, 50*time.Millisecond, cfg.Game.AttackDuration)
+	time.Sleep(cfg.Game.AttackDuration)
+	w.Step() // the attack expires
+
+	if !w.Stun(1001, 500*time.Millisecond) {
+		t.Fatal("stun not applied")
+	}
+	reject(protocol.RejectStunned, 400*time.Millisecond, 500*time.Millisecond)
+
+	w.Kill(1001)
+	reject(protocol.RejectDead, 0, 0) // killed by an admin: no respawn scheduled
+
+	w.Revive(1001)
+	if err := w.ApplyAdminEvent(types.GameEvent{PlayerID: 1001, Type: types.EventFreeze, Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	reject(protocol.RejectFrozen, 0, 0)
+
+	if rej := w.Attack(9999); rej.Rejected() {
+		t.Errorf("Attack for a player who left = %+v, want nothing to report", rej)
+	}
+}
+
+func TestSafeZones(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Game.AttackDuration = time.Millisecond
+	cfg.Game.AttackRange = 60
+	cfg.World.SafeZones = []config.SpawnArea{{Name: "town", MinX: 450, MinY: 450, MaxX: 560, MaxY: 560}}
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, FacingRight: true},
+		game.ExportedPlayer{ID: 1002, X: 550, Y: 500},
+		game.ExportedPlayer{ID: 1003, X: 580, Y: 500}, // outside, facing 1002
+	)
+	var hits []game.Hit
+	w.SetHitHandler(func(h game.Hit) { hits = append(hits, h) })
+	safe := func(id uint32) bool {
+		p, _ := w.Player(id)
+		return p.State&types.StateFlagSafeZone != 0
+	}
+	w.Step()
+	if !safe(1001) || !safe(1002) || safe(1003) {
+		t.Fatalf("safe zone flags = %v %v %v, want true true false", safe(1001), safe(1002), safe(1003))
+	}
+	if rej := w.Attack(1001); rej.Reason != protocol.RejectSafeZone {
+		t.Errorf("attack from the safe zone = %+v, want RejectSafeZone", rej)
+	}
+	if rej := w.Attack(1003); rej.Rejected() {
+		t.Fatalf("attack from outside rejected: %+v", rej)
+	}
+	w.Step()
+	if len(hits) != 0 {
+		t.Errorf("player in the safe zone was hit: %+v", hits)
+	}
+
+	if err := w.ApplyAdminEvent(types.GameEvent{PlayerID: 1002, Type: types.EventTeleport, X: 570, Y: 500}); err != nil {
+		t.Fatal(err)
+	}
+	w.Step()
+	if safe(1002) {
+		t.Error("flag kept after leaving the safe zone")
+	}
+	time.Sleep(cfg.Game.AttackDuration)
+	if rej := w.Attack(1003); rej.Rejected() {
+		t.Fatalf("second attack rejected: %+v", rej)
+	}
+	w.Step()
+	if len(hits) != 1 || hits[0].VictimID != 1002 {
+		t.Errorf("hits = %+v, want 1002 hit once it left the zone", hits)
+	}
+	for _, p := range w.ExportState().Players {
+		if p.State&types.StateFlagSafeZone != 0 {
+			t.Errorf("exported player %d keeps the safe zone flag", p.ID)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	for _, tt := range []struct {
+		from, to uint8
+		want     bool
+	}{
+		{types.StateIdle, types.StateAttacking, true},
+		{types.StateAttacking, types.StateMoving, true},
+		{types.StateStunned, types.StateAttacking, false},
+		{types.StateDead, types.StateMoving, false},
+		{types.StateDead, types.StateIdle, true},
+		{types.StateIdle, 9, false},
+	} {
+		if got := game.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func formatPlayer(p types.PlayerState) string {
+	return fmt.Sprintf("id=%d pos=(%d,%d) v=(%d,%d) right=%v state=%d", p.ID, p.X, p.Y, p.VX, p.VY, p.FacingRight, p.State)
+}
+
+func TestRemovedPlayerKeepsOwnComponents(t *testing.T) {
+	w := testutil.NewWorld(t, nil)
+	old := w.AddPlayer()
+	old.SetVX(1)
+	w.RemovePlayer(old.ID)
+	w.Step() // the freed row is recycled at the start of a tick
+
+	fresh := w.AddPlayer()
+	if fresh.GetVX() != 0 || fresh.GetState() != types.StateIdle {
+		t.Fatalf("new player inherited vx=%d state=%d from the removed one", fresh.GetVX(), fresh.GetState())
+	}
+	x := fresh.GetX()
+	old.SetX(x + 10)
+	old.SetVX(-1)
+	if fresh.GetX() != x || fresh.GetVX() != 0 {
+		t.Fatal("a removed player's handle wrote into the new player's components")
+	}
+	if tick := w.Step(); len(tick.All) != 1 || tick.All[0].ID != fresh.ID {
+		t.Fatalf("tick players = %+v, want only %d", tick.All, fresh.ID)
+	}
+}
+
+// The tick must give the same result whatever the worker count, chunk size or
+// region sharding.
+func TestParallelTickMatchesSerial(t *testing.T) {
+	var players []game.ExportedPlayer
+	for i := range 300 {
+		players = append(players, game.ExportedPlayer{
+			ID: uint32(1001 + i),
+			X:  uint32(200 + i*13%2500),
+			Y:  uint32(200 + i*29%2500),
+			VX: int8(i%3 - 1),
+			VY: int8(i/3%3 - 1),
+		})
+	}
+	run := func(workers, chunk int, sharded bool) []types.PlayerState {
+		cfg := testutil.Config()
+		cfg.Game.TickWorkers, cfg.Game.TickChunkSize, cfg.Game.RegionSharding = workers, chunk, sharded
+		w := testutil.NewWorld(t, cfg, players...)
+		for i := range 20 {
+			if i == 10 {
+				w.Move(1001, 0, 0)
+			}
+			w.Step()
+		}
+		snap := w.AcquireSnapshot()
+		defer snap.Release()
+		out := slices.Clone(snap.Players)
+		slices.SortFunc(out, func(a, b types.PlayerState) int { return int(a.ID) - int(b.ID) })
+		return out
+	}
+
+	want := run(1, 1024, false)
+	for _, tc := range []struct {
+		workers, chunk int
+		sharded        bool
+	}{{4, 1, false}, {4, 16, false}, {8, 7, true}} {
+		if got := run(tc.workers, tc.chunk, tc.sharded); !slices.Equal(got, want) {
+			t.Fatalf("workers=%d chunk=%d sharded=%v: state differs from the serial tick", tc.workers, tc.chunk, tc.sharded)
+		}
+	}
+}
+
+// TestInputsAppliedAtTick: MOVE and FACE wait for the next tick and are then applied
+// per player in arrival order, whatever goroutines delivered them.
+func TestInputsAppliedAtTick(t *testing.T) {
+	cfg := testutil.Config()
+	speed := uint32(cfg.Game.PlayerSpeedPerTick)
+	run := func(concurrent bool) []types.PlayerState {
+		var players []game.ExportedPlayer
+		for i := range 8 {
+			players = append(players, game.ExportedPlayer{ID: uint32(1001 + i), X: 500, Y: uint32(500 + i*10)})
+		}
+		w := testutil.NewWorld(t, cfg, players...)
+		w.Step()
+
+		var wg sync.WaitGroup
+		for _, p := range players {
+			send := func() {
+				w.Move(p.ID, 1, 0)
+				w.Move(p.ID, 0, 1)
+				w.ProcessEvent(types.GameEvent{PlayerID: p.ID, Type: types.EventFace, FacingRight: true})
+				w.Move(p.ID, -1, 0)
+			}
+			if concurrent {
+				wg.Go(send)
+			} else {
+				send()
+			}
+		}
+		wg.Wait()
+		if st, _ := w.AdminPlayerState(1001); st.X != 500 {
+			t.Fatalf("input applied before the tick: x=%d", st.X)
+		}
+		w.Step()
+		snap := w.AcquireSnapshot()
+		defer snap.Release()
+		out := slices.Clone(snap.Players)
+		slices.SortFunc(out, func(a, b types.PlayerState) int { return int(a.ID) - int(b.ID) })
+		return out
+	}
+
+	want := run(false)
+	if p := want[0]; p.VX != -1 || p.VY != 0 || !p.FacingRight || p.X != 500-speed {
+		t.Fatalf("after the tick = %+v, want the last MOVE applied at x=%d", p, 500-speed)
+	}
+	if got := run(true); !slices.Equal(got, want) {
+		t.Fatalf("concurrent input = %+v, want %+v", got, want)
+	}
+}
+
+func TestSetViewportClampsToCells(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.MaxViewportWidth, cfg.Net.MaxViewportHeight = 1000, 600
+	w := testutil.NewWorld(t, cfg)
+	p := w.AddPlayer()
+
+	steps := []struct {
+		w, h         uint16
+		changed      bool
+		wantW, wantH uint16
+	}{
+		{801, 450, true, 900, 500},      // rounded up to whole 100-unit cells
+		{820, 420, false, 900, 500},     // same cells: subscription unchanged
+		{60000, 60000, true, 1000, 600}, // clamped to the configured maximum
+		{0, 500, true, 0, 0},            // AOI off
+	}
+	for _, st := range steps {
+		if changed := w.SetViewport(p.ID, st.w, st.h); changed != st.changed {
+			t.Errorf("SetViewport(%d×%d) changed = %v, want %v", st.w, st.h, changed, st.changed)
+		}
+		if gw, gh := p.GetViewSize(); gw != st.wantW || gh != st.wantH {
+			t.Errorf("SetViewport(%d×%d) view = %d×%d, want %d×%d", st.w, st.h, gw, gh, st.wantW, st.wantH)
+		}
+	}
+	if _, ok := p.GetViewport(); ok {
+		t.Error("0×0 viewport still filters")
+	}
+}
+
+func TestAdminEvents(t *testing.T) {
+	cfg := testutil.Config()
+	w := testutil.NewWorld(t, cfg,
+		game.ExportedPlayer{ID: 1001, X: 500, Y: 500, VX: 1},
+		game.ExportedPlayer{ID: 1002, X: 600, Y: 600},
+	)
+	speed := uint32(cfg.Game.PlayerSpeedPerTick)
+	apply := func(ev types.GameEvent) {
+		t.Helper()
+		if err := w.ApplyAdminEvent(ev); err != nil {
+			t.Fatalf("%+v: %v", ev, err)
+		}
+	}
+
+	apply(types.GameEvent{PlayerID: 1001, Type: types.EventSpeed, Value: 200})
+	w.Step()
+	if p, _ := w.Player(1001); p.X != 500+2*speed {
+		t.Fatalf("x at 200%% speed = %d, want %d", p.X, 500+2*speed)
+	}
+
+	apply(types.GameEvent{PlayerID: 1001, Type: types.EventTeleport, X: 100, Y: 200})
+	w.Step()
+	if p, _ := w.Player(1001); p.X != 100 || p.Y != 200 || p.VX != 0 {
+		t.Fatalf("after teleport = %+v, want stopped at 100,200", p)
+	}
+
+	apply(types.GameEvent{PlayerID: 1001, Type: types.EventFreeze, Value: 1})
+	w.Move(1001, 1, 1)
+	if _, _, ok := w.TryAttack(1001); ok {
+		t.Error("frozen player attacked")
+	}
+	w.Step()
+	if p, _ := w.Player(1001); p.X != 100 || p.Y != 200 {
+		t.Errorf("frozen player moved to %d,%d", p.X, p.Y)
+	}
+	apply(types.GameEvent{PlayerID: 1001, Type: types.EventFreeze, Value: 0})
+	w.Move(1001, 1, 0)
+	w.Step()
+	if p, _ := w.Player(1001); p.X != 100+2*speed {
+		t.Errorf("unfrozen player at x=%d, want %d", p.X, 100+2*speed)
+	}
+
+	apply(types.GameEvent{PlayerID: 1002, Type: types.EventInvulnerable, Value: 1})
+	if w.Stun(1002, time.Second) || w.Kill(1002) {
+		t.Error("invulnerable player stunned or killed")
+	}
+	w.Step()
+	if p, _ := w.Player(1002); p.State&types.StateFlagSpawnProtected == 0 {
+		t.Errorf("invulnerable player state %#x lacks the protection flag", p.State)
+	}
+	if st, _ := w.AdminPlayerState(1002); !st.Invulnerable || st.Frozen || st.SpeedPercent != 100 {
+		t.Errorf("AdminPlayerState = %+v", st)
+	}
+
+	for _, ev := range []types.GameEvent{
+		{PlayerID: 9999, Type: types.EventFreeze, Value: 1},
+		{PlayerID: 1001, Type: types.EventSpeed, Value: 0},
+		{PlayerID: 1001, Type: types.EventTeleport, X: cfg.World.MaxX + 1, Y: 10},
+		{PlayerID: 1001, Type: types.EventMove},
+	} {
+		if err := w.ApplyAdminEvent(ev); err == nil {
+			t.Errorf("%+v accepted", ev)
+		}
+	}
+}
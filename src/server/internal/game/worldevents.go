@@ -0,0 +1,203 @@
+package game
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/schedule"
+)
+
+// Scheduled global world events (gameConfig.json "worldEvents").
+//
+// A scheduler goroutine checks every cron expression once per wall-clock minute.
+// night and storm events stay active for DurationSec; announcements are instant.
+// Every start/end is passed to the world event handler, which broadcasts WORLD_EVENT;
+// ActiveWorldEvents lets the server brief newcomers on what is still running.
+
+// WorldEvent — one start/end notification (or an active event for a newcomer).
+type WorldEvent struct {
+	Kind      uint8 // protocol.WorldEvent*
+	Active    bool
+	Value     uint16        // storm: speed percent
+	Remaining time.Duration // until the event ends; 0 = instant
+	Text      string
+}
+
+// worldEventFuncHolder оборачивает обработчик событий мира для хранения в atomic.Value.
+type worldEventFuncHolder struct {
+	fn func(WorldEvent)
+}
+
+type scheduledEvent struct {
+	cfg      config.WorldEventConfig
+	kind     uint8
+	cron     *schedule.Cron
+	endsAtNs int64 // 0 = not active
+}
+
+var worldEventKinds = map[string]uint8{
+	"night":        protocol.WorldEventNight,
+	"storm":        protocol.WorldEventStorm,
+	"announcement": protocol.WorldEventAnnouncement,
+}
+
+// worldEvents — состояние планировщика; mu защищает events (scheduler vs ActiveWorldEvents).
+type worldEvents struct {
+	mu     sync.Mutex
+	events []scheduledEvent
+}
+
+// initWorldEvents разбирает конфиг событий. Некорректные события логируются и пропускаются.
+func (gw *GameWorld) initWorldEvents() {
+	metrics.WorldSpeedPercent.Set(100)
+	for _, ec := range gw.cfg.Game.WorldEvents {
+		kind, ok := worldEventKinds[ec.Kind]
+		if !ok {
+			slog.Error("world event skipped: unknown kind", "name", ec.Name, "kind", ec.Kind)
+			continue
+		}
+		cron, err := schedule.ParseCron(ec.Schedule)
+		if err != nil {
+			slog.Error("world event skipped: bad schedule", "name", ec.Name, "error", err)
+			continue
+		}
+		if kind == protocol.WorldEventStorm && (ec.SpeedPercent <= 0 || ec.SpeedPercent > 1000) {
+			slog.Error("world event skipped: storm speedPercent must be 1-1000", "name", ec.Name)
+			continue
+		}
+		if kind != protocol.WorldEventAnnouncement && ec.DurationSec <= 0 {
+			slog.Error("world event skipped: durationSec must be positive", "name", ec.Name)
+			continue
+		}
+		gw.worldEvents.events = append(gw.worldEvents.events, scheduledEvent{cfg: ec, kind: kind, cron: cron})
+	}
+	if len(gw.worldEvents.events) > 0 {
+		slog.Info("world events scheduled", "count", len(gw.worldEvents.events))
+		go gw.runWorldEvents()
+	}
+}
+
+// SetWorldEventHandler регистрирует обработчик начала/конца событий мира.
+// Вызывается из горутины планировщика.
+func (gw *GameWorld) SetWorldEventHandler(fn func(WorldEvent)) {
+	gw.worldEventFn.Store(worldEventFuncHolder{fn: fn})
+}
+
+// SpeedPercent возвращает текущий множитель скорости (100 = норма).
+func (gw *GameWorld) SpeedPercent() int32 {
+	return atomic.LoadInt32(&gw.speedPercent)
+}
+
+// ActiveWorldEvents возвращает события, которые сейчас идут, с оставшимся временем.
+func (gw *GameWorld) ActiveWorldEvents() []WorldEvent {
+	we := &gw.worldEvents
+	we.mu.Lock()
+	defer we.mu.Unlock()
+
+	nowNs := time.Now().UnixNano()
+	var active []WorldEvent
+	for i := range we.events {
+		ev := &we.events[i]
+		if ev.endsAtNs > nowNs {
+			active = append(active, ev.notification(true, time.Duration(ev.endsAtNs-nowNs)))
+		}
+	}
+	return active
+}
+
+func (ev *scheduledEvent) notification(active bool, remaining time.Duration) WorldEvent {
+	n := WorldEvent{Kind: ev.kind, Active: active, Remaining: remaining}
+	if ev.kind == protocol.WorldEventStorm {
+		n.Value = uint16(ev.cfg.SpeedPercent)
+	}
+	if active {
+		n.Text = ev.cfg.Text
+	}
+	return n
+}
+
+// runWorldEvents — планировщик: раз в секунду завершает истёкшие события и
+// на границе минуты запускает события, чьё расписание совпало.
+func (gw *GameWorld) runWorldEvents() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastMinute time.Time
+	for {
+		select {
+		case now := <-ticker.C:
+			minute := now.Truncate(time.Minute)
+			fire := !minute.Equal(lastMinute)
+			lastMinute = minute
+			gw.stepWorldEvents(now, fire)
+		case <-gw.stopChan:
+			return
+		}
+	}
+}
+
+// stepWorldEvents ends expired events and, when fire is set, starts matching ones.
+func (gw *GameWorld) stepWorldEvents(now time.Time, fire bool) {
+	we := &gw.worldEvents
+	nowNs := now.UnixNano()
+	var out []WorldEvent
+
+	we.mu.Lock()
+	for i := range we.events {
+		ev := &we.events[i]
+		if ev.endsAtNs != 0 && nowNs >= ev.endsAtNs {
+			ev.endsAtNs = 0
+			out = append(out, ev.notification(false, 0))
+			slog.Info("world event ended", "name", ev.cfg.Name)
+		}
+	}
+	if fire {
+		for i := range we.events {
+			ev := &we.events[i]
+			if !ev.cron.Matches(now) {
+				continue
+			}
+			duration := time.Duration(ev.cfg.DurationSec) * time.Second
+			if ev.kind != protocol.WorldEventAnnouncement {
+				// A re-trigger while active extends the event.
+				ev.endsAtNs = nowNs + duration.Nanoseconds()
+			} else {
+				duration = 0
+			}
+			out = append(out, ev.notification(true, duration))
+			metrics.WorldEvents.WithLabelValues(ev.cfg.Kind).Inc()
+			slog.Info("world event started", "name", ev.cfg.Name, "kind", ev.cfg.Kind)
+		}
+	}
+	gw.applyWorldModifiers(nowNs)
+	we.mu.Unlock()
+
+	if holder, ok := gw.worldEventFn.Load().(worldEventFuncHolder); ok {
+		for _, n := range out {
+			holder.fn(n)
+		}
+	}
+}
+
+// applyWorldModifiers recomputes the speed multiplier: the active storm furthest from
+// 100% wins, a slowdown or a boost alike (on a tie, the one configured first).
+// Caller holds worldEvents.mu.
+func (gw *GameWorld) applyWorldModifiers(nowNs int64) {
+	speed := int32(100)
+	for i := range gw.worldEvents.events {
+		ev := &gw.worldEvents.events[i]
+		if ev.kind != protocol.WorldEventStorm || ev.endsAtNs <= nowNs {
+			continue
+		}
+		if pct := int32(ev.cfg.SpeedPercent); abs32(pct-100) > abs32(speed-100) {
+			speed = pct
+		}
+	}
+	atomic.StoreInt32(&gw.speedPercent, speed)
+	metrics.WorldSpeedPercent.Set(float64(speed))
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/systems"
+)
+
+// Per-zone metrics (World.ZoneCols × World.ZoneRows, see metrics.ZoneGrid).
+//
+// Player counts are recomputed once per second from the tick snapshot. Tick time is
+// attributed by the tick workers: each worker splits its chunk time between zones in
+// proportion to the players it processed there, so a crowded zone shows up as hot even
+// though individual players are not timed.
+
+const zoneReportInterval = time.Second
+
+// ZoneLoad — число игроков в зоне.
+type ZoneLoad struct {
+	Zone    string `json:"zone"`
+	Players int    `json:"players"`
+}
+
+// ZoneReport — разбивка по зонам и самые населённые ячейки сетки видимости.
+type ZoneReport struct {
+	Zones    []ZoneLoad         `json:"zones"`
+	TopCells []systems.CellLoad `json:"top_cells"`
+}
+
+// zoneState — последние посчитанные населения зон для ZoneReport.
+type zoneState struct {
+	mu     sync.Mutex
+	counts []int
+}
+
+// Zones возвращает сетку зон; nil, если метрики по зонам выключены.
+func (gw *GameWorld) Zones() *metrics.ZoneGrid {
+	return gw.zones
+}
+
+// ZoneReport возвращает население зон (на последний отчёт) и до topN самых населённых ячеек.
+func (gw *GameWorld) ZoneReport(topN int) ZoneReport {
+	report := ZoneReport{TopCells: gw.visibility.Load().TopCells(topN)}
+	gw.zoneState.mu.Lock()
+	for i, n := range gw.zoneState.counts {
+		report.Zones = append(report.Zones, ZoneLoad{Zone: gw.zones.Label(i), Players: n})
+	}
+	gw.zoneState.mu.Unlock()
+	return report
+}
+
+// runZoneReport раз в секунду пересчитывает население зон по снапшоту.
+func (gw *GameWorld) runZoneReport() {
+	ticker := time.NewTicker(zoneReportInterval)
+	defer ticker.Stop()
+
+	counts := make([]int, gw.zones.Len())
+	for {
+		select {
+		case <-ticker.C:
+			clear(counts)
+			snap := gw.AcquireSnapshot()
+			for _, p := range snap.Players {
+				counts[gw.zones.Index(p.X, p.Y)]++
+			}
+			snap.Release()
+			gw.zones.SetPlayers(counts)
+
+			gw.zoneState.mu.Lock()
+			gw.zoneState.counts = append(gw.zoneState.counts[:0], counts...)
+			gw.zoneState.mu.Unlock()
+		case <-gw.stopChan:
+			return
+		}
+	}
+}
+
+// attributeZoneTick распределяет время чанка tick worker'а по зонам пропорционально
+// числу обработанных в них игроков и обнуляет counts. Только tick worker, владеющий counts.
+func (gw *GameWorld) attributeZoneTick(counts []int, elapsed time.Duration, total int) {
+	if total == 0 {
+		return
+	}
+	perPlayer := elapsed.Seconds() / float64(total)
+	for i, n := range counts {
+		if n > 0 {
+			gw.zones.AddTickSeconds(i, perPlayer*float64(n))
+			counts[i] = 0
+		}
+	}
+}
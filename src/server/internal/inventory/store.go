@@ -0,0 +1,242 @@
+// Package inventory keeps the items of player accounts and trades between them.
+//
+// Items are numbered 1..65535; what an item is belongs to the game, not to this
+// package. An inventory holds at most maxSlots different items of at most maxStack
+// each. Every change — a grant, a consumption, a whole trade — is one Entry of
+// changes that is applied all or nothing: it is checked against the counts, appended
+// as one JSON line to the log file and fsynced before it takes effect, and Open
+// replays the file. So a trade can neither half-happen nor, raced by a consumption,
+// give away an item that is gone.
+//
+// Inventories belong to accounts (internal/auth); anonymous players have none.
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/auth"
+	"pixi_game_server/internal/jsonlog"
+)
+
+// Errors of the Store operations; the text is shown to the player.
+var (
+	ErrOwner     = errors.New("only signed-in players have an inventory")
+	ErrItem      = errors.New("no such item")
+	ErrCount     = errors.New("the count must be positive")
+	ErrNotEnough = errors.New("not enough items")
+	ErrStack     = errors.New("stack full")
+	ErrSlots     = errors.New("inventory full")
+)
+
+// Change — a count added to (Delta > 0) or taken from (Delta < 0) an item of Owner.
+type Change struct {
+	Owner string `json:"owner"`
+	Item  uint16 `json:"item"`
+	Delta int64  `json:"delta"`
+}
+
+// Entry — one log record: changes applied together.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason"` // "grant", "consume", "trade", or what the caller says
+	Changes []Change  `json:"changes"`
+}
+
+// Stack — a count of one item.
+type Stack struct {
+	Item  uint16 `json:"item"`
+	Count uint32 `json:"count"`
+}
+
+// Store — inventories of every account. Safe for concurrent use.
+type Store struct {
+	maxSlots int
+	maxStack uint32
+
+	mu    sync.RWMutex
+	log   *jsonlog.Log[Entry] // nil: in memory only
+	items map[string]map[uint16]uint32
+}
+
+// Open loads the log at path and keeps it open for appending. An empty path gives a
+// store that lives in memory only.
+func Open(path string, maxSlots int, maxStack uint32) (*Store, error) {
+	s := &Store{
+		maxSlots: max(maxSlots, 1),
+		maxStack: max(maxStack, 1),
+		items:    make(map[string]map[uint16]uint32),
+	}
+	if path == "" {
+		return s, nil
+	}
+	log, err := jsonlog.Open(path, func(e Entry) {
+		// The log holds checked entries only; replay them even if the limits shrank.
+		s.apply(e.Changes)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("inventory: %w", err)
+	}
+	s.log = log
+	return s, nil
+}
+
+// Close closes the log file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Reopen opens the log at path for appending again after Close. The store keeps its
+// inventories: the log holds nothing they do not.
+func (s *Store) Reopen(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil || path == "" {
+		return nil
+	}
+	log, err := jsonlog.Open(path, func(Entry) {})
+	if err != nil {
+		return fmt.Errorf("inventory: %w", err)
+	}
+	s.log = log
+	return nil
+}
+
+// Grant adds n of item to owner's inventory and returns the new count.
+func (s *Store) Grant(owner string, item uint16, n uint32, reason string) (uint32, error) {
+	if n == 0 {
+		return 0, ErrCount
+	}
+	if err := s.Apply(reason, Change{Owner: owner, Item: item, Delta: int64(n)}); err != nil {
+		return 0, err
+	}
+	return s.Count(owner, item), nil
+}
+
+// Consume takes n of item from owner's inventory and returns the new count.
+func (s *Store) Consume(owner string, item uint16, n uint32, reason string) (uint32, error) {
+	if n == 0 {
+		return 0, ErrCount
+	}
+	if err := s.Apply(reason, Change{Owner: owner, Item: item, Delta: -int64(n)}); err != nil {
+		return 0, err
+	}
+	return s.Count(owner, item), nil
+}
+
+// Apply checks changes against the inventories, records them as one entry and
+// applies them; on error nothing changes.
+func (s *Store) Apply(reason string, changes ...Change) error {
+	for _, c := range changes {
+		if !auth.ValidAccount(c.Owner) {
+			return ErrOwner
+		}
+		if c.Item == 0 {
+			return ErrItem
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.check(changes); err != nil {
+		return err
+	}
+	if s.log != nil {
+		if err := s.log.Append(Entry{Time: time.Now().UTC(), Reason: reason, Changes: changes}); err != nil {
+			return err
+		}
+	}
+	s.apply(changes)
+	return nil
+}
+
+// check reports whether changes, applied in order, keep every count in
+// 0..maxStack and no inventory grows past maxSlots. Caller holds mu.
+func (s *Store) check(changes []Change) error {
+	type key struct {
+		owner string
+		item  uint16
+	}
+	counts := make(map[key]int64, len(changes))
+	slots := make(map[string]int)
+	grows := make(map[string]bool) // gets an item it did not have
+	for _, c := range changes {
+		k := key{c.Owner, c.Item}
+		n, seen := counts[k]
+		if !seen {
+			n = int64(s.items[c.Owner][c.Item])
+		}
+		if _, ok := slots[c.Owner]; !ok {
+			slots[c.Owner] = len(s.items[c.Owner])
+		}
+		after := n + c.Delta
+		switch {
+		case after < 0:
+			return ErrNotEnough
+		case after > int64(s.maxStack) || after > math.MaxUint32:
+			return ErrStack
+		}
+		if n == 0 && after > 0 {
+			slots[c.Owner]++
+			grows[c.Owner] = true
+		} else if n > 0 && after == 0 {
+			slots[c.Owner]--
+		}
+		counts[k] = after
+	}
+	for owner := range grows {
+		if slots[owner] > s.maxSlots {
+			return ErrSlots
+		}
+	}
+	return nil
+}
+
+// apply adds changes to the counts. Caller holds mu (or owns s).
+func (s *Store) apply(changes []Change) {
+	for _, c := range changes {
+		inv := s.items[c.Owner]
+		if inv == nil {
+			inv = make(map[uint16]uint32)
+			s.items[c.Owner] = inv
+		}
+		n := max(int64(inv[c.Item])+c.Delta, 0)
+		if n == 0 {
+			delete(inv, c.Item)
+		} else {
+			inv[c.Item] = uint32(min(n, math.MaxUint32))
+		}
+		if len(inv) == 0 {
+			delete(s.items, c.Owner)
+		}
+	}
+}
+
+// Count returns how many of item owner has.
+func (s *Store) Count(owner string, item uint16) uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items[owner][item]
+}
+
+// Items returns owner's inventory, sorted by item.
+func (s *Store) Items(owner string) []Stack {
+	s.mu.RLock()
+	out := make([]Stack, 0, len(s.items[owner]))
+	for item, n := range s.items[owner] {
+		out = append(out, Stack{Item: item, Count: n})
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(out, func(a, b Stack) int { return int(a.Item) - int(b.Item) })
+	return out
+}
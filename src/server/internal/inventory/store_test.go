@@ -0,0 +1,64 @@
+package inventory
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.jsonl")
+	s, err := Open(path, 2, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Grant("", 1, 1, "grant"); !errors.Is(err, ErrOwner) {
+		t.Errorf("grant to an anonymous player: %v", err)
+	}
+	if _, err := s.Grant("alice", 0, 1, "grant"); !errors.Is(err, ErrItem) {
+		t.Errorf("grant of item 0: %v", err)
+	}
+	if n, err := s.Grant("alice", 7, 60, "grant"); err != nil || n != 60 {
+		t.Fatalf("grant = %d, %v", n, err)
+	}
+	if _, err := s.Grant("alice", 7, 41, "grant"); !errors.Is(err, ErrStack) {
+		t.Errorf("grant over the stack: %v", err)
+	}
+	if _, err := s.Grant("alice", 8, 1, "grant"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Grant("alice", 9, 1, "grant"); !errors.Is(err, ErrSlots) {
+		t.Errorf("grant of a third item: %v", err)
+	}
+	if n, err := s.Consume("alice", 7, 10, "potion"); err != nil || n != 50 {
+		t.Fatalf("consume = %d, %v", n, err)
+	}
+	if _, err := s.Consume("alice", 8, 2, "consume"); !errors.Is(err, ErrNotEnough) {
+		t.Errorf("consume of more than held: %v", err)
+	}
+
+	// A batch that fails on its last change changes nothing.
+	err = s.Apply("trade",
+		Change{Owner: "alice", Item: 7, Delta: -50},
+		Change{Owner: "bob", Item: 7, Delta: 50},
+		Change{Owner: "alice", Item: 8, Delta: -5},
+	)
+	if !errors.Is(err, ErrNotEnough) || s.Count("alice", 7) != 50 || s.Count("bob", 7) != 0 {
+		t.Errorf("failed batch: %v, alice has %d, bob %d", err, s.Count("alice", 7), s.Count("bob", 7))
+	}
+	// Emptying a slot makes room in the same batch.
+	if err := s.Apply("trade", Change{Owner: "alice", Item: 8, Delta: -1}, Change{Owner: "alice", Item: 9, Delta: 3}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path, 2, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, want := s.Items("alice"), []Stack{{7, 50}, {9, 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("alice's items after reopen = %+v, want %+v", got, want)
+	}
+}
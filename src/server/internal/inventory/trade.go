@@ -0,0 +1,197 @@
+package inventory
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Errors of the Trades operations; the text is shown to the player.
+var (
+	ErrTradeSelf = errors.New("you cannot trade with yourself")
+	ErrTrading   = errors.New("already trading")
+	ErrNoRequest = errors.New("no such trade request, or it expired")
+	ErrNoTrade   = errors.New("you are not trading")
+	ErrOffers    = errors.New("too many items offered")
+)
+
+// MaxOffers — upper bound on the different items one side of a trade offers.
+const MaxOffers = 16
+
+// Trade — two players swapping items through the server. Each side offers stacks of
+// its inventory; changing an offer withdraws both confirmations, so a player never
+// confirms something else than what it gets. Side 0 asked for the trade.
+type Trade struct {
+	ID        uint32
+	Players   [2]uint32
+	Owners    [2]string // accounts
+	Offers    [2][]Stack
+	Confirmed [2]bool
+}
+
+// Side returns the side of playerID in t.
+func (t *Trade) Side(playerID uint32) int {
+	if t.Players[1] == playerID {
+		return 1
+	}
+	return 0
+}
+
+// Changes returns the inventory changes of the trade: every offered stack leaves its
+// side and reaches the other. The takes come first, so the counts checked are the
+// ones before the trade.
+func (t *Trade) Changes() []Change {
+	var takes, gives []Change
+	for side, offers := range t.Offers {
+		for _, st := range offers {
+			takes = append(takes, Change{Owner: t.Owners[side], Item: st.Item, Delta: -int64(st.Count)})
+			gives = append(gives, Change{Owner: t.Owners[1-side], Item: st.Item, Delta: int64(st.Count)})
+		}
+	}
+	return append(takes, gives...)
+}
+
+// Trades holds the open trades and pending trade requests of one server. It is not
+// safe for concurrent use.
+type Trades struct {
+	requestTTL time.Duration
+	now        func() time.Time // time.Now; tests replace it
+
+	nextID   uint32
+	byPlayer map[uint32]*Trade
+	requests map[uint32]map[uint32]tradeRequest // invitee → requester → request
+}
+
+type tradeRequest struct {
+	owner  string
+	expiry time.Time
+}
+
+// NewTrades returns an empty set of trades whose requests expire after requestTTL.
+func NewTrades(requestTTL time.Duration) *Trades {
+	return &Trades{
+		requestTTL: requestTTL,
+		now:        time.Now,
+		byPlayer:   make(map[uint32]*Trade),
+		requests:   make(map[uint32]map[uint32]tradeRequest),
+	}
+}
+
+// Of returns the trade of playerID, nil if none.
+func (ts *Trades) Of(playerID uint32) *Trade {
+	return ts.byPlayer[playerID]
+}
+
+// Len returns the number of open trades.
+func (ts *Trades) Len() int {
+	return len(ts.byPlayer) / 2
+}
+
+// Request records that from (playing as account owner) asks to trade with to.
+func (ts *Trades) Request(from uint32, owner string, to uint32) error {
+	if from == to {
+		return ErrTradeSelf
+	}
+	if ts.byPlayer[from] != nil || ts.byPlayer[to] != nil {
+		return ErrTrading
+	}
+	now := ts.now()
+	pending := ts.requests[to]
+	if pending == nil {
+		pending = make(map[uint32]tradeRequest)
+		ts.requests[to] = pending
+	}
+	for requester, r := range pending {
+		if !now.Before(r.expiry) {
+			delete(pending, requester)
+		}
+	}
+	pending[from] = tradeRequest{owner: owner, expiry: now.Add(ts.requestTTL)}
+	return nil
+}
+
+// Accept opens the trade that from asked to (playing as account owner) for.
+func (ts *Trades) Accept(to uint32, owner string, from uint32) (*Trade, error) {
+	r, ok := ts.requests[to][from]
+	if !ok || !ts.now().Before(r.expiry) {
+		delete(ts.requests[to], from)
+		return nil, ErrNoRequest
+	}
+	if ts.byPlayer[from] != nil || ts.byPlayer[to] != nil {
+		return nil, ErrTrading
+	}
+	delete(ts.requests, to)
+	delete(ts.requests[from], to)
+	ts.nextID++
+	t := &Trade{ID: ts.nextID, Players: [2]uint32{from, to}, Owners: [2]string{r.owner, owner}}
+	ts.byPlayer[from], ts.byPlayer[to] = t, t
+	return t, nil
+}
+
+// Decline drops the request of from to trade with to; false if there was none.
+func (ts *Trades) Decline(to, from uint32) bool {
+	_, ok := ts.requests[to][from]
+	delete(ts.requests[to], from)
+	if len(ts.requests[to]) == 0 {
+		delete(ts.requests, to)
+	}
+	return ok
+}
+
+// Offer sets how many of item playerID offers (0 = none) and withdraws both
+// confirmations. Whether it has them is checked when the trade completes.
+func (ts *Trades) Offer(playerID uint32, item uint16, count uint32) (*Trade, error) {
+	t := ts.byPlayer[playerID]
+	if t == nil {
+		return nil, ErrNoTrade
+	}
+	if item == 0 {
+		return nil, ErrItem
+	}
+	side := t.Side(playerID)
+	offers := slices.DeleteFunc(t.Offers[side], func(st Stack) bool { return st.Item == item })
+	if count > 0 {
+		if len(offers) >= MaxOffers {
+			return nil, ErrOffers
+		}
+		i, _ := slices.BinarySearchFunc(offers, item, func(st Stack, item uint16) int { return int(st.Item) - int(item) })
+		offers = slices.Insert(offers, i, Stack{Item: item, Count: count})
+	}
+	t.Offers[side] = offers
+	t.Confirmed = [2]bool{}
+	return t, nil
+}
+
+// Confirm records that playerID agrees to the offers as they are. done is true once
+// both sides confirmed: the caller applies t.Changes and then calls Close.
+func (ts *Trades) Confirm(playerID uint32) (t *Trade, done bool, err error) {
+	t = ts.byPlayer[playerID]
+	if t == nil {
+		return nil, false, ErrNoTrade
+	}
+	t.Confirmed[t.Side(playerID)] = true
+	return t, t.Confirmed[0] && t.Confirmed[1], nil
+}
+
+// Close ends the trade of playerID, done or cancelled, and returns it; nil if none.
+func (ts *Trades) Close(playerID uint32) *Trade {
+	t := ts.byPlayer[playerID]
+	if t != nil {
+		delete(ts.byPlayer, t.Players[0])
+		delete(ts.byPlayer, t.Players[1])
+	}
+	return t
+}
+
+// Remove forgets a player that left the server: its trade is closed and returned
+// (nil if none), and its requests, sent and received, are dropped.
+func (ts *Trades) Remove(playerID uint32) *Trade {
+	delete(ts.requests, playerID)
+	for invitee, pending := range ts.requests {
+		delete(pending, playerID)
+		if len(pending) == 0 {
+			delete(ts.requests, invitee)
+		}
+	}
+	return ts.Close(playerID)
+}
@@ -0,0 +1,77 @@
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrade(t *testing.T) {
+	ts := NewTrades(time.Minute)
+	now := time.Unix(1000, 0)
+	ts.now = func() time.Time { return now }
+	s, _ := Open("", 8, 100)
+	s.Grant("alice", 1, 10, "grant")
+	s.Grant("bob", 2, 3, "grant")
+
+	if err := ts.Request(1, "alice", 1); !errors.Is(err, ErrTradeSelf) {
+		t.Errorf("self request: %v", err)
+	}
+	if _, err := ts.Accept(2, "bob", 1); !errors.Is(err, ErrNoRequest) {
+		t.Errorf("accept without a request: %v", err)
+	}
+	if err := ts.Request(1, "alice", 2); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := ts.Accept(2, "bob", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Of(1) != tr || ts.Of(2) != tr || ts.Len() != 1 || tr.Side(2) != 1 {
+		t.Fatalf("trade not open for both: %+v", tr)
+	}
+	if err := ts.Request(3, "carol", 1); !errors.Is(err, ErrTrading) {
+		t.Errorf("request to a trading player: %v", err)
+	}
+
+	ts.Offer(1, 1, 4)
+	ts.Offer(2, 2, 3)
+	if _, done, _ := ts.Confirm(1); done {
+		t.Fatal("done after one confirmation")
+	}
+	// Bob changes the offer: alice's confirmation is withdrawn.
+	ts.Offer(2, 2, 2)
+	if _, done, _ := ts.Confirm(2); done || tr.Confirmed[0] {
+		t.Fatalf("done without alice confirming the new offer: %+v", tr)
+	}
+	ts.Confirm(1)
+	want := []Change{{"alice", 1, -4}, {"bob", 2, -2}, {"bob", 1, 4}, {"alice", 2, 2}}
+	if got := tr.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %+v, want %+v", got, want)
+	}
+	if err := s.Apply("trade", tr.Changes()...); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Close(2) != tr || ts.Of(1) != nil || ts.Len() != 0 {
+		t.Error("trade kept after Close")
+	}
+	if s.Count("alice", 2) != 2 || s.Count("bob", 1) != 4 || s.Count("bob", 2) != 1 {
+		t.Errorf("after the trade: alice %+v, bob %+v", s.Items("alice"), s.Items("bob"))
+	}
+
+	ts.Request(1, "alice", 2)
+	now = now.Add(time.Minute)
+	if _, err := ts.Accept(2, "bob", 1); !errors.Is(err, ErrNoRequest) {
+		t.Errorf("accept of an expired request: %v", err)
+	}
+	ts.Request(1, "alice", 2)
+	ts.Remove(1)
+	if _, err := ts.Accept(2, "bob", 1); !errors.Is(err, ErrNoRequest) {
+		t.Errorf("accept of a request from a player who left: %v", err)
+	}
+	ts.Request(1, "alice", 2)
+	if !ts.Decline(2, 1) || ts.Decline(2, 1) {
+		t.Error("Decline of a request, then of none")
+	}
+}
@@ -0,0 +1,145 @@
+// Package jsonlog is the append-only file behind the moderation, friend, inventory
+// and player record logs: one JSON record per line, appended and fsynced before the
+// change it records takes effect, replayed in order when the log is opened.
+//
+// An append is one write of whole lines, so a crash can leave at most the last line
+// of the file without its newline. Open cuts such a torn record off — it was never
+// synced, so nobody was told it was saved — and logs it; a complete line that does
+// not parse is corruption and fails Open.
+//
+// A log whose records are mostly superseded can be compacted with Rewrite.
+package jsonlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Log — an open log of records of type T. Not safe for concurrent use: the stores
+// call it under their own lock.
+type Log[T any] struct {
+	path    string
+	file    *os.File
+	records int // in the file
+}
+
+// Open opens the log at path, creating it, and calls replay with every record in
+// order.
+func Open[T any](path string, replay func(T)) (*Log[T], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log[T]{path: path, file: f}
+	if err := l.read(replay); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log[T]) read(replay func(T)) error {
+	r := bufio.NewReader(l.file)
+	var offset int64 // end of the last complete line
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				slog.Warn("log: cut off a partial last record", "path", l.path, "line", line, "bytes", len(data))
+				return l.file.Truncate(offset)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(data))
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var rec T
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return fmt.Errorf("%s:%d: %w", l.path, line, err)
+		}
+		replay(rec)
+		l.records++
+	}
+}
+
+// Len returns the number of records in the file, superseded ones included.
+func (l *Log[T]) Len() int {
+	return l.records
+}
+
+// Append writes records as JSON lines with one write and fsyncs the file.
+func (l *Log[T]) Append(records ...T) error {
+	buf, err := encode(records)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.records += len(records)
+	return nil
+}
+
+// Rewrite replaces the whole log with records. They are written to a temporary file
+// that is fsynced and renamed over the log, so a crash leaves either log whole.
+func (l *Log[T]) Rewrite(records []T) error {
+	buf, err := encode(records)
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// The rename itself is durable once the directory is synced.
+	if dir, err := os.Open(filepath.Dir(l.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	l.file.Close()
+	l.file, l.records = f, len(records)
+	return nil
+}
+
+func encode[T any](records []T) ([]byte, error) {
+	var buf []byte
+	for i := range records {
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	return buf, nil
+}
+
+// Close closes the file.
+func (l *Log[T]) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,98 @@
+package jsonlog
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type rec struct {
+	N int `json:"n"`
+}
+
+func replayAll(t *testing.T, path string) ([]int, error) {
+	t.Helper()
+	var got []int
+	l, err := Open(path, func(r rec) { got = append(got, r.N) })
+	if err == nil {
+		l.Close()
+	}
+	return got, err
+}
+
+func TestAppendReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := Open(path, func(rec) { t.Error("replayed a record of a new log") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(rec{1}, rec{2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(rec{3}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if got, err := replayAll(t, path); err != nil || !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("replayed %v, %v", got, err)
+	}
+}
+
+func TestTornLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n\n{\"n\":2}\n{\"n\":"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(path, func(rec) {})
+	if err != nil {
+		t.Fatalf("a torn last record failed Open: %v", err)
+	}
+	// The torn bytes are gone, so the next record starts on a line of its own.
+	if err := l.Append(rec{3}); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if got, err := replayAll(t, path); err != nil || !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("replayed %v, %v", got, err)
+	}
+}
+
+func TestCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	for _, body := range []string{"{\"n\":1}\nnot json\n{\"n\":2}\n", "{\"n\":1}\nnot json\n"} {
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := replayAll(t, path); err == nil {
+			t.Errorf("%q opened", body)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := Open(path, func(rec) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(rec{1}, rec{2}, rec{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Rewrite([]rec{{3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(rec{4}); err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != 2 {
+		t.Errorf("Len = %d, want 2", l.Len())
+	}
+	l.Close()
+	if got, err := replayAll(t, path); err != nil || !slices.Equal(got, []int{3, 4}) {
+		t.Fatalf("replayed %v, %v", got, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
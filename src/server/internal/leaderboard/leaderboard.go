@@ -0,0 +1,156 @@
+// Package leaderboard keeps player scores per metric (kills, damage dealt) and
+// answers top-N and around-me queries.
+//
+// Scores live in a Store: Redis sorted sets, shared by every server of a deployment
+// and kept across restarts, or an in-memory fallback for a single server. The game
+// loop never waits for the store: Add only accumulates deltas, and Flush — called
+// periodically by the server — writes them in one batch. Deltas that fail to flush
+// are kept for the next attempt. Every call that reaches the store takes a context
+// whose deadline bounds its I/O.
+//
+// Members are opaque strings; the server uses "account:<id>" for authenticated
+// players and "player:<id>" for anonymous ones. Ranks are 1-based, highest score
+// first; equal scores are ordered by member, descending, as Redis ZREVRANGE does.
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Metrics ranked by the leaderboard. The index in Metrics is the metric's wire ID
+// (LEADERBOARD metric field).
+const (
+	MetricKills  = "kills"
+	MetricDamage = "damage"
+)
+
+var Metrics = []string{MetricKills, MetricDamage}
+
+var ErrMetric = errors.New("leaderboard: unknown metric")
+
+// MetricID returns the wire ID of metric.
+func MetricID(metric string) (uint8, bool) {
+	for i, m := range Metrics {
+		if m == metric {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
+
+// Entry — one ranked member.
+type Entry struct {
+	Rank   int    `json:"rank"`
+	Member string `json:"member"`
+	Score  int64  `json:"score"`
+}
+
+// Store holds the scores of every metric. Calls give up when ctx is done.
+type Store interface {
+	// Add adds deltas (member → delta) to the scores of metric.
+	Add(ctx context.Context, metric string, deltas map[string]int64) error
+	// Range returns the members ranked start..stop (0-based, inclusive), highest
+	// score first. stop past the end is clamped.
+	Range(ctx context.Context, metric string, start, stop int) ([]Entry, error)
+	// Rank returns the 0-based rank of member; ok is false if it has no score.
+	Rank(ctx context.Context, metric, member string) (rank int, ok bool, err error)
+	Close() error
+}
+
+// Board buffers score changes in front of a Store.
+type Board struct {
+	store Store
+
+	mu      sync.Mutex
+	pending map[string]map[string]int64 // metric → member → delta not yet flushed
+}
+
+// New returns a Board writing to store.
+func New(store Store) *Board {
+	return &Board{store: store, pending: make(map[string]map[string]int64)}
+}
+
+// Add adds delta to member's score in metric. It does not touch the store.
+func (b *Board) Add(metric, member string, delta int64) {
+	if delta == 0 || member == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	deltas := b.pending[metric]
+	if deltas == nil {
+		deltas = make(map[string]int64)
+		b.pending[metric] = deltas
+	}
+	deltas[member] += delta
+}
+
+// Flush writes the pending deltas to the store. Metrics that fail stay pending and
+// are merged with the deltas added meanwhile; a store that failed half-way through a
+// batch may count part of it twice.
+func (b *Board) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string]map[string]int64, len(pending))
+	b.mu.Unlock()
+
+	var errs []error
+	for metric, deltas := range pending {
+		if err := b.store.Add(ctx, metric, deltas); err != nil {
+			errs = append(errs, fmt.Errorf("flush %s: %w", metric, err))
+			b.mu.Lock()
+			if newer := b.pending[metric]; newer != nil {
+				for member, d := range newer {
+					deltas[member] += d
+				}
+			}
+			b.pending[metric] = deltas
+			b.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pending returns the number of members with unflushed changes, over all metrics.
+func (b *Board) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, deltas := range b.pending {
+		n += len(deltas)
+	}
+	return n
+}
+
+// Top returns the n best members of metric.
+func (b *Board) Top(ctx context.Context, metric string, n int) ([]Entry, error) {
+	if _, ok := MetricID(metric); !ok {
+		return nil, ErrMetric
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	return b.store.Range(ctx, metric, 0, n-1)
+}
+
+// Around returns member and up to n members ranked right above and below it. ok is
+// false if member has no score in metric.
+func (b *Board) Around(ctx context.Context, metric, member string, n int) (entries []Entry, ok bool, err error) {
+	if _, known := MetricID(metric); !known {
+		return nil, false, ErrMetric
+	}
+	rank, ok, err := b.store.Rank(ctx, metric, member)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	entries, err = b.store.Range(ctx, metric, max(rank-n, 0), rank+n)
+	return entries, err == nil, err
+}
+
+// Close closes the store. Pending deltas are not flushed.
+func (b *Board) Close() error {
+	return b.store.Close()
+}
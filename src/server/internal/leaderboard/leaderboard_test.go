@@ -0,0 +1,243 @@
+package leaderboard
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkStore runs the same scores through any Store.
+func checkStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := t.Context()
+	if err := s.Add(ctx, MetricKills, map[string]int64{"account:alice": 3, "account:bob": 5, "player:7": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(ctx, MetricKills, map[string]int64{"account:alice": 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Range(ctx, MetricKills, 0, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Rank: 1, Member: "account:alice", Score: 7},
+		{Rank: 2, Member: "account:bob", Score: 5},
+		{Rank: 3, Member: "player:7", Score: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Range = %+v, want %+v", got, want)
+	}
+	if got, _ := s.Range(ctx, MetricKills, 1, 1); !reflect.DeepEqual(got, want[1:2]) {
+		t.Errorf("Range(1, 1) = %+v, want %+v", got, want[1:2])
+	}
+	if got, _ := s.Range(ctx, MetricDamage, 0, 9); len(got) != 0 {
+		t.Errorf("empty metric ranked %+v", got)
+	}
+
+	if rank, ok, err := s.Rank(ctx, MetricKills, "player:7"); err != nil || !ok || rank != 2 {
+		t.Errorf("Rank(player:7) = %d, %v, %v; want 2", rank, ok, err)
+	}
+	if _, ok, err := s.Rank(ctx, MetricKills, "account:nobody"); err != nil || ok {
+		t.Errorf("Rank of a member without a score = %v, %v", ok, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	checkStore(t, NewMemory())
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := fakeRedis(t, "hunter2")
+	s := NewRedis(addr, "hunter2", "lb:")
+	t.Cleanup(func() { s.Close() })
+	checkStore(t, s)
+
+	if _, err := NewRedis(addr, "wrong", "lb:").Range(t.Context(), MetricKills, 0, 0); err == nil {
+		t.Error("wrong password accepted")
+	}
+}
+
+func TestRedisStoreDeadline(t *testing.T) {
+	// A server that accepts and never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	s := NewRedis(ln.Addr().String(), "", "lb:")
+	t.Cleanup(func() { s.Close() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.Range(ctx, MetricKills, 0, 9); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Range past the deadline: %v, want DeadlineExceeded", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("Range took %v, want about the 50ms deadline", took)
+	}
+
+	ctx, cancel = context.WithCancel(t.Context())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := s.Add(ctx, MetricKills, map[string]int64{"player:1": 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Add cancelled: %v, want Canceled", err)
+	}
+	if err := s.Add(ctx, MetricKills, map[string]int64{"player:1": 1}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Add with a done context: %v, want Canceled", err)
+	}
+}
+
+// failingStore fails Add until ok is set.
+type failingStore struct {
+	*MemoryStore
+	ok bool
+}
+
+func (s *failingStore) Add(ctx context.Context, metric string, deltas map[string]int64) error {
+	if !s.ok {
+		return errors.New("store down")
+	}
+	return s.MemoryStore.Add(ctx, metric, deltas)
+}
+
+func TestBoardKeepsDeltasUntilFlushed(t *testing.T) {
+	ctx := t.Context()
+	store := &failingStore{MemoryStore: NewMemory()}
+	b := New(store)
+	b.Add(MetricKills, "account:alice", 1)
+	b.Add(MetricDamage, "account:alice", 20)
+	if err := b.Flush(ctx); err == nil {
+		t.Fatal("Flush hid the store error")
+	}
+	b.Add(MetricKills, "account:alice", 1)
+	b.Add(MetricKills, "", 1) // not ranked
+	if n := b.Pending(); n != 2 {
+		t.Errorf("pending = %d, want alice in both metrics", n)
+	}
+
+	store.ok = true
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if b.Pending() != 0 {
+		t.Error("deltas left after a successful flush")
+	}
+	top, _ := b.Top(ctx, MetricKills, 10)
+	if len(top) != 1 || top[0].Score != 2 {
+		t.Errorf("kills = %+v, want both kills of alice", top)
+	}
+
+	for i := range 10 {
+		b.Add(MetricDamage, "player:"+strconv.Itoa(i), int64(i))
+	}
+	b.Flush(ctx)
+	around, ok, err := b.Around(ctx, MetricDamage, "player:5", 1)
+	if err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	var members []string
+	for _, e := range around {
+		members = append(members, e.Member)
+	}
+	if !reflect.DeepEqual(members, []string{"player:6", "player:5", "player:4"}) || around[1].Rank != 6 { // alice leads with 20
+		t.Errorf("around player:5 = %+v", around)
+	}
+	if _, err := b.Top(ctx, "deaths", 10); !errors.Is(err, ErrMetric) {
+		t.Errorf("unknown metric: %v", err)
+	}
+}
+
+// fakeRedis serves the commands RedisStore uses from a MemoryStore.
+func fakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	data := NewMemory()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRedis(conn, data, password)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFakeRedis(conn net.Conn, data *MemoryStore, password string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := password == ""
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i], _ = it.(string)
+		}
+		var out string
+		switch {
+		case len(args) == 2 && args[0] == "AUTH":
+			if authed = args[1] == password; authed {
+				out = "+OK\r\n"
+			} else {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case len(args) == 4 && args[0] == "ZINCRBY":
+			d, _ := strconv.ParseInt(args[2], 10, 64)
+			data.Add(context.Background(), args[1], map[string]int64{args[3]: d})
+			out = bulk("0")
+		case len(args) == 5 && args[0] == "ZREVRANGE":
+			start, _ := strconv.Atoi(args[2])
+			stop, _ := strconv.Atoi(args[3])
+			entries, _ := data.Range(context.Background(), args[1], start, stop)
+			var b strings.Builder
+			b.WriteString("*" + strconv.Itoa(2*len(entries)) + "\r\n")
+			for _, e := range entries {
+				b.WriteString(bulk(e.Member) + bulk(strconv.FormatInt(e.Score, 10)))
+			}
+			out = b.String()
+		case len(args) == 3 && args[0] == "ZREVRANK":
+			if rank, ok, _ := data.Rank(context.Background(), args[1], args[2]); ok {
+				out = ":" + strconv.Itoa(rank) + "\r\n"
+			} else {
+				out = "$-1\r\n"
+			}
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		if _, err := conn.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
@@ -0,0 +1,78 @@
+package leaderboard
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStore keeps the scores in process memory: one server only, lost on restart.
+// Queries sort the whole metric, which is fine for the few thousand members of a
+// single server.
+type MemoryStore struct {
+	mu     sync.Mutex
+	scores map[string]map[string]int64 // metric → member → score
+}
+
+// NewMemory returns an empty MemoryStore.
+func NewMemory() *MemoryStore {
+	return &MemoryStore{scores: make(map[string]map[string]int64)}
+}
+
+func (s *MemoryStore) Add(_ context.Context, metric string, deltas map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scores := s.scores[metric]
+	if scores == nil {
+		scores = make(map[string]int64, len(deltas))
+		s.scores[metric] = scores
+	}
+	for member, d := range deltas {
+		scores[member] += d
+	}
+	return nil
+}
+
+func (s *MemoryStore) Range(_ context.Context, metric string, start, stop int) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ranked := s.ranked(metric)
+	start, stop = max(start, 0), min(stop, len(ranked)-1)
+	if start > stop {
+		return nil, nil
+	}
+	entries := ranked[start : stop+1]
+	for i := range entries {
+		entries[i].Rank = start + i + 1
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) Rank(_ context.Context, metric, member string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scores[metric][member]; !ok {
+		return 0, false, nil
+	}
+	rank := slices.IndexFunc(s.ranked(metric), func(e Entry) bool { return e.Member == member })
+	return rank, true, nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
+
+// ranked returns every member of metric, best first. Called with mu held.
+func (s *MemoryStore) ranked(metric string) []Entry {
+	scores := s.scores[metric]
+	entries := make([]Entry, 0, len(scores))
+	for member, score := range scores {
+		entries = append(entries, Entry{Member: member, Score: score})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Member, a.Member)
+	})
+	return entries
+}
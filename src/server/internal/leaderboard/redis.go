@@ -0,0 +1,264 @@
+package leaderboard
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisStore keeps each metric in a Redis sorted set, key prefix+metric. It speaks
+// just enough RESP2 for ZINCRBY, ZREVRANGE and ZREVRANK over a single connection,
+// dialed on first use and again after any error; commands of one call are pipelined.
+// The deadline of a call's context is the I/O deadline of its dial and round trip,
+// and cancelling the context interrupts them.
+type RedisStore struct {
+	addr     string
+	password string // AUTH after dialing; empty = none
+	prefix   string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedis returns a store for the Redis server at addr (host:port). Nothing is
+// dialed until the first call.
+func NewRedis(addr, password, prefix string) *RedisStore {
+	return &RedisStore{addr: addr, password: password, prefix: prefix}
+}
+
+// redisError — an error reply from the server; the connection is still usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (s *RedisStore) Add(ctx context.Context, metric string, deltas map[string]int64) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	key := s.prefix + metric
+	cmds := make([][]string, 0, len(deltas))
+	for member, d := range deltas {
+		cmds = append(cmds, []string{"ZINCRBY", key, strconv.FormatInt(d, 10), member})
+	}
+	_, err := s.do(ctx, cmds...)
+	return err
+}
+
+func (s *RedisStore) Range(ctx context.Context, metric string, start, stop int) ([]Entry, error) {
+	start = max(start, 0)
+	if stop < start {
+		return nil, nil
+	}
+	replies, err := s.do(ctx, []string{"ZREVRANGE", s.prefix + metric, strconv.Itoa(start), strconv.Itoa(stop), "WITHSCORES"})
+	if err != nil {
+		return nil, err
+	}
+	items, ok := replies[0].([]any)
+	if !ok || len(items)%2 != 0 {
+		return nil, fmt.Errorf("redis: unexpected ZREVRANGE reply %v", replies[0])
+	}
+	entries := make([]Entry, 0, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		member, _ := items[i].(string)
+		raw, _ := items[i+1].(string)
+		score, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: score of %q: %w", member, err)
+		}
+		entries = append(entries, Entry{Rank: start + i/2 + 1, Member: member, Score: int64(math.Round(score))})
+	}
+	return entries, nil
+}
+
+func (s *RedisStore) Rank(ctx context.Context, metric, member string) (int, bool, error) {
+	replies, err := s.do(ctx, []string{"ZREVRANK", s.prefix + metric, member})
+	if err != nil {
+		return 0, false, err
+	}
+	switch rank := replies[0].(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return int(rank), true, nil
+	default:
+		return 0, false, fmt.Errorf("redis: unexpected ZREVRANK reply %v", rank)
+	}
+}
+
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn, s.r = nil, nil
+	return err
+}
+
+// do sends cmds in one write and reads a reply for each. The first error reply is
+// returned after all replies are read; an I/O or protocol error drops the connection.
+func (s *RedisStore) do(ctx context.Context, cmds ...[]string) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := s.roundTrip(ctx, cmds)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		s.conn.Close()
+		s.conn, s.r = nil, nil
+	}
+	return replies, err
+}
+
+func (s *RedisStore) dial(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	s.conn, s.r = conn, bufio.NewReader(conn)
+	if s.password == "" {
+		return nil
+	}
+	if _, err := s.roundTrip(ctx, [][]string{{"AUTH", s.password}}); err != nil {
+		conn.Close()
+		s.conn, s.r = nil, nil
+		return err
+	}
+	return nil
+}
+
+func (s *RedisStore) roundTrip(ctx context.Context, cmds [][]string) (replies []any, err error) {
+	deadline, hasDeadline := ctx.Deadline()
+	conn := s.conn
+	conn.SetDeadline(deadline) // zero = none
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer func() {
+		stop()
+		var replyErr redisError
+		if err == nil || errors.As(err, &replyErr) {
+			return
+		}
+		// Cancelled or timed out half-way: report why rather than the I/O timeout it
+		// caused. The socket deadline can fire before the context's own timer, so a
+		// timeout past the deadline counts as DeadlineExceeded even while ctx.Err()
+		// is still nil.
+		var netErr net.Error
+		switch {
+		case ctx.Err() != nil:
+			err = fmt.Errorf("redis: %w", ctx.Err())
+		case hasDeadline && errors.As(err, &netErr) && netErr.Timeout() && !time.Now().Before(deadline):
+			err = fmt.Errorf("redis: %w", context.DeadlineExceeded)
+		}
+	}()
+
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = appendCommand(buf, cmd)
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	replies = make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := readReply(s.r)
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := reply.(redisError); ok && firstErr == nil {
+			firstErr = e
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// appendCommand appends cmd as a RESP array of bulk strings.
+func appendCommand(buf []byte, cmd []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(cmd)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range cmd {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// maxBulkLen — replies larger than this are treated as a broken stream.
+const maxBulkLen = 1 << 20
+
+// readReply reads one RESP2 reply: string (simple or bulk), int64, redisError, nil
+// (null bulk or array) or []any.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulkLen {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulkLen {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
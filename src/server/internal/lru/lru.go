@@ -0,0 +1,191 @@
+// Package lru is a bounded, sharded least-recently-used cache whose entries also
+// expire after a period without use.
+//
+// Keys are spread over shards by hash, each with its own mutex, so lookups of
+// different keys rarely contend. Every shard holds at most its share of the capacity:
+// adding to a full shard evicts its least recently used entry in O(1), and an entry
+// not touched for the TTL is dropped on the next access to its shard. Memory stays
+// proportional to the capacity however many distinct keys pass through.
+//
+// Expiry is idle time, not age: Get and GetOrAdd refresh it. Because the recency
+// list is ordered by last use, expired entries gather at its tail and are removed
+// from there without scanning the rest.
+package lru
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// Cache — LRU с TTL простоя. Безопасен для конкурентного использования.
+type Cache[K comparable, V any] struct {
+	shards []shard[K, V]
+	seed   maphash.Seed
+	ttl    int64            // ns; 0 = entries never expire
+	now    func() time.Time // replaced in tests
+}
+
+type shard[K comparable, V any] struct {
+	mu    sync.Mutex
+	items map[K]*entry[K, V]
+	head  entry[K, V] // sentinel: head.next is the most recently used entry
+	limit int
+}
+
+type entry[K comparable, V any] struct {
+	key        K
+	value      V
+	used       int64 // UnixNano of the last use
+	prev, next *entry[K, V]
+}
+
+// New returns a cache of at most capacity entries split over shards (rounded down to
+// whole entries per shard, at least one). ttl <= 0 disables expiry.
+func New[K comparable, V any](capacity, shards int, ttl time.Duration) *Cache[K, V] {
+	shards = max(shards, 1)
+	c := &Cache[K, V]{
+		shards: make([]shard[K, V], shards),
+		seed:   maphash.MakeSeed(),
+		ttl:    max(int64(ttl), 0),
+		now:    time.Now,
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.items = make(map[K]*entry[K, V])
+		s.head.prev, s.head.next = &s.head, &s.head
+		s.limit = max(capacity/shards, 1)
+	}
+	return c
+}
+
+func (c *Cache[K, V]) shard(key K) *shard[K, V] {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	return &c.shards[maphash.Comparable(c.seed, key)%uint64(len(c.shards))]
+}
+
+// Get returns the value of key and marks it used.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	now := c.now().UnixNano()
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now, c.ttl)
+	e, ok := s.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	s.touch(e, now)
+	return e.value, true
+}
+
+// GetOrAdd returns the value of key, adding create() first if it is missing. create
+// runs under the shard lock, so concurrent callers for one key share a single value.
+func (c *Cache[K, V]) GetOrAdd(key K, create func() V) V {
+	now := c.now().UnixNano()
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now, c.ttl)
+	if e, ok := s.items[key]; ok {
+		s.touch(e, now)
+		return e.value
+	}
+	return s.add(key, create(), now).value
+}
+
+// Add sets the value of key and marks it used.
+func (c *Cache[K, V]) Add(key K, value V) {
+	now := c.now().UnixNano()
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now, c.ttl)
+	if e, ok := s.items[key]; ok {
+		e.value = value
+		s.touch(e, now)
+		return
+	}
+	s.add(key, value, now)
+}
+
+// Remove forgets key.
+func (c *Cache[K, V]) Remove(key K) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+}
+
+// Len returns the number of entries, counting expired ones not yet removed.
+func (c *Cache[K, V]) Len() int {
+	n := 0
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Purge forgets every entry.
+func (c *Cache[K, V]) Purge() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		clear(s.items)
+		s.head.prev, s.head.next = &s.head, &s.head
+		s.mu.Unlock()
+	}
+}
+
+// add inserts a new entry at the front, evicting the least recently used one if the
+// shard is full. Caller holds mu.
+func (s *shard[K, V]) add(key K, value V, now int64) *entry[K, V] {
+	if len(s.items) >= s.limit {
+		s.remove(s.head.prev)
+	}
+	e := &entry[K, V]{key: key, value: value}
+	s.items[key] = e
+	s.link(e)
+	e.used = now
+	return e
+}
+
+// expire removes entries unused for ttl, oldest first. Caller holds mu.
+func (s *shard[K, V]) expire(now, ttl int64) {
+	if ttl == 0 {
+		return
+	}
+	for e := s.head.prev; e != &s.head && now-e.used >= ttl; e = s.head.prev {
+		s.remove(e)
+	}
+}
+
+// touch moves e to the front. Caller holds mu.
+func (s *shard[K, V]) touch(e *entry[K, V], now int64) {
+	e.used = now
+	if s.head.next == e {
+		return
+	}
+	e.prev.next, e.next.prev = e.next, e.prev
+	s.link(e)
+}
+
+func (s *shard[K, V]) link(e *entry[K, V]) {
+	e.prev, e.next = &s.head, s.head.next
+	s.head.next.prev = e
+	s.head.next = e
+}
+
+func (s *shard[K, V]) remove(e *entry[K, V]) {
+	e.prev.next, e.next.prev = e.next, e.prev
+	e.prev, e.next = nil, nil
+	delete(s.items, e.key)
+}
@@ -0,0 +1,82 @@
+package lru
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](3, 1, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("a = %d, %v", v, ok)
+	}
+	c.Add("d", 4) // b is now the least recently used
+	if _, ok := c.Get("b"); ok {
+		t.Error("b survived eviction")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("%s evicted", k)
+		}
+	}
+	if n := c.Len(); n != 3 {
+		t.Errorf("len = %d, want 3", n)
+	}
+	c.Add("a", 10)
+	if v, _ := c.Get("a"); v != 10 || c.Len() != 3 {
+		t.Errorf("a = %d (len %d) after update, want 10 (len 3)", v, c.Len())
+	}
+	c.Remove("a")
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("len = %d after Purge", c.Len())
+	}
+}
+
+func TestExpiresIdleEntries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New[int, string](10, 1, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add(1, "one")
+	c.Add(2, "two")
+	now = now.Add(40 * time.Second)
+	c.Get(1) // refreshes 1 only
+	now = now.Add(30 * time.Second)
+	if _, ok := c.Get(2); ok {
+		t.Error("2 not expired after 70s idle")
+	}
+	if _, ok := c.Get(1); !ok {
+		t.Error("1 expired 30s after its last use")
+	}
+	now = now.Add(time.Minute)
+	if got := c.GetOrAdd(1, func() string { return "fresh" }); got != "fresh" {
+		t.Errorf("GetOrAdd after expiry = %q, want a fresh value", got)
+	}
+	if c.Len() != 1 {
+		t.Errorf("len = %d, want 1", c.Len())
+	}
+}
+
+func TestShardedCapacity(t *testing.T) {
+	c := New[int, int](64, 8, 0)
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 1000 {
+				k := g*1000 + i
+				if v := c.GetOrAdd(k, func() int { return k }); v != k {
+					t.Errorf("GetOrAdd(%d) = %d", k, v)
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if n := c.Len(); n > 64 {
+		t.Errorf("len = %d, want at most 64", n)
+	}
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metrics history: the last few minutes of a handful of key series, sampled into a
+// fixed-size ring, so /metrics/history can feed dashboard graphs in dev without an
+// external TSDB. Samples are read back from the collectors above, so the hot paths
+// record nothing extra.
+
+// History is the ring of samples. A nil *History is valid and records nothing
+// (history disabled).
+type History struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	ring []historySample
+	next int // slot of the next sample
+	n    int // samples stored, up to len(ring)
+	last historyTotals
+}
+
+type historySample struct {
+	at               int64 // Unix ms
+	players          float64
+	tickMs           float64
+	eventsPerSec     float64
+	broadcastsPerSec float64
+}
+
+// historyTotals — cumulative collector values at the previous sample; the rates
+// are differences against them.
+type historyTotals struct {
+	at         time.Time
+	tickSum    float64
+	tickCount  float64
+	events     float64
+	broadcasts float64
+}
+
+// HistorySeries is a compact columnar time series: Time[i] belongs to Players[i],
+// TickMs[i] and so on.
+type HistorySeries struct {
+	IntervalMs       int64     `json:"interval_ms"`
+	Time             []int64   `json:"t"`       // Unix ms of each sample
+	Players          []float64 `json:"players"` // connected players
+	TickMs           []float64 `json:"tick_ms"` // mean tick duration over the interval
+	EventsPerSec     []float64 `json:"events_per_sec"`
+	BroadcastsPerSec []float64 `json:"broadcasts_per_sec"`
+}
+
+// NewHistory keeps window worth of samples taken every interval. Returns nil when
+// window or interval is not positive.
+func NewHistory(window, interval time.Duration) *History {
+	if window <= 0 || interval <= 0 {
+		return nil
+	}
+	return &History{
+		interval: interval,
+		ring:     make([]historySample, max(int(window/interval), 1)),
+	}
+}
+
+// Run samples every interval until ctx is done.
+func (h *History) Run(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	h.sample(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			h.sample(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sample reads the collectors and appends one sample. The first call only sets the
+// baseline of the rates.
+func (h *History) sample(now time.Time) {
+	players, _ := ReadCollector(PlayersConnected)
+	tickSum, tickCount := ReadCollector(TickDuration)
+	events, _ := ReadCollector(EventsProcessed)
+	_, broadcasts := ReadCollector(BroadcastPayloadBytes)
+	cur := historyTotals{at: now, tickSum: tickSum, tickCount: tickCount, events: events, broadcasts: broadcasts}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev := h.last
+	h.last = cur
+	if prev.at.IsZero() {
+		return
+	}
+	dt := now.Sub(prev.at).Seconds()
+	if dt <= 0 {
+		return
+	}
+	s := historySample{
+		at:               now.UnixMilli(),
+		players:          players,
+		eventsPerSec:     round3((events - prev.events) / dt),
+		broadcastsPerSec: round3((broadcasts - prev.broadcasts) / dt),
+	}
+	if ticks := tickCount - prev.tickCount; ticks > 0 {
+		s.tickMs = round3((tickSum - prev.tickSum) / ticks * 1000)
+	}
+	h.ring[h.next] = s
+	h.next = (h.next + 1) % len(h.ring)
+	h.n = min(h.n+1, len(h.ring))
+}
+
+// Since returns the samples taken after sinceMs (Unix ms; 0 = all), oldest first.
+func (h *History) Since(sinceMs int64) HistorySeries {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := HistorySeries{IntervalMs: h.interval.Milliseconds()}
+	for i := range h.n {
+		s := h.ring[(h.next-h.n+i+len(h.ring))%len(h.ring)]
+		if s.at <= sinceMs {
+			continue
+		}
+		out.Time = append(out.Time, s.at)
+		out.Players = append(out.Players, s.players)
+		out.TickMs = append(out.TickMs, s.tickMs)
+		out.EventsPerSec = append(out.EventsPerSec, s.eventsPerSec)
+		out.BroadcastsPerSec = append(out.BroadcastsPerSec, s.broadcastsPerSec)
+	}
+	return out
+}
+
+// ReadCollector sums the current values of every series of c: counter and gauge
+// values, or histogram sample sums, plus histogram sample counts.
+func ReadCollector(c prometheus.Collector) (value, count float64) {
+	ch := make(chan prometheus.Metric, 16)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var m dto.Metric
+	for metric := range ch {
+		m.Reset()
+		if metric.Write(&m) != nil {
+			continue
+		}
+		switch {
+		case m.Counter != nil:
+			value += m.Counter.GetValue()
+		case m.Gauge != nil:
+			value += m.Gauge.GetValue()
+		case m.Histogram != nil:
+			value += m.Histogram.GetSampleSum()
+			count += float64(m.Histogram.GetSampleCount())
+		}
+	}
+	return value, count
+}
+
+// round3 keeps three decimals, enough for graphs and much shorter in JSON.
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	if NewHistory(0, time.Second) != nil {
+		t.Fatal("zero window must disable the history")
+	}
+	h := NewHistory(3*time.Second, time.Second) // room for 3 samples
+	t0 := time.UnixMilli(1_000_000)
+
+	h.sample(t0) // baseline only
+	for i := 1; i <= 4; i++ {
+		EventsProcessed.WithLabelValues("move").Add(float64(10 * i))
+		TickDuration.Observe(0.002)
+		TickDuration.Observe(0.004)
+		BroadcastPayloadBytes.Observe(100)
+		h.sample(t0.Add(time.Duration(i) * time.Second))
+	}
+
+	got := h.Since(0)
+	if want := []int64{1_002_000, 1_003_000, 1_004_000}; !slices.Equal(got.Time, want) {
+		t.Fatalf("times = %v, want the last three samples %v", got.Time, want)
+	}
+	if want := []float64{20, 30, 40}; !slices.Equal(got.EventsPerSec, want) {
+		t.Errorf("events/sec = %v, want %v", got.EventsPerSec, want)
+	}
+	if want := []float64{3, 3, 3}; !slices.Equal(got.TickMs, want) {
+		t.Errorf("tick ms = %v, want %v", got.TickMs, want)
+	}
+	if want := []float64{1, 1, 1}; !slices.Equal(got.BroadcastsPerSec, want) {
+		t.Errorf("broadcasts/sec = %v, want %v", got.BroadcastsPerSec, want)
+	}
+	if got.IntervalMs != 1000 || len(got.Players) != 3 {
+		t.Errorf("interval = %d, players = %v", got.IntervalMs, got.Players)
+	}
+
+	if newer := h.Since(1_003_000); !slices.Equal(newer.Time, []int64{1_004_000}) {
+		t.Errorf("since 1003000: times = %v, want [1004000]", newer.Time)
+	}
+}
@@ -37,12 +37,101 @@ var (
 		Help: "Total number of WebSocket disconnections",
 	})
 
+	WorldEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_world_events_total",
+		Help: "Scheduled world events started, by kind",
+	}, []string{"kind"})
+
+	WorldSpeedPercent = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_world_speed_percent",
+		Help: "Current movement speed multiplier from world events (100 = normal)",
+	})
+
+	PortalTeleports = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_portal_teleports_total",
+		Help: "Players moved by map portals",
+	})
+
+	DirectoryRegistrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_directory_registrations_total",
+		Help: "Server directory registration attempts by result (ok, error)",
+	}, []string{"result"})
+
+	WorldViewRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_world_view_requests_total",
+		Help: "/world snapshot requests by format (json, binary) and /world/stream connections (stream)",
+	}, []string{"format"})
+
+	WorldViewStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_world_view_streams",
+		Help: "Open /world/stream (SSE) connections",
+	})
+
+	BotsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_bots_active",
+		Help: "Current number of server-side bot players",
+	})
+
+	BotAIDeferred = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_bot_ai_deferred_total",
+		Help: "Bot behaviour tree evaluations put off to the next pass by BOT_AI_BUDGET_MS",
+	})
+
+	PathSearchSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "game_path_search_seconds",
+		Help:    "Path searches on the collision grid by result (found, no_path, blocked)",
+		Buckets: prometheus.ExponentialBucketsRange(0.00001, 0.1, 9),
+	}, []string{"result"})
+
+	MaintenancePhase = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_maintenance_phase",
+		Help: "Maintenance mode phase (0 = off, 1 = scheduled, 2 = world paused)",
+	})
+
+	MaintenanceRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_maintenance_rejected_connections_total",
+		Help: "WebSocket connections rejected with 503 Retry-After during maintenance",
+	})
+
+	OriginRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_origin_rejected_connections_total",
+		Help: "WebSocket connections rejected with 403 because their Origin is not in ALLOWED_ORIGINS",
+	})
+
+	Handovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_handovers_total",
+		Help: "Blue/green state handovers by direction (in, out) and result (ok, error)",
+	}, []string{"direction", "result"})
+
 	SessionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "game_session_duration_seconds",
 		Help:    "Player session duration in seconds",
 		Buckets: []float64{5, 30, 60, 300, 600, 1800, 3600},
 	})
 
+	SessionBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "game_session_bytes",
+		Help:    "Bytes transferred per player session",
+		Buckets: prometheus.ExponentialBuckets(1024, 8, 8), // 1 KiB .. 2 GiB
+	}, []string{"direction"})
+
+	SessionAvgRTT = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_session_avg_rtt_seconds",
+		Help:    "Average WebSocket ping RTT per player session",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.2, 0.5, 1},
+	})
+
+	SessionDrops = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_session_drops",
+		Help:    "Messages dropped for the client per player session",
+		Buckets: []float64{0, 1, 10, 100, 1000, 10000},
+	})
+
+	SessionWebhooks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_session_webhooks_total",
+		Help: "Session summaries posted to SESSION_WEBHOOK_URL, by result (ok, error, dropped)",
+	}, []string{"result"})
+
 	// ── Game loop ─────────────────────────────────────────────────────────────
 	TickDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "game_tick_duration_seconds",
@@ -55,12 +144,72 @@ var (
 		Help: "Total number of game ticks processed",
 	})
 
+	SimulationBehind = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_simulation_behind_seconds",
+		Help: "How far the simulation lagged the wall clock when the game loop last woke up",
+	})
+
+	TicksCaughtUp = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ticks_caught_up_total",
+		Help: "Overdue ticks run back to back without a snapshot or broadcast",
+	})
+
+	TicksDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ticks_dropped_total",
+		Help: "Overdue ticks dropped beyond TICK_MAX_CATCH_UP (the simulation slowed down)",
+	})
+
+	TickDegradation = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_tick_degradation",
+		Help: "Game loop degradation: 0 = on time, 1 = catching up, 2 = dropping ticks",
+	})
+
 	// ── Events ───────────────────────────────────────────────────────────────
 	EventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "game_events_processed_total",
 		Help: "Total game events processed, by type",
 	}, []string{"type"})
 
+	InputsDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_inputs_dropped_total",
+		Help: "Total client inputs dropped because the buffer of input waiting for the next tick was full",
+	})
+
+	InputTimeouts = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_input_timeouts_total",
+		Help: "Total moving players stopped because no MOVE arrived within the input timeout",
+	})
+
+	PlayerStateRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_player_state_rejected_total",
+		Help: "Player state transitions refused by the state machine (e.g. attack while stunned), by from/to state",
+	}, []string{"from", "to"})
+
+	SpawnCrowdedFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_spawn_crowded_fallbacks_total",
+		Help: "Total spawns placed in a crowded grid cell because every cell of the spawn areas was crowded",
+	})
+
+	WorldResizes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_world_resizes_total",
+		Help: "World resizes through the admin API",
+	})
+
+	WorldRelocatedPlayers = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_world_relocated_players_total",
+		Help: "Players moved inside the bounds of a shrunk world",
+	})
+
+	AttackHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_attack_hits_total",
+		Help: "Players hit by an attack (one attack may hit several)",
+	})
+
+	PlayerKills = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_player_kills_total",
+		Help: "Players killed by attacks",
+	})
+
 	// ── Messages ─────────────────────────────────────────────────────────────
 	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "game_messages_received_total",
@@ -72,6 +221,41 @@ var (
 		Help: "Total messages dropped due to per-connection rate limiting",
 	})
 
+	ActionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_actions_rejected_total",
+		Help: "ACTION_REJECTED sent to clients, by reason: cooldown, stunned, dead, frozen, rate_limited, unknown",
+	}, []string{"reason"})
+
+	Emotes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_emotes_total",
+		Help: "EMOTEs relayed to nearby players, by emote name",
+	}, []string{"emote"})
+
+	PanicsRecovered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_panics_recovered_total",
+		Help: "Panics caught and survived, by where they happened",
+	}, []string{"where"})
+
+	ViewportUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_viewport_updates_total",
+		Help: "VIEWPORT_UPDATE messages by result: applied (AOI changed), unchanged (same cells), deferred or coalesced (rate limit)",
+	}, []string{"result"})
+
+	ViewportClamped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_viewport_clamped_total",
+		Help: "VIEWPORT_UPDATE sizes reduced to the configured maximum or the world size",
+	})
+
+	MoveAcksCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_move_acks_coalesced_total",
+		Help: "Total MOVEMENT_ACKs collapsed into a later ACK for the same player within one tick",
+	})
+
+	OutboundDeduped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_outbound_deduped_total",
+		Help: "Total outbound messages dropped by the write loop because a newer one for the same subject was queued behind them",
+	})
+
 	BytesReceived = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "game_bytes_received_total",
 		Help: "Total bytes received from clients",
@@ -138,6 +322,22 @@ var (
 		Buckets: []float64{1, 10, 50, 100, 250, 500, 1000, 2000, 5000, 10000},
 	})
 
+	ViewportFilteredPlayers = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_viewport_filtered_players",
+		Help:    "Players excluded from a viewport-filtered world-state frame, per recipient",
+		Buckets: []float64{0, 10, 50, 100, 250, 500, 1000, 2000, 5000, 10000},
+	})
+
+	InterestCutFrames = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_interest_cut_frames_total",
+		Help: "Delta frames over CLIENT_TICK_BUDGET_BYTES cut to the most relevant updates for their client",
+	})
+
+	InterestDeferred = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_interest_deferred_updates_total",
+		Help: "Player updates left out of a cut delta frame and deferred to later ticks",
+	})
+
 	FanoutRecipientLimit = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "game_fanout_recipient_limit",
 		Help: "Current adaptive recipient limit for world-state fanout per tick (0 means unlimited)",
@@ -149,27 +349,418 @@ var (
 		Help: "Total WebSocket upgrade failures",
 	})
 
+	WSSubprotocol = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_ws_subprotocol_total",
+		Help: "WebSocket upgrades by subprotocol outcome: negotiated, legacy (previous version), none (older client), rejected",
+	}, []string{"result"})
+
+	WSCloseCodes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_ws_close_codes_total",
+		Help: "Connections closed by the server with a close code, by code name",
+	}, []string{"code"})
+
+	ScriptCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_script_calls_total",
+		Help: "Script hook calls by hook and result (ok, unhandled, error, timeout, dropped)",
+	}, []string{"hook", "result"})
+
+	ScriptReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_script_reloads_total",
+		Help: "Loads of the scripts directory by result (ok, error)",
+	}, []string{"result"})
+
+	DisconnectReasons = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_disconnect_reasons_total",
+		Help: "Closed connections, handshakes included, by cause and its origin (client, network, server)",
+	}, []string{"reason", "origin"})
+
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_auth_failures_total",
+		Help: "WebSocket upgrades refused for a bad session token, by reason: missing, malformed, signature, expired",
+	}, []string{"reason"})
+
+	DuplicateSessions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_duplicate_sessions_total",
+		Help: "Accounts joining while already connected, by outcome: takeover, replaced (old session still joining), rejected",
+	}, []string{"result"})
+
+	Handshakes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_handshakes_total",
+		Help: "Connection handshakes by result (joined, timeout, invalid, duplicate, rejected)",
+	}, []string{"result"})
+
+	HandshakesPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_handshakes_pending",
+		Help: "Upgraded connections that have not sent JOIN yet",
+	})
+
 	WSReadErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "game_ws_read_errors_total",
 		Help: "Total unexpected WebSocket read errors",
 	})
 
+	WSReadBufferMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ws_read_buffer_misses_total",
+		Help: "Client frames larger than READ_BUFFER_SIZE, read into a buffer of their own",
+	})
+
+	ReadViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_ws_read_violations_total",
+		Help: "Client frames breaking the read limits: frame_too_large and slow_read close the connection, message_too_large drops the message",
+	}, []string{"reason"})
+
 	WSWriteErrors = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "game_ws_write_errors_total",
 		Help: "Total WebSocket write errors",
 	})
 
+	ClientReportedMissed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_client_reported_missed_total",
+		Help: "Server messages clients reported missing (outbound sequence gaps)",
+	})
+
+	ClientReportedMissedPerReport = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_client_reported_missed_per_report",
+		Help:    "Missing server messages per client SEQUENCE_REPORT",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100},
+	})
+
+	ChecksumFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_checksum_failures_total",
+		Help: "Messages dropped for a bad CRC-32C by direction (inbound: seen by the server, outbound: reported by clients)",
+	}, []string{"direction"})
+
+	SealedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_sealed_messages_total",
+		Help: "Encrypted (SEALED) messages by direction",
+	}, []string{"direction"})
+
+	SealedRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_sealed_rejected_total",
+		Help: "Client messages dropped by the sealing rules: failed to open, replayed counter, or a sealed type sent in plaintext",
+	})
+
+	ReliableMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_reliable_messages_total",
+		Help: "RELIABLE messages by event: sent, retransmitted, acked, expired (given up unacked)",
+	}, []string{"event"})
+
+	ScheduledActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_scheduled_actions_total",
+		Help: "Scheduled restarts and maintenance windows carried out, by action",
+	}, []string{"action"})
+
+	NextScheduledAction = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_next_scheduled_action_timestamp_seconds",
+		Help: "Unix time of the next scheduled restart or maintenance window, by action",
+	}, []string{"action"})
+
+	ChatMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_chat_messages_total",
+		Help: "Chat lines by result: relayed, party (relayed to the party), muted (refused)",
+	}, []string{"result"})
+
+	ConsoleCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_console_commands_total",
+		Help: "Console commands by command and status (ok, error, denied, unknown)",
+	}, []string{"command", "status"})
+
+	Announcements = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_announcements_total",
+		Help: "ANNOUNCE messages by target: motd (per joining client), global, zone, player (per announcement)",
+	}, []string{"target"})
+
+	ClientResyncs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_client_resyncs_total",
+		Help: "Client full-state resync requests by result (sent, throttled)",
+	}, []string{"result"})
+
+	ClientBandwidthCapBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_client_bandwidth_cap_bytes",
+		Help: "Configured per-connection outbound bandwidth budget in bytes/sec (0 = unlimited)",
+	})
+
+	ClientOutboundBytesPerSec = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_client_outbound_bytes_per_second",
+		Help:    "Measured per-connection outbound rate over each bandwidth window",
+		Buckets: prometheus.ExponentialBuckets(1024, 2, 12),
+	})
+
+	ClientsByUpdateTier = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_clients_update_tier",
+		Help: "Connections per bandwidth update tier (tier k receives every 2^k-th delta)",
+	}, []string{"tier"})
+
+	ClientUpdateTierChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_client_update_tier_changes_total",
+		Help: "Update tier changes caused by the bandwidth budget, by direction (throttle, relax)",
+	}, []string{"direction"})
+
+	// ── Client capabilities (JOIN flags) ─────────────────────────────────────
+	ClientCapabilities = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_client_capabilities_total",
+		Help: "Joined clients by effective capability (delta, full_state, compression, size_limit, minimap)",
+	}, []string{"capability"})
+
+	MinimapSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_minimap_sent_total",
+		Help: "MINIMAP messages enqueued to subscribed clients",
+	})
+
+	MinimapBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_minimap_bytes",
+		Help: "Size of the last encoded MINIMAP message (run-length encoded grid)",
+	})
+
+	LeaderboardSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_leaderboard_sent_total",
+		Help: "LEADERBOARD messages enqueued to subscribed clients",
+	})
+
+	LeaderboardErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_leaderboard_errors_total",
+		Help: "Failed leaderboard store operations, by op: flush, top (broadcast), query (/leaderboard)",
+	}, []string{"op"})
+
+	PartyActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_party_actions_total",
+		Help: "PARTY actions by action (invite, accept, leave) and result (ok, refused)",
+	}, []string{"action", "result"})
+
+	Parties = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_parties",
+		Help: "Parties on this server",
+	})
+
+	FriendActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_friend_actions_total",
+		Help: "FRIEND actions by action (add, remove) and result (ok, refused)",
+	}, []string{"action", "result"})
+
+	FriendUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_friend_updates_total",
+		Help: "FRIEND_UPDATE messages sent by status (offline, online, incoming, outgoing, removed)",
+	}, []string{"status"})
+
+	InventoryChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_inventory_changes_total",
+		Help: "Inventory changes by reason (grant, consume, admin, trade) and result (ok, refused)",
+	}, []string{"reason", "result"})
+
+	TradeActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_trade_actions_total",
+		Help: "TRADE actions by action (request, accept, offer, confirm, cancel) and result (ok, refused)",
+	}, []string{"action", "result"})
+
+	Trades = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_trades_total",
+		Help: "Trades by how they ended (completed, cancelled, failed)",
+	}, []string{"result"})
+
+	StreamedCells = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_streamed_cells_total",
+		Help: "Cells loaded onto and unloaded from cell-streaming clients",
+	}, []string{"op"})
+
+	OriginMoves = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_origin_moves_total",
+		Help: "ORIGIN messages sent to clients of a large world whose player left the middle of its wire window",
+	})
+
+	FullStateFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_full_state_fallbacks_total",
+		Help: "Full GAME_STATE frames sent in place of a delta to clients without delta support",
+	})
+
+	WorldStateChunks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_world_state_chunks_total",
+		Help: "World-state messages sent split into chunks to fit a client's max message size",
+	})
+
+	WSDeflateBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_ws_deflate_bytes_total",
+		Help: "Outbound payload bytes of permessage-deflate frames before (raw) and after (compressed) compression",
+	}, []string{"stage"})
+
+	BroadcastsDecimated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_broadcasts_decimated_total",
+		Help: "Delta broadcasts skipped for connections on a reduced update tier",
+	})
+
+	WSWriteRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ws_write_retries_total",
+		Help: "Timed-out write batches retried once with a fresh deadline",
+	})
+
+	WSPartialWrites = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ws_partial_writes_total",
+		Help: "Write batches that failed mid-frame; the connection is closed because its stream is corrupt",
+	})
+
+	// ── Accept scaling (server/reuseport.go) ──────────────────────────────────
+	ListenerAccepts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_listener_accepts_total",
+		Help: "TCP connections accepted by the game listener, by socket (REUSEPORT_LISTENERS; 0 = the only one without it)",
+	}, []string{"socket"})
+
 	// ── Connection rate limiting ───────────────────────────────────────────────
 	IPRateLimited = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "game_ip_rate_limited_total",
 		Help: "Total connection attempts rejected by IP rate limiter",
 	})
 
+	AccountRateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_account_rate_limited_total",
+		Help: "Total connection attempts rejected by the per-account rate limiter",
+	})
+
+	// ── Abuse detection ───────────────────────────────────────────────────────
+	AbuseAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_abuse_anomalies_total",
+		Help: "Sliding-window message rates above the abuse limits, by check (move_rate, message_rate, invalid_rate)",
+	}, []string{"check"})
+
+	AbuseKicks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_abuse_kicks_total",
+		Help: "Players disconnected after reaching ABUSE_STRIKES anomalies",
+	})
+
+	// ── Moderation (bans, mutes) ──────────────────────────────────────────────
+	ModerationActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_moderation_actions_total",
+		Help: "Moderation actions recorded in the audit log, by action (ban, unban, mute, unmute)",
+	}, []string{"action"})
+
+	BannedConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_banned_connections_total",
+		Help: "WebSocket connections closed with BANNED: refused at upgrade or kicked when the ban was imposed",
+	})
+
+	// ── Go runtime tuning (internal/runtimeopt) ──────────────────────────────
+	RuntimeGCPercent = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_runtime_gc_percent",
+		Help: "GC percent in effect (GOGC; -1 = off)",
+	})
+
+	RuntimeMemoryLimitBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_runtime_memory_limit_bytes",
+		Help: "Soft memory limit in effect (0 = none)",
+	})
+
+	RuntimeBallastBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_runtime_heap_ballast_bytes",
+		Help: "Size of the heap ballast allocation",
+	})
+
+	RuntimeTuningChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_runtime_tuning_changes_total",
+		Help: "Runtime tuning changes (startup and /admin/runtime)",
+	})
+
+	// ── Alerts (internal/alerts) ───────────────────────────────────────────────
+	AlertFiring = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_alert_firing",
+		Help: "1 while an alert rule has been over its threshold for its sustain period, else 0",
+	}, []string{"alert"})
+
+	AlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_alerts_fired_total",
+		Help: "Times each alert rule started firing",
+	}, []string{"alert"})
+
+	// ── Player records (internal/persist) ─────────────────────────────────────
+	PersistEnqueued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_persist_enqueued_total",
+		Help: "Player records handed to the write-behind queue",
+	})
+
+	PersistPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_persist_pending",
+		Help: "Accounts with a record waiting to be written",
+	})
+
+	PersistFlushes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_persist_batches_total",
+		Help: "Batches of player records written, by result (ok, error)",
+	}, []string{"result"})
+
+	PersistBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_persist_batch_size",
+		Help:    "Records per batch written to the player record store",
+		Buckets: []float64{1, 4, 16, 64, 256, 1024},
+	})
+
+	PersistFlushSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_persist_batch_seconds",
+		Help:    "Time to write one batch of player records",
+		Buckets: prometheus.ExponentialBucketsRange(0.0001, 5, 10),
+	})
+
+	PersistDeadLetters = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_persist_dead_letters_total",
+		Help: "Player records dropped after PERSIST_RETRIES failed writes (logged in full)",
+	})
+
+	// ── Login queue (server/loginqueue.go) ────────────────────────────────────
+	LoginQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_login_queue_length",
+		Help: "JOINs waiting in the login queue, by tier (standard, returning, vip)",
+	}, []string{"tier"})
+
+	LoginQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "game_login_queue_wait_seconds",
+		Help:    "Time admitted clients spent in the login queue, by tier",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"tier"})
+
+	LoginQueueAbandoned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_login_queue_abandoned_total",
+		Help: "Queued clients that disconnected before a slot freed up",
+	})
+
+	LoginQueueChurn = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "game_login_queue_churn",
+		Help: "Players leaving per second, smoothed; the wait estimates of QUEUE_STATUS divide by it",
+	})
+
+	// ── Tenant usage (server/usage.go) ────────────────────────────────────────
+	// tenant="host" is the server's own world. Updated every USAGE_REPORT_SEC.
+	TenantConnectionSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_tenant_connection_seconds_total",
+		Help: "Time joined players spent connected, by tenant",
+	}, []string{"tenant"})
+
+	TenantMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_tenant_messages_total",
+		Help: "Data messages received from clients, by tenant",
+	}, []string{"tenant"})
+
+	TenantBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_tenant_bytes_total",
+		Help: "WebSocket bytes by tenant and direction (in, out)",
+	}, []string{"tenant", "direction"})
+
+	TenantTickSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_tenant_tick_seconds_total",
+		Help: "Time the tenant's world spent in ticks",
+	}, []string{"tenant"})
+
+	TenantTickShare = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_tenant_tick_share",
+		Help: "Fraction of all worlds' tick time the tenant's world took in the last usage period",
+	}, []string{"tenant"})
+
+	TenantOverQuota = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_tenant_over_quota",
+		Help: "1 while the tenant is over its max_tick_share and new connections are refused",
+	}, []string{"tenant"})
+
 	// ── Tick phase breakdown ──────────────────────────────────────────────────
-	// Labels: "world_step" (snapshot + movement update + state build),
-	//         "range" (legacy alias), "delta" (prevStates diff),
+	// Labels: "input", "movement", "collision", "snapshot" (tick job system phases),
+	//         "world_step" (all four phases), "range" (legacy alias),
+	//         "delta" (gather of row states + snapshot publish),
 	//         "encode" (binary state encoding), "fanout_send" (broadcast enqueue).
-	// Sum of all four ≈ total tick duration.
+	// world_step + delta + encode + fanout_send ≈ total tick duration.
 	TickPhaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "game_tick_phase_seconds",
 		Help:    "Time spent in each phase of the game tick",
@@ -182,6 +773,12 @@ var (
 		Buckets: prometheus.ExponentialBucketsRange(0.00005, 0.25, 14),
 	})
 
+	TickShardMigrations = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "game_tick_shard_migrations",
+		Help:    "Players that crossed a region shard boundary per tick (applied in the merge step)",
+		Buckets: []float64{0, 1, 5, 10, 50, 100, 500, 1000},
+	})
+
 	TickFanoutDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "game_tick_fanout_send_seconds",
 		Help:    "Time spent enqueueing broadcast jobs to per-connection write queues",
@@ -212,6 +809,11 @@ var (
 		Buckets: []float64{1, 2, 4, 8, 16, 32, 64},
 	})
 
+	WSWritesCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "game_ws_writes_coalesced_total",
+		Help: "Write batches copied into one buffer and written with one Write instead of writev (WRITE_COALESCE_BYTES)",
+	})
+
 	WSWriteQueueDepth = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "game_ws_write_queue_depth",
 		Help:    "Observed per-connection write queue depth during world-state enqueue",
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Per-zone breakdown: the world is split into a coarse cols×rows grid and the zone
+// metrics carry a "zone" label "r<row>c<col>". The grid is deliberately coarse (label
+// cardinality = cols*rows); per-cell detail comes from the top-N report instead.
+
+var (
+	ZonePlayers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "game_zone_players",
+		Help: "Players currently in each world zone",
+	}, []string{"zone"})
+
+	ZoneTickSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_zone_tick_seconds_total",
+		Help: "Tick worker time attributed to each zone (worker time split by player share)",
+	}, []string{"zone"})
+
+	ZoneBytesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "game_zone_bytes_sent_total",
+		Help: "Bytes written to clients whose player is in each zone",
+	}, []string{"zone"})
+)
+
+// ZoneGrid maps world coordinates to zones and holds the resolved per-zone series,
+// so hot paths never go through WithLabelValues. A nil *ZoneGrid is valid and
+// records nothing (zone metrics disabled).
+type ZoneGrid struct {
+	cols, rows    int
+	width, height int
+
+	labels      []string
+	players     []prometheus.Gauge
+	tickSeconds []prometheus.Counter
+	bytesSent   []prometheus.Counter
+}
+
+// NewZoneGrid splits a width×height world into cols×rows zones. Returns nil when
+// cols or rows is not positive.
+func NewZoneGrid(width, height uint32, cols, rows int) *ZoneGrid {
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+	z := &ZoneGrid{
+		cols:   cols,
+		rows:   rows,
+		width:  max(int(width), 1),
+		height: max(int(height), 1),
+	}
+	n := cols * rows
+	z.labels = make([]string, n)
+	z.players = make([]prometheus.Gauge, n)
+	z.tickSeconds = make([]prometheus.Counter, n)
+	z.bytesSent = make([]prometheus.Counter, n)
+	for i := range n {
+		z.labels[i] = fmt.Sprintf("r%dc%d", i/cols, i%cols)
+		z.players[i] = ZonePlayers.WithLabelValues(z.labels[i])
+		z.tickSeconds[i] = ZoneTickSeconds.WithLabelValues(z.labels[i])
+		z.bytesSent[i] = ZoneBytesSent.WithLabelValues(z.labels[i])
+	}
+	return z
+}
+
+// Len returns the number of zones (0 for a nil grid).
+func (z *ZoneGrid) Len() int {
+	if z == nil {
+		return 0
+	}
+	return len(z.labels)
+}
+
+// Index returns the zone containing world point (x, y).
+func (z *ZoneGrid) Index(x, y uint32) int {
+	col := min(int(x)*z.cols/z.width, z.cols-1)
+	row := min(int(y)*z.rows/z.height, z.rows-1)
+	return row*z.cols + col
+}
+
+// Label returns the "zone" label value of zone i.
+func (z *ZoneGrid) Label(i int) string {
+	return z.labels[i]
+}
+
+// Cell returns the row and column of zone i.
+func (z *ZoneGrid) Cell(i int) (row, col int) {
+	return i / z.cols, i % z.cols
+}
+
+// HasLabel reports whether label names a zone of the grid.
+func (z *ZoneGrid) HasLabel(label string) bool {
+	return z != nil && slices.Contains(z.labels, label)
+}
+
+// SetPlayers publishes per-zone player counts (len(counts) == Len()).
+func (z *ZoneGrid) SetPlayers(counts []int) {
+	if z == nil {
+		return
+	}
+	for i, n := range counts {
+		z.players[i].Set(float64(n))
+	}
+}
+
+// AddTickSeconds attributes tick worker time to zone i.
+func (z *ZoneGrid) AddTickSeconds(i int, seconds float64) {
+	if z == nil {
+		return
+	}
+	z.tickSeconds[i].Add(seconds)
+}
+
+// AddBytesSent attributes n bytes sent to a client at (x, y).
+func (z *ZoneGrid) AddBytesSent(x, y uint32, n int64) {
+	if z == nil {
+		return
+	}
+	z.bytesSent[z.Index(x, y)].Add(float64(n))
+}
@@ -0,0 +1,242 @@
+// Package moderation keeps player bans and mutes together with the audit trail of
+// who imposed or lifted them, when and why.
+//
+// The audit log is the storage: every action is appended as one JSON line to the log
+// file and fsynced before it takes effect, and Open replays the file to rebuild the
+// active bans and mutes. Sanctions target an account ("account:<id>", see
+// internal/auth) or an address ("ip:<addr>").
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"pixi_game_server/internal/jsonlog"
+)
+
+// Action — kind of a moderation action in the audit log.
+type Action string
+
+const (
+	ActionBan    Action = "ban"
+	ActionUnban  Action = "unban"
+	ActionMute   Action = "mute"
+	ActionUnmute Action = "unmute"
+)
+
+// MaxReasonLen — upper bound on a reason in bytes; it also goes into the close frame
+// of a banned client, whose reason must fit in 123 bytes.
+const MaxReasonLen = 120
+
+var (
+	ErrTarget = errors.New(`moderation: target must be "account:<id>" or "ip:<addr>"`)
+	ErrAction = errors.New("moderation: unknown action")
+)
+
+// Entry — one audit log record.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  Action    `json:"action"`
+	Target  string    `json:"target"`
+	By      string    `json:"by"`
+	Reason  string    `json:"reason,omitempty"`
+	Expires time.Time `json:"expires,omitzero"` // ban/mute end; zero = permanent
+}
+
+// Sanction — an active ban or mute.
+type Sanction struct {
+	Target  string    `json:"target"`
+	By      string    `json:"by"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since"`
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Active reports whether the sanction is in force at now.
+func (s Sanction) Active(now time.Time) bool {
+	return s.Expires.IsZero() || now.Before(s.Expires)
+}
+
+// AccountTarget returns the target of account ("" for an anonymous player).
+func AccountTarget(account string) string {
+	if account == "" {
+		return ""
+	}
+	return "account:" + account
+}
+
+// IPTarget returns the target of an address.
+func IPTarget(ip string) string {
+	return "ip:" + ip
+}
+
+// ParseTarget validates and normalizes a target.
+func ParseTarget(target string) (string, error) {
+	kind, value, ok := strings.Cut(target, ":")
+	if !ok || value == "" {
+		return "", ErrTarget
+	}
+	switch kind {
+	case "account":
+		return target, nil
+	case "ip":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", ErrTarget
+		}
+		return IPTarget(ip.String()), nil
+	default:
+		return "", ErrTarget
+	}
+}
+
+// Store — bans, mutes and the audit log. Safe for concurrent use.
+type Store struct {
+	mu    sync.RWMutex
+	log   *jsonlog.Log[Entry] // nil: in memory only
+	bans  map[string]Sanction
+	mutes map[string]Sanction
+	audit []Entry
+}
+
+// Open loads the audit log at path and keeps it open for appending.
+// An empty path gives a store that lives in memory only.
+func Open(path string) (*Store, error) {
+	s := &Store{bans: make(map[string]Sanction), mutes: make(map[string]Sanction)}
+	if path == "" {
+		return s, nil
+	}
+	log, err := jsonlog.Open(path, func(e Entry) {
+		s.apply(e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("moderation: %w", err)
+	}
+	s.log = log
+	return s, nil
+}
+
+// Close closes the log file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Record validates e, appends it to the log and applies it. e.Target is normalized
+// and the reason truncated to MaxReasonLen; the stored entry is returned.
+func (s *Store) Record(e Entry) (Entry, error) {
+	target, err := ParseTarget(e.Target)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Target = target
+	switch e.Action {
+	case ActionBan, ActionUnban, ActionMute, ActionUnmute:
+	default:
+		return Entry{}, ErrAction
+	}
+	if len(e.Reason) > MaxReasonLen {
+		cut := MaxReasonLen
+		for cut > 0 && !utf8.RuneStart(e.Reason[cut]) {
+			cut--
+		}
+		e.Reason = e.Reason[:cut]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		if err := s.log.Append(e); err != nil {
+			return Entry{}, err
+		}
+	}
+	s.apply(e)
+	return e, nil
+}
+
+// apply updates the active sanctions with e. Caller holds mu (or owns s).
+func (s *Store) apply(e Entry) {
+	s.audit = append(s.audit, e)
+	sanction := Sanction{Target: e.Target, By: e.By, Reason: e.Reason, Since: e.Time, Expires: e.Expires}
+	switch e.Action {
+	case ActionBan:
+		s.bans[e.Target] = sanction
+	case ActionUnban:
+		delete(s.bans, e.Target)
+	case ActionMute:
+		s.mutes[e.Target] = sanction
+	case ActionUnmute:
+		delete(s.mutes, e.Target)
+	}
+}
+
+// Banned returns the active ban of the first banned target at now. Empty targets
+// (anonymous accounts) are skipped.
+func (s *Store) Banned(now time.Time, targets ...string) (Sanction, bool) {
+	return s.lookup(s.bans, now, targets)
+}
+
+// Muted returns the active mute of the first muted target at now.
+func (s *Store) Muted(now time.Time, targets ...string) (Sanction, bool) {
+	return s.lookup(s.mutes, now, targets)
+}
+
+func (s *Store) lookup(m map[string]Sanction, now time.Time, targets []string) (Sanction, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range targets {
+		if sanction, ok := m[t]; ok && t != "" && sanction.Active(now) {
+			return sanction, true
+		}
+	}
+	return Sanction{}, false
+}
+
+// Bans returns the bans active at now, sorted by target.
+func (s *Store) Bans(now time.Time) []Sanction {
+	return s.active(s.bans, now)
+}
+
+// Mutes returns the mutes active at now, sorted by target.
+func (s *Store) Mutes(now time.Time) []Sanction {
+	return s.active(s.mutes, now)
+}
+
+func (s *Store) active(m map[string]Sanction, now time.Time) []Sanction {
+	s.mu.RLock()
+	out := make([]Sanction, 0, len(m))
+	for _, sanction := range m {
+		if sanction.Active(now) {
+			out = append(out, sanction)
+		}
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(out, func(a, b Sanction) int { return strings.Compare(a.Target, b.Target) })
+	return out
+}
+
+// Audit returns up to limit log entries, newest first; with target set, only that
+// target's entries. limit <= 0 = all.
+func (s *Store) Audit(target string, limit int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Entry
+	for i := len(s.audit) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if target == "" || s.audit[i].Target == target {
+			out = append(out, s.audit[i])
+		}
+	}
+	return out
+}
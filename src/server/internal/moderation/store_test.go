@@ -0,0 +1,75 @@
+package moderation
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.jsonl")
+	now := time.Unix(1_800_000_000, 0).UTC()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []Entry{
+		{Time: now, Action: ActionBan, Target: "account:alice", By: "ops", Reason: "cheating"},
+		{Time: now, Action: ActionBan, Target: "ip:10.0.0.7", By: "ops", Reason: "flood", Expires: now.Add(time.Hour)},
+		{Time: now, Action: ActionMute, Target: "account:bob", By: "mod", Reason: "spam"},
+		{Time: now.Add(time.Minute), Action: ActionUnmute, Target: "account:bob", By: "mod"},
+	} {
+		if _, err := s.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if ban, ok := s.Banned(now, "", "account:alice"); !ok || ban.Reason != "cheating" || ban.By != "ops" {
+		t.Errorf("Banned(alice) = %+v, %v", ban, ok)
+	}
+	if _, ok := s.Banned(now.Add(30*time.Minute), IPTarget("10.0.0.7")); !ok {
+		t.Error("ip ban not active before it expires")
+	}
+	if _, ok := s.Banned(now.Add(time.Hour), IPTarget("10.0.0.7")); ok {
+		t.Error("ip ban still active after it expired")
+	}
+	if _, ok := s.Muted(now, "account:bob"); ok {
+		t.Error("unmuted account still muted")
+	}
+	if got := len(s.Bans(now)); got != 2 {
+		t.Errorf("active bans = %d, want 2", got)
+	}
+
+	audit := s.Audit("account:bob", 0)
+	if len(audit) != 2 || audit[0].Action != ActionUnmute || audit[1].Action != ActionMute {
+		t.Errorf("audit(bob) = %+v, want unmute then mute", audit)
+	}
+	if got := s.Audit("", 1); len(got) != 1 || got[0].Action != ActionUnmute {
+		t.Errorf("audit limit 1 = %+v", got)
+	}
+}
+
+func TestRecordValidates(t *testing.T) {
+	s, _ := Open("")
+	for _, target := range []string{"", "alice", "user:alice", "ip:not-an-ip", "account:"} {
+		if _, err := s.Record(Entry{Action: ActionBan, Target: target}); !errors.Is(err, ErrTarget) {
+			t.Errorf("Record(%q) error = %v, want ErrTarget", target, err)
+		}
+	}
+	if _, err := s.Record(Entry{Action: "kick", Target: "account:a"}); !errors.Is(err, ErrAction) {
+		t.Errorf("unknown action error = %v, want ErrAction", err)
+	}
+	e, err := s.Record(Entry{Action: ActionBan, Target: "ip:::ffff:10.0.0.1"})
+	if err != nil || e.Target != "ip:10.0.0.1" {
+		t.Errorf("Record normalized target = %q, %v; want ip:10.0.0.1", e.Target, err)
+	}
+}
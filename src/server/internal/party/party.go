@@ -0,0 +1,179 @@
+// Package party groups the players of one server into parties.
+//
+// A player that is not in a party, or leads one, invites another player; the invitee
+// accepts within the invite TTL and joins the inviter's party, which is created on the
+// first accept with the inviter as leader. A member leaves on its own or by
+// disconnecting; when the leader leaves the longest-standing member leads, and a party
+// left with one member breaks up.
+//
+// Parties are keyed by player ID, so they live as long as the players on this server:
+// a session takeover keeps the party, a restart or handover does not. A Party value is
+// never modified after it is returned, so callers may keep it and read it from any
+// goroutine; the Registry itself is not safe for concurrent use.
+package party
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Errors of the Registry operations; the text is shown to the player.
+var (
+	ErrSelf      = errors.New("you cannot invite yourself")
+	ErrNotLeader = errors.New("only the party leader can invite")
+	ErrFull      = errors.New("the party is full")
+	ErrInParty   = errors.New("already in a party")
+	ErrNoInvite  = errors.New("no such invite, or it expired")
+)
+
+// Party — snapshot of a party. Members are in joining order, the leader included.
+type Party struct {
+	ID      uint32
+	Leader  uint32
+	Members []uint32
+}
+
+// Has reports whether playerID is a member of p; a nil party has no members.
+func (p *Party) Has(playerID uint32) bool {
+	return p != nil && slices.Contains(p.Members, playerID)
+}
+
+// Registry holds the parties and pending invites of one server.
+type Registry struct {
+	maxSize   int
+	inviteTTL time.Duration
+	now       func() time.Time // time.Now; tests replace it
+
+	nextID   uint32
+	parties  int
+	byPlayer map[uint32]*Party
+	invites  map[uint32]map[uint32]time.Time // invitee → inviter → expiry
+}
+
+// NewRegistry returns an empty registry of parties of at most maxSize members
+// (at least 2) whose invites expire after inviteTTL.
+func NewRegistry(maxSize int, inviteTTL time.Duration) *Registry {
+	return &Registry{
+		maxSize:   max(maxSize, 2),
+		inviteTTL: inviteTTL,
+		now:       time.Now,
+		byPlayer:  make(map[uint32]*Party),
+		invites:   make(map[uint32]map[uint32]time.Time),
+	}
+}
+
+// Of returns the party of playerID, nil if none.
+func (r *Registry) Of(playerID uint32) *Party {
+	return r.byPlayer[playerID]
+}
+
+// Len returns the number of parties.
+func (r *Registry) Len() int {
+	return r.parties
+}
+
+// Invite records an invite from one player to another. A newer invite from the same
+// inviter replaces the older one.
+func (r *Registry) Invite(from, to uint32) error {
+	if from == to {
+		return ErrSelf
+	}
+	if p := r.byPlayer[from]; p != nil {
+		if p.Leader != from {
+			return ErrNotLeader
+		}
+		if len(p.Members) >= r.maxSize {
+			return ErrFull
+		}
+	}
+	if r.byPlayer[to] != nil {
+		return ErrInParty
+	}
+	now := r.now()
+	pending := r.invites[to]
+	if pending == nil {
+		pending = make(map[uint32]time.Time)
+		r.invites[to] = pending
+	}
+	for inviter, expiry := range pending {
+		if !now.Before(expiry) {
+			delete(pending, inviter)
+		}
+	}
+	pending[from] = now.Add(r.inviteTTL)
+	return nil
+}
+
+// Accept adds to to the party of from, whose invite it accepts, creating the party if
+// from is not in one. It returns the party as it is now.
+func (r *Registry) Accept(to, from uint32) (*Party, error) {
+	expiry, ok := r.invites[to][from]
+	if !ok || !r.now().Before(expiry) {
+		delete(r.invites[to], from)
+		return nil, ErrNoInvite
+	}
+	if r.byPlayer[to] != nil {
+		return nil, ErrInParty
+	}
+	old := r.byPlayer[from]
+	if old != nil && len(old.Members) >= r.maxSize {
+		return nil, ErrFull
+	}
+	delete(r.invites, to)
+
+	var p *Party
+	if old == nil {
+		r.nextID++
+		r.parties++
+		p = &Party{ID: r.nextID, Leader: from, Members: []uint32{from, to}}
+	} else {
+		p = &Party{ID: old.ID, Leader: old.Leader, Members: append(slices.Clip(old.Members), to)}
+	}
+	r.store(p)
+	return p, nil
+}
+
+// Leave removes playerID from its party. It returns the party it left and the party as
+// it is now, nil if it broke up; ok is false if playerID was not in a party.
+func (r *Registry) Leave(playerID uint32) (left, now *Party, ok bool) {
+	left = r.byPlayer[playerID]
+	if left == nil {
+		return nil, nil, false
+	}
+	delete(r.byPlayer, playerID)
+	members := slices.DeleteFunc(slices.Clone(left.Members), func(id uint32) bool { return id == playerID })
+	if len(members) < 2 {
+		for _, id := range members {
+			delete(r.byPlayer, id)
+		}
+		r.parties--
+		return left, nil, true
+	}
+	now = &Party{ID: left.ID, Leader: left.Leader, Members: members}
+	if now.Leader == playerID {
+		now.Leader = members[0]
+	}
+	r.store(now)
+	return left, now, true
+}
+
+// Remove forgets a player that left the server: it leaves its party (see Leave) and
+// its invites, sent and received, are dropped.
+func (r *Registry) Remove(playerID uint32) (left, now *Party, ok bool) {
+	delete(r.invites, playerID)
+	for invitee, pending := range r.invites {
+		delete(pending, playerID)
+		if len(pending) == 0 {
+			delete(r.invites, invitee)
+		}
+	}
+	return r.Leave(playerID)
+}
+
+// store makes p the party of each of its members.
+func (r *Registry) store(p *Party) {
+	for _, id := range p.Members {
+		r.byPlayer[id] = p
+	}
+}
@@ -0,0 +1,79 @@
+package party
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(3, time.Minute)
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+
+	if err := r.Invite(1, 1); !errors.Is(err, ErrSelf) {
+		t.Errorf("self invite: %v", err)
+	}
+	if _, err := r.Accept(2, 1); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accept without an invite: %v", err)
+	}
+	for _, to := range []uint32{2, 3, 4} {
+		if err := r.Invite(1, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := r.Accept(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Party{ID: 1, Leader: 1, Members: []uint32{1, 2}}); !reflect.DeepEqual(p, want) {
+		t.Errorf("new party = %+v, want %+v", p, want)
+	}
+	if err := r.Invite(2, 5); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("invite by a member: %v", err)
+	}
+	if err := r.Invite(5, 2); !errors.Is(err, ErrInParty) {
+		t.Errorf("invite of a member: %v", err)
+	}
+	if _, err := r.Accept(3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Accept(4, 1); !errors.Is(err, ErrFull) {
+		t.Errorf("accept into a full party: %v", err)
+	}
+	if r.Len() != 1 || !r.Of(3).Has(1) || r.Of(4).Has(1) {
+		t.Errorf("%d parties, party of 3 = %+v, of 4 = %+v", r.Len(), r.Of(3), r.Of(4))
+	}
+
+	// The leader leaves: the next member leads; the old snapshot is untouched.
+	before := r.Of(1)
+	left, after, ok := r.Leave(1)
+	if !ok || left != before || !reflect.DeepEqual(after, &Party{ID: 1, Leader: 2, Members: []uint32{2, 3}}) {
+		t.Errorf("leader left: %v %+v %+v", ok, left, after)
+	}
+	if len(before.Members) != 3 || r.Of(1) != nil {
+		t.Errorf("snapshot changed to %+v, or the leaver still has a party", before)
+	}
+
+	// A party left with one member breaks up.
+	if _, after, _ := r.Remove(3); after != nil || r.Of(2) != nil || r.Len() != 0 {
+		t.Errorf("party of one kept: %+v, %+v", after, r.Of(2))
+	}
+	if _, _, ok := r.Leave(2); ok {
+		t.Error("Leave outside a party reported ok")
+	}
+
+	if err := r.Invite(2, 6); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := r.Accept(6, 2); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accept of an expired invite: %v", err)
+	}
+	r.Invite(2, 6)
+	r.Remove(2)
+	if _, err := r.Accept(6, 2); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accept of an invite from a player who left: %v", err)
+	}
+}
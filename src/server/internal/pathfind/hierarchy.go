@@ -0,0 +1,200 @@
+package pathfind
+
+// Cluster graph of the hierarchical search (see the package comment).
+
+// minWideEntrance — an open border stretch at least this long gets an entrance at each
+// end instead of one in the middle, so paths along a wide gap do not detour to its centre.
+const minWideEntrance = 6
+
+func (f *Finder) build() {
+	c := f.cluster
+	f.ccols, f.crows = (f.cols+c-1)/c, (f.rows+c-1)/c
+	f.byTile = make(map[int32]int32)
+	f.byCluster = make([][]int32, f.ccols*f.crows)
+
+	for x := c - 1; x+1 < f.cols; x += c {
+		f.scanBorder(f.rows, func(i int) (int32, int32) {
+			return int32(i*f.cols + x), int32(i*f.cols + x + 1)
+		})
+	}
+	for y := c - 1; y+1 < f.rows; y += c {
+		f.scanBorder(f.cols, func(i int) (int32, int32) {
+			return int32(y*f.cols + i), int32((y+1)*f.cols + i)
+		})
+	}
+
+	s := new(search)
+	for cl, ids := range f.byCluster {
+		b := f.clusterRect(cl)
+		for _, u := range ids {
+			s.run(f, f.nodes[u], -1, b)
+			for _, v := range ids {
+				if v == u {
+					continue
+				}
+				if cost, ok := s.cost(f, f.nodes[v]); ok {
+					f.edges[u] = append(f.edges[u], absEdge{to: v, cost: cost})
+				}
+			}
+		}
+	}
+}
+
+// scanBorder adds entrances along one border line; pair(i) gives the two tiles facing
+// each other across it at position i. Stretches are cut where the clusters along the
+// line change.
+func (f *Finder) scanBorder(n int, pair func(i int) (int32, int32)) {
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if end-start < minWideEntrance {
+			f.entrance(pair(start + (end-start)/2))
+		} else {
+			f.entrance(pair(start))
+			f.entrance(pair(end - 1))
+		}
+		start = -1
+	}
+	for i := 0; i < n; i++ {
+		if i%f.cluster == 0 {
+			flush(i)
+		}
+		if a, b := pair(i); f.blocked(a) || f.blocked(b) {
+			flush(i)
+		} else if start < 0 {
+			start = i
+		}
+	}
+	flush(n)
+}
+
+func (f *Finder) entrance(a, b int32) {
+	na, nb := f.node(a), f.node(b)
+	f.edges[na] = append(f.edges[na], absEdge{to: nb, cost: straightCost})
+	f.edges[nb] = append(f.edges[nb], absEdge{to: na, cost: straightCost})
+}
+
+func (f *Finder) node(tile int32) int32 {
+	if id, ok := f.byTile[tile]; ok {
+		return id
+	}
+	id := int32(len(f.nodes))
+	f.nodes = append(f.nodes, tile)
+	f.edges = append(f.edges, nil)
+	f.byTile[tile] = id
+	cl := f.clusterOf(tile)
+	f.byCluster[cl] = append(f.byCluster[cl], id)
+	return id
+}
+
+func (f *Finder) clusterOf(tile int32) int {
+	col, row := int(tile)%f.cols, int(tile)/f.cols
+	return row/f.cluster*f.ccols + col/f.cluster
+}
+
+func (f *Finder) clusterRect(cl int) rect {
+	c := f.cluster
+	cx, cy := cl%f.ccols*c, cl/f.ccols*c
+	return rect{cx, cy, min(cx+c, f.cols), min(cy+c, f.rows)}
+}
+
+// connect returns the distances from tile to the entrances of its cluster.
+func (f *Finder) connect(s *search, tile int32) []absEdge {
+	cl := f.clusterOf(tile)
+	s.run(f, tile, -1, f.clusterRect(cl))
+	var out []absEdge
+	for _, v := range f.byCluster[cl] {
+		if cost, ok := s.cost(f, f.nodes[v]); ok {
+			out = append(out, absEdge{to: v, cost: cost})
+		}
+	}
+	return out
+}
+
+// hierarchical searches the cluster graph with start and goal linked in, then refines
+// each step of the result into tiles.
+func (f *Finder) hierarchical(s *search, start, goal int32) ([]int32, bool) {
+	fromStart := f.connect(s, start)
+	toGoal := f.connect(s, goal)
+	if len(fromStart) == 0 || len(toGoal) == 0 {
+		return nil, false
+	}
+	n := int32(len(f.nodes))
+	startNode, goalNode := n, n+1
+	tileOf := func(u int32) int32 {
+		switch u {
+		case startNode:
+			return start
+		case goalNode:
+			return goal
+		}
+		return f.nodes[u]
+	}
+
+	s.aprepare(int(n) + 2)
+	s.aseen[startNode], s.ag[startNode], s.aparent[startNode] = s.agen, 0, -1
+	s.heap.push(startNode, f.heuristic(start, goal))
+	relax := func(u, v, cost int32) {
+		if s.aclosed[v] == s.agen {
+			return
+		}
+		g := s.ag[u] + cost
+		if s.aseen[v] == s.agen && s.ag[v] <= g {
+			return
+		}
+		s.aseen[v], s.ag[v], s.aparent[v] = s.agen, g, u
+		s.heap.push(v, g+f.heuristic(tileOf(v), goal))
+	}
+	found := false
+	for len(s.heap) > 0 {
+		u := s.heap.pop()
+		if s.aclosed[u] == s.agen {
+			continue
+		}
+		s.aclosed[u] = s.agen
+		if u == goalNode {
+			found = true
+			break
+		}
+		if u == startNode {
+			for _, e := range fromStart {
+				relax(u, e.to, e.cost)
+			}
+			continue
+		}
+		for _, e := range f.edges[u] {
+			relax(u, e.to, e.cost)
+		}
+		for _, e := range toGoal {
+			if e.to == u {
+				relax(u, goalNode, e.cost)
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	var steps []int32
+	for u := goalNode; u >= 0; u = s.aparent[u] {
+		steps = append(steps, tileOf(u))
+	}
+	path := []int32{start}
+	for i := len(steps) - 2; i >= 0; i-- {
+		a, b := path[len(path)-1], steps[i]
+		switch {
+		case a == b:
+		case f.clusterOf(a) != f.clusterOf(b): // across a border: neighbours
+			path = append(path, b)
+		default:
+			seg, ok := s.astar(f, a, b, f.clusterRect(f.clusterOf(a)))
+			if !ok {
+				return nil, false
+			}
+			path = append(path, seg[1:]...)
+		}
+	}
+	return path, true
+}
@@ -0,0 +1,191 @@
+package pathfind
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"pixi_game_server/internal/worldmap"
+)
+
+const tile = 10
+
+// gridMap builds a map from rows of '.' (open) and '#' (blocked), tiles of 10 units.
+func gridMap(rows ...string) *worldmap.Map {
+	m := &worldmap.Map{Cols: len(rows[0]), Rows: len(rows), TileWidth: tile, TileHeight: tile}
+	m.Width, m.Height = uint32(m.Cols*tile), uint32(m.Rows*tile)
+	for r, line := range rows {
+		for c, ch := range line {
+			m.SetBlocked(c, r, ch == '#')
+		}
+	}
+	return m
+}
+
+func at(col, row int) worldmap.Point {
+	return worldmap.Point{X: uint32(col*tile + tile/2), Y: uint32(row*tile + tile/2)}
+}
+
+// walk follows the waypoints from `from` in tile steps, failing on a blocked tile, a
+// cut corner or a leg that is not one of the 8 directions, and returns the cost.
+func walk(t *testing.T, m *worldmap.Map, from worldmap.Point, path []worldmap.Point) int {
+	t.Helper()
+	col, row := int(from.X/tile), int(from.Y/tile)
+	cost := 0
+	for _, wp := range path {
+		tc, tr := int(wp.X/tile), int(wp.Y/tile)
+		dc, dr := tc-col, tr-row
+		if dc != 0 && dr != 0 && abs(dc) != abs(dr) {
+			t.Fatalf("leg (%d,%d) → (%d,%d) is not straight or diagonal", col, row, tc, tr)
+		}
+		sc, sr := sign(dc), sign(dr)
+		for col != tc || row != tr {
+			if sc != 0 && sr != 0 && (m.TileBlocked(col+sc, row) || m.TileBlocked(col, row+sr)) {
+				t.Fatalf("path cuts the corner at (%d,%d)", col, row)
+			}
+			col, row = col+sc, row+sr
+			if m.TileBlocked(col, row) {
+				t.Fatalf("path crosses blocked tile (%d,%d)", col, row)
+			}
+			if sc != 0 && sr != 0 {
+				cost += diagonalCost
+			} else {
+				cost += straightCost
+			}
+		}
+	}
+	return cost
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func TestPathAroundWall(t *testing.T) {
+	m := gridMap(
+		"..........",
+		"....#.....",
+		"....#.....",
+		"....#.....",
+		"..........",
+	)
+	f := New(m)
+	if f.Hierarchical() {
+		t.Fatal("a 10×5 map searched hierarchically")
+	}
+	from, to := at(2, 2), at(7, 2)
+	path, err := f.Path(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path[len(path)-1] != to {
+		t.Fatalf("path ends at %v, want %v", path[len(path)-1], to)
+	}
+	// Shortest: round the end of the wall through row 0 or 4, three diagonal steps
+	// and three straight ones — the diagonal past the wall's end would cut its corner.
+	if cost := walk(t, m, from, path); cost != 3*diagonalCost+3*straightCost {
+		t.Errorf("path %v costs %d, want %d", path, cost, 3*diagonalCost+3*straightCost)
+	}
+}
+
+func TestPathErrors(t *testing.T) {
+	m := gridMap(
+		"..#..",
+		"..#..",
+		"###..",
+		".....",
+	)
+	f := New(m)
+	if _, err := f.Path(at(0, 0), at(4, 0)); !errors.Is(err, ErrNoPath) {
+		t.Errorf("walled in: %v, want ErrNoPath", err)
+	}
+	if _, err := f.Path(at(0, 0), at(2, 0)); !errors.Is(err, ErrBlocked) {
+		t.Errorf("goal on a wall: %v, want ErrBlocked", err)
+	}
+	if _, err := f.Path(at(0, 0), at(9, 0)); !errors.Is(err, ErrBlocked) {
+		t.Errorf("goal off the map: %v, want ErrBlocked", err)
+	}
+	if path, err := f.Path(at(3, 3), at(3, 3)); err != nil || len(path) != 1 {
+		t.Errorf("start = goal: %v, %v", path, err)
+	}
+}
+
+// TestHierarchicalMatchesFlat compares cluster search with flat A* on a random maze:
+// both must agree on what is reachable, and cluster paths stay close to the shortest.
+func TestHierarchicalMatchesFlat(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	rows := make([]string, 80)
+	for r := range rows {
+		var b strings.Builder
+		for range 96 {
+			if rng.Intn(100) < 28 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		rows[r] = b.String()
+	}
+	m := gridMap(rows...)
+	flat, hier := newFinder(m, 0), newFinder(m, ClusterSize)
+	if hier.Entrances() == 0 {
+		t.Fatal("no entrances")
+	}
+
+	found, worst := 0, 1.0
+	for range 300 {
+		from, to := at(rng.Intn(96), rng.Intn(80)), at(rng.Intn(96), rng.Intn(80))
+		want, errF := flat.Path(from, to)
+		got, errH := hier.Path(from, to)
+		if !errors.Is(errH, errF) {
+			t.Fatalf("%v → %v: hierarchical %v, flat %v", from, to, errH, errF)
+		}
+		if errF != nil {
+			continue
+		}
+		found++
+		best, cost := walk(t, m, from, want), walk(t, m, from, got)
+		if cost < best {
+			t.Fatalf("%v → %v: hierarchical cost %d beats the optimum %d", from, to, cost, best)
+		}
+		if best > 0 {
+			worst = max(worst, float64(cost)/float64(best))
+		}
+	}
+	if found < 50 {
+		t.Fatalf("only %d of 300 pairs connected; the maze is too dense to test", found)
+	}
+	if worst > 1.5 {
+		t.Errorf("a hierarchical path is %.2f× the shortest", worst)
+	}
+	t.Logf("%d paths, worst %.2f× the shortest", found, worst)
+}
+
+func TestGeneratedMap(t *testing.T) {
+	m, err := worldmap.Generate(42, 9000, 9000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New(m)
+	if !f.Hierarchical() {
+		t.Fatalf("%d×%d tiles searched flat", m.Cols, m.Rows)
+	}
+	// Generated obstacles never touch, so every pair of item spawns is connected.
+	items := m.ItemSpawns
+	for i := 1; i < len(items); i++ {
+		path, err := f.Path(items[i-1], items[i])
+		if err != nil {
+			t.Fatalf("%v → %v: %v", items[i-1], items[i], err)
+		}
+		if path[len(path)-1] != items[i] {
+			t.Fatalf("path ends at %v, want %v", path[len(path)-1], items[i])
+		}
+	}
+}
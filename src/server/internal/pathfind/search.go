@@ -0,0 +1,190 @@
+package pathfind
+
+// rect — tiles [c0, c1) × [r0, r1) a search stays in.
+type rect struct {
+	c0, r0, c1, r1 int
+}
+
+func (b rect) contains(col, row int) bool {
+	return col >= b.c0 && col < b.c1 && row >= b.r0 && row < b.r1
+}
+
+var directions = [8]struct{ dc, dr int }{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {1, -1}, {-1, 1}, {-1, -1},
+}
+
+// search — the scratch of one query, reused through Finder.pool. Entries are valid
+// when their stamp is the current generation, so nothing is cleared between searches.
+type search struct {
+	// Tiles of b, indexed by (row-r0)*w + col-c0.
+	b      rect
+	w      int
+	gen    uint32
+	seen   []uint32
+	closed []uint32
+	g      []int32
+	parent []int32 // tile
+
+	// Cluster graph nodes: the entrances, then the start and the goal.
+	agen    uint32
+	aseen   []uint32
+	aclosed []uint32
+	ag      []int32
+	aparent []int32
+
+	heap minHeap
+}
+
+func (s *search) prepare(b rect) {
+	s.b, s.w = b, b.c1-b.c0
+	if n := s.w * (b.r1 - b.r0); len(s.seen) < n {
+		s.seen, s.closed = make([]uint32, n), make([]uint32, n)
+		s.g, s.parent = make([]int32, n), make([]int32, n)
+		s.gen = 0
+	}
+	if s.gen++; s.gen == 0 {
+		clear(s.seen)
+		clear(s.closed)
+		s.gen = 1
+	}
+	s.heap = s.heap[:0]
+}
+
+func (s *search) local(f *Finder, tile int32) int {
+	col, row := int(tile)%f.cols, int(tile)/f.cols
+	return (row-s.b.r0)*s.w + col - s.b.c0
+}
+
+// run searches from start inside b: A* that stops at goal, or with goal < 0 Dijkstra
+// over every tile of b it can reach.
+func (s *search) run(f *Finder, start, goal int32, b rect) bool {
+	s.prepare(b)
+	li := s.local(f, start)
+	s.seen[li], s.g[li], s.parent[li] = s.gen, 0, -1
+	s.heap.push(start, 0)
+	for len(s.heap) > 0 {
+		tile := s.heap.pop()
+		li := s.local(f, tile)
+		if s.closed[li] == s.gen {
+			continue
+		}
+		s.closed[li] = s.gen
+		if tile == goal {
+			return true
+		}
+		col, row := int(tile)%f.cols, int(tile)/f.cols
+		for _, d := range directions {
+			nc, nr := col+d.dc, row+d.dr
+			if !b.contains(nc, nr) || f.m.TileBlocked(nc, nr) {
+				continue
+			}
+			cost := int32(straightCost)
+			if d.dc != 0 && d.dr != 0 {
+				if f.m.TileBlocked(col+d.dc, row) || f.m.TileBlocked(col, row+d.dr) {
+					continue
+				}
+				cost = diagonalCost
+			}
+			next := int32(nr*f.cols + nc)
+			nli := s.local(f, next)
+			if s.closed[nli] == s.gen {
+				continue
+			}
+			g := s.g[li] + cost
+			if s.seen[nli] == s.gen && s.g[nli] <= g {
+				continue
+			}
+			s.seen[nli], s.g[nli], s.parent[nli] = s.gen, g, tile
+			h := int32(0)
+			if goal >= 0 {
+				h = f.heuristic(next, goal)
+			}
+			s.heap.push(next, g+h)
+		}
+	}
+	return goal < 0
+}
+
+// astar returns the tiles from start to goal inside b, both included.
+func (s *search) astar(f *Finder, start, goal int32, b rect) ([]int32, bool) {
+	if !s.run(f, start, goal, b) {
+		return nil, false
+	}
+	var path []int32
+	for t := goal; t >= 0; t = s.parent[s.local(f, t)] {
+		path = append(path, t)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
+
+// cost returns the distance to tile found by the last run.
+func (s *search) cost(f *Finder, tile int32) (int32, bool) {
+	col, row := int(tile)%f.cols, int(tile)/f.cols
+	if !s.b.contains(col, row) {
+		return 0, false
+	}
+	li := s.local(f, tile)
+	return s.g[li], s.seen[li] == s.gen
+}
+
+func (s *search) aprepare(n int) {
+	if len(s.aseen) < n {
+		s.aseen, s.aclosed = make([]uint32, n), make([]uint32, n)
+		s.ag, s.aparent = make([]int32, n), make([]int32, n)
+		s.agen = 0
+	}
+	if s.agen++; s.agen == 0 {
+		clear(s.aseen)
+		clear(s.aclosed)
+		s.agen = 1
+	}
+	s.heap = s.heap[:0]
+}
+
+// minHeap — open list ordered by estimated total cost.
+type minHeap []heapItem
+
+type heapItem struct {
+	node, f int32
+}
+
+func (h *minHeap) push(node, f int32) {
+	*h = append(*h, heapItem{node, f})
+	a := *h
+	for i := len(a) - 1; i > 0; {
+		p := (i - 1) / 2
+		if a[p].f <= a[i].f {
+			break
+		}
+		a[p], a[i] = a[i], a[p]
+		i = p
+	}
+}
+
+func (h *minHeap) pop() int32 {
+	a := *h
+	top := a[0].node
+	n := len(a) - 1
+	a[0] = a[n]
+	a = a[:n]
+	for i := 0; ; {
+		l, r, m := 2*i+1, 2*i+2, i
+		if l < n && a[l].f < a[m].f {
+			m = l
+		}
+		if r < n && a[r].f < a[m].f {
+			m = r
+		}
+		if m == i {
+			break
+		}
+		a[m], a[i] = a[i], a[m]
+		i = m
+	}
+	*h = a
+	return top
+}
@@ -0,0 +1,168 @@
+// Package persist saves player records — where a signed-in player was and how much
+// health it had — without putting storage I/O on the game's hot path.
+//
+// Callers hand records to a Queue, which only remembers the latest record of each
+// account. A goroutine (Queue.Run) writes the pending records to a Store in batches:
+// every flush interval, or sooner once a batch worth of accounts is pending. A batch
+// that fails stays pending and is retried with the next flush; a record that failed
+// Retries flushes is dead-lettered: logged in full, so it can be replayed by hand,
+// and dropped. A newer record of the same account replaces a failing one and starts
+// over.
+//
+// The Store is a log like the friend and inventory logs: a batch is appended as JSON
+// lines and fsynced with one write, and Open replays the file, the last record of an
+// account winning. A record with the same position and HP as the account's saved one
+// is not written again, so idle players cost nothing, and once the log holds more
+// than compactRatio lines per account it is rewritten with the latest records only —
+// when opened, and when a save takes it past the limit. Accounts are the IDs of
+// internal/auth; anonymous players are not saved.
+package persist
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"pixi_game_server/internal/jsonlog"
+)
+
+// Record — the saved state of one account's player.
+type Record struct {
+	Account string `json:"account"`
+	X       uint32 `json:"x"`
+	Y       uint32 `json:"y"`
+	HP      uint16 `json:"hp"`
+	SavedAt int64  `json:"saved_at"` // Unix ms when the record was taken
+}
+
+// The log is compacted once it holds more than compactRatio lines per account and at
+// least compactMin lines.
+const (
+	compactRatio = 2
+	compactMin   = 1024
+)
+
+// Store holds the latest record of every account.
+type Store struct {
+	mu      sync.Mutex
+	log     *jsonlog.Log[Record] // nil = in memory only
+	records map[string]Record
+}
+
+// Open replays the log at path and appends to it from now on; an empty path keeps the
+// records in memory.
+func Open(path string) (*Store, error) {
+	s := &Store{records: make(map[string]Record)}
+	if path == "" {
+		return s, nil
+	}
+	log, err := jsonlog.Open(path, func(r Record) {
+		s.records[r.Account] = r
+	})
+	if err != nil {
+		return nil, fmt.Errorf("persist: %w", err)
+	}
+	s.log = log
+	s.compact()
+	return s, nil
+}
+
+// Save appends the records that changed since the account's saved one to the log in
+// one write and fsyncs it. Nothing is written if ctx is already done; a write under
+// way is not interrupted.
+func (s *Store) Save(ctx context.Context, records []Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := make([]Record, 0, len(records))
+	for _, r := range records {
+		if prev, ok := s.records[r.Account]; !ok || prev.X != r.X || prev.Y != r.Y || prev.HP != r.HP {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if s.log != nil {
+		if err := s.log.Append(changed...); err != nil {
+			return err
+		}
+	}
+	for _, r := range changed {
+		s.records[r.Account] = r
+	}
+	s.compact()
+	return nil
+}
+
+// compact rewrites the log with the latest records once it is mostly superseded ones.
+// A failure leaves the log as it was. Caller holds mu (or owns s).
+func (s *Store) compact() {
+	if s.log == nil || s.log.Len() < max(compactMin, compactRatio*len(s.records)+1) {
+		return
+	}
+	before := s.log.Len()
+	latest := slices.SortedFunc(maps.Values(s.records), func(a, b Record) int { return strings.Compare(a.Account, b.Account) })
+	if err := s.log.Rewrite(latest); err != nil {
+		slog.Warn("persist: log not compacted", "error", err)
+		return
+	}
+	slog.Info("persist: log compacted", "lines_before", before, "records", len(latest))
+}
+
+// Load returns the latest saved record of account.
+func (s *Store) Load(account string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[account]
+	return r, ok
+}
+
+// Close closes the log; later saves stay in memory.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Reopen opens the log at path for appending again after Close and appends the
+// records saved while it was closed.
+func (s *Store) Reopen(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil || path == "" {
+		return nil
+	}
+	onDisk := make(map[string]Record)
+	log, err := jsonlog.Open(path, func(r Record) {
+		onDisk[r.Account] = r
+	})
+	if err != nil {
+		return fmt.Errorf("persist: %w", err)
+	}
+	var missed []Record
+	for account, r := range s.records {
+		if onDisk[account] != r {
+			missed = append(missed, r)
+		}
+	}
+	if len(missed) > 0 {
+		if err := log.Append(missed...); err != nil {
+			log.Close()
+			return fmt.Errorf("persist: %w", err)
+		}
+	}
+	s.log = log
+	return nil
+}
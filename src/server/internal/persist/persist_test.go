@@ -0,0 +1,243 @@
+package persist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSaver records the batches it is given and fails while fail > 0.
+type fakeSaver struct {
+	mu      sync.Mutex
+	batches [][]Record
+	fail    int
+}
+
+func (f *fakeSaver) Save(_ context.Context, records []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("disk full")
+	}
+	f.batches = append(f.batches, append([]Record(nil), records...))
+	return nil
+}
+
+func (f *fakeSaver) saved() map[string]Record {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]Record)
+	for _, b := range f.batches {
+		for _, r := range b {
+			out[r.Account] = r
+		}
+	}
+	return out
+}
+
+func testQueue(saver Saver, batch, retries int) *Queue {
+	return NewQueue(saver, QueueConfig{Interval: time.Hour, BatchSize: batch, Retries: retries, Timeout: time.Second})
+}
+
+func TestQueueBatches(t *testing.T) {
+	saver := &fakeSaver{}
+	q := testQueue(saver, 2, 3)
+	q.Enqueue(Record{Account: "alice", X: 1})
+	q.Enqueue(Record{Account: "alice", X: 2})
+	q.Enqueue(Record{Account: "bob", X: 3})
+	q.Enqueue(Record{Account: "carol", X: 4})
+
+	if n := q.Flush(t.Context()); n != 3 {
+		t.Fatalf("flushed %d records, want 3 (one per account)", n)
+	}
+	if len(saver.batches) != 2 {
+		t.Fatalf("%d batches, want 2 of at most 2", len(saver.batches))
+	}
+	if got := saver.saved()["alice"].X; got != 2 {
+		t.Errorf("alice saved at x=%d, want the latest record", got)
+	}
+	if q.Pending() != 0 {
+		t.Errorf("%d pending after a flush", q.Pending())
+	}
+}
+
+func TestQueueFlushesAtBatchSize(t *testing.T) {
+	saver := &fakeSaver{}
+	q := testQueue(saver, 2, 3)
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go q.Run(ctx)
+
+	q.Enqueue(Record{Account: "alice"})
+	q.Enqueue(Record{Account: "bob"})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(saver.saved()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("a full batch was not flushed before the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueueRetryAndDeadLetter(t *testing.T) {
+	saver := &fakeSaver{fail: 1}
+	q := testQueue(saver, 10, 2)
+	q.Enqueue(Record{Account: "alice"})
+	if n := q.Flush(t.Context()); n != 0 || q.Pending() != 1 {
+		t.Fatalf("after a failed flush: %d written, %d pending; want 0 and 1", n, q.Pending())
+	}
+	if n := q.Flush(t.Context()); n != 1 {
+		t.Fatalf("retry wrote %d records, want 1", n)
+	}
+
+	saver.fail = 2
+	q.Enqueue(Record{Account: "bob"})
+	q.Flush(t.Context())
+	q.Flush(t.Context())
+	if q.Pending() != 0 {
+		t.Fatal("a record out of retries is still pending")
+	}
+	if _, ok := saver.saved()["bob"]; ok {
+		t.Fatal("the dead-lettered record was saved")
+	}
+}
+
+func TestQueueNewerRecordReplacesFailed(t *testing.T) {
+	saver := &fakeSaver{fail: 1}
+	q := testQueue(saver, 10, 1)
+	q.Enqueue(Record{Account: "alice", X: 1})
+	q.Flush(t.Context()) // fails: out of retries, dead-lettered
+	q.Enqueue(Record{Account: "alice", X: 2})
+	q.Flush(t.Context())
+	if got := saver.saved()["alice"].X; got != 2 {
+		t.Fatalf("alice saved at x=%d, want 2", got)
+	}
+}
+
+func TestStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(t.Context(), []Record{{Account: "alice", X: 1, HP: 100}, {Account: "bob", X: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(t.Context(), []Record{{Account: "alice", X: 2, HP: 40}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if r, ok := s.Load("alice"); !ok || r.X != 2 || r.HP != 40 {
+		t.Errorf("alice after replay: %+v, %v", r, ok)
+	}
+	if _, ok := s.Load("bob"); !ok {
+		t.Error("bob lost on replay")
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := s.Save(ctx, []Record{{Account: "carol"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("save with a cancelled context: %v", err)
+	}
+}
+
+func TestStoreCorruptLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	if err := os.WriteFile(path, []byte("{\"account\":\"alice\"}\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("a corrupt log opened")
+	}
+}
+
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestStoreSkipsUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := range 3 {
+		// A checkpoint of an idle player: only SavedAt differs.
+		if err := s.Save(t.Context(), []Record{{Account: "alice", X: 1, HP: 100, SavedAt: int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Save(t.Context(), []Record{{Account: "alice", X: 1, HP: 90}, {Account: "bob", X: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := lineCount(t, path); n != 3 {
+		t.Errorf("%d lines in the log, want 3: unchanged records are not written", n)
+	}
+}
+
+func TestStoreCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "players.jsonl")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range compactMin + 10 {
+		if err := s.Save(t.Context(), []Record{{Account: "alice", X: uint32(i)}, {Account: "bob", X: 7}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+	if n := lineCount(t, path); n > compactMin {
+		t.Errorf("%d lines in the log of 2 accounts, want it compacted", n)
+	}
+
+	// A log grown by an older server is compacted when opened.
+	var old []byte
+	for i := range compactMin * 2 {
+		old = fmt.Appendf(old, "{\"account\":\"carol\",\"x\":%d}\n", i)
+	}
+	if err := os.WriteFile(path, old, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if n := lineCount(t, path); n != 1 {
+		t.Errorf("%d lines after Open, want the one latest record", n)
+	}
+	if r, ok := s.Load("carol"); !ok || r.X != compactMin*2-1 {
+		t.Errorf("carol after compaction: %+v, %v", r, ok)
+	}
+	if err := s.Save(t.Context(), []Record{{Account: "dave", X: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if s, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok := s.Load("dave"); !ok {
+		t.Error("a record saved after compaction is lost")
+	}
+}
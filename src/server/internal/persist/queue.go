@@ -0,0 +1,157 @@
+package persist
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Saver writes batches of records; *Store is one.
+type Saver interface {
+	Save(ctx context.Context, records []Record) error
+}
+
+// QueueConfig — how a Queue batches and retries.
+type QueueConfig struct {
+	Interval  time.Duration // flush period
+	BatchSize int           // records per Save; this many pending flush early
+	Retries   int           // failed flushes before a record is dead-lettered
+	Timeout   time.Duration // per Save
+}
+
+// Queue collects records and writes them to a Saver off the caller's goroutine.
+type Queue struct {
+	saver Saver
+	cfg   QueueConfig
+	kick  chan struct{}
+
+	mu       sync.Mutex
+	pending  map[string]*entry
+	flushing sync.Mutex // one Flush at a time
+}
+
+type entry struct {
+	rec      Record
+	attempts int // failed flushes of rec
+}
+
+// NewQueue returns a queue writing to saver; run it with Run.
+func NewQueue(saver Saver, cfg QueueConfig) *Queue {
+	cfg.BatchSize = max(cfg.BatchSize, 1)
+	cfg.Retries = max(cfg.Retries, 1)
+	return &Queue{
+		saver:   saver,
+		cfg:     cfg,
+		kick:    make(chan struct{}, 1),
+		pending: make(map[string]*entry),
+	}
+}
+
+// Enqueue queues r, replacing a pending record of the same account. It never blocks
+// on storage.
+func (q *Queue) Enqueue(r Record) {
+	q.mu.Lock()
+	q.pending[r.Account] = &entry{rec: r}
+	n := len(q.pending)
+	q.mu.Unlock()
+	metrics.PersistEnqueued.Inc()
+	metrics.PersistPending.Set(float64(n))
+	if n >= q.cfg.BatchSize {
+		select {
+		case q.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending returns the number of accounts waiting to be written.
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Run flushes every Interval, and as soon as a batch is pending, until ctx is done.
+// It does not flush on the way out: call Flush after the last Enqueue.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.kick:
+		}
+		q.Flush(ctx)
+	}
+}
+
+// Flush writes the pending records in batches of BatchSize and returns the number
+// written. Records of a failed batch stay pending unless they failed Retries times.
+func (q *Queue) Flush(ctx context.Context) int {
+	q.flushing.Lock()
+	defer q.flushing.Unlock()
+
+	q.mu.Lock()
+	taken := make([]*entry, 0, len(q.pending))
+	for account, e := range q.pending {
+		taken = append(taken, e)
+		delete(q.pending, account)
+	}
+	q.mu.Unlock()
+
+	written := 0
+	for start := 0; start < len(taken); start += q.cfg.BatchSize {
+		batch := taken[start:min(start+q.cfg.BatchSize, len(taken))]
+		if err := q.save(ctx, batch); err != nil {
+			slog.Warn("persist: batch not written", "records", len(batch), "error", err)
+			q.retry(batch)
+			continue
+		}
+		written += len(batch)
+	}
+	metrics.PersistPending.Set(float64(q.Pending()))
+	return written
+}
+
+func (q *Queue) save(ctx context.Context, batch []*entry) error {
+	records := make([]Record, len(batch))
+	for i, e := range batch {
+		records[i] = e.rec
+	}
+	ctx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	err := q.saver.Save(ctx, records)
+	metrics.PersistFlushSeconds.Observe(time.Since(start).Seconds())
+	metrics.PersistBatchSize.Observe(float64(len(records)))
+	if err != nil {
+		metrics.PersistFlushes.WithLabelValues("error").Inc()
+		return err
+	}
+	metrics.PersistFlushes.WithLabelValues("ok").Inc()
+	return nil
+}
+
+// retry puts a failed batch back, except records superseded meanwhile and records out
+// of attempts, which are dead-lettered.
+func (q *Queue) retry(batch []*entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, e := range batch {
+		if _, newer := q.pending[e.rec.Account]; newer {
+			continue
+		}
+		e.attempts++
+		if e.attempts >= q.cfg.Retries {
+			metrics.PersistDeadLetters.Inc()
+			slog.Error("persist: record dropped", "attempts", e.attempts, "record", e.rec)
+			continue
+		}
+		q.pending[e.rec.Account] = e
+	}
+}
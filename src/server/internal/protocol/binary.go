@@ -3,6 +3,10 @@ package protocol
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"pixi_game_server/internal/types"
 )
@@ -16,32 +20,297 @@ const (
 	MessageAttack         = 5  // ATTACK
 	MessageAttackEnd      = 6  // ATTACK_END
 	MessageViewportUpdate = 13 // Custom viewport (separate from attack)
+	MessageInputBatch     = 15 // INPUT_BATCH (several MOVE inputs in one frame)
+	MessageSequenceReport = 16 // SEQUENCE_REPORT (outbound loss stats / resync request)
+	MessageSealedClient   = 24 // SEALED_CLIENT (encrypted client message, see sealed.go)
+	MessageReliableAck    = 29 // RELIABLE_ACK (client received a RELIABLE message)
+	MessageChat           = 31 // CHAT (chat line or /command)
+	MessageParty          = 37 // PARTY (invite / accept / leave)
+	MessagePartyChat      = 38 // PARTY_CHAT (chat line to the party only)
+	MessageFriend         = 42 // FRIEND (friend request / accept / remove)
+	MessageTrade          = 45 // TRADE (request / accept / offer / confirm / cancel)
+	MessageEmote          = 47 // EMOTE (play an emote of the catalog)
 
 	// Server -> Client messages
-	MessageGameState      = 7  // GAME_STATE (full)
-	MessageMovementAck    = 8  // MOVEMENT_ACK
-	MessagePlayerJoined   = 11 // PLAYER_JOINED
-	MessagePlayerLeft     = 12 // PLAYER_LEFT
-	MessageDeltaGameState = 14 // DELTA_GAME_STATE (only changed players)
+	MessageGameState        = 7  // GAME_STATE (full)
+	MessageMovementAck      = 8  // MOVEMENT_ACK
+	MessagePlayerJoined     = 11 // PLAYER_JOINED
+	MessagePlayerLeft       = 12 // PLAYER_LEFT
+	MessageDeltaGameState   = 14 // DELTA_GAME_STATE (only changed players)
+	MessageWorldEvent       = 17 // WORLD_EVENT (day/night, storm, announcement)
+	MessageMaintenance      = 18 // MAINTENANCE (countdown / paused / over)
+	MessageSessionTakeover  = 19 // SESSION_TAKEOVER (account connected elsewhere)
+	MessageConfig           = 20 // CONFIG (authoritative gameplay constants, after JOIN)
+	MessageMinimap          = 21 // MINIMAP (coarse player density grid, CapMinimap clients only)
+	MessageCipherInit       = 22 // CIPHER_INIT (sealed-message salt and types, CapEncryption clients only)
+	MessageSealed           = 23 // SEALED (encrypted server message, see sealed.go)
+	MessageWorldUpdate      = 25 // WORLD_UPDATE (world resized at runtime)
+	MessageCellLoad         = 26 // CELL_LOAD (players of a streamed cell, CapCellStreaming clients only)
+	MessageCellUnload       = 27 // CELL_UNLOAD (streamed cell left the viewport)
+	MessageReliable         = 28 // RELIABLE (critical message the client acks, CapReliable clients only)
+	MessageAnnounce         = 30 // ANNOUNCE (message of the day / admin announcement)
+	MessageChatMessage      = 32 // CHAT_MESSAGE (chat line relayed from a player)
+	MessageCommandResult    = 33 // COMMAND_RESULT (answer to a /command)
+	MessageHit              = 34 // HIT (an attack landed, to the attacker and the victim)
+	MessageActionRejected   = 35 // ACTION_REJECTED (an input the server refused, to roll back)
+	MessageLeaderboard      = 36 // LEADERBOARD (top players of a metric, CapLeaderboard clients only)
+	MessagePartyInvite      = 39 // PARTY_INVITE (a player invites the client to a party)
+	MessagePartyRoster      = 40 // PARTY_ROSTER (members of the client's party; none = not in one)
+	MessagePartyChatMessage = 41 // PARTY_CHAT_MESSAGE (party chat line, to the members only)
+	MessageFriendUpdate     = 43 // FRIEND_UPDATE (a friend's presence, or a change of the friend list)
+	MessageInventory        = 44 // INVENTORY (the client's items, after JOIN and every change)
+	MessageTradeState       = 46 // TRADE_STATE (the client's trade after every change)
+	MessagePlayerEmote      = 48 // PLAYER_EMOTE (a nearby player, or the client, plays an emote)
+	MessageOrigin           = 49 // ORIGIN (large worlds: the point wire positions are relative to)
+	MessageQueueStatus      = 50 // QUEUE_STATUS (the client waits in the login queue)
+	MessageQueueAdmitted    = 51 // QUEUE_ADMITTED (the client leaves the login queue and joins)
 )
 
-// BinaryProtocol обрабатывает сериализацию/десериализацию сообщений
-type BinaryProtocol struct{}
+// Boundary modes (CONFIG boundaryMode field): what happens at the world edge.
+const (
+	BoundaryClamp = 0 // positions are clamped to [0, worldWidth] × [0, worldHeight]
+)
+
+// GameConfig — authoritative gameplay constants sent to the client in CONFIG.
+type GameConfig struct {
+	PlayerID           uint32 // the client's own player
+	TickRate           uint8
+	PlayerSpeedPerTick uint16
+	WorldWidth         uint32
+	WorldHeight        uint32
+	BoundaryMode       uint8
+	Acceleration       uint16 // units/s²; 0 = instant
+	Friction           uint16 // units/s²; 0 = instant
+	WorldSeed          uint32 // worldmap.Generate seed; 0 = not generated
+}
+
+// Maintenance phases (MAINTENANCE phase field).
+const (
+	MaintenanceOver      = 0 // back to normal
+	MaintenanceScheduled = 1 // simulation pauses in countdownMs
+	MaintenancePaused    = 2 // simulation paused, state saved
+)
+
+// World event kinds (WORLD_EVENT kind field).
+const (
+	WorldEventNight        = 1 // active = night, inactive = day
+	WorldEventStorm        = 2 // value = movement speed percent while active
+	WorldEventAnnouncement = 3 // text only
+)
+
+// Text limits: the MaxLen of the string fields (FieldString) in schema.go.
+
+// MaxWorldEventText — upper bound on announcement text bytes (UTF-8).
+const MaxWorldEventText = 512
+
+// Announcement severities (ANNOUNCE severity field).
+const (
+	SeverityInfo     = 0
+	SeverityWarning  = 1
+	SeverityCritical = 2
+)
+
+// Announcement kinds (ANNOUNCE kind field).
+const (
+	AnnounceMessage = 0 // admin announcement
+	AnnounceMOTD    = 1 // message of the day, sent after JOIN
+)
+
+// MaxAnnounceText — upper bound on ANNOUNCE text bytes (UTF-8).
+const MaxAnnounceText = 1024
+
+// MaxChatText — upper bound on CHAT / CHAT_MESSAGE text bytes (UTF-8).
+const MaxChatText = 256
+
+// MaxCommandResultText — upper bound on COMMAND_RESULT text bytes (UTF-8).
+const MaxCommandResultText = 4096
+
+// Command result statuses (COMMAND_RESULT status field).
+const (
+	CommandOK      = 0 // done; text is the output
+	CommandError   = 1 // bad arguments or the command failed; text says why
+	CommandDenied  = 2 // the caller's role may not run it
+	CommandUnknown = 3 // no such command
+	CommandMuted   = 4 // a chat line from a muted player, not relayed
+)
+
+// Reasons an input was refused (ACTION_REJECTED reason field).
+const (
+	RejectCooldown    = 1 // the previous attack's cooldown is still running
+	RejectStunned     = 2
+	RejectDead        = 3 // until respawn; retryAfterTicks = 0 when no respawn is scheduled
+	RejectFrozen      = 4 // frozen by an admin, for no known time
+	RejectRateLimited = 5 // message rate limit; sent once per burst of dropped messages
+	RejectUnknown     = 6 // not allowed at all, e.g. an emote missing from the catalog
+	RejectSafeZone    = 7 // the attacker stands in a safe zone
+)
+
+// Login queue tiers (QUEUE_STATUS tier field), the highest queued first.
+const (
+	QueueTierStandard  = 0
+	QueueTierReturning = 1 // the account played here a short time ago
+	QueueTierVIP       = 2 // the session token carries the vip flag
+)
+
+// Party actions (PARTY action field).
+const (
+	PartyInvite = 0 // invite playerId
+	PartyAccept = 1 // accept the invite of playerId
+	PartyLeave  = 2 // leave the party; playerId is ignored
+)
 
-// MovementVector представляет движение игрока
+// MaxAccountText — upper bound on the account of FRIEND / FRIEND_UPDATE (auth.MaxAccountLen).
+const MaxAccountText = 64
+
+// Friend actions (FRIEND action field).
+const (
+	FriendAdd    = 0 // ask account to be friends, or accept its request
+	FriendRemove = 1 // end the friendship, or decline / withdraw a request
+)
+
+// Friend statuses (FRIEND_UPDATE status field).
+const (
+	FriendOffline  = 0 // a friend not online on this server
+	FriendOnline   = 1 // a friend online, in zoneRow/zoneCol
+	FriendIncoming = 2 // account asks the client to be friends
+	FriendOutgoing = 3 // the client asked account, not answered yet
+	FriendRemoved  = 4 // no longer a friend or a request
+)
+
+// ZoneNone — FRIEND_UPDATE zoneRow/zoneCol of a player whose zone is not known
+// (offline, or zones are off).
+const ZoneNone = 0xFF
+
+// FriendUpdate — one FRIEND_UPDATE.
+type FriendUpdate struct {
+	Status           uint8  // Friend* status
+	PlayerID         uint32 // the friend's player while online; 0 otherwise
+	ZoneRow, ZoneCol uint8  // ZoneNone while not known
+	Account          string
+}
+
+// Trade actions (TRADE action field).
+const (
+	TradeRequest = 0 // ask playerId to trade
+	TradeAccept  = 1 // open the trade playerId asked for
+	TradeOffer   = 2 // offer count of item (0 = withdraw it)
+	TradeConfirm = 3 // agree to both offers as they are
+	TradeCancel  = 4 // end the trade, or decline the request of playerId
+)
+
+// Trade statuses (TRADE_STATE status field).
+const (
+	TradeRequested = 0 // partnerId asks the client to trade
+	TradeOpen      = 1
+	TradeCompleted = 2 // the offers were swapped
+	TradeCancelled = 3 // cancelled, declined, or a side no longer had its offer
+)
+
+// Trade confirmation flags (TRADE_STATE confirmed field).
+const (
+	TradeConfirmedSelf    = 0x01
+	TradeConfirmedPartner = 0x02
+)
+
+// ItemStack — count of one item (INVENTORY entry).
+type ItemStack struct {
+	Item  uint16
+	Count uint32
+}
+
+// TradeOfferEntry — one offered stack of a TRADE_STATE; Partner = offered by the other side.
+type TradeOfferEntry struct {
+	Partner bool
+	Item    uint16
+	Count   uint32
+}
+
+// LeaderboardEntry — one ranked player of a LEADERBOARD, best first.
+type LeaderboardEntry struct {
+	PlayerID uint32 // 0 = not online on this server
+	Score    uint32
+}
+
+// BinaryProtocol обрабатывает сериализацию/десериализацию сообщений.
+// Раскладка байтов берётся из таблицы Messages (schema.go) — здесь нет ручных смещений,
+// только отображение полей схемы на значения Go-структур.
+//
+// Позиции игроков (записи игроков, MOVEMENT_ACK) идут в 1/2^PositionBits единицы мира:
+// нулевое значение — целые единицы, как у клиентов LegacySubprotocol. В больших мирах
+// (config.LargeWorld) позиции отсчитываются от точки OriginX, OriginY, которую клиент
+// узнаёт из ORIGIN, — у каждого соединения свой BinaryProtocol.
+type BinaryProtocol struct {
+	PositionBits     uint8  // 0-8, см. config Net.PositionFractionBits
+	OriginX, OriginY uint32 // world units
+}
+
+// Position returns the wire values of point (x, y): from the origin, in 1/2^PositionBits
+// of a unit, rounded down and saturated at the uint16 range.
+func (bp *BinaryProtocol) Position(x, y types.Fixed) (uint16, uint16) {
+	return bp.coord(x, bp.OriginX), bp.coord(y, bp.OriginY)
+}
+
+func (bp *BinaryProtocol) coord(v types.Fixed, origin uint32) uint16 {
+	v -= min(types.FixedOf(origin), v)
+	return uint16(min(uint32(v)>>(types.FracBits-bp.PositionBits), math.MaxUint16))
+}
+
+// MovementVector представляет движение игрока: направление DX, DY (-1, 0, 1) и
+// аналоговые оси AX, AY (-MaxAxis..MaxAxis) того же знака. У цифрового ввода оси
+// полные: AX = DX*MaxAxis.
 type MovementVector struct {
 	DX int8
 	DY int8
+	AX int8
+	AY int8
 }
 
+// MaxAxis — полное отклонение аналоговой оси MOVE.
+const MaxAxis = 127
+
 // ClientMessage представляет сообщение от клиента
 type ClientMessage struct {
 	Type           uint8
 	MovementVector MovementVector
 	Direction      bool // FacingRight
 	InputSequence  uint32
+	ViewportWidth  uint16 // world units, MessageViewportUpdate only
+	ViewportHeight uint16
+	LastSequence   uint32 // MessageSequenceReport only
+	Missed         uint32 // MessageSequenceReport: messages lost since the previous report
+	Resync         bool   // MessageSequenceReport: client asks for a full GAME_STATE
+	Corrupted      uint32 // MessageSequenceReport: messages that failed the checksum since the previous report
+	Capabilities   uint8  // MessageJoin: Cap* flags (CapsLegacy for a bare JOIN)
+	MaxMessageSize uint32 // MessageJoin: 0 = unlimited
+	ReliableID     uint32 // MessageReliableAck: ID of the acked RELIABLE message
+	Text           string // MessageChat, MessagePartyChat: the line, at most MaxChatText bytes
+	PartyAction    uint8  // MessageParty: Party* action
+	TargetID       uint32 // MessageParty: the player invited (PartyInvite) or whose invite is accepted (PartyAccept)
+	FriendAction   uint8  // MessageFriend: Friend* action
+	Account        string // MessageFriend: the other account, at most MaxAccountText bytes
+	TradeAction    uint8  // MessageTrade: Trade* action; TargetID, Item and Count are its arguments
+	Item           uint16 // MessageTrade: TradeOffer item
+	Count          uint32 // MessageTrade: TradeOffer count
+	EmoteID        uint8  // MessageEmote
+	AimX           int8   // MessageAttack: aim direction; 0, 0 = none
+	AimY           int8
 }
 
+// Client capabilities (JOIN capabilities field).
+const (
+	CapDeltaUpdates  = 0x01 // merges DELTA_GAME_STATE; without it every update is a full GAME_STATE
+	CapCompression   = 0x02 // wants permessage-deflate frames when the extension was negotiated
+	CapMinimap       = 0x04 // subscribes to the periodic MINIMAP density grid
+	CapChecksum      = 0x08 // CRC-32C on every message in both directions (checksum.go)
+	CapEncryption    = 0x10 // holds the session key: sensitive message types go sealed (sealed.go)
+	CapCellStreaming = 0x20 // world content streamed per cell as the viewport moves (CELL_LOAD / CELL_UNLOAD)
+	CapReliable      = 0x40 // acks critical messages sent in RELIABLE envelopes (reliable.go)
+	CapLeaderboard   = 0x80 // subscribes to the periodic LEADERBOARD
+
+	// CapsLegacy — capabilities of a client that sends JOIN without the capability fields.
+	CapsLegacy = CapDeltaUpdates
+)
+
 // PackMovement упаковывает движение в один байт (совместимо с artillery-processor.cjs)
 func PackMovement(dx, dy int8) uint8 {
 	packed := uint8(0)
@@ -50,49 +319,299 @@ func PackMovement(dx, dy int8) uint8 {
 	return packed
 }
 
-// UnpackMovement распаковывает движение из байта
+// UnpackMovement распаковывает движение из байта; оси — полные по направлению.
 func UnpackMovement(packed uint8) MovementVector {
 	dx := int8(packed&0x03) - 1      // Extract bits 0-1, convert back to -1,0,1
 	dy := int8((packed>>2)&0x03) - 1 // Extract bits 2-3, convert back to -1,0,1
-	return MovementVector{DX: dx, DY: dy}
+	return MovementVector{DX: dx, DY: dy, AX: dx * MaxAxis, AY: dy * MaxAxis}
+}
+
+// setAxes sets the analog axes of a MOVE. An axis must lie in -MaxAxis..MaxAxis with
+// the sign of the packed direction; 0 keeps the axis full, so a MOVE encoded with the
+// axes left at 0 stays digital.
+func (m *MovementVector) setAxes(ax, ay int8) error {
+	if ax < -MaxAxis || ay < -MaxAxis || (ax != 0 && sign(ax) != m.DX) || (ay != 0 && sign(ay) != m.DY) {
+		return fmt.Errorf("move axes %d,%d: want -%d..%d with the signs of direction %d,%d", ax, ay, MaxAxis, MaxAxis, m.DX, m.DY)
+	}
+	if ax != 0 {
+		m.AX = ax
+	}
+	if ay != 0 {
+		m.AY = ay
+	}
+	return nil
+}
+
+// Throttle returns the magnitude of each axis, 0..MaxAxis.
+func (m MovementVector) Throttle() (x, y uint8) {
+	return uint8(m.DX * m.AX), uint8(m.DY * m.AY)
+}
+
+func sign(v int8) int8 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// SequenceReportResync — бит в поле flags SEQUENCE_REPORT: клиент просит полный GAME_STATE.
+const SequenceReportResync = 0x01
+
+// MaxInputBatch — максимум inputs в одном INPUT_BATCH. Больше — протокольная ошибка,
+// чтобы один фрейм не мог обойти per-connection rate limit.
+const MaxInputBatch = 32
+
+// maxSchemaFields — upper bound on fixed fields per message (stack-allocated value arrays).
+const maxSchemaFields = 12
+
+// putFields writes values in schema order starting at dst[offset] and returns the next offset.
+// dst must already be sized (see MessageSchema.Size).
+func putFields(dst []byte, offset int, fields []Field, values []uint32) int {
+	for i, f := range fields {
+		v := values[i]
+		switch f.Type {
+		case FieldU16:
+			binary.LittleEndian.PutUint16(dst[offset:], uint16(v))
+		case FieldU32, FieldCount, FieldString:
+			binary.LittleEndian.PutUint32(dst[offset:], v)
+		default:
+			dst[offset] = uint8(v)
+		}
+		offset += f.Type.Width()
+	}
+	return offset
 }
 
-// DecodeClientMessage декодирует сообщение от клиента
-func (bp *BinaryProtocol) DecodeClientMessage(data []byte) (*ClientMessage, error) {
+// getFields reads fields in schema order starting at src[offset] into values.
+// Signed and packed fields are returned raw; callers convert them.
+func getFields(src []byte, offset int, fields []Field, values []uint32) int {
+	for i, f := range fields {
+		switch f.Type {
+		case FieldU16:
+			values[i] = uint32(binary.LittleEndian.Uint16(src[offset:]))
+		case FieldU32, FieldCount, FieldString:
+			values[i] = binary.LittleEndian.Uint32(src[offset:])
+		default:
+			values[i] = uint32(src[offset])
+		}
+		offset += f.Type.Width()
+	}
+	return offset
+}
+
+// growFor extends dst by size bytes, reusing its capacity when possible.
+// Returns the extended slice and the offset where the new message starts.
+func growFor(dst []byte, size int) ([]byte, int) {
+	startOffset := len(dst)
+	totalSize := startOffset + size
+	if cap(dst) < totalSize {
+		newDst := make([]byte, totalSize, totalSize+size)
+		copy(newDst, dst)
+		return newDst, startOffset
+	}
+	return dst[:totalSize], startOffset
+}
+
+// playerFlags packs state (bits 0-6: bit 6 = spawn protection, bit 5 = safe zone) and
+// facing (bit 7) into the flags byte.
+func playerFlags(player types.PlayerState) uint32 {
+	flags := uint32(player.State & 0x7F)
+	if player.FacingRight {
+		flags |= 0x80
+	}
+	return flags
+}
+
+// playerEntryValues maps PlayerState onto playerEntryFields (id, x, y, vx, vy, flags).
+func (bp *BinaryProtocol) playerEntryValues(values *[maxSchemaFields]uint32, player types.PlayerState) {
+	x, y := bp.Position(player.Fixed())
+	values[0] = player.ID
+	values[1] = uint32(x)
+	values[2] = uint32(y)
+	values[3] = uint32(uint8(player.VX))
+	values[4] = uint32(uint8(player.VY))
+	values[5] = playerFlags(player)
+}
+
+// DecodeClientMessage декодирует сообщение от клиента.
+// Обычное сообщение даёт срез из одного элемента; INPUT_BATCH разворачивается в
+// упорядоченные MOVE inputs с последовательными номерами baseSequence, baseSequence+1, ...
+func (bp *BinaryProtocol) DecodeClientMessage(data []byte) ([]ClientMessage, error) {
 	if len(data) < 1 {
 		return nil, fmt.Errorf("message too short")
 	}
 
-	msg := &ClientMessage{
-		Type: data[0],
+	schema := LookupSchema(data[0])
+	if schema == nil || schema.Direction != ClientToServer {
+		return nil, fmt.Errorf("unknown message type: %d", data[0])
+	}
+	if len(data) < schema.MinSize() {
+		return nil, fmt.Errorf("%s message too short", strings.ToLower(schema.ConstName()))
+	}
+	fields := schema.Fields
+	full := len(data) >= schema.Size(0)
+	if !full {
+		fields = schema.RequiredFields()
+	}
+
+	var values [maxSchemaFields]uint32
+	offset := getFields(data, 1, fields, values[:])
+
+	var text string
+	if field, ok := schema.Text(); ok {
+		var err error
+		if text, err = decodeText(data, offset, schema, field, values[len(fields)-1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if data[0] == MessageInputBatch {
+		return decodeInputBatch(data, offset, schema, values[0], values[1])
 	}
 
+	msgs := make([]ClientMessage, 1)
+	msg := &msgs[0]
+	msg.Type = data[0]
+
 	switch msg.Type {
 	case MessageMove:
-		if len(data) < 6 {
-			return nil, fmt.Errorf("move message too short")
+		msg.MovementVector = UnpackMovement(uint8(values[0]))
+		msg.InputSequence = values[1]
+		if full {
+			if err := msg.MovementVector.setAxes(int8(values[2]), int8(values[3])); err != nil {
+				return nil, err
+			}
 		}
-		movement := UnpackMovement(data[1])
-		msg.MovementVector = movement
-		msg.InputSequence = binary.LittleEndian.Uint32(data[2:6])
 
 	case MessageDirection:
-		if len(data) < 2 {
-			return nil, fmt.Errorf("direction message too short")
+		msg.Direction = values[0] == 1
+
+	case MessageJoin:
+		msg.Capabilities = CapsLegacy
+		if full {
+			msg.Capabilities = uint8(values[0])
+			msg.MaxMessageSize = values[1]
+		}
+
+	case MessageAttack:
+		// Older clients append x, y as float32: only the exact 3-byte form is aimed.
+		if len(data) == schema.Size(0) {
+			msg.AimX, msg.AimY = int8(values[0]), int8(values[1])
+			if msg.AimX == math.MinInt8 || msg.AimY == math.MinInt8 {
+				return nil, fmt.Errorf("attack aim out of range")
+			}
 		}
-		msg.Direction = data[1] == 1
 
-	case MessageAttack, MessageAttackEnd:
-		// No additional data needed for these messages
+	case MessageAttackEnd:
+		// No additional data needed
 
 	case MessageViewportUpdate:
-		// Accepted but not processed — viewport-based culling not yet implemented.
+		msg.ViewportWidth = uint16(values[0])
+		msg.ViewportHeight = uint16(values[1])
+
+	case MessageSequenceReport:
+		msg.LastSequence = values[0]
+		msg.Missed = values[1]
+		msg.Resync = values[2]&SequenceReportResync != 0
+		if full {
+			msg.Corrupted = values[3]
+		}
+
+	case MessageReliableAck:
+		msg.ReliableID = values[0]
+
+	case MessageChat, MessagePartyChat:
+		msg.Text = text
 
-	default:
-		return nil, fmt.Errorf("unknown message type: %d", msg.Type)
+	case MessageParty:
+		msg.PartyAction = uint8(values[0])
+		msg.TargetID = values[1]
+
+	case MessageFriend:
+		msg.FriendAction = uint8(values[0])
+		msg.Account = text
+
+	case MessageTrade:
+		msg.TradeAction = uint8(values[0])
+		msg.TargetID = values[1]
+		msg.Item = uint16(values[2])
+		msg.Count = values[3]
+
+	case MessageEmote:
+		msg.EmoteID = uint8(values[0])
+	}
+
+	return msgs, nil
+}
+
+// decodeText reads the n bytes of text at offset, the trailing string field of schema,
+// and makes them safe to pass on: invalid UTF-8 becomes U+FFFD and control characters
+// are dropped. Text longer than field.MaxLen is an error, not truncated: a client that
+// follows the protocol never sends it.
+func decodeText(data []byte, offset int, schema *MessageSchema, field Field, n uint32) (string, error) {
+	name := strings.ToLower(schema.ConstName())
+	if n > uint32(field.MaxLen) {
+		return "", fmt.Errorf("%s %s of %d bytes exceeds %d", name, field.Name, n, field.MaxLen)
+	}
+	if len(data) < schema.Size(int(n)) {
+		return "", fmt.Errorf("%s message too short", name)
 	}
+	return SanitizeText(string(data[offset : offset+int(n)])), nil
+}
+
+// SanitizeText replaces invalid UTF-8 with U+FFFD and drops control characters
+// (newlines and tabs included): decoded text is one printable line.
+func SanitizeText(s string) string {
+	if utf8.ValidString(s) && strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(s, string(utf8.RuneError)))
+}
 
-	return msg, nil
+// TruncateText cuts s to at most maxLen bytes on a character boundary, after
+// replacing invalid UTF-8 with U+FFFD, so the text on the wire is always valid.
+func TruncateText(s string, maxLen int) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if len(s) <= maxLen {
+		return s
+	}
+	n := maxLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// decodeInputBatch expands INPUT_BATCH entries (starting at offset) into MOVE messages.
+func decodeInputBatch(data []byte, offset int, schema *MessageSchema, baseSequence, count uint32) ([]ClientMessage, error) {
+	if count == 0 || count > MaxInputBatch {
+		return nil, fmt.Errorf("input batch size %d out of range 1..%d", count, MaxInputBatch)
+	}
+	if len(data) < schema.Size(int(count)) {
+		return nil, fmt.Errorf("input_batch message too short")
+	}
+
+	msgs := make([]ClientMessage, count)
+	var values [maxSchemaFields]uint32
+	for i := range msgs {
+		offset = getFields(data, offset, schema.Repeated, values[:])
+		msgs[i] = ClientMessage{
+			Type:           MessageMove,
+			MovementVector: UnpackMovement(uint8(values[0])),
+			InputSequence:  baseSequence + uint32(i),
+		}
+	}
+	return msgs, nil
 }
 
 // EncodeGameState кодирует состояние игры для отправки клиенту
@@ -105,55 +624,13 @@ func (bp *BinaryProtocol) EncodeGameState(players []types.PlayerState, stateSequ
 // after those bytes — dst[len(dst):len(dst)+payloadSize] — with no allocation if
 // cap(dst) is sufficient (ring slot pre-allocated to 64 KB).
 func (bp *BinaryProtocol) AppendGameState(dst []byte, players []types.PlayerState, stateSequence uint32) []byte {
-	// Header: message type (1) + state sequence (4) + player count (4) = 9 bytes
-	playerSize := 11 // ID(4) + X(2) + Y(2) + VX(1) + VY(1) + Flags(1) = 11 bytes
-	startOffset := len(dst)
-	payloadSize := 9 + len(players)*playerSize
-	totalSize := startOffset + payloadSize
-
-	if cap(dst) < totalSize {
-		newDst := make([]byte, totalSize, totalSize+payloadSize)
-		copy(newDst, dst)
-		dst = newDst
-	} else {
-		dst = dst[:totalSize]
-	}
-
-	offset := startOffset
-
-	// Message type
-	dst[offset] = MessageGameState
-	offset++
-
-	// Monotonic world-state sequence (for stale/out-of-order filtering on client)
-	binary.LittleEndian.PutUint32(dst[offset:], stateSequence)
-	offset += 4
-
-	// Player count
-	binary.LittleEndian.PutUint32(dst[offset:], uint32(len(players)))
-	offset += 4
-
-	// Players data
-	for _, player := range players {
-		binary.LittleEndian.PutUint32(dst[offset:], player.ID)
-		offset += 4
-		binary.LittleEndian.PutUint16(dst[offset:], player.X)
-		offset += 2
-		binary.LittleEndian.PutUint16(dst[offset:], player.Y)
-		offset += 2
-		dst[offset] = uint8(player.VX)
-		offset++
-		dst[offset] = uint8(player.VY)
-		offset++
-		flags := uint8(player.State & 0x7F)
-		if player.FacingRight {
-			flags |= 0x80
-		}
-		dst[offset] = flags
-		offset++
-	}
+	return bp.appendPlayerList(dst, schemaGameState, stateSequence, players)
+}
 
-	return dst
+// AppendGameStateParts encodes a full game state whose players are split across several
+// slices (e.g. a shared snapshot plus one extra player) without concatenating them first.
+func (bp *BinaryProtocol) AppendGameStateParts(dst []byte, stateSequence uint32, parts ...[]types.PlayerState) []byte {
+	return bp.appendPlayerList(dst, schemaGameState, stateSequence, parts...)
 }
 
 // EncodeDeltaGameState кодирует дельту — только изменившихся игроков.
@@ -167,47 +644,41 @@ func (bp *BinaryProtocol) EncodeDeltaGameState(players []types.PlayerState, stat
 // Формат идентичен AppendGameState (11 байт/игрок), но тип сообщения = MessageDeltaGameState.
 // Клиент мёржит дельту в своё состояние вместо полной замены.
 func (bp *BinaryProtocol) AppendDeltaGameState(dst []byte, players []types.PlayerState, stateSequence uint32) []byte {
-	playerSize := 11
-	startOffset := len(dst)
-	payloadSize := 9 + len(players)*playerSize
-	totalSize := startOffset + payloadSize
+	return bp.appendPlayerList(dst, schemaDeltaGameState, stateSequence, players)
+}
 
-	if cap(dst) < totalSize {
-		newDst := make([]byte, totalSize, totalSize+payloadSize)
-		copy(newDst, dst)
-		dst = newDst
-	} else {
-		dst = dst[:totalSize]
-	}
+// WorldStateSize returns the encoded size of a GAME_STATE / DELTA_GAME_STATE with n players.
+func WorldStateSize(n int) int {
+	return schemaGameState.Size(n)
+}
 
-	offset := startOffset
+// WorldStatePlayersPerMessage returns how many players fit into one GAME_STATE /
+// DELTA_GAME_STATE of at most maxBytes bytes (at least 1).
+func WorldStatePlayersPerMessage(maxBytes int) int {
+	return max((maxBytes-schemaGameState.Size(0))/schemaGameState.EntrySize(), 1)
+}
 
-	dst[offset] = MessageDeltaGameState
-	offset++
+// appendPlayerList encodes a [type][stateSequence][count][players...] message described by schema.
+// The players of all parts are written in order as one list.
+func (bp *BinaryProtocol) appendPlayerList(dst []byte, schema *MessageSchema, stateSequence uint32, parts ...[]types.PlayerState) []byte {
+	count := 0
+	for _, players := range parts {
+		count += len(players)
+	}
+	dst, offset := growFor(dst, schema.Size(count))
 
-	binary.LittleEndian.PutUint32(dst[offset:], stateSequence)
-	offset += 4
+	dst[offset] = schema.Type
+	offset++
 
-	binary.LittleEndian.PutUint32(dst[offset:], uint32(len(players)))
-	offset += 4
+	header := [maxSchemaFields]uint32{stateSequence, uint32(count)}
+	offset = putFields(dst, offset, schema.Fields, header[:])
 
-	for _, player := range players {
-		binary.LittleEndian.PutUint32(dst[offset:], player.ID)
-		offset += 4
-		binary.LittleEndian.PutUint16(dst[offset:], player.X)
-		offset += 2
-		binary.LittleEndian.PutUint16(dst[offset:], player.Y)
-		offset += 2
-		dst[offset] = uint8(player.VX)
-		offset++
-		dst[offset] = uint8(player.VY)
-		offset++
-		flags := uint8(player.State & 0x7F)
-		if player.FacingRight {
-			flags |= 0x80
+	var values [maxSchemaFields]uint32
+	for _, players := range parts {
+		for _, player := range players {
+			bp.playerEntryValues(&values, player)
+			offset = putFields(dst, offset, schema.Repeated, values[:])
 		}
-		dst[offset] = flags
-		offset++
 	}
 
 	return dst
@@ -215,66 +686,320 @@ func (bp *BinaryProtocol) AppendDeltaGameState(dst []byte, players []types.Playe
 
 // EncodePlayerJoined кодирует сообщение о присоединении игрока
 func (bp *BinaryProtocol) EncodePlayerJoined(player types.PlayerState) []byte {
-	buffer := make([]byte, 12) // 1 + 11 bytes
-	offset := 0
-
-	buffer[offset] = MessagePlayerJoined
-	offset++
+	buffer := make([]byte, schemaPlayerJoined.Size(0)) // 1 + 11 bytes
+	buffer[0] = MessagePlayerJoined
 
 	// Same as in game state but for single player
-	binary.LittleEndian.PutUint32(buffer[offset:], player.ID)
-	offset += 4
-	binary.LittleEndian.PutUint16(buffer[offset:], player.X)
-	offset += 2
-	binary.LittleEndian.PutUint16(buffer[offset:], player.Y)
-	offset += 2
-	buffer[offset] = uint8(player.VX)
-	offset++
-	buffer[offset] = uint8(player.VY)
-	offset++
-
-	flags := uint8(player.State & 0x7F)
-	if player.FacingRight {
-		flags |= 0x80
-	}
-	buffer[offset] = flags
+	var values [maxSchemaFields]uint32
+	bp.playerEntryValues(&values, player)
+	putFields(buffer, 1, schemaPlayerJoined.Fields, values[:])
 
 	return buffer
 }
 
 // EncodePlayerLeft кодирует сообщение об отключении игрока
 func (bp *BinaryProtocol) EncodePlayerLeft(playerID uint32) []byte {
-	buffer := make([]byte, 5) // 1 + 4 bytes
+	buffer := make([]byte, schemaPlayerLeft.Size(0)) // 1 + 4 bytes
 	buffer[0] = MessagePlayerLeft
-	binary.LittleEndian.PutUint32(buffer[1:], playerID)
+	values := [maxSchemaFields]uint32{playerID}
+	putFields(buffer, 1, schemaPlayerLeft.Fields, values[:])
 	return buffer
 }
 
-// EncodeMovementAck кодирует подтверждение движения для отправки клиенту
+// EncodeMovementAck кодирует подтверждение движения для отправки клиенту; x, y — уже
+// значения на проводе (Position).
 func (bp *BinaryProtocol) EncodeMovementAck(playerID uint32, x, y uint16, inputSequence uint32) []byte {
-	// Header: message type (1) + player ID (4) + position (4) + input sequence (4) = 13 bytes
-	buffer := make([]byte, 13)
-	offset := 0
+	// message type (1) + player ID (4) + position (4) + input sequence (4) = 13 bytes
+	buffer := make([]byte, schemaMovementAck.Size(0))
+	buffer[0] = MessageMovementAck
+	values := [maxSchemaFields]uint32{playerID, uint32(x), uint32(y), inputSequence}
+	putFields(buffer, 1, schemaMovementAck.Fields, values[:])
+	return buffer
+}
 
-	// Message type
-	buffer[offset] = MessageMovementAck
-	offset++
+// EncodeWorldEvent кодирует глобальное событие мира. text обрезается до MaxWorldEventText
+// байт по границе символа.
+func (bp *BinaryProtocol) EncodeWorldEvent(kind uint8, active bool, value uint16, durationMs uint32, text string) []byte {
+	values := [maxSchemaFields]uint32{uint32(kind), 0, uint32(value), durationMs}
+	if active {
+		values[1] = 1
+	}
+	return encodeText(schemaWorldEvent, values, text)
+}
+
+// EncodeAnnounce кодирует MOTD или объявление. text обрезается до MaxAnnounceText байт
+// по границе символа.
+func (bp *BinaryProtocol) EncodeAnnounce(kind, severity uint8, text string) []byte {
+	return encodeText(schemaAnnounce, [maxSchemaFields]uint32{uint32(kind), uint32(severity)}, text)
+}
+
+// EncodeChatMessage кодирует строку чата игрока playerID для рассылки.
+func (bp *BinaryProtocol) EncodeChatMessage(playerID uint32, text string) []byte {
+	return encodeText(schemaChatMessage, [maxSchemaFields]uint32{playerID}, text)
+}
+
+// EncodeCommandResult кодирует ответ на /команду. text обрезается до MaxCommandResultText байт.
+func (bp *BinaryProtocol) EncodeCommandResult(status uint8, text string) []byte {
+	return encodeText(schemaCommandResult, [maxSchemaFields]uint32{uint32(status)}, text)
+}
 
-	// Player ID (4 bytes)
-	binary.LittleEndian.PutUint32(buffer[offset:], playerID)
-	offset += 4
+// EncodeHit кодирует попадание атаки: урон, HP жертвы после него и её отбрасывание.
+func (bp *BinaryProtocol) EncodeHit(attackerID, victimID uint32, damage, hp uint16, knockbackX, knockbackY int8) []byte {
+	buffer := make([]byte, schemaHit.Size(0))
+	buffer[0] = MessageHit
+	values := [maxSchemaFields]uint32{attackerID, victimID, uint32(damage), uint32(hp), uint32(uint8(knockbackX)), uint32(uint8(knockbackY))}
+	putFields(buffer, 1, schemaHit.Fields, values[:])
+	return buffer
+}
 
-	// Position X (2 bytes)
-	binary.LittleEndian.PutUint16(buffer[offset:], x)
-	offset += 2
+// EncodeActionRejected кодирует отказ в действии: тип сообщения клиента, причину
+// (Reject*) и через сколько тиков его имеет смысл повторить (0 = неизвестно).
+func (bp *BinaryProtocol) EncodeActionRejected(action, reason uint8, retryAfterTicks uint16) []byte {
+	buffer := make([]byte, schemaActionRejected.Size(0))
+	buffer[0] = MessageActionRejected
+	values := [maxSchemaFields]uint32{uint32(action), uint32(reason), uint32(retryAfterTicks)}
+	putFields(buffer, 1, schemaActionRejected.Fields, values[:])
+	return buffer
+}
 
-	// Position Y (2 bytes)
-	binary.LittleEndian.PutUint16(buffer[offset:], y)
-	offset += 2
+// EncodeLeaderboard кодирует таблицу лидеров по метрике (leaderboard.MetricID), лучшие первыми.
+func (bp *BinaryProtocol) EncodeLeaderboard(metric uint8, entries []LeaderboardEntry) []byte {
+	buffer := make([]byte, schemaLeaderboard.Size(len(entries)))
+	buffer[0] = MessageLeaderboard
+	header := [maxSchemaFields]uint32{uint32(metric), uint32(len(entries))}
+	offset := putFields(buffer, 1, schemaLeaderboard.Fields, header[:])
+	for _, e := range entries {
+		values := [maxSchemaFields]uint32{e.PlayerID, e.Score}
+		offset = putFields(buffer, offset, schemaLeaderboard.Repeated, values[:])
+	}
+	return buffer
+}
 
-	// Input sequence (4 bytes)
-	binary.LittleEndian.PutUint32(buffer[offset:], inputSequence)
-	offset += 4
+// EncodePartyInvite кодирует приглашение в группу от игрока inviterID.
+func (bp *BinaryProtocol) EncodePartyInvite(inviterID uint32) []byte {
+	buffer := make([]byte, schemaPartyInvite.Size(0))
+	buffer[0] = MessagePartyInvite
+	values := [maxSchemaFields]uint32{inviterID}
+	putFields(buffer, 1, schemaPartyInvite.Fields, values[:])
+	return buffer
+}
+
+// EncodePlayerEmote кодирует эмоцию игрока playerID.
+func (bp *BinaryProtocol) EncodePlayerEmote(playerID uint32, emoteID uint8) []byte {
+	buffer := make([]byte, schemaPlayerEmote.Size(0))
+	buffer[0] = MessagePlayerEmote
+	values := [maxSchemaFields]uint32{playerID, uint32(emoteID)}
+	putFields(buffer, 1, schemaPlayerEmote.Fields, values[:])
+	return buffer
+}
+
+// EncodeQueueStatus кодирует место клиента в очереди на вход; waitSec 0 — оценки
+// пока нет.
+func (bp *BinaryProtocol) EncodeQueueStatus(position, length, waitSec uint32, tier uint8) []byte {
+	buffer := make([]byte, schemaQueueStatus.Size(0))
+	buffer[0] = MessageQueueStatus
+	values := [maxSchemaFields]uint32{position, length, waitSec, uint32(tier)}
+	putFields(buffer, 1, schemaQueueStatus.Fields, values[:])
+	return buffer
+}
+
+// EncodeQueueAdmitted кодирует выход клиента из очереди после waitedMs ожидания.
+func (bp *BinaryProtocol) EncodeQueueAdmitted(waitedMs uint32) []byte {
+	buffer := make([]byte, schemaQueueAdmitted.Size(0))
+	buffer[0] = MessageQueueAdmitted
+	values := [maxSchemaFields]uint32{waitedMs}
+	putFields(buffer, 1, schemaQueueAdmitted.Fields, values[:])
+	return buffer
+}
+
+// EncodeOrigin кодирует ORIGIN — точку, от которой bp отсчитывает позиции.
+func (bp *BinaryProtocol) EncodeOrigin() []byte {
+	buffer := make([]byte, schemaOrigin.Size(0))
+	buffer[0] = MessageOrigin
+	values := [maxSchemaFields]uint32{bp.OriginX, bp.OriginY}
+	putFields(buffer, 1, schemaOrigin.Fields, values[:])
+	return buffer
+}
+
+// EncodePartyRoster кодирует состав группы клиента; partyID 0 и пустой members —
+// клиент не в группе.
+func (bp *BinaryProtocol) EncodePartyRoster(partyID, leaderID uint32, members []uint32) []byte {
+	buffer := make([]byte, schemaPartyRoster.Size(len(members)))
+	buffer[0] = MessagePartyRoster
+	header := [maxSchemaFields]uint32{partyID, leaderID, uint32(len(members))}
+	offset := putFields(buffer, 1, schemaPartyRoster.Fields, header[:])
+	for _, id := range members {
+		values := [maxSchemaFields]uint32{id}
+		offset = putFields(buffer, offset, schemaPartyRoster.Repeated, values[:])
+	}
+	return buffer
+}
+
+// EncodeInventory кодирует предметы клиента, по возрастанию номера.
+func (bp *BinaryProtocol) EncodeInventory(items []ItemStack) []byte {
+	buffer := make([]byte, schemaInventory.Size(len(items)))
+	buffer[0] = MessageInventory
+	header := [maxSchemaFields]uint32{uint32(len(items))}
+	offset := putFields(buffer, 1, schemaInventory.Fields, header[:])
+	for _, st := range items {
+		values := [maxSchemaFields]uint32{uint32(st.Item), st.Count}
+		offset = putFields(buffer, offset, schemaInventory.Repeated, values[:])
+	}
+	return buffer
+}
+
+// EncodeTradeState кодирует состояние обмена клиента с игроком partnerID.
+func (bp *BinaryProtocol) EncodeTradeState(tradeID uint32, status uint8, partnerID uint32, confirmed uint8, offers []TradeOfferEntry) []byte {
+	buffer := make([]byte, schemaTradeState.Size(len(offers)))
+	buffer[0] = MessageTradeState
+	header := [maxSchemaFields]uint32{tradeID, uint32(status), partnerID, uint32(confirmed), uint32(len(offers))}
+	offset := putFields(buffer, 1, schemaTradeState.Fields, header[:])
+	for _, o := range offers {
+		var side uint32
+		if o.Partner {
+			side = 1
+		}
+		values := [maxSchemaFields]uint32{side, uint32(o.Item), o.Count}
+		offset = putFields(buffer, offset, schemaTradeState.Repeated, values[:])
+	}
+	return buffer
+}
+
+// EncodeFriendUpdate кодирует присутствие друга или изменение списка друзей.
+func (bp *BinaryProtocol) EncodeFriendUpdate(u FriendUpdate) []byte {
+	values := [maxSchemaFields]uint32{uint32(u.Status), u.PlayerID, uint32(u.ZoneRow), uint32(u.ZoneCol)}
+	return encodeText(schemaFriendUpdate, values, u.Account)
+}
+
+// EncodePartyChatMessage кодирует строку чата группы от игрока playerID.
+func (bp *BinaryProtocol) EncodePartyChatMessage(playerID uint32, text string) []byte {
+	return encodeText(schemaPartyChatMessage, [maxSchemaFields]uint32{playerID}, text)
+}
+
+// encodeText encodes a message that ends in a string field: values holds the fields
+// before it, text is cut to the field's MaxLen (TruncateText).
+func encodeText(schema *MessageSchema, values [maxSchemaFields]uint32, text string) []byte {
+	field, _ := schema.Text()
+	text = TruncateText(text, field.MaxLen)
+	buffer := make([]byte, schema.Size(len(text)))
+	buffer[0] = schema.Type
+	values[len(schema.Fields)-1] = uint32(len(text))
+	offset := putFields(buffer, 1, schema.Fields, values[:])
+	copy(buffer[offset:], text)
+	return buffer
+}
+
+// EncodeSessionTakeover кодирует уведомление о том, что сессию забрало новое соединение.
+func (bp *BinaryProtocol) EncodeSessionTakeover(playerID uint32) []byte {
+	buffer := make([]byte, schemaSessionTakeover.Size(0))
+	buffer[0] = MessageSessionTakeover
+	values := [maxSchemaFields]uint32{playerID}
+	putFields(buffer, 1, schemaSessionTakeover.Fields, values[:])
+	return buffer
+}
+
+// EncodeConfig кодирует игровые константы сервера для клиента.
+func (bp *BinaryProtocol) EncodeConfig(cfg GameConfig) []byte {
+	buffer := make([]byte, schemaConfig.Size(0))
+	buffer[0] = MessageConfig
+	values := [maxSchemaFields]uint32{
+		cfg.PlayerID,
+		uint32(cfg.TickRate),
+		uint32(cfg.PlayerSpeedPerTick),
+		cfg.WorldWidth & 0xFFFF,
+		cfg.WorldHeight & 0xFFFF,
+		uint32(cfg.BoundaryMode),
+		uint32(cfg.Acceleration),
+		uint32(cfg.Friction),
+		uint32(bp.PositionBits),
+		cfg.WorldWidth >> 16,
+		cfg.WorldHeight >> 16,
+		cfg.WorldSeed,
+	}
+	putFields(buffer, 1, schemaConfig.Fields, values[:])
+	return buffer
+}
+
+// EncodeWorldUpdate кодирует новые размеры мира после изменения через admin API.
+func (bp *BinaryProtocol) EncodeWorldUpdate(width, height uint32) []byte {
+	buffer := make([]byte, schemaWorldUpdate.Size(0))
+	buffer[0] = MessageWorldUpdate
+	values := [maxSchemaFields]uint32{width & 0xFFFF, height & 0xFFFF, BoundaryClamp, width >> 16, height >> 16}
+	putFields(buffer, 1, schemaWorldUpdate.Fields, values[:])
+	return buffer
+}
+
+// AppendCellLoad appends a CELL_LOAD with the players of a number of newly loaded cells to dst.
+func (bp *BinaryProtocol) AppendCellLoad(dst []byte, cellSize uint16, cells int, players []types.PlayerState) []byte {
+	dst, offset := growFor(dst, schemaCellLoad.Size(len(players)))
+	dst[offset] = MessageCellLoad
+	header := [maxSchemaFields]uint32{uint32(cellSize), uint32(cells), uint32(len(players))}
+	offset = putFields(dst, offset+1, schemaCellLoad.Fields, header[:])
+	var values [maxSchemaFields]uint32
+	for _, player := range players {
+		bp.playerEntryValues(&values, player)
+		offset = putFields(dst, offset, schemaCellLoad.Repeated, values[:])
+	}
+	return dst
+}
+
+// CellLoadPlayersPerMessage returns how many players fit into one CELL_LOAD of at
+// most maxBytes bytes (at least 1).
+func CellLoadPlayersPerMessage(maxBytes int) int {
+	return max((maxBytes-schemaCellLoad.Size(0))/schemaCellLoad.EntrySize(), 1)
+}
+
+// EncodeCellUnload кодирует выгрузку ячеек; cells — ключи y<<16 | x.
+func (bp *BinaryProtocol) EncodeCellUnload(cellSize uint16, cells []uint32) []byte {
+	buffer := make([]byte, schemaCellUnload.Size(len(cells)))
+	buffer[0] = MessageCellUnload
+	header := [maxSchemaFields]uint32{uint32(cellSize), uint32(len(cells))}
+	offset := putFields(buffer, 1, schemaCellUnload.Fields, header[:])
+	for _, key := range cells {
+		values := [maxSchemaFields]uint32{key & 0xFFFF, key >> 16}
+		offset = putFields(buffer, offset, schemaCellUnload.Repeated, values[:])
+	}
+	return buffer
+}
+
+// MaxMinimapCells — upper bound on cols×rows of a MINIMAP grid (both are u8).
+const MaxMinimapCells = 255 * 255
+
+// EncodeMinimap кодирует сетку плотности игроков (counts — row-major, cols×rows ячеек).
+// Ячейки сжимаются в пары (run, count): run одинаковых подряд значений, не больше 255.
+func (bp *BinaryProtocol) EncodeMinimap(cols, rows uint8, cellWidth, cellHeight uint16, counts []uint8) []byte {
+	runs := 0
+	for i := 0; i < len(counts); runs++ {
+		i += minimapRun(counts[i:])
+	}
+	buffer := make([]byte, schemaMinimap.Size(runs))
+	buffer[0] = MessageMinimap
+	values := [maxSchemaFields]uint32{uint32(cols), uint32(rows), uint32(cellWidth), uint32(cellHeight), uint32(runs)}
+	offset := putFields(buffer, 1, schemaMinimap.Fields, values[:])
+	for i := 0; i < len(counts); {
+		n := minimapRun(counts[i:])
+		buffer[offset] = uint8(n)
+		buffer[offset+1] = counts[i]
+		offset += 2
+		i += n
+	}
+	return buffer
+}
+
+// minimapRun returns the length of the run at the start of counts (1..255).
+func minimapRun(counts []uint8) int {
+	n := 1
+	for n < len(counts) && n < 255 && counts[n] == counts[0] {
+		n++
+	}
+	return n
+}
 
+// EncodeMaintenance кодирует фазу режима обслуживания.
+func (bp *BinaryProtocol) EncodeMaintenance(phase uint8, countdownMs uint32) []byte {
+	buffer := make([]byte, schemaMaintenance.Size(0))
+	buffer[0] = MessageMaintenance
+	values := [maxSchemaFields]uint32{uint32(phase), countdownMs}
+	putFields(buffer, 1, schemaMaintenance.Fields, values[:])
 	return buffer
 }
@@ -0,0 +1,62 @@
+package protocol_test
+
+import (
+	"fmt"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+// Hot-path benchmarks: every client message goes through DecodeClientMessage and
+// every tick encodes the world. Compare runs with cmd/bench.
+
+func BenchmarkDecodeClientMessage(b *testing.B) {
+	batch := []byte{protocol.MessageInputBatch, 0x0A, 0x00, 0x00, 0x00, protocol.MaxInputBatch, 0x00, 0x00, 0x00}
+	for i := 0; i < protocol.MaxInputBatch; i++ {
+		batch = append(batch, protocol.PackMovement(1, 0))
+	}
+	inputs := []struct {
+		name string
+		data []byte
+	}{
+		{"move", []byte{protocol.MessageMove, protocol.PackMovement(1, -1), 0x39, 0x30, 0x00, 0x00}},
+		{"join", []byte{protocol.MessageJoin, protocol.CapDeltaUpdates, 0x00, 0x10, 0x00, 0x00}},
+		{"input_batch", batch},
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in.data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := bp.DecodeClientMessage(in.data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeGameState(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		players := testutil.Players(n, 8192, 8192)
+		b.Run(fmt.Sprintf("players=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(protocol.WorldStateSize(n)))
+			for i := 0; i < b.N; i++ {
+				bp.EncodeGameState(players, uint32(i))
+			}
+		})
+	}
+}
+
+// BenchmarkAppendGameState is the broadcast path: the buffer comes from the frame pool.
+func BenchmarkAppendGameState(b *testing.B) {
+	players := testutil.Players(10000, 8192, 8192)
+	buf := make([]byte, 0, protocol.WorldStateSize(len(players)))
+	b.ReportAllocs()
+	b.SetBytes(int64(cap(buf)))
+	for i := 0; i < b.N; i++ {
+		buf = bp.AppendGameState(buf[:0], players, uint32(i))
+	}
+}
@@ -0,0 +1,340 @@
+package protocol_test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// Golden wire-format tests: every encoder's output and every client message decode is
+// pinned in testdata/. A diff there is a protocol change — the client codec
+// (generated.ts, binaryProtocol.ts) and docs/protocol.md must change with it.
+
+var bp = &protocol.BinaryProtocol{}
+
+// samplePlayers covers every field of a player entry: facing, negative vectors,
+// attack state and the spawn-protection flag.
+var samplePlayers = []types.PlayerState{
+	{ID: 1001, X: 100, Y: 200, VX: 1, VY: 0, FacingRight: true, State: 0},
+	{ID: 1002, X: 65535, Y: 0, VX: -1, VY: -1, FacingRight: false, State: 1},
+	{ID: 42, X: 1234, Y: 4321, VX: 0, VY: 1, FacingRight: true, State: types.StateFlagSpawnProtected},
+}
+
+// fracBP sends positions in 1/16 units; fracPlayers have fractions of a unit, and 1002
+// sits past the largest wire position.
+var (
+	fracBP      = &protocol.BinaryProtocol{PositionBits: 4}
+	fracPlayers = []types.PlayerState{
+		{ID: 1001, X: 100, FracX: 0x80, Y: 200, FracY: 0x1F, VX: 1, FacingRight: true},
+		{ID: 1002, X: 4096, Y: 4095, FracY: 0xFF, VX: -1},
+	}
+)
+
+// originBP encodes a large world from the origin (90000, 4096) in 1/4 units; of
+// originPlayers, 1002 lies before the origin and 1003 past the window.
+var (
+	originBP      = &protocol.BinaryProtocol{PositionBits: 2, OriginX: 90000, OriginY: 4096}
+	originPlayers = []types.PlayerState{
+		{ID: 1001, X: 90100, FracX: 0x40, Y: 5000, VX: 1, FacingRight: true},
+		{ID: 1002, X: 89999, Y: 4096, FracY: 0xC0},
+		{ID: 1003, X: 90000 + 16384, Y: 4096 + 16383, FracY: 0xFF, VY: -1},
+	}
+)
+
+var sampleSalt = [protocol.SaltSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+func sampleSealer() cipher.AEAD {
+	aead, err := protocol.NewSealer([]byte("session key"), sampleSalt[:])
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func TestEncodeGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"game_state", bp.EncodeGameState(samplePlayers, 7)},
+		{"game_state_empty", bp.EncodeGameState(nil, 0xFFFFFFFF)},
+		{"game_state_append", bp.AppendGameState([]byte{0xAA, 0xBB}, samplePlayers[:1], 1)},
+		{"game_state_parts", bp.AppendGameStateParts(nil, 9, samplePlayers[:2], samplePlayers[2:])},
+		{"delta_game_state", bp.EncodeDeltaGameState(samplePlayers[1:], 8)},
+		{"player_joined", bp.EncodePlayerJoined(samplePlayers[2])},
+		{"player_left", bp.EncodePlayerLeft(1002)},
+		{"movement_ack", bp.EncodeMovementAck(1001, 300, 400, 123456)},
+		{"game_state_fractions", fracBP.EncodeGameState(fracPlayers, 7)},
+		{"config_fractions", fracBP.EncodeConfig(protocol.GameConfig{PlayerID: 1001, TickRate: 30, PlayerSpeedPerTick: 4, WorldWidth: 4000, WorldHeight: 3000})},
+		{"game_state_origin", originBP.EncodeGameState(originPlayers, 7)},
+		{"origin", originBP.EncodeOrigin()},
+		{"queue_status", bp.EncodeQueueStatus(3, 120, 45, protocol.QueueTierReturning)},
+		{"queue_admitted", bp.EncodeQueueAdmitted(61500)},
+		{"config_large", originBP.EncodeConfig(protocol.GameConfig{PlayerID: 1001, TickRate: 30, PlayerSpeedPerTick: 4, WorldWidth: 200000, WorldHeight: 70000})},
+		{"world_update_large", bp.EncodeWorldUpdate(200000, 70000)},
+		{"world_event_night", bp.EncodeWorldEvent(protocol.WorldEventNight, true, 0, 60000, "")},
+		{"world_event_storm_end", bp.EncodeWorldEvent(protocol.WorldEventStorm, false, 50, 0, "")},
+		{"world_event_announcement", bp.EncodeWorldEvent(protocol.WorldEventAnnouncement, true, 0, 5000, "Привет, world")},
+		{"announce_motd", bp.EncodeAnnounce(protocol.AnnounceMOTD, protocol.SeverityInfo, "Добро пожаловать")},
+		{"chat_message", bp.EncodeChatMessage(1001, "привет")},
+		{"command_result", bp.EncodeCommandResult(protocol.CommandDenied, "/kick needs role moderator")},
+		{"announce_critical", bp.EncodeAnnounce(protocol.AnnounceMessage, protocol.SeverityCritical, "Restart in 5 minutes")},
+		{"maintenance_scheduled", bp.EncodeMaintenance(protocol.MaintenanceScheduled, 30000)},
+		{"maintenance_over", bp.EncodeMaintenance(protocol.MaintenanceOver, 0)},
+		{"session_takeover", bp.EncodeSessionTakeover(1001)},
+		{"config", bp.EncodeConfig(protocol.GameConfig{PlayerID: 1001, TickRate: 30, PlayerSpeedPerTick: 4, WorldWidth: 6000, WorldHeight: 3000})},
+		{"config_seed", bp.EncodeConfig(protocol.GameConfig{PlayerID: 1001, TickRate: 30, PlayerSpeedPerTick: 4, WorldWidth: 6000, WorldHeight: 3000, WorldSeed: 42})},
+		{"minimap", bp.EncodeMinimap(4, 2, 1500, 1500, []uint8{0, 0, 3, 255, 1, 1, 1, 0})},
+		{"cipher_init", bp.EncodeCipherInit(sampleSalt, []uint8{protocol.MessageConfig, protocol.MessageViewportUpdate})},
+		{"sealed", protocol.AppendSealed(nil, sampleSealer(), protocol.MessageSealed, 1, bp.EncodeSessionTakeover(1001))},
+		{"world_update", bp.EncodeWorldUpdate(8000, 4000)},
+		{"cell_load", bp.AppendCellLoad(nil, 500, 4, samplePlayers)},
+		{"cell_unload", bp.EncodeCellUnload(500, []uint32{1<<16 | 3, 2<<16 | 11})},
+		{"reliable", bp.AppendReliable(nil, 7, bp.EncodePlayerLeft(1001))},
+		{"hit", bp.EncodeHit(1001, 1002, 20, 80, -21, 21)},
+		{"action_rejected", bp.EncodeActionRejected(protocol.MessageAttack, protocol.RejectCooldown, 12)},
+		{"leaderboard", bp.EncodeLeaderboard(1, []protocol.LeaderboardEntry{{PlayerID: 1003, Score: 420}, {PlayerID: 0, Score: 300}})},
+		{"party_invite", bp.EncodePartyInvite(1001)},
+		{"party_roster", bp.EncodePartyRoster(5, 1001, []uint32{1001, 1002})},
+		{"party_roster_empty", bp.EncodePartyRoster(0, 0, nil)},
+		{"party_chat_message", bp.EncodePartyChatMessage(1002, "за мной")},
+		{"friend_online", bp.EncodeFriendUpdate(protocol.FriendUpdate{Status: protocol.FriendOnline, PlayerID: 1002, ZoneRow: 1, ZoneCol: 3, Account: "bob"})},
+		{"inventory", bp.EncodeInventory([]protocol.ItemStack{{Item: 1, Count: 5}, {Item: 300, Count: 70000}})},
+		{"player_emote", bp.EncodePlayerEmote(1001, 3)},
+		{"inventory_empty", bp.EncodeInventory(nil)},
+		{"trade_state", bp.EncodeTradeState(3, protocol.TradeOpen, 1002, protocol.TradeConfirmedPartner,
+			[]protocol.TradeOfferEntry{{Item: 1, Count: 2}, {Partner: true, Item: 300, Count: 10}})},
+		{"friend_incoming", bp.EncodeFriendUpdate(protocol.FriendUpdate{Status: protocol.FriendIncoming, ZoneRow: protocol.ZoneNone, ZoneCol: protocol.ZoneNone, Account: "carol-7"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Golden(t, tt.name, tt.data)
+		})
+	}
+}
+
+func TestEncodeWorldEventTruncatesText(t *testing.T) {
+	data := bp.EncodeWorldEvent(protocol.WorldEventAnnouncement, true, 0, 0, strings.Repeat("x", protocol.MaxWorldEventText+10))
+	if want := protocol.LookupSchema(protocol.MessageWorldEvent).Size(protocol.MaxWorldEventText); len(data) != want {
+		t.Fatalf("len = %d, want %d", len(data), want)
+	}
+}
+
+func TestEncodeAnnounceTruncatesOnRune(t *testing.T) {
+	// 2-byte runes: the limit falls in the middle of one, which is dropped whole.
+	data := bp.EncodeAnnounce(protocol.AnnounceMessage, protocol.SeverityInfo, "x"+strings.Repeat("я", protocol.MaxAnnounceText))
+	text := data[protocol.LookupSchema(protocol.MessageAnnounce).Size(0):]
+	if len(text) != protocol.MaxAnnounceText-1 || !utf8.Valid(text) {
+		t.Fatalf("text is %d bytes (valid UTF-8: %v), want %d", len(text), utf8.Valid(text), protocol.MaxAnnounceText-1)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"яя", 3, "я"}, // the limit splits the second rune
+		{"я", 1, ""},
+		{"a\xffb", 10, "a\uFFFDb"},
+		{"\xff\xff", 4, "\uFFFD"},
+	} {
+		if got := protocol.TruncateText(tt.in, tt.max); got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("TruncateText(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestWorldStateSize(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		if got, want := protocol.WorldStateSize(n), len(bp.EncodeGameState(samplePlayers[:n], 1)); got != want {
+			t.Errorf("WorldStateSize(%d) = %d, encoded %d", n, got, want)
+		}
+	}
+	size := protocol.WorldStateSize(0) + 2*protocol.LookupSchema(protocol.MessageGameState).EntrySize()
+	if got := protocol.WorldStatePlayersPerMessage(size); got != 2 {
+		t.Errorf("WorldStatePlayersPerMessage(%d) = %d, want 2", size, got)
+	}
+	if got := protocol.WorldStatePlayersPerMessage(0); got != 1 {
+		t.Errorf("WorldStatePlayersPerMessage(0) = %d, want 1", got)
+	}
+}
+
+func TestPackMovementGolden(t *testing.T) {
+	var b strings.Builder
+	for dy := int8(-1); dy <= 1; dy++ {
+		for dx := int8(-1); dx <= 1; dx++ {
+			packed := protocol.PackMovement(dx, dy)
+			fmt.Fprintf(&b, "dx=%2d dy=%2d packed=0x%02x unpacked=%+v\n", dx, dy, packed, protocol.UnpackMovement(packed))
+		}
+	}
+	testutil.GoldenText(t, "pack_movement", b.String())
+}
+
+func TestDecodeClientMessageGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"join_legacy", []byte{protocol.MessageJoin}},
+		{"join_caps", []byte{protocol.MessageJoin, protocol.CapDeltaUpdates | protocol.CapCompression, 0x00, 0x10, 0x00, 0x00}},
+		{"join_caps_truncated", []byte{protocol.MessageJoin, 0x00, 0x00}},
+		{"move", []byte{protocol.MessageMove, protocol.PackMovement(1, -1), 0x39, 0x30, 0x00, 0x00}},
+		{"move_short", []byte{protocol.MessageMove, 0x05}},
+		{"move_analog", []byte{protocol.MessageMove, protocol.PackMovement(1, -1), 0x39, 0x30, 0x00, 0x00, 64, 0x81}},
+		{"move_analog_digital", []byte{protocol.MessageMove, protocol.PackMovement(1, -1), 0x39, 0x30, 0x00, 0x00, 0, 0}},
+		{"move_analog_mismatch", []byte{protocol.MessageMove, protocol.PackMovement(1, 0), 0x39, 0x30, 0x00, 0x00, 64, 1}},
+		{"move_analog_out_of_range", []byte{protocol.MessageMove, protocol.PackMovement(-1, 0), 0x39, 0x30, 0x00, 0x00, 0x80, 0}},
+		{"direction_right", []byte{protocol.MessageDirection, 1}},
+		{"direction_left", []byte{protocol.MessageDirection, 0xFF}},
+		{"attack", []byte{protocol.MessageAttack, 0x01, 0x02}},
+		{"attack_bare", []byte{protocol.MessageAttack}},
+		{"attack_legacy", []byte{protocol.MessageAttack, 0x00, 0x40, 0xFA, 0x43, 0x00, 0x40, 0x48, 0x43}},
+		{"attack_aim_out_of_range", []byte{protocol.MessageAttack, 0x80, 0x00}},
+		{"attack_end", []byte{protocol.MessageAttackEnd}},
+		{"viewport", []byte{protocol.MessageViewportUpdate, 0x80, 0x07, 0x38, 0x04}},
+		{"input_batch", []byte{protocol.MessageInputBatch, 0x0A, 0x00, 0x00, 0x00, 3, 0x00, 0x00, 0x00,
+			protocol.PackMovement(1, 0), protocol.PackMovement(0, 1), protocol.PackMovement(0, 0)}},
+		{"input_batch_empty", []byte{protocol.MessageInputBatch, 0x0A, 0x00, 0x00, 0x00, 0, 0x00, 0x00, 0x00}},
+		{"input_batch_short", []byte{protocol.MessageInputBatch, 0x0A, 0x00, 0x00, 0x00, 2, 0x00, 0x00, 0x00, 0x05}},
+		{"sequence_report", []byte{protocol.MessageSequenceReport,
+			0x64, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, protocol.SequenceReportResync}},
+		{"reliable_ack", []byte{protocol.MessageReliableAck, 0x07, 0x00, 0x00, 0x00}},
+		{"chat", append([]byte{protocol.MessageChat, 4, 0, 0, 0}, "/who"...)},
+		{"chat_short", append([]byte{protocol.MessageChat, 5, 0, 0, 0}, "/who"...)},
+		{"chat_too_long", []byte{protocol.MessageChat, 0x01, 0x01, 0x00, 0x00}},
+		{"chat_unsafe", append([]byte{protocol.MessageChat, 10, 0, 0, 0}, "hi\n\x1b[2J\xffя"...)},
+		{"party_invite", []byte{protocol.MessageParty, protocol.PartyInvite, 0xEA, 0x03, 0x00, 0x00}},
+		{"party_leave", []byte{protocol.MessageParty, protocol.PartyLeave, 0x00, 0x00, 0x00, 0x00}},
+		{"party_chat", append([]byte{protocol.MessagePartyChat, 2, 0, 0, 0}, "hi"...)},
+		{"emote", []byte{protocol.MessageEmote, 3}},
+		{"trade_offer", []byte{protocol.MessageTrade, protocol.TradeOffer, 0, 0, 0, 0, 0x2C, 0x01, 10, 0, 0, 0}},
+		{"friend_add", append([]byte{protocol.MessageFriend, protocol.FriendAdd, 5, 0, 0, 0}, "alice"...)},
+		{"empty", nil},
+		{"unknown_type", []byte{0xEE}},
+		{"server_message", []byte{protocol.MessageGameState, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := bp.DecodeClientMessage(tt.data)
+			var b strings.Builder
+			fmt.Fprintf(&b, "input: % x\n", tt.data)
+			if err != nil {
+				fmt.Fprintf(&b, "error: %v\n", err)
+			}
+			for _, m := range msgs {
+				b.WriteString(setFields(m) + "\n")
+			}
+			testutil.GoldenText(t, "decode_"+tt.name, b.String())
+		})
+	}
+}
+
+// setFields prints the fields of m its message type set — Type and every non-zero
+// field — so a field added for one message type leaves the goldens of the others alone.
+func setFields(m protocol.ClientMessage) string {
+	v := reflect.ValueOf(m)
+	parts := []string{fmt.Sprintf("Type:%d", m.Type)}
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if name := v.Type().Field(i).Name; name != "Type" && !f.IsZero() {
+			if f.Kind() == reflect.String {
+				parts = append(parts, fmt.Sprintf("%s:%q", name, f.String()))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s:%+v", name, f.Interface()))
+			}
+		}
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+func TestEncodeMinimapLongRun(t *testing.T) {
+	data := bp.EncodeMinimap(30, 20, 100, 100, make([]uint8, 600))
+	schema := protocol.LookupSchema(protocol.MessageMinimap)
+	if len(data) != schema.Size(3) {
+		t.Fatalf("len = %d, want 3 runs (%d bytes)", len(data), schema.Size(3))
+	}
+	runs := data[schema.Size(0):]
+	if runs[0] != 255 || runs[2] != 255 || runs[4] != 90 {
+		t.Errorf("runs = % x, want 255, 255, 90 cells", runs)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	msg := []byte{protocol.MessageMove, 0x05, 1, 0, 0, 0}
+	framed := protocol.AppendChecksummed(nil, msg)
+	if got, err := protocol.VerifyChecksum(framed); err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("VerifyChecksum = % x, %v; want % x", got, err, msg)
+	}
+	// CRC-32C check value (RFC 3720 B.4).
+	if got := protocol.Checksum([]byte("123456789")); got != 0xE3069283 {
+		t.Errorf("Checksum(123456789) = %#x, want 0xe3069283", got)
+	}
+	for _, data := range [][]byte{nil, framed[:3], append([]byte{framed[0] ^ 1}, framed[1:]...)} {
+		if _, err := protocol.VerifyChecksum(data); !errors.Is(err, protocol.ErrChecksum) {
+			t.Errorf("VerifyChecksum(% x) error = %v, want ErrChecksum", data, err)
+		}
+	}
+}
+
+func TestOpenSealed(t *testing.T) {
+	aead := sampleSealer()
+	msg := []byte{protocol.MessageViewportUpdate, 0x80, 0x07, 0x38, 0x04}
+	sealed := protocol.AppendSealed([]byte{0xAA}, aead, protocol.MessageSealedClient, 7, msg)[1:]
+	if counter, got, err := protocol.OpenSealed(aead, sealed); err != nil || counter != 7 || !bytes.Equal(got, msg) {
+		t.Fatalf("OpenSealed = %d, % x, %v; want 7, % x", counter, got, err, msg)
+	}
+
+	other, _ := protocol.NewSealer([]byte("other key"), sampleSalt[:])
+	flipped := func(i int) []byte {
+		b := bytes.Clone(sealed)
+		b[i] ^= 1
+		return b
+	}
+	down := bytes.Clone(sealed)
+	down[0] = protocol.MessageSealed // same bytes claimed for the other direction
+	for name, tc := range map[string]struct {
+		aead cipher.AEAD
+		data []byte
+	}{
+		"wrong key":  {other, sealed},
+		"counter":    {aead, flipped(1)},
+		"ciphertext": {aead, flipped(len(sealed) - 1)},
+		"direction":  {aead, down},
+		"truncated":  {aead, sealed[:len(sealed)-1]},
+		"not sealed": {aead, msg},
+	} {
+		if _, _, err := protocol.OpenSealed(tc.aead, tc.data); !errors.Is(err, protocol.ErrSealed) {
+			t.Errorf("%s: error = %v, want ErrSealed", name, err)
+		}
+	}
+}
+
+func TestOpenReliable(t *testing.T) {
+	msg := bp.EncodePlayerLeft(1001)
+	env := bp.AppendReliable([]byte{0xAA}, 7, msg)[1:]
+	if id, got, err := protocol.OpenReliable(env); err != nil || id != 7 || !bytes.Equal(got, msg) {
+		t.Fatalf("OpenReliable = %d, % x, %v; want 7, % x", id, got, err, msg)
+	}
+	for name, data := range map[string][]byte{
+		"truncated":    env[:len(env)-1],
+		"empty inner":  bp.AppendReliable(nil, 1, nil),
+		"not reliable": msg,
+	} {
+		if _, _, err := protocol.OpenReliable(data); !errors.Is(err, protocol.ErrReliable) {
+			t.Errorf("%s: error = %v, want ErrReliable", name, err)
+		}
+	}
+}
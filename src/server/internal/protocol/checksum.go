@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// Message checksums (JOIN capability CapChecksum).
+//
+// A client that sets CapChecksum gets a CRC-32C (Castagnoli) of every server → client
+// message right after the sequence: [seq u32][crc u32][message]. From then on its own
+// messages carry the same prefix: [crc u32][message]. The checksum covers the message
+// bytes only (not the sequence), so a shared broadcast frame is summed once for all
+// recipients. It catches corruption the transport does not: buggy proxies and our own
+// frame concatenation; WebSocket itself only has TCP's checksum.
+const ChecksumSize = 4
+
+// ErrChecksum — a checksummed message did not match its CRC.
+var ErrChecksum = errors.New("message checksum mismatch")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum returns the CRC-32C of msg.
+func Checksum(msg []byte) uint32 {
+	return crc32.Checksum(msg, castagnoli)
+}
+
+// AppendChecksummed appends [crc u32][msg] to dst.
+func AppendChecksummed(dst, msg []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, Checksum(msg))
+	return append(dst, msg...)
+}
+
+// VerifyChecksum checks a [crc u32][message] frame and returns the message.
+func VerifyChecksum(data []byte) ([]byte, error) {
+	if len(data) < ChecksumSize {
+		return nil, ErrChecksum
+	}
+	msg := data[ChecksumSize:]
+	if binary.LittleEndian.Uint32(data) != Checksum(msg) {
+		return nil, ErrChecksum
+	}
+	return msg, nil
+}
@@ -0,0 +1,37 @@
+package protocol
+
+import "errors"
+
+// Reliable messages (JOIN capability CapReliable).
+//
+// A critical server message travels inside a RELIABLE envelope [type][id u32][length
+// u32][message]; id is 1, 2, ... per connection and stays the same when the server
+// sends the message again. The client answers every copy with RELIABLE_ACK [type][id]
+// and handles each id once.
+
+// ErrReliable — a malformed RELIABLE envelope.
+var ErrReliable = errors.New("malformed reliable message")
+
+// AppendReliable appends msg wrapped in a RELIABLE envelope with id.
+func (bp *BinaryProtocol) AppendReliable(dst []byte, id uint32, msg []byte) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, schemaReliable.Size(0))...)
+	dst[start] = MessageReliable
+	values := [maxSchemaFields]uint32{id, uint32(len(msg))}
+	putFields(dst, start+1, schemaReliable.Fields, values[:])
+	return append(dst, msg...)
+}
+
+// OpenReliable returns the id and the inner message of a RELIABLE envelope.
+func OpenReliable(data []byte) (uint32, []byte, error) {
+	headerSize := schemaReliable.Size(0)
+	if len(data) < headerSize || data[0] != MessageReliable {
+		return 0, nil, ErrReliable
+	}
+	var values [maxSchemaFields]uint32
+	getFields(data, 1, schemaReliable.Fields, values[:])
+	if int(values[1]) != len(data)-headerSize || values[1] == 0 {
+		return 0, nil, ErrReliable
+	}
+	return values[0], data[headerSize:], nil
+}
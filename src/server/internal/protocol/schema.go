@@ -0,0 +1,833 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Declarative description of the wire format.
+// Single source of truth for cmd/protogen, which renders the protocol reference
+// (docs/protocol.md) and the TypeScript codec used by the client. When a message
+// changes, update this table first, then regenerate: `make protogen`.
+
+// FieldType — wire type of a single message field. All multi-byte values are little-endian.
+type FieldType uint8
+
+const (
+	FieldU8 FieldType = iota
+	FieldI8
+	FieldU16
+	FieldU32
+	// FieldMovement — packed movement vector: bits 0-1 = dx+1, bits 2-3 = dy+1 (see PackMovement).
+	FieldMovement
+	// FieldFlags — player flags: bit 7 = facingRight, bit 6 = spawn protection, bit 5 = safe zone,
+	// bits 0-4 = state.
+	FieldFlags
+	// FieldCount — uint32 number of Repeated entries that follow the fixed fields.
+	FieldCount
+	// FieldString — uint32 byte length, then that many bytes of UTF-8 text, at most
+	// Field.MaxLen. Only as the last field of a message without Repeated entries.
+	FieldString
+)
+
+// Width returns the encoded size of the field in bytes.
+func (t FieldType) Width() int {
+	switch t {
+	case FieldU16:
+		return 2
+	case FieldU32, FieldCount, FieldString:
+		return 4 // a string's length prefix; the text itself is not fixed-width
+	default:
+		return 1
+	}
+}
+
+// String returns the name used in the generated reference.
+func (t FieldType) String() string {
+	switch t {
+	case FieldU8:
+		return "u8"
+	case FieldI8:
+		return "i8"
+	case FieldU16:
+		return "u16"
+	case FieldU32:
+		return "u32"
+	case FieldMovement:
+		return "movement"
+	case FieldFlags:
+		return "flags"
+	case FieldCount:
+		return "count"
+	case FieldString:
+		return "string"
+	default:
+		return "unknown"
+	}
+}
+
+// Direction — who sends the message.
+type Direction uint8
+
+const (
+	ClientToServer Direction = iota
+	ServerToClient
+)
+
+// Field — one fixed-width field of a message.
+// Optional fields come last and may be absent (older clients send the shorter form).
+type Field struct {
+	Name     string
+	Type     FieldType
+	Doc      string
+	Optional bool
+	MaxLen   int // FieldString: most bytes of text
+}
+
+// MessageSchema describes one message: type byte, fixed fields in wire order and,
+// for list messages, the layout of each repeated entry (preceded by a FieldCount field).
+type MessageSchema struct {
+	Type         uint8
+	Name         string
+	Direction    Direction
+	Doc          string
+	Fields       []Field
+	Repeated     []Field
+	RepeatedName string // name of the decoded entry list, e.g. "players"
+
+	// Client → server only: bounds for MaxSize, enforced on the read path.
+	MaxEntries int // most repeated entries accepted
+	Trailing   int // extra bytes older clients append and the server ignores
+}
+
+// Size returns the encoded size for a message with n repeated entries, or n bytes of
+// text for a message that ends in a string, including the leading type byte.
+func (m *MessageSchema) Size(n int) int {
+	size := 1
+	for _, f := range m.Fields {
+		size += f.Type.Width()
+	}
+	if _, ok := m.Text(); ok {
+		return size + n
+	}
+	return size + n*m.EntrySize()
+}
+
+// Text returns the trailing string field of the message, if it has one.
+func (m *MessageSchema) Text() (Field, bool) {
+	if n := len(m.Fields); n > 0 && m.Fields[n-1].Type == FieldString {
+		return m.Fields[n-1], true
+	}
+	return Field{}, false
+}
+
+// MinSize returns the size of the shortest valid encoding: the type byte plus the
+// fields before the first optional one.
+func (m *MessageSchema) MinSize() int {
+	return 1 + fieldsWidth(m.RequiredFields())
+}
+
+// RequiredFields returns the leading non-optional fields.
+func (m *MessageSchema) RequiredFields() []Field {
+	for i, f := range m.Fields {
+		if f.Optional {
+			return m.Fields[:i]
+		}
+	}
+	return m.Fields
+}
+
+func fieldsWidth(fields []Field) int {
+	size := 0
+	for _, f := range fields {
+		size += f.Type.Width()
+	}
+	return size
+}
+
+// MaxSize returns the largest encoding of a client message the server accepts: every
+// field, MaxEntries entries (or the longest text) and the Trailing bytes (the checksum
+// trailer not included).
+func (m *MessageSchema) MaxSize() int {
+	if text, ok := m.Text(); ok {
+		return m.Size(text.MaxLen) + m.Trailing
+	}
+	return m.Size(m.MaxEntries) + m.Trailing
+}
+
+// EntrySize returns the encoded size of one repeated entry (0 for non-list messages).
+func (m *MessageSchema) EntrySize() int {
+	size := 0
+	for _, f := range m.Repeated {
+		size += f.Type.Width()
+	}
+	return size
+}
+
+// playerEntryFields — 11-byte player record shared by GAME_STATE, DELTA_GAME_STATE and PLAYER_JOINED.
+var playerEntryFields = []Field{
+	{Name: "id", Type: FieldU32},
+	{Name: "x", Type: FieldU16, Doc: "in 1/2^positionBits world units (CONFIG), from the ORIGIN point"},
+	{Name: "y", Type: FieldU16, Doc: "in 1/2^positionBits world units (CONFIG), from the ORIGIN point"},
+	{Name: "vx", Type: FieldI8, Doc: "-1, 0, 1"},
+	{Name: "vy", Type: FieldI8, Doc: "-1, 0, 1"},
+	{Name: "flags", Type: FieldFlags, Doc: "state: 0 idle, 1 attacking, 2 moving, 3 stunned, 4 dead; bit 5 = in a safe zone, bit 6 = spawn protection, bit 7 = facing right"},
+}
+
+// Messages — all messages of the protocol, in type order per direction.
+var Messages = []MessageSchema{
+	{
+		Type: MessageJoin, Name: "Join", Direction: ClientToServer,
+		Doc: "Handshake: must be the first message after the WebSocket upgrade. The server " +
+			"allocates the player and replies with GAME_STATE; anything else first closes the connection. " +
+			"A bare 1-byte JOIN (clients without capability flags) means capabilities = 0x01, no size limit.",
+		Fields: []Field{
+			{Name: "capabilities", Type: FieldU8, Optional: true,
+				Doc: "bit 0 = merges DELTA_GAME_STATE, bit 1 = wants permessage-deflate (if negotiated), bit 2 = subscribes to MINIMAP, " +
+					"bit 3 = CRC-32C checksums on every message (see Handshake), " +
+					"bit 4 = holds the session key, wants CIPHER_INIT and sealed messages, " +
+					"bit 5 = cell streaming (CELL_LOAD / CELL_UNLOAD instead of the whole world on join), " +
+					"bit 6 = acks critical messages sent in RELIABLE envelopes (see Reliable messages), " +
+					"bit 7 = subscribes to LEADERBOARD"},
+			{Name: "maxMessageSize", Type: FieldU32, Optional: true,
+				Doc: "largest world-state message accepted (bytes incl. sequence and checksum); 0 = unlimited"},
+		},
+	},
+	{
+		Type: MessageMove, Name: "Move", Direction: ClientToServer,
+		Doc: "Movement input. The server applies the vector every tick until the next MOVE: " +
+			"the velocity of each axis approaches axis/127 × the player speed at the configured acceleration and friction. " +
+			"Without the analog axes the input is digital, a full axis in the packed direction.",
+		Fields: []Field{
+			{Name: "movement", Type: FieldMovement},
+			{Name: "inputSequence", Type: FieldU32, Doc: "client input sequence, echoed in MOVEMENT_ACK"},
+			{Name: "axisX", Type: FieldI8, Optional: true,
+				Doc: "analog x axis, -127..127 with the sign of the packed dx; 0 = full. Anything else is a protocol error"},
+			{Name: "axisY", Type: FieldI8, Optional: true, Doc: "analog y axis, likewise"},
+		},
+	},
+	{
+		Type: MessageDirection, Name: "Direction", Direction: ClientToServer,
+		Doc: "Facing change.",
+		Fields: []Field{
+			{Name: "direction", Type: FieldI8, Doc: "1 = right, anything else = left"},
+		},
+	},
+	{
+		Type: MessageAttack, Name: "Attack", Direction: ClientToServer,
+		Doc: "Attack request; the server uses its own position and resolves the hits. " +
+			"Without an aim the attack hits in front of the attacker. With one it hits within ATTACK_RANGE " +
+			"and ATTACK_ARC/2 degrees of the aim, and the attacker turns towards it. " +
+			"The 9-byte form of older clients (x, y as float32) carries no aim.",
+		Fields: []Field{
+			{Name: "aimX", Type: FieldI8, Optional: true,
+				Doc: "aim direction in world axes, -127..127; only its direction counts, 0, 0 = no aim. -128 is a protocol error"},
+			{Name: "aimY", Type: FieldI8, Optional: true, Doc: "likewise, y grows downwards"},
+		},
+		Trailing: 6, // the 9-byte form of older clients
+	},
+	{
+		Type: MessageAttackEnd, Name: "AttackEnd", Direction: ClientToServer,
+		Doc: "Ignored: attack duration is server-authoritative.",
+	},
+	{
+		Type: MessageViewportUpdate, Name: "ViewportUpdate", Direction: ClientToServer,
+		Doc: "Client viewport size in world units. The server centres it on the player " +
+			"(plus a margin) and only sends world state inside it. The size is capped at " +
+			"MAX_VIEWPORT_WIDTH×MAX_VIEWPORT_HEIGHT and rounded up to whole visibility cells; " +
+			"updates over VIEWPORT_RATE_LIMIT per second are deferred and only the latest applies.",
+		Fields: []Field{
+			{Name: "width", Type: FieldU16},
+			{Name: "height", Type: FieldU16},
+		},
+	},
+	{
+		Type: MessageInputBatch, Name: "InputBatch", Direction: ClientToServer,
+		Doc: "Several MOVE inputs sent in one frame (e.g. accumulated during a frame hiccup). " +
+			"Entry i gets input sequence baseSequence+i; at most 32 entries.",
+		Fields: []Field{
+			{Name: "baseSequence", Type: FieldU32, Doc: "input sequence of the first entry"},
+			{Name: "inputCount", Type: FieldCount},
+		},
+		Repeated:     []Field{{Name: "movement", Type: FieldMovement}},
+		RepeatedName: "inputs",
+		MaxEntries:   MaxInputBatch,
+	},
+	{
+		Type: MessageSequenceReport, Name: "SequenceReport", Direction: ClientToServer,
+		Doc: "Periodic report of server → client sequence gaps seen by the client. With the resync " +
+			"flag set the server answers with a full GAME_STATE (rate-limited per connection).",
+		Fields: []Field{
+			{Name: "lastSequence", Type: FieldU32, Doc: "highest outbound sequence received"},
+			{Name: "missed", Type: FieldU32, Doc: "messages missing since the previous report"},
+			{Name: "flags", Type: FieldU8, Doc: "bit 0 = resync requested"},
+			{Name: "corrupted", Type: FieldU32, Optional: true,
+				Doc: "messages dropped for a bad checksum since the previous report (checksum clients)"},
+		},
+	},
+	{
+		Type: MessageSealedClient, Name: "SealedClient", Direction: ClientToServer,
+		Doc: "Encrypted envelope around a client message of a type listed in CIPHER_INIT " +
+			"(see Sealed messages). The server drops those types when they arrive unsealed.",
+		Fields: []Field{
+			{Name: "counter", Type: FieldU32, Doc: "1, 2, ... per connection; must grow"},
+			{Name: "length", Type: FieldCount, Doc: "ciphertext bytes, GCM tag included"},
+		},
+		Repeated:     []Field{{Name: "byte", Type: FieldU8}},
+		RepeatedName: "ciphertext",
+		// MaxEntries: the largest other client message plus the GCM tag, set in init.
+	},
+	{
+		Type: MessageReliableAck, Name: "ReliableAck", Direction: ClientToServer,
+		Doc: "Acknowledges a RELIABLE message; sent for every copy received, retransmissions included.",
+		Fields: []Field{
+			{Name: "id", Type: FieldU32, Doc: "id of the RELIABLE message"},
+		},
+	},
+	{
+		Type: MessageChat, Name: "Chat", Direction: ClientToServer,
+		Doc: "Chat line. The server relays it to everyone as CHAT_MESSAGE, unless it starts with /: " +
+			"then it is a console command (/help lists them) answered with COMMAND_RESULT.",
+		Fields: []Field{
+			{Name: "text", Type: FieldString, MaxLen: MaxChatText},
+		},
+	},
+	{
+		Type: MessageParty, Name: "Party", Direction: ClientToServer,
+		Doc: "Party membership. A player not in a party, or the leader of one, invites a player not in a party; the " +
+			"invitee accepts within PARTY_INVITE_TTL_SEC and every member gets PARTY_ROSTER. A refused action is " +
+			"answered with COMMAND_RESULT.",
+		Fields: []Field{
+			{Name: "action", Type: FieldU8, Doc: "0 = invite, 1 = accept, 2 = leave"},
+			{Name: "playerId", Type: FieldU32, Doc: "invite: the invitee; accept: the inviter; leave: ignored"},
+		},
+	},
+	{
+		Type: MessagePartyChat, Name: "PartyChat", Direction: ClientToServer,
+		Doc: "Chat line to the sender's party, relayed to its members as PARTY_CHAT_MESSAGE.",
+		Fields: []Field{
+			{Name: "text", Type: FieldString, MaxLen: MaxChatText},
+		},
+	},
+	{
+		Type: MessageFriend, Name: "Friend", Direction: ClientToServer,
+		Doc: "Friend list change of a signed-in player. Adding an account asks it to be friends, or accepts its " +
+			"request; removing ends the friendship or declines / withdraws a request. Both sides get FRIEND_UPDATE; " +
+			"a refused action is answered with COMMAND_RESULT.",
+		Fields: []Field{
+			{Name: "action", Type: FieldU8, Doc: "0 = add / accept, 1 = remove / decline"},
+			{Name: "account", Type: FieldString, MaxLen: MaxAccountText},
+		},
+	},
+	{
+		Type: MessageTrade, Name: "Trade", Direction: ClientToServer,
+		Doc: "Trade between two signed-in players, mediated by the server. One asks (request), the other accepts; " +
+			"each side then offers items of its inventory and confirms. Changing an offer withdraws both " +
+			"confirmations; once both confirmed the same offers the server swaps them in one step, or cancels " +
+			"the trade if a side no longer has its offer. Both get TRADE_STATE after every change; a refused " +
+			"action is answered with COMMAND_RESULT.",
+		Fields: []Field{
+			{Name: "action", Type: FieldU8, Doc: "0 = request, 1 = accept, 2 = offer, 3 = confirm, 4 = cancel / decline"},
+			{Name: "playerId", Type: FieldU32, Doc: "request: the partner; accept / decline: the requester; otherwise ignored"},
+			{Name: "item", Type: FieldU16, Doc: "offer: the item"},
+			{Name: "count", Type: FieldU32, Doc: "offer: how many; 0 withdraws the item"},
+		},
+	},
+	{
+		Type: MessageEmote, Name: "Emote", Direction: ClientToServer,
+		Doc: "Plays an emote of the \"emotes\" catalog of gameConfig.json. The server sends PLAYER_EMOTE to the " +
+			"players within EMOTE_RADIUS, the client included; an emote missing from the catalog, or sent before " +
+			"the cooldown of the previous one ran out, is answered with ACTION_REJECTED. Emotes have no effect " +
+			"on the game.",
+		Fields: []Field{
+			{Name: "emoteId", Type: FieldU8, Doc: "id of a catalog entry"},
+		},
+	},
+	{
+		Type: MessageGameState, Name: "GameState", Direction: ServerToClient,
+		Doc: "Full world state (initial state and periodic full sync).",
+		Fields: []Field{
+			{Name: "stateSequence", Type: FieldU32, Doc: "monotonic world-state sequence"},
+			{Name: "playerCount", Type: FieldCount},
+		},
+		Repeated:     playerEntryFields,
+		RepeatedName: "players",
+	},
+	{
+		Type: MessageMovementAck, Name: "MovementAck", Direction: ServerToClient,
+		Doc: "Authoritative position for the given input sequence.",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32},
+			{Name: "x", Type: FieldU16, Doc: "in 1/2^positionBits world units (CONFIG), from the ORIGIN point"},
+			{Name: "y", Type: FieldU16, Doc: "in 1/2^positionBits world units (CONFIG), from the ORIGIN point"},
+			{Name: "inputSequence", Type: FieldU32},
+		},
+	},
+	{
+		Type: MessagePlayerJoined, Name: "PlayerJoined", Direction: ServerToClient,
+		Doc:    "A player entered the world.",
+		Fields: playerEntryFields,
+	},
+	{
+		Type: MessagePlayerLeft, Name: "PlayerLeft", Direction: ServerToClient,
+		Doc: "A player left the world.",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32},
+		},
+	},
+	{
+		Type: MessageDeltaGameState, Name: "DeltaGameState", Direction: ServerToClient,
+		Doc: "Only players whose state changed since the previous tick; merged into client state.",
+		Fields: []Field{
+			{Name: "stateSequence", Type: FieldU32, Doc: "monotonic world-state sequence"},
+			{Name: "playerCount", Type: FieldCount},
+		},
+		Repeated:     playerEntryFields,
+		RepeatedName: "players",
+	},
+	{
+		Type: MessageWorldEvent, Name: "WorldEvent", Direction: ServerToClient,
+		Doc: "Global world event from the schedule (also sent to newcomers for events still active). " +
+			"kind: 1 = night (inactive = day), 2 = storm, 3 = announcement.",
+		Fields: []Field{
+			{Name: "kind", Type: FieldU8},
+			{Name: "active", Type: FieldU8, Doc: "1 = started, 0 = ended"},
+			{Name: "value", Type: FieldU16, Doc: "storm: movement speed percent"},
+			{Name: "durationMs", Type: FieldU32, Doc: "time left until the event ends; 0 = instant"},
+			{Name: "text", Type: FieldString, MaxLen: MaxWorldEventText, Doc: "announcement only"},
+		},
+	},
+	{
+		Type: MessageMaintenance, Name: "Maintenance", Direction: ServerToClient,
+		Doc: "Maintenance mode. While active the server rejects new connections; " +
+			"after the countdown the simulation pauses until maintenance is over.",
+		Fields: []Field{
+			{Name: "phase", Type: FieldU8, Doc: "0 = over, 1 = scheduled, 2 = paused"},
+			{Name: "countdownMs", Type: FieldU32, Doc: "phase 1: time until the pause"},
+		},
+	},
+	{
+		Type: MessageSessionTakeover, Name: "SessionTakeover", Direction: ServerToClient,
+		Doc: "The same account connected again and took over this session's player. " +
+			"Sent right before the close frame with DUPLICATE_SESSION; the client should not reconnect.",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32, Doc: "the player now controlled by the new connection"},
+		},
+	},
+	{
+		Type: MessageConfig, Name: "Config", Direction: ServerToClient,
+		Doc: "Authoritative gameplay constants, sent once after JOIN ahead of the first GAME_STATE. " +
+			"The client uses these instead of its bundled gameConfig.json, which is only a fallback for older servers.",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32, Doc: "the client's own player"},
+			{Name: "tickRate", Type: FieldU8, Doc: "simulation ticks per second"},
+			{Name: "playerSpeedPerTick", Type: FieldU16, Doc: "world units a player moves per tick along each axis"},
+			{Name: "worldWidth", Type: FieldU16},
+			{Name: "worldHeight", Type: FieldU16},
+			{Name: "boundaryMode", Type: FieldU8, Doc: "0 = clamp to [0, worldWidth] × [0, worldHeight]"},
+			{Name: "acceleration", Type: FieldU16, Optional: true,
+				Doc: "world units/s² a player speeds up by towards its input speed; 0 = reaches it at once"},
+			{Name: "friction", Type: FieldU16, Optional: true,
+				Doc: "world units/s² a player slows down by towards a lower input speed or a stop; 0 = at once"},
+			{Name: "positionBits", Type: FieldU8, Optional: true,
+				Doc: "positions in player entries and MOVEMENT_ACK are in 1/2^positionBits world units (0-8); absent = whole units"},
+			{Name: "worldWidthHigh", Type: FieldU8, Optional: true,
+				Doc: "bits 16-23 of worldWidth; a world of 65536 / 2^positionBits units or more a side sends ORIGIN"},
+			{Name: "worldHeightHigh", Type: FieldU8, Optional: true, Doc: "bits 16-23 of worldHeight"},
+			{Name: "worldSeed", Type: FieldU32, Optional: true,
+				Doc: "the world layout was generated from this seed (WORLD_SEED), for decoration matching it; 0 = not generated"},
+		},
+	},
+	{
+		Type: MessageMinimap, Name: "Minimap", Direction: ServerToClient,
+		Doc: "Coarse player density grid for a minimap, about once a second, only to clients that set " +
+			"capability bit 2 in JOIN. Cells are row-major from the top-left corner and run-length encoded: " +
+			"each run repeats count for run consecutive cells. Counts saturate at 255.",
+		Fields: []Field{
+			{Name: "cols", Type: FieldU8},
+			{Name: "rows", Type: FieldU8},
+			{Name: "cellWidth", Type: FieldU16, Doc: "world units per cell (the last column may be narrower)"},
+			{Name: "cellHeight", Type: FieldU16},
+			{Name: "runCount", Type: FieldCount},
+		},
+		Repeated: []Field{
+			{Name: "run", Type: FieldU8, Doc: "cells in this run, 1..255"},
+			{Name: "count", Type: FieldU8, Doc: "players in each of those cells"},
+		},
+		RepeatedName: "runs",
+	},
+	{
+		Type: MessageCipherInit, Name: "CipherInit", Direction: ServerToClient,
+		Doc: "Sent right after JOIN to clients that set capability bit 4 and connected with a session token. " +
+			"Carries the salt of the connection key and the message types that travel sealed from now on, " +
+			"in both directions (see Sealed messages).",
+		Fields: []Field{
+			{Name: "salt0", Type: FieldU32, Doc: "salt bytes 0-3; the four salt fields are the 16 salt bytes in wire order"},
+			{Name: "salt1", Type: FieldU32},
+			{Name: "salt2", Type: FieldU32},
+			{Name: "salt3", Type: FieldU32},
+			{Name: "typeCount", Type: FieldCount},
+		},
+		Repeated:     []Field{{Name: "type", Type: FieldU8}},
+		RepeatedName: "types",
+	},
+	{
+		Type: MessageSealed, Name: "Sealed", Direction: ServerToClient,
+		Doc: "Encrypted envelope around a server message of a type listed in CIPHER_INIT (see Sealed messages).",
+		Fields: []Field{
+			{Name: "counter", Type: FieldU32, Doc: "1, 2, ... per connection; must grow"},
+			{Name: "length", Type: FieldCount, Doc: "ciphertext bytes, GCM tag included"},
+		},
+		Repeated:     []Field{{Name: "byte", Type: FieldU8}},
+		RepeatedName: "ciphertext",
+	},
+	{
+		Type: MessageWorldUpdate, Name: "WorldUpdate", Direction: ServerToClient,
+		Doc: "The world was resized at runtime; replaces worldWidth and worldHeight of CONFIG. " +
+			"Players left outside the new bounds have already been moved inside and get a MOVEMENT_ACK correction.",
+		Fields: []Field{
+			{Name: "worldWidth", Type: FieldU16},
+			{Name: "worldHeight", Type: FieldU16},
+			{Name: "boundaryMode", Type: FieldU8, Doc: "as in CONFIG"},
+			{Name: "worldWidthHigh", Type: FieldU8, Optional: true, Doc: "as in CONFIG"},
+			{Name: "worldHeightHigh", Type: FieldU8, Optional: true, Doc: "as in CONFIG"},
+		},
+	},
+	{
+		Type: MessageCellLoad, Name: "CellLoad", Direction: ServerToClient,
+		Doc: "Cell streaming (capability bit 5): the players inside the cells that entered the client's viewport " +
+			"this tick, to merge into its state. Many players may come in several CELL_LOADs.",
+		Fields: []Field{
+			{Name: "cellSize", Type: FieldU16, Doc: "world units per cell side"},
+			{Name: "cells", Type: FieldU16, Doc: "cells loaded by this message (0 in the continuation of a split one)"},
+			{Name: "playerCount", Type: FieldCount},
+		},
+		Repeated:     playerEntryFields,
+		RepeatedName: "players",
+	},
+	{
+		Type: MessageCellUnload, Name: "CellUnload", Direction: ServerToClient,
+		Doc: "Cell streaming: loaded cells that left the viewport; the client drops the other players inside them. " +
+			"Cell (x, y) covers [x·cellSize, (x+1)·cellSize) × [y·cellSize, (y+1)·cellSize).",
+		Fields: []Field{
+			{Name: "cellSize", Type: FieldU16},
+			{Name: "cellCount", Type: FieldCount},
+		},
+		Repeated: []Field{
+			{Name: "cellX", Type: FieldU16},
+			{Name: "cellY", Type: FieldU16},
+		},
+		RepeatedName: "cells",
+	},
+	{
+		Type: MessageReliable, Name: "Reliable", Direction: ServerToClient,
+		Doc: "Envelope around a critical message (PLAYER_JOINED, PLAYER_LEFT, movement corrections) for clients " +
+			"that set capability bit 6. The client answers RELIABLE_ACK with the id and handles each id once: " +
+			"unacked messages are sent again with backoff (see Reliable messages).",
+		Fields: []Field{
+			{Name: "id", Type: FieldU32, Doc: "1, 2, ... per connection; a retransmission keeps its id"},
+			{Name: "length", Type: FieldCount, Doc: "bytes of the inner message"},
+		},
+		Repeated:     []Field{{Name: "byte", Type: FieldU8}},
+		RepeatedName: "message",
+	},
+	{
+		Type: MessageAnnounce, Name: "Announce", Direction: ServerToClient,
+		Doc: "Message of the day (right after JOIN) or an announcement from the admin API, to everyone, " +
+			"one zone or one player. The text is already in the client's language when the server has it " +
+			"(see the lang query parameter in Handshake).",
+		Fields: []Field{
+			{Name: "kind", Type: FieldU8, Doc: "0 = announcement, 1 = message of the day"},
+			{Name: "severity", Type: FieldU8, Doc: "0 = info, 1 = warning, 2 = critical"},
+			{Name: "text", Type: FieldString, MaxLen: MaxAnnounceText},
+		},
+	},
+	{
+		Type: MessageChatMessage, Name: "ChatMessage", Direction: ServerToClient,
+		Doc: "Chat line of a player, relayed to everyone (the sender included).",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32},
+			{Name: "text", Type: FieldString, MaxLen: MaxChatText},
+		},
+	},
+	{
+		Type: MessageCommandResult, Name: "CommandResult", Direction: ServerToClient,
+		Doc: "Answer to a console command sent in CHAT, to the issuing client only; also refuses chat lines of a muted player.",
+		Fields: []Field{
+			{Name: "status", Type: FieldU8, Doc: "0 = ok, 1 = error, 2 = permission denied, 3 = unknown command, 4 = muted"},
+			{Name: "text", Type: FieldString, MaxLen: MaxCommandResultText},
+		},
+	},
+	{
+		Type: MessageHit, Name: "Hit", Direction: ServerToClient,
+		Doc: "An attack landed, decided by the server: sent to the attacker as confirmation and to the victim to apply, " +
+			"ahead of the next state frame (RELIABLE for capability bit 6). The victim's position already includes the " +
+			"knockback; the victim also gets a MOVEMENT_ACK correction to it.",
+		Fields: []Field{
+			{Name: "attackerId", Type: FieldU32},
+			{Name: "victimId", Type: FieldU32},
+			{Name: "damage", Type: FieldU16},
+			{Name: "hp", Type: FieldU16, Doc: "victim's health after the hit; 0 = killed, respawns in place with full health"},
+			{Name: "knockbackX", Type: FieldI8, Doc: "how far the hit pushed the victim, world units"},
+			{Name: "knockbackY", Type: FieldI8},
+		},
+	},
+	{
+		Type: MessageActionRejected, Name: "ActionRejected", Direction: ServerToClient,
+		Doc: "The server refused an input, so the client can roll back what it showed optimistically. " +
+			"Sent for ATTACK (cooldown, stunned, dead, frozen, safe zone) and for any message dropped by the rate limit. " +
+			"MOVE is never rejected this way: MOVEMENT_ACK already carries the authoritative position.",
+		Fields: []Field{
+			{Name: "action", Type: FieldU8, Doc: "message type of the refused input, e.g. 5 = ATTACK"},
+			{Name: "reason", Type: FieldU8, Doc: "1 = cooldown, 2 = stunned, 3 = dead, 4 = frozen, 5 = rate limited, 6 = unknown (e.g. an emote missing from the catalog), 7 = safe zone"},
+			{Name: "retryAfterTicks", Type: FieldU16, Doc: "ticks until the input can succeed; 0 = unknown"},
+		},
+	},
+	{
+		Type: MessageLeaderboard, Name: "Leaderboard", Direction: ServerToClient,
+		Doc: "Top players of one metric, best first, every LEADERBOARD_INTERVAL_MS per metric, only to clients that set " +
+			"capability bit 7 in JOIN. Scores span every server sharing the leaderboard store; deeper ranks, " +
+			"account IDs and a player's own neighbourhood are at GET /leaderboard.",
+		Fields: []Field{
+			{Name: "metric", Type: FieldU8, Doc: "0 = kills, 1 = damage dealt"},
+			{Name: "entryCount", Type: FieldCount},
+		},
+		Repeated: []Field{
+			{Name: "playerId", Type: FieldU32, Doc: "0 = not online on this server"},
+			{Name: "score", Type: FieldU32},
+		},
+		RepeatedName: "entries",
+	},
+	{
+		Type: MessagePartyInvite, Name: "PartyInvite", Direction: ServerToClient,
+		Doc: "Another player invites the client to its party; answer with PARTY accept.",
+		Fields: []Field{
+			{Name: "inviterId", Type: FieldU32},
+		},
+	},
+	{
+		Type: MessagePartyRoster, Name: "PartyRoster", Direction: ServerToClient,
+		Doc: "The client's party after every change (RELIABLE for capability bit 6), and again after a session " +
+			"takeover. No members = not in a party (left, or the party broke up). With PARTY_ALWAYS_VISIBLE the " +
+			"members are in the world state whatever the viewport.",
+		Fields: []Field{
+			{Name: "partyId", Type: FieldU32, Doc: "0 = not in a party"},
+			{Name: "leaderId", Type: FieldU32},
+			{Name: "memberCount", Type: FieldCount},
+		},
+		Repeated:     []Field{{Name: "playerId", Type: FieldU32}},
+		RepeatedName: "members",
+	},
+	{
+		Type: MessagePartyChatMessage, Name: "PartyChatMessage", Direction: ServerToClient,
+		Doc: "Party chat line of a member, to every member (the sender included).",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32},
+			{Name: "text", Type: FieldString, MaxLen: MaxChatText},
+		},
+	},
+	{
+		Type: MessageInventory, Name: "Inventory", Direction: ServerToClient,
+		Doc: "The client's items, by item number, after JOIN and after every change (RELIABLE for capability bit " +
+			"6, latest wins). Signed-in players only.",
+		Fields: []Field{
+			{Name: "itemCount", Type: FieldCount},
+		},
+		Repeated: []Field{
+			{Name: "item", Type: FieldU16},
+			{Name: "count", Type: FieldU32},
+		},
+		RepeatedName: "items",
+	},
+	{
+		Type: MessageTradeState, Name: "TradeState", Direction: ServerToClient,
+		Doc: "The client's trade after every change: a request to answer, the offers of both sides while open, " +
+			"then completed or cancelled.",
+		Fields: []Field{
+			{Name: "tradeId", Type: FieldU32, Doc: "0 while requested"},
+			{Name: "status", Type: FieldU8, Doc: "0 = requested by the partner, 1 = open, 2 = completed, 3 = cancelled"},
+			{Name: "partnerId", Type: FieldU32},
+			{Name: "confirmed", Type: FieldU8, Doc: "bit 0 = the client confirmed, bit 1 = the partner confirmed"},
+			{Name: "offerCount", Type: FieldCount},
+		},
+		Repeated: []Field{
+			{Name: "side", Type: FieldU8, Doc: "0 = offered by the client, 1 = by the partner"},
+			{Name: "item", Type: FieldU16},
+			{Name: "count", Type: FieldU32},
+		},
+		RepeatedName: "offers",
+	},
+	{
+		Type: MessagePlayerEmote, Name: "PlayerEmote", Direction: ServerToClient,
+		Doc: "A player within EMOTE_RADIUS of the client, or the client itself, plays an emote. Not RELIABLE: " +
+			"a late emote is not worth a retransmission.",
+		Fields: []Field{
+			{Name: "playerId", Type: FieldU32},
+			{Name: "emoteId", Type: FieldU8},
+		},
+	},
+	{
+		Type: MessageOrigin, Name: "Origin", Direction: ServerToClient,
+		Doc: "Large worlds only (a side of 65536 / 2^positionBits world units or more, see CONFIG): the point that " +
+			"positions in all later messages are relative to, world x = originX + x / 2^positionBits. Sent after CONFIG, " +
+			"ahead of the first GAME_STATE, and again whenever the client's player leaves the middle half of the window " +
+			"the wire positions cover. Messages encoded for the old origin all arrive before it. Without one the origin is (0, 0).",
+		Fields: []Field{
+			{Name: "originX", Type: FieldU32, Doc: "world units"},
+			{Name: "originY", Type: FieldU32, Doc: "world units"},
+		},
+	},
+	{
+		Type: MessageQueueStatus, Name: "QueueStatus", Direction: ServerToClient,
+		Doc: "The server is full and holds the client's JOIN in the login queue (LOGIN_QUEUE_SIZE). Sent right after " +
+			"JOIN and then every LOGIN_QUEUE_UPDATE_MS until QUEUE_ADMITTED. The client should keep the connection " +
+			"open and send nothing; messages other than JOIN are ignored while queued.",
+		Fields: []Field{
+			{Name: "position", Type: FieldU32, Doc: "1 = next to join"},
+			{Name: "length", Type: FieldU32, Doc: "clients in the queue"},
+			{Name: "waitSec", Type: FieldU32, Doc: "estimated wait from the rate players left lately; 0 = no estimate yet"},
+			{Name: "tier", Type: FieldU8, Doc: "0 = standard, 1 = returning (played here recently), 2 = vip; higher tiers are admitted first"},
+		},
+	},
+	{
+		Type: MessageQueueAdmitted, Name: "QueueAdmitted", Direction: ServerToClient,
+		Doc: "A slot freed up: the queued JOIN goes ahead. CONFIG and the initial state follow as after any JOIN.",
+		Fields: []Field{
+			{Name: "waitedMs", Type: FieldU32, Doc: "time spent in the queue"},
+		},
+	},
+	{
+		Type: MessageFriendUpdate, Name: "FriendUpdate", Direction: ServerToClient,
+		Doc: "One entry of the client's friend list: all of them after JOIN, then on every change and when a " +
+			"friend comes online, goes offline or moves to another zone (FRIEND_PRESENCE_INTERVAL_MS). Presence " +
+			"covers the players of this server.",
+		Fields: []Field{
+			{Name: "status", Type: FieldU8, Doc: "0 = friend offline, 1 = friend online, 2 = incoming request, 3 = outgoing request, 4 = removed"},
+			{Name: "playerId", Type: FieldU32, Doc: "the friend's player while online; 0 otherwise"},
+			{Name: "zoneRow", Type: FieldU8, Doc: "zone of an online friend (ZONE_ROWS × ZONE_COLS grid); 255 = unknown"},
+			{Name: "zoneCol", Type: FieldU8, Doc: "255 = unknown"},
+			{Name: "account", Type: FieldString, MaxLen: MaxAccountText},
+		},
+	},
+}
+
+// schemaByType — O(1) lookup table built from Messages at init.
+var schemaByType [256]*MessageSchema
+
+// Schemas used directly by the encoders in binary.go.
+var (
+	schemaGameState        *MessageSchema
+	schemaDeltaGameState   *MessageSchema
+	schemaPlayerJoined     *MessageSchema
+	schemaPlayerLeft       *MessageSchema
+	schemaMovementAck      *MessageSchema
+	schemaWorldEvent       *MessageSchema
+	schemaMaintenance      *MessageSchema
+	schemaSessionTakeover  *MessageSchema
+	schemaConfig           *MessageSchema
+	schemaMinimap          *MessageSchema
+	schemaCipherInit       *MessageSchema
+	schemaSealed           *MessageSchema
+	schemaSealedClient     *MessageSchema
+	schemaWorldUpdate      *MessageSchema
+	schemaCellLoad         *MessageSchema
+	schemaCellUnload       *MessageSchema
+	schemaReliable         *MessageSchema
+	schemaAnnounce         *MessageSchema
+	schemaChatMessage      *MessageSchema
+	schemaCommandResult    *MessageSchema
+	schemaHit              *MessageSchema
+	schemaActionRejected   *MessageSchema
+	schemaLeaderboard      *MessageSchema
+	schemaPartyInvite      *MessageSchema
+	schemaPartyRoster      *MessageSchema
+	schemaPartyChatMessage *MessageSchema
+	schemaFriendUpdate     *MessageSchema
+	schemaInventory        *MessageSchema
+	schemaTradeState       *MessageSchema
+	schemaPlayerEmote      *MessageSchema
+	schemaOrigin           *MessageSchema
+	schemaQueueStatus      *MessageSchema
+	schemaQueueAdmitted    *MessageSchema
+)
+
+func init() {
+	for i := range Messages {
+		m := &Messages[i]
+		if schemaByType[m.Type] != nil {
+			panic(fmt.Sprintf("protocol: duplicate message type %d (%s)", m.Type, m.Name))
+		}
+		if len(m.Fields) > maxSchemaFields || len(m.Repeated) > maxSchemaFields {
+			panic(fmt.Sprintf("protocol: message %s exceeds %d fields", m.Name, maxSchemaFields))
+		}
+		for j, f := range m.Fields {
+			if f.Type == FieldString && (j != len(m.Fields)-1 || len(m.Repeated) > 0 || f.Optional || f.MaxLen <= 0) {
+				panic(fmt.Sprintf("protocol: string %s.%s must be the last, required field with a MaxLen", m.Name, f.Name))
+			}
+		}
+		schemaByType[m.Type] = m
+	}
+
+	sealed := schemaByType[MessageSealedClient]
+	for i := range Messages {
+		if m := &Messages[i]; m.Direction == ClientToServer && m != sealed {
+			sealed.MaxEntries = max(sealed.MaxEntries, m.MaxSize()+SealOverhead)
+		}
+	}
+
+	schemaGameState = schemaByType[MessageGameState]
+	schemaDeltaGameState = schemaByType[MessageDeltaGameState]
+	schemaPlayerJoined = schemaByType[MessagePlayerJoined]
+	schemaPlayerLeft = schemaByType[MessagePlayerLeft]
+	schemaMovementAck = schemaByType[MessageMovementAck]
+	schemaWorldEvent = schemaByType[MessageWorldEvent]
+	schemaMaintenance = schemaByType[MessageMaintenance]
+	schemaSessionTakeover = schemaByType[MessageSessionTakeover]
+	schemaConfig = schemaByType[MessageConfig]
+	schemaMinimap = schemaByType[MessageMinimap]
+	schemaCipherInit = schemaByType[MessageCipherInit]
+	schemaSealed = schemaByType[MessageSealed]
+	schemaSealedClient = schemaByType[MessageSealedClient]
+	schemaWorldUpdate = schemaByType[MessageWorldUpdate]
+	schemaCellLoad = schemaByType[MessageCellLoad]
+	schemaCellUnload = schemaByType[MessageCellUnload]
+	schemaReliable = schemaByType[MessageReliable]
+	schemaAnnounce = schemaByType[MessageAnnounce]
+	schemaChatMessage = schemaByType[MessageChatMessage]
+	schemaCommandResult = schemaByType[MessageCommandResult]
+	schemaHit = schemaByType[MessageHit]
+	schemaActionRejected = schemaByType[MessageActionRejected]
+	schemaLeaderboard = schemaByType[MessageLeaderboard]
+	schemaPartyInvite = schemaByType[MessagePartyInvite]
+	schemaPartyRoster = schemaByType[MessagePartyRoster]
+	schemaPartyChatMessage = schemaByType[MessagePartyChatMessage]
+	schemaFriendUpdate = schemaByType[MessageFriendUpdate]
+	schemaInventory = schemaByType[MessageInventory]
+	schemaTradeState = schemaByType[MessageTradeState]
+	schemaPlayerEmote = schemaByType[MessagePlayerEmote]
+	schemaOrigin = schemaByType[MessageOrigin]
+	schemaQueueStatus = schemaByType[MessageQueueStatus]
+	schemaQueueAdmitted = schemaByType[MessageQueueAdmitted]
+}
+
+// LookupSchema returns the schema for a message type, or nil if unknown.
+func LookupSchema(msgType uint8) *MessageSchema {
+	return schemaByType[msgType]
+}
+
+// ConstName returns the wire constant name, e.g. "DeltaGameState" → "DELTA_GAME_STATE".
+func (m *MessageSchema) ConstName() string {
+	return constName(m.Name)
+}
+
+// constName converts a CamelCase name to UPPER_SNAKE.
+func constName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	return strings.ToUpper(b.String())
+}
@@ -0,0 +1,79 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand/v2"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// The schema-driven encoders replaced hand-written ones. The goldens of TestEncodeGolden
+// pin their output for fixed inputs; this test checks them against the hand-written
+// layouts themselves, copied below, on random inputs in the range the old encoders
+// covered (whole units, no origin).
+
+func handwrittenPlayer(dst []byte, p types.PlayerState) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, p.ID)
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(p.X))
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(p.Y))
+	dst = append(dst, uint8(p.VX), uint8(p.VY))
+	flags := p.State & 0x7F
+	if p.FacingRight {
+		flags |= 0x80
+	}
+	return append(dst, flags)
+}
+
+func handwrittenState(msgType uint8, players []types.PlayerState, seq uint32) []byte {
+	dst := []byte{msgType}
+	dst = binary.LittleEndian.AppendUint32(dst, seq)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(players)))
+	for _, p := range players {
+		dst = handwrittenPlayer(dst, p)
+	}
+	return dst
+}
+
+func TestSchemaEncodersMatchHandwritten(t *testing.T) {
+	rng := rand.New(rand.NewPCG(4589, 1))
+	randomPlayer := func() types.PlayerState {
+		return types.PlayerState{
+			ID:          rng.Uint32(),
+			X:           rng.Uint32N(1 << 16),
+			Y:           rng.Uint32N(1 << 16),
+			VX:          int8(rng.IntN(3) - 1),
+			VY:          int8(rng.IntN(3) - 1),
+			FacingRight: rng.IntN(2) == 0,
+			State:       uint8(rng.UintN(256)),
+		}
+	}
+	check := func(name string, got, want []byte) {
+		t.Helper()
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s:\n got % x\nwant % x", name, got, want)
+		}
+	}
+	for range 200 {
+		players := make([]types.PlayerState, rng.IntN(20))
+		for i := range players {
+			players[i] = randomPlayer()
+		}
+		seq := rng.Uint32()
+		check("GAME_STATE", bp.EncodeGameState(players, seq), handwrittenState(protocol.MessageGameState, players, seq))
+		check("DELTA_GAME_STATE", bp.EncodeDeltaGameState(players, seq), handwrittenState(protocol.MessageDeltaGameState, players, seq))
+
+		p := randomPlayer()
+		check("PLAYER_JOINED", bp.EncodePlayerJoined(p), handwrittenPlayer([]byte{protocol.MessagePlayerJoined}, p))
+		check("PLAYER_LEFT", bp.EncodePlayerLeft(p.ID), binary.LittleEndian.AppendUint32([]byte{protocol.MessagePlayerLeft}, p.ID))
+
+		x, y, input := uint16(p.X), uint16(p.Y), rng.Uint32()
+		ack := binary.LittleEndian.AppendUint32([]byte{protocol.MessageMovementAck}, p.ID)
+		ack = binary.LittleEndian.AppendUint16(ack, x)
+		ack = binary.LittleEndian.AppendUint16(ack, y)
+		ack = binary.LittleEndian.AppendUint32(ack, input)
+		check("MOVEMENT_ACK", bp.EncodeMovementAck(p.ID, x, y, input), ack)
+	}
+}
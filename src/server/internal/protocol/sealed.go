@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Sealed messages (JOIN capability CapEncryption).
+//
+// For deployments where TLS ends at an edge that should not read everything, the
+// server picks message types (per direction, listed in CIPHER_INIT) that travel inside
+// SEALED / SEALED_CLIENT envelopes, encrypted and authenticated with AES-256-GCM:
+//
+//	key   = HMAC-SHA256(sessionKey, "pixi.cipher\x00" || salt)
+//	nonce = [direction u8][7 zero bytes][counter u32 LE]
+//	AAD   = envelope header [type][counter][length]
+//
+// sessionKey is auth.SessionKey of the connection's token, salt the random bytes of
+// CIPHER_INIT, so every connection gets its own key. counter starts at 1 in each
+// direction and grows by one per sealed message; receivers reject a counter that is not
+// above the last one they opened, so the edge cannot replay or reorder sealed messages.
+// The plaintext is the whole inner message, type byte included.
+
+const (
+	SaltSize     = 16 // CIPHER_INIT salt
+	SealOverhead = 16 // GCM tag appended to every ciphertext
+	sealDirUp    = 0  // client → server
+	sealDirDown  = 1  // server → client
+)
+
+// ErrSealed — an envelope that is malformed or fails authentication.
+var ErrSealed = errors.New("sealed message rejected")
+
+// NewSealer returns the AEAD of a connection from its session key and salt.
+func NewSealer(sessionKey, salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte("pixi.cipher\x00"))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncodeCipherInit кодирует соль ключа соединения и список запечатываемых типов.
+func (bp *BinaryProtocol) EncodeCipherInit(salt [SaltSize]byte, sealed []uint8) []byte {
+	buffer := make([]byte, schemaCipherInit.Size(len(sealed)))
+	buffer[0] = MessageCipherInit
+	var values [maxSchemaFields]uint32
+	for i := range SaltSize / 4 {
+		values[i] = binary.LittleEndian.Uint32(salt[i*4:])
+	}
+	values[SaltSize/4] = uint32(len(sealed))
+	offset := putFields(buffer, 1, schemaCipherInit.Fields, values[:])
+	copy(buffer[offset:], sealed)
+	return buffer
+}
+
+// envelopeSchema returns the schema and nonce direction of a SEALED envelope type.
+func envelopeSchema(envelope uint8) (*MessageSchema, byte) {
+	if envelope == MessageSealedClient {
+		return schemaSealedClient, sealDirUp
+	}
+	return schemaSealed, sealDirDown
+}
+
+func sealNonce(dir byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	nonce[0] = dir
+	binary.LittleEndian.PutUint32(nonce[8:], counter)
+	return nonce
+}
+
+// AppendSealed appends msg sealed in an envelope (MessageSealed from the server,
+// MessageSealedClient from a client) with counter.
+func AppendSealed(dst []byte, aead cipher.AEAD, envelope uint8, counter uint32, msg []byte) []byte {
+	schema, dir := envelopeSchema(envelope)
+	start := len(dst)
+	dst = append(dst, make([]byte, schema.Size(0))...)
+	dst[start] = envelope
+	values := [maxSchemaFields]uint32{counter, uint32(len(msg) + SealOverhead)}
+	putFields(dst, start+1, schema.Fields, values[:])
+	header := append([]byte(nil), dst[start:]...) // AAD must not overlap the output
+	return aead.Seal(dst, sealNonce(dir, counter), msg, header)
+}
+
+// OpenSealed opens an envelope of type data[0] and returns its counter and the inner
+// message. The caller checks the counter against the last one it accepted.
+func OpenSealed(aead cipher.AEAD, data []byte) (uint32, []byte, error) {
+	if len(data) == 0 || (data[0] != MessageSealed && data[0] != MessageSealedClient) {
+		return 0, nil, ErrSealed
+	}
+	schema, dir := envelopeSchema(data[0])
+	headerSize := schema.Size(0)
+	if len(data) < headerSize+SealOverhead {
+		return 0, nil, ErrSealed
+	}
+	var values [maxSchemaFields]uint32
+	getFields(data, 1, schema.Fields, values[:])
+	if int(values[1]) != len(data)-headerSize {
+		return 0, nil, ErrSealed
+	}
+	msg, err := aead.Open(nil, sealNonce(dir, values[0]), data[headerSize:], data[:headerSize])
+	if err != nil || len(msg) == 0 {
+		return 0, nil, ErrSealed
+	}
+	return values[0], msg, nil
+}
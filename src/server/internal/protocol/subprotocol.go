@@ -0,0 +1,57 @@
+package protocol
+
+// Subprotocol — WebSocket subprotocol (Sec-WebSocket-Protocol) of the current wire
+// format. The version is bumped with any incompatible change to Messages, so reverse
+// proxies and clients can tell protocol versions apart during the upgrade.
+const Subprotocol = "pixi.game.v3"
+
+// LegacySubprotocol — the previous wire format, still served. It differs from
+// Subprotocol only in positions: whole world units, as BinaryProtocol.PositionBits 0
+// encodes them, whatever CONFIG positionBits says.
+const LegacySubprotocol = "pixi.game.v2"
+
+// Close codes (RFC 6455 private range 4000-4999) sent in the close frame before the
+// server drops a connection, so the client can tell why.
+const (
+	CloseUnsupportedSubprotocol = 4000
+	CloseProtocolViolation      = 4001
+	CloseKicked                 = 4002
+	CloseBanned                 = 4003
+	CloseServerShutdown         = 4004
+	CloseIdleTimeout            = 4005
+	CloseDuplicateSession       = 4006
+	CloseInternalError          = 4007
+)
+
+// CloseCode describes one close code for the generated docs and TypeScript constants.
+type CloseCode struct {
+	Code      uint16
+	Name      string // CamelCase; the TypeScript constant is the upper-snake form
+	Reconnect bool   // the client may reconnect automatically (with backoff)
+	Doc       string
+}
+
+// CloseCodes — all close codes of the protocol, in code order.
+var CloseCodes = []CloseCode{
+	{Code: CloseUnsupportedSubprotocol, Name: "UnsupportedSubprotocol",
+		Doc: "The client offered WebSocket subprotocols, none of them " + Subprotocol + " or " + LegacySubprotocol + " (or offered none while the server requires it). Reconnecting will not help: the client is out of date."},
+	{Code: CloseProtocolViolation, Name: "ProtocolViolation",
+		Doc: "The client broke the protocol, e.g. sent another message before JOIN, a frame over the read limit, or a frame too slowly to finish within the frame timeout. A reconnect would repeat the violation."},
+	{Code: CloseKicked, Name: "Kicked",
+		Doc: "The server kicked the player (abuse detection or an operator). The client should not reconnect on its own."},
+	{Code: CloseBanned, Name: "Banned",
+		Doc: "The account or address is banned; the reason carries the ban reason. Reconnecting is refused."},
+	{Code: CloseServerShutdown, Name: "ServerShutdown", Reconnect: true,
+		Doc: "The server is shutting down or handing over to a new process. Reconnect after a short delay."},
+	{Code: CloseIdleTimeout, Name: "IdleTimeout", Reconnect: true,
+		Doc: "Nothing was heard from the client in time: JOIN did not arrive within the handshake timeout, or pings went unanswered."},
+	{Code: CloseDuplicateSession, Name: "DuplicateSession",
+		Doc: "The account is connected elsewhere: this session was taken over by a new connection (SESSION_TAKEOVER came first), or the new connection was refused. Reconnecting would fight the other session, so the client should not."},
+	{Code: CloseInternalError, Name: "InternalError", Reconnect: true,
+		Doc: "The server hit a bug while handling this connection and dropped it; other players are not affected. Reconnect after a short delay."},
+}
+
+// ConstName returns the wire constant name, e.g. "UnsupportedSubprotocol" → "UNSUPPORTED_SUBPROTOCOL".
+func (c *CloseCode) ConstName() string {
+	return constName(c.Name)
+}
@@ -0,0 +1,209 @@
+// Package runtimeopt applies the Go runtime tuning of the game server: GC percent,
+// soft memory limit and an optional heap ballast. Settings are process-wide, so the
+// package keeps one current state; the admin API changes it live (/admin/runtime)
+// and every change starts a new GC pause window, so the effect of a setting can be
+// read off the before/after pause statistics.
+package runtimeopt
+
+import (
+	"log/slog"
+	"math"
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// NoMemoryLimit — MemoryLimit value that disables the soft limit (runtime default).
+const NoMemoryLimit = math.MaxInt64
+
+// Settings — runtime tuning knobs.
+type Settings struct {
+	GCPercent    int   `json:"gc_percent"`    // debug.SetGCPercent; -1 = GC off (only the memory limit triggers it)
+	MemoryLimit  int64 `json:"memory_limit"`  // bytes, debug.SetMemoryLimit; NoMemoryLimit = none
+	BallastBytes int64 `json:"ballast_bytes"` // heap ballast; 0 = none
+}
+
+// PauseStats — GC stop-the-world pauses within one settings window.
+type PauseStats struct {
+	Settings Settings      `json:"settings"`
+	Duration time.Duration `json:"duration_ns"`
+	Cycles   uint64        `json:"gc_cycles"`
+	Pauses   uint64        `json:"pauses"`
+	P50      time.Duration `json:"p50_ns"`
+	P99      time.Duration `json:"p99_ns"`
+	Max      time.Duration `json:"max_ns"` // upper bound of the highest non-empty bucket
+}
+
+// Runtime metrics read for the pause windows (STW-free, unlike ReadMemStats).
+const (
+	metricGCPauses = "/sched/pauses/total/gc:seconds"
+	metricGCCycles = "/gc/cycles/total:gc-cycles"
+)
+
+var (
+	mu      sync.Mutex
+	current Settings
+	ballast []byte // kept reachable: counts as live heap, so the GC target grows by its size
+
+	windowStart  time.Time
+	windowPauses []uint64 // histogram counts at windowStart
+	windowCycles uint64
+	previous     *PauseStats // the window closed by the last Apply
+)
+
+func init() {
+	current = Settings{
+		GCPercent:   debug.SetGCPercent(-1),
+		MemoryLimit: debug.SetMemoryLimit(-1), // negative = read without changing
+	}
+	debug.SetGCPercent(current.GCPercent)
+	windowStart = time.Now()
+	windowPauses, windowCycles = readPauses()
+}
+
+// Current returns the settings in effect.
+func Current() Settings {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+// Apply switches the runtime to s and returns the pause statistics of the window
+// that ran under the previous settings. Negative ballast is treated as 0, a
+// non-positive memory limit as NoMemoryLimit.
+func Apply(s Settings) PauseStats {
+	s.BallastBytes = max(s.BallastBytes, 0)
+	if s.MemoryLimit <= 0 {
+		s.MemoryLimit = NoMemoryLimit
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	closed := windowStatsLocked()
+	previous = &closed
+
+	debug.SetGCPercent(s.GCPercent)
+	debug.SetMemoryLimit(s.MemoryLimit)
+	if s.BallastBytes != int64(len(ballast)) {
+		ballast = nil
+		if s.BallastBytes > 0 {
+			// Never written: the pages stay untouched, so RSS does not grow by its size.
+			ballast = make([]byte, s.BallastBytes)
+		}
+	}
+	current = s
+
+	windowStart = time.Now()
+	windowPauses, windowCycles = readPauses()
+
+	metrics.RuntimeGCPercent.Set(float64(s.GCPercent))
+	if s.MemoryLimit == NoMemoryLimit {
+		metrics.RuntimeMemoryLimitBytes.Set(0)
+	} else {
+		metrics.RuntimeMemoryLimitBytes.Set(float64(s.MemoryLimit))
+	}
+	metrics.RuntimeBallastBytes.Set(float64(s.BallastBytes))
+	metrics.RuntimeTuningChanges.Inc()
+
+	slog.Info("runtime tuning applied",
+		"gc_percent", s.GCPercent,
+		"memory_limit_mb", memoryLimitMB(s.MemoryLimit),
+		"ballast_mb", s.BallastBytes>>20,
+		"prev_window_s", closed.Duration.Seconds(),
+		"prev_gc_cycles", closed.Cycles,
+		"prev_pause_p99_us", closed.P99.Microseconds(),
+	)
+	return closed
+}
+
+// Pauses returns the pause statistics of the current window and of the window
+// before the last Apply (nil if settings never changed).
+func Pauses() (now PauseStats, before *PauseStats) {
+	mu.Lock()
+	defer mu.Unlock()
+	return windowStatsLocked(), previous
+}
+
+func windowStatsLocked() PauseStats {
+	counts, cycles := readPauses()
+	stats := PauseStats{
+		Settings: current,
+		Duration: time.Since(windowStart),
+		Cycles:   cycles - windowCycles,
+	}
+	delta := make([]uint64, len(counts))
+	for i := range counts {
+		if i < len(windowPauses) {
+			delta[i] = counts[i] - windowPauses[i]
+		} else {
+			delta[i] = counts[i]
+		}
+		stats.Pauses += delta[i]
+	}
+	if stats.Pauses == 0 {
+		return stats
+	}
+	buckets := pauseBuckets()
+	stats.P50 = percentile(delta, buckets, stats.Pauses, 0.50)
+	stats.P99 = percentile(delta, buckets, stats.Pauses, 0.99)
+	stats.Max = percentile(delta, buckets, stats.Pauses, 1)
+	return stats
+}
+
+// percentile returns the upper bound of the bucket holding the q-th pause.
+func percentile(counts []uint64, buckets []float64, total uint64, q float64) time.Duration {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen >= rank {
+			upper := buckets[i+1]
+			if math.IsInf(upper, 1) {
+				upper = buckets[i]
+			}
+			return time.Duration(upper * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+var (
+	bucketsOnce sync.Once
+	buckets     []float64
+)
+
+// pauseBuckets returns the bucket boundaries of the pause histogram (fixed for the
+// lifetime of the process).
+func pauseBuckets() []float64 {
+	bucketsOnce.Do(func() {
+		s := []rtmetrics.Sample{{Name: metricGCPauses}}
+		rtmetrics.Read(s)
+		if s[0].Value.Kind() == rtmetrics.KindFloat64Histogram {
+			buckets = s[0].Value.Float64Histogram().Buckets
+		}
+	})
+	return buckets
+}
+
+func readPauses() (counts []uint64, cycles uint64) {
+	s := []rtmetrics.Sample{{Name: metricGCPauses}, {Name: metricGCCycles}}
+	rtmetrics.Read(s)
+	if s[0].Value.Kind() == rtmetrics.KindFloat64Histogram {
+		counts = append([]uint64(nil), s[0].Value.Float64Histogram().Counts...)
+	}
+	if s[1].Value.Kind() == rtmetrics.KindUint64 {
+		cycles = s[1].Value.Uint64()
+	}
+	return counts, cycles
+}
+
+func memoryLimitMB(limit int64) int64 {
+	if limit == NoMemoryLimit {
+		return 0
+	}
+	return limit >> 20
+}
@@ -0,0 +1,60 @@
+package runtimeopt
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	orig := Current()
+	t.Cleanup(func() { Apply(orig) })
+
+	Apply(Settings{GCPercent: 250, MemoryLimit: 1 << 30, BallastBytes: 4 << 20})
+	if got := debug.SetGCPercent(250); got != 250 {
+		t.Errorf("runtime GC percent = %d, want 250", got)
+	}
+	if got := debug.SetMemoryLimit(-1); got != 1<<30 {
+		t.Errorf("runtime memory limit = %d, want %d", got, 1<<30)
+	}
+	if len(ballast) != 4<<20 {
+		t.Errorf("ballast = %d bytes, want %d", len(ballast), 4<<20)
+	}
+
+	runtime.GC()
+	now, before := Pauses()
+	if before == nil || before.Settings != orig {
+		t.Fatalf("previous window = %+v, want settings %+v", before, orig)
+	}
+	if now.Cycles == 0 || now.Pauses == 0 || now.P99 <= 0 {
+		t.Errorf("current window after runtime.GC = %+v, want a cycle with pauses", now)
+	}
+
+	closed := Apply(Settings{GCPercent: 100, MemoryLimit: 0})
+	if closed.Settings.GCPercent != 250 || closed.Cycles == 0 {
+		t.Errorf("closed window = %+v", closed)
+	}
+	if Current().MemoryLimit != NoMemoryLimit || ballast != nil {
+		t.Errorf("memory limit 0 / no ballast not applied: %+v, ballast %d", Current(), len(ballast))
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	buckets := []float64{0, 0.001, 0.002, math.Inf(1)}
+	counts := []uint64{98, 1, 1}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, time.Millisecond},
+		{0.99, 2 * time.Millisecond},
+		{1, 2 * time.Millisecond}, // +Inf bucket reports its lower bound
+	}
+	for _, tt := range tests {
+		if got := percentile(counts, buckets, 100, tt.q); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package schedule parses cron-like expressions for timed world events and scheduled
+// restarts.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron — parsed 5-field expression "minute hour day-of-month month day-of-week".
+// Each field accepts *, a value, a range a-b, a step */n or a-b/n, and comma lists.
+// Day-of-week is 0-6 (Sunday = 0, 7 is accepted as Sunday). As in classic cron, when
+// both day fields are restricted a time matches if either of them does.
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // bit i set = value i allowed
+	domAny, dowAny                bool
+}
+
+var cronFields = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+// ParseCron parses expr. Descriptors @hourly and @daily are accepted too.
+func ParseCron(expr string) (*Cron, error) {
+	switch strings.TrimSpace(expr) {
+	case "@hourly":
+		expr = "0 * * * *"
+	case "@daily":
+		expr = "0 0 * * *"
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		f := cronFields[i]
+		set, err := parseCronField(part, f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %s: %w", expr, f.name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1 // 7 = Sunday
+	}
+	return &Cron{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", item)
+			}
+			rangePart, step = item[:i], n
+		}
+
+		from, to := lo, hi
+		if rangePart != "*" {
+			a, b, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad value %q", item)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad range %q", item)
+				}
+			} else if step > 1 {
+				to = hi // "a/n" = from a to the end in steps of n
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q out of range %d-%d", item, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Matches reports whether t (to minute precision) matches the expression.
+func (c *Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<t.Minute()) == 0 || c.hour&(1<<t.Hour()) == 0 || c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	return c.dayMatches(t)
+}
+
+// dayMatches checks the day-of-month and day-of-week fields.
+func (c *Cron) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<t.Day()) != 0
+	dowOK := c.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowOK
+	case c.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+// maxCronSearch bounds Next: every valid expression matches within a leap-year cycle,
+// except impossible dates such as February 30.
+const maxCronSearch = 4 * 366 * 24 * time.Hour
+
+// Next returns the first minute after t that matches the expression, or the zero time
+// if none does (e.g. "0 0 30 2 *").
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for end := next.Add(maxCronSearch); next.Before(end); {
+		switch {
+		case c.month&(1<<int(next.Month())) == 0:
+			// Skip to the first day of the next month.
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !c.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case c.hour&(1<<next.Hour()) == 0:
+			// The next local hour: Truncate works on absolute time, which is off by
+			// the half hour in zones such as +05:30.
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case c.minute&(1<<next.Minute()) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
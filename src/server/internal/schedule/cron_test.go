@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // Europe/Berlin without the system zoneinfo
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, time.January, 31, 22, 30, 15, 0, time.UTC) // Saturday
+	for expr, want := range map[string]time.Time{
+		"* * * * *":     time.Date(2026, time.January, 31, 22, 31, 0, 0, time.UTC),
+		"0 4 * * *":     time.Date(2026, time.February, 1, 4, 0, 0, 0, time.UTC),
+		"30 22 * * *":   time.Date(2026, time.February, 1, 22, 30, 0, 0, time.UTC),
+		"0 6 * * 1":     time.Date(2026, time.February, 2, 6, 0, 0, 0, time.UTC),
+		"15 3 29 2 *":   time.Date(2028, time.February, 29, 3, 15, 0, 0, time.UTC),
+		"*/20 23 * * *": time.Date(2026, time.January, 31, 23, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":    {},
+	} {
+		c, err := ParseCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Next(from); !got.Equal(want) {
+			t.Errorf("%q: Next = %v, want %v", expr, got, want)
+		}
+		if !want.IsZero() && !c.Matches(want) {
+			t.Errorf("%q does not match its own Next %v", expr, want)
+		}
+	}
+}
+
+func TestCronNextLocal(t *testing.T) {
+	kolkata := time.FixedZone("IST", 5*3600+1800)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		expr       string
+		from, want time.Time
+	}{
+		// Half-hour offset: local hours start at :30 UTC.
+		{"0 4 * * *", time.Date(2026, time.January, 31, 22, 30, 0, 0, kolkata), time.Date(2026, time.February, 1, 4, 0, 0, 0, kolkata)},
+		{"0 * * * *", time.Date(2026, time.January, 31, 22, 30, 0, 0, kolkata), time.Date(2026, time.January, 31, 23, 0, 0, 0, kolkata)},
+		// Spring forward (Mar 29 02:00 → 03:00): 02:30 does not exist that day.
+		{"30 2 * * *", time.Date(2026, time.March, 28, 23, 0, 0, 0, berlin), time.Date(2026, time.March, 30, 2, 30, 0, 0, berlin)},
+		{"0 3 * * *", time.Date(2026, time.March, 29, 1, 30, 0, 0, berlin), time.Date(2026, time.March, 29, 3, 0, 0, 0, berlin)},
+		// Fall back (Oct 25 03:00 → 02:00): from the first 02:30, past the repeated hour.
+		{"0 3 * * *", time.Date(2026, time.October, 25, 0, 30, 0, 0, time.UTC).In(berlin), time.Date(2026, time.October, 25, 3, 0, 0, 0, berlin)},
+		{"0 4 * * *", time.Date(2026, time.October, 25, 1, 0, 0, 0, berlin), time.Date(2026, time.October, 25, 4, 0, 0, 0, berlin)},
+	} {
+		c, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Next(tc.from); !got.Equal(tc.want) {
+			t.Errorf("%q from %v: Next = %v, want %v", tc.expr, tc.from, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+//go:build lua
+
+package scripting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Lua runtime (gopher-lua). One LState holds every script of the set; the engine
+// goroutine is the only one touching it.
+
+const (
+	luaCallStack = 256 // nested calls: runaway recursion errors out here
+	luaSlotBytes = 16  // an LValue in the registry
+)
+
+func init() {
+	newRuntime = newLuaRuntime
+}
+
+type luaRuntime struct {
+	L *lua.LState
+}
+
+func newLuaRuntime(host Host, scripts []Script, opts Options) (runtime, error) {
+	slots := max(opts.MemoryMB, 1) << 20 / luaSlotBytes
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:     true,
+		CallStackSize:    luaCallStack,
+		RegistrySize:     min(1024*20, slots),
+		RegistryMaxSize:  slots,
+		RegistryGrowStep: 32,
+	})
+	openSafeLibs(L, opts.MemoryMB<<20)
+	L.SetGlobal("game", gameTable(L, host))
+	L.SetGlobal("print", L.NewFunction(luaLog))
+
+	for _, s := range scripts {
+		if err := loadScript(L, s, opts); err != nil {
+			L.Close()
+			return nil, err
+		}
+	}
+	return &luaRuntime{L: L}, nil
+}
+
+// openSafeLibs opens base, table, string and math, without the base functions that
+// read files or compile code at run time. string.rep refuses results over maxString
+// bytes: it is the one library call that allocates a string of any size in a single
+// step, before the CPU budget gets a chance to stop it.
+func openSafeLibs(L *lua.LState, maxString int) {
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage", "_printregs"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.GetGlobal(lua.StringLibName).(*lua.LTable).RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+		str, n := L.CheckString(1), L.CheckInt(2)
+		if n > 0 && len(str) > 0 && n > maxString/len(str) {
+			L.RaiseError("string.rep: result over %d bytes", maxString)
+		}
+		L.Push(lua.LString(strings.Repeat(str, max(n, 0))))
+		return 1
+	}))
+}
+
+func loadScript(L *lua.LState, s Script, opts Options) error {
+	fn, err := L.Load(bytes.NewReader(s.Source), s.Name)
+	if err != nil {
+		return fmt.Errorf("scripting: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Budget)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	L.Push(fn)
+	if err := L.PCall(0, 0, nil); err != nil {
+		return fmt.Errorf("scripting: %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+func gameTable(L *lua.LState, host Host) *lua.LTable {
+	t := L.NewTable()
+	L.SetFuncs(t, map[string]lua.LGFunction{
+		"say": func(L *lua.LState) int {
+			host.Say(uint32(L.CheckNumber(1)), L.CheckString(2))
+			return 0
+		},
+		"announce": func(L *lua.LState) int {
+			host.Announce(L.CheckString(1))
+			return 0
+		},
+		"player": func(L *lua.LState) int {
+			p, ok := host.Player(uint32(L.CheckNumber(1)))
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			t := L.NewTable()
+			t.RawSetString("id", lua.LNumber(p.ID))
+			t.RawSetString("x", lua.LNumber(p.X))
+			t.RawSetString("y", lua.LNumber(p.Y))
+			L.Push(t)
+			return 1
+		},
+		"log": luaLog,
+	})
+	return t
+}
+
+func luaLog(L *lua.LState) int {
+	args := make([]any, 0, L.GetTop())
+	for i := 1; i <= L.GetTop(); i++ {
+		args = append(args, L.Get(i).String())
+	}
+	slog.Info("script: " + fmt.Sprint(args...))
+	return 0
+}
+
+func (r *luaRuntime) call(ctx context.Context, ev *Event) (string, bool, error) {
+	L := r.L
+	fn := L.GetGlobal(ev.Hook)
+	if fn.Type() != lua.LTFunction {
+		return "", false, nil
+	}
+	var args []lua.LValue
+	switch ev.Hook {
+	case HookPlayerJoin:
+		args = []lua.LValue{lua.LNumber(ev.PlayerID)}
+	case HookTick:
+		args = []lua.LValue{lua.LNumber(ev.Tick)}
+	case HookAttack:
+		args = []lua.LValue{lua.LNumber(ev.PlayerID), lua.LNumber(ev.VictimID), lua.LNumber(ev.Damage), lua.LNumber(ev.HP)}
+	case HookChatCommand:
+		args = []lua.LValue{lua.LNumber(ev.PlayerID), lua.LString(ev.Command)}
+		for _, a := range ev.Args {
+			args = append(args, lua.LString(a))
+		}
+	}
+
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	if err := L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...); err != nil {
+		L.SetTop(0)
+		return "", false, err
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+	if ev.Hook != HookChatCommand {
+		return "", true, nil
+	}
+	if s, ok := ret.(lua.LString); ok {
+		return string(s), true, nil
+	}
+	return "", false, nil
+}
+
+func (r *luaRuntime) close() {
+	r.L.Close()
+}
@@ -0,0 +1,118 @@
+//go:build lua
+
+package scripting
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingHost records what scripts asked the game to do.
+type recordingHost struct {
+	said []string
+}
+
+func (h *recordingHost) Say(playerID uint32, text string) { h.said = append(h.said, text) }
+func (h *recordingHost) Announce(text string)             { h.said = append(h.said, "*"+text) }
+func (h *recordingHost) Player(playerID uint32) (PlayerInfo, bool) {
+	return PlayerInfo{ID: playerID, X: 10, Y: 20}, playerID == 7
+}
+
+func newTestLua(t *testing.T, host Host, src string) runtime {
+	t.Helper()
+	rt, err := newLuaRuntime(host, []Script{{Name: "test.lua", Source: []byte(src)}}, Options{Budget: 50 * time.Millisecond, MemoryMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rt.close)
+	return rt
+}
+
+func callLua(rt runtime, ev *Event) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	return rt.call(ctx, ev)
+}
+
+func TestLuaHooks(t *testing.T) {
+	host := &recordingHost{}
+	rt := newTestLua(t, host, `
+function onPlayerJoin(id)
+  local p = game.player(id)
+  game.say(id, "hi " .. p.x .. "," .. p.y)
+  game.announce("joined")
+end
+function onChatCommand(id, name, a, b)
+  if name == "add" then return tostring(tonumber(a) + tonumber(b)) end
+end`)
+
+	if _, handled, err := callLua(rt, &Event{Hook: HookPlayerJoin, PlayerID: 7}); err != nil || !handled {
+		t.Fatalf("onPlayerJoin: handled=%v err=%v", handled, err)
+	}
+	if strings.Join(host.said, "|") != "hi 10,20|*joined" {
+		t.Errorf("host got %q", host.said)
+	}
+	if reply, handled, err := callLua(rt, &Event{Hook: HookChatCommand, PlayerID: 7, Command: "add", Args: []string{"2", "3"}}); err != nil || !handled || reply != "5" {
+		t.Errorf("/add 2 3 = %q, %v, %v", reply, handled, err)
+	}
+	if _, handled, err := callLua(rt, &Event{Hook: HookChatCommand, Command: "nope"}); err != nil || handled {
+		t.Errorf("/nope: handled=%v err=%v", handled, err)
+	}
+	if _, handled, err := callLua(rt, &Event{Hook: HookTick}); err != nil || handled {
+		t.Errorf("undefined onTick: handled=%v err=%v", handled, err)
+	}
+}
+
+func TestLuaSandbox(t *testing.T) {
+	for _, name := range []string{"io", "os", "require", "module", "dofile", "loadfile", "load", "loadstring", "collectgarbage", "debug", "package"} {
+		rt := newTestLua(t, nil, `function onChatCommand() return type(`+name+`) end`)
+		if reply, _, err := callLua(rt, &Event{Hook: HookChatCommand}); err != nil || reply != "nil" {
+			t.Errorf("type(%s) = %q, %v; want nil", name, reply, err)
+		}
+	}
+}
+
+func TestLuaLimits(t *testing.T) {
+	rt := newTestLua(t, nil, `
+function onTick() while true do end end
+function onAttack() local function f() return 1 + f() end return f() end
+function onChatCommand(id, name, n) return #string.rep("x", tonumber(n)) .. "" end`)
+
+	start := time.Now()
+	if _, _, err := callLua(rt, &Event{Hook: HookTick}); err == nil {
+		t.Error("endless onTick finished")
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("endless onTick aborted after %v, want about the 50ms budget", took)
+	}
+	if _, _, err := callLua(rt, &Event{Hook: HookAttack}); err == nil {
+		t.Error("runaway recursion finished")
+	}
+
+	if reply, _, err := callLua(rt, &Event{Hook: HookChatCommand, Args: []string{"1024"}}); err != nil || reply != "1024" {
+		t.Errorf("string.rep within the limit: %q, %v", reply, err)
+	}
+	// 2^31 bytes would be allocated before any budget check.
+	if _, _, err := callLua(rt, &Event{Hook: HookChatCommand, Args: []string{"2147483648"}}); err == nil || !strings.Contains(err.Error(), "string.rep") {
+		t.Errorf("string.rep over SCRIPT_MEMORY_MB: %v, want refused", err)
+	}
+	// The runtime survives its scripts' errors.
+	if reply, _, err := callLua(rt, &Event{Hook: HookChatCommand, Args: []string{"3"}}); err != nil || reply != "3" {
+		t.Errorf("after the errors: %q, %v", reply, err)
+	}
+}
+
+func TestLuaLoadErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"syntax":        "function (",
+		"top-level":     `error("boom")`,
+		"endless chunk": "while true do end",
+	} {
+		_, err := newLuaRuntime(nil, []Script{{Name: "bad.lua", Source: []byte(src)}}, Options{Budget: 20 * time.Millisecond, MemoryMB: 1})
+		if err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
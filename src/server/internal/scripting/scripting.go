@@ -0,0 +1,282 @@
+// Package scripting runs gameplay scripts next to the game: designers drop Lua files
+// into SCRIPTS_DIR and hook into the server without a rebuild.
+//
+// Scripts define global functions, each optional:
+//
+//	onPlayerJoin(id)                      a player joined
+//	onTick(tick)                          after every world tick, tick counted from 1
+//	onAttack(attacker, victim, damage, hp) an attack hit; hp is the victim's after it
+//	onChatCommand(id, name, args...)      /name args… that no built-in command took;
+//	                                      a returned string is the answer, nil = unknown
+//
+// and call back through the game table: game.say(id, text), game.announce(text),
+// game.player(id) → {id, x, y} or nil, game.log(text).
+//
+// The Engine never runs a script on the caller's goroutine: hooks are queued and run
+// one at a time on the engine goroutine, so a slow script cannot stall the game loop
+// or a read worker — when the queue is full the event is dropped and counted. Every
+// call has a CPU budget (SCRIPT_BUDGET_MS); a script still running at the deadline
+// is aborted. SCRIPT_MEMORY_MB bounds the interpreter's value stack and registry and
+// the strings string.rep builds; it is not a heap limit — tables and strings a script
+// grows step by step are bounded only by what it can allocate within its budget.
+// Scripts get no io, os, module loading or load/loadstring.
+//
+// The files of the directory are loaded in name order into one interpreter. The
+// engine checks them for changes every ReloadInterval and reloads the whole set into
+// a fresh interpreter — globals a script kept are lost. A set that fails to load is
+// logged and the previous one keeps running.
+//
+// The interpreter is github.com/yuin/gopher-lua, compiled in with the lua build tag
+// (go build -tags lua); without it New reports ErrNoRuntime.
+package scripting
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Hook names, also the metric labels.
+const (
+	HookPlayerJoin  = "onPlayerJoin"
+	HookTick        = "onTick"
+	HookAttack      = "onAttack"
+	HookChatCommand = "onChatCommand"
+)
+
+// ReloadInterval — how often the scripts directory is checked for changes.
+const ReloadInterval = time.Second
+
+// queueSize — events waiting for the engine goroutine before new ones are dropped.
+const queueSize = 1024
+
+// ErrNoRuntime — the binary was built without a script interpreter.
+var ErrNoRuntime = errors.New("scripting: built without a script runtime (build with -tags lua)")
+
+// Host is what scripts can do to the game. Called on the engine goroutine.
+type Host interface {
+	Say(playerID uint32, text string) // a line to one player
+	Announce(text string)             // a line to every player
+	Player(playerID uint32) (PlayerInfo, bool)
+}
+
+// PlayerInfo — a connected player as scripts see it.
+type PlayerInfo struct {
+	ID   uint32
+	X, Y uint32
+}
+
+// Event — one hook call.
+type Event struct {
+	Hook     string
+	PlayerID uint32 // onPlayerJoin, onChatCommand; the attacker of onAttack
+	VictimID uint32
+	Damage   uint16
+	HP       uint16
+	Tick     uint64
+	Command  string
+	Args     []string
+	reply    func(text string, handled bool) // onChatCommand only
+}
+
+// Script — one loaded file.
+type Script struct {
+	Name   string
+	Source []byte
+}
+
+// Options — limits of a runtime.
+type Options struct {
+	Budget   time.Duration // for the top-level code of each file
+	MemoryMB int
+}
+
+// runtime — a loaded set of scripts. call runs one hook and stops at ctx's deadline;
+// a hook the scripts do not define is not handled and not an error.
+type runtime interface {
+	call(ctx context.Context, ev *Event) (reply string, handled bool, err error)
+	close()
+}
+
+// newRuntime loads scripts into an interpreter; nil when none is compiled in (lua.go).
+var newRuntime func(host Host, scripts []Script, opts Options) (runtime, error)
+
+// Config — engine settings, from config.GameConfig.
+type Config struct {
+	Dir      string
+	Budget   time.Duration // CPU time one hook call may take
+	MemoryMB int
+}
+
+// Engine runs the scripts of a directory. Its methods post events and never block.
+type Engine struct {
+	cfg    Config
+	host   Host
+	events chan Event
+
+	// Engine goroutine only.
+	rt      runtime
+	version [sha256.Size]byte // of the loaded set
+	broken  [sha256.Size]byte // of the last set that failed to load, not retried
+	tick    uint64
+}
+
+// New loads the scripts of cfg.Dir. An empty directory is fine; scripts may be added
+// while the server runs.
+func New(cfg Config, host Host) (*Engine, error) {
+	if newRuntime == nil {
+		return nil, ErrNoRuntime
+	}
+	if _, err := os.Stat(cfg.Dir); err != nil {
+		return nil, fmt.Errorf("scripting: %w", err)
+	}
+	e := &Engine{cfg: cfg, host: host, events: make(chan Event, queueSize)}
+	if err := e.reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// PlayerJoined posts onPlayerJoin.
+func (e *Engine) PlayerJoined(playerID uint32) {
+	e.post(Event{Hook: HookPlayerJoin, PlayerID: playerID})
+}
+
+// Tick posts onTick. Called once per tick by the game loop.
+func (e *Engine) Tick() {
+	e.post(Event{Hook: HookTick})
+}
+
+// Attack posts onAttack.
+func (e *Engine) Attack(attackerID, victimID uint32, damage, hp uint16) {
+	e.post(Event{Hook: HookAttack, PlayerID: attackerID, VictimID: victimID, Damage: damage, HP: hp})
+}
+
+// ChatCommand posts onChatCommand. reply is called exactly once, on the engine
+// goroutine or right away when the event is dropped, with handled = false when no
+// script answered.
+func (e *Engine) ChatCommand(playerID uint32, name string, args []string, reply func(text string, handled bool)) {
+	if !e.post(Event{Hook: HookChatCommand, PlayerID: playerID, Command: name, Args: args, reply: reply}) {
+		reply("", false)
+	}
+}
+
+func (e *Engine) post(ev Event) bool {
+	select {
+	case e.events <- ev:
+		return true
+	default:
+		metrics.ScriptCalls.WithLabelValues(ev.Hook, "dropped").Inc()
+		return false
+	}
+}
+
+// Run runs hooks and reloads until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	reload := time.NewTicker(ReloadInterval)
+	defer reload.Stop()
+	defer func() {
+		if e.rt != nil {
+			e.rt.close()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload.C:
+			if err := e.reload(); err != nil {
+				slog.Error("scripts not reloaded, the previous ones keep running", "dir", e.cfg.Dir, "err", err)
+			}
+		case ev := <-e.events:
+			e.run(ctx, &ev)
+		}
+	}
+}
+
+func (e *Engine) run(ctx context.Context, ev *Event) {
+	if ev.Hook == HookTick {
+		e.tick++
+		ev.Tick = e.tick
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Budget)
+	reply, handled, err := e.rt.call(ctx, ev)
+	overBudget := ctx.Err() == context.DeadlineExceeded
+	cancel()
+
+	result := "ok"
+	switch {
+	case err != nil && overBudget:
+		result = "timeout"
+		slog.Warn("script hook over its budget, aborted", "hook", ev.Hook, "budget", e.cfg.Budget)
+	case err != nil:
+		result = "error"
+		slog.Warn("script hook failed", "hook", ev.Hook, "err", err)
+	case !handled:
+		result = "unhandled"
+	}
+	metrics.ScriptCalls.WithLabelValues(ev.Hook, result).Inc()
+	if ev.reply != nil {
+		ev.reply(reply, err == nil && handled)
+	}
+}
+
+// reload loads the directory into a new runtime if its files changed since the last
+// load. On error the current runtime stays.
+func (e *Engine) reload() error {
+	scripts, version, err := readScripts(e.cfg.Dir)
+	if err != nil {
+		metrics.ScriptReloads.WithLabelValues("error").Inc()
+		return err
+	}
+	if e.rt != nil && (version == e.version || version == e.broken) {
+		return nil
+	}
+	rt, err := newRuntime(e.host, scripts, Options{Budget: e.cfg.Budget, MemoryMB: e.cfg.MemoryMB})
+	if err != nil {
+		e.broken = version
+		metrics.ScriptReloads.WithLabelValues("error").Inc()
+		return err
+	}
+	if e.rt != nil {
+		e.rt.close()
+		slog.Info("scripts reloaded", "dir", e.cfg.Dir, "files", len(scripts))
+	} else {
+		slog.Info("scripts loaded", "dir", e.cfg.Dir, "files", len(scripts))
+	}
+	e.rt, e.version = rt, version
+	metrics.ScriptReloads.WithLabelValues("ok").Inc()
+	return nil
+}
+
+// readScripts reads the *.lua files of dir in name order and hashes names and contents.
+func readScripts(dir string) ([]Script, [sha256.Size]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return nil, [sha256.Size]byte{}, err
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	scripts := make([]Script, 0, len(paths))
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, [sha256.Size]byte{}, fmt.Errorf("scripting: %w", err)
+		}
+		name := filepath.Base(path)
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(src))
+		h.Write(src)
+		scripts = append(scripts, Script{Name: name, Source: src})
+	}
+	var version [sha256.Size]byte
+	h.Sum(version[:0])
+	return scripts, version, nil
+}
@@ -0,0 +1,182 @@
+package scripting
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRuntime stands in for the interpreter: a set "loads" unless a file says
+// "syntax error", and hooks behave as the concatenated sources say.
+type fakeRuntime struct {
+	source string
+	closed bool
+}
+
+func useFakeRuntime(t *testing.T) *[]*fakeRuntime {
+	t.Helper()
+	var loaded []*fakeRuntime
+	prev := newRuntime
+	newRuntime = func(_ Host, scripts []Script, _ Options) (runtime, error) {
+		var src strings.Builder
+		for _, s := range scripts {
+			src.Write(s.Source)
+		}
+		if strings.Contains(src.String(), "syntax error") {
+			return nil, os.ErrInvalid
+		}
+		rt := &fakeRuntime{source: src.String()}
+		loaded = append(loaded, rt)
+		return rt, nil
+	}
+	t.Cleanup(func() { newRuntime = prev })
+	return &loaded
+}
+
+func (r *fakeRuntime) call(ctx context.Context, ev *Event) (string, bool, error) {
+	if strings.Contains(r.source, "loop") {
+		<-ctx.Done()
+		return "", false, ctx.Err()
+	}
+	if ev.Hook == HookChatCommand && strings.Contains(r.source, "/"+ev.Command) {
+		return r.source + ":" + strings.Join(ev.Args, ","), true, nil
+	}
+	return "", false, nil
+}
+
+func (r *fakeRuntime) close() { r.closed = true }
+
+func writeScript(t *testing.T, dir, name, src string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestEngine(t *testing.T, dir string) *Engine {
+	t.Helper()
+	e, err := New(Config{Dir: dir, Budget: 20 * time.Millisecond, MemoryMB: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestNewWithoutRuntime(t *testing.T) {
+	prev := newRuntime
+	newRuntime = nil
+	defer func() { newRuntime = prev }()
+	if _, err := New(Config{Dir: t.TempDir()}, nil); err != ErrNoRuntime {
+		t.Fatalf("New = %v, want ErrNoRuntime", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	loaded := useFakeRuntime(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "b.lua", "/b")
+	writeScript(t, dir, "a.lua", "/a")
+	writeScript(t, dir, "notes.txt", "syntax error")
+	e := newTestEngine(t, dir)
+	if got := (*loaded)[0].source; got != "/a/b" {
+		t.Fatalf("loaded %q, want the .lua files in name order", got)
+	}
+
+	if err := e.reload(); err != nil || len(*loaded) != 1 {
+		t.Fatalf("unchanged files: reload = %v, %d loads", err, len(*loaded))
+	}
+	writeScript(t, dir, "a.lua", "/c")
+	if err := e.reload(); err != nil || len(*loaded) != 2 {
+		t.Fatalf("changed file: reload = %v, %d loads", err, len(*loaded))
+	}
+	if !(*loaded)[0].closed || e.rt != (*loaded)[1] {
+		t.Fatal("the new set did not replace the old one")
+	}
+
+	// A set that fails keeps the previous one and is not retried until it changes.
+	writeScript(t, dir, "a.lua", "syntax error")
+	if err := e.reload(); err == nil {
+		t.Fatal("broken set loaded")
+	}
+	if e.rt != (*loaded)[1] || (*loaded)[1].closed {
+		t.Fatal("broken set replaced the running one")
+	}
+	if err := e.reload(); err != nil {
+		t.Fatalf("broken set retried: %v", err)
+	}
+	writeScript(t, dir, "a.lua", "/d")
+	if err := e.reload(); err != nil || e.rt != (*loaded)[2] {
+		t.Fatalf("fixed set: reload = %v", err)
+	}
+}
+
+func TestChatCommand(t *testing.T) {
+	useFakeRuntime(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "a.lua", "/roll")
+	e := newTestEngine(t, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx)
+
+	type answer struct {
+		text    string
+		handled bool
+	}
+	replies := make(chan answer, 1)
+	ask := func(name string, args ...string) answer {
+		e.ChatCommand(7, name, args, func(text string, handled bool) { replies <- answer{text, handled} })
+		select {
+		case a := <-replies:
+			return a
+		case <-time.After(5 * time.Second):
+			t.Fatalf("/%s: no reply", name)
+			return answer{}
+		}
+	}
+	if a := ask("roll", "1", "6"); !a.handled || a.text != "/roll:1,6" {
+		t.Errorf("/roll = %+v", a)
+	}
+	if a := ask("nope"); a.handled {
+		t.Errorf("/nope handled: %+v", a)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	useFakeRuntime(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "a.lua", "/spin loop")
+	e := newTestEngine(t, dir)
+
+	var handled bool
+	replied := false
+	start := time.Now()
+	e.run(context.Background(), &Event{Hook: HookChatCommand, Command: "spin", reply: func(_ string, h bool) {
+		replied, handled = true, h
+	}})
+	if !replied || handled {
+		t.Fatalf("over-budget hook: replied %v, handled %v", replied, handled)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("over-budget hook ran %v", took)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	useFakeRuntime(t)
+	e := newTestEngine(t, t.TempDir())
+	for range queueSize {
+		e.Tick()
+	}
+	replied := false
+	e.ChatCommand(1, "roll", nil, func(_ string, handled bool) { replied = !handled })
+	if !replied {
+		t.Fatal("a dropped command was not answered")
+	}
+	if len(e.events) != queueSize {
+		t.Fatalf("%d events queued, want %d", len(e.events), queueSize)
+	}
+}
@@ -0,0 +1,367 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Per-player traffic analytics and abuse detection.
+//
+// Every connection counts the messages it sends by kind, in total and in a sliding
+// window of trafficBuckets buckets spanning Net.AbuseWindow. When a bucket closes,
+// the window rates are compared with the limits of a legit client (the browser sends
+// at most one MOVE per rendered frame); a rate above a limit is an anomaly. Each
+// anomaly is a strike against the player; at Net.AbuseStrikes strikes the player is
+// kicked. Anomalies are kept in a short history for /admin/abuse, which is also where
+// anti-cheat and ban decisions read them from.
+
+// trafficKind — class of a client message in the traffic counters.
+type trafficKind uint8
+
+const (
+	trafficMove trafficKind = iota // MOVE, including every entry of an INPUT_BATCH
+	trafficDirection
+	trafficAttack
+	trafficAttackEnd
+	trafficViewport
+	trafficSequenceReport
+	trafficChat   // CHAT and PARTY_CHAT
+	trafficParty  // PARTY actions
+	trafficFriend // FRIEND actions
+	trafficTrade  // TRADE actions
+	trafficEmote
+	trafficInvalid     // failed to decode
+	trafficRateLimited // dropped by the per-connection message limiter
+	numTrafficKinds
+)
+
+var trafficKindNames = [numTrafficKinds]string{
+	trafficMove:           "move",
+	trafficDirection:      "direction",
+	trafficAttack:         "attack",
+	trafficAttackEnd:      "attack_end",
+	trafficViewport:       "viewport",
+	trafficSequenceReport: "sequence_report",
+	trafficChat:           "chat",
+	trafficParty:          "party",
+	trafficFriend:         "friend",
+	trafficTrade:          "trade",
+	trafficEmote:          "emote",
+	trafficInvalid:        "invalid",
+	trafficRateLimited:    "rate_limited",
+}
+
+func (k trafficKind) String() string {
+	if k < numTrafficKinds {
+		return trafficKindNames[k]
+	}
+	return "unknown"
+}
+
+// trafficKindOf maps a decoded client message type to its counter.
+func trafficKindOf(msgType byte) (trafficKind, bool) {
+	switch msgType {
+	case protocol.MessageMove:
+		return trafficMove, true
+	case protocol.MessageDirection:
+		return trafficDirection, true
+	case protocol.MessageAttack:
+		return trafficAttack, true
+	case protocol.MessageAttackEnd:
+		return trafficAttackEnd, true
+	case protocol.MessageViewportUpdate:
+		return trafficViewport, true
+	case protocol.MessageSequenceReport:
+		return trafficSequenceReport, true
+	case protocol.MessageChat, protocol.MessagePartyChat:
+		return trafficChat, true
+	case protocol.MessageParty:
+		return trafficParty, true
+	case protocol.MessageFriend:
+		return trafficFriend, true
+	case protocol.MessageTrade:
+		return trafficTrade, true
+	case protocol.MessageEmote:
+		return trafficEmote, true
+	}
+	return 0, false
+}
+
+// trafficBuckets — resolution of the sliding window.
+const trafficBuckets = 10
+
+// abuseHistorySize — anomalies kept for /admin/abuse.
+const abuseHistorySize = 256
+
+// Anomaly checks, as labelled in metrics and reports.
+const (
+	abuseCheckMoveRate    = "move_rate"
+	abuseCheckMessageRate = "message_rate"
+	abuseCheckInvalidRate = "invalid_rate"
+)
+
+// abuseLimits — window rates (per second) no legit client reaches. 0 disables a check.
+type abuseLimits struct {
+	maxMovesPerSec    float64
+	maxMessagesPerSec float64 // every kind, including rate-limited frames
+	maxInvalidPerSec  float64
+}
+
+// anomaly — one limit exceeded over a window.
+type anomaly struct {
+	check string
+	rate  float64
+	limit float64
+}
+
+// check returns the limits the window rates exceed.
+func (l abuseLimits) check(rates *[numTrafficKinds]float64) []anomaly {
+	var total float64
+	for _, r := range rates {
+		total += r
+	}
+	var found []anomaly
+	for _, c := range []struct {
+		check string
+		rate  float64
+		limit float64
+	}{
+		{abuseCheckMoveRate, rates[trafficMove], l.maxMovesPerSec},
+		{abuseCheckMessageRate, total, l.maxMessagesPerSec},
+		{abuseCheckInvalidRate, rates[trafficInvalid], l.maxInvalidPerSec},
+	} {
+		if c.limit > 0 && c.rate > c.limit {
+			found = append(found, anomaly{check: c.check, rate: c.rate, limit: c.limit})
+		}
+	}
+	return found
+}
+
+// trafficStats — per-connection counters. Recorded on the connection's read path,
+// read concurrently by the admin API.
+type trafficStats struct {
+	mu       sync.Mutex
+	total    [numTrafficKinds]uint64
+	buckets  [trafficBuckets][numTrafficKinds]uint32
+	bucketNs int64 // bucket width
+	headNs   int64 // start of the current bucket; 0 = nothing recorded yet
+	head     int   // index of the current bucket
+	filled   int   // closed buckets in the window, up to trafficBuckets-1
+	strikes  int
+	clearNs  int64 // no new strike before this time (one strike per window)
+}
+
+func newTrafficStats(window time.Duration) *trafficStats {
+	return &trafficStats{bucketNs: max(window.Nanoseconds()/trafficBuckets, 1)}
+}
+
+// record counts one message of kind at nowNs. closed reports whether the previous
+// bucket was closed by this call, i.e. whether the window rates are worth checking.
+func (t *trafficStats) record(kind trafficKind, nowNs int64) (closed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	closed = t.advanceLocked(nowNs)
+	t.total[kind]++
+	t.buckets[t.head][kind]++
+	return closed
+}
+
+// advanceLocked moves the head bucket to nowNs, clearing the buckets skipped over.
+func (t *trafficStats) advanceLocked(nowNs int64) bool {
+	if t.headNs == 0 {
+		t.headNs = nowNs
+		return false
+	}
+	steps := (nowNs - t.headNs) / t.bucketNs
+	if steps <= 0 {
+		return false
+	}
+	for i := int64(0); i < min(steps, trafficBuckets); i++ {
+		t.head = (t.head + 1) % trafficBuckets
+		t.buckets[t.head] = [numTrafficKinds]uint32{}
+	}
+	t.headNs += steps * t.bucketNs
+	t.filled = min(t.filled+int(steps), trafficBuckets-1)
+	return true
+}
+
+// rates returns per-second rates over the closed buckets of the window (the open
+// head bucket is excluded: it would understate a burst that just started).
+func (t *trafficStats) rates(nowNs int64) (rates [numTrafficKinds]float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.advanceLocked(nowNs)
+	if t.filled == 0 {
+		return rates
+	}
+	secs := float64(int64(t.filled)*t.bucketNs) / float64(time.Second)
+	for i := 1; i <= t.filled; i++ {
+		b := &t.buckets[(t.head-i+trafficBuckets)%trafficBuckets]
+		for k, n := range b {
+			rates[k] += float64(n)
+		}
+	}
+	for k := range rates {
+		rates[k] /= secs
+	}
+	return rates
+}
+
+// strike records an anomaly and returns the strike count, or 0 when the player was
+// already struck within the current window.
+func (t *trafficStats) strike(nowNs int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if nowNs < t.clearNs {
+		return 0
+	}
+	t.strikes++
+	t.clearNs = nowNs + t.bucketNs*trafficBuckets
+	return t.strikes
+}
+
+// TrafficSnapshot — a player's traffic as reported by /admin/abuse.
+type TrafficSnapshot struct {
+	PlayerID uint32             `json:"player_id"`
+	IP       string             `json:"ip"`
+	Total    map[string]uint64  `json:"total"`
+	Rates    map[string]float64 `json:"rates_per_sec"`
+	Strikes  int                `json:"strikes"`
+}
+
+func (t *trafficStats) snapshot(nowNs int64) (total [numTrafficKinds]uint64, rates [numTrafficKinds]float64, strikes int) {
+	rates = t.rates(nowNs)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total, rates, t.strikes
+}
+
+// AbuseReport — one detected anomaly.
+type AbuseReport struct {
+	Time     time.Time `json:"time"`
+	PlayerID uint32    `json:"player_id"`
+	IP       string    `json:"ip"`
+	Check    string    `json:"check"`
+	Rate     float64   `json:"rate_per_sec"`
+	Limit    float64   `json:"limit_per_sec"`
+	Strikes  int       `json:"strikes"`
+	Kicked   bool      `json:"kicked"`
+}
+
+// abuseHistory — ring of the latest reports.
+type abuseHistory struct {
+	mu      sync.Mutex
+	reports [abuseHistorySize]AbuseReport
+	next    int
+	count   int
+}
+
+func (h *abuseHistory) add(r AbuseReport) {
+	h.mu.Lock()
+	h.reports[h.next] = r
+	h.next = (h.next + 1) % abuseHistorySize
+	h.count = min(h.count+1, abuseHistorySize)
+	h.mu.Unlock()
+}
+
+// recent returns the reports, newest first.
+func (h *abuseHistory) recent() []AbuseReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]AbuseReport, 0, h.count)
+	for i := 1; i <= h.count; i++ {
+		out = append(out, h.reports[(h.next-i+abuseHistorySize)%abuseHistorySize])
+	}
+	return out
+}
+
+// recordTraffic counts one message from c and runs the detector when a window
+// bucket closed. Called on c's read path only.
+func (s *Server) recordTraffic(c *Connection, kind trafficKind) {
+	if c.traffic == nil {
+		return
+	}
+	nowNs := time.Now().UnixNano()
+	if !c.traffic.record(kind, nowNs) || atomic.LoadInt32(&c.state) != connJoined {
+		return
+	}
+	rates := c.traffic.rates(nowNs)
+	for _, a := range s.abuseLimits.check(&rates) {
+		s.reportAbuse(c, a, nowNs)
+	}
+}
+
+// reportAbuse turns an anomaly into a strike against c's player and kicks the
+// player once the strike limit is reached.
+func (s *Server) reportAbuse(c *Connection, a anomaly, nowNs int64) {
+	metrics.AbuseAnomalies.WithLabelValues(a.check).Inc()
+	strikes := c.traffic.strike(nowNs)
+	if strikes == 0 {
+		return
+	}
+	report := AbuseReport{
+		Time:     time.Unix(0, nowNs),
+		PlayerID: c.player.ID,
+		IP:       c.ip,
+		Check:    a.check,
+		Rate:     a.rate,
+		Limit:    a.limit,
+		Strikes:  strikes,
+		Kicked:   s.cfg.Net.AbuseStrikes > 0 && strikes >= s.cfg.Net.AbuseStrikes,
+	}
+	s.abuseHistory.add(report)
+	slog.Warn("abusive traffic detected",
+		"player_id", report.PlayerID,
+		"ip", report.IP,
+		"check", a.check,
+		"rate_per_sec", a.rate,
+		"limit_per_sec", a.limit,
+		"strikes", strikes,
+		"kicked", report.Kicked,
+	)
+	if report.Kicked {
+		metrics.AbuseKicks.Inc()
+		c.noteDisconnect(causeRateLimit)
+		s.closeConnection(c, protocol.CloseKicked, "abusive traffic: "+a.check)
+	}
+}
+
+// trafficSnapshots returns the traffic of every joined player, or of one player
+// when playerID != 0.
+func (s *Server) trafficSnapshots(playerID uint32) []TrafficSnapshot {
+	s.connectionsMu.RLock()
+	conns := make([]*Connection, 0, len(s.connections))
+	for id, c := range s.connections {
+		if playerID == 0 || id == playerID {
+			conns = append(conns, c)
+		}
+	}
+	s.connectionsMu.RUnlock()
+
+	nowNs := time.Now().UnixNano()
+	out := make([]TrafficSnapshot, 0, len(conns))
+	for _, c := range conns {
+		if c.traffic == nil {
+			continue
+		}
+		total, rates, strikes := c.traffic.snapshot(nowNs)
+		snap := TrafficSnapshot{
+			PlayerID: c.player.ID,
+			IP:       c.ip,
+			Total:    make(map[string]uint64, numTrafficKinds),
+			Rates:    make(map[string]float64, numTrafficKinds),
+			Strikes:  strikes,
+		}
+		for k := trafficKind(0); k < numTrafficKinds; k++ {
+			snap.Total[k.String()] = total[k]
+			snap.Rates[k.String()] = rates[k]
+		}
+		out = append(out, snap)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrafficWindowRates(t *testing.T) {
+	ts := newTrafficStats(time.Second) // 100 ms buckets
+	start := int64(time.Hour)
+	// 10 moves per 100 ms for half a second = 100/s.
+	for i := 0; i < 50; i++ {
+		ts.record(trafficMove, start+int64(i)*int64(10*time.Millisecond))
+	}
+	ts.record(trafficInvalid, start+int64(450*time.Millisecond))
+
+	rates := ts.rates(start + int64(500*time.Millisecond))
+	if got := rates[trafficMove]; got < 99 || got > 101 {
+		t.Errorf("move rate = %.1f/s, want 100", got)
+	}
+	if got := rates[trafficInvalid]; got < 1.9 || got > 2.1 {
+		t.Errorf("invalid rate = %.1f/s, want 2", got)
+	}
+
+	// Once the burst slides out of the window, the rate drops to zero.
+	rates = ts.rates(start + int64(3*time.Second))
+	if rates[trafficMove] != 0 {
+		t.Errorf("move rate after the window = %.1f/s, want 0", rates[trafficMove])
+	}
+	if ts.total[trafficMove] != 50 {
+		t.Errorf("total moves = %d, want 50", ts.total[trafficMove])
+	}
+}
+
+func TestAbuseLimitsCheck(t *testing.T) {
+	limits := abuseLimits{maxMovesPerSec: 200, maxMessagesPerSec: 300, maxInvalidPerSec: 0}
+
+	var legit [numTrafficKinds]float64
+	legit[trafficMove], legit[trafficDirection], legit[trafficInvalid] = 144, 4, 50
+	if got := limits.check(&legit); len(got) != 0 {
+		t.Errorf("144 moves/s flagged as %v", got)
+	}
+
+	var bot [numTrafficKinds]float64
+	bot[trafficMove], bot[trafficRateLimited] = 250, 100
+	got := limits.check(&bot)
+	if len(got) != 2 || got[0].check != abuseCheckMoveRate || got[1].check != abuseCheckMessageRate {
+		t.Fatalf("anomalies = %+v, want move_rate and message_rate", got)
+	}
+	if got[1].rate != 350 {
+		t.Errorf("message rate = %.0f, want 350", got[1].rate)
+	}
+}
+
+func TestTrafficStrikeOncePerWindow(t *testing.T) {
+	ts := newTrafficStats(time.Second)
+	now := int64(time.Hour)
+	if got := ts.strike(now); got != 1 {
+		t.Fatalf("first strike = %d, want 1", got)
+	}
+	if got := ts.strike(now + int64(500*time.Millisecond)); got != 0 {
+		t.Errorf("strike within the window = %d, want 0", got)
+	}
+	if got := ts.strike(now + int64(time.Second)); got != 2 {
+		t.Errorf("strike after the window = %d, want 2", got)
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/runtimeopt"
+)
+
+// Admin HTTP API. Every endpoint requires "Authorization: Bearer <ADMIN_TOKEN>";
+// without a configured token the endpoints are not registered at all.
+
+// requireAdmin wraps h with the bearer-token check. A tenant's public API key is
+// refused even if its config made it the admin token.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + s.cfg.Server.AdminToken)
+	if s.tenantKey != "" && s.tenantKey == s.cfg.Server.AdminToken {
+		want = nil
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if want == nil || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleAdminBots manages server-side bots:
+//
+//	GET    /admin/bots           → current count
+//	POST   /admin/bots?count=N   → spawn N bots (limited by BOT_MAX)
+//	DELETE /admin/bots[?count=N] → remove N bots, or all without count
+func (s *Server) handleAdminBots(w http.ResponseWriter, r *http.Request) {
+	count := 0
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "count must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	changed := 0
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if count == 0 {
+			http.Error(w, "count is required", http.StatusBadRequest)
+			return
+		}
+		changed = s.bots.Spawn(count)
+	case http.MethodDelete:
+		changed = s.bots.Remove(count)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"bots":%d,"changed":%d}`, s.bots.Count(), changed)
+}
+
+// handleAdminWorld shows and changes the world size:
+//
+//	GET  /admin/world → current bounds
+//	POST /admin/world?width=W&height=H[&relocate=clamp|spawn] → resize the world; players
+//	     outside the new bounds are clamped to the edge (default) or respawned, and every
+//	     client gets WORLD_UPDATE
+func (s *Server) handleAdminWorld(w http.ResponseWriter, r *http.Request) {
+	result := game.ResizeResult{Bounds: s.gameWorld.Bounds()}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		var size [2]uint32
+		for i, name := range []string{"width", "height"} {
+			n, err := strconv.ParseUint(q.Get(name), 10, 32)
+			if err != nil {
+				http.Error(w, name+" must be a non-negative 32-bit integer", http.StatusBadRequest)
+				return
+			}
+			size[i] = uint32(n)
+		}
+		relocate := q.Get("relocate")
+		if relocate == "" {
+			relocate = game.RelocateClamp
+		}
+		var err error
+		if result, err = s.gameWorld.Resize(size[0], size[1], relocate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.broadcastWorldUpdate(result.Bounds)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// SpawnsResult — spawn points and teams shown by /admin/spawns.
+type SpawnsResult struct {
+	Points []game.SpawnPointState `json:"points"`
+	Teams  []game.TeamState       `json:"teams,omitempty"`
+}
+
+// handleAdminSpawns shows the spawn points and teams and switches points on and off:
+//
+//	GET  /admin/spawns → spawn points, and teams with their bases and player counts
+//	POST /admin/spawns?point=NAME&enabled=0|1 → enable or disable a spawn point for
+//	     the following spawns
+func (s *Server) handleAdminSpawns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		enabled, err := strconv.ParseBool(q.Get("enabled"))
+		if err != nil {
+			http.Error(w, "enabled must be 0 or 1", http.StatusBadRequest)
+			return
+		}
+		if err := s.gameWorld.SetSpawnPointEnabled(q.Get("point"), enabled); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Info("spawn point changed", "point", q.Get("point"), "enabled", enabled)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SpawnsResult{Points: s.gameWorld.SpawnPoints(), Teams: s.gameWorld.Teams()})
+}
+
+// handleAdminRuntime shows and adjusts the Go runtime tuning:
+//
+//	GET  /admin/runtime → settings plus GC pauses since the last change and before it
+//	POST /admin/runtime?gc_percent=N&memory_limit_mb=M&ballast_mb=B → change the given
+//	     settings (memory_limit_mb=0 removes the limit, gc_percent=-1 turns GC off)
+func (s *Server) handleAdminRuntime(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		settings := runtimeopt.Current()
+		q := r.URL.Query()
+		for _, p := range []struct {
+			name  string
+			min   int64
+			apply func(int64)
+		}{
+			{"gc_percent", -1, func(v int64) { settings.GCPercent = int(v) }},
+			{"memory_limit_mb", 0, func(v int64) { settings.MemoryLimit = v << 20 }},
+			{"ballast_mb", 0, func(v int64) { settings.BallastBytes = v << 20 }},
+		} {
+			v := q.Get(p.name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < p.min {
+				http.Error(w, fmt.Sprintf("%s must be an integer >= %d", p.name, p.min), http.StatusBadRequest)
+				return
+			}
+			p.apply(n)
+		}
+		runtimeopt.Apply(settings)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now, before := runtimeopt.Pauses()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Settings runtimeopt.Settings    `json:"settings"`
+		Current  runtimeopt.PauseStats  `json:"current_window"`
+		Previous *runtimeopt.PauseStats `json:"previous_window,omitempty"`
+	}{runtimeopt.Current(), now, before})
+}
+
+// handleAdminAbuse reports per-player traffic and detected anomalies:
+//
+//	GET /admin/abuse             → recent anomalies (newest first) plus every flagged player
+//	GET /admin/abuse?player=ID   → the same, with ID's traffic even if it was never flagged
+func (s *Server) handleAdminAbuse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var playerID uint32
+	if v := r.URL.Query().Get("player"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			http.Error(w, "player must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		playerID = uint32(n)
+	}
+
+	players := s.trafficSnapshots(playerID)
+	if playerID == 0 {
+		flagged := players[:0]
+		for _, p := range players {
+			if p.Strikes > 0 {
+				flagged = append(flagged, p)
+			}
+		}
+		players = flagged
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Reports []AbuseReport     `json:"reports"`
+		Players []TrafficSnapshot `json:"players"`
+	}{s.abuseHistory.recent(), players})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestAdminWorldResize(t *testing.T) {
+	s := newSessionServer(t, "")
+	c, fake := joinAccount(s, "")
+
+	rec := httptest.NewRecorder()
+	s.handleAdminWorld(rec, httptest.NewRequest(http.MethodPost, "/admin/world?width=1000&height=400", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resize status = %d: %s", rec.Code, rec.Body)
+	}
+	var res game.ResizeResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Bounds.Width != 1000 || res.Bounds.Height != 400 || !slices.Contains(res.Relocated, c.player.ID) {
+		t.Errorf("result = %+v, want a 1000×400 world with player %d relocated", res, c.player.ID)
+	}
+	if x, y := c.player.GetX(), c.player.GetY(); x > 1000 || y > 400 {
+		t.Errorf("player at (%d,%d), outside the new world", x, y)
+	}
+	if !hasMessage(t, fake, protocol.MessageWorldUpdate, time.Second) {
+		t.Error("client did not get WORLD_UPDATE")
+	}
+
+	for _, url := range []string{
+		"/admin/world?width=1000",                          // no height
+		"/admin/world?width=50&height=400",                 // smaller than a grid cell
+		"/admin/world?width=1000&height=400&relocate=nope", // bad mode
+	} {
+		rec := httptest.NewRecorder()
+		s.handleAdminWorld(rec, httptest.NewRequest(http.MethodPost, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status %d, want 400", url, rec.Code)
+		}
+	}
+}
+
+func TestAdminSpawns(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.World.SpawnPoints = []config.SpawnPoint{{Name: "gate", X: 100, Y: 100}, {Name: "tower", X: 900, Y: 900, Disabled: true}}
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	defer func() { s.cancel(); s.gameWorld.Stop() }()
+
+	rec := httptest.NewRecorder()
+	s.handleAdminSpawns(rec, httptest.NewRequest(http.MethodPost, "/admin/spawns?point=tower&enabled=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enable status = %d: %s", rec.Code, rec.Body)
+	}
+	var res SpawnsResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	want := []game.SpawnPointState{{Name: "gate", X: 100, Y: 100, Enabled: true}, {Name: "tower", X: 900, Y: 900, Enabled: true}}
+	if !slices.Equal(res.Points, want) || res.Teams != nil {
+		t.Errorf("result = %+v, want points %+v and no teams", res, want)
+	}
+
+	for url, code := range map[string]int{
+		"/admin/spawns?point=moat&enabled=1":  http.StatusNotFound,
+		"/admin/spawns?point=gate&enabled=no": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleAdminSpawns(rec, httptest.NewRequest(http.MethodPost, url, nil))
+		if rec.Code != code {
+			t.Errorf("POST %s: status %d, want %d", url, rec.Code, code)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"time"
+
+	"pixi_game_server/internal/alerts"
+	"pixi_game_server/internal/metrics"
+)
+
+// Alert rules on the server's health signals (internal/alerts). Tick duration and
+// the drop rate are read back from the Prometheus collectors as differences between
+// two checks, so they describe the last interval rather than the whole uptime.
+
+// alertCheckInterval — how often the rules are evaluated.
+const alertCheckInterval = time.Second
+
+// Alert rule names, also the "alert" label of game_alert_firing.
+const (
+	alertSlowTick    = "slow_tick"
+	alertReadBacklog = "read_backlog"
+	alertDropRate    = "drop_rate"
+)
+
+// Alerts returns the alert monitor, so other subsystems can register hooks.
+func (s *Server) Alerts() *alerts.Monitor {
+	return s.alerts
+}
+
+// initAlerts sets up the rules from the config and the built-in hooks.
+func (s *Server) initAlerts() {
+	cfg := &s.cfg.Server
+	s.alerts = alerts.NewMonitor(alertCheckInterval)
+	s.alerts.AddHook(alerts.LogHook)
+	if cfg.AlertWebhookURL != "" {
+		s.alerts.AddHook(alerts.WebhookHook(s.ctx, cfg.AlertWebhookURL, cfg.AlertWebhookTimeout))
+	}
+
+	lastTickSum, lastTicks := metrics.ReadCollector(metrics.TickDuration)
+	s.alerts.AddRule(alerts.Rule{
+		Name:      alertSlowTick,
+		Threshold: cfg.AlertTickMs,
+		Sustain:   cfg.AlertSustain,
+		Value: func() float64 {
+			sum, ticks := metrics.ReadCollector(metrics.TickDuration)
+			dSum, dTicks := sum-lastTickSum, ticks-lastTicks
+			lastTickSum, lastTicks = sum, ticks
+			if dTicks == 0 {
+				return 0 // paused or stopped world: no ticks, nothing slow
+			}
+			return dSum / dTicks * 1000
+		},
+	})
+
+	rh := s.rh
+	s.alerts.AddRule(alerts.Rule{
+		Name:      alertReadBacklog,
+		Threshold: float64(cfg.AlertReadBacklog),
+		Sustain:   cfg.AlertSustain,
+		Value:     func() float64 { return float64(rh.backlog()) },
+	})
+
+	lastDrops, _ := metrics.ReadCollector(metrics.BroadcastsDropped)
+	lastAt := time.Now()
+	s.alerts.AddRule(alerts.Rule{
+		Name:      alertDropRate,
+		Threshold: cfg.AlertDropsPerSec,
+		Sustain:   cfg.AlertSustain,
+		Value: func() float64 {
+			drops, _ := metrics.ReadCollector(metrics.BroadcastsDropped)
+			now := time.Now()
+			rate := (drops - lastDrops) / max(now.Sub(lastAt).Seconds(), 1e-3)
+			lastDrops, lastAt = drops, now
+			return rate
+		},
+	})
+}
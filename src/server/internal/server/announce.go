@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Message of the day and admin announcements (ANNOUNCE).
+//
+// Texts are localized on the server: the client passes its language at upgrade
+// (/ws?lang=ru-RU, kept as "ru" in Connection.locale) and gets the text for it, or the
+// default one. The MOTD comes from Server.MOTD (default text) and Server.MOTDFile
+// (JSON {"ru": "...", "de": "..."}) and is sent to every client right after JOIN.
+// POST /admin/announce sends an announcement to everyone, to the players of one
+// metrics zone or to one player. Both go reliable to clients that ack RELIABLE.
+
+// localizedText — текст по языкам; ключ "" — текст по умолчанию.
+type localizedText map[string]string
+
+// pick returns the text for locale, falling back to the default text.
+func (t localizedText) pick(locale string) string {
+	if text, ok := t[locale]; ok && locale != "" {
+		return text
+	}
+	return t[""]
+}
+
+// normalizeLocale reduces a language tag ("ru-RU", "pt_BR", "EN") to its lowercase
+// primary subtag; "" for anything that is not one.
+func normalizeLocale(tag string) string {
+	tag, _, _ = strings.Cut(tag, "-")
+	tag, _, _ = strings.Cut(tag, "_")
+	if len(tag) < 2 || len(tag) > 8 {
+		return ""
+	}
+	tag = strings.ToLower(tag)
+	for i := range len(tag) {
+		if tag[i] < 'a' || tag[i] > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
+
+// loadMOTD builds the MOTD from Server.MOTD and Server.MOTDFile; nil = no MOTD.
+func loadMOTD(text, path string) (localizedText, error) {
+	motd := localizedText{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var texts map[string]string
+		if err := json.Unmarshal(data, &texts); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for tag, t := range texts {
+			if locale := normalizeLocale(tag); locale != "" {
+				motd[locale] = t
+			}
+		}
+	}
+	if text != "" {
+		motd[""] = text
+	}
+	if len(motd) == 0 {
+		return nil, nil
+	}
+	return motd, nil
+}
+
+// sendMOTD sends the message of the day to a joining client. Clients whose language
+// has no text and no default text is set get nothing.
+func (s *Server) sendMOTD(c *Connection) {
+	if text := s.motd.pick(c.locale); text != "" {
+		s.sendReliable(c, s.protocol.EncodeAnnounce(protocol.AnnounceMOTD, protocol.SeverityInfo, text), 0)
+		metrics.Announcements.WithLabelValues("motd").Inc()
+	}
+}
+
+// announceTarget — кому отправить объявление; нулевое значение — всем.
+type announceTarget struct {
+	zone     string // metrics zone label ("r0c1"); "" = any
+	playerID uint32 // 0 = any
+}
+
+func (t announceTarget) label() string {
+	switch {
+	case t.playerID != 0:
+		return "player"
+	case t.zone != "":
+		return "zone"
+	}
+	return "global"
+}
+
+// announce sends an announcement to the target players and returns how many got it.
+// The message is encoded once per language.
+func (s *Server) announce(target announceTarget, severity uint8, texts localizedText) int {
+	zones := s.gameWorld.Zones()
+	encoded := make(map[string][]byte)
+	sent := 0
+
+	s.connectionsMu.RLock()
+	for id, c := range s.connections {
+		if target.playerID != 0 && id != target.playerID {
+			continue
+		}
+		if target.zone != "" && zones.Label(zones.Index(c.player.GetX(), c.player.GetY())) != target.zone {
+			continue
+		}
+		text := texts.pick(c.locale)
+		if text == "" {
+			continue
+		}
+		data, ok := encoded[text]
+		if !ok {
+			data = s.protocol.EncodeAnnounce(protocol.AnnounceMessage, severity, text)
+			encoded[text] = data
+		}
+		s.sendReliable(c, data, 0)
+		sent++
+	}
+	s.connectionsMu.RUnlock()
+
+	metrics.Announcements.WithLabelValues(target.label()).Inc()
+	return sent
+}
+
+// severityNames — значения параметра severity в /admin/announce.
+var severityNames = map[string]uint8{
+	"info":     protocol.SeverityInfo,
+	"warning":  protocol.SeverityWarning,
+	"critical": protocol.SeverityCritical,
+}
+
+// handleAdminAnnounce sends an announcement:
+//
+//	POST /admin/announce?text=T[&text.ru=T2...][&severity=info|warning|critical]
+//	     [&zone=r0c1 | &player=ID] → {"recipients": N}
+//
+// text.<lang> are translations; clients in other languages get text.
+func (s *Server) handleAdminAnnounce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	texts := localizedText{"": q.Get("text")}
+	for key, values := range q {
+		if tag, ok := strings.CutPrefix(key, "text."); ok {
+			locale := normalizeLocale(tag)
+			if locale == "" {
+				http.Error(w, "bad language in "+key, http.StatusBadRequest)
+				return
+			}
+			texts[locale] = values[0]
+		}
+	}
+	if texts[""] == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	severity := uint8(protocol.SeverityInfo)
+	if v := q.Get("severity"); v != "" {
+		var ok bool
+		if severity, ok = severityNames[v]; !ok {
+			http.Error(w, "severity must be info, warning or critical", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var target announceTarget
+	if v := q.Get("player"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || id == 0 {
+			http.Error(w, "player must be a player ID", http.StatusBadRequest)
+			return
+		}
+		target.playerID = uint32(id)
+	}
+	if target.zone = q.Get("zone"); target.zone != "" {
+		if s.gameWorld.Zones() == nil {
+			http.Error(w, "zones are off (METRICS_ZONE_COLS / METRICS_ZONE_ROWS)", http.StatusBadRequest)
+			return
+		}
+		if !s.gameWorld.Zones().HasLabel(target.zone) {
+			http.Error(w, "unknown zone "+target.zone, http.StatusBadRequest)
+			return
+		}
+	}
+
+	n := s.announce(target, severity, texts)
+	slog.Info("announcement sent", "target", target.label(), "zone", target.zone, "player_id", target.playerID,
+		"severity", q.Get("severity"), "recipients", n)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"recipients": n})
+}
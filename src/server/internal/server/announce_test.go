@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestNormalizeLocale(t *testing.T) {
+	for tag, want := range map[string]string{
+		"ru-RU": "ru", "pt_BR": "pt", "EN": "en", "de": "de",
+		"": "", "x": "", "r1": "", "averylongtag": "",
+	} {
+		if got := normalizeLocale(tag); got != want {
+			t.Errorf("normalizeLocale(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestLoadMOTD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "motd.json")
+	if err := os.WriteFile(path, []byte(`{"ru-RU": "Привет", "de": "Hallo"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	motd, err := loadMOTD("Hello", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for locale, want := range map[string]string{"ru": "Привет", "de": "Hallo", "fr": "Hello", "": "Hello"} {
+		if got := motd.pick(locale); got != want {
+			t.Errorf("pick(%q) = %q, want %q", locale, got, want)
+		}
+	}
+	if motd, err := loadMOTD("", ""); motd != nil || err != nil {
+		t.Errorf("empty config = %v, %v; want no MOTD", motd, err)
+	}
+}
+
+// announcements returns the ANNOUNCE texts written to fake so far.
+func announcements(t *testing.T, fake *testutil.FakeConn) []string {
+	t.Helper()
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, f := range frames {
+		msg := f.Payload[seqHeaderSize:]
+		if msg[0] == protocol.MessageAnnounce {
+			texts = append(texts, string(msg[protocol.LookupSchema(protocol.MessageAnnounce).Size(0):]))
+		}
+	}
+	return texts
+}
+
+func TestAnnounce(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.MOTD = "Welcome"
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	join := func(locale string) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.locale = locale
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	_, enFake := join("")
+	ru, ruFake := join("ru")
+
+	post := func(query url.Values) int {
+		rec := httptest.NewRecorder()
+		s.handleAdminAnnounce(rec, httptest.NewRequest(http.MethodPost, "/admin/announce?"+query.Encode(), nil))
+		return rec.Code
+	}
+	if code := post(url.Values{"text": {"Restart soon"}, "text.ru": {"Скоро рестарт"}, "severity": {"warning"}}); code != http.StatusOK {
+		t.Fatalf("global announcement: status %d", code)
+	}
+	if code := post(url.Values{"text": {"Only you"}, "player": {strconv.FormatUint(uint64(ru.player.ID), 10)}}); code != http.StatusOK {
+		t.Fatalf("player announcement: status %d", code)
+	}
+	for _, bad := range []url.Values{
+		{"severity": {"info"}},
+		{"text": {"x"}, "severity": {"loud"}},
+		{"text": {"x"}, "zone": {"nowhere"}},
+	} {
+		if code := post(bad); code != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want 400", bad, code)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(announcements(t, ruFake)) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := announcements(t, enFake); len(got) != 2 || got[0] != "Welcome" || got[1] != "Restart soon" {
+		t.Errorf("default-language client got %q", got)
+	}
+	if got := announcements(t, ruFake); len(got) != 3 || got[1] != "Скоро рестарт" || got[2] != "Only you" {
+		t.Errorf("ru client got %q", got)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/party"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Viewport (AOI) filtering for world-state broadcasts.
+//
+// Clients that never sent MessageViewportUpdate keep receiving the shared frame
+// encoded once per tick. Clients with a reported viewport get their own frame
+// containing only players inside their bounds (recomputed by the world on every
+// position change). A stationary player that enters someone's viewport only
+// because the viewer moved shows up on the next full sync or when it changes state —
+// unless the client streams cells (streaming.go), which are always filtered here.
+// Party members pass the filter wherever they are when Net.PartyAlwaysVisible is set
+// (party.go). In large worlds every client comes here, and nobody outside the window
+// of its ORIGIN passes, party members included (largeworld.go). With a per-client
+// tick budget, deltas over it are cut to the most relevant updates (interest.go).
+
+// aoiFilter — which players a client's own frame lets through.
+type aoiFilter struct {
+	bounds  types.ViewportBounds
+	window  types.ViewportBounds
+	spatial bool // bounds apply: a reported viewport, a cell stream or an ORIGIN window
+	large   bool // window applies as well (largeworld.go)
+	members *party.Party
+}
+
+func (f *aoiFilter) passes(st *types.PlayerState) bool {
+	return (!f.spatial || f.bounds.Contains(st.X, st.Y) || f.members.Has(st.ID)) &&
+		(!f.large || f.window.Contains(st.X, st.Y))
+}
+
+// splitViewportRecipients moves connections with a reported viewport, a cell stream, an
+// ORIGIN or deferred updates out of recipients into s.aoiConns — all of them when
+// overBudget, the shared delta being over Net.ClientTickBudget. Returns the remaining
+// shared-frame recipients. Runs on the gameLoop goroutine only (inside broadcastTick).
+func (s *Server) splitViewportRecipients(recipients []*Connection, overBudget bool) []*Connection {
+	s.aoiConns = s.aoiConns[:0]
+	shared := recipients[:0]
+	for _, conn := range recipients {
+		if _, ok := conn.player.GetViewport(); ok || overBudget || conn.cutting() || conn.stream != nil || conn.origin.Load() != nil {
+			s.aoiConns = append(s.aoiConns, conn)
+		} else {
+			shared = append(shared, conn)
+		}
+	}
+	return shared
+}
+
+// enqueueViewportFrames encodes and enqueues one viewport-filtered frame per
+// connection in s.aoiConns, honouring its capabilities (capabilities.go).
+// Returns the number of dropped enqueues.
+func (s *Server) enqueueViewportFrames(allPlayers, changed []types.PlayerState, fullSync bool, stateSequence uint32, sentAtNs int64) int {
+	dropped := 0
+	for i, conn := range s.aoiConns {
+		s.moveOrigin(conn)
+		full := fullSync || !conn.wantsDelta()
+		players := changed
+		if full {
+			players = allPlayers
+		}
+		var filter aoiFilter
+		filter.bounds, filter.spatial = conn.player.GetViewport()
+		if conn.stream != nil {
+			filter.bounds, filter.spatial = s.streamCells(conn, allPlayers, stateSequence), true
+		}
+		filter.window, filter.large = originWindow(conn)
+		if !filter.spatial && filter.large {
+			filter.bounds, filter.spatial = filter.window, true
+		}
+		filter.members = s.visibleParty(conn)
+		s.aoiScratch = s.aoiScratch[:0]
+		for i := range players {
+			if filter.passes(&players[i]) {
+				s.aoiScratch = append(s.aoiScratch, players[i])
+			}
+		}
+		metrics.ViewportFilteredPlayers.Observe(float64(len(players) - len(s.aoiScratch)))
+		if s.cfg.Net.ClientTickBudget > 0 {
+			if !full {
+				s.prioritize(conn, &filter, allPlayers, stateSequence, sentAtNs)
+			} else if conn.interest != nil {
+				conn.interest.sentAll(s.aoiScratch, sentAtNs)
+			}
+		}
+
+		// An empty delta carries no information; a full sync is always sent so the
+		// client drops players that are no longer visible.
+		if !full && len(s.aoiScratch) == 0 {
+			s.aoiConns[i] = nil
+			continue
+		}
+		if full && !fullSync {
+			metrics.FullStateFallbacks.Inc()
+		}
+
+		if !conn.fitsMessage(protocol.WorldStateSize(len(s.aoiScratch))) {
+			if !s.sendWorldStateChunks(conn, s.aoiScratch, full, stateSequence, sentAtNs) {
+				dropped++
+			}
+			s.aoiConns[i] = nil
+			continue
+		}
+
+		f := s.newWorldStateFrame(s.protocolFor(conn), s.aoiScratch, full, stateSequence, conn.compressMin > 0)
+		atomic.StoreInt32(&f.refs, 1)
+
+		if !s.enqueueBroadcastJob(conn, f, sentAtNs) {
+			dropped++
+		}
+		s.aoiConns[i] = nil
+	}
+	s.aoiConns = s.aoiConns[:0]
+	return dropped
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Per-connection bandwidth budget (Net.ClientBandwidthCap).
+//
+// The write loop measures bytes written per connection over bandwidthWindow. A client
+// above the cap is moved one update tier up; tier k receives world-state deltas only on
+// every 2^k-th tick (staggered by player ID). Full syncs, ACKs and join/leave events are
+// never decimated, so a throttled client still converges — it just sees fewer
+// intermediate positions instead of losing random messages to a full writeCh.
+// A tier is relaxed once the measured rate would stay under the cap at the lower tier.
+
+const (
+	bandwidthWindow = time.Second
+	maxUpdateTier   = 3 // every 8th delta: ~4 Hz at 30 Hz ticks
+)
+
+// updateTierLabels — metric label per tier, precomputed to keep the write loop alloc-free.
+var updateTierLabels = [maxUpdateTier + 1]string{"0", "1", "2", "3"}
+
+// accountBandwidth adds n written bytes to c's window and re-evaluates its update tier
+// when the window closes. Write loop only.
+func (s *Server) accountBandwidth(c *Connection, n int64, nowNs int64) {
+	c.bwWindowBytes += n
+	atomic.AddInt64(&c.bytesOut, n)
+	s.usage.bytesOut.Add(n)
+	if c.bwWindowStartNs == 0 {
+		c.bwWindowStartNs = nowNs
+		return
+	}
+	elapsed := nowNs - c.bwWindowStartNs
+	if elapsed < bandwidthWindow.Nanoseconds() {
+		return
+	}
+
+	rate := c.bwWindowBytes * int64(time.Second) / elapsed
+	if atomic.LoadInt32(&c.state) == connJoined {
+		s.gameWorld.Zones().AddBytesSent(c.player.GetX(), c.player.GetY(), c.bwWindowBytes)
+	}
+	c.bwWindowBytes = 0
+	c.bwWindowStartNs = nowNs
+	metrics.ClientOutboundBytesPerSec.Observe(float64(rate))
+
+	limit := s.clientBandwidthCap
+	if limit <= 0 {
+		return
+	}
+	tier := atomic.LoadInt32(&c.updateTier)
+	next := tier
+	switch {
+	case rate > limit && tier < maxUpdateTier:
+		next = tier + 1
+	case rate*2 < limit && tier > 0:
+		// Dropping a tier roughly doubles the delta rate.
+		next = tier - 1
+	}
+	if next == tier {
+		return
+	}
+	s.setUpdateTier(c, tier, next)
+	if next > tier {
+		metrics.ClientUpdateTierChanges.WithLabelValues("throttle").Inc()
+	} else {
+		metrics.ClientUpdateTierChanges.WithLabelValues("relax").Inc()
+	}
+}
+
+// setUpdateTier moves c from tier prev to next and keeps the per-tier gauge in sync.
+func (s *Server) setUpdateTier(c *Connection, prev, next int32) {
+	atomic.StoreInt32(&c.updateTier, next)
+	metrics.ClientsByUpdateTier.WithLabelValues(updateTierLabels[prev]).Dec()
+	metrics.ClientsByUpdateTier.WithLabelValues(updateTierLabels[next]).Inc()
+}
+
+// skipDelta reports whether c's update tier skips the delta broadcast of tick.
+func skipDelta(c *Connection, tick uint32) bool {
+	tier := atomic.LoadInt32(&c.updateTier)
+	if tier == 0 {
+		return false
+	}
+	mask := uint32(1)<<tier - 1
+	return (tick+c.player.ID)&mask != 0
+}
+
+// releaseUpdateTier removes c from the per-tier gauge when its write loop exits.
+func releaseUpdateTier(c *Connection) {
+	tier := atomic.SwapInt32(&c.updateTier, 0)
+	metrics.ClientsByUpdateTier.WithLabelValues(updateTierLabels[tier]).Dec()
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"container/heap"
+	"encoding/binary"
+	"errors"
 	"log/slog"
 	"net"
 	"sync"
@@ -10,57 +12,88 @@ import (
 
 	"github.com/gobwas/ws"
 
+	"pixi_game_server/internal/game"
 	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
 	"pixi_game_server/internal/types"
 )
 
-// wsFrameSlice fills the 10-byte WS binary frame header into the start of slot
-// and returns the sub-slice [headerStart:] containing header+payload.
-// slot layout: [10 reserved bytes][payload bytes].
-// No allocation: returns a slice into slot's existing backing array.
-func wsFrameSlice(slot []byte) []byte {
-	payloadLen := len(slot) - 10
+// Outbound framing: every server → client data message is written as one WS binary
+// frame [WS header][seq u32 LE][payload]. seq is a per-connection outbound sequence
+// stamped by the write loop (see sequence.go); payloads stay shared between connections.
+// Connections with checksums get [seq][crc u32 LE][payload] (protocol/checksum.go).
+const (
+	seqHeaderSize      = 4
+	maxFrameHeaderSize = 10 + deflatePrefixSize + seqHeaderSize + protocol.ChecksumSize
+)
+
+// dataHeaderSize — bytes between the WS header and the payload: seq, plus the CRC
+// when checksum is set.
+func dataHeaderSize(checksum bool) int {
+	if checksum {
+		return seqHeaderSize + protocol.ChecksumSize
+	}
+	return seqHeaderSize
+}
+
+// appendFrameHeader appends the WS binary frame header for a payload of payloadLen
+// bytes (plus the data header) followed by seq itself and, if checksum is set, sum.
+func appendFrameHeader(dst []byte, payloadLen int, seq uint32, checksum bool, sum uint32) []byte {
+	dst = appendWSHeader(dst, 0x82, payloadLen+dataHeaderSize(checksum)) // FIN + binary opcode
+	return appendDataHeader(dst, seq, checksum, sum)
+}
+
+// appendDataHeader appends seq and, if checksum is set, sum.
+func appendDataHeader(dst []byte, seq uint32, checksum bool, sum uint32) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, seq)
+	if checksum {
+		dst = binary.LittleEndian.AppendUint32(dst, sum)
+	}
+	return dst
+}
+
+// appendWSHeader appends an unmasked WS frame header: first byte b0, payload length n.
+func appendWSHeader(dst []byte, b0 byte, n int) []byte {
 	switch {
-	case payloadLen < 126:
-		slot[8] = 0x82 // FIN + binary opcode
-		slot[9] = byte(payloadLen)
-		return slot[8:]
-	case payloadLen <= 65535:
-		slot[6] = 0x82 // FIN + binary opcode
-		slot[7] = 0x7E // extended 16-bit length
-		slot[8] = byte(payloadLen >> 8)
-		slot[9] = byte(payloadLen)
-		return slot[6:]
+	case n < 126:
+		return append(dst, b0, byte(n)) // 7-bit length
+	case n <= 65535:
+		return append(dst, b0, 0x7E, byte(n>>8), byte(n)) // extended 16-bit length
 	default:
-		slot[0] = 0x82 // FIN + binary opcode
-		slot[1] = 0x7F // extended 64-bit length
-		slot[2] = byte(payloadLen >> 56)
-		slot[3] = byte(payloadLen >> 48)
-		slot[4] = byte(payloadLen >> 40)
-		slot[5] = byte(payloadLen >> 32)
-		slot[6] = byte(payloadLen >> 24)
-		slot[7] = byte(payloadLen >> 16)
-		slot[8] = byte(payloadLen >> 8)
-		slot[9] = byte(payloadLen)
-		return slot
+		return append(dst, b0, 0x7F, // extended 64-bit length
+			byte(uint64(n)>>56), byte(uint64(n)>>48), byte(uint64(n)>>40), byte(uint64(n)>>32),
+			byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
 	}
 }
 
 // tickFrame — reference-counted broadcast frame buffer obtained from broadcastFramePool.
 // broadcastTick fills it once per tick; each shard calls release() after writing its connections.
 // When the last shard releases (refs reaches 0), the buffer returns to the pool.
+// Event broadcasts share one the same way (prepareFrame).
 // This replaces the ring buffer which had an unsafe data race: shards held slices into the
 // ring slot's backing array while broadcastTick could overwrite it 32 ticks later.
 type tickFrame struct {
-	data  []byte // pre-allocated: [10 WS header prefix bytes][payload bytes]
-	frame []byte // actual WS frame bytes to write: sub-slice of data
-	refs  int32  // atomic countdown; when 0 → return to pool
+	data     []byte // encoded message payload (WS header + seq are added per connection)
+	deflated []byte // data compressed for permessage-deflate connections; empty = not compressed
+	refs     int32  // atomic countdown; when 0 → return to pool
+	sum      uint64 // 1<<32 | CRC-32C of data once a checksum connection asked; 0 = not yet (atomic)
+}
+
+// checksum returns the CRC-32C of f.data, computed by the first write loop that needs it.
+func (f *tickFrame) checksum() uint32 {
+	if v := atomic.LoadUint64(&f.sum); v != 0 {
+		return uint32(v)
+	}
+	sum := protocol.Checksum(f.data)
+	atomic.StoreUint64(&f.sum, 1<<32|uint64(sum)) // racing loops store the same value
+	return sum
 }
 
 func (f *tickFrame) release() {
 	if atomic.AddInt32(&f.refs, -1) == 0 {
 		f.data = f.data[:0]
-		f.frame = nil
+		f.deflated = f.deflated[:0]
+		atomic.StoreUint64(&f.sum, 0)
 		broadcastFramePool.Put(f)
 	}
 }
@@ -110,20 +143,21 @@ func (h *topKMinHeap) Pop() any {
 	return item
 }
 
-// Write timeouts.
+// Write timeouts. The values below are fallbacks for non-positive NetworkConfig
+// settings (BROADCAST_WRITE_TIMEOUT_MS, DIRECT_WRITE_TIMEOUT_MS, WRITE_MAX_FAILURES).
 const (
-	// broadcastWriteTimeout — per-connection deadline during mass-write.
+	// defaultBroadcastWriteTimeout — per-connection deadline during mass-write.
 	// 100ms = 3× tick budget (33ms). A goroutine parks via Go netpoller waiting
 	// for TCP window; if the client can't accept data within 100ms it is dead.
-	broadcastWriteTimeout = 100 * time.Millisecond
+	defaultBroadcastWriteTimeout = 100 * time.Millisecond
 
-	// directWriteTimeout — deadline for ACK, pong, initial-state writes.
-	directWriteTimeout = 30 * time.Millisecond
+	// defaultDirectWriteTimeout — deadline for ACK, pong, initial-state writes.
+	defaultDirectWriteTimeout = 30 * time.Millisecond
 
-	// maxWriteFailures — consecutive write failures before declaring a connection dead.
+	// defaultMaxWriteFailures — consecutive write failures before declaring a connection dead.
 	// At 30 Hz ticks with broadcastWriteTimeout=100ms: 150 × 100ms = 15s of sustained
 	// inability to write before disconnect.
-	maxWriteFailures = 150
+	defaultMaxWriteFailures = 150
 
 	// writeChanSize — per-connection channel buffer depth.
 	// 32 slots × 33ms/tick ≈ 1s of broadcast frames before dropping.
@@ -131,20 +165,29 @@ const (
 	// so the channel will not fill under normal load.
 	writeChanSize = 32
 
-	// maxWriteBatchSizeLimit clamps WRITE_BATCH_SIZE from env.
+	// maxWriteBatchSizeLimit clamps the batch sizes from env (WRITE_BATCH_*_SIZE).
 	maxWriteBatchSizeLimit = 64
 )
 
 // writeJob is the value type sent over Connection.writeCh.
 // Using a value type (not a closure) eliminates one heap allocation per broadcast per connection.
 //
-//   - Broadcast tick:  frame != nil, direct == nil. Write loop writes frame.frame,
-//     then calls frame.release() to decrement the ref-count.
-//   - Direct write:    frame == nil, direct != nil. Write loop writes direct bytes.
+//   - Broadcast tick:  frame != nil. Write loop frames frame.data with this
+//     connection's sequence, then calls frame.release() to decrement the ref-count.
+//     Prepared events (prepareFrame) too, with class event instead of state.
+//   - Direct write:    direct != nil. Message payload (ACK, initial state, join/leave),
+//     framed and sequenced like a broadcast.
+//   - Control frame:   control != nil. Pre-compiled ping/pong, written as-is (no sequence).
+//     With closeAfter set it is a close frame: the write loop writes it as the last
+//     frame and closes the connection (see closeConnection).
 type writeJob struct {
-	frame   *tickFrame // non-nil for broadcast (shared, ref-counted)
-	direct  []byte     // non-nil for ACK / pong / initial-state
-	timeout time.Duration
+	frame      *tickFrame // non-nil for broadcast (shared, ref-counted)
+	direct     []byte     // non-nil for ACK / initial-state / join-leave payloads
+	control    []byte     // non-nil for ping / pong / close
+	timeout    time.Duration
+	closeAfter bool       // close the connection once this job is written
+	class      writeClass // batching class (writebatch.go); zero = event, never delayed
+	dedupe     uint64     // latest-wins key (dedupe.go); 0 = always written
 }
 
 type fanoutJob struct {
@@ -178,16 +221,28 @@ func (s *Server) runFanoutWorker() {
 		case <-s.ctx.Done():
 			return
 		case job := <-s.fanoutJobs:
-			localDropped := 0
-			for _, conn := range job.conns {
-				if !s.enqueueBroadcastJob(conn, job.frame, job.sentAtNs) {
-					localDropped++
-				}
-			}
-			if localDropped > 0 {
-				atomic.AddInt64(job.dropped, int64(localDropped))
-			}
-			job.wg.Done()
+			s.runFanoutJob(job)
+		}
+	}
+}
+
+// runFanoutJob enqueues job's frame for its connections. A panic drops the connection
+// it happened on and the rest of the batch (recovery.go); the tick still completes.
+func (s *Server) runFanoutJob(job fanoutJob) {
+	localDropped := 0
+	var conn *Connection
+	defer func() {
+		if p := recover(); p != nil {
+			s.connPanic(conn, "fanout", p)
+		}
+		if localDropped > 0 {
+			atomic.AddInt64(job.dropped, int64(localDropped))
+		}
+		job.wg.Done()
+	}()
+	for _, conn = range job.conns {
+		if !s.enqueueBroadcastJob(conn, job.frame, job.sentAtNs) {
+			localDropped++
 		}
 	}
 }
@@ -213,7 +268,7 @@ func (s *Server) enqueueBroadcastJob(conn *Connection, frame *tickFrame, sentAtN
 	}
 
 	select {
-	case conn.writeCh <- writeJob{frame: frame, timeout: broadcastWriteTimeout}:
+	case conn.writeCh <- writeJob{frame: frame, timeout: s.broadcastWriteTimeout, class: writeClassState}:
 		atomic.StoreInt64(&conn.lastWorldStateSentNs, sentAtNs)
 		if atomic.LoadInt32(&conn.fanoutDrops) != 0 {
 			atomic.StoreInt32(&conn.fanoutDrops, 0)
@@ -222,8 +277,9 @@ func (s *Server) enqueueBroadcastJob(conn *Connection, frame *tickFrame, sentAtN
 	default:
 		atomic.StoreInt32(&conn.pendingBroadcast, 0)
 		frame.release()
-		metrics.BroadcastsDropped.Inc()
+		s.noteDrop(conn)
 		if atomic.AddInt32(&conn.fanoutDrops, 1) == s.fanoutDropLimit {
+			conn.noteDisconnect(causeSlowConsumer)
 			go s.cleanupConnection(conn)
 		}
 		return false
@@ -249,66 +305,128 @@ func (s *Server) enqueueBroadcastJob(conn *Connection, frame *tickFrame, sentAtN
 // long-lived. GC only scans these stacks during STW — it does not create/destroy them.
 func (s *Server) startWriteLoop(c *Connection) {
 	go func() {
-		batchSize := s.writeBatchSize
-		if batchSize < 1 {
-			batchSize = 1
-		} else if batchSize > maxWriteBatchSizeLimit {
-			batchSize = maxWriteBatchSizeLimit
+		var own *writeScratch // without Net.WriteBufferPool the batch scratch stays with the loop (connbuf.go)
+		if !s.cfg.Net.WriteBufferPool {
+			own = newWriteScratch(s.writeBatchSize)
 		}
-
-		jobs := make([]writeJob, batchSize)
-		frames := make([][]byte, batchSize)
+		batch := writeBatch{limits: &s.writeBatches}
+		waits := s.writeBatches.waits()
+		var timer *time.Timer // created on the first wait (writebatch.go)
+
+		metrics.ClientsByUpdateTier.WithLabelValues(updateTierLabels[0]).Inc()
+		defer releaseUpdateTier(c)
+		defer func() {
+			if p := recover(); p != nil {
+				s.connPanic(c, "write", p)
+			}
+		}()
 
 		for {
 			select {
 			case first := <-c.writeCh:
-				jobs[0] = first
-				if first.frame != nil {
-					frames[0] = first.frame.frame
-				} else {
-					frames[0] = first.direct
+				sc := own
+				if sc == nil {
+					sc = s.getWriteScratch()
 				}
-
+				jobs, frames := sc.jobs, sc.frames
+				var nowNs int64
+				if waits {
+					nowNs = time.Now().UnixNano()
+				}
+				jobs[0] = first
 				count := 1
-				maxTimeout := first.timeout
-				for count < batchSize {
+				closing := first.closeAfter
+				batch.reset()
+				flush := batch.add(first.class, nowNs)
+				for count < len(jobs) && !closing {
+					var job writeJob
 					select {
-					case job := <-c.writeCh:
-						jobs[count] = job
-						if job.frame != nil {
-							frames[count] = job.frame.frame
+					case job = <-c.writeCh:
+					default:
+						// Queue is empty: write now, or wait for more while every held
+						// class is within its batch timeout.
+						if flush || !waits {
+							goto writeBatch
+						}
+						wait := batch.wait(time.Now().UnixNano())
+						if wait <= 0 {
+							goto writeBatch
+						}
+						if timer == nil {
+							timer = time.NewTimer(wait)
 						} else {
-							frames[count] = job.direct
+							timer.Reset(wait)
 						}
-						if job.timeout > maxTimeout {
-							maxTimeout = job.timeout
+						select {
+						case job = <-c.writeCh:
+							timer.Stop()
+						case <-timer.C:
+							goto writeBatch
+						case <-c.ctx.Done():
+							releaseJobs(jobs[:count])
+							drainWriteCh(c.writeCh)
+							return
 						}
-						count++
-					default:
-						goto writeBatch
 					}
+					if waits {
+						nowNs = time.Now().UnixNano()
+					}
+					jobs[count] = job
+					count++
+					closing = job.closeAfter
+					flush = batch.add(job.class, nowNs) || flush
 				}
 
 			writeBatch:
+				if s.cfg.Net.OutboundDedupe {
+					count = dedupeJobs(jobs[:count])
+				}
+				maxTimeout := jobs[0].timeout
+				for i := 1; i < count; i++ {
+					maxTimeout = max(maxTimeout, jobs[i].timeout)
+				}
 				writeStart := time.Now()
-				c.rawConn.SetWriteDeadline(time.Now().Add(maxTimeout))
-				buffers := net.Buffers(frames[:count])
-				n, err := buffers.WriteTo(c.rawConn)
+				frames = c.appendJobFrames(frames[:0], sc.headers, jobs[:count])
+				buffers := net.Buffers(frames)
+				total := buffersLen(buffers)
+				if s.cfg.Net.WriteCoalesceBytes > 0 && len(frames) > 2 && total <= int64(s.cfg.Net.WriteCoalesceBytes) {
+					sc.flat = coalesceFrames(sc.flat[:0], frames)
+					buffers = net.Buffers{sc.flat}
+					metrics.WSWritesCoalesced.Inc()
+				}
+				n, err := s.writeBuffers(c, &buffers, maxTimeout)
 				metrics.WSWriteBatchDuration.Observe(time.Since(writeStart).Seconds())
 				metrics.WSWriteBatchJobs.Observe(float64(count))
 
 				for i := 0; i < count; i++ {
 					if jobs[i].frame != nil {
-						atomic.StoreInt32(&c.pendingBroadcast, 0)
+						if jobs[i].class == writeClassState {
+							atomic.StoreInt32(&c.pendingBroadcast, 0)
+						}
 						jobs[i].frame.release()
 					}
-					frames[i] = nil
 					jobs[i] = writeJob{}
 				}
+				clear(frames)
+				sc.frames = frames[:0]
+				if own == nil {
+					s.putWriteScratch(sc)
+				}
 
 				if err != nil {
 					metrics.WSWriteErrors.Inc()
-					if atomic.AddInt32(&c.writeFailures, 1) >= maxWriteFailures {
+					// A frame cut in the middle desynchronises the client's WS parser:
+					// the next frame would be read as the tail of this one. Close instead.
+					torn := n > 0 && n < total
+					if torn {
+						metrics.WSPartialWrites.Inc()
+					}
+					if torn || atomic.AddInt32(&c.writeFailures, 1) >= s.maxWriteFailures {
+						if isTimeoutErr(err) {
+							c.noteDisconnect(causeWriteTimeout)
+						} else {
+							c.noteDisconnect(causeWriteError)
+						}
 						go s.cleanupConnection(c)
 						// Drain any tickFrame refs that are already buffered before
 						// exiting. cleanupConnection will drain whatever arrives after
@@ -319,6 +437,13 @@ func (s *Server) startWriteLoop(c *Connection) {
 				} else {
 					atomic.StoreInt32(&c.writeFailures, 0)
 					metrics.BytesSent.Add(float64(n))
+					s.accountBandwidth(c, n, writeStart.UnixNano())
+				}
+				if closing {
+					// The close frame is out (or could not be written): nothing may follow it.
+					go s.cleanupConnection(c)
+					drainWriteCh(c.writeCh)
+					return
 				}
 
 			case <-c.ctx.Done():
@@ -331,6 +456,45 @@ func (s *Server) startWriteLoop(c *Connection) {
 	}()
 }
 
+// writeBuffers writes buffers with the given deadline. WriteTo consumes buffers as it
+// goes, so on a transient timeout the remaining bytes (possibly the tail of a
+// partially written frame) are retried once with a fresh deadline when
+// Net.WriteRetryOnTimeout is set. Returns the total bytes written.
+func (s *Server) writeBuffers(c *Connection, buffers *net.Buffers, timeout time.Duration) (int64, error) {
+	c.rawConn.SetWriteDeadline(time.Now().Add(timeout))
+	n, err := buffers.WriteTo(c.rawConn)
+	if err == nil || !s.cfg.Net.WriteRetryOnTimeout || !isTimeoutErr(err) {
+		return n, err
+	}
+	metrics.WSWriteRetries.Inc()
+	c.rawConn.SetWriteDeadline(time.Now().Add(timeout))
+	m, err := buffers.WriteTo(c.rawConn)
+	return n + m, err
+}
+
+func buffersLen(b net.Buffers) int64 {
+	var n int64
+	for _, buf := range b {
+		n += int64(len(buf))
+	}
+	return n
+}
+
+// isTimeoutErr reports whether err is a deadline expiry (transient stall, not a dead socket).
+func isTimeoutErr(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// releaseJobs releases the tickFrame refs of jobs the write loop took but will not write.
+func releaseJobs(jobs []writeJob) {
+	for i := range jobs {
+		if jobs[i].frame != nil {
+			jobs[i].frame.release()
+		}
+	}
+}
+
 // drainWriteCh releases all tickFrame refs currently buffered in ch and discards
 // direct-write jobs (their frameBytes are owned by the caller, not the pool).
 // Must be called after the write-loop goroutine has decided to exit so that
@@ -607,15 +771,12 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 	stateSequence := atomic.AddUint32(&s.worldStateSeq, 1)
 	f := broadcastFramePool.Get().(*tickFrame)
 	f.data = f.data[:0]
-	// Reserve 10 bytes at front for the WS binary frame header (filled by wsFrameSlice below).
-	f.data = append(f.data, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
 	if fullSync {
 		f.data = s.protocol.AppendGameState(f.data, allPlayers, stateSequence)
 	} else {
 		f.data = s.protocol.AppendDeltaGameState(f.data, changed, stateSequence)
 	}
-	f.frame = wsFrameSlice(f.data)
-	if payloadSize := len(f.data) - 10; payloadSize > 0 {
+	if payloadSize := len(f.data); payloadSize > 0 {
 		metrics.BroadcastPayloadBytes.Observe(float64(payloadSize))
 	}
 	metrics.TickPhaseDuration.WithLabelValues("encode").Observe(time.Since(t0).Seconds())
@@ -629,7 +790,6 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 	if n == 0 {
 		s.connectionsMu.RUnlock()
 		f.data = f.data[:0]
-		f.frame = nil
 		broadcastFramePool.Put(f)
 		return
 	}
@@ -638,11 +798,21 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 	if cap(conns) < n {
 		conns = make([]*Connection, 0, n)
 	}
+	decimated := 0
 	for _, conn := range s.connections {
+		// Bandwidth-throttled clients only get every 2^tier-th delta (bandwidth.go).
+		if !fullSync && skipDelta(conn, stateSequence) {
+			decimated++
+			continue
+		}
 		conns = append(conns, conn)
 	}
 	metrics.BroadcastTargets.Observe(float64(n))
 	s.connectionsMu.RUnlock()
+	if decimated > 0 {
+		metrics.BroadcastsDecimated.Add(float64(decimated))
+	}
+	n = len(conns)
 
 	selectStart := time.Now()
 	recipients, overdue := s.selectRecipients(conns, sentAtNs)
@@ -659,13 +829,12 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 		connectionSlicePool.Put(buf)
 
 		f.data = f.data[:0]
-		f.frame = nil
 		broadcastFramePool.Put(f)
 		return
 	}
 
 	if budgetBytes := s.fanoutMaxBroadcastBytesPerTick; budgetBytes > 0 {
-		frameBytes := len(f.frame)
+		frameBytes := len(f.data) + maxFrameHeaderSize
 		if frameBytes > 0 {
 			budgetRecipients := budgetBytes / frameBytes
 			if budgetRecipients < 1 {
@@ -691,29 +860,57 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 		metrics.BroadcastDeferred.Add(float64(deferred))
 	}
 
-	atomic.StoreInt32(&f.refs, int32(m))
+	payloadBytes := len(f.data)
+
+	// Recipients with a reported viewport get their own filtered frame (aoi.go);
+	// the rest share the frame encoded above.
+	overBudget := !fullSync && s.cfg.Net.ClientTickBudget > 0 && payloadBytes > s.cfg.Net.ClientTickBudget
+	shared := s.splitViewportRecipients(recipients, overBudget)
+	// Clients without delta support or with a smaller message limit get their own
+	// frames too (capabilities.go).
+	shared, compress := s.splitCapabilityRecipients(shared, fullSync, payloadBytes)
+	ms := len(shared)
 
 	enqueueStart := time.Now()
 	dropped := 0
-	if s.fanoutWorkers <= 1 || m < s.fanoutWorkers*64 {
-		for _, conn := range recipients {
+	if len(s.aoiConns) > 0 {
+		dropped += s.enqueueViewportFrames(allPlayers, changed, fullSync, stateSequence, sentAtNs)
+	}
+	if len(s.capConns) > 0 {
+		dropped += s.enqueueCapabilityFrames(allPlayers, changed, fullSync, stateSequence, sentAtNs)
+	}
+
+	if ms > 0 {
+		if compress {
+			s.deflateFrame(f)
+		}
+		atomic.StoreInt32(&f.refs, int32(ms))
+	}
+
+	switch {
+	case ms == 0:
+		// Every recipient got a viewport frame — the shared one goes straight back to the pool.
+		f.data = f.data[:0]
+		broadcastFramePool.Put(f)
+	case s.fanoutWorkers <= 1 || ms < s.fanoutWorkers*64:
+		for _, conn := range shared {
 			if !s.enqueueBroadcastJob(conn, f, sentAtNs) {
 				dropped++
 			}
 		}
-	} else {
-		chunkSize := (m + s.fanoutWorkers - 1) / s.fanoutWorkers
+	default:
+		chunkSize := (ms + s.fanoutWorkers - 1) / s.fanoutWorkers
 		var wg sync.WaitGroup
 		var droppedAtomic int64
 
-		for start := 0; start < m; start += chunkSize {
+		for start := 0; start < ms; start += chunkSize {
 			end := start + chunkSize
-			if end > m {
-				end = m
+			if end > ms {
+				end = ms
 			}
 			wg.Add(1)
 			s.fanoutJobs <- fanoutJob{
-				conns:    recipients[start:end],
+				conns:    shared[start:end],
 				frame:    f,
 				sentAtNs: sentAtNs,
 				dropped:  &droppedAtomic,
@@ -721,7 +918,7 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 			}
 		}
 		wg.Wait()
-		dropped = int(atomic.LoadInt64(&droppedAtomic))
+		dropped += int(atomic.LoadInt64(&droppedAtomic))
 	}
 	enqueueDur := time.Since(enqueueStart)
 	metrics.TickFanoutEnqueueDuration.Observe(enqueueDur.Seconds())
@@ -778,7 +975,7 @@ func (s *Server) broadcastTick(allPlayers []types.PlayerState, changed []types.P
 				"duration_ms", fanoutDur.Milliseconds(),
 				"connections", n,
 				"dropped_jobs", dropped,
-				"payload_bytes", len(f.data)-10,
+				"payload_bytes", payloadBytes,
 				"full_sync", fullSync,
 				"changed_players", len(changed),
 				"all_players", len(allPlayers))
@@ -800,99 +997,215 @@ func maxDuration(a, b time.Duration) time.Duration {
 	return b
 }
 
-// broadcastEvent sends a pre-compiled WS frame to every connected client.
-// Used for join/left notifications. push() returns immediately (non-blocking).
-func (s *Server) broadcastEvent(frameBytes []byte) {
+// broadcastEvent sends one message payload to every connected client.
+// Used for chat, world events and maintenance notices; never blocks.
+func (s *Server) broadcastEvent(data []byte) {
+	f := s.prepareFrame(data)
 	s.connectionsMu.RLock()
 	for _, conn := range s.connections {
-		select {
-		case conn.writeCh <- writeJob{direct: frameBytes, timeout: directWriteTimeout}:
-		default:
-			metrics.BroadcastsDropped.Inc()
-		}
+		s.sendPrepared(conn, f)
 	}
 	s.connectionsMu.RUnlock()
+	f.release()
+}
+
+// prepareFrame copies a message that goes unmodified to many clients into a pooled
+// frame, deflated once when compression is on; its CRC is computed by the first write
+// loop that needs it. Every recipient's job shares the frame like a tick broadcast,
+// so only the WS header and the sequence are built per connection (and the payload
+// sealed, for a sealed type). The frame holds one reference for the caller: release
+// it once every sendPrepared is done.
+func (s *Server) prepareFrame(data []byte) *tickFrame {
+	f := broadcastFramePool.Get().(*tickFrame)
+	f.data = append(f.data[:0], data...)
+	if s.cfg.Net.WSCompression {
+		s.deflateFrame(f)
+	}
+	atomic.StoreInt32(&f.refs, 1)
+	return f
+}
+
+// sendPrepared enqueues a frame from prepareFrame on conn's write loop.
+func (s *Server) sendPrepared(conn *Connection, f *tickFrame) {
+	atomic.AddInt32(&f.refs, 1)
+	select {
+	case conn.writeCh <- writeJob{frame: f, timeout: s.directWriteTimeout}:
+	default:
+		f.release()
+		s.noteDrop(conn)
+	}
 }
 
 // ── Per-connection sends ──────────────────────────────────────────────────────
 
 // sendInitialState sends the full game state to a newly connected client.
-// Uses the broadcast frame pool + wsFrameSlice to avoid intermediate allocations:
-// eliminates the AppendGameState nil-dst alloc and the ws.CompileFrame alloc.
-// Remaining allocs: GetAllPlayers ([]PlayerState) + the final frame copy.
+// Players come from the per-tick world snapshot (no live-state iteration); the only
+// alloc is the encoded payload.
 func (s *Server) sendInitialState(conn *Connection) {
-	allPlayers := s.gameWorld.GetAllPlayers()
+	if conn.stream != nil {
+		// The rest of the world arrives cell by cell with the first ticks (streaming.go).
+		data := s.protocolFor(conn).AppendGameState(nil, []types.PlayerState{conn.player.ToState()}, atomic.LoadUint32(&s.worldStateSeq))
+		s.sendDirect(conn, data)
+		atomic.StoreInt64(&conn.lastWorldStateSentNs, time.Now().UnixNano())
+		return
+	}
+	snap := s.gameWorld.AcquireSnapshot()
+	defer snap.Release()
+
+	// The snapshot usually predates this player's join. The client learns its own ID
+	// from CONFIG (older clients take the highest player ID in their first
+	// GAME_STATE), so the newcomer must be present.
+	players := windowPlayers(conn, snap.Players)
+	var self []types.PlayerState
+	if !snapshotHasPlayer(players, conn.player.ID) {
+		self = []types.PlayerState{conn.player.ToState()}
+	}
 
-	// Borrow a pooled 64 KB buffer — same pool used by broadcastTick.
-	f := broadcastFramePool.Get().(*tickFrame)
-	f.data = f.data[:0]
-	f.data = append(f.data, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0) // reserve 10-byte WS header
 	seq := atomic.LoadUint32(&s.worldStateSeq)
-	f.data = s.protocol.AppendGameState(f.data, allPlayers, seq) // zero-alloc into pool buf
-	frame := wsFrameSlice(f.data)                                // zero-alloc sub-slice
+	data := s.protocolFor(conn).AppendGameStateParts(nil, seq, players, self)
+	chunks := [][]byte{data}
+	if !conn.fitsMessage(len(data)) {
+		chunks = s.worldStateChunks(conn, selfFirst(conn.player, players), true, seq)
+	}
 
-	// Copy frame bytes before returning pool buffer: write loop reads them later.
-	frameBytes := make([]byte, len(frame))
-	copy(frameBytes, frame)
+	for _, data := range chunks {
+		select {
+		case conn.writeCh <- writeJob{direct: data, timeout: s.directWriteTimeout}:
+			atomic.StoreInt64(&conn.lastWorldStateSentNs, time.Now().UnixNano())
+		default:
+			s.noteDrop(conn)
+		}
+	}
+}
 
-	f.data = f.data[:0]
-	f.frame = nil
-	broadcastFramePool.Put(f)
+// selfFirst returns players with self moved to the front, so a chunked initial state
+// still carries the client's own player in its first GAME_STATE.
+func selfFirst(self *types.Player, players []types.PlayerState) []types.PlayerState {
+	out := make([]types.PlayerState, 1, len(players)+1)
+	out[0] = self.ToState()
+	for i := range players {
+		if players[i].ID != self.ID {
+			out = append(out, players[i])
+		}
+	}
+	return out
+}
 
-	select {
-	case conn.writeCh <- writeJob{direct: frameBytes, timeout: directWriteTimeout}:
-		atomic.StoreInt64(&conn.lastWorldStateSentNs, time.Now().UnixNano())
-	default:
-		metrics.BroadcastsDropped.Inc()
+// snapshotHasPlayer reports whether players contains playerID.
+func snapshotHasPlayer(players []types.PlayerState, playerID uint32) bool {
+	for i := range players {
+		if players[i].ID == playerID {
+			return true
+		}
 	}
+	return false
 }
 
-// sendDirect wraps data in a WS binary frame and enqueues it on conn's writeQueue.
+// sendDirect enqueues one message payload on conn's write loop.
 func (s *Server) sendDirect(conn *Connection, data []byte) {
-	frameBytes, err := ws.CompileFrame(ws.NewBinaryFrame(data))
-	if err != nil {
-		return
-	}
+	s.sendDirectClass(conn, data, writeClassEvent)
+}
+
+// sendDirectClass is sendDirect for a message of the given batching class.
+func (s *Server) sendDirectClass(conn *Connection, data []byte, class writeClass) {
+	s.sendLatest(conn, data, class, 0)
+}
+
+// sendLatest is sendDirectClass for a message that supersedes earlier ones with the
+// same dedupe key (dedupe.go); key 0 = never deduplicated.
+func (s *Server) sendLatest(conn *Connection, data []byte, class writeClass, key uint64) {
 	select {
-	case conn.writeCh <- writeJob{direct: frameBytes, timeout: directWriteTimeout}:
+	case conn.writeCh <- writeJob{direct: data, timeout: s.directWriteTimeout, class: class, dedupe: key}:
 	default:
-		metrics.BroadcastsDropped.Inc()
+		s.noteDrop(conn)
 	}
 }
 
 // notifyPlayerJoined notifies all clients that a new player has joined.
 // The client filters its own join by player ID.
 func (s *Server) notifyPlayerJoined(newPlayer *types.Player) {
-	playerState := types.PlayerState{
-		ID:          newPlayer.ID,
-		X:           uint16(newPlayer.GetX()),
-		Y:           uint16(newPlayer.GetY()),
-		FacingRight: true,
-	}
-	data := s.protocol.EncodePlayerJoined(playerState)
-	frameBytes, err := ws.CompileFrame(ws.NewBinaryFrame(data))
-	if err != nil {
-		slog.Error("failed to compile player joined frame", "error", err)
-		return
+	// ToState carries the spawn-protection flag so others see the newcomer as protected.
+	st := newPlayer.ToState()
+	data := s.protocol.EncodePlayerJoined(st)
+	legacy := data
+	if s.legacyProtocol != nil && s.legacyProtocol.PositionBits != s.protocol.PositionBits {
+		legacy = s.legacyProtocol.EncodePlayerJoined(st)
 	}
-	s.broadcastEvent(frameBytes)
+	s.connectionsMu.RLock()
+	for _, conn := range s.connections {
+		if conn.origin.Load() != nil {
+			s.notifyJoinedFromOrigin(conn, st)
+			continue
+		}
+		if conn.legacyPositions {
+			s.sendReliable(conn, legacy, 0)
+		} else {
+			s.sendReliable(conn, data, 0)
+		}
+	}
+	s.connectionsMu.RUnlock()
 }
 
 // notifyPlayerLeft notifies all clients that a player has disconnected.
 func (s *Server) notifyPlayerLeft(leftPlayerID uint32) {
-	data := s.protocol.EncodePlayerLeft(leftPlayerID)
-	frameBytes, err := ws.CompileFrame(ws.NewBinaryFrame(data))
-	if err != nil {
-		slog.Error("failed to compile player left frame", "error", err)
-		return
+	s.broadcastReliable(s.protocol.EncodePlayerLeft(leftPlayerID))
+}
+
+// sendHit tells the attacker and the victim of a hit about it, ahead of the state
+// frame that shows the knockback: reliably where the client acks, and both are marked
+// critical so the next fan-out does not shed them.
+func (s *Server) sendHit(hit game.Hit) {
+	data := s.protocol.EncodeHit(hit.AttackerID, hit.VictimID, hit.Damage, hit.HP, hit.KnockbackX, hit.KnockbackY)
+	s.connectionsMu.RLock()
+	attacker := s.connections[hit.AttackerID]
+	victim := s.connections[hit.VictimID]
+	s.connectionsMu.RUnlock()
+	if attacker != nil {
+		s.recordHit(attacker, hit)
 	}
-	s.broadcastEvent(frameBytes)
+	if s.cfg.Net.ClientTickBudget > 0 {
+		now := time.Now().UnixNano()
+		if attacker != nil {
+			attacker.noteInteraction(hit.VictimID, now)
+		}
+		if victim != nil {
+			victim.noteInteraction(hit.AttackerID, now)
+		}
+	}
+	for _, conn := range []*Connection{attacker, victim} {
+		if conn == nil {
+			continue // bot
+		}
+		s.markConnectionCritical(conn)
+		s.sendReliable(conn, data, 0)
+	}
+}
+
+// broadcastWorldEvent sends a scheduled world event start/end to every client.
+func (s *Server) broadcastWorldEvent(ev game.WorldEvent) {
+	s.broadcastEvent(s.encodeWorldEvent(ev))
+}
+
+// broadcastWorldUpdate tells every client the new world size after a resize.
+func (s *Server) broadcastWorldUpdate(b game.Bounds) {
+	s.broadcastEvent(s.protocol.EncodeWorldUpdate(b.Width, b.Height))
+}
+
+// sendActiveWorldEvents briefs a newcomer on world events that are still running.
+func (s *Server) sendActiveWorldEvents(conn *Connection) {
+	for _, ev := range s.gameWorld.ActiveWorldEvents() {
+		s.sendDirect(conn, s.encodeWorldEvent(ev))
+	}
+}
+
+func (s *Server) encodeWorldEvent(ev game.WorldEvent) []byte {
+	return s.protocol.EncodeWorldEvent(ev.Kind, ev.Active, ev.Value, uint32(ev.Remaining.Milliseconds()), ev.Text)
 }
 
 // runPingLoop periodically checks for stale connections and sends WS pings.
 // Replaces the per-shard ping ticker. Runs for the lifetime of the server context.
 func (s *Server) runPingLoop() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(s.pingInterval)
 	defer ticker.Stop()
 
 	pingFrame, _ := ws.CompileFrame(ws.NewPingFrame(nil))
@@ -900,16 +1213,18 @@ func (s *Server) runPingLoop() {
 	for {
 		select {
 		case <-ticker.C:
-			cutoff := time.Now().Add(-90 * time.Second).UnixNano()
+			nowNs := time.Now().UnixNano()
+			cutoff := nowNs - s.pongTimeout.Nanoseconds()
 			s.connectionsMu.RLock()
 			for _, conn := range s.connections {
 				if atomic.LoadInt64(&conn.lastActivity) < cutoff {
-					// No pong within two ping intervals — treat as dead.
-					go s.cleanupConnection(conn)
+					// No frame (pong or otherwise) within PongTimeout — treat as dead.
+					s.closeConnection(conn, protocol.CloseIdleTimeout, "ping timeout")
 					continue
 				}
 				select {
-				case conn.writeCh <- writeJob{direct: pingFrame, timeout: directWriteTimeout}:
+				case conn.writeCh <- writeJob{control: pingFrame, timeout: s.directWriteTimeout}:
+					conn.notePingSent(nowNs)
 				default:
 				}
 			}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// BenchmarkBroadcastFanout measures one world-state broadcast end to end: encode,
+// recipient selection, enqueue and the write loops framing it for every connection.
+// Each iteration waits until all write loops are done, so ns/op is the time to get
+// one tick out to conns clients (writes go to a discarding net.Conn).
+func BenchmarkBroadcastFanout(b *testing.B) {
+	players := testutil.Players(10000, 8192, 8192)
+	for _, conns := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("conns=%d", conns), func(b *testing.B) {
+			benchmarkFanout(b, players, newBenchServer(b, conns, nil))
+		})
+	}
+	// Every client with its own viewport-filtered frame (aoi.go).
+	b.Run("conns=1000/viewport", func(b *testing.B) {
+		benchmarkFanout(b, players, newBenchServer(b, 1000, func(c *Connection) {
+			p := players[c.player.ID%uint32(len(players))]
+			c.player.ViewW = 1920
+			c.player.SetViewport(types.ViewportBounds{
+				MinX: p.X - min(p.X, 1000), MinY: p.Y - min(p.Y, 600),
+				MaxX: p.X + min(8191-p.X, 1000), MaxY: p.Y + min(8191-p.Y, 600),
+			})
+		}))
+	})
+}
+
+func benchmarkFanout(b *testing.B, players []types.PlayerState, s *Server) {
+	conns := make([]*Connection, 0, len(s.connections))
+	for _, c := range s.connections {
+		conns = append(conns, c)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.broadcastTick(players, nil, true)
+		for _, c := range conns {
+			for atomic.LoadInt32(&c.pendingBroadcast) != 0 {
+				runtime.Gosched()
+			}
+		}
+	}
+}
+
+// newBenchServer returns a server with conns joined connections writing to nowhere;
+// setup, if set, runs on each of them. The world is paused so only the benchmark
+// broadcasts.
+func newBenchServer(b *testing.B, conns int, setup func(*Connection)) *Server {
+	b.Helper()
+	cfg := testutil.Config()
+	cfg.Net.FanoutQueueShedDepth = 0
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	b.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	for i := 0; i < conns; i++ {
+		c := s.createConnection(discardConn{})
+		c.player = types.NewPlayer(uint32(1001 + i))
+		c.caps = protocol.CapsLegacy
+		c.state = connJoined
+		if setup != nil {
+			setup(c)
+		}
+		s.connections[c.player.ID] = c
+	}
+	return s
+}
+
+// discardConn is a net.Conn whose writes always succeed.
+type discardConn struct{}
+
+func (discardConn) Read([]byte) (int, error)         { select {} }
+func (discardConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (discardConn) Close() error                     { return nil }
+func (discardConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (discardConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (discardConn) SetDeadline(time.Time) error      { return nil }
+func (discardConn) SetReadDeadline(time.Time) error  { return nil }
+func (discardConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestBroadcastSharesPreparedFrame(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.WSCompression = true
+	cfg.Net.WSCompressionMinBytes = 16
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}, connections: make(map[uint32]*Connection)}
+	for id := uint32(1); id <= 3; id++ {
+		s.connections[id] = &Connection{writeCh: make(chan writeJob, 4), state: connJoined, compressMin: 16}
+	}
+	acking := s.connections[3]
+	acking.reliable = &reliableState{}
+
+	text := bytes.Repeat([]byte("hello "), 20)
+	s.broadcastEvent(s.protocol.EncodeChatMessage(7, string(text)))
+	var shared *tickFrame
+	for id := uint32(1); id <= 3; id++ {
+		job := <-s.connections[id].writeCh
+		if job.frame == nil || job.class != writeClassEvent || (shared != nil && job.frame != shared) {
+			t.Fatalf("connection %d got %+v, want the event frame all recipients share", id, job)
+		}
+		shared = job.frame
+	}
+	if refs := atomic.LoadInt32(&shared.refs); refs != 3 || len(shared.deflated) == 0 {
+		t.Fatalf("refs %d, deflated %d bytes; want a reference per recipient and the payload compressed once", refs, len(shared.deflated))
+	}
+
+	// The acking client gets its own RELIABLE envelope; the others share the plain copy.
+	s.broadcastReliable(s.protocol.EncodePlayerLeft(7))
+	plain1, plain2, env := <-s.connections[1].writeCh, <-s.connections[2].writeCh, <-acking.writeCh
+	if plain1.frame == nil || plain1.frame != plain2.frame || env.frame != nil || env.direct[0] != protocol.MessageReliable {
+		t.Fatalf("PLAYER_LEFT jobs %+v, %+v, %+v; want a shared frame and an envelope", plain1, plain2, env)
+	}
+	if refs := atomic.LoadInt32(&plain1.frame.refs); refs != 2 {
+		t.Errorf("PLAYER_LEFT frame refs %d, want 2", refs)
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"math"
+	"sync/atomic"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Client capability flags (JOIN, see protocol.Cap*).
+//
+// Clients report in JOIN whether they merge deltas, whether they want compression and
+// the largest world-state message they accept. Older clients send a bare JOIN and keep
+// the old behaviour (protocol.CapsLegacy, no limit). Encoders are picked per connection:
+//   - no delta support: every update is a full GAME_STATE (one shared frame per tick);
+//   - size limit: a world state that does not fit is split into a GAME_STATE (or delta)
+//     followed by DELTA_GAME_STATE chunks carrying the same stateSequence;
+//   - compression: permessage-deflate, if it was also negotiated at upgrade (compression.go);
+//   - minimap: the periodic MINIMAP density grid (minimap.go);
+//   - checksum: CRC-32C on every message both ways (protocol/checksum.go, sequence.go);
+//   - cell streaming: world content per cell as the viewport moves (streaming.go);
+//   - reliable: joins, leaves and corrections in acked RELIABLE envelopes (reliable.go);
+//   - leaderboard: the periodic LEADERBOARD of every metric (leaderboard.go).
+//
+// Not a JOIN flag, but picked per connection the same way: clients of
+// protocol.LegacySubprotocol get positions in whole units (s.legacyProtocol) when the
+// current protocol sends fractions, so world states are encoded for them separately.
+
+// minClientMessageSize — smaller limits are raised to it: only world states are split,
+// and the chunks of a large world must still fit in writeCh.
+const minClientMessageSize = 4096
+
+// applyCapabilities stores the JOIN capabilities on c. Called before c gets any data message.
+func (s *Server) applyCapabilities(c *Connection, join *protocol.ClientMessage) {
+	c.caps = join.Capabilities
+	if join.MaxMessageSize > 0 {
+		c.maxMessageSize = max(int(min(join.MaxMessageSize, math.MaxInt32)), minClientMessageSize)
+		metrics.ClientCapabilities.WithLabelValues("size_limit").Inc()
+	}
+	if c.deflate && c.caps&protocol.CapCompression != 0 {
+		c.compressMin = max(s.cfg.Net.WSCompressionMinBytes, 1)
+		metrics.ClientCapabilities.WithLabelValues("compression").Inc()
+	}
+	if c.caps&protocol.CapMinimap != 0 {
+		metrics.ClientCapabilities.WithLabelValues("minimap").Inc()
+	}
+	if c.caps&protocol.CapChecksum != 0 {
+		c.checksum = true
+		metrics.ClientCapabilities.WithLabelValues("checksum").Inc()
+	}
+	if c.caps&protocol.CapCellStreaming != 0 && s.cfg.Net.StreamCellSize > 0 {
+		c.stream = &cellStream{loaded: make(map[uint32]struct{})}
+		metrics.ClientCapabilities.WithLabelValues("cell_streaming").Inc()
+	}
+	if c.caps&protocol.CapReliable != 0 && s.cfg.Net.ReliableRetry > 0 {
+		c.reliable = &reliableState{}
+		metrics.ClientCapabilities.WithLabelValues("reliable").Inc()
+	}
+	if c.caps&protocol.CapLeaderboard != 0 {
+		metrics.ClientCapabilities.WithLabelValues("leaderboard").Inc()
+	}
+	if c.wantsDelta() {
+		metrics.ClientCapabilities.WithLabelValues("delta").Inc()
+	} else {
+		metrics.ClientCapabilities.WithLabelValues("full_state").Inc()
+	}
+}
+
+// wantsDelta reports whether c merges DELTA_GAME_STATE.
+func (c *Connection) wantsDelta() bool {
+	return c.caps&protocol.CapDeltaUpdates != 0
+}
+
+// fitsMessage reports whether a payload of n bytes (plus seq) is within c's limit.
+func (c *Connection) fitsMessage(n int) bool {
+	return c.maxMessageSize == 0 || n+c.dataHeaderSize() <= c.maxMessageSize
+}
+
+// dataHeaderSize — bytes in front of every data message to c: seq and, with
+// checksums, the CRC.
+func (c *Connection) dataHeaderSize() int {
+	if c.checksum {
+		return seqHeaderSize + protocol.ChecksumSize
+	}
+	return seqHeaderSize
+}
+
+// protocolFor returns the encoder of positions for c: whole units for legacy clients,
+// relative to c's ORIGIN in large worlds (see largeworld.go).
+func (s *Server) protocolFor(c *Connection) *protocol.BinaryProtocol {
+	if p := c.origin.Load(); p != nil {
+		return p
+	}
+	if c.legacyPositions {
+		return s.legacyProtocol
+	}
+	return s.protocol
+}
+
+// splitCapabilityRecipients moves connections that cannot take the shared frame of
+// frameLen bytes (no delta support, over their size limit, or legacy positions) into
+// s.capConns.
+// Returns the remaining shared-frame recipients and whether any of them compresses.
+// Runs on the gameLoop goroutine only (inside broadcastTick).
+func (s *Server) splitCapabilityRecipients(recipients []*Connection, fullSync bool, frameLen int) ([]*Connection, bool) {
+	s.capConns = s.capConns[:0]
+	shared := recipients[:0]
+	compress := false
+	for _, conn := range recipients {
+		if (!fullSync && !conn.wantsDelta()) || !conn.fitsMessage(frameLen) || conn.legacyPositions {
+			s.capConns = append(s.capConns, conn)
+			continue
+		}
+		compress = compress || conn.compressMin > 0
+		shared = append(shared, conn)
+	}
+	return shared, compress
+}
+
+// enqueueCapabilityFrames serves s.capConns: full states for clients without delta
+// support and world states with legacy positions (each encoded once and shared), and
+// chunked world states for size-limited clients. Returns the number of dropped enqueues.
+func (s *Server) enqueueCapabilityFrames(allPlayers, changed []types.PlayerState, fullSync bool, stateSequence uint32, sentAtNs int64) int {
+	dropped := 0
+	fullFits := protocol.WorldStateSize(len(allPlayers))
+	deltaFits := protocol.WorldStateSize(len(changed))
+	fullConns := s.capConns[:0]
+	legacy := &s.legacyConns
+	compress, legacyCompress := false, [2]bool{}
+	for i, conn := range s.capConns {
+		full := fullSync || !conn.wantsDelta()
+		switch {
+		case full && conn.fitsMessage(fullFits) && !conn.legacyPositions:
+			compress = compress || conn.compressMin > 0
+			fullConns = append(fullConns, conn)
+			continue
+		case full && conn.fitsMessage(fullFits):
+			legacyCompress[0] = legacyCompress[0] || conn.compressMin > 0
+			legacy[0] = append(legacy[0], conn)
+			s.capConns[i] = nil
+			continue
+		case !full && conn.fitsMessage(deltaFits) && conn.legacyPositions:
+			legacyCompress[1] = legacyCompress[1] || conn.compressMin > 0
+			legacy[1] = append(legacy[1], conn)
+			s.capConns[i] = nil
+			continue
+		}
+		players := changed
+		if full {
+			players = allPlayers
+		}
+		if !s.sendWorldStateChunks(conn, players, full, stateSequence, sentAtNs) {
+			dropped++
+		}
+		s.capConns[i] = nil
+	}
+
+	if len(fullConns) > 0 {
+		f := s.newWorldStateFrame(s.protocol, allPlayers, true, stateSequence, compress)
+		dropped += s.enqueueSharedFrame(f, fullConns, sentAtNs)
+		if !fullSync {
+			metrics.FullStateFallbacks.Add(float64(len(fullConns)))
+		}
+	}
+	for i, conns := range legacy {
+		if len(conns) == 0 {
+			continue
+		}
+		players := allPlayers
+		if i == 1 {
+			players = changed
+		}
+		f := s.newWorldStateFrame(s.legacyProtocol, players, i == 0, stateSequence, legacyCompress[i])
+		dropped += s.enqueueSharedFrame(f, conns, sentAtNs)
+		legacy[i] = conns[:0]
+	}
+	s.capConns = s.capConns[:0]
+	return dropped
+}
+
+// enqueueSharedFrame enqueues f for every connection of conns and clears conns.
+// Returns the number of dropped enqueues.
+func (s *Server) enqueueSharedFrame(f *tickFrame, conns []*Connection, sentAtNs int64) int {
+	dropped := 0
+	atomic.StoreInt32(&f.refs, int32(len(conns)))
+	for i, conn := range conns {
+		if !s.enqueueBroadcastJob(conn, f, sentAtNs) {
+			dropped++
+		}
+		conns[i] = nil
+	}
+	return dropped
+}
+
+// newWorldStateFrame encodes players with p as a GAME_STATE (full) or DELTA_GAME_STATE
+// into a pooled frame, compressed as well when compress is set. refs is left to the caller.
+func (s *Server) newWorldStateFrame(p *protocol.BinaryProtocol, players []types.PlayerState, full bool, stateSequence uint32, compress bool) *tickFrame {
+	f := broadcastFramePool.Get().(*tickFrame)
+	if full {
+		f.data = p.AppendGameState(f.data[:0], players, stateSequence)
+	} else {
+		f.data = p.AppendDeltaGameState(f.data[:0], players, stateSequence)
+	}
+	if compress {
+		s.deflateFrame(f)
+	}
+	return f
+}
+
+// worldStateChunks splits players into messages within conn's size limit: the first is
+// a GAME_STATE when full, the rest are DELTA_GAME_STATE, all with stateSequence.
+func (s *Server) worldStateChunks(conn *Connection, players []types.PlayerState, full bool, stateSequence uint32) [][]byte {
+	per := protocol.WorldStatePlayersPerMessage(conn.maxMessageSize - conn.dataHeaderSize())
+	chunks := make([][]byte, 0, (len(players)+per-1)/per)
+	p := s.protocolFor(conn)
+	for start := 0; start < len(players) || start == 0; start += per {
+		part := players[start:min(start+per, len(players))]
+		if full && start == 0 {
+			chunks = append(chunks, p.AppendGameState(nil, part, stateSequence))
+		} else {
+			chunks = append(chunks, p.AppendDeltaGameState(nil, part, stateSequence))
+		}
+	}
+	metrics.WorldStateChunks.Inc()
+	return chunks
+}
+
+// sendWorldStateChunks enqueues a chunked world state for conn. The last chunk goes
+// through enqueueBroadcastJob so a client that is still busy with the previous world
+// state skips this one entirely instead of piling up chunks.
+func (s *Server) sendWorldStateChunks(conn *Connection, players []types.PlayerState, full bool, stateSequence uint32, sentAtNs int64) bool {
+	if atomic.LoadInt32(&conn.pendingBroadcast) != 0 {
+		metrics.BroadcastsShed.Inc()
+		return true
+	}
+	chunks := s.worldStateChunks(conn, players, full, stateSequence)
+	last := len(chunks) - 1
+	for _, data := range chunks[:last] {
+		s.sendDirect(conn, data)
+	}
+	f := broadcastFramePool.Get().(*tickFrame)
+	f.data = append(f.data[:0], chunks[last]...)
+	if conn.compressMin > 0 {
+		s.deflateFrame(f)
+	}
+	atomic.StoreInt32(&f.refs, 1)
+	return s.enqueueBroadcastJob(conn, f, sentAtNs)
+}
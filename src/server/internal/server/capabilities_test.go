@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestWorldStateChunks(t *testing.T) {
+	s := &Server{protocol: &protocol.BinaryProtocol{}}
+	conn := &Connection{maxMessageSize: minClientMessageSize}
+	players := make([]types.PlayerState, 1000)
+	for i := range players {
+		players[i] = types.PlayerState{ID: uint32(i + 1), X: uint32(i)}
+	}
+
+	for _, full := range []bool{true, false} {
+		chunks := s.worldStateChunks(conn, players, full, 77)
+		if len(chunks) < 2 {
+			t.Fatalf("full=%v: %d chunks, want a split", full, len(chunks))
+		}
+		total := 0
+		for i, data := range chunks {
+			if !conn.fitsMessage(len(data)) {
+				t.Errorf("full=%v chunk %d: %d bytes over the limit", full, i, len(data))
+			}
+			wantType := byte(protocol.MessageDeltaGameState)
+			if full && i == 0 {
+				wantType = protocol.MessageGameState
+			}
+			if data[0] != wantType {
+				t.Errorf("full=%v chunk %d: type %d, want %d", full, i, data[0], wantType)
+			}
+			total += (len(data) - protocol.WorldStateSize(0)) / protocol.LookupSchema(protocol.MessageGameState).EntrySize()
+		}
+		if total != len(players) {
+			t.Errorf("full=%v: chunks carry %d players, want %d", full, total, len(players))
+		}
+	}
+}
+
+func TestSelfFirst(t *testing.T) {
+	self := types.NewPlayer(1005)
+	self.SetX(10)
+	got := selfFirst(self, []types.PlayerState{{ID: 1001}, {ID: 1005, X: 99}, {ID: 1003}})
+	if len(got) != 3 || got[0].ID != 1005 || got[0].X != 10 || got[1].ID != 1001 || got[2].ID != 1003 {
+		t.Fatalf("selfFirst = %+v", got)
+	}
+}
+
+func TestApplyCapabilities(t *testing.T) {
+	s := &Server{cfg: testutil.Config()}
+	tests := []struct {
+		name         string
+		deflate      bool
+		join         protocol.ClientMessage
+		wantDelta    bool
+		wantMax      int
+		wantCompress bool
+	}{
+		{"legacy", true, protocol.ClientMessage{Capabilities: protocol.CapsLegacy}, true, 0, false},
+		{"no delta, small limit", false, protocol.ClientMessage{MaxMessageSize: 100}, false, minClientMessageSize, false},
+		{"compression negotiated", true, protocol.ClientMessage{Capabilities: protocol.CapDeltaUpdates | protocol.CapCompression, MaxMessageSize: 1 << 20}, true, 1 << 20, true},
+		{"compression not negotiated", false, protocol.ClientMessage{Capabilities: protocol.CapCompression}, false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connection{deflate: tt.deflate}
+			s.applyCapabilities(c, &tt.join)
+			if c.wantsDelta() != tt.wantDelta || c.maxMessageSize != tt.wantMax || (c.compressMin > 0) != tt.wantCompress {
+				t.Fatalf("delta=%v max=%d compressMin=%d", c.wantsDelta(), c.maxMessageSize, c.compressMin)
+			}
+		})
+	}
+}
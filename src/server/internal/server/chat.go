@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Chat and console commands (internal/console).
+//
+// A CHAT line is relayed to everyone as CHAT_MESSAGE, unless the player is muted
+// (moderation.go) or the line starts with "/": then it runs as a console command and
+// only the issuing client gets the COMMAND_RESULT. A caller's role is looked up once
+// per connection, at upgrade, from the account in its session token (Server.Roles).
+//
+// The built-in commands are registered here; other subsystems add theirs through
+// Server.Console. Commands run on the caller's read path.
+
+// whoListLimit — players /who lists by ID; the count is always complete.
+const whoListLimit = 20
+
+// Console returns the command registry, for subsystems to register commands in.
+func (s *Server) Console() *console.Registry {
+	return s.console
+}
+
+// initConsole sets up roles and the built-in commands.
+func (s *Server) initConsole() {
+	roles, err := console.ParseRoles(s.cfg.Server.Roles)
+	if err != nil {
+		slog.Error("bad ROLES, everyone is a player", "error", err)
+	}
+	s.roles = roles
+
+	s.console = console.NewRegistry()
+	s.console.MustRegister(console.Command{
+		Name: "who",
+		Help: "players online",
+		Run:  s.cmdWho,
+	})
+	s.console.MustRegister(console.Command{
+		Name: "stats",
+		Help: "server and connection statistics",
+		Run:  s.cmdStats,
+	})
+	s.console.MustRegister(console.Command{
+		Name:  "kick",
+		Usage: "<player> [reason]",
+		Help:  "disconnects a player",
+		Role:  console.RoleModerator,
+		Run:   s.cmdKick,
+	})
+	s.registerPlayerOpCommands()
+	s.console.MustRegister(console.Command{
+		Name:  "announce",
+		Usage: "[info|warning|critical] <text>",
+		Help:  "announcement to every player",
+		Role:  console.RoleAdmin,
+		Run:   s.cmdAnnounce,
+	})
+}
+
+// roleOf returns the console role of an account ("" = anonymous: player).
+func (s *Server) roleOf(account string) console.Role {
+	if account == "" {
+		return console.RolePlayer
+	}
+	return s.roles[account]
+}
+
+// handleChat relays a chat line or runs the command in it.
+func (s *Server) handleChat(c *Connection, text string) {
+	text = strings.TrimSpace(strings.ToValidUTF8(text, ""))
+	if text == "" {
+		return
+	}
+	if console.IsCommand(text) {
+		s.runCommand(c, text)
+		return
+	}
+	if s.isMuted(c) {
+		metrics.ChatMessages.WithLabelValues("muted").Inc()
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandMuted, "you are muted"))
+		return
+	}
+	metrics.ChatMessages.WithLabelValues("relayed").Inc()
+	s.broadcastEvent(s.protocol.EncodeChatMessage(c.player.ID, text))
+}
+
+// runCommand runs a console command for c and answers it.
+func (s *Server) runCommand(c *Connection, line string) {
+	caller := console.Caller{PlayerID: c.player.ID, Account: c.account, Role: c.role}
+	name, res := s.console.Dispatch(caller, line)
+	if res.Status == console.StatusUnknown && name != "" && s.scripts != nil {
+		s.runScriptCommand(c, caller, line, name, res)
+		return
+	}
+	s.answerCommand(c, caller, name, res)
+}
+
+// answerCommand counts, logs and sends the result of command name.
+func (s *Server) answerCommand(c *Connection, caller console.Caller, name string, res console.Result) {
+	if res.Status == console.StatusUnknown {
+		name = "unknown" // keeps the label set bounded
+	}
+	metrics.ConsoleCommands.WithLabelValues(name, commandStatusLabels[res.Status]).Inc()
+	if caller.Role > console.RolePlayer || res.Status == console.StatusDenied {
+		slog.Info("console command", "player_id", caller.PlayerID, "account", caller.Account,
+			"role", caller.Role.String(), "command", name, "status", commandStatusLabels[res.Status])
+	}
+	s.sendDirect(c, s.protocol.EncodeCommandResult(uint8(res.Status), res.Text))
+}
+
+var commandStatusLabels = [...]string{
+	console.StatusOK:      "ok",
+	console.StatusError:   "error",
+	console.StatusDenied:  "denied",
+	console.StatusUnknown: "unknown",
+}
+
+func (s *Server) cmdWho(_ console.Caller, _ []string) console.Result {
+	s.connectionsMu.RLock()
+	n := len(s.connections)
+	ids := make([]string, 0, min(n, whoListLimit))
+	for id := range s.connections {
+		if len(ids) == whoListLimit {
+			break
+		}
+		ids = append(ids, strconv.FormatUint(uint64(id), 10))
+	}
+	s.connectionsMu.RUnlock()
+
+	out := fmt.Sprintf("%d players online", n)
+	if len(ids) > 0 {
+		out += ": " + strings.Join(ids, ", ")
+		if n > len(ids) {
+			out += ", …"
+		}
+	}
+	return console.OK("%s", out)
+}
+
+func (s *Server) cmdStats(caller console.Caller, _ []string) console.Result {
+	var b strings.Builder
+	fmt.Fprintf(&b, "players %d, tick rate %d Hz, uptime %s",
+		s.gameWorld.GetPlayerCount(), s.cfg.Game.TickRate, time.Since(s.startTime).Truncate(time.Second))
+	s.connectionsMu.RLock()
+	c := s.connections[caller.PlayerID]
+	s.connectionsMu.RUnlock()
+	if c != nil {
+		sum := c.sessionSummary(time.Now())
+		fmt.Fprintf(&b, "\nyou: position %d,%d, ping %.0f ms, %d bytes in, %d bytes out, %d dropped",
+			c.player.GetX(), c.player.GetY(), sum.AvgRTTMs, sum.BytesIn, sum.BytesOut, sum.Drops)
+	}
+	return console.OK("%s", b.String())
+}
+
+func (s *Server) cmdKick(caller console.Caller, args []string) console.Result {
+	if len(args) == 0 {
+		return console.Errorf("usage: /kick <player> [reason]")
+	}
+	target, res := s.commandTarget(args[0])
+	if target == nil {
+		return res
+	}
+	reason := "kicked by a moderator"
+	if len(args) > 1 {
+		reason = strings.Join(args[1:], " ")
+	}
+	// The reason goes into the close frame, which holds 123 bytes.
+	reason = protocol.TruncateText(reason, 120)
+	slog.Info("player kicked from the console", "player_id", target.player.ID, "by", caller.PlayerID, "reason", reason)
+	s.closeConnection(target, protocol.CloseKicked, reason)
+	return console.OK("kicked %d", target.player.ID)
+}
+
+// commandTarget resolves a player ID argument to a connected player.
+func (s *Server) commandTarget(arg string) (*Connection, console.Result) {
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return nil, console.Errorf("%q is not a player ID", arg)
+	}
+	s.connectionsMu.RLock()
+	c := s.connections[uint32(id)]
+	s.connectionsMu.RUnlock()
+	if c == nil {
+		return nil, console.Errorf("player %d is not online", id)
+	}
+	return c, console.Result{}
+}
+
+func (s *Server) cmdAnnounce(_ console.Caller, args []string) console.Result {
+	severity := uint8(protocol.SeverityInfo)
+	if len(args) > 1 {
+		if v, ok := severityNames[args[0]]; ok {
+			severity, args = v, args[1:]
+		}
+	}
+	text := strings.Join(args, " ")
+	if text == "" || !utf8.ValidString(text) {
+		return console.Errorf("usage: /announce [info|warning|critical] <text>")
+	}
+	n := s.announce(announceTarget{}, severity, localizedText{"": text})
+	return console.OK("announced to %d players", n)
+}
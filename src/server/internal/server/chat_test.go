@@ -0,0 +1,107 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/moderation"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+// chatFrames returns the CHAT_MESSAGE and COMMAND_RESULT messages written to fake.
+func chatFrames(t *testing.T, fake *testutil.FakeConn) (chat []string, results []string) {
+	t.Helper()
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		msg := f.Payload[seqHeaderSize:]
+		switch msg[0] {
+		case protocol.MessageChatMessage:
+			chat = append(chat, string(msg[protocol.LookupSchema(protocol.MessageChatMessage).Size(0):]))
+		case protocol.MessageCommandResult:
+			head := protocol.LookupSchema(protocol.MessageCommandResult).Size(0)
+			results = append(results, string('0'+msg[1])+":"+string(msg[head:]))
+		}
+	}
+	return chat, results
+}
+
+func TestChatAndCommands(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.Roles = "mod=moderator"
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	join := func(account string) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.account = account
+		c.role = s.roleOf(account)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	mod, modFake := join("mod")
+	player, playerFake := join("bob")
+	if mod.role != console.RoleModerator || player.role != console.RolePlayer {
+		t.Fatalf("roles = %v, %v", mod.role, player.role)
+	}
+
+	s.handleChat(player, "  hello  ")
+	s.handleChat(player, "/who")
+	s.handleChat(player, "/kick 1")
+	s.handleChat(player, "/nope")
+	if _, err := s.moderation.Record(moderation.Entry{
+		Time: time.Now().UTC(), Action: moderation.ActionMute,
+		Target: moderation.AccountTarget("bob"), Reason: "spam",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.handleChat(player, "still here")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, results := chatFrames(t, playerFake)
+		if len(results) >= 4 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	chat, _ := chatFrames(t, modFake)
+	if len(chat) != 1 || chat[0] != "hello" {
+		t.Errorf("moderator saw chat %q, want [hello]", chat)
+	}
+	_, results := chatFrames(t, playerFake)
+	if len(results) != 4 {
+		t.Fatalf("player got results %q", results)
+	}
+	if !strings.HasPrefix(results[0], "0:2 players online") {
+		t.Errorf("/who = %q", results[0])
+	}
+	for i, status := range []byte{protocol.CommandDenied, protocol.CommandUnknown, protocol.CommandMuted} {
+		if results[i+1][0] != '0'+status {
+			t.Errorf("result %d = %q, want status %d", i+1, results[i+1], status)
+		}
+	}
+
+	s.handleChat(mod, "/kick "+strconv.FormatUint(uint64(player.player.ID), 10)+" \"spamming chat\"")
+	deadline = time.Now().Add(time.Second)
+	for !playerFake.Closed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !playerFake.Closed() {
+		t.Error("/kick by a moderator did not close the player's connection")
+	}
+}
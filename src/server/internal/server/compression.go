@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// permessage-deflate (RFC 7692) for clients that negotiated it at upgrade and set
+// protocol.CapCompression in JOIN.
+//
+// Both directions use no_context_takeover, so every message is an independent deflate
+// stream. That keeps tick frames shareable: the payload is compressed once per tick
+// (tickFrame.deflated) and each connection puts its sequence in front as a tiny stored
+// block, which is a valid deflate prefix of the same message.
+
+const (
+	deflatePrefixSize = 5        // stored block header in front of the seq: BFINAL=0 BTYPE=00, LEN, NLEN
+	maxInflatedSize   = 64 << 10 // client messages are tiny; anything bigger is a deflate bomb
+)
+
+// deflateTail — sync-flush marker stripped from every compressed message (RFC 7692 §7.2.1).
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+var (
+	deflateRawBytes        = metrics.WSDeflateBytes.WithLabelValues("raw")
+	deflateCompressedBytes = metrics.WSDeflateBytes.WithLabelValues("compressed")
+)
+
+var flateWriterPool = sync.Pool{
+	New: func() any {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+var flateReaderPool = sync.Pool{
+	New: func() any { return flate.NewReader(nil) },
+}
+
+// upgrade performs the WebSocket handshake, answering with subprotocol (if not
+// empty, see subprotocol.go) and offering permessage-deflate when Net.WSCompression
+// is on. Reports whether the extension was negotiated.
+func (s *Server) upgrade(r *http.Request, w http.ResponseWriter, subprotocol string) (net.Conn, bool, error) {
+	var u ws.HTTPUpgrader
+	if subprotocol != "" {
+		u.Protocol = func(p string) bool { return p == subprotocol }
+	}
+	if !s.cfg.Net.WSCompression {
+		conn, _, _, err := u.Upgrade(r, w)
+		return conn, false, err
+	}
+	ext := wsflate.Extension{Parameters: wsflate.Parameters{
+		ServerNoContextTakeover: true,
+		ClientNoContextTakeover: true,
+	}}
+	u.Negotiate = ext.Negotiate
+	conn, _, _, err := u.Upgrade(r, w)
+	_, accepted := ext.Accepted()
+	return conn, accepted, err
+}
+
+// appendDeflate appends the raw deflate encoding of src, without the sync-flush
+// tail, to dst.
+func appendDeflate(dst, src []byte) []byte {
+	buf := bytes.NewBuffer(dst)
+	w := flateWriterPool.Get().(*flate.Writer)
+	w.Reset(buf)
+	w.Write(src) // bytes.Buffer never fails
+	w.Flush()
+	w.Reset(io.Discard) // don't pin buf in the pool
+	flateWriterPool.Put(w)
+	out := buf.Bytes()
+	return out[:len(out)-len(deflateTail)]
+}
+
+// appendDeflateFrameHeader is appendFrameHeader for a compressed frame: RSV1 set and
+// the seq wrapped in a stored block ahead of deflatedLen bytes of shared deflate output.
+func appendDeflateFrameHeader(dst []byte, deflatedLen int, seq uint32, checksum bool, sum uint32) []byte {
+	n := dataHeaderSize(checksum)
+	dst = appendWSHeader(dst, 0xC2, deflatePrefixSize+n+deflatedLen) // FIN + RSV1 + binary
+	dst = append(dst, 0x00, byte(n), 0x00, ^byte(n), 0xFF)
+	return appendDataHeader(dst, seq, checksum, sum)
+}
+
+// deflateFrame compresses f for connections with compression on; frames below
+// Net.WSCompressionMinBytes, or that deflate does not shrink, stay uncompressed.
+func (s *Server) deflateFrame(f *tickFrame) {
+	if len(f.data) >= s.cfg.Net.WSCompressionMinBytes {
+		f.deflated = appendDeflate(f.deflated[:0], f.data)
+		if !deflateSaves(f.data, f.deflated) {
+			f.deflated = f.deflated[:0]
+		}
+	}
+}
+
+// deflateSaves reports whether sending deflated instead of raw saves bytes.
+func deflateSaves(raw, deflated []byte) bool {
+	return len(deflated)+deflatePrefixSize < len(raw)
+}
+
+// inflate decompresses an RSV1 message from c.
+func (c *Connection) inflate(payload []byte) ([]byte, error) {
+	if !c.deflate {
+		return nil, errors.New("compressed frame without permessage-deflate")
+	}
+	r := flateReaderPool.Get().(io.ReadCloser)
+	defer flateReaderPool.Put(r)
+	r.(flate.Resetter).Reset(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)), nil)
+
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedSize+1))
+	// The stream ends at a sync flush, not a final block.
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if len(out) > maxInflatedSize {
+		return nil, errors.New("inflated message too large")
+	}
+	return out, nil
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Connection buffers.
+//
+// Client messages are small — the largest legal one is minReadLimit bytes — so the read
+// handlers do not allocate every frame's header and payload. They borrow a readBuf from
+// s.readBufs: its header array takes any header, its data any payload up to
+// Net.ReadBufferSize; larger frames (up to the read limit) get a buffer of their own as
+// before. The epoll path holds a readBuf only while a worker reads and dispatches one
+// frame, so the pool keeps about one per read worker however many clients are connected;
+// the goroutine fallback keeps one per connection for its lifetime.
+//
+// With Net.WriteBufferPool the write loop likewise borrows its batch scratch
+// (writeScratch) only while it writes a batch instead of holding it for the connection's
+// lifetime: at 30 Hz a connection spends most of its time waiting for the next tick.
+//
+// Nothing may keep a payload past dispatch: decoded messages copy what they need
+// (protocol.DecodeClientMessage), inflate returns a new buffer and a pong is compiled
+// into a frame of its own.
+
+// readBuf — scratch for reading one frame.
+type readBuf struct {
+	hdr  [ws.MaxHeaderSize]byte
+	data []byte
+}
+
+// getReadBuf borrows a readBuf; give it back with putReadBuf once the frame is dispatched.
+func (s *Server) getReadBuf() *readBuf {
+	if rb, ok := s.readBufs.Get().(*readBuf); ok {
+		return rb
+	}
+	return &readBuf{data: make([]byte, s.readBufSize)}
+}
+
+func (s *Server) putReadBuf(rb *readBuf) {
+	s.readBufs.Put(rb)
+}
+
+// readHeader reads a frame header from r; the first have bytes of it (0 or 1) are
+// already in rb.hdr. ws.ReadHeader without its allocation per frame.
+func (rb *readBuf) readHeader(r io.Reader, have int) (h ws.Header, err error) {
+	b := rb.hdr[:]
+	if _, err = io.ReadFull(r, b[have:2]); err != nil {
+		return h, err
+	}
+	h.Fin = b[0]&0x80 != 0
+	h.Rsv = (b[0] & 0x70) >> 4
+	h.OpCode = ws.OpCode(b[0] & 0x0f)
+
+	extra := 0
+	if b[1]&0x80 != 0 {
+		h.Masked = true
+		extra += 4
+	}
+	length := b[1] & 0x7f
+	switch length {
+	case 126:
+		extra += 2
+	case 127:
+		extra += 8
+	default:
+		h.Length = int64(length)
+	}
+	if extra == 0 {
+		return h, nil
+	}
+
+	b = b[2 : 2+extra]
+	if _, err = io.ReadFull(r, b); err != nil {
+		return h, err
+	}
+	switch length {
+	case 126:
+		h.Length = int64(binary.BigEndian.Uint16(b))
+		b = b[2:]
+	case 127:
+		if b[0]&0x80 != 0 {
+			return h, ws.ErrHeaderLengthMSB
+		}
+		h.Length = int64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+	}
+	if h.Masked {
+		copy(h.Mask[:], b)
+	}
+	return h, nil
+}
+
+// readPayload reads and unmasks the payload of the frame behind h: into rb.data when it
+// fits, else into a buffer of its own. The result is valid until rb goes back to the pool.
+func (rb *readBuf) readPayload(r io.Reader, h ws.Header) ([]byte, error) {
+	if h.Length == 0 {
+		return nil, nil
+	}
+	var payload []byte
+	if h.Length <= int64(len(rb.data)) {
+		payload = rb.data[:h.Length]
+	} else {
+		payload = make([]byte, h.Length)
+		metrics.WSReadBufferMisses.Inc()
+	}
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	// Client frames must be masked (RFC 6455 §5.3).
+	if h.Masked {
+		ws.Cipher(payload, h.Mask, 0)
+	}
+	return payload, nil
+}
+
+// writeScratch — what the write loop needs to write one batch: the jobs, the frames
+// for writev and their headers (appendJobFrames), and the buffer a small batch is
+// copied into instead (coalesceFrames).
+type writeScratch struct {
+	jobs    []writeJob
+	frames  [][]byte
+	headers []byte
+	flat    []byte
+}
+
+func newWriteScratch(batchSize int) *writeScratch {
+	return &writeScratch{
+		jobs:    make([]writeJob, batchSize),
+		frames:  make([][]byte, 0, 2*batchSize),
+		headers: make([]byte, 0, batchSize*maxFrameHeaderSize),
+	}
+}
+
+// getWriteScratch borrows the scratch for one batch; give it back with putWriteScratch
+// with its jobs and frames cleared.
+func (s *Server) getWriteScratch() *writeScratch {
+	if sc, ok := s.writeScratch.Get().(*writeScratch); ok {
+		return sc
+	}
+	return newWriteScratch(s.writeBatchSize)
+}
+
+func (s *Server) putWriteScratch(sc *writeScratch) {
+	s.writeScratch.Put(sc)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gobwas/ws"
+)
+
+// clientFrame returns a masked binary frame as a client sends it.
+func clientFrame(tb testing.TB, payload []byte) []byte {
+	tb.Helper()
+	frame := ws.MaskFrame(ws.NewBinaryFrame(bytes.Clone(payload)))
+	var buf bytes.Buffer
+	if err := ws.WriteFrame(&buf, frame); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadBufFrames(t *testing.T) {
+	rb := &readBuf{data: make([]byte, 64)}
+	// Every header length (7-bit, 16-bit, 64-bit), in and over the buffer.
+	for _, size := range []int{0, 1, 64, 65, 125, 126, 1000, 1 << 16} {
+		payload := make([]byte, size)
+		for i := range payload {
+			payload[i] = byte(i * 7)
+		}
+		raw := clientFrame(t, payload)
+		for have := range 2 {
+			r := bytes.NewReader(raw)
+			copy(rb.hdr[:have], raw)
+			r.Seek(int64(have), io.SeekStart)
+
+			hdr, err := rb.readHeader(r, have)
+			if err != nil {
+				t.Fatalf("size %d, have %d: header: %v", size, have, err)
+			}
+			if hdr.OpCode != ws.OpBinary || !hdr.Fin || !hdr.Masked || hdr.Length != int64(size) {
+				t.Fatalf("size %d, have %d: header %+v", size, have, hdr)
+			}
+			got, err := rb.readPayload(r, hdr)
+			if err != nil {
+				t.Fatalf("size %d, have %d: payload: %v", size, have, err)
+			}
+			if !bytes.Equal(got, payload) || r.Len() != 0 {
+				t.Errorf("size %d, have %d: payload differs or %d bytes left over", size, have, r.Len())
+			}
+			if inBuf := size > 0 && &got[0] == &rb.data[0]; inBuf != (size > 0 && size <= len(rb.data)) {
+				t.Errorf("size %d: read into the pooled buffer = %v", size, inBuf)
+			}
+		}
+	}
+}
+
+func TestReadBufHeaderErrors(t *testing.T) {
+	rb := &readBuf{}
+	if _, err := rb.readHeader(bytes.NewReader([]byte{0x82, 0x7f, 0x80, 0, 0, 0, 0, 0, 0, 0}), 0); err != ws.ErrHeaderLengthMSB {
+		t.Errorf("64-bit length with the top bit set: err = %v", err)
+	}
+	if _, err := rb.readHeader(bytes.NewReader([]byte{0x82, 0xfe, 0}), 0); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated header: err = %v", err)
+	}
+}
+
+// BenchmarkReadFrame reads a typical MOVE frame: "gobwas" as the read handlers did
+// before connbuf.go, "pooled" with a readBuf from the pool.
+func BenchmarkReadFrame(b *testing.B) {
+	raw := clientFrame(b, make([]byte, 13))
+	var r bytes.Reader
+
+	b.Run("gobwas", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			r.Reset(raw)
+			hdr, err := ws.ReadHeader(&r)
+			if err != nil {
+				b.Fatal(err)
+			}
+			payload := make([]byte, hdr.Length)
+			if _, err := io.ReadFull(&r, payload); err != nil {
+				b.Fatal(err)
+			}
+			ws.Cipher(payload, hdr.Mask, 0)
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		s := &Server{readBufSize: 512}
+		b.ReportAllocs()
+		for b.Loop() {
+			r.Reset(raw)
+			rb := s.getReadBuf()
+			hdr, err := rb.readHeader(&r, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := rb.readPayload(&r, hdr); err != nil {
+				b.Fatal(err)
+			}
+			s.putReadBuf(rb)
+		}
+	})
+}
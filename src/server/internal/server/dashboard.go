@@ -0,0 +1,27 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Dev dashboard.
+//
+// GET /dashboard is a single embedded page that puts the loose operator endpoints
+// together: counters from /metrics/json, /metrics and /stats, graphs from
+// /metrics/history, a minimap fed by /world/stream and buttons for the admin API. The
+// page holds no data of its own — it only calls those endpoints from the browser, so
+// each keeps its own switch (WORLD_VIEW, METRICS_HISTORY_MIN, ADMIN_TOKEN) and the page
+// shows what is off.
+// The admin token is typed into the page and kept in the tab's sessionStorage.
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// handleDashboard serves the dashboard page.
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write(dashboardPage)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"pixi_game_server/internal/testutil"
+)
+
+func TestDashboard(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.AdminToken = "secret"
+	cfg.Server.WorldView = true
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("GET /dashboard = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	page := rec.Body.String()
+
+	// Every endpoint the page calls is served next to it.
+	var paths []string
+	for _, m := range regexp.MustCompile(`(?:fetch|EventSource)\("(/[a-z/]+)`).FindAllStringSubmatch(page, -1) {
+		paths = append(paths, m[1])
+	}
+	for _, m := range regexp.MustCompile(`data-admin="([a-z]+)"`).FindAllStringSubmatch(page, -1) {
+		paths = append(paths, "/admin/"+m[1])
+	}
+	if len(paths) < 8 {
+		t.Fatalf("found only %v in the page", paths)
+	}
+	for _, path := range paths {
+		if _, p := mux.Handler(httptest.NewRequest(http.MethodGet, path, nil)); p != path {
+			t.Errorf("the page calls %s, served by %q", path, p)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import "pixi_game_server/internal/metrics"
+
+// Outbound deduplication.
+//
+// Some messages carry the whole current state of one subject: MOVEMENT_ACK the
+// sender's authoritative position, MINIMAP the density grid. A client toggling
+// direction rapidly, or a socket that stalls for a few ticks, leaves several of them
+// queued for the same subject; only the newest matters. When the write loop has taken
+// its batch (the flush window, see writebatch.go), dedupeJobs drops every job that a
+// later job with the same key supersedes — before framing, so the dropped ones take
+// neither bytes nor an outbound sequence number (Net.OutboundDedupe).
+
+// dedupeKey — ключ latest-wins сообщения: тип и сущность (0 — сообщение о самом
+// соединении, например MINIMAP).
+func dedupeKey(msgType byte, entityID uint32) uint64 {
+	return uint64(msgType)<<32 | uint64(entityID)
+}
+
+// dedupeJobs удаляет из jobs задания, после которых в батче есть задание с тем же
+// ключом, сохраняя порядок остальных. Возвращает новую длину.
+func dedupeJobs(jobs []writeJob) int {
+	n := 0
+	for i := range jobs {
+		if key := jobs[i].dedupe; key != 0 && supersededAfter(jobs[i+1:], key) {
+			metrics.OutboundDeduped.Inc()
+			continue
+		}
+		jobs[n] = jobs[i]
+		n++
+	}
+	clear(jobs[n:])
+	return n
+}
+
+func supersededAfter(jobs []writeJob, key uint64) bool {
+	for i := range jobs {
+		if jobs[i].dedupe == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+)
+
+func TestDedupeJobsKeepsLatestPerSubject(t *testing.T) {
+	ack := func(player uint32, b byte) writeJob {
+		return writeJob{direct: []byte{b}, dedupe: dedupeKey(protocol.MessageMovementAck, player)}
+	}
+	jobs := []writeJob{
+		ack(1001, 1),
+		{direct: []byte{2}}, // not deduplicated
+		ack(1002, 3),
+		ack(1001, 4),
+		{direct: []byte{5}},
+		ack(1001, 6),
+	}
+	n := dedupeJobs(jobs)
+
+	var got []byte
+	for _, j := range jobs[:n] {
+		got = append(got, j.direct[0])
+	}
+	if want := []byte{2, 3, 5, 6}; string(got) != string(want) {
+		t.Fatalf("kept %v, want %v", got, want)
+	}
+	for _, j := range jobs[n:] {
+		if j.direct != nil {
+			t.Fatal("dropped jobs not cleared")
+		}
+	}
+}
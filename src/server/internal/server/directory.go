@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+)
+
+// Server browser support.
+//
+// /info describes this instance; when Server.DirectoryURL is set the same JSON is
+// POSTed there every DirectoryInterval so a central directory can list instances.
+// The directory is expected to expire entries that stop refreshing.
+
+// serverInfo — the /info payload and directory registration body.
+type serverInfo struct {
+	Name          string `json:"name"`
+	Address       string `json:"address"` // public host:port clients connect to
+	Region        string `json:"region"`
+	Players       int    `json:"players"`
+	Capacity      int    `json:"capacity"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+func (s *Server) currentInfo() serverInfo {
+	address := s.cfg.Server.PublicAddress
+	if address == "" {
+		address = fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port)
+	}
+	return serverInfo{
+		Name:          s.cfg.Server.Name,
+		Address:       address,
+		Region:        s.cfg.Server.Region,
+		Players:       s.gameWorld.GetPlayerCount(),
+		Capacity:      s.cfg.Net.MaxConnections,
+		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+	}
+}
+
+// handleInfo serves the instance description for server-browser UIs.
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(s.currentInfo())
+}
+
+// runDirectoryRegistration registers with the directory until the server stops.
+func (s *Server) runDirectoryRegistration() {
+	interval := s.cfg.Server.DirectoryInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	slog.Info("directory registration enabled", "url", config.RedactURL(s.cfg.Server.DirectoryURL), "interval_s", interval.Seconds())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.registerWithDirectory(s.ctx); err != nil {
+			metrics.DirectoryRegistrations.WithLabelValues("error").Inc()
+			slog.Warn("directory registration failed", "url", config.RedactURL(s.cfg.Server.DirectoryURL), "error", config.RedactURLError(err))
+		} else {
+			metrics.DirectoryRegistrations.WithLabelValues("ok").Inc()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// registerWithDirectory POSTs the instance description once, within DirectoryTimeout.
+func (s *Server) registerWithDirectory(ctx context.Context) error {
+	body, err := json.Marshal(s.currentInfo())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Server.DirectoryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Server.DirectoryURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("directory responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Deliberate disconnects. Whenever the server drops a client on purpose it first
+// sends a close frame with a protocol.Close* code, so the client can tell a kick from
+// a shutdown and decide whether to reconnect. The close frame goes through the
+// connection's write loop like any other control frame: whatever was queued before
+// it is still written, nothing after it is, and the write loop then runs
+// cleanupConnection. Connections that die on their own (EOF, read/write errors) are
+// closed without a code — there is nobody left to tell.
+
+// closeCodeNames — protocol.CloseCodes by code, for metric labels.
+var closeCodeNames = func() map[uint16]string {
+	names := make(map[uint16]string, len(protocol.CloseCodes))
+	for i := range protocol.CloseCodes {
+		names[protocol.CloseCodes[i].Code] = protocol.CloseCodes[i].ConstName()
+	}
+	return names
+}()
+
+func closeCodeName(code uint16) string {
+	if name, ok := closeCodeNames[code]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// closeConnection sends a close frame with code and reason to c and closes it once
+// the frame is written. Only the first call per connection has an effect. If c's
+// write queue is full the client is not reading anyway, so it is closed right away
+// without the frame. Never blocks.
+func (s *Server) closeConnection(c *Connection, code uint16, reason string) {
+	if !atomic.CompareAndSwapInt32(&c.closing, 0, 1) {
+		return
+	}
+	c.noteDisconnect(causeForCode(code))
+	metrics.WSCloseCodes.WithLabelValues(closeCodeName(code)).Inc()
+	slog.Debug("closing connection",
+		"player_id", c.playerID(),
+		"code", code,
+		"code_name", closeCodeName(code),
+		"reason", reason,
+	)
+	frame := ws.MustCompileFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason)))
+	select {
+	case c.writeCh <- writeJob{control: frame, timeout: s.directWriteTimeout, closeAfter: true}:
+	default:
+		go s.cleanupConnection(c)
+	}
+}
+
+// closeAll closes every joined connection with code and reason and waits up to
+// grace for the close frames to go out; connections still open after that are
+// closed without waiting further.
+func (s *Server) closeAll(code uint16, reason string, grace time.Duration) {
+	s.connectionsMu.RLock()
+	conns := make([]*Connection, 0, len(s.connections))
+	for _, c := range s.connections {
+		conns = append(conns, c)
+	}
+	s.connectionsMu.RUnlock()
+
+	for _, c := range conns {
+		s.closeConnection(c, code, reason)
+	}
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+	expired := false
+	for _, c := range conns {
+		if !expired {
+			select {
+			case <-c.ctx.Done():
+				continue
+			case <-deadline.C:
+				expired = true
+			}
+		}
+		s.cleanupConnection(c)
+	}
+}
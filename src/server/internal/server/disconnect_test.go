@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+type nopReadHandler struct{}
+
+func (nopReadHandler) register(*Server, *Connection) {}
+func (nopReadHandler) remove(*Connection)            {}
+func (nopReadHandler) backlog() int                  { return 0 }
+
+func TestCloseConnectionSendsCode(t *testing.T) {
+	s := &Server{
+		cfg:                testutil.Config(),
+		ctx:                context.Background(),
+		rh:                 nopReadHandler{},
+		directWriteTimeout: time.Second,
+		writeBatchSize:     8,
+	}
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+
+	c.writeCh <- writeJob{direct: []byte{0x0B}, timeout: time.Second}
+	s.closeConnection(c, protocol.CloseKicked, "bye")
+	s.closeConnection(c, protocol.CloseBanned, "ignored") // only the first close counts
+	select {
+	case c.writeCh <- writeJob{direct: []byte{0x0C}, timeout: time.Second}:
+	default:
+	}
+
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after the close frame")
+	}
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("wrote %d frames, want the queued message and the close frame", len(frames))
+	}
+	checkDataFrame(t, frames[0], 1, []byte{0x0B})
+	if frames[1].OpCode != ws.OpClose {
+		t.Fatalf("second frame = %+v, want a close frame", frames[1])
+	}
+	code, reason := ws.ParseCloseFrameData(frames[1].Payload)
+	if uint16(code) != protocol.CloseKicked || reason != "bye" {
+		t.Errorf("close = (%d, %q), want (%d, %q)", code, reason, protocol.CloseKicked, "bye")
+	}
+	// cleanupConnection cancels ctx before closing rawConn.
+	for deadline := time.Now().Add(5 * time.Second); !fake.Closed(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("raw connection left open")
+		}
+	}
+}
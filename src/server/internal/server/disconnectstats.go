@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Disconnect analytics. Every connection, handshakes included, ends with one cause:
+// whoever first decides the connection is over records it with noteDisconnect (later
+// causes are ignored — a kicked client's EOF is still a kick), and cleanupConnection
+// counts it once in game_disconnect_reasons_total{reason,origin} and in a rolling
+// per-minute breakdown served at GET /stats. The origin groups the causes so network
+// trouble is not mistaken for evictions: "client" left on its own, "network" lost
+// the client (errors, timeouts, a client that cannot keep up), "server" dropped it on
+// purpose.
+
+// disconnectCause — why a connection ended; 0 = not recorded.
+type disconnectCause int32
+
+const (
+	causeOther disconnectCause = iota
+	causeClientClose
+	causeReadError
+	causeWriteTimeout
+	causeWriteError
+	causeSlowConsumer
+	causeIdle
+	causeKick
+	causeRateLimit
+	causeProtocolViolation
+	causeDuplicateSession
+	causeShutdown
+	causeInternalError
+	numDisconnectCauses
+)
+
+// disconnectCauses — metric label and origin by cause.
+var disconnectCauses = [numDisconnectCauses]struct{ name, origin string }{
+	causeOther:             {"other", "other"},
+	causeClientClose:       {"client_close", "client"},
+	causeReadError:         {"read_error", "network"},
+	causeWriteTimeout:      {"write_timeout", "network"},
+	causeWriteError:        {"write_error", "network"},
+	causeSlowConsumer:      {"slow_consumer", "network"},
+	causeIdle:              {"idle", "network"},
+	causeKick:              {"kick", "server"},
+	causeRateLimit:         {"rate_limit", "server"},
+	causeProtocolViolation: {"protocol_violation", "server"},
+	causeDuplicateSession:  {"duplicate_session", "server"},
+	causeShutdown:          {"shutdown", "server"},
+	causeInternalError:     {"internal_error", "server"},
+}
+
+func (d disconnectCause) String() string { return disconnectCauses[d].name }
+
+// causeForCode maps a close code sent by closeConnection to its cause.
+func causeForCode(code uint16) disconnectCause {
+	switch code {
+	case protocol.CloseKicked, protocol.CloseBanned:
+		return causeKick
+	case protocol.CloseProtocolViolation, protocol.CloseUnsupportedSubprotocol:
+		return causeProtocolViolation
+	case protocol.CloseIdleTimeout:
+		return causeIdle
+	case protocol.CloseDuplicateSession:
+		return causeDuplicateSession
+	case protocol.CloseServerShutdown:
+		return causeShutdown
+	case protocol.CloseInternalError:
+		return causeInternalError
+	}
+	return causeOther
+}
+
+// noteDisconnect records cause as the reason c ends, unless one is recorded already.
+func (c *Connection) noteDisconnect(cause disconnectCause) {
+	atomic.CompareAndSwapInt32(&c.disconnectCause, 0, int32(cause))
+}
+
+// countDisconnect counts the recorded cause of c. Called once, by cleanupConnection.
+func (s *Server) countDisconnect(c *Connection) {
+	cause := disconnectCause(atomic.LoadInt32(&c.disconnectCause))
+	metrics.DisconnectReasons.WithLabelValues(cause.String(), disconnectCauses[cause].origin).Inc()
+	s.disconnects.record(cause, time.Now())
+}
+
+// disconnectStatsMinutes — how far back the breakdown goes, in one-minute buckets.
+const disconnectStatsMinutes = 60
+
+// disconnectStats — disconnect counts by cause per minute over the last hour, and
+// since start. The zero value is ready to use.
+type disconnectStats struct {
+	mu      sync.Mutex
+	buckets [disconnectStatsMinutes]disconnectBucket // by minute % disconnectStatsMinutes
+	total   [numDisconnectCauses]uint64
+}
+
+type disconnectBucket struct {
+	minute int64 // Unix minute the counts belong to
+	counts [numDisconnectCauses]uint32
+}
+
+func (d *disconnectStats) record(cause disconnectCause, now time.Time) {
+	minute := now.Unix() / 60
+	d.mu.Lock()
+	b := &d.buckets[minute%disconnectStatsMinutes]
+	if b.minute != minute {
+		*b = disconnectBucket{minute: minute}
+	}
+	b.counts[cause]++
+	d.total[cause]++
+	d.mu.Unlock()
+}
+
+// DisconnectBreakdown — disconnects in one window of GET /stats.
+type DisconnectBreakdown struct {
+	Total    uint64            `json:"total"`
+	ByOrigin map[string]uint64 `json:"by_origin"`
+	ByReason map[string]uint64 `json:"by_reason"`
+}
+
+// disconnectWindows — the windows of GET /stats, in minutes; the current minute
+// counts as a whole one.
+var disconnectWindows = []struct {
+	name    string
+	minutes int64
+}{{"1m", 1}, {"5m", 5}, {"15m", 15}, {"1h", 60}}
+
+// snapshot returns the breakdown for every window in disconnectWindows, plus "total"
+// since start.
+func (d *disconnectStats) snapshot(now time.Time) map[string]DisconnectBreakdown {
+	minute := now.Unix() / 60
+	windows := make([][numDisconnectCauses]uint64, len(disconnectWindows))
+
+	d.mu.Lock()
+	for i := range d.buckets {
+		b := &d.buckets[i]
+		age := minute - b.minute
+		for w, win := range disconnectWindows {
+			if age < 0 || age >= win.minutes {
+				continue
+			}
+			for cause, n := range b.counts {
+				windows[w][cause] += uint64(n)
+			}
+		}
+	}
+	total := d.total
+	d.mu.Unlock()
+
+	out := make(map[string]DisconnectBreakdown, len(disconnectWindows)+1)
+	for w, win := range disconnectWindows {
+		out[win.name] = newDisconnectBreakdown(&windows[w])
+	}
+	out["total"] = newDisconnectBreakdown(&total)
+	return out
+}
+
+func newDisconnectBreakdown(counts *[numDisconnectCauses]uint64) DisconnectBreakdown {
+	b := DisconnectBreakdown{
+		ByOrigin: map[string]uint64{"client": 0, "network": 0, "server": 0},
+		ByReason: make(map[string]uint64, numDisconnectCauses),
+	}
+	for cause, n := range counts {
+		info := disconnectCauses[cause]
+		b.Total += n
+		b.ByReason[info.name] = n
+		if n != 0 {
+			b.ByOrigin[info.origin] += n
+		}
+	}
+	return b
+}
+
+// handleStats returns the disconnect breakdown: GET /stats.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		UptimeSeconds int64                          `json:"uptime_seconds"`
+		Players       int                            `json:"players"`
+		Disconnects   map[string]DisconnectBreakdown `json:"disconnects"`
+	}{
+		UptimeSeconds: int64(time.Since(s.startTime).Seconds()),
+		Players:       s.gameWorld.GetPlayerCount(),
+		Disconnects:   s.disconnects.snapshot(time.Now()),
+	})
+}
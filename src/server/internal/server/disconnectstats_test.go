@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestDisconnectStatsWindows(t *testing.T) {
+	var d disconnectStats
+	now := time.Unix(1_700_000_000, 0)
+	d.record(causeKick, now.Add(-2*time.Hour)) // only in total
+	d.record(causeReadError, now.Add(-30*time.Minute))
+	d.record(causeIdle, now.Add(-3*time.Minute))
+	d.record(causeClientClose, now)
+	d.record(causeClientClose, now)
+
+	got := d.snapshot(now)
+	for window, want := range map[string]uint64{"1m": 2, "5m": 3, "15m": 3, "1h": 4, "total": 5} {
+		if got[window].Total != want {
+			t.Errorf("%s: %d disconnects, want %d", window, got[window].Total, want)
+		}
+	}
+	if b := got["1h"]; b.ByReason["read_error"] != 1 || b.ByReason["kick"] != 0 ||
+		b.ByOrigin["client"] != 2 || b.ByOrigin["network"] != 2 || b.ByOrigin["server"] != 0 {
+		t.Errorf("1h = %+v", b)
+	}
+	if n := got["total"].ByOrigin["server"]; n != 1 {
+		t.Errorf("total: %d server disconnects, want 1", n)
+	}
+	if _, ok := got["total"].ByOrigin["other"]; ok {
+		t.Error("origin \"other\" reported without disconnects")
+	}
+}
+
+// TestDisconnectFirstCauseWins: a kicked client that then hangs up counts as a kick, once.
+func TestDisconnectFirstCauseWins(t *testing.T) {
+	s := &Server{
+		cfg:                testutil.Config(),
+		ctx:                context.Background(),
+		rh:                 nopReadHandler{},
+		directWriteTimeout: time.Second,
+		writeBatchSize:     8,
+	}
+	c := s.createConnection(testutil.NewFakeConn())
+	s.closeConnection(c, protocol.CloseKicked, "bye")
+	c.noteDisconnect(causeClientClose)
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after the close frame")
+	}
+	s.cleanupConnection(c)
+
+	total := s.disconnects.snapshot(time.Now())["total"]
+	if total.Total != 1 || total.ByReason["kick"] != 1 {
+		t.Errorf("total = %+v, want one kick", total)
+	}
+}
+
+func TestStatsEndpoint(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	mux := http.NewServeMux()
+	s.registerAdminRoutes(mux)
+	s.disconnects.record(causeWriteTimeout, time.Now())
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /stats = %d", rec.Code)
+	}
+	var body struct {
+		Disconnects map[string]DisconnectBreakdown `json:"disconnects"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if b := body.Disconnects["5m"]; b.ByReason["write_timeout"] != 1 || b.ByOrigin["network"] != 1 {
+		t.Errorf("5m = %+v", b)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"time"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Emotes.
+//
+// EMOTE plays an emote of the Game.Emotes catalog (gameConfig.json "emotes"): the
+// players within Game.EmoteRadius of the emoter, the emoter included, get
+// PLAYER_EMOTE. It is a plain event message — not reliable, not marked critical — so
+// a full send queue drops it like a chat line. An unknown emote, or one sent before
+// the cooldown of the previous emote ran out, is answered with ACTION_REJECTED.
+// Emotes change nothing in the world.
+
+// emoteCatalog — the emotes by ID, with their cooldown and metric label.
+type emoteCatalog [256]struct {
+	name     string // "" = no such emote
+	cooldown time.Duration
+}
+
+// initEmotes builds the emote catalog from the config.
+func (s *Server) initEmotes() {
+	s.emotes = new(emoteCatalog)
+	for _, e := range s.cfg.Game.Emotes {
+		cooldown := time.Duration(e.CooldownMs) * time.Millisecond
+		if cooldown == 0 {
+			cooldown = s.cfg.Game.EmoteCooldown
+		}
+		s.emotes[uint8(e.ID)].name = e.Name
+		s.emotes[uint8(e.ID)].cooldown = cooldown
+	}
+}
+
+// handleEmote relays an EMOTE of c to the players near it. Read path only.
+func (s *Server) handleEmote(c *Connection, emoteID uint8) {
+	e := &s.emotes[emoteID]
+	if e.name == "" {
+		s.rejectAction(c, protocol.MessageEmote, game.Rejection{Reason: protocol.RejectUnknown})
+		return
+	}
+	now := time.Now().UnixNano()
+	if wait := c.emoteReadyNs - now; wait > 0 {
+		s.rejectAction(c, protocol.MessageEmote, game.Rejection{Reason: protocol.RejectCooldown, RetryAfter: time.Duration(wait)})
+		return
+	}
+	c.emoteReadyNs = now + int64(e.cooldown)
+	metrics.Emotes.WithLabelValues(e.name).Inc()
+
+	data := s.protocol.EncodePlayerEmote(c.player.ID, emoteID)
+	near := s.gameWorld.AppendPlayersNear(c.emoteScratch[:0], c.player.ID, s.cfg.Game.EmoteRadius)
+	c.emoteScratch = near
+	s.connectionsMu.RLock()
+	for _, id := range near {
+		if conn := s.connections[id]; conn != nil {
+			s.sendDirect(conn, data)
+		}
+	}
+	s.connectionsMu.RUnlock()
+}
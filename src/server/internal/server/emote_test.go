@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestEmote(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Game.Emotes = []config.EmoteConfig{{ID: 1, Name: "wave"}}
+	cfg.Game.EmoteCooldown = time.Minute
+	cfg.Game.EmoteRadius = 500
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func(x uint32) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		if err := s.gameWorld.ApplyAdminEvent(types.GameEvent{PlayerID: c.player.ID, Type: types.EventTeleport, X: x, Y: 1000}); err != nil {
+			t.Fatal(err)
+		}
+		return c, fake
+	}
+	alice, aliceFake := join(1000)
+	_, bobFake := join(1400)
+	_, carolFake := join(2000)
+	s.gameWorld.Step()
+
+	s.handleEmote(alice, 1)
+	want := s.protocol.EncodePlayerEmote(alice.player.ID, 1)
+	for name, fake := range map[string]*testutil.FakeConn{"alice": aliceFake, "bob": bobFake} {
+		if got := messagesOf(t, fake, protocol.MessagePlayerEmote, 1); len(got) != 1 || string(got[0]) != string(want) {
+			t.Errorf("%s got PLAYER_EMOTEs %x, want %x", name, got, want)
+		}
+	}
+	if got := messagesOf(t, carolFake, protocol.MessagePlayerEmote, 0); len(got) != 0 {
+		t.Errorf("carol, out of range, got %x", got)
+	}
+
+	s.handleEmote(alice, 1)
+	s.handleEmote(alice, 2)
+	got := messagesOf(t, aliceFake, protocol.MessageActionRejected, 2)
+	if len(got) != 2 || got[0][2] != protocol.RejectCooldown || got[1][2] != protocol.RejectUnknown {
+		t.Errorf("ACTION_REJECTED = %x, want cooldown then unknown", got)
+	}
+	if got := messagesOf(t, bobFake, protocol.MessagePlayerEmote, 2); len(got) != 1 {
+		t.Errorf("refused emotes relayed: %x", got)
+	}
+}
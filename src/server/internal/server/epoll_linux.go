@@ -82,7 +82,8 @@ func connFd(nc net.Conn) (int, error) {
 func (ep *epollPoller) register(_ *Server, c *Connection) {
 	fd, err := connFd(c.rawConn)
 	if err != nil {
-		slog.Error("epoll: cannot get fd", "player_id", c.player.ID, "err", err)
+		slog.Error("epoll: cannot get fd", "player_id", c.playerID(), "err", err)
+		c.noteDisconnect(causeInternalError)
 		go ep.svr.cleanupConnection(c)
 		return
 	}
@@ -96,10 +97,11 @@ func (ep *epollPoller) register(_ *Server, c *Connection) {
 		Events: unix.EPOLLIN | unix.EPOLLRDHUP | unix.EPOLLONESHOT,
 		Fd:     int32(fd),
 	}); err != nil {
-		slog.Error("epoll: EPOLL_CTL_ADD failed", "player_id", c.player.ID, "fd", fd, "err", err)
+		slog.Error("epoll: EPOLL_CTL_ADD failed", "player_id", c.playerID(), "fd", fd, "err", err)
 		ep.mu.Lock()
 		delete(ep.fds, fd)
 		ep.mu.Unlock()
+		c.noteDisconnect(causeInternalError)
 		go ep.svr.cleanupConnection(c)
 	}
 }
@@ -114,6 +116,11 @@ func (ep *epollPoller) remove(c *Connection) {
 	unix.EpollCtl(ep.efd, unix.EPOLL_CTL_DEL, c.fd, nil)
 }
 
+// backlog implements readHandler.
+func (ep *epollPoller) backlog() int {
+	return len(ep.jobs)
+}
+
 // rearm re-arms EPOLLONESHOT so a subsequent read event can fire.
 // Must be called after each successful frame read.
 func (ep *epollPoller) rearm(c *Connection) {
@@ -126,10 +133,14 @@ func (ep *epollPoller) rearm(c *Connection) {
 // waitLoop runs as a dedicated goroutine and blocks in EpollWait.
 // On each ready event it either triggers cleanup (HUP/ERR) or enqueues
 // the connection into the worker jobs channel.
+//
+// Nothing here polls: EpollWait blocks without a timeout until a descriptor is
+// ready, and a full jobs channel blocks the loop until a worker frees up. Re-arming
+// instead would fire the same level-triggered event again at once and spin.
 func (ep *epollPoller) waitLoop() {
 	events := make([]unix.EpollEvent, 256)
 	for {
-		n, err := unix.EpollWait(ep.efd, events, 100 /* ms timeout */)
+		n, err := unix.EpollWait(ep.efd, events, -1)
 		if err != nil {
 			if err == unix.EINTR {
 				continue
@@ -149,19 +160,19 @@ func (ep *epollPoller) waitLoop() {
 			}
 
 			if ev.Events&(unix.EPOLLRDHUP|unix.EPOLLHUP|unix.EPOLLERR) != 0 {
+				if ev.Events&unix.EPOLLERR != 0 {
+					c.noteDisconnect(causeReadError)
+				} else {
+					c.noteDisconnect(causeClientClose)
+				}
 				ep.remove(c)
 				go ep.svr.cleanupConnection(c)
 				continue
 			}
 
 			if ev.Events&unix.EPOLLIN != 0 {
-				// Non-blocking send; if the jobs channel is full we re-arm
-				// immediately (level-triggered semantics: epoll will fire again).
-				select {
-				case ep.jobs <- c:
-				default:
-					ep.rearm(c)
-				}
+				// EPOLLONESHOT keeps the descriptor quiet until a worker re-arms it.
+				ep.jobs <- c
 			}
 		}
 	}
@@ -184,41 +195,63 @@ func (ep *epollPoller) processRead(c *Connection) {
 	default:
 	}
 
-	// Set a short read deadline so a misbehaving client can't park a worker.
-	c.rawConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	// Data is ready, so the whole frame must arrive within the deadline: a misbehaving
+	// client can't park a worker by trickling bytes (slow-read watchdog, readlimit.go).
+	c.rawConn.SetReadDeadline(time.Now().Add(ep.svr.readFrameTimeout))
 
-	hdr, err := ws.ReadHeader(c.rawConn)
+	rb := ep.svr.getReadBuf()
+	defer ep.svr.putReadBuf(rb)
+
+	hdr, err := rb.readHeader(c.rawConn, 0)
 	if err != nil {
 		if err == io.EOF || isClosedErr(err) {
 			// Normal close; cleanupConnection will run via HUP event or here.
+			if err == io.EOF {
+				c.noteDisconnect(causeClientClose)
+			} else {
+				c.noteDisconnect(causeReadError) // reset by peer: lost, not closed
+			}
+		} else if isTimeout(err) {
+			ep.svr.readViolation(c, violationSlowRead, 0)
+			return
 		} else {
 			metrics.WSReadErrors.Inc()
+			c.noteDisconnect(causeReadError)
 		}
 		go ep.svr.cleanupConnection(c)
 		return
 	}
+	if ep.svr.frameTooLarge(hdr) {
+		ep.svr.readViolation(c, violationFrameTooLarge, hdr.Length)
+		return
+	}
 
-	// Read the payload.
-	var payload []byte
-	if hdr.Length > 0 {
-		payload = make([]byte, hdr.Length)
-		if _, err := io.ReadFull(c.rawConn, payload); err != nil {
+	payload, err := rb.readPayload(c.rawConn, hdr)
+	if err != nil {
+		if isTimeout(err) {
+			ep.svr.readViolation(c, violationSlowRead, hdr.Length)
+			return
+		}
+		metrics.WSReadErrors.Inc()
+		c.noteDisconnect(causeReadError)
+		go ep.svr.cleanupConnection(c)
+		return
+	}
+	if hdr.Rsv1() {
+		if payload, err = c.inflate(payload); err != nil {
 			metrics.WSReadErrors.Inc()
+			c.noteDisconnect(causeProtocolViolation)
 			go ep.svr.cleanupConnection(c)
 			return
 		}
 	}
 
-	// Client frames must be masked (RFC 6455 §5.3).
-	if hdr.Masked {
-		ws.Cipher(payload, hdr.Mask, 0)
-	}
-
 	// Update liveness timestamp.
 	atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
 
 	switch hdr.OpCode {
 	case ws.OpClose:
+		c.noteDisconnect(causeClientClose)
 		go ep.svr.cleanupConnection(c)
 		return
 
@@ -227,22 +260,22 @@ func (ep *epollPoller) processRead(c *Connection) {
 		pongFrame, compErr := ws.CompileFrame(ws.NewPongFrame(payload))
 		if compErr == nil {
 			select {
-			case c.writeCh <- writeJob{direct: pongFrame, timeout: directWriteTimeout}:
+			case c.writeCh <- writeJob{control: pongFrame, timeout: ep.svr.directWriteTimeout}:
 			default:
 			}
 		}
 
 	case ws.OpPong:
-		// Already updated lastActivity above; nothing else needed.
+		// lastActivity is already updated above.
+		c.notePong(time.Now().UnixNano())
 
 	case ws.OpBinary, ws.OpText:
 		metrics.BytesReceived.Add(float64(len(payload)))
+		atomic.AddInt64(&c.bytesIn, int64(len(payload)))
+		ep.svr.countMessage(len(payload))
 
-		if !c.rateLimiter.Allow() {
-			slog.Warn("rate limit exceeded", "player_id", c.player.ID)
-			metrics.MessagesRateLimited.Inc()
-		} else {
-			ep.svr.processMessage(c, payload)
+		if ep.svr.allowMessage(c, payload) && !ep.svr.dispatchMessage(c, payload) {
+			return // panicked: closing, do not re-arm (recovery.go)
 		}
 
 	default:
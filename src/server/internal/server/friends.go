@@ -0,0 +1,306 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/social"
+)
+
+// Friends (internal/social).
+//
+// FRIEND adds and removes friends of a signed-in account; both accounts get
+// FRIEND_UPDATE while online, and a refused action is answered with COMMAND_RESULT.
+// After JOIN the client gets its whole list, one FRIEND_UPDATE per entry, and its
+// online friends learn that it came online; when it leaves, that it went offline.
+// Every Net.FriendPresenceInterval the online players are checked for a zone change
+// (the World.ZoneCols × ZoneRows grid of the zone metrics), which their friends get
+// as a new online status. Presence covers the players of this server; a session
+// takeover is no change.
+//
+// The website reads and edits the lists through /admin/friends on the admin
+// listener, the same way it manages bans.
+//
+// Changes and presence updates are serialized by friendsMu, which is held while the
+// updates are enqueued, so a client never gets an older status of a friend after a
+// newer one.
+
+var friendActionLabels = [...]string{
+	protocol.FriendAdd:    "add",
+	protocol.FriendRemove: "remove",
+}
+
+// errFriendsUnavailable is what a player is told when the friend log cannot be written.
+var errFriendsUnavailable = errors.New("friend list unavailable, try again later")
+
+var friendStatusLabels = [...]string{
+	protocol.FriendOffline:  "offline",
+	protocol.FriendOnline:   "online",
+	protocol.FriendIncoming: "incoming",
+	protocol.FriendOutgoing: "outgoing",
+	protocol.FriendRemoved:  "removed",
+}
+
+// initFriends opens the friend log and starts the presence loop.
+func (s *Server) initFriends() {
+	store, err := social.Open(s.cfg.Server.FriendsLog, s.cfg.Server.FriendsMax)
+	if err != nil {
+		// Friendships in the log are not shown until it is fixed and the server restarted.
+		slog.Error("friend log unreadable, starting with empty friend lists", "path", s.cfg.Server.FriendsLog, "error", err)
+		store, _ = social.Open("", s.cfg.Server.FriendsMax)
+	}
+	s.friends = store
+	s.friendsOnline = make(map[string]*Connection)
+	if s.cfg.Net.FriendPresenceInterval > 0 {
+		go s.runFriendPresenceLoop()
+	}
+}
+
+// handleFriend runs a FRIEND action of c.
+func (s *Server) handleFriend(c *Connection, action uint8, account string) {
+	if int(action) >= len(friendActionLabels) {
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, "unknown friend action"))
+		return
+	}
+	name := friendActionLabels[action]
+	if c.account == "" {
+		s.refuseFriend(c, name, "sign in to have friends")
+		return
+	}
+	if err := s.changeFriend(action, c.account, account); err != nil {
+		s.refuseFriend(c, name, err.Error())
+		return
+	}
+	metrics.FriendActions.WithLabelValues(name, "ok").Inc()
+}
+
+// refuseFriend answers a FRIEND action that was not done.
+func (s *Server) refuseFriend(c *Connection, action, reason string) {
+	metrics.FriendActions.WithLabelValues(action, "refused").Inc()
+	s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, reason))
+}
+
+// changeFriend adds or removes other as a friend of account and sends both of them
+// what the other is to them now, where online.
+func (s *Server) changeFriend(action uint8, account, other string) error {
+	s.friendsMu.Lock()
+	defer s.friendsMu.Unlock()
+	var err error
+	if action == protocol.FriendAdd {
+		_, err = s.friends.Add(account, other)
+	} else {
+		err = s.friends.Remove(account, other)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, social.ErrAccount), errors.Is(err, social.ErrSelf), errors.Is(err, social.ErrLimit), errors.Is(err, social.ErrNone):
+		return err
+	default:
+		slog.Error("friend change not recorded", "account", account, "other", other, "error", err)
+		return errFriendsUnavailable
+	}
+	if c := s.friendsOnline[account]; c != nil {
+		s.sendFriendUpdate(c, s.friendUpdate(account, other))
+	}
+	if c := s.friendsOnline[other]; c != nil {
+		s.sendFriendUpdate(c, s.friendUpdate(other, account))
+	}
+	return nil
+}
+
+// joinFriends marks the account of a joined connection online: it gets its friend
+// list and, unless it resumed a session, its online friends get its presence.
+func (s *Server) joinFriends(c *Connection, resumed bool) {
+	if c.account == "" {
+		return
+	}
+	s.friendsMu.Lock()
+	defer s.friendsMu.Unlock()
+	s.friendsOnline[c.account] = c
+	c.friendZone = s.friendZoneOf(c)
+	for _, rel := range s.friends.Relations(c.account) {
+		s.sendFriendUpdate(c, s.friendUpdate(c.account, rel.Account))
+	}
+	if !resumed {
+		s.announcePresence(c.account)
+	}
+}
+
+// leaveFriends tells the online friends of a disconnected player that it went
+// offline. A connection whose session was taken over is not online any more.
+func (s *Server) leaveFriends(c *Connection) {
+	if c.account == "" {
+		return
+	}
+	s.friendsMu.Lock()
+	defer s.friendsMu.Unlock()
+	if s.friendsOnline[c.account] != c {
+		return
+	}
+	delete(s.friendsOnline, c.account)
+	s.announcePresence(c.account)
+}
+
+// runFriendPresenceLoop checks for zone changes every FriendPresenceInterval until
+// the server stops.
+func (s *Server) runFriendPresenceLoop() {
+	t := time.NewTicker(s.cfg.Net.FriendPresenceInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.updateFriendZones()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateFriendZones announces every online player that moved to another zone since
+// its friends last heard.
+func (s *Server) updateFriendZones() {
+	if s.gameWorld.Zones() == nil {
+		return
+	}
+	s.friendsMu.Lock()
+	defer s.friendsMu.Unlock()
+	for account, c := range s.friendsOnline {
+		if zone := s.friendZoneOf(c); zone != c.friendZone {
+			c.friendZone = zone
+			s.announcePresence(account)
+		}
+	}
+}
+
+// announcePresence sends the presence of account to its online friends. Called
+// with friendsMu held.
+func (s *Server) announcePresence(account string) {
+	for _, friend := range s.friends.Friends(account) {
+		if c := s.friendsOnline[friend]; c != nil {
+			s.sendFriendUpdate(c, s.friendUpdate(friend, account))
+		}
+	}
+}
+
+// friendUpdate returns what other is to account, with its presence if a friend.
+// Called with friendsMu held.
+func (s *Server) friendUpdate(account, other string) protocol.FriendUpdate {
+	u := protocol.FriendUpdate{ZoneRow: protocol.ZoneNone, ZoneCol: protocol.ZoneNone, Account: other}
+	switch s.friends.Kind(account, other) {
+	case social.KindFriend:
+		u.Status = protocol.FriendOffline
+		if c := s.friendsOnline[other]; c != nil {
+			u.Status = protocol.FriendOnline
+			u.PlayerID = c.player.ID
+			if c.friendZone >= 0 {
+				row, col := s.gameWorld.Zones().Cell(c.friendZone)
+				u.ZoneRow, u.ZoneCol = uint8(min(row, protocol.ZoneNone-1)), uint8(min(col, protocol.ZoneNone-1))
+			}
+		}
+	case social.KindIncoming:
+		u.Status = protocol.FriendIncoming
+	case social.KindOutgoing:
+		u.Status = protocol.FriendOutgoing
+	default:
+		u.Status = protocol.FriendRemoved
+	}
+	return u
+}
+
+// sendFriendUpdate sends one FRIEND_UPDATE, reliably where the client acks.
+func (s *Server) sendFriendUpdate(c *Connection, u protocol.FriendUpdate) {
+	metrics.FriendUpdates.WithLabelValues(friendStatusLabels[u.Status]).Inc()
+	s.sendReliable(c, s.protocol.EncodeFriendUpdate(u), 0)
+}
+
+// friendZoneOf returns the zone of c's player, -1 when zones are off.
+func (s *Server) friendZoneOf(c *Connection) int {
+	zones := s.gameWorld.Zones()
+	if zones == nil {
+		return -1
+	}
+	return zones.Index(c.player.GetX(), c.player.GetY())
+}
+
+// adminFriend — one entry of an /admin/friends list.
+type adminFriend struct {
+	Account  string      `json:"account"`
+	Kind     social.Kind `json:"kind"`
+	Online   bool        `json:"online,omitempty"` // friends only
+	PlayerID uint32      `json:"player_id,omitempty"`
+	Zone     string      `json:"zone,omitempty"` // zone label, e.g. "r1c2"
+}
+
+// handleAdminFriends serves the friend lists to the website:
+//
+//	GET    /admin/friends?account=A           → A's friends and requests, with presence
+//	POST   /admin/friends?account=A&friend=B  → A asks B, or accepts B's request; B as an entry of A's list
+//	DELETE /admin/friends?account=A&friend=B  → the friendship or request is removed (204)
+//
+// Online players get the change as FRIEND_UPDATE, as if they had made it.
+func (s *Server) handleAdminFriends(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	account := q.Get("account")
+	if account == "" {
+		http.Error(w, "account is required", http.StatusBadRequest)
+		return
+	}
+	var action uint8
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.adminFriends(account))
+		return
+	case http.MethodPost:
+		action = protocol.FriendAdd
+	case http.MethodDelete:
+		action = protocol.FriendRemove
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	friend := q.Get("friend")
+	if err := s.changeFriend(action, account, friend); err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, social.ErrNone):
+			status = http.StatusNotFound
+		case errors.Is(err, errFriendsUnavailable):
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	slog.Info("friend list changed by admin", "action", friendActionLabels[action], "account", account, "friend", friend)
+	if action == protocol.FriendRemove {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adminFriend{Account: friend, Kind: s.friends.Kind(account, friend)})
+}
+
+// adminFriends returns the /admin/friends list of account.
+func (s *Server) adminFriends(account string) []adminFriend {
+	s.friendsMu.Lock()
+	defer s.friendsMu.Unlock()
+	relations := s.friends.Relations(account)
+	out := make([]adminFriend, len(relations))
+	for i, rel := range relations {
+		out[i] = adminFriend{Account: rel.Account, Kind: rel.Kind}
+		if c := s.friendsOnline[rel.Account]; c != nil && rel.Kind == social.KindFriend {
+			out[i].Online = true
+			out[i].PlayerID = c.player.ID
+			if c.friendZone >= 0 {
+				out[i].Zone = s.gameWorld.Zones().Label(c.friendZone)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestFriends(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.World.ZoneCols, cfg.World.ZoneRows = 2, 2
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func(account string) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.account = account
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	zoneOf := func(c *Connection) (row, col uint8) {
+		r, cl := s.gameWorld.Zones().Cell(s.friendZoneOf(c))
+		return uint8(r), uint8(cl)
+	}
+	update := func(status uint8, c *Connection, account string) []byte {
+		u := protocol.FriendUpdate{Status: status, ZoneRow: protocol.ZoneNone, ZoneCol: protocol.ZoneNone, Account: account}
+		if c != nil {
+			u.PlayerID = c.player.ID
+			u.ZoneRow, u.ZoneCol = zoneOf(c)
+		}
+		return s.protocol.EncodeFriendUpdate(u)
+	}
+	expect := func(fake *testutil.FakeConn, want ...[]byte) {
+		t.Helper()
+		got := messagesOf(t, fake, protocol.MessageFriendUpdate, len(want))
+		if len(got) != len(want) {
+			t.Fatalf("%d FRIEND_UPDATEs, want %d: %x", len(got), len(want), got)
+		}
+		for i := range want {
+			if string(got[i]) != string(want[i]) {
+				t.Errorf("FRIEND_UPDATE %d = %x, want %x", i, got[i], want[i])
+			}
+		}
+	}
+	alice, aliceFake := join("alice")
+	bob, bobFake := join("bob")
+	anon, anonFake := join("")
+
+	s.handleFriend(alice, protocol.FriendAdd, "bob")
+	s.handleFriend(bob, protocol.FriendAdd, "alice")
+	aliceSent := [][]byte{update(protocol.FriendOutgoing, nil, "bob"), update(protocol.FriendOnline, bob, "bob")}
+	expect(aliceFake, aliceSent...)
+	expect(bobFake, update(protocol.FriendIncoming, nil, "alice"), update(protocol.FriendOnline, alice, "alice"))
+
+	s.handleFriend(anon, protocol.FriendAdd, "alice")
+	if got := messagesOf(t, anonFake, protocol.MessageCommandResult, 1); len(got) != 1 || got[0][1] != protocol.CommandError {
+		t.Errorf("anonymous FRIEND answered %x", got)
+	}
+
+	// Bob walks into the opposite zone.
+	x, y := cfg.World.Width-5, cfg.World.Height-5
+	if bob.player.GetX() > cfg.World.Width/2 {
+		x = 5
+	}
+	if err := s.gameWorld.ApplyAdminEvent(types.GameEvent{PlayerID: bob.player.ID, Type: types.EventTeleport, X: x, Y: y}); err != nil {
+		t.Fatal(err)
+	}
+	s.gameWorld.Step()
+	s.updateFriendZones()
+	aliceSent = append(aliceSent, update(protocol.FriendOnline, bob, "bob"))
+	expect(aliceFake, aliceSent...)
+
+	// Bob leaves and comes back: alice sees bob go offline and online, and bob gets
+	// the list.
+	s.cleanupConnection(bob)
+	aliceSent = append(aliceSent, update(protocol.FriendOffline, nil, "bob"))
+	expect(aliceFake, aliceSent...)
+	bob, bobFake = join("bob")
+	expect(bobFake, update(protocol.FriendOnline, alice, "alice"))
+	aliceSent = append(aliceSent, update(protocol.FriendOnline, bob, "bob"))
+	expect(aliceFake, aliceSent...)
+
+	w := httptest.NewRecorder()
+	s.handleAdminFriends(w, httptest.NewRequest(http.MethodGet, "/admin/friends?account=alice", nil))
+	var list []adminFriend
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Account != "bob" || !list[0].Online || list[0].PlayerID != bob.player.ID || list[0].Zone == "" {
+		t.Errorf("alice's friends = %+v", list)
+	}
+
+	// The website ends the friendship; both players hear of it.
+	w = httptest.NewRecorder()
+	s.handleAdminFriends(w, httptest.NewRequest(http.MethodDelete, "/admin/friends?account=bob&friend=alice", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", w.Code, w.Body)
+	}
+	expect(aliceFake, append(aliceSent, update(protocol.FriendRemoved, nil, "bob"))...)
+	expect(bobFake, update(protocol.FriendOnline, alice, "alice"), update(protocol.FriendRemoved, nil, "alice"))
+}
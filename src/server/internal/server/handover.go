@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Blue/green handover between two server processes on the same host.
+//
+// The running (old) process listens on HANDOVER_SOCKET. A new process started with the
+// same HANDOVER_SOCKET connects to it from New, before it accepts anyone:
+//
+//	new → old  handoverRequest (JSON)
+//	old        pauses the world (maintenance: new connections get 503 + Retry-After),
+//	           saves the players and closes its friend, inventory and record logs
+//	old → new  1 byte: 1 = listener FD attached (SCM_RIGHTS), 0 = none
+//	old → new  game.ExportedState (JSON)
+//	new        imports the state and adopts the bots
+//	new → old  handoverAck (JSON)
+//	new        opens the logs, which now hold everything the old process wrote
+//	old        on success closes its listener and connections and returns from Start;
+//	           on failure reopens its logs, resumes the world and keeps serving.
+//
+// Player sockets stay with the old process — those clients reconnect. The bots, the
+// player ID counter and (HANDOVER_LISTENER=1) the listening socket move over, so the
+// port keeps accepting throughout the deploy.
+
+const (
+	handoverTimeout    = 10 * time.Second
+	handoverRetryAfter = 1 // seconds; the new process accepts right after the handover
+)
+
+type handoverRequest struct {
+	Version  int  `json:"version"`
+	Listener bool `json:"listener"` // wants the listening socket FD
+}
+
+type handoverAck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// takeOver inherits world state (and optionally the listener) from a running process.
+// Returns false when there is no peer or the handover failed; the caller starts fresh.
+func (s *Server) takeOver(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false // nobody to take over from (first start or stale socket)
+	}
+	uc := conn.(*net.UnixConn)
+	defer uc.Close()
+	uc.SetDeadline(time.Now().Add(handoverTimeout))
+	slog.Warn("taking over from running server", "socket", path)
+
+	req := handoverRequest{Version: game.ExportedStateVersion, Listener: s.cfg.Server.HandoverListener}
+	if err := json.NewEncoder(uc).Encode(req); err != nil {
+		return s.handoverFailed(err)
+	}
+	ln, err := recvListener(uc)
+	if err != nil {
+		return s.handoverFailed(err)
+	}
+	var st game.ExportedState
+	if err := json.NewDecoder(uc).Decode(&st); err != nil {
+		if ln != nil {
+			ln.Close()
+		}
+		return s.handoverFailed(err)
+	}
+
+	// Игроки без сокета здесь не нужны: их клиенты переподключатся и получат новые ID.
+	total := len(st.Players)
+	bots := st.Players[:0]
+	for _, p := range st.Players {
+		if game.IsBotID(p.ID) {
+			bots = append(bots, p)
+		}
+	}
+	st.Players = bots
+
+	players, err := s.gameWorld.ImportState(&st)
+	if err != nil {
+		if ln != nil {
+			ln.Close()
+		}
+		json.NewEncoder(uc).Encode(handoverAck{Error: err.Error()})
+		return s.handoverFailed(err)
+	}
+	adopted := 0
+	for _, p := range players {
+		if s.bots.Adopt(p) {
+			adopted++
+		} else {
+			s.gameWorld.RemovePlayer(p.ID) // over BOT_MAX
+		}
+	}
+
+	if err := json.NewEncoder(uc).Encode(handoverAck{OK: true}); err != nil {
+		// Старый процесс не увидит ACK и продолжит работу сам — состояние уже у нас.
+		slog.Error("handover ack failed", "error", err)
+	}
+	s.listener = ln
+	s.tookOver = true
+	metrics.Handovers.WithLabelValues("in", "ok").Inc()
+	slog.Warn("took over world state",
+		"tick", st.Tick,
+		"bots", adopted,
+		"players_dropped", total-len(bots),
+		"listener", ln != nil)
+	return true
+}
+
+func (s *Server) handoverFailed(err error) bool {
+	metrics.Handovers.WithLabelValues("in", "error").Inc()
+	slog.Error("handover failed, starting fresh", "error", err)
+	return false
+}
+
+// serveHandover accepts handover requests from a newer process until one succeeds,
+// then shuts this server down. ln is the public listener offered to the new process.
+func (s *Server) serveHandover(ln net.Listener) {
+	path := s.cfg.Server.HandoverSocket
+	os.Remove(path) // a live peer was already taken over in New; this is a stale file
+	hl, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		slog.Error("handover socket listen failed", "path", path, "error", err)
+		return
+	}
+	go func() {
+		<-s.ctx.Done()
+		hl.Close()
+	}()
+
+	for {
+		uc, err := hl.AcceptUnix()
+		if err != nil {
+			return
+		}
+		if s.handOver(uc, ln) {
+			hl.SetUnlinkOnClose(false) // путь теперь принадлежит новому процессу
+			hl.Close()
+			s.shutdownAfterHandover()
+			return
+		}
+	}
+}
+
+// handOver runs the old-process side of the protocol. Returns true once the new
+// process acknowledged the state.
+func (s *Server) handOver(uc *net.UnixConn, ln net.Listener) bool {
+	defer uc.Close()
+	uc.SetDeadline(time.Now().Add(handoverTimeout))
+
+	dec := json.NewDecoder(uc)
+	var req handoverRequest
+	if err := dec.Decode(&req); err != nil {
+		slog.Warn("bad handover request", "error", err)
+		return false
+	}
+	if req.Version != game.ExportedStateVersion {
+		slog.Warn("handover version mismatch", "peer", req.Version, "ours", game.ExportedStateVersion)
+		return false
+	}
+	slog.Warn("handover requested", "listener", req.Listener)
+
+	s.pauseNow(handoverRetryAfter)
+	s.closeStores()
+	st := s.gameWorld.ExportState()
+
+	sent, err := sendListener(uc, ln, req.Listener)
+	if err == nil {
+		err = json.NewEncoder(uc).Encode(st)
+	}
+	var ack handoverAck
+	if err == nil {
+		err = dec.Decode(&ack)
+	}
+	if err == nil && !ack.OK {
+		err = errors.New(ack.Error)
+	}
+	if err != nil {
+		metrics.Handovers.WithLabelValues("out", "error").Inc()
+		slog.Error("handover failed, resuming", "error", err)
+		s.reopenStores()
+		s.endMaintenance()
+		return false
+	}
+
+	metrics.Handovers.WithLabelValues("out", "ok").Inc()
+	slog.Warn("world state handed over", "players", len(st.Players), "listener", sent)
+	return true
+}
+
+// shutdownAfterHandover closes the public listener and every client connection so
+// Start returns and the process can exit.
+func (s *Server) shutdownAfterHandover() {
+	// The players now live in the new process: tell clients to reconnect to it.
+	s.closeAll(protocol.CloseServerShutdown, "server restarting", s.directWriteTimeout)
+
+	s.cancel()
+	s.gameWorld.Stop()
+	s.closeHTTP()
+	s.flushLeaderboard(context.Background()) // the in-memory board does not survive the handover
+	s.leaderboard.Close()
+}
+
+// closeStores writes the last player records and closes the friend, inventory and
+// player record logs before the state goes out: the new process replays them once it
+// has the state, so nothing may reach them after that. Changes made here from now on
+// stay in memory — the players are about to reconnect to the new process.
+func (s *Server) closeStores() {
+	s.checkpointPlayers()
+	s.flushRecords()
+	s.friends.Close()
+	s.inventory.Close()
+	s.records.Close()
+}
+
+// reopenStores undoes closeStores when the handover failed and we keep serving.
+func (s *Server) reopenStores() {
+	for _, err := range []error{
+		s.friends.Reopen(s.cfg.Server.FriendsLog),
+		s.inventory.Reopen(s.cfg.Server.InventoryLog),
+		s.records.Reopen(s.cfg.Server.PlayerRecordsLog),
+	} {
+		if err != nil {
+			slog.Error("log not reopened after a failed handover, changes stay in memory", "error", err)
+		}
+	}
+}
+
+// listen binds the public address, with SO_REUSEPORT when reusePort is set
+// (reuseport.go). After a handover without the listener FD the old process releases
+// the port only once it has our ACK, so retry for a moment.
+func (s *Server) listen(addr string, reusePort bool) (net.Listener, error) {
+	var lc net.ListenConfig
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	deadline := time.Now().Add(handoverTimeout)
+	for {
+		ln, err := lc.Listen(context.Background(), "tcp", addr)
+		if err == nil || !s.tookOver || time.Now().After(deadline) {
+			return ln, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
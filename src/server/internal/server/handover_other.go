@@ -0,0 +1,27 @@
+//go:build !unix
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// sendListener: FD passing needs SCM_RIGHTS; only the state is handed over here.
+func sendListener(uc *net.UnixConn, ln net.Listener, want bool) (bool, error) {
+	_, err := uc.Write([]byte{0})
+	return false, err
+}
+
+// recvListener reads the handover marker byte; listener FDs are not supported.
+func recvListener(uc *net.UnixConn) (net.Listener, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(uc, b[:]); err != nil {
+		return nil, err
+	}
+	if b[0] != 0 {
+		return nil, errors.New("listener handover is not supported on this platform")
+	}
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/social"
+	"pixi_game_server/internal/testutil"
+)
+
+// TestHandoverKeepsLogs hands a server over to a new one and checks that what the old
+// process wrote right before — the players' last records, friend requests, items —
+// reaches the new one. A refused handover first checks that the old process gets its logs back.
+func TestHandoverKeepsLogs(t *testing.T) {
+	dir, err := os.MkdirTemp("", "handover") // unix socket paths are short
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	newCfg := func() *config.Config {
+		cfg := testutil.Config()
+		cfg.Server.HandoverSocket = filepath.Join(dir, "handover.sock")
+		cfg.Server.HandoverListener = false
+		cfg.Server.FriendsLog = filepath.Join(dir, "friends.log")
+		cfg.Server.InventoryLog = filepath.Join(dir, "inventory.log")
+		cfg.Server.PlayerRecordsLog = filepath.Join(dir, "records.log")
+		return cfg
+	}
+
+	old := New(newCfg(), nil)
+	old.gameWorld.SetPaused(true)
+	old.rh = nopReadHandler{}
+	handedOver := make(chan struct{})
+	go func() {
+		old.serveHandover(nil)
+		close(handedOver)
+	}()
+	t.Cleanup(func() {
+		old.cancel()
+		select {
+		case <-handedOver:
+		default:
+			old.gameWorld.Stop()
+		}
+	})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if _, err := os.Stat(old.cfg.Server.HandoverSocket); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("handover socket not created")
+		}
+	}
+
+	alice, _ := joinAccount(old, "alice")
+	st, _ := old.gameWorld.AdminPlayerState(alice.player.ID)
+	if _, err := old.inventory.Grant("alice", 7, 3, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer that refuses the state: the old process keeps serving and appending.
+	conn, err := net.Dial("unix", old.cfg.Server.HandoverSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	json.NewEncoder(conn).Encode(handoverRequest{Version: game.ExportedStateVersion})
+	var marker [1]byte
+	var state game.ExportedState
+	if _, err := io.ReadFull(conn, marker[:]); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	json.NewEncoder(conn).Encode(handoverAck{Error: "refused"})
+	io.Copy(io.Discard, io.MultiReader(dec.Buffered(), conn)) // closed once handOver returns
+	conn.Close()
+	if _, err := old.friends.Add("alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.inventory.Grant("alice", 7, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(newCfg(), nil)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	if !s.tookOver {
+		t.Fatal("the new server did not take over")
+	}
+	select {
+	case <-handedOver:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the old server did not shut down")
+	}
+
+	if r, ok := s.records.Load("alice"); !ok || r.X != st.X || r.Y != st.Y || r.HP != st.HP {
+		t.Errorf("alice's record after the handover = %+v, %v; want the player at (%d,%d) with %d HP", r, ok, st.X, st.Y, st.HP)
+	}
+	if kind := s.friends.Kind("alice", "bob"); kind != social.KindOutgoing {
+		t.Errorf("alice → bob after the handover = %q, want %q", kind, social.KindOutgoing)
+	}
+	if n := s.inventory.Count("alice", 7); n != 4 {
+		t.Errorf("alice has %d of item 7 after the handover, want 4", n)
+	}
+}
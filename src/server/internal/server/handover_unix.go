@@ -0,0 +1,67 @@
+//go:build unix
+
+package server
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// sendListener sends the handover marker byte, with ln's FD attached when the peer
+// wants it and ln is a TCP listener. Reports whether the FD was sent.
+func sendListener(uc *net.UnixConn, ln net.Listener, want bool) (bool, error) {
+	tl, ok := ln.(*net.TCPListener)
+	if !want || !ok {
+		_, err := uc.Write([]byte{0})
+		return false, err
+	}
+	f, err := tl.File() // dup: closing f does not affect ln
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, _, err := uc.WriteMsgUnix([]byte{1}, syscall.UnixRights(int(f.Fd())), nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recvListener reads the handover marker byte; returns the inherited listener or nil.
+func recvListener(uc *net.UnixConn) (net.Listener, error) {
+	var b [1]byte
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := uc.ReadMsgUnix(b[:], oob)
+	if err != nil {
+		return nil, err
+	}
+	if n != 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if b[0] == 0 {
+		return nil, nil
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("expected 1 control message, got %d", len(msgs))
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("expected 1 fd, got %d", len(fds))
+	}
+	f := os.NewFile(uintptr(fds[0]), "handover-listener")
+	defer f.Close() // FileListener dups the fd
+	return net.FileListener(f)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Connection handshake.
+//
+// After the WebSocket upgrade a connection is "half-open": it has a write loop and
+// is registered with the read handler, but owns no Player and is not in
+// s.connections (no broadcasts). The client must send JOIN within
+// Net.HandshakeTimeout; only then is the Player allocated and the initial state
+// sent. Any other message before JOIN closes the connection. Half-open connections
+// are capped by Net.MaxPendingHandshakes so slow/idle upgrades cannot pile up. On a
+// full server the JOIN may wait in the login queue first (loginqueue.go).
+
+// Handshake states (Connection.state).
+const (
+	connAwaitingJoin int32 = iota // upgraded, waiting for JOIN
+	connJoining                   // JOIN received, player being set up
+	connJoined                    // player in world and in s.connections
+	connClosed                    // cleanupConnection ran (or handshake timed out)
+	connTransferred               // player handed to a new session of the same account (see session.go)
+	connQueued                    // JOIN held in the login queue (see loginqueue.go)
+)
+
+// playerID returns the connection's player ID, or 0 before the handshake completes.
+func (c *Connection) playerID() uint32 {
+	if atomic.LoadInt32(&c.state) != connJoined {
+		return 0
+	}
+	return c.player.ID
+}
+
+// reserveHandshake counts a new half-open connection. Returns false when the
+// MaxPendingHandshakes limit is reached.
+func (s *Server) reserveHandshake() bool {
+	n := atomic.AddInt32(&s.pendingHandshakes, 1)
+	if limit := s.cfg.Net.MaxPendingHandshakes; limit > 0 && int(n) > limit {
+		atomic.AddInt32(&s.pendingHandshakes, -1)
+		return false
+	}
+	metrics.HandshakesPending.Set(float64(n))
+	return true
+}
+
+// releaseHandshake undoes reserveHandshake once the connection leaves connAwaitingJoin.
+func (s *Server) releaseHandshake() {
+	metrics.HandshakesPending.Set(float64(atomic.AddInt32(&s.pendingHandshakes, -1)))
+}
+
+// startHandshakeTimer closes c if JOIN does not arrive within Net.HandshakeTimeout.
+func (s *Server) startHandshakeTimer(c *Connection) {
+	if s.cfg.Net.HandshakeTimeout <= 0 {
+		return
+	}
+	c.handshakeTimer = time.AfterFunc(s.cfg.Net.HandshakeTimeout, func() {
+		if !atomic.CompareAndSwapInt32(&c.state, connAwaitingJoin, connClosed) {
+			return // joined (or closed) in time
+		}
+		s.releaseHandshake()
+		metrics.Handshakes.WithLabelValues("timeout").Inc()
+		s.closeConnection(c, protocol.CloseIdleTimeout, "join timeout")
+	})
+}
+
+// handleHandshakeMessage processes a message from a connection that has not joined yet.
+func (s *Server) handleHandshakeMessage(c *Connection, message []byte) {
+	msgs, err := s.protocol.DecodeClientMessage(message)
+	if err != nil || msgs[0].Type != protocol.MessageJoin {
+		metrics.Handshakes.WithLabelValues("invalid").Inc()
+		slog.Debug("message before join, closing", "remote_addr", c.rawConn.RemoteAddr(), "error", err)
+		s.closeConnection(c, protocol.CloseProtocolViolation, "expected JOIN")
+		return
+	}
+	s.completeJoin(c, &msgs[0])
+}
+
+// completeJoin ends the handshake of c and lets it join, or queue when the server is full.
+func (s *Server) completeJoin(c *Connection, join *protocol.ClientMessage) {
+	if !atomic.CompareAndSwapInt32(&c.state, connAwaitingJoin, connJoining) {
+		return // duplicate JOIN or already timed out
+	}
+	if c.handshakeTimer != nil {
+		c.handshakeTimer.Stop()
+	}
+	s.releaseHandshake()
+	s.applyCapabilities(c, join)
+	if s.queueJoin(c) {
+		return
+	}
+	s.joinPlayer(c)
+}
+
+// joinPlayer allocates the player of c (connJoining) and promotes c to a full game
+// connection.
+func (s *Server) joinPlayer(c *Connection) {
+	player, ok := s.claimSession(c)
+	if !ok {
+		metrics.Handshakes.WithLabelValues("duplicate").Inc()
+		s.closeConnection(c, protocol.CloseDuplicateSession, "account already connected")
+		return
+	}
+	metrics.Handshakes.WithLabelValues("joined").Inc()
+
+	s.startSealing(c)
+	resumed := player != nil
+	if !resumed {
+		player = s.gameWorld.AddPlayer()
+	}
+	c.player = player
+
+	// Send CONFIG and the initial state BEFORE adding to s.connections so that the
+	// write loop delivers them as the very first messages the client receives. If
+	// we add to the map first, a 30 Hz tick can race here and enqueue a
+	// delta/gamestate frame ahead of the initial state.
+	s.sendConfig(c)
+	s.placeOrigin(c)
+	s.sendInitialState(c)
+	s.sendActiveWorldEvents(c)
+	s.sendMaintenanceStatus(c)
+	s.sendMOTD(c)
+
+	s.connectionsMu.Lock()
+	s.connections[player.ID] = c
+	s.connectionsMu.Unlock()
+
+	// Notify all existing players about the new player (a taken-over player never left)
+	if !resumed {
+		s.notifyPlayerJoined(player)
+		metrics.PlayersConnected.Inc()
+		if s.scripts != nil {
+			s.scripts.PlayerJoined(player.ID)
+		}
+	} else {
+		s.resumeParty(c)
+	}
+	s.joinFriends(c, resumed)
+	s.joinInventory(c, resumed)
+	metrics.ConnectionsTotal.Inc()
+
+	if !atomic.CompareAndSwapInt32(&c.state, connJoining, connJoined) {
+		// cleanupConnection ran while we were joining and skipped the player part.
+		s.unregisterPlayer(c)
+		drainWriteCh(c.writeCh)
+		s.gameWorld.RemovePlayer(player.ID)
+	}
+}
+
+// sendConfig sends CONFIG: the gameplay constants the client must use instead of its
+// bundled gameConfig.json, and its own player ID.
+func (s *Server) sendConfig(c *Connection) {
+	bounds := s.gameWorld.Bounds()
+	s.sendDirect(c, s.protocolFor(c).EncodeConfig(protocol.GameConfig{
+		PlayerID:           c.player.ID,
+		TickRate:           uint8(min(s.cfg.Game.TickRate, math.MaxUint8)),
+		PlayerSpeedPerTick: uint16(min(s.cfg.Game.PlayerSpeedPerTick, math.MaxUint16)),
+		WorldWidth:         bounds.Width,
+		WorldHeight:        bounds.Height,
+		BoundaryMode:       protocol.BoundaryClamp,
+		Acceleration:       uint16(s.cfg.Game.PlayerAcceleration),
+		Friction:           uint16(s.cfg.Game.PlayerFriction),
+		WorldSeed:          s.worldSeed,
+	}))
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestHitSentToAttackerAndVictim(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Game.AttackDamage, cfg.Game.Knockback = 25, 10
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func() (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	attacker, attackerFake := join()
+	victim, victimFake := join()
+	bystander, bystanderFake := join()
+	for id, x := range map[uint32]uint32{attacker.player.ID: 1000, victim.player.ID: 1020, bystander.player.ID: 3000} {
+		if err := s.gameWorld.ApplyAdminEvent(types.GameEvent{PlayerID: id, Type: types.EventTeleport, X: x, Y: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attacker.player.SetFacingRight(true)
+	s.gameWorld.Step()
+
+	if _, _, ok := s.gameWorld.TryAttack(attacker.player.ID); !ok {
+		t.Fatal("attack rejected")
+	}
+	s.gameWorld.Step()
+
+	want := s.protocol.EncodeHit(attacker.player.ID, victim.player.ID, 25, uint16(cfg.Game.MaxHP-25), 10, 0)
+	hits := func(fake *testutil.FakeConn) (n int) {
+		t.Helper()
+		frames, err := fake.Frames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range frames {
+			if msg := f.Payload[seqHeaderSize:]; msg[0] == protocol.MessageHit {
+				if string(msg) != string(want) {
+					t.Errorf("HIT = %x, want %x", msg, want)
+				}
+				n++
+			}
+		}
+		return n
+	}
+	for deadline := time.Now().Add(5 * time.Second); hits(attackerFake) == 0 || hits(victimFake) == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("HIT delivered to attacker %d times, victim %d times", hits(attackerFake), hits(victimFake))
+		}
+	}
+	if n := hits(bystanderFake); n != 0 {
+		t.Errorf("bystander got %d HITs", n)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"errors"
+	"net"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// In-process transport. acceptConn serves a connection that never went through /ws —
+// in tests, the server end of testutil.Pipe — exactly as handleWebSocket serves an
+// upgraded one: the client still speaks WebSocket frames, sends JOIN first and gets
+// the same handshake, broadcasts and close codes. Only the HTTP admission chain and
+// the upgrade are skipped. The descriptor-less connection is read by a goroutine of
+// its own (readloop.go) instead of epoll, so thousands of simulated clients can run
+// in one test without sockets.
+
+// errTooManyHandshakes — acceptConn at the MaxPendingHandshakes limit.
+var errTooManyHandshakes = errors.New("too many pending connections")
+
+// acceptConn serves rawConn as a newly upgraded connection from ip.
+func (s *Server) acceptConn(rawConn net.Conn, ip string) (*Connection, error) {
+	if !s.reserveHandshake() {
+		metrics.Handshakes.WithLabelValues("rejected").Inc()
+		return nil, errTooManyHandshakes
+	}
+	c := s.createConnection(rawConn)
+	c.ip = ip
+	c.role = s.roleOf("")
+	c.inProcess = true
+	s.startHandshakeTimer(c)
+	s.readHandlerOf(c).register(s, c)
+	return c, nil
+}
+
+// readHandlerOf returns the read handler serving c.
+func (s *Server) readHandlerOf(c *Connection) readHandler {
+	if c.inProcess {
+		return &goroutineReadHandler{}
+	}
+	return s.rh
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+// pipeClient is a simulated client over testutil.Pipe: the test writes its messages,
+// a goroutine reads everything the server sends and counts it by message type.
+type pipeClient struct {
+	conn   *testutil.PipeConn
+	got    [256]atomic.Int32 // messages received, by type
+	closed chan struct{}     // the server closed the connection or it broke
+	code   ws.StatusCode     // close code; valid once closed is
+}
+
+func dialInProcess(t testing.TB, s *Server, ip string) *pipeClient {
+	t.Helper()
+	client, server := testutil.Pipe()
+	if _, err := s.acceptConn(server, ip); err != nil {
+		t.Fatal(err)
+	}
+	pc := &pipeClient{conn: client, closed: make(chan struct{})}
+	go pc.readLoop()
+	return pc
+}
+
+func (pc *pipeClient) readLoop() {
+	defer close(pc.closed)
+	for {
+		f, err := testutil.ReadFrame(pc.conn)
+		if err != nil {
+			return
+		}
+		switch f.OpCode {
+		case ws.OpClose:
+			pc.code, _ = ws.ParseCloseFrameData(f.Payload)
+			return
+		case ws.OpBinary:
+			if len(f.Payload) > seqHeaderSize {
+				pc.got[f.Payload[seqHeaderSize]].Add(1)
+			}
+		}
+	}
+}
+
+func (pc *pipeClient) send(t testing.TB, msg []byte) {
+	t.Helper()
+	if err := testutil.WriteClientFrame(pc.conn, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitAll waits until cond holds for every client.
+func waitAll(t testing.TB, what string, clients []*pipeClient, cond func(*pipeClient) bool) {
+	t.Helper()
+	deadline := time.Now().Add(20 * time.Second)
+	for _, pc := range clients {
+		for !cond(pc) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+// TestInProcessClients runs a crowd of clients through the whole server — join,
+// move, tick broadcasts, disconnects, shutdown — over in-memory connections.
+func TestInProcessClients(t *testing.T) {
+	n := 2000
+	if testing.Short() || raceEnabled {
+		n = 200
+	}
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Game.TickRate = 20
+	cfg.Net.MaxPendingHandshakes = 0
+	s := New(cfg, nil)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	clients := make([]*pipeClient, n)
+	for i := range clients {
+		clients[i] = dialInProcess(t, s, fmt.Sprintf("10.0.%d.%d", i/250, i%250+1))
+		clients[i].send(t, []byte{protocol.MessageJoin, protocol.CapDeltaUpdates, 0, 0, 0, 0})
+	}
+	waitAll(t, "CONFIG after JOIN", clients, func(pc *pipeClient) bool { return pc.got[protocol.MessageConfig].Load() > 0 })
+	if got := s.gameWorld.GetPlayerCount(); got != n {
+		t.Fatalf("%d players in the world, want %d: %+v", got, n, s.disconnects.snapshot(time.Now())["total"].ByReason)
+	}
+
+	states := func(pc *pipeClient) int32 {
+		return pc.got[protocol.MessageGameState].Load() + pc.got[protocol.MessageDeltaGameState].Load()
+	}
+	before := make([]int32, n)
+	for i, pc := range clients {
+		before[i] = states(pc)
+		pc.send(t, []byte{protocol.MessageMove, protocol.PackMovement(1, 0), 0, 0, 0, 0})
+	}
+	waitAll(t, "MOVEMENT_ACK", clients, func(pc *pipeClient) bool { return pc.got[protocol.MessageMovementAck].Load() > 0 })
+	for i, pc := range clients {
+		waitAll(t, "a tick broadcast after MOVE", []*pipeClient{pc}, func(pc *pipeClient) bool { return states(pc) > before[i] })
+	}
+
+	// Half the crowd hangs up; the rest stays until shutdown.
+	gone, staying := clients[:n/2], clients[n/2:]
+	for _, pc := range gone {
+		pc.conn.Close()
+	}
+	for deadline := time.Now().Add(10 * time.Second); s.gameWorld.GetPlayerCount() != len(staying); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d players left after %d hung up, want %d", s.gameWorld.GetPlayerCount(), len(gone), len(staying))
+		}
+	}
+	if left := s.disconnects.snapshot(time.Now())["total"].ByReason["client_close"]; left != uint64(len(gone)) {
+		t.Errorf("%d client closes counted, want %d", left, len(gone))
+	}
+	waitAll(t, "PLAYER_LEFT", staying, func(pc *pipeClient) bool { return pc.got[protocol.MessagePlayerLeft].Load() > 0 })
+
+	// A close frame finds no room in a full write queue: let the PLAYER_LEFT storm drain.
+	for deadline := time.Now().Add(10 * time.Second); queued(s) > 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d writes still queued", queued(s))
+		}
+	}
+	s.closeAll(protocol.CloseServerShutdown, "test over", 5*time.Second)
+	for _, pc := range staying {
+		<-pc.closed
+		if pc.code != protocol.CloseServerShutdown {
+			t.Fatalf("closed with %d, want %d", pc.code, protocol.CloseServerShutdown)
+		}
+	}
+}
+
+// queued returns the number of writes queued for all joined connections.
+func queued(s *Server) int {
+	s.connectionsMu.RLock()
+	defer s.connectionsMu.RUnlock()
+	n := 0
+	for _, c := range s.connections {
+		n += len(c.writeCh)
+	}
+	return n
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Interest scoring (Net.ClientTickBudget > 0).
+//
+// A delta over a client's per-tick budget — a crowd, a big fight — is cut to the K
+// updates that matter most to that client instead of going out whole and late or being
+// shed with the rest of the tick. Every candidate, a changed player the client sees, is
+// scored for the client:
+//
+//   - distance: 1 at the viewer, ½ at interestFalloff world units;
+//   - interaction: party members and players it hit or was hit by within
+//     interactionWindow;
+//   - change: how far the player moved, or whether its state or facing changed, since
+//     the update this client last got of it — a player the client has never been sent
+//     (it just came into view) counts as fully changed;
+//   - staleness: how long ago this client last got it, so a player that stays in view
+//     while others keep winning still gets its turn.
+//
+// The viewer itself always goes first. The rest is deferred: deferred players stay
+// candidates on the next ticks with their current state, even if they stop changing,
+// until they are sent, leave the view or a full sync (never cut) covers them. Clients
+// on the shared frame move to their own frames (aoi.go) while the shared delta is over
+// the budget or they have deferred players.
+
+const (
+	interestFalloff   = 400 // world units at which the distance score halves
+	interestMoveScale = 64  // world units moved that count as a full change
+	interactionWindow = 3 * time.Second
+	stalenessScale    = time.Second // staleness score per second since the last update sent, capped at 2
+
+	interestWeightDistance    = 1.0
+	interestWeightInteraction = 2.0
+	interestWeightChange      = 1.0
+	interestWeightStaleness   = 0.5
+)
+
+// interestState — what a client was last sent of each player. gameLoop only.
+type interestState struct {
+	sent       map[uint32]sentEntity
+	deferred   []uint32         // players cut from earlier deltas, not sent since
+	interacted map[uint32]int64 // player → UnixNano of the last hit between them
+}
+
+// sentEntity — the state of a player in the last update a client got of it.
+type sentEntity struct {
+	x, y   uint32
+	state  uint8
+	facing bool
+	atNs   int64
+}
+
+// scoredEntity — a candidate update and its score.
+type scoredEntity struct {
+	st    types.PlayerState
+	score float64
+}
+
+// playerIndex — indices into the tick's player list by player ID.
+type playerIndex struct {
+	seq uint32 // stateSequence the index was built for
+	ids map[uint32]int32
+}
+
+func (c *Connection) interestState() *interestState {
+	if c.interest == nil {
+		c.interest = &interestState{sent: make(map[uint32]sentEntity), interacted: make(map[uint32]int64)}
+	}
+	return c.interest
+}
+
+// cutting reports whether c has deferred players to catch up on.
+func (c *Connection) cutting() bool {
+	return c.interest != nil && len(c.interest.deferred) > 0
+}
+
+// noteInteraction records a hit between c's player and other. gameLoop only.
+func (c *Connection) noteInteraction(other uint32, nowNs int64) {
+	c.interestState().interacted[other] = nowNs
+}
+
+// sentAll records a full world state, which supersedes whatever was deferred, and
+// forgets interactions past the window.
+func (is *interestState) sentAll(players []types.PlayerState, nowNs int64) {
+	clear(is.sent)
+	is.deferred = is.deferred[:0]
+	is.record(players, nowNs)
+	for id, at := range is.interacted {
+		if nowNs-at >= interactionWindow.Nanoseconds() {
+			delete(is.interacted, id)
+		}
+	}
+}
+
+func (is *interestState) record(players []types.PlayerState, nowNs int64) {
+	for i := range players {
+		st := &players[i]
+		is.sent[st.ID] = sentEntity{x: st.X, y: st.Y, state: st.State, facing: st.FacingRight, atNs: nowNs}
+	}
+}
+
+// prioritize cuts s.aoiScratch, the delta c gets this tick, to the most relevant
+// updates that fit Net.ClientTickBudget, adding c's deferred players that filter still
+// lets through. Whatever does not fit is deferred.
+func (s *Server) prioritize(c *Connection, filter *aoiFilter, allPlayers []types.PlayerState, stateSequence uint32, nowNs int64) {
+	is := c.interest
+	if is == nil && protocol.WorldStateSize(len(s.aoiScratch)) <= s.cfg.Net.ClientTickBudget {
+		return // within budget and nothing deferred: no need to track the client yet
+	}
+	is = c.interestState()
+
+	candidates := s.interestScratch[:0]
+	for i := range s.aoiScratch {
+		candidates = append(candidates, scoredEntity{st: s.aoiScratch[i]})
+	}
+	if len(is.deferred) > 0 {
+		index := s.playerIndex(allPlayers, stateSequence)
+		if s.interestSeen == nil {
+			s.interestSeen = make(map[uint32]struct{})
+		}
+		clear(s.interestSeen)
+		for i := range s.aoiScratch {
+			s.interestSeen[s.aoiScratch[i].ID] = struct{}{}
+		}
+		for _, id := range is.deferred {
+			i, ok := index[id]
+			if _, changed := s.interestSeen[id]; changed || !ok || !filter.passes(&allPlayers[i]) {
+				continue // changed again this tick, left, or out of view
+			}
+			candidates = append(candidates, scoredEntity{st: allPlayers[i]})
+		}
+		is.deferred = is.deferred[:0]
+	}
+
+	k := protocol.WorldStatePlayersPerMessage(s.cfg.Net.ClientTickBudget)
+	s.aoiScratch = s.aoiScratch[:0]
+	if len(candidates) > k {
+		viewer := c.player.ToState()
+		for i := range candidates {
+			candidates[i].score = is.score(&viewer, &candidates[i].st, filter, nowNs)
+		}
+		slices.SortFunc(candidates, func(a, b scoredEntity) int {
+			if d := cmp.Compare(b.score, a.score); d != 0 {
+				return d // highest first
+			}
+			return cmp.Compare(a.st.ID, b.st.ID)
+		})
+		for i := range candidates[k:] {
+			is.deferred = append(is.deferred, candidates[k+i].st.ID)
+		}
+		metrics.InterestCutFrames.Inc()
+		metrics.InterestDeferred.Add(float64(len(candidates) - k))
+		candidates = candidates[:k]
+	}
+	for i := range candidates {
+		s.aoiScratch = append(s.aoiScratch, candidates[i].st)
+	}
+	is.record(s.aoiScratch, nowNs)
+	s.interestScratch = candidates[:0]
+}
+
+// score rates how much an update of st matters to viewer (see the top of the file).
+func (is *interestState) score(viewer, st *types.PlayerState, filter *aoiFilter, nowNs int64) float64 {
+	if st.ID == viewer.ID {
+		return math.Inf(1)
+	}
+	dx, dy := float64(st.X)-float64(viewer.X), float64(st.Y)-float64(viewer.Y)
+	score := interestWeightDistance * interestFalloff / (interestFalloff + math.Hypot(dx, dy))
+
+	if at, ok := is.interacted[st.ID]; (ok && nowNs-at < interactionWindow.Nanoseconds()) || filter.members.Has(st.ID) {
+		score += interestWeightInteraction
+	}
+
+	change, staleness := 1.0, 2.0
+	if sent, ok := is.sent[st.ID]; ok {
+		if sent.state == st.State && sent.facing == st.FacingRight {
+			mx, my := float64(st.X)-float64(sent.x), float64(st.Y)-float64(sent.y)
+			change = min(math.Hypot(mx, my)/interestMoveScale, 1)
+		}
+		staleness = min(float64(nowNs-sent.atNs)/float64(stalenessScale), 2)
+	}
+	return score + interestWeightChange*change + interestWeightStaleness*staleness
+}
+
+// playerIndex returns allPlayers' indices by ID, built once per tick on first use.
+func (s *Server) playerIndex(allPlayers []types.PlayerState, stateSequence uint32) map[uint32]int32 {
+	idx := &s.interestIndex
+	if idx.ids != nil && idx.seq == stateSequence {
+		return idx.ids
+	}
+	if idx.ids == nil {
+		idx.ids = make(map[uint32]int32, len(allPlayers))
+	}
+	clear(idx.ids)
+	for i := range allPlayers {
+		idx.ids[allPlayers[i].ID] = int32(i)
+	}
+	idx.seq = stateSequence
+	return idx.ids
+}
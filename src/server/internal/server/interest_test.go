@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/binary"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestInterestScoring(t *testing.T) {
+	s := newSessionServer(t, config.SessionTakeover)
+	s.cfg.Net.ClientTickBudget = protocol.WorldStateSize(3)
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	viewer := c.player.ID
+	c.player.SetX(1000)
+	c.player.SetY(1000)
+
+	deltaIDs := func(n int) []uint32 {
+		msgs := messagesOf(t, fake, protocol.MessageDeltaGameState, n)
+		if len(msgs) != n {
+			t.Fatalf("%d DELTA_GAME_STATE, want %d", len(msgs), n)
+		}
+		schema := protocol.LookupSchema(protocol.MessageDeltaGameState)
+		var ids []uint32
+		for off := schema.Size(0); off < len(msgs[n-1]); off += schema.EntrySize() {
+			ids = append(ids, binary.LittleEndian.Uint32(msgs[n-1][off:]))
+		}
+		return ids
+	}
+	tick := func(seq uint32, changed []types.PlayerState, players []types.PlayerState, now time.Time) {
+		if shared := s.splitViewportRecipients([]*Connection{c}, true); len(shared) != 0 {
+			t.Fatal("a client without a viewport stayed on the shared frame over the budget")
+		}
+		s.enqueueViewportFrames(players, changed, false, seq, now.UnixNano())
+		for atomic.LoadInt32(&c.pendingBroadcast) != 0 { // the next delta would be shed
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	players := []types.PlayerState{
+		{ID: viewer, X: 1000, Y: 1000},
+		{ID: 9001, X: 1010, Y: 1000}, // next to the viewer
+		{ID: 9002, X: 1100, Y: 1000},
+		{ID: 9003, X: 3000, Y: 3000},
+		{ID: 9004, X: 1500, Y: 1000},
+		{ID: 9005, X: 4000, Y: 4000}, // far, but just hit the viewer
+	}
+	now := time.Now()
+	c.noteInteraction(9005, now.UnixNano())
+
+	// Six changed players, room for three: the viewer, whoever it fights, the nearest.
+	tick(2, players, players, now)
+	if ids := deltaIDs(1); !slices.Equal(ids, []uint32{viewer, 9005, 9001}) {
+		t.Fatalf("first delta has %v, want the viewer, its opponent and the nearest player", ids)
+	}
+
+	// Next tick only 9001 moved a little: the deferred players it has never seen win.
+	players[1].X++
+	tick(3, players[1:2], players, now.Add(33*time.Millisecond))
+	if ids := deltaIDs(2); !slices.Equal(ids, []uint32{9002, 9004, 9003}) {
+		t.Errorf("second delta has %v, want the deferred players nearest first", ids)
+	}
+	if !slices.Equal(c.interest.deferred, []uint32{9001}) {
+		t.Errorf("deferred %v, want the small move of 9001", c.interest.deferred)
+	}
+
+	// A full sync covers everything deferred.
+	s.splitViewportRecipients([]*Connection{c}, false)
+	s.enqueueViewportFrames(players, nil, true, 4, now.Add(time.Second).UnixNano())
+	if c.cutting() {
+		t.Errorf("deferred %v after a full sync", c.interest.deferred)
+	}
+}
@@ -0,0 +1,413 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/inventory"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Inventories and trades (internal/inventory).
+//
+// Signed-in players own items; after JOIN and after every change of its inventory the
+// client gets INVENTORY. Game code grants and consumes items with grantItem and
+// consumeItem (pickups, rewards), admins with /give and /take or through
+// /admin/inventory on the admin listener.
+//
+// TRADE runs a trade between two players of this server: one asks, the other
+// accepts, both offer and confirm, and both get TRADE_STATE after every change. When
+// both confirmed, the offers are swapped by one inventory.Store.Apply, which checks the
+// counts at that moment: an item consumed or traded away meanwhile fails the trade
+// instead of being duplicated. A refused action is answered with COMMAND_RESULT. A
+// player that disconnects cancels its trade; one taken over by a new session keeps it.
+// Trades are not handed over on a restart.
+//
+// Trade changes are serialized by tradeMu, which is held while the states are enqueued.
+
+var tradeActionLabels = [...]string{
+	protocol.TradeRequest: "request",
+	protocol.TradeAccept:  "accept",
+	protocol.TradeOffer:   "offer",
+	protocol.TradeConfirm: "confirm",
+	protocol.TradeCancel:  "cancel",
+}
+
+// errInventoryUnavailable is what a player is told when the inventory log cannot be written.
+var errInventoryUnavailable = errors.New("inventory unavailable, try again later")
+
+// initInventory opens the inventory log and registers the item commands.
+func (s *Server) initInventory() {
+	store, err := inventory.Open(s.cfg.Server.InventoryLog, s.cfg.Server.InventoryMaxSlots, uint32(s.cfg.Server.InventoryMaxStack))
+	if err != nil {
+		// Items in the log are not shown until it is fixed and the server restarted.
+		slog.Error("inventory log unreadable, starting with empty inventories", "path", s.cfg.Server.InventoryLog, "error", err)
+		store, _ = inventory.Open("", s.cfg.Server.InventoryMaxSlots, uint32(s.cfg.Server.InventoryMaxStack))
+	}
+	s.inventory = store
+	s.trades = inventory.NewTrades(s.cfg.Server.TradeRequestTTL)
+
+	for _, c := range []struct {
+		name, help string
+		consume    bool
+	}{
+		{"give", "gives items to a player", false},
+		{"take", "takes items from a player", true},
+	} {
+		s.console.MustRegister(console.Command{
+			Name:  c.name,
+			Usage: "<player|me> <item> [count]",
+			Help:  c.help,
+			Role:  console.RoleAdmin,
+			Run: func(caller console.Caller, args []string) console.Result {
+				if len(args) < 2 || len(args) > 3 {
+					return console.Errorf("usage: /%s <player|me> <item> [count]", c.name)
+				}
+				item, err := strconv.ParseUint(args[1], 10, 16)
+				if err != nil {
+					return console.Errorf("item must be an integer in 1..65535")
+				}
+				count := uint64(1)
+				if len(args) == 3 {
+					if count, err = strconv.ParseUint(args[2], 10, 32); err != nil {
+						return console.Errorf("count must be a positive integer")
+					}
+				}
+				id, res, ok := s.commandPlayer(caller, args[0])
+				if !ok {
+					return res
+				}
+				change := s.grantItem
+				if c.consume {
+					change = s.consumeItem
+				}
+				n, err := change(id, uint16(item), uint32(count), "admin")
+				if err != nil {
+					return console.Errorf("%v", err)
+				}
+				return console.OK("%d has %d of item %d", id, n, item)
+			},
+		})
+	}
+	s.console.MustRegister(console.Command{
+		Name:  "inventory",
+		Usage: "[player|me]",
+		Help:  "lists the items of a player",
+		Run: func(caller console.Caller, args []string) console.Result {
+			if len(args) > 1 {
+				return console.Errorf("usage: /inventory [player|me]")
+			}
+			arg := "me"
+			if len(args) == 1 {
+				arg = args[0]
+			}
+			if arg != "me" && caller.Role < console.RoleAdmin {
+				return console.Errorf("only admins see the inventory of others")
+			}
+			id, res, ok := s.commandPlayer(caller, arg)
+			if !ok {
+				return res
+			}
+			account := s.accountOf(id)
+			if account == "" {
+				return console.Errorf("%v", inventory.ErrOwner)
+			}
+			items := s.inventory.Items(account)
+			if len(items) == 0 {
+				return console.OK("%d has no items", id)
+			}
+			text := fmt.Sprintf("%d has", id)
+			for _, st := range items {
+				text += fmt.Sprintf(" %d×%d", st.Count, st.Item)
+			}
+			return console.OK("%s", text)
+		},
+	})
+}
+
+// accountOf returns the account of an online player, "" if anonymous or offline.
+func (s *Server) accountOf(playerID uint32) string {
+	if c := s.partyConnection(playerID); c != nil {
+		return c.account
+	}
+	return ""
+}
+
+// grantItem adds n of item to the inventory of an online player and returns its new
+// count; the client gets its inventory.
+func (s *Server) grantItem(playerID uint32, item uint16, n uint32, reason string) (uint32, error) {
+	return s.changeItem(playerID, item, int64(n), reason)
+}
+
+// consumeItem takes n of item from the inventory of an online player and returns its
+// new count; the client gets its inventory.
+func (s *Server) consumeItem(playerID uint32, item uint16, n uint32, reason string) (uint32, error) {
+	return s.changeItem(playerID, item, -int64(n), reason)
+}
+
+func (s *Server) changeItem(playerID uint32, item uint16, delta int64, reason string) (uint32, error) {
+	c := s.partyConnection(playerID)
+	if c == nil {
+		return 0, fmt.Errorf("player %d is not online", playerID)
+	}
+	if delta == 0 {
+		return 0, inventory.ErrCount
+	}
+	if err := s.applyItems(reason, inventory.Change{Owner: c.account, Item: item, Delta: delta}); err != nil {
+		return 0, err
+	}
+	s.sendInventory(c)
+	return s.inventory.Count(c.account, item), nil
+}
+
+// applyItems applies changes as one inventory entry. An I/O failure is logged and
+// reported as errInventoryUnavailable; a refusal is returned as is.
+func (s *Server) applyItems(reason string, changes ...inventory.Change) error {
+	err := s.inventory.Apply(reason, changes...)
+	switch {
+	case err == nil:
+		metrics.InventoryChanges.WithLabelValues(reason, "ok").Inc()
+		return nil
+	case errors.Is(err, inventory.ErrOwner), errors.Is(err, inventory.ErrItem), errors.Is(err, inventory.ErrCount),
+		errors.Is(err, inventory.ErrNotEnough), errors.Is(err, inventory.ErrStack), errors.Is(err, inventory.ErrSlots):
+		metrics.InventoryChanges.WithLabelValues(reason, "refused").Inc()
+		return err
+	default:
+		slog.Error("inventory change not recorded", "reason", reason, "changes", changes, "error", err)
+		metrics.InventoryChanges.WithLabelValues(reason, "refused").Inc()
+		return errInventoryUnavailable
+	}
+}
+
+// sendInventory sends c its items, reliably where the client acks; only the latest
+// INVENTORY matters.
+func (s *Server) sendInventory(c *Connection) {
+	if c.account == "" {
+		return
+	}
+	items := s.inventory.Items(c.account)
+	stacks := make([]protocol.ItemStack, len(items))
+	for i, st := range items {
+		stacks[i] = protocol.ItemStack{Item: st.Item, Count: st.Count}
+	}
+	s.sendReliable(c, s.protocol.EncodeInventory(stacks), dedupeKey(protocol.MessageInventory, 0))
+}
+
+// joinInventory sends a joined connection its items and, if it took over a session,
+// the trade of its player.
+func (s *Server) joinInventory(c *Connection, resumed bool) {
+	s.sendInventory(c)
+	if !resumed {
+		return
+	}
+	s.tradeMu.Lock()
+	defer s.tradeMu.Unlock()
+	if t := s.trades.Of(c.player.ID); t != nil {
+		s.sendTradeState(c, t, protocol.TradeOpen)
+	}
+}
+
+// handleTrade runs a TRADE action of c.
+func (s *Server) handleTrade(c *Connection, msg *protocol.ClientMessage) {
+	if int(msg.TradeAction) >= len(tradeActionLabels) {
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, "unknown trade action"))
+		return
+	}
+	name := tradeActionLabels[msg.TradeAction]
+	if c.account == "" {
+		s.refuseTrade(c, name, inventory.ErrOwner.Error())
+		return
+	}
+
+	s.tradeMu.Lock()
+	defer s.tradeMu.Unlock()
+	self := c.player.ID
+	switch msg.TradeAction {
+	case protocol.TradeRequest:
+		partner := s.partyConnection(msg.TargetID)
+		if partner == nil || partner.account == "" {
+			s.refuseTrade(c, name, fmt.Sprintf("player %d is not online or not signed in", msg.TargetID))
+			return
+		}
+		if err := s.trades.Request(self, c.account, msg.TargetID); err != nil {
+			s.refuseTrade(c, name, err.Error())
+			return
+		}
+		s.sendReliable(partner, s.protocol.EncodeTradeState(0, protocol.TradeRequested, self, 0, nil), 0)
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandOK, fmt.Sprintf("asked %d to trade", msg.TargetID)))
+
+	case protocol.TradeAccept:
+		t, err := s.trades.Accept(self, c.account, msg.TargetID)
+		if err != nil {
+			s.refuseTrade(c, name, err.Error())
+			return
+		}
+		s.publishTrade(t, protocol.TradeOpen)
+
+	case protocol.TradeOffer:
+		// Checked here so the partner is not shown items that are not there; the
+		// swap checks again.
+		if held := s.inventory.Count(c.account, msg.Item); held < msg.Count {
+			s.refuseTrade(c, name, fmt.Sprintf("you have %d of item %d", held, msg.Item))
+			return
+		}
+		t, err := s.trades.Offer(self, msg.Item, msg.Count)
+		if err != nil {
+			s.refuseTrade(c, name, err.Error())
+			return
+		}
+		s.publishTrade(t, protocol.TradeOpen)
+
+	case protocol.TradeConfirm:
+		t, done, err := s.trades.Confirm(self)
+		if err != nil {
+			s.refuseTrade(c, name, err.Error())
+			return
+		}
+		if !done {
+			s.publishTrade(t, protocol.TradeOpen)
+			break
+		}
+		s.trades.Close(self)
+		if err := s.applyItems("trade", t.Changes()...); err != nil {
+			metrics.Trades.WithLabelValues("failed").Inc()
+			s.publishTrade(t, protocol.TradeCancelled)
+			for _, id := range t.Players {
+				if conn := s.partyConnection(id); conn != nil {
+					s.sendDirect(conn, s.protocol.EncodeCommandResult(protocol.CommandError, "trade failed: "+err.Error()))
+				}
+			}
+			return
+		}
+		metrics.Trades.WithLabelValues("completed").Inc()
+		s.publishTrade(t, protocol.TradeCompleted)
+		for _, id := range t.Players {
+			if conn := s.partyConnection(id); conn != nil {
+				s.sendInventory(conn)
+			}
+		}
+
+	case protocol.TradeCancel:
+		if t := s.trades.Close(self); t != nil {
+			metrics.Trades.WithLabelValues("cancelled").Inc()
+			s.publishTrade(t, protocol.TradeCancelled)
+			break
+		}
+		if !s.trades.Decline(self, msg.TargetID) {
+			s.refuseTrade(c, name, inventory.ErrNoTrade.Error())
+			return
+		}
+		if requester := s.partyConnection(msg.TargetID); requester != nil {
+			s.sendReliable(requester, s.protocol.EncodeTradeState(0, protocol.TradeCancelled, self, 0, nil), 0)
+		}
+	}
+	metrics.TradeActions.WithLabelValues(name, "ok").Inc()
+}
+
+// refuseTrade answers a TRADE action that was not done.
+func (s *Server) refuseTrade(c *Connection, action, reason string) {
+	metrics.TradeActions.WithLabelValues(action, "refused").Inc()
+	s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, reason))
+}
+
+// leaveTrade cancels the trade of a disconnected player and drops its requests.
+func (s *Server) leaveTrade(c *Connection) {
+	s.tradeMu.Lock()
+	defer s.tradeMu.Unlock()
+	if t := s.trades.Remove(c.player.ID); t != nil {
+		metrics.Trades.WithLabelValues("cancelled").Inc()
+		s.publishTrade(t, protocol.TradeCancelled)
+	}
+}
+
+// publishTrade sends both players of t its state. Called with tradeMu held.
+func (s *Server) publishTrade(t *inventory.Trade, status uint8) {
+	for _, id := range t.Players {
+		if conn := s.partyConnection(id); conn != nil {
+			s.sendTradeState(conn, t, status)
+		}
+	}
+}
+
+// sendTradeState sends c the state of its trade t, its own offers first.
+func (s *Server) sendTradeState(c *Connection, t *inventory.Trade, status uint8) {
+	side := t.Side(c.player.ID)
+	var confirmed uint8
+	if t.Confirmed[side] {
+		confirmed |= protocol.TradeConfirmedSelf
+	}
+	if t.Confirmed[1-side] {
+		confirmed |= protocol.TradeConfirmedPartner
+	}
+	offers := make([]protocol.TradeOfferEntry, 0, len(t.Offers[0])+len(t.Offers[1]))
+	for _, st := range t.Offers[side] {
+		offers = append(offers, protocol.TradeOfferEntry{Item: st.Item, Count: st.Count})
+	}
+	for _, st := range t.Offers[1-side] {
+		offers = append(offers, protocol.TradeOfferEntry{Partner: true, Item: st.Item, Count: st.Count})
+	}
+	s.sendReliable(c, s.protocol.EncodeTradeState(t.ID, status, t.Players[1-side], confirmed, offers), 0)
+}
+
+// handleAdminInventory serves the inventories to the website:
+//
+//	GET    /admin/inventory?account=A                 → A's items
+//	POST   /admin/inventory?account=A&item=I&count=N  → N of item I granted; A's items
+//	DELETE /admin/inventory?account=A&item=I&count=N  → N of item I consumed; A's items
+//
+// An online player gets its new INVENTORY.
+func (s *Server) handleAdminInventory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	account := q.Get("account")
+	if account == "" {
+		http.Error(w, "account is required", http.StatusBadRequest)
+		return
+	}
+	var sign int64
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		sign = 1
+	case http.MethodDelete:
+		sign = -1
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if sign != 0 {
+		item, errItem := strconv.ParseUint(q.Get("item"), 10, 16)
+		count, errCount := strconv.ParseUint(q.Get("count"), 10, 32)
+		if errItem != nil || errCount != nil || count == 0 {
+			http.Error(w, "item (1..65535) and count (positive) are required", http.StatusBadRequest)
+			return
+		}
+		err := s.applyItems("admin", inventory.Change{Owner: account, Item: uint16(item), Delta: sign * int64(count)})
+		if err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, inventory.ErrNotEnough):
+				status = http.StatusConflict
+			case errors.Is(err, errInventoryUnavailable):
+				status = http.StatusInternalServerError
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		slog.Info("inventory changed by admin", "account", account, "item", item, "delta", sign*int64(count))
+		s.connectionsMu.RLock()
+		c := s.sessions[account]
+		s.connectionsMu.RUnlock()
+		if c != nil {
+			s.sendInventory(c)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.inventory.Items(account))
+}
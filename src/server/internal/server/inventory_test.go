@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/inventory"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestTrade(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func(account string) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.account = account
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	trade := func(c *Connection, action uint8, target uint32, item uint16, count uint32) {
+		s.handleTrade(c, &protocol.ClientMessage{Type: protocol.MessageTrade, TradeAction: action, TargetID: target, Item: item, Count: count})
+	}
+	lastState := func(fake *testutil.FakeConn, n int) []byte {
+		t.Helper()
+		got := messagesOf(t, fake, protocol.MessageTradeState, n)
+		if len(got) != n {
+			t.Fatalf("%d TRADE_STATEs, want %d", len(got), n)
+		}
+		return got[n-1]
+	}
+	alice, aliceFake := join("alice")
+	bob, bobFake := join("bob")
+	if _, err := s.grantItem(alice.player.ID, 1, 5, "grant"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.grantItem(bob.player.ID, 2, 3, "grant"); err != nil {
+		t.Fatal(err)
+	}
+	if got := messagesOf(t, aliceFake, protocol.MessageInventory, 2); len(got) != 2 ||
+		string(got[1]) != string(s.protocol.EncodeInventory([]protocol.ItemStack{{Item: 1, Count: 5}})) {
+		t.Fatalf("alice's INVENTORYs = %x", got)
+	}
+
+	trade(alice, protocol.TradeRequest, bob.player.ID, 0, 0)
+	if got := lastState(bobFake, 1); string(got) != string(s.protocol.EncodeTradeState(0, protocol.TradeRequested, alice.player.ID, 0, nil)) {
+		t.Errorf("request = %x", got)
+	}
+	trade(bob, protocol.TradeAccept, alice.player.ID, 0, 0)
+	trade(alice, protocol.TradeOffer, 0, 1, 9)
+	if got := messagesOf(t, aliceFake, protocol.MessageCommandResult, 2); len(got) != 2 || got[1][1] != protocol.CommandError {
+		t.Errorf("offer of more than alice has answered %x", got)
+	}
+	trade(alice, protocol.TradeOffer, 0, 1, 4)
+	trade(bob, protocol.TradeOffer, 0, 2, 3)
+	trade(alice, protocol.TradeConfirm, 0, 0, 0)
+	want := s.protocol.EncodeTradeState(1, protocol.TradeOpen, alice.player.ID, protocol.TradeConfirmedPartner,
+		[]protocol.TradeOfferEntry{{Item: 2, Count: 3}, {Partner: true, Item: 1, Count: 4}})
+	if got := lastState(bobFake, 5); string(got) != string(want) {
+		t.Errorf("bob's state = %x, want %x", got, want)
+	}
+
+	// Alice spends an offered item before bob confirms: the trade fails, nothing moves.
+	if _, err := s.consumeItem(alice.player.ID, 1, 2, "consume"); err != nil {
+		t.Fatal(err)
+	}
+	trade(bob, protocol.TradeConfirm, 0, 0, 0)
+	if got := lastState(aliceFake, 5); got[5] != protocol.TradeCancelled {
+		t.Errorf("trade with a spent offer ended with %x", got)
+	}
+	if s.inventory.Count("alice", 1) != 3 || s.inventory.Count("bob", 2) != 3 || s.inventory.Count("bob", 1) != 0 {
+		t.Errorf("failed trade moved items: alice %+v, bob %+v", s.inventory.Items("alice"), s.inventory.Items("bob"))
+	}
+
+	trade(bob, protocol.TradeRequest, alice.player.ID, 0, 0)
+	trade(alice, protocol.TradeAccept, bob.player.ID, 0, 0)
+	trade(alice, protocol.TradeOffer, 0, 1, 3)
+	trade(bob, protocol.TradeConfirm, 0, 0, 0)
+	trade(alice, protocol.TradeConfirm, 0, 0, 0)
+	if got := lastState(bobFake, 10); got[5] != protocol.TradeCompleted {
+		t.Errorf("trade ended with %x", got)
+	}
+	if s.inventory.Count("alice", 1) != 0 || s.inventory.Count("bob", 1) != 3 {
+		t.Errorf("after the trade: alice %+v, bob %+v", s.inventory.Items("alice"), s.inventory.Items("bob"))
+	}
+
+	// A player that leaves cancels its trade.
+	trade(alice, protocol.TradeRequest, bob.player.ID, 0, 0)
+	trade(bob, protocol.TradeAccept, alice.player.ID, 0, 0)
+	s.cleanupConnection(bob)
+	if got := lastState(aliceFake, 12); got[5] != protocol.TradeCancelled {
+		t.Errorf("partner left, trade state %x", got)
+	}
+	if s.trades.Len() != 0 {
+		t.Error("trade kept after the partner left")
+	}
+
+	w := httptest.NewRecorder()
+	s.handleAdminInventory(w, httptest.NewRequest(http.MethodPost, "/admin/inventory?account=alice&item=7&count=2", nil))
+	var items []inventory.Stack
+	if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+		t.Fatal(err, w.Body)
+	}
+	if len(items) != 1 || items[0] != (inventory.Stack{Item: 7, Count: 2}) {
+		t.Errorf("alice's items = %+v", items)
+	}
+	w = httptest.NewRecorder()
+	s.handleAdminInventory(w, httptest.NewRequest(http.MethodDelete, "/admin/inventory?account=alice&item=7&count=3", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("DELETE of more than alice has = %d", w.Code)
+	}
+}
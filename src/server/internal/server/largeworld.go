@@ -0,0 +1,148 @@
+package server
+
+import (
+	"math"
+	"sync/atomic"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Large worlds (config.LargeWorld).
+//
+// Wire positions are uint16, so a world of config.WireSpan units or more a side does not
+// fit them. Positions stay 32-bit in the simulation; on the wire they go relative to an
+// origin near the client's own player, announced with ORIGIN. Each connection gets its
+// own encoder (protocolFor) carrying that origin. The origin is snapped to a quarter of
+// the window and moves only once the player leaves the middle half of it, so everything
+// the client may see — at most a quarter of the window either side, which config
+// validation enforces — always fits. Every connection takes the viewport path (aoi.go),
+// and players outside the window are left out like players outside the viewport.
+//
+// A message encoded for one origin must reach the client before the ORIGIN that
+// replaces it. The origin only moves on the gameLoop goroutine (moveOrigin), which
+// encodes the world states as well; senders on other goroutines hold the origin in place
+// from encoding to enqueueing (lockOrigin).
+
+// originAxis returns the origin along one axis for position v: cur while v stays in the
+// middle half of the window [cur, cur+span), else v snapped a half window in.
+func originAxis(cur, v, span uint32) uint32 {
+	step := span / 4
+	if (v >= cur+step || cur == 0) && v < cur+span-step {
+		return cur
+	}
+	return (max(v, span/2) - span/2) &^ (step - 1)
+}
+
+// originProtocol returns the encoder of a large-world client whose player stands at (x, y).
+func (s *Server) originProtocol(cur *protocol.BinaryProtocol, x, y uint32) *protocol.BinaryProtocol {
+	span := config.WireSpan(int(s.protocol.PositionBits))
+	return &protocol.BinaryProtocol{
+		PositionBits: s.protocol.PositionBits,
+		OriginX:      originAxis(cur.OriginX, x, span),
+		OriginY:      originAxis(cur.OriginY, y, span),
+	}
+}
+
+// placeOrigin picks the first origin of c in a large world and sends ORIGIN. Called at
+// join, before c gets its initial state or joins s.connections.
+func (s *Server) placeOrigin(c *Connection) {
+	if !s.largeWorld {
+		return
+	}
+	p := s.originProtocol(&protocol.BinaryProtocol{}, c.player.GetX(), c.player.GetY())
+	c.origin.Store(p)
+	s.sendDirect(c, p.EncodeOrigin())
+}
+
+// moveOrigin re-centres c's origin once its player has left the middle half of the
+// window and sends ORIGIN ahead of the messages encoded for the new one. A full write
+// queue keeps the old origin until the next tick. gameLoop goroutine only.
+func (s *Server) moveOrigin(c *Connection) {
+	cur := c.origin.Load()
+	if cur == nil {
+		return
+	}
+	next := s.originProtocol(cur, c.player.GetX(), c.player.GetY())
+	if next.OriginX == cur.OriginX && next.OriginY == cur.OriginY {
+		return
+	}
+	c.originMu.Lock()
+	defer c.originMu.Unlock()
+	select {
+	case c.writeCh <- writeJob{direct: next.EncodeOrigin(), timeout: s.directWriteTimeout}:
+	default:
+		s.noteDrop(c)
+		return
+	}
+	rebaseMoveAck(c, cur, next)
+	c.origin.Store(next)
+	metrics.OriginMoves.Inc()
+}
+
+// rebaseMoveAck converts the coalesced MOVEMENT_ACK still waiting for flushMoveAcks from
+// the wire units of origin from to those of to. Caller holds c.originMu, so
+// queueMoveAck cannot store one meanwhile.
+func rebaseMoveAck(c *Connection, from, to *protocol.BinaryProtocol) {
+	x, y, seq := unpackMoveAck(atomic.LoadUint64(&c.pendingAck))
+	shift := func(v uint16, was, now uint32) uint16 {
+		w := int64(v) + (int64(was)-int64(now))<<to.PositionBits
+		return uint16(min(max(w, 0), math.MaxUint16))
+	}
+	atomic.StoreUint64(&c.pendingAck, packMoveAck(shift(x, from.OriginX, to.OriginX), shift(y, from.OriginY, to.OriginY), seq))
+}
+
+// lockOrigin holds c's origin in place until unlockOrigin, so a message encoded with
+// protocolFor(c) meanwhile is enqueued before any ORIGIN that replaces it. No-op
+// outside large worlds.
+func (s *Server) lockOrigin(c *Connection) {
+	if s.largeWorld {
+		c.originMu.Lock()
+	}
+}
+
+func (s *Server) unlockOrigin(c *Connection) {
+	if s.largeWorld {
+		c.originMu.Unlock()
+	}
+}
+
+// originWindow returns the part of the world c's wire positions cover; ok=false outside
+// large worlds, where they cover all of it.
+func originWindow(c *Connection) (window types.ViewportBounds, ok bool) {
+	p := c.origin.Load()
+	if p == nil {
+		return types.ViewportBounds{}, false
+	}
+	span := config.WireSpan(int(p.PositionBits))
+	return types.ViewportBounds{MinX: p.OriginX, MinY: p.OriginY, MaxX: p.OriginX + span - 1, MaxY: p.OriginY + span - 1}, true
+}
+
+// windowPlayers returns the players inside c's window, or players itself outside large
+// worlds.
+func windowPlayers(c *Connection, players []types.PlayerState) []types.PlayerState {
+	window, ok := originWindow(c)
+	if !ok {
+		return players
+	}
+	out := make([]types.PlayerState, 0, len(players))
+	for _, st := range players {
+		if window.Contains(st.X, st.Y) {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// notifyJoinedFromOrigin sends PLAYER_JOINED for st to a large-world client, encoded
+// for its origin; a newcomer outside its window shows up with the world states once
+// the client gets near.
+func (s *Server) notifyJoinedFromOrigin(c *Connection, st types.PlayerState) {
+	s.lockOrigin(c)
+	defer s.unlockOrigin(c)
+	if window, _ := originWindow(c); window.Contains(st.X, st.Y) {
+		s.sendReliable(c, s.protocolFor(c).EncodePlayerJoined(st), 0)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestOriginAxis(t *testing.T) {
+	const span = 4096 // 4 fraction bits
+	tests := []struct {
+		cur, v, want uint32
+	}{
+		{0, 0, 0},       // the world's edge stays at the edge
+		{0, 3071, 0},    // still in the middle half
+		{0, 3072, 1024}, // left it: a half window in, snapped to a quarter
+		{1024, 2048, 1024},
+		{1024, 2047, 0},
+		{8192, 20000, 17408}, // a teleport jumps straight there
+	}
+	for _, tt := range tests {
+		if got := originAxis(tt.cur, tt.v, span); got != tt.want {
+			t.Errorf("originAxis(%d, %d) = %d, want %d", tt.cur, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateSubprotocolLargeWorld(t *testing.T) {
+	s := &Server{cfg: &config.Config{}, largeWorld: true}
+	for _, offered := range []string{"", protocol.LegacySubprotocol} {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		if offered != "" {
+			r.Header.Add("Sec-WebSocket-Protocol", offered)
+		}
+		if _, ok := s.negotiateSubprotocol(r); ok {
+			t.Errorf("offered %q: accepted in a large world", offered)
+		}
+	}
+	r := httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Add("Sec-WebSocket-Protocol", protocol.Subprotocol)
+	if _, ok := s.negotiateSubprotocol(r); !ok {
+		t.Error("current client rejected")
+	}
+}
+
+func TestLargeWorld(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Net.PositionFractionBits = 4 // a 4096-unit window
+	cfg.Net.CoalesceMoveAcks = true
+	cfg.World.Width, cfg.World.MaxX = 20000, 20000
+	cfg.World.SpawnMinX, cfg.World.SpawnMaxX = 1000, 2000
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	if !s.largeWorld {
+		t.Fatal("a 20000-unit world with 4 fraction bits is not large")
+	}
+	join := func() (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	origins := func(fake *testutil.FakeConn, n int) (x, y []uint32) {
+		t.Helper()
+		msgs := messagesOf(t, fake, protocol.MessageOrigin, n)
+		if len(msgs) != n {
+			t.Fatalf("%d ORIGINs, want %d", len(msgs), n)
+		}
+		for _, msg := range msgs {
+			x, y = append(x, binary.LittleEndian.Uint32(msg[1:])), append(y, binary.LittleEndian.Uint32(msg[5:]))
+		}
+		return x, y
+	}
+
+	a, aFake := join()
+	ox, oy := origins(aFake, 1)
+	if window, _ := originWindow(a); window.MinX != ox[0] || window.MinY != oy[0] || !window.Contains(a.player.GetX(), a.player.GetY()) {
+		t.Fatalf("ORIGIN (%d, %d), window %+v, player at (%d, %d)", ox[0], oy[0], window, a.player.GetX(), a.player.GetY())
+	}
+
+	// An ACK queued for the old origin is rebased when the origin moves before the flush.
+	x, y := ox[0]+3500, a.player.GetY()
+	a.player.Position().SetFixed(types.FixedOf(x), types.FixedOf(y))
+	s.queueMoveAck(a, types.FixedOf(x), types.FixedOf(y), 1)
+	s.moveOrigin(a)
+	s.flushMoveAcks()
+	ox, _ = origins(aFake, 2)
+	if want := (x - 2048) &^ 1023; ox[1] != want {
+		t.Errorf("moved ORIGIN x = %d, want %d", ox[1], want)
+	}
+	acks := messagesOf(t, aFake, protocol.MessageMovementAck, 1)
+	if len(acks) != 1 {
+		t.Fatalf("%d MOVEMENT_ACKs", len(acks))
+	}
+	if got, want := binary.LittleEndian.Uint16(acks[0][5:]), uint16(x-ox[1])<<4; got != want {
+		t.Errorf("MOVEMENT_ACK x = %d, want %d from the new origin", got, want)
+	}
+
+	// A newcomer outside a's window is not announced to it.
+	a.player.Position().SetFixed(types.FixedOf(15000), types.FixedOf(y))
+	s.moveOrigin(a)
+	origins(aFake, 3)
+	b, bFake := join()
+	if got := messagesOf(t, aFake, protocol.MessagePlayerJoined, 2); len(got) != 1 {
+		t.Errorf("a got %d PLAYER_JOINEDs, want its own only", len(got))
+	}
+	if joined := messagesOf(t, bFake, protocol.MessagePlayerJoined, 1); len(joined) != 1 ||
+		binary.LittleEndian.Uint32(joined[0][1:]) != b.player.ID {
+		t.Errorf("b's PLAYER_JOINEDs = %x", joined)
+	}
+}
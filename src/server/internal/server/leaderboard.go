@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/leaderboard"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Leaderboard. Damage dealt and kills of connected players are added to a
+// leaderboard.Board from sendHit; bots are not ranked. Players are ranked by account
+// ("account:<id>") or, when anonymous, by player ID ("player:<id>"), which a later
+// session may be given again.
+//
+// The board is flushed to its store — Redis when LEADERBOARD_REDIS_ADDR is set, so that
+// every server of a deployment shares one board, memory otherwise — every
+// LeaderboardFlush and once more on Shutdown; every store call gives up after
+// LeaderboardTimeout, so a stalled Redis delays a flush, a broadcast or a query, never
+// the game. Clients that set protocol.CapLeaderboard
+// get the top LeaderboardSize players of each metric every LeaderboardInterval;
+// GET /leaderboard answers top-N and around-me queries.
+
+// initLeaderboard picks the store and starts the flush/broadcast loop.
+func (s *Server) initLeaderboard() {
+	var store leaderboard.Store = leaderboard.NewMemory()
+	if addr := s.cfg.Server.LeaderboardRedisAddr; addr != "" {
+		store = leaderboard.NewRedis(addr, s.cfg.Server.LeaderboardRedisPassword, s.cfg.Server.LeaderboardRedisPrefix)
+		slog.Info("leaderboard in redis", "addr", addr, "prefix", s.cfg.Server.LeaderboardRedisPrefix)
+	}
+	s.leaderboard = leaderboard.New(store)
+	go s.runLeaderboardLoop()
+}
+
+// leaderboardMember returns the member c is ranked as.
+func (c *Connection) leaderboardMember() string {
+	if c.account != "" {
+		return "account:" + c.account
+	}
+	return "player:" + strconv.FormatUint(uint64(c.player.ID), 10)
+}
+
+// recordHit scores a hit for the attacker. gameLoop goroutine (via sendHit).
+func (s *Server) recordHit(attacker *Connection, hit game.Hit) {
+	member := attacker.leaderboardMember()
+	s.leaderboard.Add(leaderboard.MetricDamage, member, int64(hit.Damage))
+	if hit.HP == 0 {
+		s.leaderboard.Add(leaderboard.MetricKills, member, 1)
+	}
+}
+
+// runLeaderboardLoop flushes the board every LeaderboardFlush and sends LEADERBOARD
+// every LeaderboardInterval (if set) until the server stops.
+func (s *Server) runLeaderboardLoop() {
+	flush := time.NewTicker(s.cfg.Server.LeaderboardFlush)
+	defer flush.Stop()
+	var broadcast <-chan time.Time
+	if s.cfg.Net.LeaderboardInterval > 0 {
+		t := time.NewTicker(s.cfg.Net.LeaderboardInterval)
+		defer t.Stop()
+		broadcast = t.C
+	}
+	for {
+		select {
+		case <-flush.C:
+			s.flushLeaderboard(s.ctx)
+		case <-broadcast:
+			s.sendLeaderboard()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// flushLeaderboard writes pending score changes to the store.
+func (s *Server) flushLeaderboard(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Server.LeaderboardTimeout)
+	defer cancel()
+	if err := s.leaderboard.Flush(ctx); err != nil {
+		metrics.LeaderboardErrors.WithLabelValues("flush").Inc()
+		slog.Warn("leaderboard flush failed, retrying next time", "pending", s.leaderboard.Pending(), "error", err)
+	}
+}
+
+// onlineMembers maps the member of every connected player to its player ID.
+func (s *Server) onlineMembers() map[string]uint32 {
+	s.connectionsMu.RLock()
+	defer s.connectionsMu.RUnlock()
+	online := make(map[string]uint32, len(s.connections))
+	for id, conn := range s.connections {
+		online[conn.leaderboardMember()] = id
+	}
+	return online
+}
+
+// sendLeaderboard sends the top players of every metric to the subscribers.
+func (s *Server) sendLeaderboard() {
+	if s.shedOptional() {
+		return
+	}
+	var subscribers []*Connection
+	s.connectionsMu.RLock()
+	for _, conn := range s.connections {
+		if conn.caps&protocol.CapLeaderboard != 0 {
+			subscribers = append(subscribers, conn)
+		}
+	}
+	s.connectionsMu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+	online := s.onlineMembers()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Server.LeaderboardTimeout)
+	defer cancel()
+	for i, metric := range leaderboard.Metrics {
+		top, err := s.leaderboard.Top(ctx, metric, s.cfg.Net.LeaderboardSize)
+		if err != nil {
+			metrics.LeaderboardErrors.WithLabelValues("top").Inc()
+			slog.Warn("leaderboard query failed", "metric", metric, "error", err)
+			continue
+		}
+		entries := make([]protocol.LeaderboardEntry, len(top))
+		for j, e := range top {
+			entries[j] = protocol.LeaderboardEntry{PlayerID: online[e.Member], Score: uint32(min(max(e.Score, 0), math.MaxUint32))}
+		}
+		data := s.protocol.EncodeLeaderboard(uint8(i), entries)
+		for _, conn := range subscribers {
+			s.sendLatest(conn, data, writeClassEvent, dedupeKey(protocol.MessageLeaderboard, uint32(i)))
+		}
+		metrics.LeaderboardSent.Add(float64(len(subscribers)))
+	}
+}
+
+// leaderboardEntry — /leaderboard entry: a leaderboard.Entry and the player it is
+// online as on this server.
+type leaderboardEntry struct {
+	leaderboard.Entry
+	PlayerID uint32 `json:"player_id,omitempty"`
+}
+
+// handleLeaderboard serves the leaderboard:
+//
+//	GET /leaderboard?metric=M[&limit=N]               → top N (default LEADERBOARD_SIZE)
+//	GET /leaderboard?metric=M&member=X[&limit=N]      → X and N players above and below it
+//
+// M is kills or damage; X is account:<id> or player:<id>. Score changes of the last
+// LEADERBOARD_FLUSH_MS may not be counted yet.
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	metric := q.Get("metric")
+	if _, ok := leaderboard.MetricID(metric); !ok {
+		http.Error(w, "metric must be kills or damage", http.StatusBadRequest)
+		return
+	}
+	limit := s.cfg.Net.LeaderboardSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > config.MaxLeaderboardSize {
+			http.Error(w, "limit must be 0-"+strconv.Itoa(config.MaxLeaderboardSize), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Server.LeaderboardTimeout)
+	defer cancel()
+	var entries []leaderboard.Entry
+	var err error
+	if member := q.Get("member"); member != "" {
+		var ok bool
+		entries, ok, err = s.leaderboard.Around(ctx, metric, member, limit)
+		if err == nil && !ok {
+			http.Error(w, "member has no score", http.StatusNotFound)
+			return
+		}
+	} else {
+		entries, err = s.leaderboard.Top(ctx, metric, limit)
+	}
+	if err != nil {
+		metrics.LeaderboardErrors.WithLabelValues("query").Inc()
+		slog.Warn("leaderboard query failed", "metric", metric, "error", err)
+		http.Error(w, "leaderboard unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	online := s.onlineMembers()
+	result := make([]leaderboardEntry, len(entries))
+	for i, e := range entries {
+		result[i] = leaderboardEntry{Entry: e, PlayerID: online[e.Member]}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(struct {
+		Metric  string             `json:"metric"`
+		Entries []leaderboardEntry `json:"entries"`
+	}{metric, result})
+}
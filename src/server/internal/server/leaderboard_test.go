@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestLeaderboard(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Game.MaxHP, cfg.Game.AttackDamage = 30, 30
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func(caps uint8) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: caps})
+		return c, fake
+	}
+	attacker, attackerFake := join(protocol.CapsLegacy | protocol.CapLeaderboard)
+	victim, victimFake := join(protocol.CapsLegacy)
+	for id, x := range map[uint32]uint32{attacker.player.ID: 1000, victim.player.ID: 1020} {
+		if err := s.gameWorld.ApplyAdminEvent(types.GameEvent{PlayerID: id, Type: types.EventTeleport, X: x, Y: 1000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attacker.player.SetFacingRight(true)
+	s.gameWorld.Step()
+	if rej := s.gameWorld.Attack(attacker.player.ID); rej.Rejected() {
+		t.Fatalf("attack rejected: %+v", rej)
+	}
+	s.gameWorld.Step() // one hit, one kill
+	s.flushLeaderboard(t.Context())
+	s.sendLeaderboard()
+
+	leaderboards := func(fake *testutil.FakeConn, n int) map[uint8][]byte {
+		t.Helper()
+		got := make(map[uint8][]byte)
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+			frames, err := fake.Frames()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range frames {
+				if msg := f.Payload[seqHeaderSize:]; msg[0] == protocol.MessageLeaderboard {
+					got[msg[1]] = msg
+				}
+			}
+			if len(got) >= n || time.Now().After(deadline) {
+				return got
+			}
+		}
+	}
+	got := leaderboards(attackerFake, 2)
+	for metric, score := range []uint32{1, 30} { // kills, damage
+		want := s.protocol.EncodeLeaderboard(uint8(metric), []protocol.LeaderboardEntry{{PlayerID: attacker.player.ID, Score: score}})
+		if string(got[uint8(metric)]) != string(want) {
+			t.Errorf("LEADERBOARD %d = %x, want %x", metric, got[uint8(metric)], want)
+		}
+	}
+	if got := leaderboards(victimFake, 0); len(got) != 0 {
+		t.Errorf("client without the capability got %d LEADERBOARDs", len(got))
+	}
+
+	member := "player:" + strconv.FormatUint(uint64(attacker.player.ID), 10)
+	get := func(query string) (int, leaderboardResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.handleLeaderboard(w, httptest.NewRequest(http.MethodGet, "/leaderboard?"+query, nil))
+		var resp leaderboardResponse
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, resp
+	}
+	code, resp := get("metric=damage&member=" + member)
+	if code != http.StatusOK || len(resp.Entries) != 1 || resp.Entries[0].Member != member ||
+		resp.Entries[0].Rank != 1 || resp.Entries[0].Score != 30 || resp.Entries[0].PlayerID != attacker.player.ID {
+		t.Errorf("around the attacker = %d %+v", code, resp)
+	}
+	if code, _ := get("metric=damage&member=account:nobody"); code != http.StatusNotFound {
+		t.Errorf("unranked member = %d, want 404", code)
+	}
+	if code, _ := get("metric=deaths"); code != http.StatusBadRequest {
+		t.Errorf("unknown metric = %d, want 400", code)
+	}
+}
+
+type leaderboardResponse struct {
+	Metric  string             `json:"metric"`
+	Entries []leaderboardEntry `json:"entries"`
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// HTTP listeners.
+//
+// Everything is served on HOST:PORT unless ADMIN_ADDR or STATIC_ADDR give the admin
+// endpoints (/admin, /metrics, /debug, /world) or the client build a listener of
+// their own — typically the admin API on 127.0.0.1 only, out of reach of players.
+// Routes of a group without its own address stay on the game listener, so the
+// default deployment is unchanged. Each listener has its own TLS certificate and its
+// own middleware chain (middleware.go); /health is answered on every listener so each
+// port can be probed on its own.
+//
+// Only the game listener takes part in a blue/green handover (handover.go); the
+// others are bound again by the new process once the old one has closed them. The
+// game listener alone can also be bound several times to spread accepts across
+// cores (reuseport.go).
+
+// middleware wraps a listener's handler.
+type middleware func(http.Handler) http.Handler
+
+// httpListener — one bound address and the routes served on it.
+type httpListener struct {
+	name      string // game, admin or static (logs)
+	addr      string
+	certFile  string // empty = plain HTTP
+	keyFile   string
+	mux       *http.ServeMux
+	chain     []middleware // outermost first
+	inherited net.Listener // the game listener after a handover; nil = bind addr
+	sockets   int          // SO_REUSEPORT sockets bound on addr (reuseport.go); 0 = one plain socket
+}
+
+// handler returns the listener's mux wrapped in its middleware chain.
+func (l *httpListener) handler() http.Handler {
+	return chain(l.mux, l.chain...)
+}
+
+// httpListeners returns the game listener first, then the admin and static ones when
+// they have their own address, and a mux per route group (game, admin, static) that
+// points at the listener serving it.
+func (s *Server) httpListeners() (listeners []*httpListener, game, admin, static *http.ServeMux) {
+	srv := s.cfg.Server
+	gameL := s.newHTTPListener("game", net.JoinHostPort(srv.Host, strconv.Itoa(srv.Port)), srv.TLSCertFile, srv.TLSKeyFile)
+	gameL.inherited = s.listener
+	gameL.sockets = srv.ReusePortListeners
+	listeners = append(listeners, gameL)
+
+	group := func(name, addr, cert, key string) *http.ServeMux {
+		if addr == "" {
+			return gameL.mux
+		}
+		l := s.newHTTPListener(name, addr, cert, key)
+		listeners = append(listeners, l)
+		return l.mux
+	}
+	admin = group("admin", srv.AdminAddr, srv.AdminTLSCertFile, srv.AdminTLSKeyFile)
+	static = group("static", srv.StaticAddr, srv.StaticTLSCertFile, srv.StaticTLSKeyFile)
+
+	for _, l := range listeners {
+		l.mux.HandleFunc("/health", s.handleHealth)
+	}
+	return listeners, gameL.mux, admin, static
+}
+
+func (s *Server) newHTTPListener(name, addr, cert, key string) *httpListener {
+	l := &httpListener{
+		name: name, addr: addr, certFile: cert, keyFile: key, mux: http.NewServeMux(),
+		chain: []middleware{recoverPanics, withRequestID, logRequests},
+	}
+	if cert != "" && s.cfg.Server.HSTSMaxAge > 0 {
+		l.chain = append(l.chain, hsts(int(s.cfg.Server.HSTSMaxAge.Seconds())))
+	}
+	return l
+}
+
+// hsts tells browsers to use HTTPS only for this host for maxAge seconds.
+func hsts(maxAge int) middleware {
+	value := fmt.Sprintf("max-age=%d", maxAge)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// serveHTTP binds every listener and serves until one of them stops. A listener that
+// fails to bind or serve stops the rest; http.ErrServerClosed (Shutdown, handover)
+// returns nil.
+func (s *Server) serveHTTP(listeners []*httpListener) error {
+	bound := make([][]net.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		lns, err := s.bindListener(l)
+		if err != nil {
+			for _, b := range bound {
+				closeListeners(b)
+			}
+			return fmt.Errorf("%s listener: %w", l.name, err)
+		}
+		bound = append(bound, lns)
+	}
+
+	servers := make([]*http.Server, len(listeners))
+	for i, l := range listeners {
+		servers[i] = &http.Server{Handler: l.handler()}
+	}
+	s.httpMu.Lock()
+	s.httpServers = servers
+	s.httpMu.Unlock()
+	if s.cfg.Server.HandoverSocket != "" {
+		go s.serveHandover(bound[0][0])
+	}
+
+	sockets := 0
+	for _, lns := range bound {
+		sockets += len(lns)
+	}
+	errCh := make(chan error, sockets)
+	for i, l := range listeners {
+		slog.Info("server listening", "listener", l.name, "addr", bound[i][0].Addr().String(),
+			"inherited", l.inherited != nil, "tls", l.certFile != "", "sockets", len(bound[i]))
+		for j, ln := range bound[i] {
+			if i == 0 {
+				ln = countAccepts(ln, j)
+			}
+			go func() {
+				var err error
+				if l.certFile != "" {
+					err = servers[i].ServeTLS(ln, l.certFile, l.keyFile)
+				} else {
+					err = servers[i].Serve(ln)
+				}
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					err = fmt.Errorf("%s listener: %w", l.name, err)
+				}
+				errCh <- err
+			}()
+		}
+	}
+	err := <-errCh
+	s.closeHTTP()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// closeHTTP closes every listener and its connections.
+func (s *Server) closeHTTP() {
+	s.httpMu.Lock()
+	servers := s.httpServers
+	s.httpMu.Unlock()
+	for _, srv := range servers {
+		srv.Close()
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/testutil"
+)
+
+func TestRoutesSplitAcrossListeners(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.AdminToken = "secret"
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	pattern := func(l *httpListener, path string) string {
+		_, p := l.mux.Handler(httptest.NewRequest(http.MethodGet, path, nil))
+		return p
+	}
+
+	listeners := s.routes()
+	if len(listeners) != 1 {
+		t.Fatalf("%d listeners without ADMIN_ADDR/STATIC_ADDR, want 1", len(listeners))
+	}
+	for _, path := range []string{"/ws", "/metrics", "/admin/bots", "/health"} {
+		if p := pattern(listeners[0], path); p != path {
+			t.Errorf("shared listener serves %s with %q", path, p)
+		}
+	}
+
+	cfg.Server.AdminAddr = "127.0.0.1:0"
+	cfg.Server.AdminTLSCertFile, cfg.Server.AdminTLSKeyFile = "admin.crt", "admin.key"
+	cfg.Server.StaticAddr = "127.0.0.1:0"
+	cfg.Server.HSTSMaxAge = time.Hour
+	listeners = s.routes()
+	if len(listeners) != 3 {
+		t.Fatalf("%d listeners, want game, admin and static", len(listeners))
+	}
+	game, admin, static := listeners[0], listeners[1], listeners[2]
+	for _, tc := range []struct {
+		l          *httpListener
+		path, want string
+	}{
+		{game, "/ws", "/ws"},
+		{game, "/metrics", ""}, // no static fallback either: it moved to its own listener
+		{game, "/admin/bots", ""},
+		{admin, "/metrics", "/metrics"},
+		{admin, "/admin/bots", "/admin/bots"},
+		{admin, "/ws", ""},
+		{admin, "/debug/config", "/debug/config"},
+		{static, "/", "/"},
+		{static, "/debug/config", "/"}, // SPA fallback, not the debug handler
+		{static, "/health", "/health"},
+	} {
+		if p := pattern(tc.l, tc.path); p != tc.want {
+			t.Errorf("%s listener serves %s with %q, want %q", tc.l.name, tc.path, p, tc.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	admin.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=3600" {
+		t.Errorf("TLS admin listener HSTS = %q, want max-age=3600", got)
+	}
+	rec = httptest.NewRecorder()
+	game.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("plain game listener sent HSTS %q", got)
+	}
+
+	// Without ADMIN_TOKEN the effective config is not served at all.
+	cfg.Server.AdminToken = ""
+	admin = s.routes()[1]
+	if p := pattern(admin, "/debug/config"); p == "/debug/config" {
+		t.Error("/debug/config served without ADMIN_TOKEN")
+	}
+	if p := pattern(admin, "/debug/path"); p != "/debug/path" {
+		t.Errorf("/debug/path served with %q", p)
+	}
+}
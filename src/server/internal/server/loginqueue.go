@@ -0,0 +1,267 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Login queue (Net.LoginQueueSize > 0).
+//
+// A full server lets up to LoginQueueSize more clients upgrade instead of refusing
+// them with 503. Their JOIN is held: the connection leaves the handshake (no
+// handshake timeout, not a pending handshake) and waits in connQueued, getting
+// QUEUE_STATUS — position, queue length, estimated wait — right away and every
+// LoginQueueUpdate. Whenever a player leaves, the first queued client of the highest
+// tier takes the slot: QUEUE_ADMITTED, then the usual join (CONFIG, initial state).
+// While anyone is queued, new JOINs queue too, even if a slot is free for a moment.
+//
+// Tiers, admitted first to last: vip (the session token's auth.FlagVIP), returning
+// (the account left this server less than LoginQueueReturnWindow ago, e.g. a dropped
+// connection) and standard; FIFO within a tier. The wait estimate is the position
+// divided by the churn — players leaving per second, smoothed over churnHalfLife.
+
+// churnHalfLife — how fast the churn estimate forgets the past.
+const churnHalfLife = 5 * time.Minute
+
+// queueTiers — QUEUE_STATUS tier names for metrics, by protocol.QueueTier*.
+var queueTiers = [...]string{"standard", "returning", "vip"}
+
+// loginQueue — queued JOINs and the departures the wait estimates come from.
+type loginQueue struct {
+	mu         sync.Mutex
+	tiers      [len(queueTiers)][]*queuedJoin // FIFO per tier
+	left       map[string]time.Time           // account → when it last left, within the return window
+	departures int                            // players that left since the last round
+	churn      float64                        // players leaving per second, smoothed
+	lastRound  time.Time
+}
+
+// queuedJoin — a connection waiting in the queue.
+type queuedJoin struct {
+	c     *Connection
+	tier  uint8
+	since time.Time
+}
+
+func newLoginQueue() *loginQueue {
+	return &loginQueue{left: make(map[string]time.Time), lastRound: time.Now()}
+}
+
+// length returns the number of queued clients. Caller holds q.mu.
+func (q *loginQueue) length() int {
+	n := 0
+	for _, t := range q.tiers {
+		n += len(t)
+	}
+	return n
+}
+
+// Len returns the number of queued clients.
+func (q *loginQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.length()
+}
+
+// freeSlots returns how many more players may join.
+func (s *Server) freeSlots() int {
+	s.connectionsMu.RLock()
+	defer s.connectionsMu.RUnlock()
+	return s.cfg.Net.MaxConnections - len(s.connections)
+}
+
+// queueFull reports whether a full server has to refuse new connections outright.
+func (s *Server) queueFull() bool {
+	return s.cfg.Net.LoginQueueSize == 0 || s.loginQueue.Len() >= s.cfg.Net.LoginQueueSize
+}
+
+// queueJoin puts c, which sent JOIN (connJoining), into the queue if the server is
+// full or others are waiting. It returns false when c may join right away.
+func (s *Server) queueJoin(c *Connection) bool {
+	if s.cfg.Net.LoginQueueSize == 0 {
+		return false
+	}
+	q := s.loginQueue
+	q.mu.Lock()
+	if q.length() == 0 && s.freeSlots() > 0 {
+		q.mu.Unlock()
+		return false
+	}
+	if !atomic.CompareAndSwapInt32(&c.state, connJoining, connQueued) {
+		q.mu.Unlock()
+		return true // closed meanwhile
+	}
+	tier := uint8(protocol.QueueTierStandard)
+	if c.vip {
+		tier = protocol.QueueTierVIP
+	} else if at, ok := q.left[c.account]; ok && time.Since(at) < s.cfg.Net.LoginQueueReturnWindow {
+		tier = protocol.QueueTierReturning
+	}
+	q.tiers[tier] = append(q.tiers[tier], &queuedJoin{c: c, tier: tier, since: time.Now()})
+	metrics.LoginQueueLength.WithLabelValues(queueTiers[tier]).Inc()
+	position, length := q.position(c), q.length()
+	wait := q.estimate(position)
+	q.mu.Unlock()
+
+	slog.Debug("join queued", "ip", c.ip, "account", c.account, "tier", queueTiers[tier], "position", position)
+	s.sendQueueStatus(c, position, length, wait, tier)
+	return true
+}
+
+// position returns c's 1-based place in the queue; 0 = not queued. Caller holds q.mu.
+func (q *loginQueue) position(c *Connection) int {
+	ahead := 0
+	for tier := len(q.tiers) - 1; tier >= 0; tier-- {
+		for i, j := range q.tiers[tier] {
+			if j.c == c {
+				return ahead + i + 1
+			}
+		}
+		ahead += len(q.tiers[tier])
+	}
+	return 0
+}
+
+// estimate returns the expected wait in seconds at position; 0 = no estimate.
+// Caller holds q.mu.
+func (q *loginQueue) estimate(position int) uint32 {
+	if q.churn <= 0 {
+		return 0
+	}
+	return uint32(min(math.Ceil(float64(position)/q.churn), math.MaxUint32))
+}
+
+// remove drops c from the queue (it disconnected while waiting).
+func (q *loginQueue) remove(c *Connection) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for tier, joins := range q.tiers {
+		for i, j := range joins {
+			if j.c == c {
+				q.tiers[tier] = append(joins[:i], joins[i+1:]...)
+				metrics.LoginQueueLength.WithLabelValues(queueTiers[tier]).Dec()
+				metrics.LoginQueueAbandoned.Inc()
+				return
+			}
+		}
+	}
+}
+
+// playerLeft counts a departure and remembers account for the returning tier.
+func (q *loginQueue) playerLeft(account string) {
+	q.mu.Lock()
+	q.departures++
+	if account != "" {
+		q.left[account] = time.Now()
+	}
+	q.mu.Unlock()
+}
+
+// admitQueued lets queued clients join while there are free slots, highest tier first.
+func (s *Server) admitQueued() {
+	q := s.loginQueue
+	var admitted []*queuedJoin
+	q.mu.Lock()
+	for free := s.freeSlots(); free > 0; {
+		j := q.pop()
+		if j == nil {
+			break
+		}
+		metrics.LoginQueueLength.WithLabelValues(queueTiers[j.tier]).Dec()
+		if atomic.CompareAndSwapInt32(&j.c.state, connQueued, connJoining) {
+			admitted = append(admitted, j)
+			free--
+		}
+	}
+	q.mu.Unlock()
+
+	for _, j := range admitted {
+		waited := time.Since(j.since)
+		metrics.LoginQueueWait.WithLabelValues(queueTiers[j.tier]).Observe(waited.Seconds())
+		s.sendDirect(j.c, s.protocol.EncodeQueueAdmitted(uint32(min(waited.Milliseconds(), math.MaxUint32))))
+		s.joinPlayer(j.c)
+	}
+}
+
+// pop takes the first client of the highest non-empty tier. Caller holds q.mu.
+func (q *loginQueue) pop() *queuedJoin {
+	for tier := len(q.tiers) - 1; tier >= 0; tier-- {
+		if joins := q.tiers[tier]; len(joins) > 0 {
+			j := joins[0]
+			joins[0] = nil
+			q.tiers[tier] = joins[1:]
+			return j
+		}
+	}
+	return nil
+}
+
+// runLoginQueue admits what it can and updates the queued clients every
+// Net.LoginQueueUpdate.
+func (s *Server) runLoginQueue() {
+	t := time.NewTicker(s.cfg.Net.LoginQueueUpdate)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.admitQueued()
+			s.loginQueueRound(time.Now())
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// loginQueueRound updates the churn, forgets accounts past the return window and
+// sends every queued client its QUEUE_STATUS.
+func (s *Server) loginQueueRound(now time.Time) {
+	type status struct {
+		c              *Connection
+		position, wait uint32
+		tier           uint8
+	}
+	q := s.loginQueue
+	q.mu.Lock()
+	if elapsed := now.Sub(q.lastRound); elapsed > 0 {
+		rate := float64(q.departures) / elapsed.Seconds()
+		if q.churn == 0 {
+			q.churn = rate
+		} else {
+			alpha := 1 - math.Exp2(-elapsed.Seconds()/churnHalfLife.Seconds())
+			q.churn += alpha * (rate - q.churn)
+		}
+		q.departures, q.lastRound = 0, now
+		metrics.LoginQueueChurn.Set(q.churn)
+	}
+	for account, at := range q.left {
+		if now.Sub(at) >= s.cfg.Net.LoginQueueReturnWindow {
+			delete(q.left, account)
+		}
+	}
+	length := q.length()
+	statuses := make([]status, 0, length)
+	position := 0
+	for tier := len(q.tiers) - 1; tier >= 0; tier-- {
+		for _, j := range q.tiers[tier] {
+			position++
+			statuses = append(statuses, status{j.c, uint32(position), q.estimate(position), j.tier})
+		}
+	}
+	q.mu.Unlock()
+
+	for _, st := range statuses {
+		s.sendQueueStatus(st.c, int(st.position), length, st.wait, st.tier)
+	}
+}
+
+// sendQueueStatus sends QUEUE_STATUS; a newer one supersedes it in the write queue.
+func (s *Server) sendQueueStatus(c *Connection, position, length int, wait uint32, tier uint8) {
+	msg := s.protocol.EncodeQueueStatus(uint32(position), uint32(length), wait, tier)
+	s.sendLatest(c, msg, writeClassEvent, dedupeKey(protocol.MessageQueueStatus, 0))
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestLoginQueue(t *testing.T) {
+	s := newSessionServer(t, config.SessionTakeover)
+	s.cfg.Net.MaxConnections = 1
+	s.cfg.Net.LoginQueueSize = 5
+	s.cfg.Net.LoginQueueReturnWindow = time.Minute
+
+	join := func(account string, vip bool) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.account, c.vip = account, vip
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	state := func(c *Connection) int32 { return atomic.LoadInt32(&c.state) }
+
+	alice, _ := join("alice", false)
+	bob, bobFake := join("bob", false)
+	carol, carolFake := join("carol", true)
+	if state(alice) != connJoined || state(bob) != connQueued || state(carol) != connQueued {
+		t.Fatalf("states alice=%d bob=%d carol=%d, want alice joined and the others queued", state(alice), state(bob), state(carol))
+	}
+	if status := messagesOf(t, bobFake, protocol.MessageQueueStatus, 1); len(status) != 1 ||
+		binary.LittleEndian.Uint32(status[0][1:]) != 1 || status[0][13] != protocol.QueueTierStandard {
+		t.Errorf("bob's QUEUE_STATUS = % x, want position 1 of the standard tier", status)
+	}
+	if pos := s.loginQueue.position(carol); pos != 1 {
+		t.Errorf("vip carol at position %d, want ahead of bob", pos)
+	}
+
+	// Alice leaves: carol takes the slot, and alice queues as a returning player ahead of bob.
+	s.cleanupConnection(alice)
+	if state(carol) != connJoined {
+		t.Fatalf("carol state %d after a slot freed up, want joined", state(carol))
+	}
+	if len(messagesOf(t, carolFake, protocol.MessageQueueAdmitted, 1)) != 1 || !hasMessage(t, carolFake, protocol.MessageConfig, time.Second) {
+		t.Error("carol did not get QUEUE_ADMITTED and CONFIG")
+	}
+	again, _ := join("alice", false)
+	if pos := s.loginQueue.position(again); state(again) != connQueued || pos != 1 {
+		t.Errorf("returning alice: state %d, position %d; want queued first", state(again), pos)
+	}
+
+	// One departure in the last 10 s: bob, second in line, waits about 20 s.
+	now := time.Now()
+	s.loginQueue.lastRound = now.Add(-10 * time.Second)
+	s.loginQueueRound(now)
+	status := messagesOf(t, bobFake, protocol.MessageQueueStatus, 2)
+	if last := status[len(status)-1]; binary.LittleEndian.Uint32(last[1:]) != 2 || binary.LittleEndian.Uint32(last[9:]) != 20 {
+		t.Errorf("bob's QUEUE_STATUS = % x, want position 2 and a 20 s wait", last)
+	}
+
+	// A queued client that disconnects leaves the queue.
+	s.cleanupConnection(again)
+	if n := s.loginQueue.Len(); n != 1 {
+		t.Errorf("queue length %d after alice left it, want 1", n)
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Maintenance mode for zero-data-loss deploys:
+//
+//  1. POST /admin/maintenance?countdown=S — clients get MAINTENANCE(scheduled, S),
+//     new connections are rejected with 503 + Retry-After.
+//  2. After the countdown the simulation pauses, the world snapshot is written to
+//     MAINTENANCE_SNAPSHOT_PATH and clients get MAINTENANCE(paused).
+//  3. DELETE /admin/maintenance resumes the world and accepts connections again.
+//
+// Scheduled restarts and maintenance windows (scheduled.go) go through the same steps.
+
+// maintenanceState — фаза и таймер режима обслуживания. phase читается атомарно на
+// горячем пути (handleWebSocket), остальное под mu.
+type maintenanceState struct {
+	phase int32 // atomic, protocol.Maintenance*
+
+	mu         sync.Mutex
+	timer      *time.Timer
+	pauseAt    time.Time
+	retryAfter int    // seconds for the Retry-After header
+	snapshot   string // path of the last written snapshot
+	snapErr    string
+}
+
+// inMaintenance сообщает, отклоняются ли сейчас новые подключения.
+func (s *Server) inMaintenance() bool {
+	return atomic.LoadInt32(&s.maint.phase) != protocol.MaintenanceOver
+}
+
+// rejectMaintenance отвечает 503 с Retry-After на попытку подключения во время обслуживания.
+func (s *Server) rejectMaintenance(w http.ResponseWriter) {
+	s.maint.mu.Lock()
+	retry := s.maint.retryAfter
+	s.maint.mu.Unlock()
+	metrics.MaintenanceRejected.Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	http.Error(w, "Server maintenance", http.StatusServiceUnavailable)
+}
+
+// startMaintenance объявляет обслуживание; симуляция встанет через countdown.
+// Повторный вызов переносит момент паузы (если мир ещё не остановлен).
+func (s *Server) startMaintenance(countdown time.Duration, retryAfter int) error {
+	m := &s.maint
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if atomic.LoadInt32(&m.phase) == protocol.MaintenancePaused {
+		return fmt.Errorf("world is already paused")
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.retryAfter = retryAfter
+	m.pauseAt = time.Now().Add(countdown)
+	m.timer = time.AfterFunc(countdown, s.pauseForMaintenance)
+	s.setMaintenancePhase(protocol.MaintenanceScheduled)
+
+	slog.Warn("maintenance scheduled", "countdown", countdown, "retry_after_sec", retryAfter)
+	s.broadcastEvent(s.protocol.EncodeMaintenance(protocol.MaintenanceScheduled, uint32(countdown.Milliseconds())))
+	return nil
+}
+
+// pauseForMaintenance останавливает симуляцию и сохраняет снапшот мира.
+func (s *Server) pauseForMaintenance() {
+	m := &s.maint
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if atomic.LoadInt32(&m.phase) != protocol.MaintenanceScheduled {
+		return // отменено, пока таймер срабатывал
+	}
+	m.timer = nil
+	s.pauseLocked()
+}
+
+// pauseNow останавливает мир сразу, без обратного отсчёта (handover).
+func (s *Server) pauseNow(retryAfter int) {
+	m := &s.maint
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.retryAfter = retryAfter
+	if atomic.LoadInt32(&m.phase) != protocol.MaintenancePaused {
+		s.pauseLocked()
+	}
+}
+
+// pauseLocked — общая часть паузы; вызывается под s.maint.mu.
+func (s *Server) pauseLocked() {
+	m := &s.maint
+
+	// SetPaused возвращается между тиками — после него снапшот больше не меняется.
+	s.gameWorld.SetPaused(true)
+	s.setMaintenancePhase(protocol.MaintenancePaused)
+
+	m.snapshot, m.snapErr = "", ""
+	if path := s.cfg.Server.MaintenanceSnapshotPath; path != "" {
+		if err := s.writeMaintenanceSnapshot(path); err != nil {
+			m.snapErr = err.Error()
+			slog.Error("maintenance snapshot failed", "path", path, "error", err)
+		} else {
+			m.snapshot = path
+		}
+	}
+
+	slog.Warn("world paused for maintenance", "snapshot", m.snapshot)
+	s.broadcastEvent(s.protocol.EncodeMaintenance(protocol.MaintenancePaused, 0))
+}
+
+// endMaintenance возобновляет симуляцию и снова принимает подключения.
+func (s *Server) endMaintenance() {
+	m := &s.maint
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if atomic.LoadInt32(&m.phase) == protocol.MaintenanceOver {
+		return
+	}
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	if s.gameWorld.Paused() {
+		s.gameWorld.SetPaused(false)
+	}
+	s.setMaintenancePhase(protocol.MaintenanceOver)
+
+	slog.Warn("maintenance over")
+	s.broadcastEvent(s.protocol.EncodeMaintenance(protocol.MaintenanceOver, 0))
+}
+
+func (s *Server) setMaintenancePhase(phase int32) {
+	atomic.StoreInt32(&s.maint.phase, phase)
+	metrics.MaintenancePhase.Set(float64(phase))
+}
+
+// sendMaintenanceStatus сообщает игроку, завершившему JOIN во время обслуживания, текущую фазу.
+func (s *Server) sendMaintenanceStatus(conn *Connection) {
+	m := &s.maint
+	m.mu.Lock()
+	phase := atomic.LoadInt32(&m.phase)
+	left := time.Until(m.pauseAt)
+	m.mu.Unlock()
+
+	switch phase {
+	case protocol.MaintenanceScheduled:
+		s.sendDirect(conn, s.protocol.EncodeMaintenance(protocol.MaintenanceScheduled, uint32(max(left, 0).Milliseconds())))
+	case protocol.MaintenancePaused:
+		s.sendDirect(conn, s.protocol.EncodeMaintenance(protocol.MaintenancePaused, 0))
+	}
+}
+
+// writeMaintenanceSnapshot пишет снапшот (game.ExportedState) атомарно: во временный файл и rename.
+func (s *Server) writeMaintenanceSnapshot(path string) error {
+	data, err := json.MarshalIndent(s.gameWorld.ExportState(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// handleAdminMaintenance manages maintenance mode:
+//
+//	GET    /admin/maintenance                              → current status
+//	POST   /admin/maintenance?countdown=S[&retry_after=R]  → announce, pause in S seconds
+//	DELETE /admin/maintenance                              → resume
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		q := r.URL.Query()
+		countdown, err := strconv.Atoi(q.Get("countdown"))
+		if err != nil || countdown < 0 {
+			http.Error(w, "countdown must be a non-negative integer (seconds)", http.StatusBadRequest)
+			return
+		}
+		retryAfter := int(s.cfg.Server.MaintenanceRetryAfter.Seconds())
+		if v := q.Get("retry_after"); v != "" {
+			if retryAfter, err = strconv.Atoi(v); err != nil || retryAfter < 0 {
+				http.Error(w, "retry_after must be a non-negative integer (seconds)", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.startMaintenance(time.Duration(countdown)*time.Second, retryAfter); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	case http.MethodDelete:
+		s.endMaintenance()
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m := &s.maint
+	m.mu.Lock()
+	status := struct {
+		Phase       string `json:"phase"`
+		CountdownMs int64  `json:"countdown_ms,omitempty"`
+		RetryAfter  int    `json:"retry_after,omitempty"`
+		Snapshot    string `json:"snapshot,omitempty"`
+		Error       string `json:"snapshot_error,omitempty"`
+		// Next scheduled restart / maintenance window (scheduled.go)
+		Scheduled map[string]time.Time `json:"scheduled,omitempty"`
+	}{Phase: maintenancePhaseNames[atomic.LoadInt32(&m.phase)], Scheduled: s.nextScheduled()}
+	if atomic.LoadInt32(&m.phase) != protocol.MaintenanceOver {
+		status.RetryAfter = m.retryAfter
+		status.Snapshot, status.Error = m.snapshot, m.snapErr
+	}
+	if atomic.LoadInt32(&m.phase) == protocol.MaintenanceScheduled {
+		status.CountdownMs = max(time.Until(m.pauseAt), 0).Milliseconds()
+	}
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+var maintenancePhaseNames = [...]string{
+	protocol.MaintenanceOver:      "off",
+	protocol.MaintenanceScheduled: "scheduled",
+	protocol.MaintenancePaused:    "paused",
+}
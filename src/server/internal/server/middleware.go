@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// HTTP middleware.
+//
+// Every listener wraps its routes in recoverPanics → withRequestID → logRequests
+// (listeners.go), so a panicking handler costs one 500 and a log line instead of the
+// process, and every request carries an ID in its context and the X-Request-ID
+// response header. The WebSocket upgrade additionally passes the admission checks of
+// wsAdmission, one middleware per concern, before handleWebSocket runs: capacity,
+// maintenance, origin, authentication and the connection-attempt budgets.
+
+// chain wraps h in mws, the first one outermost.
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	wsClientKey
+)
+
+// maxRequestIDLen bounds an X-Request-ID taken from the client (or a proxy).
+const maxRequestIDLen = 64
+
+// requestID returns the ID withRequestID gave the request; "" outside the chain.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withRequestID keeps a well-formed X-Request-ID from a proxy in front, or makes one
+// up, and echoes it in the response.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = fmt.Sprintf("%016x", rand.Uint64())
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// recoverPanics turns a panic in a handler into a 500 (when nothing was written yet)
+// and a logged stack. http.ErrAbortHandler is re-raised: it is how a handler asks
+// net/http to drop the connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			metrics.PanicsRecovered.WithLabelValues("http").Inc()
+			slog.Error("http handler panic", "panic", p, "method", r.Method, "path", r.URL.Path,
+				"request_id", requestID(r.Context()), "stack", string(debug.Stack()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRequests logs every request at debug level once it completes. A WebSocket
+// upgrade is logged as status 101 when the connection is hijacked.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		slog.Debug("http request", "method", r.Method, "path", r.URL.Path, "status", sw.statusCode(),
+			"duration_ms", time.Since(start).Milliseconds(), "remote_addr", r.RemoteAddr,
+			"request_id", requestID(r.Context()))
+	})
+}
+
+// statusWriter records the response status. It passes Flush (SSE, worldview.go) and
+// Hijack (the WebSocket upgrade) through to the wrapped writer.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// wsClient — who is connecting to /ws, as established by the admission checks.
+type wsClient struct {
+	ip      string // RemoteAddr host
+	account string // from the session token; "" = anonymous
+	vip     bool   // the token's auth.FlagVIP (see loginqueue.go)
+}
+
+// wsClientFrom returns the client withWSClient stored in ctx.
+func wsClientFrom(ctx context.Context) wsClient {
+	c, _ := ctx.Value(wsClientKey).(wsClient)
+	return c
+}
+
+// wsAdmission returns the checks a WebSocket upgrade must pass, cheapest first.
+func (s *Server) wsAdmission() []middleware {
+	return []middleware{s.admitCapacity, s.admitMaintenance, s.admitOrigin, s.authenticateWS, s.admitAttempt}
+}
+
+// admitCapacity rejects connections beyond MAX_CONNECTIONS (and LOGIN_QUEUE_SIZE), or
+// to a tenant over its fair-use quota, before doing anything else.
+func (s *Server) admitCapacity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.connectionsMu.RLock()
+		connCount := len(s.connections)
+		s.connectionsMu.RUnlock()
+		if connCount >= s.cfg.Net.MaxConnections && s.queueFull() {
+			http.Error(w, "Server full", http.StatusServiceUnavailable)
+			return
+		}
+		if s.usage.overQuota.Load() {
+			http.Error(w, "Over fair-use quota", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admitMaintenance rejects connections during a maintenance window (maintenance.go).
+func (s *Server) admitMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.inMaintenance() {
+			s.rejectMaintenance(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// admitOrigin checks the Origin against ALLOWED_ORIGINS (origin.go).
+func (s *Server) admitOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.originAllowed(r) {
+			metrics.OriginRejected.Inc()
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateWS verifies the session token (session.go) and stores the client in
+// the request context. A bad token spends the anonymous IP budget (ratelimit.go), so
+// forged tokens cannot be used to probe without limit.
+func (s *Server) authenticateWS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// RemoteAddr includes port — extract host only.
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr // fallback for unix sockets / tests
+		}
+		claims, err := s.authenticate(r)
+		if err != nil {
+			metrics.AuthFailures.WithLabelValues(authFailureReason(err)).Inc()
+			if !s.allowAnonymousAttempt(clientIP) {
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), wsClientKey, wsClient{ip: clientIP, account: claims.Account, vip: claims.VIP})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// admitAttempt charges the attempt to the account and IP budgets (ratelimit.go).
+func (s *Server) admitAttempt(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := wsClientFrom(r.Context())
+		if !s.allowAttempt(client.ip, client.account) {
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestListenerMiddleware(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.AllowedOrigins = "https://game.example"
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	game := s.routes()[0]
+	game.mux.HandleFunc("/boom", func(http.ResponseWriter, *http.Request) { panic("boom") })
+	srv := httptest.NewServer(game.handler())
+	t.Cleanup(srv.Close)
+
+	get := func(path string, header http.Header) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get("/health", http.Header{"X-Request-Id": {"edge-42"}})
+	if got := resp.Header.Get("X-Request-ID"); got != "edge-42" {
+		t.Errorf("request ID = %q, want the proxy's edge-42", got)
+	}
+	resp = get("/health", http.Header{"X-Request-Id": {"bad id/../" + strings.Repeat("x", 80)}})
+	if got := resp.Header.Get("X-Request-ID"); len(got) != 16 {
+		t.Errorf("request ID for a malformed header = %q, want a generated one", got)
+	}
+
+	before, _ := metrics.ReadCollector(metrics.PanicsRecovered)
+	if resp = get("/boom", nil); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("panicking handler = %d, want 500", resp.StatusCode)
+	}
+	if after, _ := metrics.ReadCollector(metrics.PanicsRecovered); after != before+1 {
+		t.Errorf("panics recovered %v → %v, want one more", before, after)
+	}
+
+	// The admission chain runs before the upgrade...
+	if resp = get("/ws", http.Header{"Origin": {"https://evil.example"}}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin = %d, want 403", resp.StatusCode)
+	}
+	// ...and the upgrade hijacks through the logging writer.
+	dialer := ws.Dialer{Header: ws.HandshakeHeaderHTTP(http.Header{"Origin": {"https://game.example"}})}
+	conn, _, _, err := dialer.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+	if err != nil {
+		t.Fatalf("upgrade through the middleware chain: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Minimap channel. Clients that set protocol.CapMinimap in JOIN get a MINIMAP every
+// Net.MinimapInterval: player counts on a coarse MinimapCols×MinimapRows grid over the
+// whole world, run-length encoded. Enough to draw a minimap heat layer without
+// shipping every position; the grid is built from the tick snapshot and encoded once
+// per period for all subscribers. Clients without the flag never see the message.
+
+// minimapGrid returns the grid dimensions for cfg, clamped to 1..255 per axis.
+func (s *Server) minimapGrid() (cols, rows int) {
+	clamp := func(n int) int { return min(max(n, 1), 255) }
+	return clamp(s.cfg.Net.MinimapCols), clamp(s.cfg.Net.MinimapRows)
+}
+
+// encodeMinimap counts players per cell of a width×height world and encodes the
+// MINIMAP message.
+func (s *Server) encodeMinimap(players []types.PlayerState, width, height uint32) []byte {
+	cols, rows := s.minimapGrid()
+	cellW := max((int(width)+cols-1)/cols, 1)
+	cellH := max((int(height)+rows-1)/rows, 1)
+
+	counts := make([]uint8, cols*rows)
+	for i := range players {
+		col := min(int(players[i].X)/cellW, cols-1)
+		row := min(int(players[i].Y)/cellH, rows-1)
+		if c := &counts[row*cols+col]; *c < 255 {
+			*c++
+		}
+	}
+	return s.protocol.EncodeMinimap(uint8(cols), uint8(rows), uint16(cellW), uint16(cellH), counts)
+}
+
+// runMinimapLoop sends MINIMAP to subscribers every MinimapInterval.
+// Runs for the lifetime of the server context; started only when the interval is set.
+func (s *Server) runMinimapLoop() {
+	ticker := time.NewTicker(s.cfg.Net.MinimapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sendMinimap()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// sendMinimap encodes the current grid once and enqueues it to every subscriber.
+func (s *Server) sendMinimap() {
+	if s.shedOptional() {
+		return
+	}
+	var subscribers []*Connection
+	s.connectionsMu.RLock()
+	for _, conn := range s.connections {
+		if conn.caps&protocol.CapMinimap != 0 {
+			subscribers = append(subscribers, conn)
+		}
+	}
+	s.connectionsMu.RUnlock()
+	if len(subscribers) == 0 {
+		return
+	}
+
+	snap := s.gameWorld.AcquireSnapshot()
+	bounds := s.gameWorld.Bounds()
+	data := s.encodeMinimap(snap.Players, bounds.Width, bounds.Height)
+	snap.Release()
+	metrics.MinimapBytes.Set(float64(len(data)))
+
+	for _, conn := range subscribers {
+		s.sendLatest(conn, data, writeClassEvent, dedupeKey(protocol.MessageMinimap, 0))
+	}
+	metrics.MinimapSent.Add(float64(len(subscribers)))
+}
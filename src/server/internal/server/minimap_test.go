@@ -0,0 +1,89 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// expandMinimap decodes the run-length encoded cells of a MINIMAP message.
+func expandMinimap(t *testing.T, data []byte) (cols, rows int, counts []uint8) {
+	t.Helper()
+	schema := protocol.LookupSchema(protocol.MessageMinimap)
+	if data[0] != protocol.MessageMinimap || len(data) < schema.Size(0) {
+		t.Fatalf("not a MINIMAP: % x", data)
+	}
+	runs := data[schema.Size(0):]
+	for i := 0; i+1 < len(runs); i += 2 {
+		for range runs[i] {
+			counts = append(counts, runs[i+1])
+		}
+	}
+	return int(data[1]), int(data[2]), counts
+}
+
+func TestEncodeMinimap(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.World.Width, cfg.World.Height = 1000, 500
+	cfg.Net.MinimapCols, cfg.Net.MinimapRows = 4, 2
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}}
+
+	players := []types.PlayerState{
+		{ID: 1, X: 0, Y: 0},
+		{ID: 2, X: 249, Y: 249},
+		{ID: 3, X: 1000, Y: 500}, // far edge lands in the last cell
+		{ID: 4, X: 600, Y: 100},
+	}
+	cols, rows, counts := expandMinimap(t, s.encodeMinimap(players, cfg.World.Width, cfg.World.Height))
+	if cols != 4 || rows != 2 {
+		t.Fatalf("grid = %dx%d, want 4x2", cols, rows)
+	}
+	want := []uint8{2, 0, 1, 0, 0, 0, 0, 1}
+	if string(counts) != string(want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+
+	cfg.Net.MinimapCols, cfg.Net.MinimapRows = 0, 1000
+	if cols, rows := s.minimapGrid(); cols != 1 || rows != 255 {
+		t.Errorf("clamped grid = %dx%d, want 1x255", cols, rows)
+	}
+}
+
+func TestSendMinimapSubscribersOnly(t *testing.T) {
+	s := newSessionServer(t, "")
+	sub, subFake := joinAccount(s, "")
+	sub.caps |= protocol.CapMinimap
+	_, otherFake := joinAccount(s, "")
+
+	s.sendMinimap()
+	if !hasMessage(t, subFake, protocol.MessageMinimap, time.Second) {
+		t.Error("subscriber got no MINIMAP")
+	}
+	if hasMessage(t, otherFake, protocol.MessageMinimap, 100*time.Millisecond) {
+		t.Error("client without the minimap flag got MINIMAP")
+	}
+}
+
+// hasMessage waits up to wait for the write loop and reports whether fake received msgType.
+func hasMessage(t *testing.T, fake *testutil.FakeConn, msgType uint8, wait time.Duration) bool {
+	t.Helper()
+	deadline := time.Now().Add(wait)
+	for {
+		frames, err := fake.Frames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range frames {
+			if len(f.Payload) > seqHeaderSize && f.Payload[seqHeaderSize] == msgType {
+				return true
+			}
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
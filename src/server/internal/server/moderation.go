@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/moderation"
+	"pixi_game_server/internal/protocol"
+)
+
+// Bans and mutes (internal/moderation). Every action goes through the audit log
+// (Server.ModerationLog), so the sanctions survive restarts and /admin/audit shows
+// who imposed or lifted them and why.
+//
+//   - Bans are checked on the upgrade path: a banned account or address gets the
+//     WebSocket upgrade and is closed right away with protocol.CloseBanned and the ban
+//     reason, so the client does not retry. Imposing a ban also kicks the matching live
+//     connections.
+//   - Mutes are for chat: isMuted is what the chat path asks before relaying a message.
+
+// sanctionTargets returns the moderation targets of a connection: its account and IP.
+func sanctionTargets(c *Connection) []string {
+	return []string{moderation.AccountTarget(c.account), moderation.IPTarget(c.ip)}
+}
+
+// isMuted reports whether c may not chat.
+func (s *Server) isMuted(c *Connection) bool {
+	_, muted := s.moderation.Muted(time.Now(), sanctionTargets(c)...)
+	return muted
+}
+
+// rejectBanned closes a freshly upgraded connection of a banned account or address.
+func (s *Server) rejectBanned(conn net.Conn, ban moderation.Sanction, ip string) {
+	metrics.BannedConnections.Inc()
+	metrics.WSCloseCodes.WithLabelValues(closeCodeName(protocol.CloseBanned)).Inc()
+	slog.Info("banned client refused", "target", ban.Target, "ip", ip)
+	closeWithCode(conn, protocol.CloseBanned, ban.Reason, s.directWriteTimeout)
+}
+
+// kickBanned closes every live connection matching a new ban.
+func (s *Server) kickBanned(ban moderation.Sanction) {
+	var kicked []*Connection
+	s.connectionsMu.RLock()
+	for _, c := range s.connections {
+		for _, t := range sanctionTargets(c) {
+			if t == ban.Target {
+				kicked = append(kicked, c)
+				break
+			}
+		}
+	}
+	s.connectionsMu.RUnlock()
+	for _, c := range kicked {
+		metrics.BannedConnections.Inc()
+		s.closeConnection(c, protocol.CloseBanned, ban.Reason)
+	}
+}
+
+// resolveTarget parses a target from the admin API. Besides "account:<id>" and
+// "ip:<addr>" it takes "player:<id>" of a connected player (as shown by /admin/abuse),
+// which resolves to the player's account, or to its IP for an anonymous player.
+func (s *Server) resolveTarget(target string) (string, bool) {
+	idStr, ok := strings.CutPrefix(target, "player:")
+	if !ok {
+		target, err := moderation.ParseTarget(target)
+		return target, err == nil
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return "", false
+	}
+	s.connectionsMu.RLock()
+	c := s.connections[uint32(id)]
+	s.connectionsMu.RUnlock()
+	if c == nil {
+		return "", false
+	}
+	if c.account != "" {
+		return moderation.AccountTarget(c.account), true
+	}
+	return moderation.IPTarget(c.ip), true
+}
+
+// handleAdminSanctions manages bans (or mutes, per action pair):
+//
+//	GET    /admin/bans                                             → active bans
+//	POST   /admin/bans?target=T&reason=R[&duration=S][&by=NAME]    → ban T (S seconds, 0 = permanent)
+//	DELETE /admin/bans?target=T[&reason=R][&by=NAME]               → lift the ban
+//
+// T is account:<id>, ip:<addr> or player:<id> (a connected player).
+func (s *Server) handleAdminSanctions(impose, lift moderation.Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		list := s.moderation.Bans
+		if impose == moderation.ActionMute {
+			list = s.moderation.Mutes
+		}
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list(now))
+			return
+		}
+
+		q := r.URL.Query()
+		entry := moderation.Entry{Time: now.UTC(), Reason: q.Get("reason"), By: q.Get("by")}
+		if entry.By == "" {
+			entry.By = "admin"
+		}
+		switch r.Method {
+		case http.MethodPost:
+			entry.Action = impose
+			if entry.Reason == "" {
+				http.Error(w, "reason is required", http.StatusBadRequest)
+				return
+			}
+			if v := q.Get("duration"); v != "" {
+				sec, err := strconv.Atoi(v)
+				if err != nil || sec < 0 {
+					http.Error(w, "duration must be a non-negative integer (seconds)", http.StatusBadRequest)
+					return
+				}
+				if sec > 0 {
+					entry.Expires = entry.Time.Add(time.Duration(sec) * time.Second)
+				}
+			}
+		case http.MethodDelete:
+			entry.Action = lift
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		target, ok := s.resolveTarget(q.Get("target"))
+		if !ok {
+			http.Error(w, "target must be account:<id>, ip:<addr> or player:<id> of a connected player", http.StatusBadRequest)
+			return
+		}
+		entry.Target = target
+
+		recorded, err := s.moderation.Record(entry)
+		if err != nil {
+			slog.Error("moderation action not recorded", "action", entry.Action, "target", target, "error", err)
+			http.Error(w, "audit log write failed", http.StatusInternalServerError)
+			return
+		}
+		entry = recorded
+		metrics.ModerationActions.WithLabelValues(string(entry.Action)).Inc()
+		slog.Info("moderation action",
+			"action", entry.Action,
+			"target", entry.Target,
+			"by", entry.By,
+			"reason", entry.Reason,
+			"expires", entry.Expires,
+		)
+		if entry.Action == moderation.ActionBan {
+			s.kickBanned(moderation.Sanction{Target: entry.Target, Reason: entry.Reason})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	}
+}
+
+// handleAdminAudit returns the moderation audit log, newest first:
+//
+//	GET /admin/audit[?target=T][&limit=N]  → entries (default limit 100, 0 = all)
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	var target string
+	if v := q.Get("target"); v != "" {
+		var ok bool
+		if target, ok = s.resolveTarget(v); !ok {
+			http.Error(w, "target must be account:<id>, ip:<addr> or player:<id> of a connected player", http.StatusBadRequest)
+			return
+		}
+	}
+	entries := s.moderation.Audit(target, limit)
+	if entries == nil {
+		entries = []moderation.Entry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/moderation"
+	"pixi_game_server/internal/protocol"
+)
+
+func TestAdminBanKicksAndAudits(t *testing.T) {
+	s := newSessionServer(t, "")
+	c, fake := joinAccount(s, "alice")
+	bans := s.handleAdminSanctions(moderation.ActionBan, moderation.ActionUnban)
+
+	rec := httptest.NewRecorder()
+	bans(rec, httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/bans?target=player:%d&reason=speedhack&by=ops", c.player.ID), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ban status = %d: %s", rec.Code, rec.Body)
+	}
+	if code, _ := closeFrame(t, c, fake); code != protocol.CloseBanned {
+		t.Errorf("banned player closed with %d, want %d", code, protocol.CloseBanned)
+	}
+	if _, banned := s.moderation.Banned(time.Now(), "account:alice"); !banned {
+		t.Error("player target did not resolve to the account ban")
+	}
+
+	rec = httptest.NewRecorder()
+	bans(rec, httptest.NewRequest(http.MethodDelete, "/admin/bans?target=account:alice&by=ops", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unban status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAdminAudit(rec, httptest.NewRequest(http.MethodGet, "/admin/audit?target=account:alice", nil))
+	var audit []moderation.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &audit); err != nil {
+		t.Fatal(err)
+	}
+	if len(audit) != 2 || audit[0].Action != moderation.ActionUnban || audit[1].Reason != "speedhack" || audit[1].By != "ops" {
+		t.Errorf("audit = %+v, want unban then ban by ops", audit)
+	}
+}
+
+func TestAdminBanValidates(t *testing.T) {
+	s := newSessionServer(t, "")
+	bans := s.handleAdminSanctions(moderation.ActionBan, moderation.ActionUnban)
+	for _, url := range []string{
+		"/admin/bans?target=account:alice",                      // no reason
+		"/admin/bans?target=alice&reason=x",                     // bad target
+		"/admin/bans?target=player:999&reason=x",                // not connected
+		"/admin/bans?target=account:alice&reason=x&duration=-1", // bad duration
+	} {
+		rec := httptest.NewRecorder()
+		bans(rec, httptest.NewRequest(http.MethodPost, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status %d, want 400", url, rec.Code)
+		}
+	}
+	if got := s.moderation.Audit("", 0); len(got) != 0 {
+		t.Errorf("rejected requests were audited: %+v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"sync/atomic"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// MOVEMENT_ACK coalescing.
+//
+// Movement itself already reaches other players only through the tick broadcast
+// (latest state per tick), but every MOVE still produced one ACK frame for the
+// sender. A client spamming MOVE therefore cost one WS frame + write-loop wakeup
+// per message. With coalescing, queueMoveAck only records the latest ACK in the
+// connection (one atomic word) and flushMoveAcks sends it once per tick from the
+// game loop's post-tick hook.
+
+// packMoveAck packs an ACK into one word so the latest value is swapped atomically:
+// x (16) | y (16) | inputSequence (32), the position in wire units.
+func packMoveAck(x, y uint16, inputSequence uint32) uint64 {
+	return uint64(x)<<48 | uint64(y)<<32 | uint64(inputSequence)
+}
+
+func unpackMoveAck(v uint64) (x, y uint16, inputSequence uint32) {
+	return uint16(v >> 48), uint16(v >> 32), uint32(v)
+}
+
+// queueMoveAck records the latest ACK for conn, the position already in its wire
+// units (BinaryProtocol.Position). When coalescing is disabled the ACK is sent
+// immediately, as before.
+func (s *Server) queueMoveAck(conn *Connection, fx, fy types.Fixed, inputSequence uint32) {
+	s.lockOrigin(conn)
+	defer s.unlockOrigin(conn)
+	x, y := s.protocolFor(conn).Position(fx, fy)
+	if !s.cfg.Net.CoalesceMoveAcks {
+		s.sendMoveAck(conn, x, y, inputSequence)
+		return
+	}
+
+	atomic.StoreUint64(&conn.pendingAck, packMoveAck(x, y, inputSequence))
+	if !atomic.CompareAndSwapInt32(&conn.ackQueued, 0, 1) {
+		// Already queued this tick — the newer value replaces the older one.
+		metrics.MoveAcksCoalesced.Inc()
+		return
+	}
+
+	s.ackMu.Lock()
+	s.pendingAcks = append(s.pendingAcks, conn)
+	s.ackMu.Unlock()
+}
+
+// flushMoveAcks sends the latest queued ACK of every connection. Runs once per tick
+// from the gameLoop goroutine (registered via SetPostTickHook).
+func (s *Server) flushMoveAcks() {
+	s.ackMu.Lock()
+	if len(s.pendingAcks) == 0 {
+		s.ackMu.Unlock()
+		return
+	}
+	// Swap buffers: producers append to the spare slice while we send.
+	conns := s.pendingAcks
+	s.pendingAcks = s.ackFlushBuf[:0]
+	s.ackMu.Unlock()
+
+	for i, conn := range conns {
+		// Clear the flag before reading the value: a MOVE arriving after this point
+		// re-queues the connection instead of being lost.
+		atomic.StoreInt32(&conn.ackQueued, 0)
+		x, y, seq := unpackMoveAck(atomic.LoadUint64(&conn.pendingAck))
+		s.sendMoveAck(conn, x, y, seq)
+		conns[i] = nil
+	}
+
+	s.ackMu.Lock()
+	s.ackFlushBuf = conns[:0]
+	s.ackMu.Unlock()
+}
+
+// sendMoveAck sends one MOVEMENT_ACK. A correction (handleInputTimeout) goes reliable to
+// clients that ack RELIABLE; any ACK supersedes an unacked correction still pending.
+func (s *Server) sendMoveAck(conn *Connection, x, y uint16, inputSequence uint32) {
+	data := s.protocolFor(conn).EncodeMovementAck(conn.player.ID, x, y, inputSequence)
+	key := dedupeKey(protocol.MessageMovementAck, conn.player.ID)
+	if atomic.SwapInt32(&conn.correction, 0) != 0 {
+		s.sendReliable(conn, data, key)
+		return
+	}
+	conn.supersedeReliable(key)
+	s.sendLatest(conn, data, writeClassMovement, key)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"pixi_game_server/internal/config"
+)
+
+// Origin check for /ws. Browsers always send Origin on a WebSocket handshake, so it
+// decides which pages may open game connections; non-browser clients (bots, loadtest)
+// send none and are not affected.
+//
+// ALLOWED_ORIGINS is a comma-separated list of origins ("https://game.example.com"),
+// "self" for the host the request was sent to, or "*" for any.
+
+// allowedOrigins is ALLOWED_ORIGINS parsed by parseOrigins ("*" parses to nil).
+type allowedOrigins struct {
+	self    bool
+	origins map[string]bool
+}
+
+func parseOrigins(s string) *allowedOrigins {
+	a := &allowedOrigins{origins: make(map[string]bool)}
+	for _, o := range strings.Split(s, ",") {
+		switch o = strings.TrimSpace(o); o {
+		case "":
+		case "*":
+			return nil
+		case "self":
+			a.self = true
+		default:
+			a.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+		}
+	}
+	return a
+}
+
+// originAllowed reports whether r may be upgraded.
+func (s *Server) originAllowed(r *http.Request) bool {
+	a := s.origins
+	origin := r.Header.Get("Origin")
+	if a == nil || origin == "" {
+		return true
+	}
+	if a.origins[strings.ToLower(origin)] {
+		return true
+	}
+	if a.self {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	return false
+}
+
+// handleDebugConfig serves the effective config: the profile and every setting with
+// its source (env, profile, default). Secrets are redacted by the config package.
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Profile  string           `json:"profile"`
+		Settings []config.Setting `json:"settings"`
+	}{s.cfg.Profile, s.cfg.Settings})
+}
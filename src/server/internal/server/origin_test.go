@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		allowed, origin, host string
+		want                  bool
+	}{
+		{"*", "https://evil.example", "game.example", true},
+		{"self", "", "game.example", true}, // non-browser client
+		{"self", "https://game.example", "game.example", true},
+		{"self", "https://evil.example", "game.example", false},
+		{"https://game.example, https://cdn.example/", "https://CDN.example", "lb.internal", true},
+		{"https://game.example", "http://game.example", "game.example", false},
+	} {
+		s := &Server{origins: parseOrigins(tc.allowed)}
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Host = tc.host
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := s.originAllowed(r); got != tc.want {
+			t.Errorf("ALLOWED_ORIGINS=%q Origin=%q Host=%q: allowed=%v, want %v",
+				tc.allowed, tc.origin, tc.host, got, tc.want)
+		}
+	}
+}
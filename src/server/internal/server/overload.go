@@ -0,0 +1,30 @@
+package server
+
+import (
+	"log/slog"
+
+	"pixi_game_server/internal/game"
+)
+
+// Game loop overload (game/catchup.go). While the loop drops ticks, periodic extras
+// that nothing depends on — MINIMAP and LEADERBOARD — are skipped, so connections and
+// the snapshot are left to the world states. Catching up alone sheds nothing here: the
+// loop already skips the snapshots of its catch-up steps.
+
+// onDegradation is the game world's degradation handler (gameLoop goroutine).
+func (s *Server) onDegradation(level game.Degradation) {
+	shed := level == game.DegradeDropTicks
+	if s.shedding.Swap(shed) == shed {
+		return
+	}
+	if shed {
+		slog.Warn("game loop is dropping ticks; minimap and leaderboard paused")
+	} else {
+		slog.Info("game loop caught up; minimap and leaderboard resumed")
+	}
+}
+
+// shedOptional reports whether optional periodic sends are skipped right now.
+func (s *Server) shedOptional() bool {
+	return s.shedding.Load()
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/party"
+	"pixi_game_server/internal/protocol"
+)
+
+// Parties (internal/party).
+//
+// PARTY invites, accepts and leaves; each change sends PARTY_ROSTER to every member it
+// touches, reliably where the client acks, and a refused action is answered with
+// COMMAND_RESULT. PARTY_CHAT lines go to the members only, as PARTY_CHAT_MESSAGE. A
+// player that disconnects leaves its party; one taken over by a new session keeps it and
+// the new connection gets the roster after JOIN.
+//
+// With Net.PartyAlwaysVisible the members of a party are in each other's world state
+// whatever the viewports (aoi.go), so they stay on screen — and on the minimap of the
+// client — across the map. Each connection keeps a snapshot of its party (c.party) that
+// the game loop reads without locking.
+//
+// Changes are serialized by partyMu, which is held while the rosters are enqueued, so
+// a member never gets an older roster after a newer one.
+
+var partyActionLabels = [...]string{
+	protocol.PartyInvite: "invite",
+	protocol.PartyAccept: "accept",
+	protocol.PartyLeave:  "leave",
+}
+
+// initParties creates the party registry; parties stay off with PartyMaxSize 0.
+func (s *Server) initParties() {
+	if s.cfg.Net.PartyMaxSize > 0 {
+		s.parties = party.NewRegistry(s.cfg.Net.PartyMaxSize, s.cfg.Net.PartyInviteTTL)
+	}
+}
+
+// handleParty runs a PARTY action of c.
+func (s *Server) handleParty(c *Connection, action uint8, target uint32) {
+	if int(action) >= len(partyActionLabels) {
+		s.refuseParty(c, "", "unknown party action")
+		return
+	}
+	name := partyActionLabels[action]
+	if s.parties == nil {
+		s.refuseParty(c, name, "parties are disabled on this server")
+		return
+	}
+
+	s.partyMu.Lock()
+	defer s.partyMu.Unlock()
+	self := c.player.ID
+	switch action {
+	case protocol.PartyInvite:
+		invitee := s.partyConnection(target)
+		if invitee == nil {
+			s.refuseParty(c, name, fmt.Sprintf("player %d is not online", target))
+			return
+		}
+		if err := s.parties.Invite(self, target); err != nil {
+			s.refuseParty(c, name, err.Error())
+			return
+		}
+		s.sendReliable(invitee, s.protocol.EncodePartyInvite(self), 0)
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandOK, fmt.Sprintf("invited %d to your party", target)))
+
+	case protocol.PartyAccept:
+		p, err := s.parties.Accept(self, target)
+		if err != nil {
+			s.refuseParty(c, name, err.Error())
+			return
+		}
+		s.publishParty(nil, p)
+
+	case protocol.PartyLeave:
+		left, now, ok := s.parties.Leave(self)
+		if !ok {
+			s.refuseParty(c, name, "you are not in a party")
+			return
+		}
+		s.publishParty(left, now)
+	}
+	metrics.PartyActions.WithLabelValues(name, "ok").Inc()
+}
+
+// refuseParty answers a PARTY action that was not done.
+func (s *Server) refuseParty(c *Connection, action, reason string) {
+	if action != "" {
+		metrics.PartyActions.WithLabelValues(action, "refused").Inc()
+	}
+	s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, reason))
+}
+
+// handlePartyChat relays a PARTY_CHAT line to the sender's party.
+func (s *Server) handlePartyChat(c *Connection, text string) {
+	text = strings.TrimSpace(strings.ToValidUTF8(text, ""))
+	if text == "" {
+		return
+	}
+	if s.isMuted(c) {
+		metrics.ChatMessages.WithLabelValues("muted").Inc()
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandMuted, "you are muted"))
+		return
+	}
+	p := c.party.Load()
+	if p == nil {
+		s.sendDirect(c, s.protocol.EncodeCommandResult(protocol.CommandError, "you are not in a party"))
+		return
+	}
+	metrics.ChatMessages.WithLabelValues("party").Inc()
+	data := s.protocol.EncodePartyChatMessage(c.player.ID, text)
+	for _, id := range p.Members {
+		if conn := s.partyConnection(id); conn != nil {
+			s.sendDirect(conn, data)
+		}
+	}
+}
+
+// leaveParty takes a disconnected player out of its party and drops its invites.
+func (s *Server) leaveParty(c *Connection) {
+	if s.parties == nil {
+		return
+	}
+	s.partyMu.Lock()
+	defer s.partyMu.Unlock()
+	if left, now, ok := s.parties.Remove(c.player.ID); ok {
+		s.publishParty(left, now)
+	}
+}
+
+// resumeParty gives a connection that took over a session the party of its player.
+func (s *Server) resumeParty(c *Connection) {
+	if s.parties == nil {
+		return
+	}
+	s.partyMu.Lock()
+	defer s.partyMu.Unlock()
+	if p := s.parties.Of(c.player.ID); p != nil {
+		s.setParty(c, p)
+	}
+}
+
+// publishParty tells the members of before that are no longer in a party that they
+// are not, and every member of after its new roster. Called with partyMu held.
+func (s *Server) publishParty(before, after *party.Party) {
+	if before != nil {
+		for _, id := range before.Members {
+			if !after.Has(id) {
+				if conn := s.partyConnection(id); conn != nil {
+					s.setParty(conn, nil)
+				}
+			}
+		}
+	}
+	if after != nil {
+		for _, id := range after.Members {
+			if conn := s.partyConnection(id); conn != nil {
+				s.setParty(conn, after)
+			}
+		}
+	}
+	metrics.Parties.Set(float64(s.parties.Len()))
+}
+
+// setParty stores c's party snapshot and sends it the roster (none = not in a party).
+func (s *Server) setParty(c *Connection, p *party.Party) {
+	c.party.Store(p)
+	var data []byte
+	if p == nil {
+		data = s.protocol.EncodePartyRoster(0, 0, nil)
+	} else {
+		data = s.protocol.EncodePartyRoster(p.ID, p.Leader, p.Members)
+	}
+	s.sendReliable(c, data, dedupeKey(protocol.MessagePartyRoster, 0))
+}
+
+// partyConnection returns the connection of a joined player, nil if offline.
+func (s *Server) partyConnection(playerID uint32) *Connection {
+	s.connectionsMu.RLock()
+	defer s.connectionsMu.RUnlock()
+	return s.connections[playerID]
+}
+
+// visibleParty returns the party whose members c sees whatever its viewport, nil if
+// none. gameLoop goroutine (aoi.go).
+func (s *Server) visibleParty(c *Connection) *party.Party {
+	if !s.cfg.Net.PartyAlwaysVisible {
+		return nil
+	}
+	return c.party.Load()
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// messagesOf returns the messages of type msgType written to fake, waiting up to a
+// second for at least n of them.
+func messagesOf(t *testing.T, fake *testutil.FakeConn, msgType uint8, n int) [][]byte {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		frames, err := fake.Frames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs [][]byte
+		for _, f := range frames {
+			if msg := f.Payload[seqHeaderSize:]; msg[0] == msgType {
+				msgs = append(msgs, msg)
+			}
+		}
+		if len(msgs) >= n || time.Now().After(deadline) {
+			return msgs
+		}
+	}
+}
+
+// stateIDs returns the player IDs of a GAME_STATE.
+func stateIDs(msg []byte) []uint32 {
+	entry := protocol.LookupSchema(protocol.MessageGameState).EntrySize()
+	head := protocol.LookupSchema(protocol.MessageGameState).Size(0)
+	var ids []uint32
+	for off := head; off < len(msg); off += entry {
+		ids = append(ids, binary.LittleEndian.Uint32(msg[off:]))
+	}
+	return ids
+}
+
+func TestParty(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Net.PartyMaxSize = 2
+	cfg.Net.PartyAlwaysVisible = true
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func() (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	alice, aliceFake := join()
+	bob, bobFake := join()
+	carol, carolFake := join()
+	a, b := alice.player.ID, bob.player.ID
+
+	s.handleParty(alice, protocol.PartyInvite, b)
+	if got := messagesOf(t, bobFake, protocol.MessagePartyInvite, 1); len(got) != 1 || string(got[0]) != string(s.protocol.EncodePartyInvite(a)) {
+		t.Fatalf("PARTY_INVITE to bob = %x", got)
+	}
+	s.handleParty(bob, protocol.PartyAccept, a)
+	roster := s.protocol.EncodePartyRoster(1, a, []uint32{a, b})
+	for _, fake := range []*testutil.FakeConn{aliceFake, bobFake} {
+		if got := messagesOf(t, fake, protocol.MessagePartyRoster, 1); len(got) != 1 || string(got[0]) != string(roster) {
+			t.Errorf("PARTY_ROSTER = %x, want %x", got, roster)
+		}
+	}
+
+	// The party is full; carol gets the refusal.
+	s.handleParty(carol, protocol.PartyInvite, a)
+	s.handleParty(alice, protocol.PartyInvite, carol.player.ID)
+	if got := messagesOf(t, carolFake, protocol.MessageCommandResult, 1); len(got) != 1 || got[0][1] != protocol.CommandError {
+		t.Errorf("carol's invite of a party member answered %x", got)
+	}
+	if got := messagesOf(t, carolFake, protocol.MessagePartyInvite, 0); len(got) != 0 {
+		t.Error("carol invited to a full party")
+	}
+
+	s.handlePartyChat(bob, "regroup")
+	want := s.protocol.EncodePartyChatMessage(b, "regroup")
+	for _, fake := range []*testutil.FakeConn{aliceFake, bobFake} {
+		if got := messagesOf(t, fake, protocol.MessagePartyChatMessage, 1); len(got) != 1 || string(got[0]) != string(want) {
+			t.Errorf("PARTY_CHAT_MESSAGE = %x, want %x", got, want)
+		}
+	}
+	if got := messagesOf(t, carolFake, protocol.MessagePartyChatMessage, 0); len(got) != 0 {
+		t.Error("party chat reached a player outside the party")
+	}
+
+	// Bob and carol are far outside alice's viewport; only bob is in her state.
+	alice.player.SetViewSize(500, 500)
+	alice.player.SetViewport(types.ViewportBounds{MinX: 0, MinY: 0, MaxX: 500, MaxY: 500})
+	players := []types.PlayerState{{ID: a, X: 100, Y: 100}, {ID: b, X: 5000, Y: 2500}, {ID: carol.player.ID, X: 5000, Y: 2600}}
+	s.splitViewportRecipients([]*Connection{alice}, false)
+	s.enqueueViewportFrames(players, nil, true, 1, time.Now().UnixNano())
+	states := messagesOf(t, aliceFake, protocol.MessageGameState, 2) // the initial state, then this one
+	if ids := stateIDs(states[len(states)-1]); !slices.Equal(ids, []uint32{a, b}) {
+		t.Errorf("alice's state has players %v, want her and bob", ids)
+	}
+
+	// Bob disconnects: the party of one breaks up.
+	s.cleanupConnection(bob)
+	got := messagesOf(t, aliceFake, protocol.MessagePartyRoster, 2)
+	if empty := s.protocol.EncodePartyRoster(0, 0, nil); len(got) != 2 || string(got[1]) != string(empty) {
+		t.Errorf("alice's rosters after bob left = %x", got)
+	}
+	if alice.party.Load() != nil || s.parties.Len() != 0 {
+		t.Error("party kept after bob left")
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"pixi_game_server/internal/worldmap"
+)
+
+// PathResult — the answer of /debug/path, points as [x, y].
+type PathResult struct {
+	From         [2]uint32   `json:"from"`
+	To           [2]uint32   `json:"to"`
+	Path         [][2]uint32 `json:"path"` // waypoints after from; the last one is to
+	Hierarchical bool        `json:"hierarchical"`
+	Micros       int64       `json:"micros"` // search time
+}
+
+// handleDebugPath finds a path on the collision grid, the one bots walk, for drawing
+// it over the map:
+//
+//	GET /debug/path?from=X,Y&to=X,Y → PathResult; 422 when a point is blocked or the
+//	    goal cannot be reached
+func (s *Server) handleDebugPath(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, err := parsePoint(q.Get("from"))
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parsePoint(q.Get("to"))
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	start := time.Now()
+	path, err := s.gameWorld.FindPath(from, to)
+	took := time.Since(start)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	res := PathResult{
+		From:         [2]uint32{from.X, from.Y},
+		To:           [2]uint32{to.X, to.Y},
+		Path:         make([][2]uint32, len(path)),
+		Hierarchical: s.gameWorld.PathsHierarchical(),
+		Micros:       took.Microseconds(),
+	}
+	for i, p := range path {
+		res.Path[i] = [2]uint32{p.X, p.Y}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// parsePoint parses "X,Y" in world units.
+func parsePoint(s string) (worldmap.Point, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	x, errX := strconv.ParseUint(strings.TrimSpace(xs), 10, 32)
+	y, errY := strconv.ParseUint(strings.TrimSpace(ys), 10, 32)
+	if !ok || errX != nil || errY != nil {
+		return worldmap.Point{}, fmt.Errorf("want X,Y, got %q", s)
+	}
+	return worldmap.Point{X: uint32(x), Y: uint32(y)}, nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/worldmap"
+)
+
+func TestHandleDebugPath(t *testing.T) {
+	// 10×10 tiles of 64 with a wall down column 5, open at the bottom row.
+	m := &worldmap.Map{Width: 640, Height: 640, TileWidth: 64, TileHeight: 64, Cols: 10, Rows: 10}
+	for row := range 9 {
+		m.SetBlocked(5, row, true)
+	}
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, m)
+	s.gameWorld.SetPaused(true)
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.handleDebugPath(rec, httptest.NewRequest(http.MethodGet, "/debug/path?"+query, nil))
+		return rec
+	}
+
+	rec := get("from=100,100&to=500,100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var res PathResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(res.Path); n < 2 || res.Path[n-1] != [2]uint32{500, 100} {
+		t.Errorf("path = %v, want waypoints ending at the goal", res.Path)
+	}
+	for _, p := range res.Path[:len(res.Path)-1] {
+		if p[0]/64 == 5 && p[1]/64 < 9 {
+			t.Errorf("waypoint %v is in the wall", p)
+		}
+	}
+
+	if rec := get("from=100,100&to=330,100"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("goal in the wall: status = %d, want 422", rec.Code)
+	}
+	if rec := get("from=100&to=500,100"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad from: status = %d, want 400", rec.Code)
+	}
+}
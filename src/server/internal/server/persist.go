@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"pixi_game_server/internal/persist"
+)
+
+// Player records (internal/persist).
+//
+// The position and HP of every signed-in player are queued every
+// Server.PersistCheckpoint and once more when it leaves; the write-behind queue
+// writes them to the record log in batches. Neither the game loop nor a connection
+// goroutine ever waits for the disk: taking a record reads the player's atomics, and
+// Enqueue only stores it. Shutdown and handover queue everyone still online and
+// flush before the process exits.
+
+// initPersistence opens the record log and starts the queue and the checkpoint loop.
+func (s *Server) initPersistence() {
+	store, err := persist.Open(s.cfg.Server.PlayerRecordsLog)
+	if err != nil {
+		// Records in the log stay there; new ones are kept in memory until a restart.
+		slog.Error("player record log unreadable, not saving players", "path", s.cfg.Server.PlayerRecordsLog, "error", err)
+		store, _ = persist.Open("")
+	}
+	s.records = store
+	s.recordQueue = persist.NewQueue(store, persist.QueueConfig{
+		Interval:  s.cfg.Server.PersistFlush,
+		BatchSize: s.cfg.Server.PersistBatchSize,
+		Retries:   s.cfg.Server.PersistRetries,
+		Timeout:   s.cfg.Server.PersistTimeout,
+	})
+	go s.recordQueue.Run(s.ctx)
+	go s.runCheckpointLoop()
+}
+
+func (s *Server) runCheckpointLoop() {
+	t := time.NewTicker(s.cfg.Server.PersistCheckpoint)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.checkpointPlayers()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// checkpointPlayers queues a record of every signed-in player online.
+func (s *Server) checkpointPlayers() {
+	s.connectionsMu.RLock()
+	conns := make([]*Connection, 0, len(s.sessions))
+	for _, c := range s.sessions {
+		conns = append(conns, c)
+	}
+	s.connectionsMu.RUnlock()
+	for _, c := range conns {
+		s.savePlayer(c)
+	}
+}
+
+// savePlayer queues a record of c's player; anonymous players are not saved.
+func (s *Server) savePlayer(c *Connection) {
+	if c.account == "" || c.player == nil {
+		return
+	}
+	st, ok := s.gameWorld.AdminPlayerState(c.player.ID)
+	if !ok {
+		return
+	}
+	s.recordQueue.Enqueue(persist.Record{
+		Account: c.account,
+		X:       st.X,
+		Y:       st.Y,
+		HP:      st.HP,
+		SavedAt: time.Now().UnixMilli(),
+	})
+}
+
+// flushRecords writes everything queued; ctx is usually cancelled by now, so it uses
+// its own.
+func (s *Server) flushRecords() {
+	s.recordQueue.Flush(context.Background())
+	if n := s.recordQueue.Pending(); n > 0 {
+		slog.Error("player records not written before exit", "records", n)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+
+	"pixi_game_server/internal/config"
+)
+
+func TestPlayerRecordsSaved(t *testing.T) {
+	s := newSessionServer(t, config.SessionTakeover)
+	alice, _ := joinAccount(s, "alice")
+	anon, _ := joinAccount(s, "")
+	st, _ := s.gameWorld.AdminPlayerState(alice.player.ID)
+
+	s.checkpointPlayers()
+	if n := s.recordQueue.Pending(); n != 1 {
+		t.Fatalf("%d records queued at the checkpoint, want alice only", n)
+	}
+	s.cleanupConnection(anon)
+	s.cleanupConnection(alice)
+	s.flushRecords()
+
+	r, ok := s.records.Load("alice")
+	if !ok {
+		t.Fatal("alice not saved")
+	}
+	if r.X != st.X || r.Y != st.Y || r.HP != st.HP {
+		t.Errorf("saved %+v, want the player at (%d,%d) with %d HP", r, st.X, st.Y, st.HP)
+	}
+	if s.recordQueue.Pending() != 0 {
+		t.Errorf("%d records left after the flush", s.recordQueue.Pending())
+	}
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/types"
+)
+
+// Admin operations on live players (game/adminops.go): teleport, freeze, speed
+// multiplier, invulnerability. The admin API and the console commands build the same
+// GameEvents; bots can be targeted too.
+
+// PlayerOpsResult — the player's position and modifiers after an operation. A
+// teleport shows its destination: it is applied at the start of the next tick.
+type PlayerOpsResult struct {
+	Player uint32 `json:"player"`
+	game.AdminPlayerState
+}
+
+// playerOp applies one admin operation and logs who did it.
+func (s *Server) playerOp(event types.GameEvent, by string) error {
+	if err := s.gameWorld.ApplyAdminEvent(event); err != nil {
+		return err
+	}
+	slog.Info("player operation",
+		"player_id", event.PlayerID,
+		"op", playerOpNames[event.Type],
+		"value", event.Value,
+		"x", event.X, "y", event.Y,
+		"by", by,
+	)
+	return nil
+}
+
+var playerOpNames = map[types.EventType]string{
+	types.EventTeleport:     "teleport",
+	types.EventFreeze:       "freeze",
+	types.EventSpeed:        "speed",
+	types.EventInvulnerable: "invulnerable",
+}
+
+// handleAdminPlayers shows and changes a player's admin modifiers:
+//
+//	GET  /admin/players?player=ID → position and modifiers
+//	POST /admin/players?player=ID[&x=X&y=Y][&frozen=0|1][&speed=P][&invulnerable=0|1][&by=NAME]
+//	     → teleport (x and y together), freeze, speed multiplier in percent (100 =
+//	       normal), invulnerability; only the given operations are applied
+func (s *Server) handleAdminPlayers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	id64, err := strconv.ParseUint(q.Get("player"), 10, 32)
+	if err != nil {
+		http.Error(w, "player must be a player ID", http.StatusBadRequest)
+		return
+	}
+	id := uint32(id64)
+
+	var teleport *types.GameEvent
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		events, err := parsePlayerOps(id, q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(events) == 0 {
+			http.Error(w, "no operation given: x and y, frozen, speed or invulnerable", http.StatusBadRequest)
+			return
+		}
+		by := q.Get("by")
+		if by == "" {
+			by = "admin"
+		}
+		for i := range events {
+			if err := s.playerOp(events[i], by); err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, game.ErrNoPlayer) {
+					status = http.StatusNotFound
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			if events[i].Type == types.EventTeleport {
+				teleport = &events[i]
+			}
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st, ok := s.gameWorld.AdminPlayerState(id)
+	res := PlayerOpsResult{Player: id, AdminPlayerState: st}
+	if !ok {
+		http.Error(w, game.ErrNoPlayer.Error(), http.StatusNotFound)
+		return
+	}
+	if teleport != nil {
+		res.X, res.Y = teleport.X, teleport.Y
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// parsePlayerOps builds the events of a POST /admin/players query.
+func parsePlayerOps(id uint32, q map[string][]string) ([]types.GameEvent, error) {
+	get := func(name string) string {
+		if v := q[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	var events []types.GameEvent
+	if x, y := get("x"), get("y"); x != "" || y != "" {
+		nx, errX := strconv.ParseUint(x, 10, 32)
+		ny, errY := strconv.ParseUint(y, 10, 32)
+		if errX != nil || errY != nil {
+			return nil, errors.New("x and y must both be non-negative 32-bit integers")
+		}
+		events = append(events, types.GameEvent{PlayerID: id, Type: types.EventTeleport, X: uint32(nx), Y: uint32(ny)})
+	}
+	for _, flag := range []struct {
+		name string
+		typ  types.EventType
+	}{{"frozen", types.EventFreeze}, {"invulnerable", types.EventInvulnerable}} {
+		switch get(flag.name) {
+		case "":
+		case "0":
+			events = append(events, types.GameEvent{PlayerID: id, Type: flag.typ, Value: 0})
+		case "1":
+			events = append(events, types.GameEvent{PlayerID: id, Type: flag.typ, Value: 1})
+		default:
+			return nil, errors.New(flag.name + " must be 0 or 1")
+		}
+	}
+	if v := get("speed"); v != "" {
+		pct, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, errors.New("speed must be an integer (percent)")
+		}
+		events = append(events, types.GameEvent{PlayerID: id, Type: types.EventSpeed, Value: int32(pct)})
+	}
+	return events, nil
+}
+
+// registerPlayerOpCommands adds the console commands of the player operations.
+func (s *Server) registerPlayerOpCommands() {
+	s.console.MustRegister(console.Command{
+		Name:  "tp",
+		Usage: "<player|me> <x> <y>",
+		Help:  "teleports a player",
+		Role:  console.RoleAdmin,
+		Run: func(caller console.Caller, args []string) console.Result {
+			if len(args) != 3 {
+				return console.Errorf("usage: /tp <player|me> <x> <y>")
+			}
+			x, errX := strconv.ParseUint(args[1], 10, 32)
+			y, errY := strconv.ParseUint(args[2], 10, 32)
+			if errX != nil || errY != nil {
+				return console.Errorf("x and y must be non-negative 32-bit integers")
+			}
+			return s.playerOpCommand(caller, args[0], types.GameEvent{Type: types.EventTeleport, X: uint32(x), Y: uint32(y)},
+				"teleporting %d to %d,%d", x, y)
+		},
+	})
+	for _, c := range []struct {
+		name, help string
+		event      types.GameEvent
+		done       string
+	}{
+		{"freeze", "stops a player until /unfreeze", types.GameEvent{Type: types.EventFreeze, Value: 1}, "froze %d"},
+		{"unfreeze", "lets a frozen player move again", types.GameEvent{Type: types.EventFreeze, Value: 0}, "unfroze %d"},
+	} {
+		s.console.MustRegister(console.Command{
+			Name:  c.name,
+			Usage: "<player|me>",
+			Help:  c.help,
+			Role:  console.RoleAdmin,
+			Run: func(caller console.Caller, args []string) console.Result {
+				if len(args) != 1 {
+					return console.Errorf("usage: /%s <player|me>", c.name)
+				}
+				return s.playerOpCommand(caller, args[0], c.event, c.done)
+			},
+		})
+	}
+	s.console.MustRegister(console.Command{
+		Name:  "speed",
+		Usage: "<player|me> <percent>",
+		Help:  "sets a player's speed multiplier (100 = normal)",
+		Role:  console.RoleAdmin,
+		Run: func(caller console.Caller, args []string) console.Result {
+			if len(args) != 2 {
+				return console.Errorf("usage: /speed <player|me> <percent>")
+			}
+			pct, err := strconv.ParseInt(args[1], 10, 32)
+			if err != nil {
+				return console.Errorf("percent must be an integer")
+			}
+			return s.playerOpCommand(caller, args[0], types.GameEvent{Type: types.EventSpeed, Value: int32(pct)},
+				"speed of %d set to %d%%", pct)
+		},
+	})
+	s.console.MustRegister(console.Command{
+		Name:  "god",
+		Usage: "<player|me> [on|off]",
+		Help:  "toggles a player's invulnerability",
+		Role:  console.RoleAdmin,
+		Run: func(caller console.Caller, args []string) console.Result {
+			if len(args) == 0 || len(args) > 2 {
+				return console.Errorf("usage: /god <player|me> [on|off]")
+			}
+			event := types.GameEvent{Type: types.EventInvulnerable, Value: 1}
+			if len(args) == 2 {
+				switch args[1] {
+				case "on":
+				case "off":
+					event.Value = 0
+				default:
+					return console.Errorf("usage: /god <player|me> [on|off]")
+				}
+			}
+			done := "%d is invulnerable"
+			if event.Value == 0 {
+				done = "%d is vulnerable again"
+			}
+			return s.playerOpCommand(caller, args[0], event, done)
+		},
+	})
+}
+
+// playerOpCommand applies event to the player named by arg ("me" = the caller) and
+// formats done with the player ID followed by extra.
+func (s *Server) playerOpCommand(caller console.Caller, arg string, event types.GameEvent, done string, extra ...any) console.Result {
+	id, res, ok := s.commandPlayer(caller, arg)
+	if !ok {
+		return res
+	}
+	event.PlayerID = id
+	by := caller.Account
+	if by == "" {
+		by = "player:" + strconv.FormatUint(uint64(caller.PlayerID), 10)
+	}
+	if err := s.playerOp(event, by); err != nil {
+		return console.Errorf("%v", err)
+	}
+	return console.OK(done, append([]any{event.PlayerID}, extra...)...)
+}
+
+// commandPlayer resolves the <player|me> argument of a console command.
+func (s *Server) commandPlayer(caller console.Caller, arg string) (uint32, console.Result, bool) {
+	if arg == "me" {
+		return caller.PlayerID, console.Result{}, true
+	}
+	id, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return 0, console.Errorf("%q is not a player ID", arg), false
+	}
+	return uint32(id), console.Result{}, true
+}
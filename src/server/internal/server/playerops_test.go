@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestAdminPlayerOps(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	c := s.createConnection(testutil.NewFakeConn())
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	id := strconv.FormatUint(uint64(c.player.ID), 10)
+
+	do := func(method, query string) (int, PlayerOpsResult) {
+		rec := httptest.NewRecorder()
+		s.handleAdminPlayers(rec, httptest.NewRequest(method, "/admin/players?"+query, nil))
+		var res PlayerOpsResult
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, res
+	}
+
+	code, res := do(http.MethodPost, "player="+id+"&x=120&y=80&frozen=1&speed=50&invulnerable=1")
+	if code != http.StatusOK || res.X != 120 || res.Y != 80 || !res.Frozen || res.SpeedPercent != 50 || !res.Invulnerable {
+		t.Fatalf("POST = %d %+v", code, res)
+	}
+	s.gameWorld.Step()
+	if x, y := c.player.GetX(), c.player.GetY(); x != 120 || y != 80 {
+		t.Errorf("position after the tick = %d,%d, want 120,80", x, y)
+	}
+	if code, res = do(http.MethodGet, "player="+id); code != http.StatusOK || res.X != 120 || !res.Frozen {
+		t.Errorf("GET = %d %+v", code, res)
+	}
+	for query, want := range map[string]int{
+		"player=" + id:                 http.StatusBadRequest, // no operation
+		"player=" + id + "&x=5":        http.StatusBadRequest,
+		"player=" + id + "&speed=0":    http.StatusBadRequest,
+		"player=" + id + "&frozen=yes": http.StatusBadRequest,
+		"player=1&frozen=1":            http.StatusNotFound,
+		"player=nobody&invulnerable=1": http.StatusBadRequest,
+	} {
+		if code, _ := do(http.MethodPost, query); code != want {
+			t.Errorf("POST %s = %d, want %d", query, code, want)
+		}
+	}
+
+	admin := console.Caller{PlayerID: c.player.ID, Role: console.RoleAdmin}
+	for line, want := range map[string]console.Status{
+		"/unfreeze me":        console.StatusOK,
+		"/speed me 100":       console.StatusOK,
+		"/god " + id + " off": console.StatusOK,
+		"/tp me 10":           console.StatusError,
+		"/speed me 5000":      console.StatusError,
+	} {
+		if _, res := s.console.Dispatch(admin, line); res.Status != want {
+			t.Errorf("%s = %+v, want status %d", line, res, want)
+		}
+	}
+	if _, res := s.console.Dispatch(console.Caller{PlayerID: c.player.ID}, "/tp me 1 1"); res.Status != console.StatusDenied {
+		t.Errorf("/tp by a player = %+v, want denied", res)
+	}
+	if st, _ := s.gameWorld.AdminPlayerState(c.player.ID); st.Frozen || st.SpeedPercent != 100 || st.Invulnerable {
+		t.Errorf("after the console commands = %+v, want everything reset", st)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package server
+
+// raceEnabled — the tests run under the race detector, which makes them several
+// times slower; crowd tests shrink.
+const raceEnabled = true
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"pixi_game_server/internal/lru"
+	"pixi_game_server/internal/metrics"
+)
+
+// Connection-attempt budgets. Players behind carrier-grade NAT share one public IP,
+// so a per-IP budget sized for one household throttles a whole ISP egress, while one
+// sized for the ISP lets a single abuser reconnect-loop at will. Authenticated
+// attempts are therefore charged twice, each against its own budget:
+//
+//   - per account (Net.AccountConnRate/Burst): one player's reconnect loop is cut off
+//     without touching anyone else behind the same IP;
+//   - per IP, authenticated (Net.IPAuthConnRate/Burst): a generous ceiling sized for a
+//     NAT pool, against floods of freshly minted tokens.
+//
+// Anonymous attempts, and attempts with a token that fails verification, spend the
+// plain per-IP budget (Net.IPConnRate/Burst) as before. A rate of 0 disables a budget.
+
+// A table keeps at most Net.ConnLimiterKeys buckets: a bucket left alone long enough
+// to refill is forgotten (it would start full anyway), and under a flood of distinct
+// keys the least recently used ones go first, so memory no longer grows with the
+// number of addresses seen between purges.
+
+// limiterShards splits each table so concurrent handshakes from different IPs rarely
+// wait on one mutex.
+const limiterShards = 16
+
+// minLimiterIdle bounds how soon an idle bucket is forgotten.
+const minLimiterIdle = time.Minute
+
+// limiterTable — token buckets by key (IP or account), created on first use.
+// The zero value allows everything.
+type limiterTable struct {
+	buckets *lru.Cache[string, *rate.Limiter]
+	limit   rate.Limit
+	burst   int
+}
+
+// init sets the per-key budget and forgets every bucket; perSec <= 0 disables the
+// table.
+func (t *limiterTable) init(perSec float64, burst, keys int) {
+	if perSec <= 0 {
+		t.buckets, t.limit, t.burst = nil, rate.Inf, 0
+		return
+	}
+	t.limit, t.burst = rate.Limit(perSec), burst
+	// An idle bucket is full again after burst/perSec; forgetting it then loses nothing.
+	idle := max(time.Duration(float64(burst)/perSec*float64(time.Second)), minLimiterIdle)
+	t.buckets = lru.New[string, *rate.Limiter](keys, limiterShards, idle)
+}
+
+// allow spends one token of key's bucket.
+func (t *limiterTable) allow(key string) bool {
+	if t.buckets == nil {
+		return true
+	}
+	return t.buckets.GetOrAdd(key, func() *rate.Limiter { return rate.NewLimiter(t.limit, t.burst) }).Allow()
+}
+
+// initConnLimiters sets the connection-attempt budgets from config.
+func (s *Server) initConnLimiters() {
+	keys := s.cfg.Net.ConnLimiterKeys
+	s.ipLimiters.init(s.cfg.Net.IPConnRate, s.cfg.Net.IPConnBurst, keys)
+	s.authIPLimiters.init(s.cfg.Net.IPAuthConnRate, s.cfg.Net.IPAuthConnBurst, keys)
+	s.accountLimiters.init(s.cfg.Net.AccountConnRate, s.cfg.Net.AccountConnBurst, keys)
+}
+
+// allowAnonymousAttempt charges an anonymous (or failed-auth) attempt to the IP budget.
+func (s *Server) allowAnonymousAttempt(ip string) bool {
+	if !s.ipLimiters.allow(ip) {
+		metrics.IPRateLimited.Inc()
+		return false
+	}
+	return true
+}
+
+// allowAttempt charges a connection attempt of account ("" = anonymous) from ip.
+func (s *Server) allowAttempt(ip, account string) bool {
+	if account == "" {
+		return s.allowAnonymousAttempt(ip)
+	}
+	if !s.accountLimiters.allow(account) {
+		metrics.AccountRateLimited.Inc()
+		return false
+	}
+	if !s.authIPLimiters.allow(ip) {
+		metrics.IPRateLimited.Inc()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+
+	"pixi_game_server/internal/testutil"
+)
+
+func TestAllowAttemptBudgets(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.IPConnRate, cfg.Net.IPConnBurst = 0.001, 2
+	cfg.Net.IPAuthConnRate, cfg.Net.IPAuthConnBurst = 0.001, 4
+	cfg.Net.AccountConnRate, cfg.Net.AccountConnBurst = 0.001, 2
+	s := &Server{cfg: cfg}
+	s.initConnLimiters()
+
+	const natIP = "100.64.0.1"
+	// An abusive account behind the NAT runs out of its own budget...
+	for i, want := range []bool{true, true, false} {
+		if got := s.allowAttempt(natIP, "abuser"); got != want {
+			t.Fatalf("abuser attempt %d = %v, want %v", i, got, want)
+		}
+	}
+	// ...while other accounts on the same IP still get in, up to the IP ceiling.
+	for i, account := range []string{"alice", "bob", "carol"} {
+		want := i < 2 // 2 abuser + alice + bob fill the IP ceiling; the rejected attempt never reached it
+		if got := s.allowAttempt(natIP, account); got != want {
+			t.Errorf("%s = %v, want %v", account, got, want)
+		}
+	}
+
+	// Anonymous attempts have their own per-IP budget.
+	for i, want := range []bool{true, true, false} {
+		if got := s.allowAttempt(natIP, ""); got != want {
+			t.Fatalf("anonymous attempt %d = %v, want %v", i, got, want)
+		}
+	}
+
+	cfg.Net.AccountConnRate = 0
+	s.initConnLimiters()
+	if !s.allowAttempt("10.0.0.1", "abuser") {
+		t.Error("account budget 0 must disable the account limiter")
+	}
+}
@@ -15,4 +15,8 @@ type readHandler interface {
 
 	// remove stops watching a connection (called before rawConn.Close).
 	remove(c *Connection)
+
+	// backlog is the number of readable connections waiting for a read worker
+	// (always 0 for the goroutine-per-connection fallback).
+	backlog() int
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Read limits. gobwas/ws has no SetReadLimit, so the read handlers check every frame
+// header themselves before allocating the payload:
+//
+//   - frame_too_large: a data frame over WS_READ_LIMIT_BYTES or a control frame over
+//     125 bytes (RFC 6455 §5.5). The payload is never read, so the stream cannot be
+//     resynchronised: the connection is closed.
+//   - message_too_large: a message longer than its type allows
+//     (protocol.MessageSchema.MaxSize). The frame boundary is intact, so only the
+//     message is dropped and counted as invalid traffic for the abuse strikes.
+//   - slow_read: a frame not complete READ_FRAME_TIMEOUT_MS after its first byte. A
+//     client trickling bytes would otherwise park a read worker (epoll) or its read
+//     goroutine for as long as it likes; it is closed instead.
+
+const (
+	defaultReadLimit = 4096
+
+	violationFrameTooLarge   = "frame_too_large"
+	violationMessageTooLarge = "message_too_large"
+	violationSlowRead        = "slow_read"
+)
+
+// minReadLimit — the largest message a client may legitimately send, checksum
+// included; a configured limit below it is raised to it.
+var minReadLimit = func() int {
+	size := 0
+	for i := range protocol.Messages {
+		if m := &protocol.Messages[i]; m.Direction == protocol.ClientToServer {
+			size = max(size, m.MaxSize())
+		}
+	}
+	return size + protocol.ChecksumSize
+}()
+
+func readLimitFor(configured int) int64 {
+	if configured <= 0 {
+		configured = defaultReadLimit
+	}
+	return int64(max(configured, minReadLimit))
+}
+
+// frameTooLarge reports whether the frame behind hdr must not be read.
+func (s *Server) frameTooLarge(hdr ws.Header) bool {
+	if hdr.OpCode.IsControl() {
+		return hdr.Length > ws.MaxControlFramePayloadSize
+	}
+	return hdr.Length > s.readLimit
+}
+
+// readViolation closes c for breaking a read limit. The caller stops reading from it.
+func (s *Server) readViolation(c *Connection, reason string, size int64) {
+	metrics.ReadViolations.WithLabelValues(reason).Inc()
+	slog.Warn("read limit violated, closing connection",
+		"player_id", c.playerID(), "ip", c.ip, "reason", reason, "frame_bytes", size)
+	s.closeConnection(c, protocol.CloseProtocolViolation, reason)
+}
+
+// messageTooLarge reports (and counts) a message longer than its type allows. Unknown
+// types pass; the decoder rejects them.
+func (s *Server) messageTooLarge(c *Connection, message []byte) bool {
+	if len(message) == 0 {
+		return false
+	}
+	schema := protocol.LookupSchema(message[0])
+	if schema == nil || schema.Direction != protocol.ClientToServer {
+		return false
+	}
+	limit := schema.MaxSize()
+	if c.checksum {
+		limit += protocol.ChecksumSize
+	}
+	if len(message) <= limit {
+		return false
+	}
+	metrics.ReadViolations.WithLabelValues(violationMessageTooLarge).Inc()
+	slog.Warn("message too large, dropped",
+		"player_id", c.playerID(), "type", schema.ConstName(), "bytes", len(message), "limit", limit)
+	s.recordTraffic(c, trafficInvalid)
+	return true
+}
+
+// isTimeout reports whether err is a read deadline expiring.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestReadLimitFor(t *testing.T) {
+	if got := readLimitFor(0); got != defaultReadLimit {
+		t.Errorf("readLimitFor(0) = %d, want %d", got, defaultReadLimit)
+	}
+	if got := readLimitFor(16); got != int64(minReadLimit) {
+		t.Errorf("readLimitFor(16) = %d, want the protocol minimum %d", got, minReadLimit)
+	}
+	if got := readLimitFor(1 << 20); got != 1<<20 {
+		t.Errorf("readLimitFor(1MiB) = %d", got)
+	}
+}
+
+func TestMessageTooLarge(t *testing.T) {
+	s := &Server{}
+	c := &Connection{}
+	chat := make([]byte, 1+4+protocol.MaxChatText)
+	chat[0] = protocol.MessageChat
+	for _, tc := range []struct {
+		name     string
+		msg      []byte
+		checksum bool
+		want     bool
+	}{
+		{"chat at the limit", chat, false, false},
+		{"chat over the limit", append(chat, 'x'), false, true},
+		{"chat with checksum", append(chat, 0, 0, 0, 0), true, false},
+		{"legacy attack", legacyAttack(), false, false},
+		{"attack with junk", append(legacyAttack(), make([]byte, 64)...), false, true},
+		{"unknown type", []byte{0xEE, 1, 2, 3}, false, false},
+	} {
+		c.checksum = tc.checksum
+		if got := s.messageTooLarge(c, tc.msg); got != tc.want {
+			t.Errorf("%s (%d bytes): too large = %v, want %v", tc.name, len(tc.msg), got, tc.want)
+		}
+	}
+}
+
+// legacyAttack — ATTACK as older clients send it, with the x, y float32 trailer.
+func legacyAttack() []byte {
+	b := make([]byte, 9)
+	b[0] = protocol.MessageAttack
+	return b
+}
+
+// tcpPair returns both ends of a loopback TCP connection (the read handler needs a
+// real socket).
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server, err = ln.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+// readClose waits for the server's close frame on client.
+func readClose(t *testing.T, client net.Conn) (uint16, string) {
+	t.Helper()
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		f, err := ws.ReadFrame(client)
+		if err != nil {
+			t.Fatalf("no close frame: %v", err)
+		}
+		if f.Header.OpCode == ws.OpClose {
+			code, reason := ws.ParseCloseFrameData(f.Payload)
+			return uint16(code), reason
+		}
+	}
+}
+
+func TestReadViolations(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Net.ReadFrameTimeout = 50 * time.Millisecond
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	defer func() { s.cancel(); s.gameWorld.Stop() }()
+
+	// A masked binary frame header announcing 1 MiB: the payload is never read.
+	raw, client := tcpPair(t)
+	s.rh.register(s, s.createConnection(raw))
+	hdr := []byte{0x82, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+	binary.BigEndian.PutUint64(hdr[2:10], 1<<20)
+	client.Write(hdr)
+	if code, reason := readClose(t, client); code != protocol.CloseProtocolViolation || reason != violationFrameTooLarge {
+		t.Errorf("oversized frame: close (%d, %q), want (%d, %q)", code, reason, protocol.CloseProtocolViolation, violationFrameTooLarge)
+	}
+
+	// Half a header, then nothing: the frame never completes.
+	raw, client = tcpPair(t)
+	s.rh.register(s, s.createConnection(raw))
+	client.Write([]byte{0x82})
+	if code, reason := readClose(t, client); code != protocol.CloseProtocolViolation || reason != violationSlowRead {
+		t.Errorf("trickled frame: close (%d, %q), want (%d, %q)", code, reason, protocol.CloseProtocolViolation, violationSlowRead)
+	}
+}
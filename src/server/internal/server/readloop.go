@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// goroutineReadHandler is the non-Linux readHandler fallback.
+// It spawns one goroutine per connection (identical to the original design).
+// Goroutine count: one per connected client. On Linux it serves in-process
+// connections, which have no descriptor for epoll (see inprocess.go).
+type goroutineReadHandler struct{}
+
+func newGoroutineReadHandler() *goroutineReadHandler {
+	slog.Info("goroutine-per-connection read handler started (non-Linux fallback)")
+	return &goroutineReadHandler{}
+}
+
+func (g *goroutineReadHandler) register(svr *Server, c *Connection) {
+	go g.readLoop(svr, c)
+}
+
+func (g *goroutineReadHandler) remove(_ *Connection) {}
+
+func (g *goroutineReadHandler) backlog() int { return 0 }
+
+func (g *goroutineReadHandler) readLoop(svr *Server, c *Connection) {
+	defer svr.cleanupConnection(c)
+
+	// The loop blocks in Read; cancellation (server shutdown, cleanupConnection)
+	// interrupts it by expiring the read deadline.
+	stop := context.AfterFunc(c.ctx, func() { c.rawConn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	rb := svr.getReadBuf()
+	defer svr.putReadBuf(rb)
+	for {
+		// Idle deadline: pings keep a healthy client sending pongs well within it.
+		if !armDeadline(c, svr.pongTimeout) {
+			return
+		}
+
+		// Wait for the first byte of the next frame; from then on the rest of the
+		// frame must arrive within readFrameTimeout (slow-read watchdog, readlimit.go).
+		if _, err := io.ReadFull(c.rawConn, rb.hdr[:1]); err != nil {
+			switch {
+			case c.ctx.Err() != nil:
+			case err == io.EOF:
+				c.noteDisconnect(causeClientClose)
+			case isTimeout(err):
+				// Nothing for pongTimeout, not even a pong.
+				c.noteDisconnect(causeIdle)
+			default:
+				metrics.WSReadErrors.Inc()
+				c.noteDisconnect(causeReadError)
+				slog.Debug("websocket read closed", "player_id", c.playerID(), "err", err)
+			}
+			return
+		}
+		if !armDeadline(c, svr.readFrameTimeout) {
+			return
+		}
+
+		hdr, err := rb.readHeader(c.rawConn, 1)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			if isTimeout(err) {
+				g.stopReading(svr, c, violationSlowRead, 0)
+			} else if err != io.EOF {
+				metrics.WSReadErrors.Inc()
+				c.noteDisconnect(causeReadError)
+				slog.Debug("websocket read closed", "player_id", c.playerID(), "err", err)
+			} else {
+				c.noteDisconnect(causeClientClose)
+			}
+			return
+		}
+		if svr.frameTooLarge(hdr) {
+			g.stopReading(svr, c, violationFrameTooLarge, hdr.Length)
+			return
+		}
+
+		payload, err := rb.readPayload(c.rawConn, hdr)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			if isTimeout(err) {
+				g.stopReading(svr, c, violationSlowRead, hdr.Length)
+			} else {
+				metrics.WSReadErrors.Inc()
+				c.noteDisconnect(causeReadError)
+			}
+			return
+		}
+		if hdr.Rsv1() {
+			if payload, err = c.inflate(payload); err != nil {
+				metrics.WSReadErrors.Inc()
+				c.noteDisconnect(causeProtocolViolation)
+				return
+			}
+		}
+
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+
+		switch hdr.OpCode {
+		case ws.OpClose:
+			c.noteDisconnect(causeClientClose)
+			return
+		case ws.OpPing:
+			pongFrame, compErr := ws.CompileFrame(ws.NewPongFrame(payload))
+			if compErr == nil {
+				select {
+				case c.writeCh <- writeJob{control: pongFrame, timeout: svr.directWriteTimeout}:
+				default:
+				}
+			}
+		case ws.OpPong:
+			c.notePong(time.Now().UnixNano())
+		case ws.OpBinary, ws.OpText:
+			metrics.BytesReceived.Add(float64(len(payload)))
+			atomic.AddInt64(&c.bytesIn, int64(len(payload)))
+			svr.countMessage(len(payload))
+			if svr.allowMessage(c, payload) && !svr.dispatchMessage(c, payload) {
+				<-c.ctx.Done() // panicked: wait for the close frame (recovery.go)
+				return
+			}
+		}
+	}
+}
+
+// armDeadline sets the read deadline d from now; false if c is already cancelled.
+// Checking after setting closes the race with the AfterFunc expiring the deadline.
+func armDeadline(c *Connection, d time.Duration) bool {
+	c.rawConn.SetReadDeadline(time.Now().Add(d))
+	return c.ctx.Err() == nil
+}
+
+// stopReading closes c for a read violation and waits for the close frame to go out
+// (or the write to time out) before readLoop returns and cleans up.
+func (g *goroutineReadHandler) stopReading(svr *Server, c *Connection, reason string, size int64) {
+	svr.readViolation(c, reason, size)
+	<-c.ctx.Done()
+}
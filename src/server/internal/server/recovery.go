@@ -0,0 +1,59 @@
+package server
+
+import (
+	"log/slog"
+	"runtime/debug"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Panic isolation. A bug triggered by one client — a message that trips an index out
+// of range, a nil player in an odd state — must cost that client its connection, not
+// every player their game. The goroutines that run code on behalf of a connection
+// recover panics and hand them to connPanic:
+//
+//   - read: processMessage, on the epoll worker or the connection's read goroutine;
+//     the connection is not read again;
+//   - fanout: enqueuing a world-state frame on a fanout worker; the rest of that
+//     worker's batch skips this tick;
+//   - write: the connection's write loop, which exits.
+//
+// The connection is closed with protocol.CloseInternalError (the client may
+// reconnect), the stack is logged and game_panics_recovered_total{where} counted.
+// HTTP handlers are covered by recoverPanics (middleware.go). The game loop is not:
+// a panic there leaves the world half-ticked, and crashing is safer than carrying on.
+
+// connPanic records a recovered panic p from where and drops c. c may be nil when the
+// panic could not be pinned on a connection.
+func (s *Server) connPanic(c *Connection, where string, p any) {
+	metrics.PanicsRecovered.WithLabelValues(where).Inc()
+	var playerID uint32
+	if c != nil {
+		playerID = c.playerID()
+	}
+	slog.Error("panic recovered", "where", where, "panic", p, "player_id", playerID, "stack", string(debug.Stack()))
+	if c == nil {
+		return
+	}
+	if where == "write" {
+		// The write loop is gone: nothing would send the close frame.
+		c.noteDisconnect(causeInternalError)
+		go s.cleanupConnection(c)
+		return
+	}
+	s.closeConnection(c, protocol.CloseInternalError, "internal error")
+}
+
+// dispatchMessage runs processMessage for c, recovering a panic. Returns false if it
+// panicked: c is being closed and must not be read again.
+func (s *Server) dispatchMessage(c *Connection, payload []byte) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			s.connPanic(c, "read", p)
+			ok = false
+		}
+	}()
+	s.processMessage(c, payload)
+	return true
+}
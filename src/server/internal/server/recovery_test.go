@@ -0,0 +1,74 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestPanicClosesOnlyThatConnection(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	other := s.createConnection(testutil.NewFakeConn())
+	s.reserveHandshake()
+	s.completeJoin(other, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	// A broken viewport limiter makes the next VIEWPORT_UPDATE panic, as a bug would.
+	c.viewport = &viewportState{}
+
+	before, _ := metrics.ReadCollector(metrics.PanicsRecovered)
+	if s.dispatchMessage(c, []byte{protocol.MessageViewportUpdate, 0x80, 0x07, 0x38, 0x04}) {
+		t.Fatal("dispatchMessage reported success for a panicking message")
+	}
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after the panic")
+	}
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(frames); n == 0 || frames[n-1].OpCode != ws.OpClose {
+		t.Fatalf("frames = %+v, want a close frame last", frames)
+	}
+	if code, _ := ws.ParseCloseFrameData(frames[len(frames)-1].Payload); uint16(code) != protocol.CloseInternalError {
+		t.Errorf("close code = %d, want %d", code, protocol.CloseInternalError)
+	}
+	// cleanupConnection removes the player after cancelling ctx.
+	for deadline := time.Now().Add(5 * time.Second); s.gameWorld.GetPlayerCount() != 1; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("players = %d, want only the other one left", s.gameWorld.GetPlayerCount())
+		}
+	}
+	if other.ctx.Err() != nil {
+		t.Error("the panic closed another connection")
+	}
+
+	// A panic on a fanout worker still completes the tick's wait group.
+	var wg sync.WaitGroup
+	var dropped int64
+	wg.Add(1)
+	s.runFanoutJob(fanoutJob{conns: []*Connection{nil}, dropped: &dropped, wg: &wg})
+	wg.Wait()
+
+	if after, _ := metrics.ReadCollector(metrics.PanicsRecovered); after != before+2 {
+		t.Errorf("panics recovered %v → %v, want two more", before, after)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Rejected actions. The client plays an attack as soon as it is clicked; when the
+// server refuses it (cooldown, stunned, dead, frozen) the client gets ACTION_REJECTED
+// with the reason and how many ticks to wait, so it can roll the animation back
+// instead of showing a swing nobody else saw. MOVE is never rejected: MOVEMENT_ACK
+// already carries the authoritative position.
+//
+// Messages dropped by the per-connection rate limiter are answered too, but only the
+// first drop of a burst — one reply per dropped message would let a flooding client
+// double its own traffic. The flag resets once a message gets through again.
+
+var rejectReasonLabels = map[uint8]string{
+	protocol.RejectCooldown:    "cooldown",
+	protocol.RejectStunned:     "stunned",
+	protocol.RejectDead:        "dead",
+	protocol.RejectFrozen:      "frozen",
+	protocol.RejectRateLimited: "rate_limited",
+	protocol.RejectUnknown:     "unknown",
+	protocol.RejectSafeZone:    "safe_zone",
+}
+
+// rejectAction tells c that its action was refused.
+func (s *Server) rejectAction(c *Connection, action uint8, rej game.Rejection) {
+	metrics.ActionsRejected.WithLabelValues(rejectReasonLabels[rej.Reason]).Inc()
+	s.sendDirect(c, s.protocol.EncodeActionRejected(action, rej.Reason, s.retryTicks(rej.RetryAfter)))
+}
+
+// retryTicks converts a wait into whole ticks, rounded up.
+func (s *Server) retryTicks(d time.Duration) uint16 {
+	if d <= 0 || s.cfg.Game.TickRate <= 0 {
+		return 0
+	}
+	tick := time.Second / time.Duration(s.cfg.Game.TickRate)
+	return uint16(min((d+tick-1)/tick, math.MaxUint16))
+}
+
+// allowMessage reports whether c's rate limiter lets payload through. Read path only.
+func (s *Server) allowMessage(c *Connection, payload []byte) bool {
+	if c.rateLimiter.Allow() {
+		c.rateLimited = false
+		return true
+	}
+	metrics.MessagesRateLimited.Inc()
+	s.recordTraffic(c, trafficRateLimited)
+	if c.rateLimited {
+		return false
+	}
+	c.rateLimited = true
+	slog.Warn("rate limit exceeded", "player_id", c.playerID())
+
+	if c.checksum {
+		payload = payload[min(protocol.ChecksumSize, len(payload)):]
+	}
+	if len(payload) == 0 || atomic.LoadInt32(&c.state) != connJoined {
+		return false
+	}
+	rej := game.Rejection{Reason: protocol.RejectRateLimited}
+	if limit := float64(c.rateLimiter.Limit()); limit > 0 {
+		rej.RetryAfter = time.Duration((1 - c.rateLimiter.Tokens()) / limit * float64(time.Second))
+	}
+	s.rejectAction(c, payload[0], rej)
+	return false
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestActionRejected(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Game.AttackDuration = time.Second
+	cfg.Net.MessageRateLimit, cfg.Net.BurstLimit = 1, 3
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+
+	rejections := func(n int) [][]byte {
+		t.Helper()
+		var got [][]byte
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+			frames, err := fake.Frames()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = got[:0]
+			for _, f := range frames {
+				if msg := f.Payload[seqHeaderSize:]; msg[0] == protocol.MessageActionRejected {
+					got = append(got, msg)
+				}
+			}
+			if len(got) >= n || time.Now().After(deadline) {
+				return got
+			}
+		}
+	}
+
+	attack := &protocol.ClientMessage{Type: protocol.MessageAttack}
+	s.handleClientMessage(c, attack, true) // accepted
+	s.handleClientMessage(c, attack, true) // in cooldown
+	got := rejections(1)
+	// 1 s of cooldown left at 1 tick/s.
+	if want := s.protocol.EncodeActionRejected(protocol.MessageAttack, protocol.RejectCooldown, 1); len(got) != 1 || string(got[0]) != string(want) {
+		t.Fatalf("ACTION_REJECTED after a second attack = %x, want %x", got, want)
+	}
+
+	// A burst past the limiter is answered once, not per dropped message.
+	move := []byte{protocol.MessageMove}
+	var allowed int
+	for range 10 {
+		if s.allowMessage(c, move) {
+			allowed++
+		}
+	}
+	if allowed != cfg.Net.BurstLimit {
+		t.Errorf("allowed %d of 10 messages, want the burst of %d", allowed, cfg.Net.BurstLimit)
+	}
+	got = rejections(2)
+	if len(got) != 2 || got[1][1] != protocol.MessageMove || got[1][2] != protocol.RejectRateLimited {
+		t.Fatalf("ACTION_REJECTED after the burst = %x, want one more, rate_limited", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got = rejections(2); len(got) != 2 {
+		t.Errorf("%d ACTION_REJECTED for one burst, want 1", len(got)-1)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Reliable messages (protocol/reliable.go).
+//
+// TCP does not lose messages, but the server does: a full writeCh drops a message
+// silently (noteDrop), and the client only learns about it from a sequence gap. Most
+// losses heal with the next delta or full sync; PLAYER_JOINED, PLAYER_LEFT and movement
+// corrections do not. A client that set protocol.CapReliable in JOIN gets those inside
+// RELIABLE envelopes and acks each one; runReliableLoop sends unacked ones again after
+// Net.ReliableRetry, doubling the delay every time, and gives up after
+// Net.ReliableMaxRetries retransmissions. Other clients get the plain messages as before.
+//
+// A correction is superseded by any later MOVEMENT_ACK: once one is sent, the older
+// correction is no longer retransmitted, so a late copy cannot pull the client back.
+
+var (
+	reliableSent          = metrics.ReliableMessages.WithLabelValues("sent")
+	reliableRetransmitted = metrics.ReliableMessages.WithLabelValues("retransmitted")
+	reliableAcked         = metrics.ReliableMessages.WithLabelValues("acked")
+	reliableExpired       = metrics.ReliableMessages.WithLabelValues("expired")
+)
+
+// reliableMsg — неподтверждённое RELIABLE сообщение.
+type reliableMsg struct {
+	id      uint32
+	data    []byte // the envelope, sent again as is
+	dedupe  uint64 // dedupeKey of the inner message; 0 = never superseded
+	retries int    // retransmissions so far
+	nextNs  int64  // UnixNano of the next retransmission
+}
+
+// reliableState — RELIABLE сообщения соединения, ждущие RELIABLE_ACK.
+type reliableState struct {
+	mu      sync.Mutex
+	nextID  uint32
+	pending []reliableMsg // ascending id
+	resend  [][]byte      // runReliableLoop scratch
+}
+
+// wrapsReliable reports whether data goes to c in a RELIABLE envelope: c acks them
+// and the type is not sealed for c (the write loop seals by the outer type).
+func (c *Connection) wrapsReliable(data []byte) bool {
+	return c.reliable != nil && (c.seal == nil || !c.seal.types[data[0]])
+}
+
+// sendReliable sends a critical message: in a RELIABLE envelope to clients that ack
+// them, plain to the others.
+func (s *Server) sendReliable(conn *Connection, data []byte, dedupe uint64) {
+	if !conn.wrapsReliable(data) {
+		s.sendDirect(conn, data)
+		return
+	}
+	rs := conn.reliable
+	rs.mu.Lock()
+	if dedupe != 0 {
+		rs.supersede(dedupe)
+	}
+	if len(rs.pending) >= max(s.cfg.Net.ReliableMaxPending, 1) {
+		// The client stopped acking; keep the newest.
+		rs.pending = append(rs.pending[:0], rs.pending[1:]...)
+		reliableExpired.Inc()
+	}
+	rs.nextID++
+	env := s.protocol.AppendReliable(nil, rs.nextID, data)
+	rs.pending = append(rs.pending, reliableMsg{
+		id:     rs.nextID,
+		data:   env,
+		dedupe: dedupe,
+		nextNs: time.Now().Add(s.cfg.Net.ReliableRetry).UnixNano(),
+	})
+	rs.mu.Unlock()
+
+	reliableSent.Inc()
+	// A drop here is what the layer is for: the message is retransmitted.
+	s.sendDirect(conn, env)
+}
+
+// broadcastReliable sends a critical message to every connected client. The plain
+// copies share one prepared frame (broadcastEvent).
+func (s *Server) broadcastReliable(data []byte) {
+	var f *tickFrame
+	s.connectionsMu.RLock()
+	for _, conn := range s.connections {
+		if conn.wrapsReliable(data) {
+			s.sendReliable(conn, data, 0)
+			continue
+		}
+		if f == nil {
+			f = s.prepareFrame(data)
+		}
+		s.sendPrepared(conn, f)
+	}
+	s.connectionsMu.RUnlock()
+	if f != nil {
+		f.release()
+	}
+}
+
+// supersedeReliable stops retransmitting pending messages of c with the dedupe key:
+// a newer message for the same subject is on its way.
+func (c *Connection) supersedeReliable(dedupe uint64) {
+	if rs := c.reliable; rs != nil {
+		rs.mu.Lock()
+		rs.supersede(dedupe)
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *reliableState) supersede(dedupe uint64) {
+	rs.pending = deleteReliable(rs.pending, func(m *reliableMsg) bool { return m.dedupe == dedupe })
+}
+
+// ackReliable handles RELIABLE_ACK. Acks of unknown ids (already acked or given up)
+// are ignored: the client acks every copy it receives.
+func (s *Server) ackReliable(c *Connection, id uint32) {
+	rs := c.reliable
+	if rs == nil {
+		return
+	}
+	rs.mu.Lock()
+	n := len(rs.pending)
+	rs.pending = deleteReliable(rs.pending, func(m *reliableMsg) bool { return m.id == id })
+	acked := len(rs.pending) < n
+	rs.mu.Unlock()
+	if acked {
+		reliableAcked.Inc()
+	}
+}
+
+func deleteReliable(pending []reliableMsg, del func(*reliableMsg) bool) []reliableMsg {
+	n := 0
+	for i := range pending {
+		if !del(&pending[i]) {
+			pending[n] = pending[i]
+			n++
+		}
+	}
+	clear(pending[n:])
+	return pending[:n]
+}
+
+// runReliableLoop retransmits overdue RELIABLE messages. Runs for the lifetime of the
+// server context.
+func (s *Server) runReliableLoop() {
+	retry := s.cfg.Net.ReliableRetry
+	ticker := time.NewTicker(max(retry/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			nowNs := time.Now().UnixNano()
+			s.connectionsMu.RLock()
+			for _, conn := range s.connections {
+				if conn.reliable != nil {
+					s.retransmitReliable(conn, nowNs, retry)
+				}
+			}
+			s.connectionsMu.RUnlock()
+
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// retransmitReliable sends c's overdue messages again and gives up those out of retries.
+func (s *Server) retransmitReliable(c *Connection, nowNs int64, retry time.Duration) {
+	rs := c.reliable
+	rs.mu.Lock()
+	rs.resend = rs.resend[:0]
+	expired := 0
+	rs.pending = deleteReliable(rs.pending, func(m *reliableMsg) bool {
+		if m.nextNs > nowNs {
+			return false
+		}
+		if m.retries >= s.cfg.Net.ReliableMaxRetries {
+			expired++
+			return true
+		}
+		m.retries++
+		m.nextNs = nowNs + int64(retry)<<min(m.retries, 16)
+		rs.resend = append(rs.resend, m.data)
+		return false
+	})
+	for _, data := range rs.resend {
+		if atomic.LoadInt32(&c.state) == connJoined {
+			s.sendDirect(c, data)
+		}
+	}
+	resent := len(rs.resend)
+	clear(rs.resend)
+	rs.mu.Unlock()
+
+	reliableRetransmitted.Add(float64(resent))
+	reliableExpired.Add(float64(expired))
+}
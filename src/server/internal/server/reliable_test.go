@@ -0,0 +1,104 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestReliableRetransmitAckExpire(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.ReliableRetry = 100 * time.Millisecond
+	cfg.Net.ReliableMaxRetries = 2
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}}
+	c := &Connection{writeCh: make(chan writeJob, 16), state: connJoined, reliable: &reliableState{}}
+
+	// sent returns the ids of the RELIABLE envelopes queued since the last call.
+	sent := func() []uint32 {
+		var ids []uint32
+		for len(c.writeCh) > 0 {
+			id, inner, err := protocol.OpenReliable((<-c.writeCh).direct)
+			if err != nil || inner[0] != protocol.MessagePlayerLeft {
+				t.Fatalf("queued %v, %v; want RELIABLE with PLAYER_LEFT", inner, err)
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	start := time.Now().UnixNano()
+	s.sendReliable(c, s.protocol.EncodePlayerLeft(7), 0)
+	s.sendReliable(c, s.protocol.EncodePlayerLeft(8), 0)
+	if got := sent(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("sent ids %v, want [1 2]", got)
+	}
+
+	s.retransmitReliable(c, start, cfg.Net.ReliableRetry)
+	if got := sent(); len(got) != 0 {
+		t.Fatalf("retransmitted %v before the retry delay", got)
+	}
+
+	s.ackReliable(c, 1)
+	now := start + int64(time.Second)
+	s.retransmitReliable(c, now, cfg.Net.ReliableRetry)
+	if got := sent(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("retransmitted %v, want [2]", got)
+	}
+	// Backoff: the second retransmission waits twice as long (2 × 100ms after now).
+	s.retransmitReliable(c, now+int64(150*time.Millisecond), cfg.Net.ReliableRetry)
+	if got := sent(); len(got) != 0 {
+		t.Fatalf("retransmitted %v before the backed-off delay", got)
+	}
+	s.retransmitReliable(c, now+int64(250*time.Millisecond), cfg.Net.ReliableRetry)
+	if got := sent(); len(got) != 1 {
+		t.Fatalf("retransmitted %v, want [2]", got)
+	}
+	// Out of retries: given up, nothing sent.
+	s.retransmitReliable(c, now+int64(time.Hour), cfg.Net.ReliableRetry)
+	if got := sent(); len(got) != 0 || len(c.reliable.pending) != 0 {
+		t.Fatalf("retransmitted %v, pending %d after max retries", got, len(c.reliable.pending))
+	}
+}
+
+func TestReliableCorrectionSuperseded(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.ReliableRetry = 100 * time.Millisecond
+	cfg.Net.CoalesceMoveAcks = false
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}}
+	c := &Connection{
+		writeCh:  make(chan writeJob, 16),
+		state:    connJoined,
+		reliable: &reliableState{},
+		player:   types.NewPlayer(1001),
+	}
+
+	c.correction = 1
+	s.queueMoveAck(c, 10, 20, 5)
+	if len(c.reliable.pending) != 1 {
+		t.Fatalf("correction not pending: %d", len(c.reliable.pending))
+	}
+	if job := <-c.writeCh; job.direct[0] != protocol.MessageReliable {
+		t.Fatalf("correction sent as type %d, want RELIABLE", job.direct[0])
+	}
+
+	// A newer ACK replaces the correction: it must not be retransmitted.
+	s.queueMoveAck(c, 12, 20, 6)
+	if job := <-c.writeCh; job.direct[0] != protocol.MessageMovementAck {
+		t.Fatalf("ACK sent as type %d, want MOVEMENT_ACK", job.direct[0])
+	}
+	if len(c.reliable.pending) != 0 {
+		t.Fatal("superseded correction still pending")
+	}
+}
+
+func TestReliableFallsBackToPlain(t *testing.T) {
+	s := &Server{cfg: testutil.Config(), protocol: &protocol.BinaryProtocol{}}
+	c := &Connection{writeCh: make(chan writeJob, 1)}
+	s.sendReliable(c, s.protocol.EncodePlayerLeft(7), 0)
+	if job := <-c.writeCh; job.direct[0] != protocol.MessagePlayerLeft {
+		t.Fatalf("sent type %d to a client without CapReliable, want PLAYER_LEFT", job.direct[0])
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// Accept scaling (Server.ReusePortListeners > 0).
+//
+// A listening socket has one accept queue, drained by one accept loop: in a
+// connection storm — the reconnect wave after a restart, a load test — that loop and
+// the TLS handshakes behind it are the bottleneck while other cores idle. With
+// REUSEPORT_LISTENERS=N the game address is bound N times with SO_REUSEPORT, each
+// socket served by an accept loop of its own (http.Server.Serve per socket), and the
+// kernel hashes every new connection to one of them. game_listener_accepts_total
+// shows the spread.
+//
+// After a handover the new process inherits socket 0 and binds the others next to
+// it; connections still in the old process's accept queues are reset when it exits
+// and reconnect. A socket inherited from a process that ran without SO_REUSEPORT
+// cannot be joined: it is served alone until the next restart.
+
+// bindListener binds the sockets l accepts on: l.inherited or addr, and with
+// l.sockets > 0 as many SO_REUSEPORT sockets on the same address.
+func (s *Server) bindListener(l *httpListener) ([]net.Listener, error) {
+	n := l.sockets
+	if n > 0 && !reusePortSupported {
+		slog.Warn("REUSEPORT_LISTENERS needs Linux, binding one socket", "listener", l.name)
+		n = 0
+	}
+	first := l.inherited
+	if first == nil {
+		var err error
+		if first, err = s.listen(l.addr, n > 0); err != nil {
+			return nil, err
+		}
+	}
+	lns := []net.Listener{first}
+	var lc net.ListenConfig
+	lc.Control = reusePortControl
+	for len(lns) < n {
+		// first's address, not l.addr: the same port when l.addr asks for any (":0").
+		ln, err := lc.Listen(context.Background(), "tcp", first.Addr().String())
+		if err != nil {
+			if l.inherited != nil {
+				slog.Warn("cannot bind SO_REUSEPORT sockets next to the inherited listener",
+					"listener", l.name, "sockets", len(lns), "error", err)
+				break
+			}
+			closeListeners(lns)
+			return nil, err
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+func closeListeners(lns []net.Listener) {
+	for _, ln := range lns {
+		ln.Close()
+	}
+}
+
+// countAccepts counts the connections accepted on socket i of the game listener.
+func countAccepts(ln net.Listener, i int) net.Listener {
+	return &countingListener{Listener: ln, accepts: metrics.ListenerAccepts.WithLabelValues(strconv.Itoa(i))}
+}
+
+type countingListener struct {
+	net.Listener
+	accepts interface{ Inc() }
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepts.Inc()
+	}
+	return conn, err
+}
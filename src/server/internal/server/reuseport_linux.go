@@ -0,0 +1,24 @@
+//go:build linux
+
+package server
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePortSupported — the kernel balances connections across SO_REUSEPORT sockets.
+const reusePortSupported = true
+
+// reusePortControl sets SO_REUSEPORT before bind, so several sockets can bind the
+// same address and the kernel hashes each new connection to one of them.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
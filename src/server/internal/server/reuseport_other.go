@@ -0,0 +1,16 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortSupported: SO_REUSEPORT exists on the BSDs too, but only Linux spreads
+// connections across the sockets; elsewhere the last one bound takes them all.
+const reusePortSupported = false
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT listeners need Linux")
+}
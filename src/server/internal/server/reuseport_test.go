@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+func TestReusePortListeners(t *testing.T) {
+	if !reusePortSupported {
+		t.Skip("SO_REUSEPORT listeners need Linux")
+	}
+	s := &Server{}
+	lns, err := s.bindListener(&httpListener{name: "game", addr: "127.0.0.1:0", sockets: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lns) != 4 {
+		t.Fatalf("%d sockets, want 4", len(lns))
+	}
+	addr := lns[0].Addr().String()
+	for _, ln := range lns[1:] {
+		if ln.Addr().String() != addr {
+			t.Fatalf("socket bound on %s, want %s like the first", ln.Addr(), addr)
+		}
+	}
+
+	// The kernel spreads connections across the sockets.
+	var mu sync.Mutex
+	accepted := make(map[int]int)
+	var wg sync.WaitGroup
+	for i, ln := range lns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+				mu.Lock()
+				accepted[i]++
+				mu.Unlock()
+			}
+		}()
+	}
+	const dials = 64
+	for range dials {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	closeListeners(lns)
+	wg.Wait()
+	if len(accepted) < 2 {
+		t.Errorf("all %d connections went to one socket: %v", dials, accepted)
+	}
+
+	// A socket inherited from a process without SO_REUSEPORT is served alone.
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	lns, err = s.bindListener(&httpListener{name: "game", addr: plain.Addr().String(), sockets: 4, inherited: plain})
+	if err != nil || len(lns) != 1 || lns[0] != plain {
+		t.Errorf("inherited plain listener: %d sockets, %v; want it alone", len(lns), err)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/schedule"
+)
+
+// Scheduled restarts and maintenance windows (RESTART_SCHEDULE, MAINTENANCE_SCHEDULE).
+//
+// Before each occurrence players get an ANNOUNCE at every lead time of
+// SCHEDULE_WARNINGS; the last warning also starts maintenance mode (maintenance.go)
+// with the remaining time as its countdown, so new connections are refused from then
+// on. At the scheduled minute the world is paused and snapshotted, then:
+//
+//   - restart: every client is closed with CloseServerShutdown (they reconnect with
+//     backoff), Start returns and the process exits with ExitRestart — the supervisor
+//     starts it again and tells a planned restart from a crash by the code;
+//   - maintenance: the world stays paused for MAINTENANCE_WINDOW_MIN, then resumes.
+
+// ExitRestart — exit code after a scheduled restart (EX_TEMPFAIL).
+const ExitRestart = 75
+
+// Scheduled actions.
+const (
+	actionRestart     = "restart"
+	actionMaintenance = "maintenance"
+)
+
+// scheduledJob — one configured schedule and its next occurrence.
+type scheduledJob struct {
+	action string
+	cron   *schedule.Cron
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (j *scheduledJob) setNext(t time.Time) {
+	j.mu.Lock()
+	j.next = t
+	j.mu.Unlock()
+	metrics.NextScheduledAction.WithLabelValues(j.action).Set(float64(t.Unix()))
+}
+
+func (j *scheduledJob) nextAt() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.next
+}
+
+// parseWarnings parses SCHEDULE_WARNINGS ("10m,5m,1m") into lead times, longest first.
+func parseWarnings(s string) ([]time.Duration, error) {
+	var warnings []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("bad warning %q: want a positive duration such as 5m", part)
+		}
+		warnings = append(warnings, d)
+	}
+	slices.SortFunc(warnings, func(a, b time.Duration) int { return int(b - a) })
+	return slices.Compact(warnings), nil
+}
+
+// initSchedule parses the schedules and starts one goroutine per job. A bad
+// expression disables only its job.
+func (s *Server) initSchedule() {
+	warnings, err := parseWarnings(s.cfg.Server.ScheduleWarnings)
+	if err != nil {
+		slog.Error("bad SCHEDULE_WARNINGS, scheduled actions come unannounced", "error", err)
+	}
+	s.scheduleWarnings = warnings
+
+	for _, j := range []struct{ action, expr string }{
+		{actionRestart, s.cfg.Server.RestartSchedule},
+		{actionMaintenance, s.cfg.Server.MaintenanceSchedule},
+	} {
+		if j.expr == "" {
+			continue
+		}
+		cron, err := schedule.ParseCron(j.expr)
+		if err != nil {
+			slog.Error("scheduled action disabled", "action", j.action, "error", err)
+			continue
+		}
+		job := &scheduledJob{action: j.action, cron: cron}
+		s.scheduled = append(s.scheduled, job)
+		go s.runScheduled(job)
+	}
+}
+
+// runScheduled carries out job at each occurrence until the server stops.
+func (s *Server) runScheduled(job *scheduledJob) {
+	for {
+		at := job.cron.Next(time.Now())
+		if at.IsZero() {
+			slog.Error("schedule never matches", "action", job.action)
+			return
+		}
+		job.setNext(at)
+		slog.Info("next scheduled action", "action", job.action, "at", at)
+		if !s.runScheduledAt(job.action, at) || job.action == actionRestart {
+			return
+		}
+	}
+}
+
+// runScheduledAt warns players ahead of at and carries out action then. Returns false
+// if the server stopped meanwhile.
+func (s *Server) runScheduledAt(action string, at time.Time) bool {
+	for i, lead := range s.scheduleWarnings {
+		warnAt := at.Add(-lead)
+		if time.Now().After(warnAt) {
+			continue // scheduled less than lead ahead (startup, short period)
+		}
+		if !s.sleepUntil(warnAt) {
+			return false
+		}
+		s.scheduleWarning(action, lead, i == len(s.scheduleWarnings)-1)
+	}
+	if !s.sleepUntil(at) {
+		return false
+	}
+
+	metrics.ScheduledActions.WithLabelValues(action).Inc()
+	retryAfter := int(s.cfg.Server.MaintenanceRetryAfter.Seconds())
+	// No-op if the last warning's countdown already paused the world.
+	s.pauseNow(retryAfter)
+	if action == actionRestart {
+		s.restart()
+		return false
+	}
+
+	slog.Warn("scheduled maintenance window started", "window", s.cfg.Server.MaintenanceWindow)
+	if !s.sleepUntil(time.Now().Add(s.cfg.Server.MaintenanceWindow)) {
+		return false
+	}
+	s.endMaintenance()
+	return true
+}
+
+// scheduleWarning announces an upcoming action; the last warning also starts the
+// maintenance countdown.
+func (s *Server) scheduleWarning(action string, lead time.Duration, last bool) {
+	text := "Server restart in " + formatLead(lead)
+	if action == actionMaintenance {
+		text = fmt.Sprintf("Scheduled maintenance in %s, for about %s", formatLead(lead), formatLead(s.cfg.Server.MaintenanceWindow))
+	}
+	severity := uint8(protocol.SeverityWarning)
+	if last {
+		severity = protocol.SeverityCritical
+	}
+	n := s.announce(announceTarget{}, severity, localizedText{"": text})
+	slog.Warn("scheduled action warning", "action", action, "in", lead, "players", n)
+
+	if last {
+		if err := s.startMaintenance(lead, int(s.cfg.Server.MaintenanceRetryAfter.Seconds())); err != nil {
+			slog.Warn("maintenance countdown not started", "action", action, "error", err)
+		}
+	}
+}
+
+// formatLead renders a lead time for players: "5 minutes", "1 minute", "30 seconds".
+func formatLead(d time.Duration) string {
+	unit, n := "second", int(d.Round(time.Second)/time.Second)
+	if d >= time.Minute {
+		unit, n = "minute", int(d.Round(time.Minute)/time.Minute)
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// sleepUntil waits for t; false if the server stopped first.
+func (s *Server) sleepUntil(t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// restart disconnects everyone and stops the server; Start returns and main exits
+// with ExitCode.
+func (s *Server) restart() {
+	slog.Warn("scheduled restart: shutting down", "exit_code", ExitRestart)
+	atomic.StoreInt32(&s.exitCode, ExitRestart)
+	s.Shutdown("scheduled restart")
+}
+
+// ExitCode returns the code the process should exit with once Start has returned:
+// ExitRestart after a scheduled restart, 0 otherwise.
+func (s *Server) ExitCode() int {
+	return int(atomic.LoadInt32(&s.exitCode))
+}
+
+// nextScheduled returns the next occurrence of each configured action (nil = none).
+func (s *Server) nextScheduled() map[string]time.Time {
+	var next map[string]time.Time
+	for _, job := range s.scheduled {
+		if at := job.nextAt(); !at.IsZero() {
+			if next == nil {
+				next = make(map[string]time.Time, len(s.scheduled))
+			}
+			next[job.action] = at
+		}
+	}
+	return next
+}
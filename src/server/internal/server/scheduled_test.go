@@ -0,0 +1,70 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestParseWarnings(t *testing.T) {
+	got, err := parseWarnings(" 1m, 10m,30s,1m ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []time.Duration{10 * time.Minute, time.Minute, 30 * time.Second}; !slices.Equal(got, want) {
+		t.Errorf("parseWarnings = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"5", "-1m", "0s", "soon"} {
+		if _, err := parseWarnings(bad); err == nil {
+			t.Errorf("parseWarnings(%q) accepted", bad)
+		}
+	}
+	for d, want := range map[time.Duration]string{
+		10 * time.Minute: "10 minutes", time.Minute: "1 minute", 30 * time.Second: "30 seconds", time.Second: "1 second",
+	} {
+		if got := formatLead(d); got != want {
+			t.Errorf("formatLead(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestScheduledRestart(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.MaintenanceSnapshotPath = filepath.Join(t.TempDir(), "snapshot.json")
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(s.cancel)
+	s.scheduleWarnings = []time.Duration{300 * time.Millisecond, 100 * time.Millisecond}
+
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	s.gameWorld.SetPaused(false)
+
+	if s.runScheduledAt(actionRestart, time.Now().Add(400*time.Millisecond)) {
+		t.Error("runScheduledAt returned true after a restart")
+	}
+	if got := s.ExitCode(); got != ExitRestart {
+		t.Errorf("ExitCode = %d, want %d", got, ExitRestart)
+	}
+	if !fake.Closed() {
+		t.Error("client not disconnected")
+	}
+	if _, err := os.Stat(cfg.Server.MaintenanceSnapshotPath); err != nil {
+		t.Errorf("world snapshot not written: %v", err)
+	}
+	if got := announcements(t, fake); len(got) != 2 {
+		t.Errorf("announcements = %q, want one per warning", got)
+	}
+	if !hasMessage(t, fake, protocol.MessageMaintenance, 0) {
+		t.Error("the last warning did not start the maintenance countdown")
+	}
+}
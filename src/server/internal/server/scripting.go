@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+
+	"pixi_game_server/internal/console"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/scripting"
+)
+
+// Gameplay scripts (SCRIPTS_DIR, see internal/scripting). The server posts hooks to
+// the engine and never waits for them: onPlayerJoin after a fresh JOIN, onTick after
+// every tick, onAttack for every hit, onChatCommand for a /command no built-in took —
+// its answer is sent when the script returns. Scripts talk back through scriptHost.
+
+// initScripts starts the script engine; a server without one runs as usual.
+func (s *Server) initScripts() {
+	g := s.cfg.Game
+	if g.ScriptsDir == "" {
+		return
+	}
+	engine, err := scripting.New(scripting.Config{Dir: g.ScriptsDir, Budget: g.ScriptBudget, MemoryMB: g.ScriptMemoryMB}, scriptHost{s})
+	if err != nil {
+		if errors.Is(err, scripting.ErrNoRuntime) {
+			slog.Error("SCRIPTS_DIR is set but this binary has no script runtime, scripts are off", "dir", g.ScriptsDir)
+		} else {
+			slog.Error("failed to load scripts, scripts are off", "dir", g.ScriptsDir, "err", err)
+		}
+		return
+	}
+	s.scripts = engine
+	go engine.Run(s.ctx)
+}
+
+// postTick runs on the gameLoop goroutine after every tick (SetPostTickHook).
+func (s *Server) postTick() {
+	s.flushMoveAcks()
+	if s.scripts != nil {
+		s.scripts.Tick()
+	}
+}
+
+// onHit sends a hit to the players involved and tells the scripts.
+func (s *Server) onHit(hit game.Hit) {
+	s.sendHit(hit)
+	if s.scripts != nil {
+		s.scripts.Attack(hit.AttackerID, hit.VictimID, hit.Damage, hit.HP)
+	}
+}
+
+// runScriptCommand offers a command unknown to the console to the scripts; unknown
+// is the answer when none takes it.
+func (s *Server) runScriptCommand(c *Connection, caller console.Caller, line, name string, unknown console.Result) {
+	args, _ := console.Split(line) // Dispatch parsed it already
+	s.scripts.ChatCommand(caller.PlayerID, name, args[1:], func(text string, handled bool) {
+		if !handled {
+			s.answerCommand(c, caller, name, unknown)
+			return
+		}
+		s.answerCommand(c, caller, "script", console.OK("%s", text))
+	})
+}
+
+// scriptHost is the game as scripts see it (scripting.Host).
+type scriptHost struct {
+	s *Server
+}
+
+func (h scriptHost) Say(playerID uint32, text string) {
+	h.s.announce(announceTarget{playerID: playerID}, protocol.SeverityInfo, localizedText{"": text})
+}
+
+func (h scriptHost) Announce(text string) {
+	h.s.announce(announceTarget{}, protocol.SeverityInfo, localizedText{"": text})
+}
+
+func (h scriptHost) Player(playerID uint32) (scripting.PlayerInfo, bool) {
+	h.s.connectionsMu.RLock()
+	c := h.s.connections[playerID]
+	h.s.connectionsMu.RUnlock()
+	if c == nil {
+		return scripting.PlayerInfo{}, false
+	}
+	return scripting.PlayerInfo{ID: playerID, X: c.player.GetX(), Y: c.player.GetY()}, true
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"pixi_game_server/internal/auth"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Sealed messages (protocol/sealed.go): application-layer encryption for deployments
+// where TLS terminates at an edge that should not read account data.
+//
+// Server.SealedMessages names the message types to seal. A client that connected with
+// a session token and set protocol.CapEncryption in JOIN gets CIPHER_INIT (salt and
+// types) first; from then on those types travel only inside SEALED envelopes, in both
+// directions: the write loop seals outgoing ones (so counters follow the write order),
+// processMessage opens incoming ones and drops plaintext of a sealed type. Anonymous
+// clients and clients without the capability get everything in plaintext as before.
+
+// sealState — per-connection AEAD and counters.
+type sealState struct {
+	aead  cipher.AEAD
+	types *[256]bool // Server.sealedTypes
+	sent  uint32     // last counter sealed (write loop only)
+	recv  uint32     // last counter opened (read path only)
+}
+
+var (
+	sealedOut = metrics.SealedMessages.WithLabelValues("outbound")
+	sealedIn  = metrics.SealedMessages.WithLabelValues("inbound")
+)
+
+// parseSealedTypes resolves Server.SealedMessages ("config,session_takeover") to
+// message types. Handshake and envelope messages cannot be sealed.
+func parseSealedTypes(names string) ([]uint8, error) {
+	var types []uint8
+	for name := range strings.SplitSeq(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found *protocol.MessageSchema
+		for i := range protocol.Messages {
+			if strings.EqualFold(protocol.Messages[i].ConstName(), name) {
+				found = &protocol.Messages[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown message %q", name)
+		}
+		switch found.Type {
+		case protocol.MessageJoin, protocol.MessageCipherInit, protocol.MessageSealed, protocol.MessageSealedClient,
+			protocol.MessageReliable, protocol.MessageReliableAck:
+			return nil, fmt.Errorf("message %q cannot be sealed", name)
+		}
+		types = append(types, found.Type)
+	}
+	return types, nil
+}
+
+// initSealedTypes fills s.sealedTypes from config.
+func (s *Server) initSealedTypes() {
+	types, err := parseSealedTypes(s.cfg.Server.SealedMessages)
+	if err != nil {
+		slog.Error("bad SEALED_MESSAGES, nothing is sealed", "error", err)
+		return
+	}
+	s.sealedList = types
+	for _, t := range types {
+		s.sealedTypes[t] = true
+	}
+}
+
+// startSealing sets up sealing for c if it asked for it and holds a session key, and
+// sends CIPHER_INIT. Called in completeJoin before any other data message.
+func (s *Server) startSealing(c *Connection) {
+	if c.caps&protocol.CapEncryption == 0 || c.account == "" || len(s.sealedList) == 0 {
+		return
+	}
+	var salt [protocol.SaltSize]byte
+	rand.Read(salt[:])
+	aead, err := protocol.NewSealer(auth.SessionKey(s.authSecret, c.token), salt[:])
+	if err != nil {
+		slog.Error("sealer setup failed", "account", c.account, "error", err)
+		return
+	}
+	c.seal = &sealState{aead: aead, types: &s.sealedTypes}
+	metrics.ClientCapabilities.WithLabelValues("encryption").Inc()
+	s.sendDirect(c, s.protocol.EncodeCipherInit(salt, s.sealedList))
+}
+
+// sealOut seals payload if its type is sealed. Write loop only.
+func (st *sealState) sealOut(payload []byte) ([]byte, bool) {
+	if len(payload) == 0 || !st.types[payload[0]] {
+		return payload, false
+	}
+	st.sent++
+	sealedOut.Inc()
+	return protocol.AppendSealed(nil, st.aead, protocol.MessageSealed, st.sent, payload), true
+}
+
+// unseal returns the message to decode from a joined connection: SEALED_CLIENT opened,
+// plaintext passed through unless its type must be sealed.
+func (s *Server) unseal(c *Connection, message []byte) ([]byte, error) {
+	st := c.seal
+	if len(message) == 0 {
+		return message, nil
+	}
+	if message[0] != protocol.MessageSealedClient {
+		if st != nil && st.types[message[0]] {
+			return nil, fmt.Errorf("unsealed %d from a sealing client", message[0])
+		}
+		return message, nil
+	}
+	if st == nil {
+		return nil, fmt.Errorf("sealed message without CIPHER_INIT")
+	}
+	counter, inner, err := protocol.OpenSealed(st.aead, message)
+	if err != nil {
+		return nil, err
+	}
+	if counter <= st.recv {
+		return nil, fmt.Errorf("sealed counter %d after %d", counter, st.recv)
+	}
+	if !st.types[inner[0]] {
+		return nil, fmt.Errorf("sealed %d is not a sealed type", inner[0])
+	}
+	st.recv = counter
+	sealedIn.Inc()
+	return inner, nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/auth"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestSealedMessages(t *testing.T) {
+	secret := []byte("s3cret")
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.AuthSecret = string(secret)
+	cfg.Server.SealedMessages = "config, viewport_update"
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	token, err := auth.Sign(secret, "alice", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	c.account, c.token = "alice", token
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy | protocol.CapEncryption})
+	if !hasMessage(t, fake, protocol.MessageSealed, time.Second) {
+		t.Fatal("no SEALED message")
+	}
+
+	// CIPHER_INIT first, then CONFIG only inside a SEALED envelope.
+	frames, _ := fake.Frames()
+	init := frames[0].Payload[seqHeaderSize:]
+	if init[0] != protocol.MessageCipherInit {
+		t.Fatalf("first message type %d, want CIPHER_INIT", init[0])
+	}
+	aead, err := protocol.NewSealer(auth.SessionKey(secret, token), init[1:1+protocol.SaltSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames[1:] {
+		msg := f.Payload[seqHeaderSize:]
+		switch msg[0] {
+		case protocol.MessageConfig:
+			t.Error("CONFIG sent in plaintext")
+		case protocol.MessageSealed:
+			counter, inner, err := protocol.OpenSealed(aead, msg)
+			if err != nil || counter != 1 || inner[0] != protocol.MessageConfig {
+				t.Errorf("SEALED = counter %d, type %v, %v; want CONFIG with counter 1", counter, inner, err)
+			}
+		}
+	}
+
+	// Client side: sealed viewport accepted once; plaintext and replays dropped.
+	viewport := []byte{protocol.MessageViewportUpdate, 0x80, 0x07, 0x38, 0x04}
+	sealed := protocol.AppendSealed(nil, aead, protocol.MessageSealedClient, 1, viewport)
+	for _, msg := range [][]byte{viewport, sealed, sealed} {
+		s.processMessage(c, msg)
+	}
+	c.traffic.mu.Lock()
+	viewports, invalid := c.traffic.total[trafficViewport], c.traffic.total[trafficInvalid]
+	c.traffic.mu.Unlock()
+	if viewports != 1 || invalid != 2 {
+		t.Errorf("viewports = %d, invalid = %d; want 1 and 2", viewports, invalid)
+	}
+}
+
+func TestParseSealedTypes(t *testing.T) {
+	types, err := parseSealedTypes("config, SESSION_TAKEOVER,,")
+	if err != nil || len(types) != 2 || types[0] != protocol.MessageConfig || types[1] != protocol.MessageSessionTakeover {
+		t.Errorf("parseSealedTypes = %v, %v", types, err)
+	}
+	for _, bad := range []string{"join", "sealed", "no_such_message"} {
+		if _, err := parseSealedTypes(bad); err == nil {
+			t.Errorf("parseSealedTypes(%q) accepted", bad)
+		}
+	}
+}
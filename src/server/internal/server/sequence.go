@@ -0,0 +1,114 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// Outbound sequence numbers.
+//
+// Every data message written to a connection is prefixed with a per-connection u32
+// sequence (see appendFrameHeader). Messages dropped before reaching the write loop
+// (full writeCh) are counted in outDropped, and the write loop skips that many
+// numbers, so the client sees a gap exactly where something was lost — without
+// reordering, since only the write loop assigns numbers. The client reports gaps
+// via SEQUENCE_REPORT and may ask for a full GAME_STATE to resynchronise.
+
+// resyncMinInterval rate-limits resync requests per connection.
+const resyncMinInterval = time.Second
+
+// noteDrop records a message that could not be enqueued for conn.
+func (s *Server) noteDrop(conn *Connection) {
+	atomic.AddUint32(&conn.outDropped, 1)
+	atomic.AddUint64(&conn.dropsTotal, 1)
+	metrics.BroadcastsDropped.Inc()
+}
+
+// nextOutSeq returns the sequence for the next written message. Write loop only.
+func (c *Connection) nextOutSeq() uint32 {
+	c.outSeq += 1 + atomic.SwapUint32(&c.outDropped, 0)
+	return c.outSeq
+}
+
+// appendJobFrames appends the buffers to write for jobs: control frames as-is, data
+// payloads behind a per-connection [WS header][seq] (plus the CRC with checksums)
+// carved from hdrBuf. With compression on, frames go out deflated (the shared
+// tickFrame.deflated, or direct payloads compressed here); the CRC is always of the
+// uncompressed message. Sealed types are encrypted here (sealed.go), so their
+// counters follow the write order.
+// hdrBuf must have room for len(jobs)*maxFrameHeaderSize bytes so it never reallocates.
+func (c *Connection) appendJobFrames(frames [][]byte, hdrBuf []byte, jobs []writeJob) [][]byte {
+	hdrBuf = hdrBuf[:0]
+	for i := range jobs {
+		job := &jobs[i]
+		if job.control != nil {
+			frames = append(frames, job.control)
+			continue
+		}
+		payload, deflated, frame := job.direct, []byte(nil), job.frame
+		if frame != nil {
+			payload, deflated = frame.data, frame.deflated
+		}
+		sealed := false
+		if c.seal != nil {
+			payload, sealed = c.seal.sealOut(payload)
+		}
+		if sealed {
+			deflated, frame = nil, nil // per connection now; ciphertext does not compress
+		} else if frame == nil && c.compressMin > 0 && len(payload) >= c.compressMin {
+			if deflated = appendDeflate(nil, payload); !deflateSaves(payload, deflated) {
+				deflated = nil
+			}
+		}
+		var sum uint32
+		if c.checksum {
+			if frame != nil {
+				sum = frame.checksum()
+			} else {
+				sum = protocol.Checksum(payload)
+			}
+		}
+		start := len(hdrBuf)
+		if c.compressMin > 0 && len(deflated) > 0 {
+			hdrBuf = appendDeflateFrameHeader(hdrBuf, len(deflated), c.nextOutSeq(), c.checksum, sum)
+			deflateRawBytes.Add(float64(len(payload)))
+			deflateCompressedBytes.Add(float64(len(deflated) + deflatePrefixSize))
+			payload = deflated
+		} else {
+			hdrBuf = appendFrameHeader(hdrBuf, len(payload), c.nextOutSeq(), c.checksum, sum)
+		}
+		frames = append(frames, hdrBuf[start:len(hdrBuf):len(hdrBuf)], payload)
+	}
+	return frames
+}
+
+var (
+	checksumFailuresIn  = metrics.ChecksumFailures.WithLabelValues("inbound")
+	checksumFailuresOut = metrics.ChecksumFailures.WithLabelValues("outbound")
+)
+
+// handleSequenceReport records client-observed loss and serves resync requests.
+func (s *Server) handleSequenceReport(conn *Connection, msg *protocol.ClientMessage) {
+	if msg.Corrupted > 0 {
+		checksumFailuresOut.Add(float64(msg.Corrupted))
+	}
+	if msg.Missed > 0 {
+		metrics.ClientReportedMissed.Add(float64(msg.Missed))
+	}
+	metrics.ClientReportedMissedPerReport.Observe(float64(msg.Missed))
+
+	if !msg.Resync {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&conn.lastResyncNs)
+	if now-last < resyncMinInterval.Nanoseconds() || !atomic.CompareAndSwapInt64(&conn.lastResyncNs, last, now) {
+		metrics.ClientResyncs.WithLabelValues("throttled").Inc()
+		return
+	}
+	metrics.ClientResyncs.WithLabelValues("sent").Inc()
+	s.sendInitialState(conn)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+// writeJobs frames jobs for c the way the write loop does and returns what the
+// client would decode.
+func writeJobs(t *testing.T, c *Connection, jobs ...writeJob) []testutil.Frame {
+	t.Helper()
+	fake := testutil.NewFakeConn()
+	frames := c.appendJobFrames(nil, make([]byte, 0, len(jobs)*maxFrameHeaderSize), jobs)
+	buffers := net.Buffers(frames)
+	if _, err := buffers.WriteTo(fake); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(jobs) {
+		t.Fatalf("decoded %d frames, want %d", len(got), len(jobs))
+	}
+	return got
+}
+
+// checkDataFrame asserts f is a binary frame [seq][payload].
+func checkDataFrame(t *testing.T, f testutil.Frame, seq uint32, payload []byte) {
+	t.Helper()
+	if f.OpCode != ws.OpBinary || len(f.Payload) < seqHeaderSize {
+		t.Fatalf("frame = %+v, want a binary data frame", f)
+	}
+	if got := binary.LittleEndian.Uint32(f.Payload); got != seq {
+		t.Errorf("seq = %d, want %d", got, seq)
+	}
+	if !bytes.Equal(f.Payload[seqHeaderSize:], payload) {
+		t.Errorf("payload = % x, want % x", f.Payload[seqHeaderSize:], payload)
+	}
+}
+
+func TestAppendJobFramesSequence(t *testing.T) {
+	c := &Connection{}
+	pong, err := ws.CompileFrame(ws.NewPongFrame([]byte("hi")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := bytes.Repeat([]byte{0x07}, 70000) // 64-bit length header
+	shared := &tickFrame{data: []byte{0x0E, 1, 2, 3}, refs: 1}
+
+	frames := writeJobs(t, c,
+		writeJob{direct: []byte{0x0B, 0xAA}},
+		writeJob{control: pong},
+		writeJob{frame: shared},
+		writeJob{direct: big},
+	)
+	checkDataFrame(t, frames[0], 1, []byte{0x0B, 0xAA})
+	if frames[1].OpCode != ws.OpPong || string(frames[1].Payload) != "hi" {
+		t.Errorf("control frame = %+v, want the pong as-is", frames[1])
+	}
+	checkDataFrame(t, frames[2], 2, shared.data)
+	checkDataFrame(t, frames[3], 3, big)
+
+	// Messages dropped before the write loop leave a gap the client can see.
+	c.outDropped = 2
+	checkDataFrame(t, writeJobs(t, c, writeJob{direct: []byte{0x0C}})[0], 6, []byte{0x0C})
+}
+
+func TestAppendJobFramesDeflate(t *testing.T) {
+	payload := bytes.Repeat([]byte("player state "), 64)
+	shared := &tickFrame{data: payload}
+	shared.deflated = appendDeflate(nil, payload)
+	if !deflateSaves(payload, shared.deflated) {
+		t.Fatal("test payload does not compress")
+	}
+
+	// Two connections share the compressed tick frame, each with its own sequence.
+	a, b := &Connection{compressMin: 1}, &Connection{compressMin: 1, outSeq: 40}
+	for _, tc := range []struct {
+		c   *Connection
+		seq uint32
+	}{{a, 1}, {b, 41}} {
+		f := writeJobs(t, tc.c, writeJob{frame: shared})[0]
+		if !f.Compressed {
+			t.Fatal("shared frame sent uncompressed")
+		}
+		checkDataFrame(t, f, tc.seq, payload)
+	}
+
+	// Direct payloads are compressed per connection above compressMin only.
+	c := &Connection{compressMin: 100}
+	frames := writeJobs(t, c, writeJob{direct: payload}, writeJob{direct: []byte{0x0B, 1}})
+	if !frames[0].Compressed || frames[1].Compressed {
+		t.Errorf("compressed = %v/%v, want true/false", frames[0].Compressed, frames[1].Compressed)
+	}
+	checkDataFrame(t, frames[0], 1, payload)
+	checkDataFrame(t, frames[1], 2, []byte{0x0B, 1})
+
+	// Connections without compression ignore the shared deflated copy.
+	if f := writeJobs(t, &Connection{}, writeJob{frame: shared})[0]; f.Compressed {
+		t.Error("uncompressed connection got a deflated frame")
+	}
+}
+
+func TestAppendJobFramesChecksum(t *testing.T) {
+	payload := bytes.Repeat([]byte("player state "), 64)
+	shared := &tickFrame{data: payload, deflated: appendDeflate(nil, payload)}
+
+	// [seq][crc][message], the CRC over the uncompressed message in both cases.
+	for _, c := range []*Connection{{checksum: true}, {checksum: true, compressMin: 1}} {
+		frames := writeJobs(t, c, writeJob{frame: shared}, writeJob{direct: []byte{0x0B, 1}})
+		if frames[0].Compressed != (c.compressMin > 0) {
+			t.Errorf("compressed = %v with compressMin %d", frames[0].Compressed, c.compressMin)
+		}
+		for i, want := range [][]byte{payload, {0x0B, 1}} {
+			if seq := binary.LittleEndian.Uint32(frames[i].Payload); seq != uint32(i+1) {
+				t.Errorf("seq = %d, want %d", seq, i+1)
+			}
+			msg, err := protocol.VerifyChecksum(frames[i].Payload[seqHeaderSize:])
+			if err != nil || !bytes.Equal(msg, want) {
+				t.Errorf("frame %d: message % x, %v; want % x", i, msg, err, want)
+			}
+		}
+	}
+	if shared.checksum() != protocol.Checksum(payload) {
+		t.Error("cached frame checksum differs")
+	}
+}
+
+func TestProcessMessageChecksum(t *testing.T) {
+	s := newSessionServer(t, "")
+	c := s.createConnection(testutil.NewFakeConn())
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy | protocol.CapChecksum})
+
+	report := []byte{protocol.MessageSequenceReport, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	s.processMessage(c, protocol.AppendChecksummed(nil, report))
+	bad := protocol.AppendChecksummed(nil, report)
+	bad[len(bad)-1] ^= 0x01
+	s.processMessage(c, bad)
+	s.processMessage(c, report) // no checksum at all
+
+	c.traffic.mu.Lock()
+	reports, invalid := c.traffic.total[trafficSequenceReport], c.traffic.total[trafficInvalid]
+	c.traffic.mu.Unlock()
+	if reports != 1 || invalid != 2 {
+		t.Errorf("sequence reports = %d, invalid = %d; want 1 and 2", reports, invalid)
+	}
+}
+
+func TestInflate(t *testing.T) {
+	msg := []byte{0x01, 0x03, 0x00, 0x10, 0x00, 0x00}
+	c := &Connection{deflate: true}
+	got, err := c.inflate(appendDeflate(nil, msg))
+	if err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("inflate = % x, %v; want % x", got, err, msg)
+	}
+	if _, err := (&Connection{}).inflate(appendDeflate(nil, msg)); err == nil {
+		t.Error("inflate accepted RSV1 without negotiated permessage-deflate")
+	}
+	bomb := appendDeflate(nil, make([]byte, maxInflatedSize+1))
+	if _, err := c.inflate(bomb); err == nil {
+		t.Error("inflate accepted a message over maxInflatedSize")
+	}
+}
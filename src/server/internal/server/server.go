@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net"
@@ -9,34 +11,163 @@ import (
 	_ "net/http/pprof" // registers /debug/pprof/* handlers on DefaultServeMux
 	"os"
 	"runtime"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/gobwas/ws"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/time/rate"
 
+	"pixi_game_server/internal/alerts"
+	"pixi_game_server/internal/assets"
 	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/console"
 	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/inventory"
+	"pixi_game_server/internal/leaderboard"
 	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/moderation"
+	"pixi_game_server/internal/party"
+	"pixi_game_server/internal/persist"
 	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/scripting"
+	"pixi_game_server/internal/social"
 	"pixi_game_server/internal/types"
+	"pixi_game_server/internal/worldmap"
 )
 
 // Server основной сервер игры
 type Server struct {
 	cfg       *config.Config
 	gameWorld *game.GameWorld
+	bots      *game.BotManager
 	protocol  *protocol.BinaryProtocol
+	// Encoder for protocol.LegacySubprotocol clients: whole-unit positions.
+	legacyProtocol *protocol.BinaryProtocol
+	largeWorld     bool   // wire positions relative to a per-connection origin (largeworld.go)
+	worldSeed      uint32 // CONFIG worldSeed: the layout was generated from it (worldmap.Generate)
 
 	// Connection management
 	connectionsMu sync.RWMutex
 	connections   map[uint32]*Connection // playerID → *Connection
+	sessions      map[string]*Connection // account → connection owning it (see session.go)
+	authSecret    []byte                 // session token key; nil = auth disabled
+	sealedTypes   [256]bool              // message types sealed for CapEncryption clients (see sealed.go)
+	sealedList    []uint8                // the same types, as sent in CIPHER_INIT
 	rh            readHandler            // epoll (Linux) or goroutine-per-conn (other) read strategy
 
-	// Rate limiting
-	rateLimiters sync.Map // map[string]*rate.Limiter
+	// Connection-attempt budgets (see ratelimit.go)
+	ipLimiters      limiterTable // anonymous and failed-auth attempts per IP
+	authIPLimiters  limiterTable // authenticated attempts per IP
+	accountLimiters limiterTable // authenticated attempts per account
+
+	// Bans, mutes and their audit log (see moderation.go)
+	moderation *moderation.Store
+
+	// Kills and damage per player (see leaderboard.go)
+	leaderboard *leaderboard.Board
+
+	// Parties; nil = disabled. partyMu serializes changes and their rosters (see party.go)
+	partyMu sync.Mutex
+	parties *party.Registry
+
+	// Friend lists and presence. friendsMu serializes changes and FRIEND_UPDATEs and
+	// guards friendsOnline (see friends.go)
+	friends       *social.Store
+	friendsMu     sync.Mutex
+	friendsOnline map[string]*Connection // account → its joined connection
+
+	emotes *emoteCatalog // by emote ID (see emote.go)
+
+	// Inventories and trades. tradeMu serializes trade changes and their states (see
+	// inventory.go)
+	inventory *inventory.Store
+	tradeMu   sync.Mutex
+	trades    *inventory.Trades
+
+	// Player records of signed-in players, written behind (see persist.go)
+	records     *persist.Store
+	recordQueue *persist.Queue
+
+	// Worlds of the tenants hosted next to this one, by API and admin key hash; set
+	// before Start and read-only after. tenantName and tenantKey (the public API key)
+	// are set on a tenant's own Server (see tenants.go)
+	tenantName        string
+	tenantKey         string
+	tenants           []*tenantServer
+	tenantsByKey      map[[sha256.Size]byte]*tenantServer
+	tenantsByAdminKey map[[sha256.Size]byte]*tenantServer
+
+	// Resource usage for reports and fair-use quotas (see usage.go)
+	usage usageCounters
+
+	// Abuse detection (see abuse.go)
+	abuseLimits  abuseLimits
+	abuseHistory abuseHistory
+
+	// Disconnect causes over the last hour, for /stats (see disconnectstats.go)
+	disconnects disconnectStats
+
+	// Chat console: commands and account roles (see chat.go)
+	console *console.Registry
+	roles   map[string]console.Role
+
+	// Gameplay scripts; nil = no SCRIPTS_DIR (see scripting.go)
+	scripts *scripting.Engine
+
+	// ALLOWED_ORIGINS for /ws; nil = any (see origin.go)
+	origins *allowedOrigins
+
+	// Message of the day by language; nil = none (see announce.go)
+	motd localizedText
+
+	// Session summaries for Server.SessionWebhookURL; nil = no webhook (see sessionstats.go)
+	sessionHooks chan SessionSummary
+
+	// Live world view for dashboards (see worldview.go)
+	worldView        worldViewCache
+	worldViewStreams int32 // open /world/stream connections (atomic)
+
+	// Half-open connections awaiting JOIN (see handshake.go)
+	pendingHandshakes int32 // atomic
+
+	// JOINs waiting for a slot on a full server (see loginqueue.go)
+	loginQueue *loginQueue
+
+	// Optional sends paused while the game loop drops ticks (see overload.go)
+	shedding atomic.Bool
+
+	// Maintenance mode (see maintenance.go)
+	maint maintenanceState
+
+	// Scheduled restarts and maintenance windows (see scheduled.go)
+	scheduled        []*scheduledJob
+	scheduleWarnings []time.Duration // longest first
+	exitCode         int32           // atomic; ExitRestart after a scheduled restart
+
+	// Listener and blue/green handover (see handover.go)
+	httpMu      sync.Mutex
+	httpServers []*http.Server // game listener first (see listeners.go)
+	listener    net.Listener   // inherited from the previous process, nil = bind in Start
+	tookOver    bool           // world state came from the previous process
+
+	// MOVEMENT_ACK coalescing (see moveack.go)
+	ackMu       sync.Mutex
+	pendingAcks []*Connection // connections with an ACK queued for this tick
+	ackFlushBuf []*Connection // spare buffer swapped in by flushMoveAcks
+
+	// Viewport (AOI) broadcast scratch — gameLoop goroutine only (see aoi.go)
+	aoiConns    []*Connection
+	aoiScratch  []types.PlayerState
+	capConns    []*Connection    // recipients needing capability-specific frames (capabilities.go)
+	legacyConns [2][]*Connection // capConns served with legacy positions: [0] full, [1] delta
+	cellIndex   cellIndex        // players by stream cell, built on demand once per tick (streaming.go)
+
+	// Interest scoring scratch — gameLoop goroutine only (see interest.go)
+	interestScratch []scoredEntity
+	interestIndex   playerIndex         // players by ID, built on demand once per tick
+	interestSeen    map[uint32]struct{} // players already in the delta being cut
 
 	// Server state
 	ctx    context.Context
@@ -55,9 +186,11 @@ type Server struct {
 	fanoutWorkers                  int
 	fanoutJobs                     chan fanoutJob
 	fanoutDropLimit                int32
-	writeBatchSize                 int
+	writeBatchSize                 int // jobs one write loop can hold: the largest class Size
+	writeBatches                   writeBatchLimits
 	fanoutMaxBroadcastBytesPerTick int
 	fanoutQueueShedDepth           int
+	clientBandwidthCap             int64 // bytes/sec per connection; 0 = unlimited
 	fanoutFairDebtMax              int32
 	fanoutFairDebtInc              int32
 	fanoutFairDebtDec              int32
@@ -78,12 +211,27 @@ type Server struct {
 	activeWindowNs       int64
 	lastFanoutTuneLog    int64 // atomic UnixNano timestamp
 
+	// Deadlines and keepalive (NetworkConfig, with fallbacks applied in New)
+	broadcastWriteTimeout time.Duration
+	directWriteTimeout    time.Duration
+	maxWriteFailures      int32
+	readFrameTimeout      time.Duration
+	readLimit             int64 // see readlimit.go
+	readBufSize           int   // see connbuf.go
+	readBufs              sync.Pool
+	writeScratch          sync.Pool
+	pingInterval          time.Duration
+	pongTimeout           time.Duration
+
 	// Performance monitoring
 	startTime time.Time
+	history   *metrics.History // /metrics/history; nil = disabled
+	alerts    *alerts.Monitor  // see alerting.go
 }
 
 // Connection represents a WebSocket client connection.
 // rawConn is the hijacked net.Conn returned by gobwas/ws after the HTTP upgrade.
+// player is nil until the client completes the JOIN handshake (see handshake.go).
 //
 // Write path: all writes are sent to writeCh and processed by a single persistent
 // write-loop goroutine (startWriteLoop). Because only one goroutine writes to rawConn,
@@ -95,22 +243,70 @@ type Connection struct {
 	rawConn              net.Conn
 	fd                   int // OS file descriptor (used by epoll remove)
 	rateLimiter          *rate.Limiter
-	writeCh              chan writeJob // buffered channel drained by startWriteLoop goroutine
-	closeOnce            sync.Once     // ensures cleanupConnection body runs once
-	lastActivity         int64         // UnixNano, updated on each received frame (atomic)
-	writeFailures        int32         // consecutive write timeouts/errors (atomic); reset on success
-	fanoutDrops          int32         // consecutive dropped broadcast enqueues (atomic)
-	fanoutFairDebt       int32         // anti-starvation debt for recipient selection fairness (atomic)
-	fanoutDebtEpoch      uint32        // marks whether conn was selected in the current fairness epoch
-	pendingBroadcast     int32         // 0/1: whether a world-state broadcast job is already queued/in-flight
-	lastWorldStateSentNs int64         // UnixNano timestamp of last successfully enqueued world-state frame
-	criticalUntilNs      int64         // UnixNano until which this client receives criticality boost
+	rateLimited          bool           // messages are being dropped by rateLimiter (read path only, rejections.go)
+	writeCh              chan writeJob  // buffered channel drained by startWriteLoop goroutine
+	closeOnce            sync.Once      // ensures cleanupConnection body runs once
+	lastActivity         int64          // UnixNano, updated on each received frame (atomic)
+	writeFailures        int32          // consecutive write timeouts/errors (atomic); reset on success
+	fanoutDrops          int32          // consecutive dropped broadcast enqueues (atomic)
+	fanoutFairDebt       int32          // anti-starvation debt for recipient selection fairness (atomic)
+	fanoutDebtEpoch      uint32         // marks whether conn was selected in the current fairness epoch
+	pendingBroadcast     int32          // 0/1: whether a world-state broadcast job is already queued/in-flight
+	lastWorldStateSentNs int64          // UnixNano timestamp of last successfully enqueued world-state frame
+	criticalUntilNs      int64          // UnixNano until which this client receives criticality boost
+	pendingAck           uint64         // latest coalesced MOVEMENT_ACK, packed by packMoveAck (atomic)
+	ackQueued            int32          // 0/1: whether conn is in Server.pendingAcks (atomic)
+	correction           int32          // 0/1: the queued ACK is a correction, sent reliable (atomic, see reliable.go)
+	state                int32          // handshake state connAwaitingJoin..connClosed (atomic)
+	handshakeTimer       *time.Timer    // closes the connection if JOIN does not arrive in time
+	outSeq               uint32         // last outbound sequence written (write loop only, see sequence.go)
+	outDropped           uint32         // messages dropped since the last write (atomic)
+	lastResyncNs         int64          // UnixNano of the last resync served (atomic)
+	updateTier           int32          // bandwidth update tier, 0 = every delta (atomic, see bandwidth.go)
+	bwWindowStartNs      int64          // start of the current bandwidth window (write loop only)
+	bwWindowBytes        int64          // bytes written in the current window (write loop only)
+	deflate              bool           // permessage-deflate negotiated at upgrade
+	caps                 uint8          // protocol.Cap* from JOIN (see capabilities.go)
+	legacyPositions      bool           // protocol.LegacySubprotocol client of a server sending fractions (protocolFor)
+	maxMessageSize       int            // largest world-state message the client accepts; 0 = unlimited
+	compressMin          int            // > 0: deflate data messages of at least this many bytes
+	checksum             bool           // CRC-32C on every message both ways (protocol.CapChecksum)
+	ip                   string         // client IP (RemoteAddr host), for logs and abuse reports
+	inProcess            bool           // served over an in-memory transport, read by a goroutine (see inprocess.go)
+	account              string         // account ID from the session token; "" = anonymous (see session.go)
+	vip                  bool           // the token carries auth.FlagVIP (see loginqueue.go)
+	locale               string         // client language from /ws?lang= (primary subtag, "ru"); "" = default (see announce.go)
+	role                 console.Role   // console command permissions, from Server.Roles by account (see chat.go)
+	token                string         // the session token itself; its auth.SessionKey keys sealed messages
+	seal                 *sealState     // nil = nothing sealed (see sealed.go)
+	stream               *cellStream    // nil = no cell streaming (see streaming.go)
+	viewport             *viewportState // nil = viewport updates not rate-limited (see viewport.go)
+	reliable             *reliableState // nil = critical messages sent plain (see reliable.go)
+	interest             *interestState // nil = no delta cut yet (gameLoop only, see interest.go)
+	traffic              *trafficStats  // per-kind message counters and abuse strikes (see abuse.go)
+	bytesIn              int64          // data bytes received (atomic, see sessionstats.go)
+	bytesOut             int64          // bytes written (atomic)
+	dropsTotal           uint64         // messages dropped for this client, never reset (atomic)
+	pingSentNs           int64          // UnixNano of the unanswered timed ping; 0 = none (atomic)
+	rttSumNs             int64          // sum of measured ping RTTs (atomic)
+	rttSamples           int64          // measured ping RTTs (atomic)
+	closing              int32          // 0/1: a close frame is queued, see closeConnection (atomic)
+	disconnectCause      int32          // why the connection ends, first recorded wins (atomic, see disconnectstats.go)
 	ctx                  context.Context
 	cancel               context.CancelFunc
+
+	party      atomic.Pointer[party.Party] // nil = not in a party; replaced, never modified (see party.go)
+	friendZone int                         // zone last announced to friends, -1 = none; guarded by Server.friendsMu (see friends.go)
+
+	emoteReadyNs int64    // UnixNano before which an EMOTE is refused (read path only, emote.go)
+	emoteScratch []uint32 // players near the emoter, reused (read path only)
+
+	origin   atomic.Pointer[protocol.BinaryProtocol] // large worlds: encoder relative to the last ORIGIN, nil otherwise (see largeworld.go)
+	originMu sync.Mutex                              // held from encoding to enqueueing off the gameLoop (lockOrigin)
 }
 
-// New создает новый сервер
-func New(cfg *config.Config) *Server {
+// New создает новый сервер. worldMap — загруженная карта Tiled или nil.
+func New(cfg *config.Config, worldMap *worldmap.Map) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Auto-detect worker count
@@ -119,13 +315,21 @@ func New(cfg *config.Config) *Server {
 	}
 
 	server := &Server{
-		cfg:         cfg,
-		gameWorld:   game.NewGameWorld(cfg),
-		protocol:    &protocol.BinaryProtocol{},
-		connections: make(map[uint32]*Connection, 4096),
-		ctx:         ctx,
-		cancel:      cancel,
-		startTime:   time.Now(),
+		cfg:            cfg,
+		gameWorld:      game.NewGameWorld(cfg, worldMap),
+		protocol:       &protocol.BinaryProtocol{PositionBits: uint8(cfg.Net.PositionFractionBits)},
+		legacyProtocol: &protocol.BinaryProtocol{},
+		largeWorld:     config.LargeWorld(cfg.World.Width, cfg.World.Height, cfg.Net.PositionFractionBits),
+		connections:    make(map[uint32]*Connection, 4096),
+		sessions:       make(map[string]*Connection),
+		loginQueue:     newLoginQueue(),
+		ctx:            ctx,
+		cancel:         cancel,
+		startTime:      time.Now(),
+	}
+
+	if worldMap != nil {
+		server.worldSeed = worldMap.Seed
 	}
 
 	if cfg.Game.BatchInterval > 0 {
@@ -137,14 +341,40 @@ func New(cfg *config.Config) *Server {
 	if server.fanoutDropLimit < 1 {
 		server.fanoutDropLimit = 1
 	}
-	server.writeBatchSize = cfg.Net.WriteBatchSize
-	if server.writeBatchSize < 1 {
-		server.writeBatchSize = 1
-	}
+	server.writeBatches = newWriteBatchLimits(&cfg.Net)
+	server.writeBatchSize = server.writeBatches.capacity()
 	server.fanoutMaxBroadcastBytesPerTick = cfg.Net.FanoutMaxBroadcastBytesPerTick
 	if server.fanoutMaxBroadcastBytesPerTick < 0 {
 		server.fanoutMaxBroadcastBytesPerTick = 0
 	}
+	server.clientBandwidthCap = int64(cfg.Net.ClientBandwidthCap)
+	if server.clientBandwidthCap < 0 {
+		server.clientBandwidthCap = 0
+	}
+	metrics.ClientBandwidthCapBytes.Set(float64(server.clientBandwidthCap))
+	server.initConnLimiters()
+	server.abuseLimits = abuseLimits{
+		maxMovesPerSec:    cfg.Net.AbuseMaxMovesPerSec,
+		maxMessagesPerSec: cfg.Net.AbuseMaxMessagesPerSec,
+		maxInvalidPerSec:  cfg.Net.AbuseMaxInvalidPerSec,
+	}
+	if cfg.Server.AuthSecret != "" {
+		server.authSecret = []byte(cfg.Server.AuthSecret)
+	} else if cfg.Server.AuthRequired {
+		slog.Warn("AUTH_REQUIRED without AUTH_SECRET: auth disabled, all players are anonymous")
+	}
+	mod, err := moderation.Open(cfg.Server.ModerationLog)
+	if err != nil {
+		// The bans in the log are not enforced until it is fixed and the server restarted.
+		slog.Error("moderation log unreadable, starting without bans and mutes", "path", cfg.Server.ModerationLog, "error", err)
+		mod, _ = moderation.Open("")
+	}
+	server.moderation = mod
+	server.initSealedTypes()
+	if p := cfg.Server.DuplicateSessionPolicy; p != config.SessionTakeover && p != config.SessionReject {
+		slog.Warn("unknown SESSION_DUPLICATE_POLICY, using takeover", "policy", p)
+		cfg.Server.DuplicateSessionPolicy = config.SessionTakeover
+	}
 	server.fanoutQueueShedDepth = cfg.Net.FanoutQueueShedDepth
 	if server.fanoutQueueShedDepth < 1 {
 		server.fanoutQueueShedDepth = 0
@@ -209,133 +439,322 @@ func New(cfg *config.Config) *Server {
 		metrics.FanoutRecipientLimit.Set(0)
 	}
 
+	server.broadcastWriteTimeout = cfg.Net.BroadcastWriteTimeout
+	if server.broadcastWriteTimeout <= 0 {
+		server.broadcastWriteTimeout = defaultBroadcastWriteTimeout
+	}
+	server.directWriteTimeout = cfg.Net.DirectWriteTimeout
+	if server.directWriteTimeout <= 0 {
+		server.directWriteTimeout = defaultDirectWriteTimeout
+	}
+	server.maxWriteFailures = int32(cfg.Net.MaxWriteFailures)
+	if server.maxWriteFailures < 1 {
+		server.maxWriteFailures = defaultMaxWriteFailures
+	}
+	server.readFrameTimeout = cfg.Net.ReadFrameTimeout
+	if server.readFrameTimeout <= 0 {
+		server.readFrameTimeout = 100 * time.Millisecond
+	}
+	server.readLimit = readLimitFor(cfg.Net.ReadLimit)
+	server.readBufSize = min(max(cfg.Net.ReadBufferSize, 0), int(server.readLimit))
+	server.pingInterval = cfg.Net.PingInterval
+	if server.pingInterval <= 0 {
+		server.pingInterval = 30 * time.Second
+	}
+	server.pongTimeout = cfg.Net.PongTimeout
+	if server.pongTimeout < server.pingInterval {
+		server.pongTimeout = 3 * server.pingInterval
+	}
+
 	server.initFanoutWorkers()
 
 	// Start ping/keepalive loop (replaces per-shard ping ticker).
 	go server.runPingLoop()
 
+	// Coarse density grid for clients with a minimap (see minimap.go).
+	if cfg.Net.MinimapInterval > 0 {
+		go server.runMinimapLoop()
+	}
+
+	// Scores of connected players, flushed to Redis or memory (see leaderboard.go).
+	server.initLeaderboard()
+
+	motd, err := loadMOTD(cfg.Server.MOTD, cfg.Server.MOTDFile)
+	if err != nil {
+		slog.Error("failed to load MOTD_FILE, only MOTD is used", "error", err)
+		motd, _ = loadMOTD(cfg.Server.MOTD, "")
+	}
+	server.motd = motd
+	server.origins = parseOrigins(cfg.Server.AllowedOrigins)
+	server.initConsole()
+	server.initParties()
+	server.initEmotes()
+	server.initSchedule()
+	server.initScripts()
+
+	// Session summaries to an analytics endpoint (see sessionstats.go).
+	if cfg.Server.SessionWebhookURL != "" {
+		server.sessionHooks = make(chan SessionSummary, sessionWebhookQueue)
+		go server.runSessionWebhook()
+	}
+
+	// Retransmission of unacked RELIABLE messages (see reliable.go).
+	if cfg.Net.ReliableRetry > 0 {
+		go server.runReliableLoop()
+	}
+
 	// Инициализируем read-хендлер (epoll на Linux, goroutine на других платформах).
 	server.rh = newReadHandler(server)
 
 	// Регистрируем tick-driven broadcast: состояние кодируется один раз в тик, разосылается всем.
 	server.gameWorld.SetTickBroadcaster(server.broadcastTick)
 
+	// Input timeout: корректируем клиента, которого сервер остановил из-за потери MOVE.
+	server.gameWorld.SetInputTimeoutHandler(server.handleInputTimeout)
+
+	// Coalesced MOVEMENT_ACKs уходят раз в тик, скрипты получают onTick.
+	server.gameWorld.SetPostTickHook(server.postTick)
+
+	// Перегрузка gameLoop: пока он теряет тики, minimap и leaderboard не рассылаются.
+	server.gameWorld.SetDegradationHandler(server.onDegradation)
+
+	// Глобальные события мира (ночь, шторм, объявления) уходят всем клиентам.
+	server.gameWorld.SetWorldEventHandler(server.broadcastWorldEvent)
+
+	// Попадания атак: HIT атакующему (подтверждение) и жертве (урон, отбрасывание).
+	server.gameWorld.SetHitHandler(server.onHit)
+
+	// Боты живут в том же мире; о появлении/уходе сообщаем как о живых игроках.
+	server.bots = game.NewBotManager(server.gameWorld, cfg)
+	server.bots.SetNotifiers(server.notifyPlayerJoined, server.notifyPlayerLeft)
+
+	// Blue/green deploy: забираем мир у запущенного процесса; если его нет — стартуем с нуля.
+	if path := cfg.Server.HandoverSocket; path == "" || !server.takeOver(path) {
+		server.bots.Spawn(cfg.Game.BotCount)
+	}
+	// The logs are replayed only now: a process we took over from closed them before
+	// handing its state over, so they hold everything it wrote.
+	server.initFriends()
+	server.initInventory()
+	server.initPersistence()
+	go server.bots.Run(ctx)
+
 	// Start performance monitoring
 	go server.performanceMonitor()
+	server.history = metrics.NewHistory(cfg.Server.MetricsHistoryWindow, cfg.Server.MetricsHistoryInterval)
+	go server.history.Run(ctx)
+	server.initAlerts()
+	go server.alerts.Run(ctx)
 
 	return server
 }
 
 // Start запускает сервер
 func (s *Server) Start() error {
-	mux := http.NewServeMux()
-
-	// WebSocket endpoint
-	mux.HandleFunc("/ws", s.handleWebSocket)
-
-	// Static files
-	mux.Handle("/", http.FileServer(http.Dir(s.cfg.Server.StaticDir)))
-
-	// Health check
-	mux.HandleFunc("/health", s.handleHealth)
+	// Instance description for server browsers is also pushed to the directory
+	if s.cfg.Server.DirectoryURL != "" {
+		go s.runDirectoryRegistration()
+	}
 
-	// Metrics endpoint (Prometheus format)
-	mux.Handle("/metrics", promhttp.Handler())
+	// Login queue status and admissions (see loginqueue.go)
+	if s.cfg.Net.LoginQueueSize > 0 {
+		go s.runLoginQueue()
+	}
 
-	// Legacy JSON metrics for backwards compat
-	mux.HandleFunc("/metrics/json", s.handleMetricsJSON)
+	// Usage of the host's and the tenants' worlds (see usage.go)
+	if len(s.tenants) > 0 && s.cfg.Server.UsageReport > 0 {
+		go s.runUsageReports()
+	}
 
-	// pprof endpoints — /debug/pprof/, /debug/pprof/trace, /debug/pprof/block etc.
 	// Block/mutex profiling enabled only when PPROF_BLOCK_RATE=1 (adds 10-30% CPU overhead).
 	if os.Getenv("PPROF_BLOCK_RATE") == "1" {
 		runtime.SetBlockProfileRate(1)     // record every blocking event
 		runtime.SetMutexProfileFraction(1) // record every mutex contention event
 	}
-	mux.Handle("/debug/pprof/", http.DefaultServeMux)
-	mux.Handle("/debug/pprof/cmdline", http.DefaultServeMux)
-	mux.Handle("/debug/pprof/profile", http.DefaultServeMux)
-	mux.Handle("/debug/pprof/symbol", http.DefaultServeMux)
-	mux.Handle("/debug/pprof/trace", http.DefaultServeMux)
-
-	// Periodically purge stale per-IP rate limiters to prevent unbounded memory growth.
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-			case <-ticker.C:
-				s.rateLimiters.Range(func(k, _ any) bool {
-					s.rateLimiters.Delete(k)
-					return true
-				})
-			}
-		}
-	}()
 
-	addr := fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port)
+	// nil after ErrServerClosed: мир передан новому процессу (shutdownAfterHandover).
+	return s.serveHTTP(s.routes())
+}
+
+// routes registers every endpoint on the listener of its group (see listeners.go).
+func (s *Server) routes() []*httpListener {
+	listeners, mux, adminMux, staticMux := s.httpListeners()
+	s.registerGameRoutes(mux)
 
-	slog.Info("server listening", "addr", addr)
-	slog.Info("serving static files", "dir", s.cfg.Server.StaticDir)
+	// Static files: the client build with SPA fallback and cache headers (internal/assets)
+	static, embedded := assets.Embedded(), true
+	if static == nil || !s.cfg.Server.StaticEmbedded {
+		static, embedded = os.DirFS(s.cfg.Server.StaticDir), false
+	}
+	staticMux.Handle("/", assets.Handler(static))
+	if embedded {
+		slog.Info("serving embedded static files")
+	} else {
+		slog.Info("serving static files", "dir", s.cfg.Server.StaticDir)
+	}
 
-	return http.ListenAndServe(addr, mux)
+	s.registerAdminRoutes(adminMux)
+	if len(s.tenants) > 0 {
+		s.routeTenants(listeners)
+	}
+	return listeners
 }
 
-// handleWebSocket обрабатывает WebSocket соединения
-func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Check connection limit before doing anything else.
-	s.connectionsMu.RLock()
-	connCount := len(s.connections)
-	s.connectionsMu.RUnlock()
-	if connCount >= s.cfg.Net.MaxConnections {
-		http.Error(w, "Server full", http.StatusServiceUnavailable)
-		return
+// registerGameRoutes registers the endpoints game clients use.
+func (s *Server) registerGameRoutes(mux *http.ServeMux) {
+	// WebSocket endpoint, behind the admission checks (middleware.go)
+	mux.Handle("/ws", chain(http.HandlerFunc(s.handleWebSocket), s.wsAdmission()...))
+
+	// Instance description for server browsers
+	mux.HandleFunc("/info", s.handleInfo)
+
+	// Top players and around-me queries (see leaderboard.go)
+	mux.HandleFunc("/leaderboard", s.handleLeaderboard)
+}
+
+// registerAdminRoutes registers the operator endpoints: dashboards, the admin API,
+// metrics and debug handlers. They go to the admin listener when ADMIN_ADDR is set.
+func (s *Server) registerAdminRoutes(mux *http.ServeMux) {
+	// Live world snapshot and SSE stream for dashboards; off unless WORLD_VIEW=1
+	if s.cfg.Server.WorldView {
+		mux.HandleFunc("/world", s.handleWorld)
+		mux.HandleFunc("/world/stream", s.handleWorldStream)
 	}
 
-	// Rate limiting by IP (RemoteAddr includes port — extract host only).
-	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		clientIP = r.RemoteAddr // fallback for unix sockets / tests
+	// Admin API (bots, maintenance, runtime tuning, abuse reports, moderation); registered only when ADMIN_TOKEN is set
+	if s.cfg.Server.AdminToken != "" {
+		mux.HandleFunc("/admin/bots", s.requireAdmin(s.handleAdminBots))
+		mux.HandleFunc("/admin/maintenance", s.requireAdmin(s.handleAdminMaintenance))
+		if s.tenantName == "" { // process-wide
+			mux.HandleFunc("/admin/runtime", s.requireAdmin(s.handleAdminRuntime))
+		}
+		mux.HandleFunc("/admin/world", s.requireAdmin(s.handleAdminWorld))
+		mux.HandleFunc("/admin/spawns", s.requireAdmin(s.handleAdminSpawns))
+		mux.HandleFunc("/admin/players", s.requireAdmin(s.handleAdminPlayers))
+		mux.HandleFunc("/admin/announce", s.requireAdmin(s.handleAdminAnnounce))
+		mux.HandleFunc("/admin/abuse", s.requireAdmin(s.handleAdminAbuse))
+		mux.HandleFunc("/admin/bans", s.requireAdmin(s.handleAdminSanctions(moderation.ActionBan, moderation.ActionUnban)))
+		mux.HandleFunc("/admin/mutes", s.requireAdmin(s.handleAdminSanctions(moderation.ActionMute, moderation.ActionUnmute)))
+		mux.HandleFunc("/admin/audit", s.requireAdmin(s.handleAdminAudit))
+		mux.HandleFunc("/admin/friends", s.requireAdmin(s.handleAdminFriends))
+		mux.HandleFunc("/admin/inventory", s.requireAdmin(s.handleAdminInventory))
+		mux.HandleFunc("/admin/usage", s.requireAdmin(s.handleAdminUsage))
+		if len(s.tenants) > 0 {
+			mux.HandleFunc("/admin/tenants", s.requireAdmin(s.handleAdminTenants))
+		}
 	}
-	limiter := s.getOrCreateRateLimiter(clientIP)
 
-	if !limiter.Allow() {
-		metrics.IPRateLimited.Inc()
-		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	// Disconnect causes over the last hour (see disconnectstats.go)
+	mux.HandleFunc("/stats", s.handleStats)
+
+	// Dev dashboard page over the endpoints below (dashboard.go)
+	mux.HandleFunc("/dashboard", s.handleDashboard)
+
+	// Metrics endpoint (Prometheus format); the tenants' worlds count into the host's
+	if s.tenantName == "" {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+
+	// Legacy JSON metrics for backwards compat
+	mux.HandleFunc("/metrics/json", s.handleMetricsJSON)
+
+	// Per-zone player counts and the busiest spatial-grid cells
+	if s.gameWorld.Zones() != nil {
+		mux.HandleFunc("/metrics/zones", s.handleMetricsZones)
+	}
+
+	// Recent key metrics as a compact time series for dashboard graphs
+	if s.history != nil {
+		mux.HandleFunc("/metrics/history", s.handleMetricsHistory)
+	}
+
+	// pprof endpoints — /debug/pprof/, /debug/pprof/trace, /debug/pprof/block etc.
+	if s.tenantName == "" {
+		mux.Handle("/debug/pprof/", http.DefaultServeMux)
+		mux.Handle("/debug/pprof/cmdline", http.DefaultServeMux)
+		mux.Handle("/debug/pprof/profile", http.DefaultServeMux)
+		mux.Handle("/debug/pprof/symbol", http.DefaultServeMux)
+		mux.Handle("/debug/pprof/trace", http.DefaultServeMux)
+	}
+
+	// Effective config (profile, env overrides, defaults) and paths on the collision
+	// grid. The config lists every setting, so it is served only behind ADMIN_TOKEN;
+	// paths stay open when no token is set.
+	if s.cfg.Server.AdminToken != "" {
+		mux.HandleFunc("/debug/config", s.requireAdmin(s.handleDebugConfig))
+		mux.HandleFunc("/debug/path", s.requireAdmin(s.handleDebugPath))
+	} else {
+		mux.HandleFunc("/debug/path", s.handleDebugPath)
+	}
+}
+
+// Shutdown disconnects everyone with reason and stops the world and the HTTP server,
+// so Start returns nil. Used for SIGTERM/SIGINT and scheduled restarts.
+func (s *Server) Shutdown(reason string) {
+	s.checkpointPlayers() // before the connections go: a leave may be handled after the flush
+	s.closeAll(protocol.CloseServerShutdown, reason, s.directWriteTimeout)
+
+	s.cancel()
+	s.gameWorld.Stop()
+	s.closeHTTP()
+	s.flushLeaderboard(context.Background()) // s.ctx is cancelled by now
+	s.leaderboard.Close()
+	s.flushRecords()
+	s.records.Close()
+	for _, t := range s.tenants {
+		t.srv.Shutdown(reason)
+	}
+}
+
+// handleWebSocket обрабатывает WebSocket соединения
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Capacity, maintenance, origin, auth and attempt budgets were checked by the
+	// wsAdmission chain (middleware.go).
+	client := wsClientFrom(r.Context())
+	clientIP, account := client.ip, client.account
+
+	// Half-open connection cap (slowloris-style join floods).
+	if !s.reserveHandshake() {
+		metrics.Handshakes.WithLabelValues("rejected").Inc()
+		http.Error(w, "Too many pending connections", http.StatusServiceUnavailable)
 		return
 	}
 
 	// Upgrade to WebSocket via gobwas/ws (hijacks the HTTP conn; no per-conn goroutine spawned).
-	// ws.UpgradeHTTP performs the Upgrade handshake and returns the hijacked net.Conn.
-	// Any origin is accepted (development / same-origin proxied).
-	rawConn, _, _, err := ws.UpgradeHTTP(r, w)
+	// s.upgrade performs the Upgrade handshake and returns the hijacked net.Conn.
+	// The Origin was checked by admitOrigin against ALLOWED_ORIGINS (origin.go).
+	subprotocol, subprotocolOK := s.negotiateSubprotocol(r)
+	rawConn, deflate, err := s.upgrade(r, w, subprotocol)
 	if err != nil {
+		s.releaseHandshake()
 		slog.Error("websocket upgrade failed", "error", err, "remote_addr", r.RemoteAddr)
 		metrics.WSUpgradeErrors.Inc()
 		return
 	}
+	if !subprotocolOK {
+		s.releaseHandshake()
+		s.rejectSubprotocol(rawConn, r)
+		return
+	}
+	if ban, banned := s.moderation.Banned(time.Now(), moderation.AccountTarget(account), moderation.IPTarget(clientIP)); banned {
+		s.releaseHandshake()
+		s.rejectBanned(rawConn, ban, clientIP)
+		return
+	}
 
-	// Create player and connection
-	player := s.gameWorld.AddPlayer()
-	connection := s.createConnection(player, rawConn)
-
-	// Send initial state BEFORE adding to s.connections so that the write loop
-	// delivers the full world snapshot as the very first message the client
-	// receives. If we add to the map first, a 30 Hz tick can race here and
-	// enqueue a delta/gamestate frame ahead of the initial state.
-	s.sendInitialState(connection)
-
-	s.connectionsMu.Lock()
-	s.connections[player.ID] = connection
-	s.connectionsMu.Unlock()
-
-	// Notify all existing players about the new player
-	s.notifyPlayerJoined(player)
-
-	// Update metrics
-	metrics.ConnectionsTotal.Inc()
-	metrics.PlayersConnected.Inc()
+	// No Player yet — it is allocated when the client sends JOIN (completeJoin).
+	connection := s.createConnection(rawConn)
+	connection.deflate = deflate
+	connection.legacyPositions = subprotocol != protocol.Subprotocol && s.protocol.PositionBits > 0
+	connection.ip = clientIP
+	connection.account = account
+	connection.vip = client.vip
+	if account != "" {
+		connection.token = r.URL.Query().Get("token")
+	}
+	connection.locale = normalizeLocale(r.URL.Query().Get("lang"))
+	connection.role = s.roleOf(account)
+	s.startHandshakeTimer(connection)
 
 	// Register with the read handler (epoll on Linux; goroutine on other platforms).
 	// No handleConnection goroutine is spawned here — this is the key change that
@@ -344,17 +763,18 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 // createConnection creates a new connection and starts its write-loop goroutine.
-func (s *Server) createConnection(player *types.Player, rawConn net.Conn) *Connection {
+func (s *Server) createConnection(rawConn net.Conn) *Connection {
 	ctx, cancel := context.WithCancel(s.ctx)
 
 	conn := &Connection{
-		player:  player,
 		rawConn: rawConn,
 		writeCh: make(chan writeJob, writeChanSize),
 		rateLimiter: rate.NewLimiter(
 			rate.Limit(s.cfg.Net.MessageRateLimit),
 			s.cfg.Net.BurstLimit,
 		),
+		viewport:             s.newViewportState(),
+		traffic:              newTrafficStats(s.cfg.Net.AbuseWindow),
 		lastActivity:         time.Now().UnixNano(),
 		lastWorldStateSentNs: time.Now().UnixNano(),
 		ctx:                  ctx,
@@ -364,15 +784,67 @@ func (s *Server) createConnection(player *types.Player, rawConn net.Conn) *Conne
 	return conn
 }
 
-// processMessage обрабатывает сообщение от клиента
+// processMessage обрабатывает сообщение от клиента.
+// INPUT_BATCH приходит уже развёрнутым в упорядоченные MOVE; ACK отправляется только
+// для последнего MOVE — промежуточные векторы всё равно перезаписываются до следующего тика.
 func (s *Server) processMessage(connection *Connection, message []byte) {
-	clientMsg, err := s.protocol.DecodeClientMessage(message)
+	if s.messageTooLarge(connection, message) {
+		return
+	}
+	if state := atomic.LoadInt32(&connection.state); state != connJoined {
+		// A taken-over session no longer controls the player; a queued one waits.
+		if state != connTransferred && state != connQueued {
+			s.handleHandshakeMessage(connection, message)
+		}
+		return
+	}
+
+	if connection.checksum {
+		var err error
+		if message, err = protocol.VerifyChecksum(message); err != nil {
+			slog.Warn("message checksum mismatch", "player_id", connection.player.ID, "ip", connection.ip)
+			checksumFailuresIn.Inc()
+			s.recordTraffic(connection, trafficInvalid)
+			return
+		}
+	}
+	message, err := s.unseal(connection, message)
+	if err != nil {
+		slog.Warn("sealed message rejected", "player_id", connection.player.ID, "ip", connection.ip, "error", err)
+		metrics.SealedRejected.Inc()
+		s.recordTraffic(connection, trafficInvalid)
+		return
+	}
+
+	clientMsgs, err := s.protocol.DecodeClientMessage(message)
 	if err != nil {
 		slog.Error("message decode failed", "player_id", connection.player.ID, "error", err)
+		s.recordTraffic(connection, trafficInvalid)
 		return
 	}
 
+	if len(clientMsgs) > 1 {
+		metrics.MessagesReceived.WithLabelValues("input_batch").Inc()
+	}
+
+	lastMove := -1
+	for i := range clientMsgs {
+		if clientMsgs[i].Type == protocol.MessageMove {
+			lastMove = i
+		}
+	}
+	for i := range clientMsgs {
+		s.handleClientMessage(connection, &clientMsgs[i], i == lastMove)
+	}
+}
+
+// handleClientMessage применяет одно декодированное сообщение.
+// ackMove=false подавляет MOVEMENT_ACK (промежуточный input внутри батча).
+func (s *Server) handleClientMessage(connection *Connection, clientMsg *protocol.ClientMessage, ackMove bool) {
 	connection.player.IncrementMessageCount()
+	if kind, ok := trafficKindOf(clientMsg.Type); ok {
+		s.recordTraffic(connection, kind)
+	}
 
 	switch clientMsg.Type {
 	case protocol.MessageMove:
@@ -380,44 +852,31 @@ func (s *Server) processMessage(connection *Connection, message []byte) {
 		s.markConnectionCritical(connection)
 
 		// Server-authoritative: process movement vector, server computes position
+		mv := clientMsg.MovementVector
+		throttleX, throttleY := mv.Throttle()
 		event := types.GameEvent{
 			PlayerID:   connection.player.ID,
 			Type:       types.EventMove,
-			VectorX:    clientMsg.MovementVector.DX,
-			VectorY:    clientMsg.MovementVector.DY,
+			VectorX:    mv.DX,
+			VectorY:    mv.DY,
+			ThrottleX:  throttleX,
+			ThrottleY:  throttleY,
 			ClientTick: clientMsg.InputSequence,
 		}
 		s.gameWorld.ProcessEvent(event)
-
-		// ACK with the position the client predicted (current + this move vector).
-		// The server will apply the same formula in its next tick.
-		// Sending this avoids false reconciliation: client delta = 0.
-		speed := int32(s.cfg.Game.PlayerSpeedPerTick)
-		dx := int32(clientMsg.MovementVector.DX)
-		dy := int32(clientMsg.MovementVector.DY)
-		ackX32 := int32(connection.player.GetX()) + dx*speed
-		ackY32 := int32(connection.player.GetY()) + dy*speed
-
-		// Clamp to world bounds (same as updatePlayerPosition)
-		if ackX32 > int32(s.cfg.World.MaxX) {
-			ackX32 = int32(s.cfg.World.MaxX)
-		} else if ackX32 < int32(s.cfg.World.MinX) {
-			ackX32 = int32(s.cfg.World.MinX)
-		}
-		if ackY32 > int32(s.cfg.World.MaxY) {
-			ackY32 = int32(s.cfg.World.MaxY)
-		} else if ackY32 < int32(s.cfg.World.MinY) {
-			ackY32 = int32(s.cfg.World.MinY)
+		if !ackMove {
+			return
 		}
 
-		// Send movement acknowledgment via shard directChan (priority over broadcast).
-		ackData := s.protocol.EncodeMovementAck(
-			connection.player.ID,
-			uint16(ackX32),
-			uint16(ackY32),
-			clientMsg.InputSequence,
-		)
-		s.sendDirect(connection, ackData)
+		// ACK with the position the client predicted (current + one physics step with
+		// this input). The server integrates the same way in its next tick, clamped to
+		// the world bounds. Sending this avoids false reconciliation: client delta = 0.
+		// The speed includes storms and admin operations (none while frozen), so those
+		// do get corrected.
+		ackX, ackY := s.gameWorld.PredictMove(connection.player, mv.DX, mv.DY, throttleX, throttleY)
+
+		// Send movement acknowledgment (coalesced to the latest one per tick).
+		s.queueMoveAck(connection, ackX, ackY, clientMsg.InputSequence)
 
 		// Обновление позиции разошлётся через tick broadcast, не здесь.
 
@@ -434,15 +893,69 @@ func (s *Server) processMessage(connection *Connection, message []byte) {
 	case protocol.MessageAttack:
 		metrics.MessagesReceived.WithLabelValues("attack").Inc()
 		s.markConnectionCritical(connection)
-		s.gameWorld.TryAttack(connection.player.ID)
-		// State=1 будет разослан всем через tick broadcast.
+		if rej := s.gameWorld.AttackToward(connection.player.ID, clientMsg.AimX, clientMsg.AimY); rej.Rejected() {
+			s.rejectAction(connection, protocol.MessageAttack, rej)
+		}
+		// StateAttacking будет разослан всем через tick broadcast, попадания — HIT (sendHit).
 
 	case protocol.MessageAttackEnd:
-		// Ignored: server is authoritative on attack duration.
+		metrics.MessagesReceived.WithLabelValues("attack_end").Inc()
+		// Ends the attack early (animation finished); the cooldown still runs from its
+		// start, and without ATTACK_END the attack expires after AttackDuration.
+		s.gameWorld.EndAttack(connection.player.ID)
 
 	case protocol.MessageViewportUpdate:
-		// Silently accepted — viewport-based culling not yet implemented.
+		metrics.MessagesReceived.WithLabelValues("viewport").Inc()
+		s.handleViewport(connection, clientMsg.ViewportWidth, clientMsg.ViewportHeight)
+
+	case protocol.MessageSequenceReport:
+		metrics.MessagesReceived.WithLabelValues("sequence_report").Inc()
+		s.handleSequenceReport(connection, clientMsg)
+
+	case protocol.MessageChat:
+		metrics.MessagesReceived.WithLabelValues("chat").Inc()
+		s.handleChat(connection, clientMsg.Text)
+
+	case protocol.MessageReliableAck:
+		metrics.MessagesReceived.WithLabelValues("reliable_ack").Inc()
+		s.ackReliable(connection, clientMsg.ReliableID)
+
+	case protocol.MessageParty:
+		metrics.MessagesReceived.WithLabelValues("party").Inc()
+		s.handleParty(connection, clientMsg.PartyAction, clientMsg.TargetID)
+
+	case protocol.MessagePartyChat:
+		metrics.MessagesReceived.WithLabelValues("party_chat").Inc()
+		s.handlePartyChat(connection, clientMsg.Text)
+
+	case protocol.MessageFriend:
+		metrics.MessagesReceived.WithLabelValues("friend").Inc()
+		s.handleFriend(connection, clientMsg.FriendAction, clientMsg.Account)
+
+	case protocol.MessageTrade:
+		metrics.MessagesReceived.WithLabelValues("trade").Inc()
+		s.handleTrade(connection, clientMsg)
+
+	case protocol.MessageEmote:
+		metrics.MessagesReceived.WithLabelValues("emote").Inc()
+		s.handleEmote(connection, clientMsg.EmoteID)
+	}
+}
+
+// handleInputTimeout sends a movement correction to a player the world stopped
+// after InputTimeoutTicks without a MOVE. The ACK carries the last applied input
+// sequence, so the client reconciles to the stopped position.
+func (s *Server) handleInputTimeout(playerID uint32, x, y types.Fixed, clientTick uint32) {
+	s.connectionsMu.RLock()
+	conn, ok := s.connections[playerID]
+	s.connectionsMu.RUnlock()
+	if !ok {
+		return
+	}
+	if conn.reliable != nil {
+		atomic.StoreInt32(&conn.correction, 1)
 	}
+	s.queueMoveAck(conn, x, y, clientTick)
 }
 
 func (s *Server) markConnectionCritical(conn *Connection) {
@@ -465,25 +978,25 @@ func (s *Server) markConnectionCritical(conn *Connection) {
 // cleanupConnection очищает соединение. Guaranteed idempotent via closeOnce.
 func (s *Server) cleanupConnection(c *Connection) {
 	c.closeOnce.Do(func() {
-		playerID := c.player.ID
-
-		metrics.DisconnectionsTotal.Inc()
-		metrics.PlayersConnected.Dec()
-		metrics.SessionDuration.Observe(time.Since(c.player.JoinTime).Seconds())
+		prev := atomic.SwapInt32(&c.state, connClosed)
+		s.countDisconnect(c)
 
 		// Stop epoll watching (must happen before rawConn.Close).
-		s.rh.remove(c)
-
-		// Remove from connections map BEFORE cancelling ctx so that broadcastTick
-		// cannot enqueue a new writeJob after the write loop exits (which would
-		// leave a tickFrame ref unreleased or panic on a send to a closed channel).
-		s.connectionsMu.Lock()
-		delete(s.connections, playerID)
-		s.connectionsMu.Unlock()
+		s.readHandlerOf(c).remove(c)
 
-		// Notify other players that this player left (after map removal so the
-		// departing connection does not receive its own leave notification).
-		s.notifyPlayerLeft(playerID)
+		switch prev {
+		case connAwaitingJoin:
+			if c.handshakeTimer != nil {
+				c.handshakeTimer.Stop()
+			}
+			s.releaseHandshake()
+		case connJoined:
+			s.unregisterPlayer(c)
+		case connQueued:
+			s.loginQueue.remove(c)
+		}
+		// connJoining: completeJoin sees connClosed and unregisters the player itself.
+		// connTransferred: the player lives on in the session that took it over.
 
 		// Cancel ctx → if the write-loop goroutine is still running, it will
 		// receive ctx.Done() and call drainWriteCh before exiting.
@@ -496,25 +1009,46 @@ func (s *Server) cleanupConnection(c *Connection) {
 		// Close the raw connection so any in-progress Write returns immediately.
 		c.rawConn.Close()
 
-		s.gameWorld.RemovePlayer(playerID)
+		if prev == connJoined {
+			s.gameWorld.RemovePlayer(c.player.ID)
+		}
 	})
 }
 
-// getOrCreateRateLimiter получает или создает rate limiter для IP.
-// Uses LoadOrStore to avoid the Load+Store TOCTOU race under concurrent connections.
-// If cfg.Net.IPConnRate == 0, rate limiting is disabled (returns an infinite limiter).
-func (s *Server) getOrCreateRateLimiter(ip string) *rate.Limiter {
-	limit := rate.Limit(s.cfg.Net.IPConnRate)
-	burst := s.cfg.Net.IPConnBurst
-	if limit <= 0 {
-		limit = rate.Inf
-		burst = 0
-	}
-	newLimiter := rate.NewLimiter(limit, burst)
-	if actual, loaded := s.rateLimiters.LoadOrStore(ip, newLimiter); loaded {
-		return actual.(*rate.Limiter)
-	}
-	return newLimiter
+// unregisterPlayer removes a joined connection from broadcasts and tells the others
+// that its player left.
+func (s *Server) unregisterPlayer(c *Connection) {
+	playerID := c.player.ID
+
+	metrics.DisconnectionsTotal.Inc()
+	metrics.PlayersConnected.Dec()
+	s.reportSession(c)
+
+	// Remove from connections map BEFORE cancelling ctx so that broadcastTick
+	// cannot enqueue a new writeJob after the write loop exits (which would
+	// leave a tickFrame ref unreleased or panic on a send to a closed channel).
+	s.connectionsMu.Lock()
+	delete(s.connections, playerID)
+	owner := c.account != "" && s.sessions[c.account] == c
+	if owner {
+		delete(s.sessions, c.account)
+	}
+	s.usage.connNs.Add(int64(time.Since(c.player.JoinTime)))
+	s.connectionsMu.Unlock()
+	if owner {
+		s.savePlayer(c)
+	}
+	if s.cfg.Net.LoginQueueSize > 0 {
+		s.loginQueue.playerLeft(c.account)
+		s.admitQueued()
+	}
+
+	// Notify other players that this player left (after map removal so the
+	// departing connection does not receive its own leave notification).
+	s.notifyPlayerLeft(playerID)
+	s.leaveParty(c)
+	s.leaveFriends(c)
+	s.leaveTrade(c)
 }
 
 // performanceMonitor мониторит производительность
@@ -569,3 +1103,36 @@ func (s *Server) handleMetricsJSON(w http.ResponseWriter, r *http.Request) {
 		runtime.NumGoroutine(),
 		mem.HeapAlloc/1024/1024)
 }
+
+// handleMetricsHistory returns the metrics history samples taken after ?since=UNIX_MS
+// (default: all), so a dashboard can poll for just the new points.
+func (s *Server) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "since must be a Unix time in milliseconds", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.history.Since(since))
+}
+
+// handleMetricsZones returns per-zone player counts and the busiest grid cells (?top=N, default 10).
+func (s *Server) handleMetricsZones(w http.ResponseWriter, r *http.Request) {
+	top := 10
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "top must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		top = min(n, 1000)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.gameWorld.ZoneReport(top))
+}
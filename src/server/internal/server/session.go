@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/auth"
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Player sessions per account. With AUTH_SECRET set, clients connect to
+// /ws?token=<session token> (see internal/auth); the token's account ID identifies
+// the player across connections. When an account that is already playing joins
+// again, Server.DuplicateSessionPolicy decides:
+//
+//   - config.SessionTakeover: the new connection gets the existing Player (ID,
+//     position, state) and the old one receives SESSION_TAKEOVER and is closed with
+//     protocol.CloseDuplicateSession. Other players see no leave/join.
+//   - config.SessionReject: the new connection is closed with
+//     protocol.CloseDuplicateSession; the old one keeps playing.
+//
+// Anonymous connections (no token) never collide. s.sessions is guarded by
+// connectionsMu and holds the connection that currently owns each account, from
+// JOIN until unregisterPlayer.
+
+var errTokenRequired = errors.New("session token required")
+
+// authenticate returns the claims of r's session token; the zero Claims (account "")
+// for an anonymous client.
+func (s *Server) authenticate(r *http.Request) (auth.Claims, error) {
+	if len(s.authSecret) == 0 {
+		return auth.Claims{}, nil
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		if s.cfg.Server.AuthRequired {
+			return auth.Claims{}, errTokenRequired
+		}
+		return auth.Claims{}, nil
+	}
+	return auth.VerifyClaims(s.authSecret, token, time.Now())
+}
+
+// authFailureReason labels an authenticate error for metrics.
+func authFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errTokenRequired):
+		return "missing"
+	case errors.Is(err, auth.ErrExpired):
+		return "expired"
+	case errors.Is(err, auth.ErrSignature):
+		return "signature"
+	default:
+		return "malformed"
+	}
+}
+
+// claimSession makes c the owner of its account. It returns the Player c takes over
+// (nil = allocate a new one), or ok=false when the account is playing elsewhere and
+// the policy rejects the new connection.
+func (s *Server) claimSession(c *Connection) (player *types.Player, ok bool) {
+	if c.account == "" {
+		return nil, true
+	}
+	s.connectionsMu.Lock()
+	old := s.sessions[c.account]
+	if old == nil {
+		s.sessions[c.account] = c
+		s.connectionsMu.Unlock()
+		return nil, true
+	}
+	if s.cfg.Server.DuplicateSessionPolicy == config.SessionReject {
+		s.connectionsMu.Unlock()
+		metrics.DuplicateSessions.WithLabelValues("rejected").Inc()
+		slog.Info("duplicate session rejected", "account", c.account, "ip", c.ip)
+		return nil, false
+	}
+	s.sessions[c.account] = c
+	// Only a fully joined session can hand its player over; one still joining (or
+	// already closing) is just closed and the new connection starts fresh.
+	if !atomic.CompareAndSwapInt32(&old.state, connJoined, connTransferred) {
+		s.connectionsMu.Unlock()
+		metrics.DuplicateSessions.WithLabelValues("replaced").Inc()
+		s.closeConnection(old, protocol.CloseDuplicateSession, "session taken over")
+		return nil, true
+	}
+	player = old.player
+	if s.connections[player.ID] == old {
+		delete(s.connections, player.ID)
+	}
+	s.connectionsMu.Unlock()
+
+	metrics.DuplicateSessions.WithLabelValues("takeover").Inc()
+	slog.Info("session taken over",
+		"account", c.account,
+		"player_id", player.ID,
+		"old_ip", old.ip,
+		"new_ip", c.ip,
+	)
+	s.sendDirect(old, s.protocol.EncodeSessionTakeover(player.ID))
+	s.closeConnection(old, protocol.CloseDuplicateSession, "session taken over")
+
+	// The old client's last input must not keep steering the player.
+	player.SetVX(0)
+	player.SetVY(0)
+	return player, true
+}
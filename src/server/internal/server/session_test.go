@@ -0,0 +1,111 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func newSessionServer(t *testing.T, policy string) *Server {
+	t.Helper()
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Server.DuplicateSessionPolicy = policy
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	return s
+}
+
+// joinAccount connects and joins a client of account.
+func joinAccount(s *Server, account string) (*Connection, *testutil.FakeConn) {
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	c.account = account
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+	return c, fake
+}
+
+// closeFrame waits until c is closed and returns the code of the close frame it got.
+func closeFrame(t *testing.T, c *Connection, fake *testutil.FakeConn) (code ws.StatusCode, frames []testutil.Frame) {
+	t.Helper()
+	select {
+	case <-c.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) == 0 || frames[len(frames)-1].OpCode != ws.OpClose {
+		t.Fatalf("last frame is not a close frame: %+v", frames)
+	}
+	code, _ = ws.ParseCloseFrameData(frames[len(frames)-1].Payload)
+	return code, frames
+}
+
+func TestSessionTakeover(t *testing.T) {
+	s := newSessionServer(t, config.SessionTakeover)
+	old, oldFake := joinAccount(s, "alice")
+	other, _ := joinAccount(s, "bob")
+	player := old.player
+
+	c, _ := joinAccount(s, "alice")
+	if c.player != player {
+		t.Fatalf("new session got player %d, want the old player %d", c.player.ID, player.ID)
+	}
+	if got := atomic.LoadInt32(&c.state); got != connJoined {
+		t.Errorf("new session state = %d, want joined", got)
+	}
+	code, frames := closeFrame(t, old, oldFake)
+	if code != protocol.CloseDuplicateSession {
+		t.Errorf("old session closed with %d, want %d", code, protocol.CloseDuplicateSession)
+	}
+	takeover := frames[len(frames)-2]
+	if len(takeover.Payload) <= seqHeaderSize || takeover.Payload[seqHeaderSize] != protocol.MessageSessionTakeover {
+		t.Errorf("frame before close = % x, want SESSION_TAKEOVER", takeover.Payload)
+	}
+
+	s.connectionsMu.RLock()
+	defer s.connectionsMu.RUnlock()
+	if s.connections[player.ID] != c || s.sessions["alice"] != c || s.sessions["bob"] != other {
+		t.Error("connection and session maps not handed to the new session")
+	}
+	if s.gameWorld.GetPlayerCount() != 2 {
+		t.Errorf("players in world = %d, want 2 (the taken-over player stays)", s.gameWorld.GetPlayerCount())
+	}
+}
+
+func TestSessionReject(t *testing.T) {
+	s := newSessionServer(t, config.SessionReject)
+	old, _ := joinAccount(s, "alice")
+
+	c, fake := joinAccount(s, "alice")
+	if code, _ := closeFrame(t, c, fake); code != protocol.CloseDuplicateSession {
+		t.Errorf("new session closed with %d, want %d", code, protocol.CloseDuplicateSession)
+	}
+	if got := atomic.LoadInt32(&old.state); got != connJoined {
+		t.Errorf("old session state = %d, want joined", got)
+	}
+	if s.gameWorld.GetPlayerCount() != 1 {
+		t.Errorf("players in world = %d, want 1", s.gameWorld.GetPlayerCount())
+	}
+
+	// Once the first session is gone the account may join again.
+	s.cleanupConnection(old)
+	if again, _ := joinAccount(s, "alice"); atomic.LoadInt32(&again.state) != connJoined {
+		t.Error("account cannot join after its session ended")
+	}
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/metrics"
+)
+
+// Session summary on disconnect.
+//
+// When a joined player leaves, unregisterPlayer turns the connection's counters into
+// one SessionSummary: how long the session lasted, bytes both ways, the client
+// messages by kind (the abuse.go traffic totals), the average WebSocket ping RTT and
+// the messages the server dropped for it. The summary is logged and observed in the
+// game_session_* histograms; with Server.SessionWebhookURL set it is also POSTed there
+// as JSON, for churn and connection-quality analysis outside Prometheus.
+//
+// Webhook posts go through a bounded queue and one sender goroutine: a slow or dead
+// endpoint costs dropped summaries (game_session_webhooks_total{result="dropped"}),
+// never a stalled disconnect.
+
+// sessionWebhookQueue — summaries waiting for the webhook sender.
+const sessionWebhookQueue = 256
+
+// SessionSummary — one finished player session.
+type SessionSummary struct {
+	PlayerID        uint32            `json:"player_id"`
+	Account         string            `json:"account,omitempty"`
+	IP              string            `json:"ip"`
+	Start           time.Time         `json:"start"`
+	End             time.Time         `json:"end"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	BytesIn         int64             `json:"bytes_in"`
+	BytesOut        int64             `json:"bytes_out"`
+	Messages        map[string]uint64 `json:"messages"`
+	AvgRTTMs        float64           `json:"avg_rtt_ms"` // 0 = no pong measured
+	RTTSamples      int64             `json:"rtt_samples"`
+	Drops           uint64            `json:"drops"` // messages the server dropped for the client
+}
+
+// notePingSent marks a ping queued to c at nowNs. Only one ping is timed at a time:
+// a ping sent while the previous one is unanswered is not measured.
+func (c *Connection) notePingSent(nowNs int64) {
+	atomic.CompareAndSwapInt64(&c.pingSentNs, 0, nowNs)
+}
+
+// notePong records the RTT of the timed ping, if any. Read path only.
+func (c *Connection) notePong(nowNs int64) {
+	sent := atomic.SwapInt64(&c.pingSentNs, 0)
+	if sent == 0 || nowNs < sent {
+		return
+	}
+	atomic.AddInt64(&c.rttSumNs, nowNs-sent)
+	atomic.AddInt64(&c.rttSamples, 1)
+}
+
+// sessionSummary collects c's counters. c's player must be set.
+func (c *Connection) sessionSummary(end time.Time) SessionSummary {
+	sum := SessionSummary{
+		PlayerID:   c.player.ID,
+		Account:    c.account,
+		IP:         c.ip,
+		Start:      c.player.JoinTime,
+		End:        end,
+		BytesIn:    atomic.LoadInt64(&c.bytesIn),
+		BytesOut:   atomic.LoadInt64(&c.bytesOut),
+		Messages:   make(map[string]uint64, numTrafficKinds),
+		RTTSamples: atomic.LoadInt64(&c.rttSamples),
+		Drops:      atomic.LoadUint64(&c.dropsTotal),
+	}
+	sum.DurationSeconds = end.Sub(sum.Start).Seconds()
+	if sum.RTTSamples > 0 {
+		sum.AvgRTTMs = float64(atomic.LoadInt64(&c.rttSumNs)) / float64(sum.RTTSamples) / float64(time.Millisecond)
+	}
+	if c.traffic != nil {
+		c.traffic.mu.Lock()
+		total := c.traffic.total
+		c.traffic.mu.Unlock()
+		for k, n := range total {
+			if n > 0 {
+				sum.Messages[trafficKind(k).String()] = n
+			}
+		}
+	}
+	return sum
+}
+
+// reportSession logs and exports the summary of c's session. Called once per joined
+// session, from unregisterPlayer.
+func (s *Server) reportSession(c *Connection) {
+	sum := c.sessionSummary(time.Now())
+
+	metrics.SessionDuration.Observe(sum.DurationSeconds)
+	metrics.SessionBytes.WithLabelValues("in").Observe(float64(sum.BytesIn))
+	metrics.SessionBytes.WithLabelValues("out").Observe(float64(sum.BytesOut))
+	metrics.SessionDrops.Observe(float64(sum.Drops))
+	if sum.RTTSamples > 0 {
+		metrics.SessionAvgRTT.Observe(sum.AvgRTTMs / 1000)
+	}
+	slog.Info("session summary",
+		"player_id", sum.PlayerID,
+		"account", sum.Account,
+		"ip", sum.IP,
+		"duration_s", sum.DurationSeconds,
+		"bytes_in", sum.BytesIn,
+		"bytes_out", sum.BytesOut,
+		"messages", sum.Messages,
+		"avg_rtt_ms", sum.AvgRTTMs,
+		"drops", sum.Drops,
+	)
+
+	if s.sessionHooks == nil {
+		return
+	}
+	select {
+	case s.sessionHooks <- sum:
+	default:
+		metrics.SessionWebhooks.WithLabelValues("dropped").Inc()
+	}
+}
+
+// runSessionWebhook posts queued summaries to Server.SessionWebhookURL until the
+// server stops.
+func (s *Server) runSessionWebhook() {
+	for {
+		select {
+		case sum := <-s.sessionHooks:
+			if err := s.postSessionSummary(s.ctx, &sum); err != nil {
+				metrics.SessionWebhooks.WithLabelValues("error").Inc()
+				slog.Warn("session webhook failed", "url", config.RedactURL(s.cfg.Server.SessionWebhookURL), "player_id", sum.PlayerID, "error", config.RedactURLError(err))
+			} else {
+				metrics.SessionWebhooks.WithLabelValues("ok").Inc()
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// postSessionSummary POSTs one summary, within SessionWebhookTimeout.
+func (s *Server) postSessionSummary(ctx context.Context, sum *SessionSummary) error {
+	body, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Server.SessionWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Server.SessionWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
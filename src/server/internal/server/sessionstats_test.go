@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestSessionSummaryWebhook(t *testing.T) {
+	got := make(chan SessionSummary, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sum SessionSummary
+		if err := json.NewDecoder(r.Body).Decode(&sum); err != nil {
+			t.Error(err)
+		}
+		got <- sum
+	}))
+	defer hook.Close()
+
+	cfg := testutil.Config()
+	cfg.Server.SessionWebhookURL = hook.URL
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &Server{cfg: cfg, ctx: ctx, sessionHooks: make(chan SessionSummary, 1)}
+	go s.runSessionWebhook()
+
+	c := &Connection{
+		player:   types.NewPlayer(1001),
+		ip:       "10.0.0.1",
+		account:  "alice",
+		traffic:  newTrafficStats(time.Second),
+		bytesIn:  120,
+		bytesOut: 4096,
+	}
+	c.player.JoinTime = time.Now().Add(-time.Minute)
+	nowNs := time.Now().UnixNano()
+	c.traffic.record(trafficMove, nowNs)
+	c.traffic.record(trafficMove, nowNs)
+	c.traffic.record(trafficAttack, nowNs)
+	s.noteDrop(c)
+	c.notePingSent(nowNs)
+	c.notePingSent(nowNs + int64(time.Millisecond)) // previous ping still unanswered
+	c.notePong(nowNs + int64(40*time.Millisecond))
+	c.notePong(nowNs + int64(time.Second)) // no ping outstanding
+
+	s.reportSession(c)
+	select {
+	case sum := <-got:
+		if sum.PlayerID != 1001 || sum.Account != "alice" || sum.IP != "10.0.0.1" {
+			t.Errorf("identity = %d %q %q", sum.PlayerID, sum.Account, sum.IP)
+		}
+		if sum.DurationSeconds < 59 || sum.BytesIn != 120 || sum.BytesOut != 4096 || sum.Drops != 1 {
+			t.Errorf("summary = %+v", sum)
+		}
+		if sum.Messages["move"] != 2 || sum.Messages["attack"] != 1 || len(sum.Messages) != 2 {
+			t.Errorf("messages = %v, want move 2, attack 1", sum.Messages)
+		}
+		if sum.RTTSamples != 1 || sum.AvgRTTMs != 40 {
+			t.Errorf("rtt = %v ms over %d samples, want 40 over 1", sum.AvgRTTMs, sum.RTTSamples)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestSessionWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // an endpoint that never answers
+	}))
+	defer hook.Close()
+	defer close(release)
+
+	cfg := testutil.Config()
+	cfg.Server.SessionWebhookURL = hook.URL
+	cfg.Server.SessionWebhookTimeout = 50 * time.Millisecond
+	s := &Server{cfg: cfg}
+
+	start := time.Now()
+	if err := s.postSessionSummary(t.Context(), &SessionSummary{PlayerID: 1001}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("post to a stalled endpoint: %v, want DeadlineExceeded", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("post took %v, want about SESSION_WEBHOOK_TIMEOUT_MS", took)
+	}
+}
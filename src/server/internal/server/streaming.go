@@ -0,0 +1,148 @@
+package server
+
+import (
+	"math"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/types"
+)
+
+// Cell streaming (protocol.CapCellStreaming).
+//
+// Without it a joining client gets every player in the world, and a stationary player
+// that enters a viewport because the viewer moved only shows up on the next full sync
+// (aoi.go). A streaming client instead gets only itself on join; the world is split
+// into Net.StreamCellSize cells, and each tick the server compares the cells under the
+// client's viewport with the set it has loaded:
+//
+//   - a cell that came into view is sent as CELL_LOAD with the players inside it;
+//   - a loaded cell more than one cell away from the view is sent as CELL_UNLOAD, and
+//     the client drops the players inside it (the one-cell margin keeps a viewport
+//     that wobbles on a cell border from reloading it every tick).
+//
+// The regular world-state frames are filtered as for any AOI client, but to the area
+// that may still be loaded (the view plus the one-cell margin), so a full sync does not
+// drop players of a loaded cell. A join costs O(viewport) instead of O(world). A
+// streaming client that has not reported a viewport yet streams just the cell it
+// stands in.
+
+// cellStream — cells loaded on a streaming client. gameLoop goroutine only.
+type cellStream struct {
+	loaded map[uint32]struct{} // cellKey
+}
+
+// cellIndex — indices into the tick's player list by stream cell.
+type cellIndex struct {
+	seq   uint32 // stateSequence the index was built for
+	cells map[uint32][]int32
+}
+
+var (
+	cellLoads   = metrics.StreamedCells.WithLabelValues("load")
+	cellUnloads = metrics.StreamedCells.WithLabelValues("unload")
+)
+
+// cellKey packs the indices of a stream cell; config validation keeps them in 16 bits.
+func cellKey(cx, cy uint32) uint32 {
+	return cy<<16 | cx
+}
+
+// streamCellSize returns Net.StreamCellSize within the u16 of the wire format.
+func (s *Server) streamCellSize() uint16 {
+	return uint16(min(max(s.cfg.Net.StreamCellSize, 1), math.MaxUint16))
+}
+
+// playersByCell returns allPlayers bucketed by stream cell, building the index on the
+// first call of a tick.
+func (s *Server) playersByCell(allPlayers []types.PlayerState, stateSequence uint32, size uint16) map[uint32][]int32 {
+	idx := &s.cellIndex
+	if idx.cells != nil && idx.seq == stateSequence {
+		return idx.cells
+	}
+	if idx.cells == nil {
+		idx.cells = make(map[uint32][]int32)
+	}
+	clear(idx.cells)
+	for i := range allPlayers {
+		key := cellKey(allPlayers[i].X/uint32(size), allPlayers[i].Y/uint32(size))
+		idx.cells[key] = append(idx.cells[key], int32(i))
+	}
+	idx.seq = stateSequence
+	return idx.cells
+}
+
+// streamBounds returns the area conn streams: its viewport, or its own position.
+func streamBounds(conn *Connection) types.ViewportBounds {
+	if bounds, ok := conn.player.GetViewport(); ok {
+		return bounds
+	}
+	x, y := conn.player.GetX(), conn.player.GetY()
+	return types.ViewportBounds{MinX: x, MinY: y, MaxX: x, MaxY: y}
+}
+
+// streamCells sends one CELL_UNLOAD for the loaded cells that left conn's view and
+// CELL_LOAD for the cells that entered it, ahead of this tick's world-state frame.
+// Returns the area to filter that frame by. gameLoop goroutine only.
+func (s *Server) streamCells(conn *Connection, allPlayers []types.PlayerState, stateSequence uint32) types.ViewportBounds {
+	size := s.streamCellSize()
+	view := streamBounds(conn)
+	minCX, minCY := view.MinX/uint32(size), view.MinY/uint32(size)
+	maxCX, maxCY := view.MaxX/uint32(size), view.MaxY/uint32(size)
+	margin := func(cell uint32, delta int) uint32 {
+		return uint32(min(max((int(cell)+delta)*int(size), 0), math.MaxUint32))
+	}
+	area := types.ViewportBounds{
+		MinX: margin(minCX, -1), MinY: margin(minCY, -1),
+		MaxX: margin(maxCX, 2) - 1, MaxY: margin(maxCY, 2) - 1,
+	}
+
+	var unload []uint32
+	for key := range conn.stream.loaded {
+		cx, cy := int(key&0xFFFF), int(key>>16)
+		if cx+1 < int(minCX) || cx > int(maxCX)+1 || cy+1 < int(minCY) || cy > int(maxCY)+1 {
+			delete(conn.stream.loaded, key)
+			unload = append(unload, key)
+		}
+	}
+	if len(unload) > 0 {
+		s.sendDirect(conn, s.protocol.EncodeCellUnload(size, unload))
+		cellUnloads.Add(float64(len(unload)))
+	}
+
+	var cells map[uint32][]int32
+	loaded := 0
+	s.aoiScratch = s.aoiScratch[:0]
+	for cy := minCY; cy <= maxCY; cy++ {
+		for cx := minCX; cx <= maxCX; cx++ {
+			key := cellKey(cx, cy)
+			if _, ok := conn.stream.loaded[key]; ok {
+				continue
+			}
+			conn.stream.loaded[key] = struct{}{}
+			loaded++
+			if cells == nil {
+				cells = s.playersByCell(allPlayers, stateSequence, size)
+			}
+			for _, i := range cells[key] {
+				s.aoiScratch = append(s.aoiScratch, allPlayers[i])
+			}
+		}
+	}
+	if loaded == 0 {
+		return area
+	}
+	cellLoads.Add(float64(loaded))
+	per := len(s.aoiScratch)
+	if conn.maxMessageSize > 0 {
+		per = protocol.CellLoadPlayersPerMessage(conn.maxMessageSize - conn.dataHeaderSize())
+	}
+	per = max(per, 1)
+	// Cells without players are still sent: one CELL_LOAD with no entries.
+	for start := 0; start < len(s.aoiScratch) || start == 0; start += per {
+		part := s.aoiScratch[start:min(start+per, len(s.aoiScratch))]
+		s.sendDirect(conn, s.protocolFor(conn).AppendCellLoad(nil, size, loaded, part))
+		loaded = 0 // continuations load no further cells
+	}
+	return area
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+// drainDirect returns the direct payloads queued on c.
+func drainDirect(c *Connection) [][]byte {
+	var out [][]byte
+	for {
+		select {
+		case job := <-c.writeCh:
+			out = append(out, job.direct)
+		default:
+			return out
+		}
+	}
+}
+
+// cellLoadIDs decodes a CELL_LOAD into its loaded-cell count and player IDs.
+func cellLoadIDs(t *testing.T, msg []byte) (cells int, ids []uint32) {
+	t.Helper()
+	if msg[0] != protocol.MessageCellLoad {
+		t.Fatalf("message %d, want CELL_LOAD", msg[0])
+	}
+	cells = int(binary.LittleEndian.Uint16(msg[3:]))
+	n := int(binary.LittleEndian.Uint32(msg[5:]))
+	entry := protocol.LookupSchema(protocol.MessageCellLoad).EntrySize()
+	for i := range n {
+		ids = append(ids, binary.LittleEndian.Uint32(msg[9+i*entry:]))
+	}
+	return cells, ids
+}
+
+func TestStreamCellsLoadsAndUnloads(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.StreamCellSize = 500
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}}
+	player := types.NewPlayer(1)
+	player.SetX(1200)
+	player.SetY(700)
+	player.SetViewSize(400, 400)
+	player.SetViewport(types.ViewportBounds{MinX: 1000, MinY: 500, MaxX: 1400, MaxY: 900})
+	c := &Connection{player: player, writeCh: make(chan writeJob, writeChanSize), stream: &cellStream{loaded: map[uint32]struct{}{}}}
+	players := []types.PlayerState{
+		{ID: 1, X: 1200, Y: 700},
+		{ID: 2, X: 1100, Y: 600}, // same cell (2,1)
+		{ID: 3, X: 100, Y: 100},  // cell (0,0)
+		{ID: 4, X: 2700, Y: 700}, // cell (5,1)
+	}
+
+	area := s.streamCells(c, players, 1)
+	if want := (types.ViewportBounds{MinX: 500, MinY: 0, MaxX: 1999, MaxY: 1499}); area != want {
+		t.Errorf("filter area = %+v, want %+v", area, want)
+	}
+	msgs := drainDirect(c)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages on join, want one CELL_LOAD", len(msgs))
+	}
+	if cells, ids := cellLoadIDs(t, msgs[0]); cells != 1 || !slices.Equal(ids, []uint32{1, 2}) {
+		t.Errorf("CELL_LOAD = %d cells, players %v; want 1 cell with players [1 2]", cells, ids)
+	}
+
+	if s.streamCells(c, players, 2); len(drainDirect(c)) != 0 {
+		t.Error("unchanged view sent messages")
+	}
+
+	player.SetViewport(types.ViewportBounds{MinX: 2600, MinY: 500, MaxX: 3400, MaxY: 900})
+	s.streamCells(c, players, 3)
+	msgs = drainDirect(c)
+	if len(msgs) != 2 || msgs[0][0] != protocol.MessageCellUnload {
+		t.Fatalf("after moving: %d messages, want CELL_UNLOAD then CELL_LOAD", len(msgs))
+	}
+	if got := msgs[0]; binary.LittleEndian.Uint32(got[3:]) != 1 || binary.LittleEndian.Uint16(got[7:]) != 2 || binary.LittleEndian.Uint16(got[9:]) != 1 {
+		t.Errorf("CELL_UNLOAD = % x, want cell (2,1)", got)
+	}
+	if cells, ids := cellLoadIDs(t, msgs[1]); cells != 2 || !slices.Equal(ids, []uint32{4}) {
+		t.Errorf("CELL_LOAD = %d cells, players %v; want cells (5,1) and (6,1) with player 4", cells, ids)
+	}
+}
+
+func TestStreamCellsSplitsLargeLoads(t *testing.T) {
+	cfg := testutil.Config()
+	s := &Server{cfg: cfg, protocol: &protocol.BinaryProtocol{}}
+	player := types.NewPlayer(1)
+	c := &Connection{player: player, writeCh: make(chan writeJob, writeChanSize), stream: &cellStream{loaded: map[uint32]struct{}{}}}
+	c.maxMessageSize = minClientMessageSize
+	players := testutil.Players(1000, 10, 10) // all in cell (0,0)
+
+	s.streamCells(c, players, 1)
+	total := 0
+	for i, msg := range drainDirect(c) {
+		if !c.fitsMessage(len(msg)) {
+			t.Errorf("CELL_LOAD %d is %d bytes, over the client limit", i, len(msg))
+		}
+		cells, ids := cellLoadIDs(t, msg)
+		if (i == 0) != (cells == 1) {
+			t.Errorf("CELL_LOAD %d loads %d cells; only the first should count the cell", i, cells)
+		}
+		total += len(ids)
+	}
+	if total != len(players) {
+		t.Errorf("streamed %d players, want %d", total, len(players))
+	}
+}
+
+func TestInitialStateForStreamingClient(t *testing.T) {
+	s := newSessionServer(t, "")
+	joinAccount(s, "")
+	joinAccount(s, "")
+	s.gameWorld.Step()
+
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapDeltaUpdates | protocol.CapCellStreaming})
+	if c.stream == nil {
+		t.Fatal("cell streaming not enabled")
+	}
+	if !hasMessage(t, fake, protocol.MessageGameState, time.Second) {
+		t.Fatal("no initial GAME_STATE")
+	}
+	frames, err := fake.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range frames {
+		msg := f.Payload[seqHeaderSize:]
+		if msg[0] != protocol.MessageGameState {
+			continue
+		}
+		if n := binary.LittleEndian.Uint32(msg[5:]); n != 1 || binary.LittleEndian.Uint32(msg[9:]) != c.player.ID {
+			t.Errorf("initial GAME_STATE has %d players, want only the client's own", n)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gobwas/ws"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/protocol"
+)
+
+// WebSocket subprotocol negotiation. The server speaks protocol.Subprotocol and
+// answers with it when the client offers it; clients that offer only
+// protocol.LegacySubprotocol get it, with positions in whole units (protocolFor). Clients
+// that offer no subprotocol at all (older builds) are accepted, as legacy ones, unless
+// Net.RequireSubprotocol is set. A large world (largeworld.go) rejects both: their
+// positions cannot go relative to an ORIGIN.
+//
+// A client that offers only unsupported subprotocols must get a close code, not a
+// failed handshake: a browser reports a missing Sec-WebSocket-Protocol answer as an
+// opaque 1006. So the server echoes the first offered subprotocol, completes the
+// upgrade and immediately closes with protocol.CloseUnsupportedSubprotocol.
+
+// negotiateSubprotocol picks the subprotocol to answer with ("" = none offered).
+// ok=false means the connection must be closed right after the upgrade.
+func (s *Server) negotiateSubprotocol(r *http.Request) (selected string, ok bool) {
+	var offered []string
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				offered = append(offered, p)
+			}
+		}
+	}
+	switch {
+	case len(offered) == 0:
+		if s.cfg.Net.RequireSubprotocol || s.largeWorld {
+			metrics.WSSubprotocol.WithLabelValues("rejected").Inc()
+			return "", false
+		}
+		metrics.WSSubprotocol.WithLabelValues("none").Inc()
+		return "", true
+	case slices.Contains(offered, protocol.Subprotocol):
+		metrics.WSSubprotocol.WithLabelValues("negotiated").Inc()
+		return protocol.Subprotocol, true
+	case slices.Contains(offered, protocol.LegacySubprotocol) && !s.largeWorld:
+		metrics.WSSubprotocol.WithLabelValues("legacy").Inc()
+		return protocol.LegacySubprotocol, true
+	default:
+		metrics.WSSubprotocol.WithLabelValues("rejected").Inc()
+		return offered[0], false
+	}
+}
+
+// rejectSubprotocol closes a freshly upgraded connection whose subprotocol is not
+// supported.
+func (s *Server) rejectSubprotocol(conn net.Conn, r *http.Request) {
+	slog.Warn("unsupported websocket subprotocol",
+		"offered", r.Header.Values("Sec-WebSocket-Protocol"),
+		"supported", protocol.Subprotocol,
+		"remote_addr", r.RemoteAddr,
+	)
+	closeWithCode(conn, protocol.CloseUnsupportedSubprotocol, "supported subprotocol: "+protocol.Subprotocol, s.directWriteTimeout)
+}
+
+// closeWithCode sends a close frame with code and reason, then closes conn. Used
+// before the connection has a write loop.
+func closeWithCode(conn net.Conn, code uint16, reason string, timeout time.Duration) {
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	ws.WriteFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusCode(code), reason)))
+	conn.Close()
+}
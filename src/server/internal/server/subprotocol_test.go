@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+	"pixi_game_server/internal/types"
+)
+
+func TestNegotiateSubprotocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		offered  []string
+		require  bool
+		selected string
+		ok       bool
+	}{
+		{"legacy client", nil, false, "", true},
+		{"legacy client, required", nil, true, "", false},
+		{"current", []string{protocol.Subprotocol}, false, protocol.Subprotocol, true},
+		{"current among others", []string{"pixi.game.v1, " + protocol.Subprotocol}, false, protocol.Subprotocol, true},
+		{"previous", []string{protocol.LegacySubprotocol}, true, protocol.LegacySubprotocol, true},
+		{"current over previous", []string{protocol.LegacySubprotocol, protocol.Subprotocol}, false, protocol.Subprotocol, true},
+		{"outdated", []string{"pixi.game.v1", "json"}, false, "pixi.game.v1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{cfg: &config.Config{Net: config.NetworkConfig{RequireSubprotocol: tt.require}}}
+			r := httptest.NewRequest("GET", "/ws", nil)
+			for _, p := range tt.offered {
+				r.Header.Add("Sec-WebSocket-Protocol", p)
+			}
+			selected, ok := s.negotiateSubprotocol(r)
+			if selected != tt.selected || ok != tt.ok {
+				t.Errorf("negotiateSubprotocol = (%q, %v), want (%q, %v)", selected, ok, tt.selected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLegacyPositions(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Net.PositionFractionBits = 4
+	cfg.World.Width, cfg.World.MaxX = 4000, 4000 // 4000 << 4 fits in uint16
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+	join := func(legacy bool) (*Connection, *testutil.FakeConn) {
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		c.legacyPositions = legacy
+		s.reserveHandshake()
+		s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+		return c, fake
+	}
+	current, currentFake := join(false)
+	legacy, legacyFake := join(true)
+	// positionBits of CONFIG is its seventh byte from the end, before worldWidthHigh, worldHeightHigh and worldSeed.
+	if got := messagesOf(t, currentFake, protocol.MessageConfig, 1); len(got) != 1 || got[0][len(got[0])-7] != 4 {
+		t.Errorf("current client's CONFIG = %x", got)
+	}
+	if got := messagesOf(t, legacyFake, protocol.MessageConfig, 1); len(got) != 1 || got[0][len(got[0])-7] != 0 {
+		t.Errorf("legacy client's CONFIG = %x", got)
+	}
+
+	x, y := types.FixedOf(100)|0x80, types.FixedOf(200)|0x40
+	current.player.Position().SetFixed(x, y)
+	s.notifyPlayerJoined(current.player)
+	s.queueMoveAck(current, x, y, 1)
+	s.queueMoveAck(legacy, x, y, 1)
+	s.flushMoveAcks()
+
+	for _, tt := range []struct {
+		name       string
+		fake       *testutil.FakeConn
+		wantX      uint16
+		wantY      uint16
+		joinedSeen int // every join is announced to all, the joiner included
+	}{
+		{"current", currentFake, 100<<4 | 8, 200<<4 | 4, 3},
+		{"legacy", legacyFake, 100, 200, 2},
+	} {
+		joined := messagesOf(t, tt.fake, protocol.MessagePlayerJoined, tt.joinedSeen)
+		if len(joined) != tt.joinedSeen {
+			t.Fatalf("%s: %d PLAYER_JOINEDs, want %d", tt.name, len(joined), tt.joinedSeen)
+		}
+		msg := joined[len(joined)-1]
+		if gx, gy := binary.LittleEndian.Uint16(msg[5:]), binary.LittleEndian.Uint16(msg[7:]); gx != tt.wantX || gy != tt.wantY {
+			t.Errorf("%s: PLAYER_JOINED at (%d, %d), want (%d, %d)", tt.name, gx, gy, tt.wantX, tt.wantY)
+		}
+		acks := messagesOf(t, tt.fake, protocol.MessageMovementAck, 1)
+		if len(acks) != 1 {
+			t.Fatalf("%s: %d MOVEMENT_ACKs", tt.name, len(acks))
+		}
+		if gx, gy := binary.LittleEndian.Uint16(acks[0][5:]), binary.LittleEndian.Uint16(acks[0][7:]); gx != tt.wantX || gy != tt.wantY {
+			t.Errorf("%s: MOVEMENT_ACK at (%d, %d), want (%d, %d)", tt.name, gx, gy, tt.wantX, tt.wantY)
+		}
+	}
+}
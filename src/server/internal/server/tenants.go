@@ -0,0 +1,147 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/tenant"
+	"pixi_game_server/internal/worldmap"
+)
+
+// Tenants (internal/tenant).
+//
+// Every tenant is a Server of its own — world, players, logs, admin API — that never
+// listens: the host's listeners hand it the requests that carry its API key.
+//
+//   - /ws, /info and /leaderboard with ?key=<API key> go to the tenant's game
+//     routes, through its own admission checks (capacity, origin, auth, bans). An
+//     unknown key is refused with 401 before the upgrade.
+//   - A request with Authorization: Bearer <admin key> goes to the tenant's admin
+//     routes; the admin key is the tenant's ADMIN_TOKEN, so a tenant administers its
+//     own world only. The API key never does: players have it. Process-wide
+//     endpoints — /metrics, /admin/runtime, pprof — are not among the routes.
+//   - The host's ADMIN_TOKEN reaches any tenant's admin routes with ?tenant=<name>,
+//     and lists the tenants at /admin/tenants.
+//
+// Everything else — static files, /health, /metrics — is the host's.
+
+// tenantServer — a hosted tenant and its routes.
+type tenantServer struct {
+	name         string
+	srv          *Server
+	game         *http.ServeMux
+	admin        *http.ServeMux
+	maxTickShare float64 // fair-use quota, 0 = none (see usage.go)
+}
+
+// AddTenant creates the world of t from its config (t.ServerConfig) on worldMap. Call
+// before Start.
+func (s *Server) AddTenant(t tenant.Tenant, cfg *config.Config, worldMap *worldmap.Map) {
+	ts := &tenantServer{
+		name:         t.Name,
+		srv:          New(cfg, worldMap),
+		game:         http.NewServeMux(),
+		admin:        http.NewServeMux(),
+		maxTickShare: t.Quotas.MaxTickShare,
+	}
+	ts.srv.tenantName, ts.srv.tenantKey = t.Name, t.APIKey
+	ts.srv.registerGameRoutes(ts.game)
+	ts.srv.registerAdminRoutes(ts.admin)
+	if s.tenantsByKey == nil {
+		s.tenantsByKey = make(map[[sha256.Size]byte]*tenantServer)
+		s.tenantsByAdminKey = make(map[[sha256.Size]byte]*tenantServer)
+	}
+	s.tenants = append(s.tenants, ts)
+	s.tenantsByKey[sha256.Sum256([]byte(t.APIKey))] = ts
+	if t.AdminKey != "" && t.AdminKey != t.APIKey {
+		s.tenantsByAdminKey[sha256.Sum256([]byte(t.AdminKey))] = ts
+	}
+}
+
+// routeTenants puts the tenant dispatch in front of the game and admin listeners.
+func (s *Server) routeTenants(listeners []*httpListener) {
+	for _, l := range listeners {
+		if l.name == "game" || l.name == "admin" {
+			l.chain = append(l.chain, s.dispatchTenants)
+		}
+	}
+}
+
+// dispatchTenants sends the requests of a tenant to its routes. Keys are looked up by
+// their hash, so the lookup time says nothing about how much of a key matches.
+func (s *Server) dispatchTenants(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if key := q.Get("key"); key != "" {
+			t := s.tenantsByKey[sha256.Sum256([]byte(key))]
+			if t == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			t.game.ServeHTTP(w, r)
+			return
+		}
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if t := s.tenantsByAdminKey[sha256.Sum256([]byte(bearer))]; t != nil {
+			t.admin.ServeHTTP(w, r)
+			return
+		}
+		name := q.Get("tenant")
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.cfg.Server.AdminToken == "" || subtle.ConstantTimeCompare([]byte(bearer), []byte(s.cfg.Server.AdminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		t := s.tenantByName(name)
+		if t == nil {
+			http.Error(w, "unknown tenant", http.StatusNotFound)
+			return
+		}
+		// The operator acts as the tenant's admin.
+		r = r.Clone(r.Context())
+		r.Header.Set("Authorization", "Bearer "+t.srv.cfg.Server.AdminToken)
+		t.admin.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) tenantByName(name string) *tenantServer {
+	for _, t := range s.tenants {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// tenantInfo — one entry of /admin/tenants.
+type tenantInfo struct {
+	Name string `json:"name"`
+	serverInfo
+}
+
+// handleAdminTenants lists the hosted tenants:
+//
+//	GET /admin/tenants → [{"name", "players", "capacity", …}]
+func (s *Server) handleAdminTenants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	out := make([]tenantInfo, len(s.tenants))
+	for i, t := range s.tenants {
+		out[i] = tenantInfo{Name: t.name, serverInfo: t.srv.currentInfo()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
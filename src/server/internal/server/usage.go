@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/metrics"
+	"pixi_game_server/internal/tenant"
+)
+
+// Resource usage per world, for billing and fair use in multi-tenant mode.
+//
+// Every Server counts what its clients cost: time joined players spent connected,
+// data messages and bytes received, bytes written, and the time its world spent in
+// ticks. The host adds them up every Server.UsageReport: the period's figures of each
+// world (the host's own as "host", then the tenants) go to the log as one "tenant
+// usage" line and to the game_tenant_* metrics, and each world's share of all tick
+// time is worked out. A tenant over its max_tick_share quota has its new connections
+// refused with 503 until a period ends with it under the quota again; its players
+// already in keep playing. GET /admin/usage returns the totals since start.
+
+// usageCounters — a Server's running totals. Hot-path counters are bumped where the
+// bytes are counted for sessions (readloop.go, epoll_linux.go, bandwidth.go).
+type usageCounters struct {
+	connNs    atomic.Int64 // connected time of sessions that ended
+	messages  atomic.Int64
+	bytesIn   atomic.Int64
+	bytesOut  atomic.Int64
+	tickShare atomic.Uint64 // math.Float64bits of the last period's tick share
+	overQuota atomic.Bool   // over max_tick_share: new connections are refused
+}
+
+// Usage — a world's usage since start, as /admin/usage returns it.
+type Usage struct {
+	Tenant            string  `json:"tenant"`
+	ConnectionMinutes float64 `json:"connection_minutes"`
+	Messages          int64   `json:"messages"`
+	BytesIn           int64   `json:"bytes_in"`
+	BytesOut          int64   `json:"bytes_out"`
+	TickSeconds       float64 `json:"tick_seconds"`
+	TickShare         float64 `json:"tick_share"` // of all worlds' tick time in the last period
+	OverQuota         bool    `json:"over_quota"`
+}
+
+// countMessage adds a data message of n bytes from a client. Read path.
+func (s *Server) countMessage(n int) {
+	s.usage.messages.Add(1)
+	s.usage.bytesIn.Add(int64(n))
+}
+
+// usageTotals returns s's usage since start.
+func (s *Server) usageTotals() Usage {
+	now := time.Now()
+	connNs := s.usage.connNs.Load()
+	s.connectionsMu.RLock()
+	for _, c := range s.connections {
+		connNs += int64(now.Sub(c.player.JoinTime))
+	}
+	s.connectionsMu.RUnlock()
+	return Usage{
+		ConnectionMinutes: time.Duration(connNs).Minutes(),
+		Messages:          s.usage.messages.Load(),
+		BytesIn:           s.usage.bytesIn.Load(),
+		BytesOut:          s.usage.bytesOut.Load(),
+		TickSeconds:       s.gameWorld.TickTime().Seconds(),
+		TickShare:         math.Float64frombits(s.usage.tickShare.Load()),
+		OverQuota:         s.usage.overQuota.Load(),
+	}
+}
+
+// worlds returns s's own world first, then its tenants'.
+func (s *Server) worlds() []*tenantServer {
+	own := &tenantServer{name: s.tenantName, srv: s}
+	if own.name == "" {
+		own.name = tenant.Host
+	}
+	return append([]*tenantServer{own}, s.tenants...)
+}
+
+func (s *Server) runUsageReports() {
+	t := time.NewTicker(s.cfg.Server.UsageReport)
+	defer t.Stop()
+	prev := s.reportUsage(nil)
+	for {
+		select {
+		case <-t.C:
+			prev = s.reportUsage(prev)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// reportUsage reports every world's usage since the totals in prev (nil = since
+// start) and applies the tick share quotas. It returns the new totals.
+func (s *Server) reportUsage(prev map[string]Usage) map[string]Usage {
+	worlds := s.worlds()
+	cur := make(map[string]Usage, len(worlds))
+	var tickTotal float64
+	for _, w := range worlds {
+		u := w.srv.usageTotals()
+		u.Tenant = w.name
+		cur[w.name] = u
+		tickTotal += u.TickSeconds - prev[w.name].TickSeconds
+	}
+	for _, w := range worlds {
+		u, p := cur[w.name], prev[w.name]
+		share := 0.0
+		if tickTotal > 0 {
+			share = (u.TickSeconds - p.TickSeconds) / tickTotal
+		}
+		over := w.maxTickShare > 0 && share > w.maxTickShare
+		w.srv.usage.tickShare.Store(math.Float64bits(share))
+		if w.srv.usage.overQuota.Swap(over) != over {
+			slog.Warn("tenant tick share quota", "tenant", w.name, "over", over, "share", share, "quota", w.maxTickShare)
+		}
+		u.TickShare, u.OverQuota = share, over
+		cur[w.name] = u
+
+		metrics.TenantConnectionSeconds.WithLabelValues(w.name).Add((u.ConnectionMinutes - p.ConnectionMinutes) * 60)
+		metrics.TenantMessages.WithLabelValues(w.name).Add(float64(u.Messages - p.Messages))
+		metrics.TenantBytes.WithLabelValues(w.name, "in").Add(float64(u.BytesIn - p.BytesIn))
+		metrics.TenantBytes.WithLabelValues(w.name, "out").Add(float64(u.BytesOut - p.BytesOut))
+		metrics.TenantTickSeconds.WithLabelValues(w.name).Add(u.TickSeconds - p.TickSeconds)
+		metrics.TenantTickShare.WithLabelValues(w.name).Set(share)
+		metrics.TenantOverQuota.WithLabelValues(w.name).Set(boolGauge(over))
+
+		if prev != nil {
+			slog.Info("tenant usage",
+				"tenant", w.name,
+				"connection_minutes", u.ConnectionMinutes-p.ConnectionMinutes,
+				"messages", u.Messages-p.Messages,
+				"bytes_in", u.BytesIn-p.BytesIn,
+				"bytes_out", u.BytesOut-p.BytesOut,
+				"tick_seconds", u.TickSeconds-p.TickSeconds,
+				"tick_share", share,
+				"over_quota", over)
+		}
+	}
+	return cur
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleAdminUsage returns the usage since start of this world and, on the host, of
+// every tenant:
+//
+//	GET /admin/usage → [{"tenant", "connection_minutes", "messages", …}]
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	worlds := s.worlds()
+	out := make([]Usage, len(worlds))
+	for i, wd := range worlds {
+		out[i] = wd.srv.usageTotals()
+		out[i].Tenant = wd.name
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/tenant"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestTenantUsage(t *testing.T) {
+	const key = "acme-key-0123456789"
+	s := New(testutil.Config(), nil)
+	s.cfg.Server.AdminToken = "host-token"
+	cfg := testutil.Config()
+	cfg.Server.AdminToken = "acme-admin-0123456789"
+	s.AddTenant(tenant.Tenant{Name: "acme", APIKey: key, AdminKey: cfg.Server.AdminToken, Quotas: tenant.Quotas{MaxTickShare: 0.5}}, cfg, nil)
+	acme := s.tenants[0].srv
+	for _, srv := range []*Server{s, acme} {
+		srv.gameWorld.SetPaused(true)
+	}
+	t.Cleanup(func() {
+		for _, srv := range []*Server{s, acme} {
+			srv.cancel()
+			srv.gameWorld.Stop()
+		}
+	})
+	h := s.routes()[0].handler()
+
+	prev := s.reportUsage(nil)
+	// Only the tenant's world ticks this period: all of the tick time is its.
+	for range 5 {
+		acme.gameWorld.Step()
+	}
+	acme.countMessage(12)
+	acme.countMessage(30)
+	cur := s.reportUsage(prev)
+	if u := cur["acme"]; u.TickShare != 1 || !u.OverQuota || u.Messages != 2 || u.BytesIn != 42 {
+		t.Errorf("acme usage %+v, want the whole tick share, over quota, 2 messages of 42 bytes", u)
+	}
+	if u := cur[tenant.Host]; u.TickShare != 0 || u.OverQuota {
+		t.Errorf("host usage %+v", u)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws?key="+key, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/ws over quota: %d, want 503", rec.Code)
+	}
+
+	// A quiet period: the tenant is back under its quota.
+	s.reportUsage(cur)
+	if acme.usage.overQuota.Load() {
+		t.Error("still over quota after a period without ticks")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/usage", nil)
+	req.Header.Set("Authorization", "Bearer host-token")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var list []Usage
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 || list[0].Tenant != tenant.Host || list[1].Tenant != "acme" || list[1].Messages != 2 {
+		t.Errorf("/admin/usage: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"pixi_game_server/internal/metrics"
+)
+
+// VIEWPORT_UPDATE rate limiting.
+//
+// A viewport change re-subscribes the client to a different set of visibility cells,
+// so it gets its own budget (Net.ViewportRateLimit/ViewportBurst) on top of the
+// per-connection message limit. An update over the budget is not dropped — the last
+// one is what the client actually shows — but deferred until the budget allows it;
+// updates that arrive while one is deferred only replace its size. The world then
+// clamps the size and rounds it to whole cells (game.GameWorld.SetViewport).
+
+// viewportState — rate limit of one connection's viewport updates.
+type viewportState struct {
+	limiter *rate.Limiter
+
+	mu      sync.Mutex
+	pending bool   // an update is deferred; w, h hold its latest size
+	w, h    uint16 // size to apply when the deferred update fires
+}
+
+var (
+	viewportDeferred  = metrics.ViewportUpdates.WithLabelValues("deferred")
+	viewportCoalesced = metrics.ViewportUpdates.WithLabelValues("coalesced")
+)
+
+// newViewportState returns nil when viewport updates are not limited.
+func (s *Server) newViewportState() *viewportState {
+	if s.cfg.Net.ViewportRateLimit <= 0 {
+		return nil
+	}
+	return &viewportState{limiter: rate.NewLimiter(rate.Limit(s.cfg.Net.ViewportRateLimit), s.cfg.Net.ViewportBurst)}
+}
+
+// handleViewport applies a VIEWPORT_UPDATE now or, over the budget, after the
+// limiter's delay with whatever size is the latest by then.
+func (s *Server) handleViewport(conn *Connection, width, height uint16) {
+	vs := conn.viewport
+	if vs == nil {
+		s.gameWorld.SetViewport(conn.player.ID, width, height)
+		return
+	}
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.w, vs.h = width, height
+	if vs.pending {
+		viewportCoalesced.Inc()
+		return
+	}
+	r := vs.limiter.Reserve()
+	if !r.OK() {
+		return
+	}
+	delay := r.Delay()
+	if delay <= 0 {
+		s.gameWorld.SetViewport(conn.player.ID, width, height)
+		return
+	}
+	vs.pending = true
+	viewportDeferred.Inc()
+	time.AfterFunc(delay, func() { s.flushViewport(conn) })
+}
+
+// flushViewport applies the deferred update unless the connection has closed since.
+func (s *Server) flushViewport(conn *Connection) {
+	vs := conn.viewport
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	vs.pending = false
+	if conn.ctx.Err() != nil {
+		return
+	}
+	s.gameWorld.SetViewport(conn.player.ID, vs.w, vs.h)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/protocol"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestViewportUpdatesRateLimited(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Server.HandoverSocket = ""
+	cfg.Net.ViewportRateLimit, cfg.Net.ViewportBurst = 20, 1
+	s := New(cfg, nil)
+	s.gameWorld.SetPaused(true)
+	s.rh = nopReadHandler{}
+	t.Cleanup(func() {
+		s.cancel()
+		s.gameWorld.Stop()
+	})
+
+	c := s.createConnection(testutil.NewFakeConn())
+	s.reserveHandshake()
+	s.completeJoin(c, &protocol.ClientMessage{Type: protocol.MessageJoin, Capabilities: protocol.CapsLegacy})
+
+	s.handleViewport(c, 400, 300)
+	if w, h := c.player.GetViewSize(); w != 400 || h != 300 {
+		t.Fatalf("first update: view %d×%d, want 400×300 at once", w, h)
+	}
+	s.handleViewport(c, 500, 300)
+	s.handleViewport(c, 700, 300)
+	if w, _ := c.player.GetViewSize(); w != 400 {
+		t.Fatalf("updates over the budget applied at once: width %d", w)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if w, _ := c.player.GetViewSize(); w == 700 {
+			break
+		}
+		if time.Now().After(deadline) {
+			w, h := c.player.GetViewSize()
+			t.Fatalf("deferred update: view %d×%d, want the latest 700×300", w, h)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
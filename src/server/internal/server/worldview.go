@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/metrics"
+)
+
+// Live world view for dashboards and minimap pages.
+//
+// GET /world returns every player of the latest tick snapshot as JSON
+// (?format=binary: the GAME_STATE wire message, decodable with the generated
+// TypeScript codec). GET /world/stream is the same JSON as Server-Sent Events,
+// one "world" event every Server.WorldViewInterval. Both read the published tick
+// snapshot, never the live world, and the JSON is encoded at most once per tick no
+// matter how many dashboards are watching.
+//
+// The endpoints expose every player's position, so they are off unless WORLD_VIEW=1;
+// put them behind the reverse proxy's auth if the page is not meant to be public.
+
+// WorldViewPlayer — one player in the /world snapshot.
+type WorldViewPlayer struct {
+	ID          uint32 `json:"id"`
+	X           uint32 `json:"x"`
+	Y           uint32 `json:"y"`
+	VX          int8   `json:"vx"`
+	VY          int8   `json:"vy"`
+	FacingRight bool   `json:"facing_right"`
+	State       string `json:"state"`
+	Bot         bool   `json:"bot"`
+}
+
+// WorldView — the /world snapshot.
+type WorldView struct {
+	Tick    uint32            `json:"tick"`
+	Time    time.Time         `json:"time"`
+	Width   uint32            `json:"width"`
+	Height  uint32            `json:"height"`
+	Players []WorldViewPlayer `json:"players"`
+}
+
+// worldViewCache — the JSON of the last snapshot served.
+type worldViewCache struct {
+	mu    sync.Mutex
+	tick  uint32
+	body  []byte
+	valid bool
+}
+
+// worldViewJSON returns the JSON snapshot of the latest tick.
+func (s *Server) worldViewJSON() []byte {
+	snap := s.gameWorld.AcquireSnapshot()
+	defer snap.Release()
+
+	c := &s.worldView
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.valid && c.tick == snap.Tick {
+		return c.body
+	}
+	bounds := s.gameWorld.Bounds()
+	view := WorldView{
+		Tick:    snap.Tick,
+		Time:    time.Now().UTC(),
+		Width:   bounds.Width,
+		Height:  bounds.Height,
+		Players: make([]WorldViewPlayer, len(snap.Players)),
+	}
+	for i, p := range snap.Players {
+		view.Players[i] = WorldViewPlayer{
+			ID:          p.ID,
+			X:           p.X,
+			Y:           p.Y,
+			VX:          p.VX,
+			VY:          p.VY,
+			FacingRight: p.FacingRight,
+			State:       game.StateName(p.State),
+			Bot:         game.IsBotID(p.ID),
+		}
+	}
+	body, _ := json.Marshal(view) // plain structs, cannot fail
+	c.tick, c.body, c.valid = snap.Tick, body, true
+	return body
+}
+
+// handleWorld serves the current world snapshot.
+func (s *Server) handleWorld(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		metrics.WorldViewRequests.WithLabelValues("json").Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.worldViewJSON())
+	case "binary":
+		if s.largeWorld {
+			// GAME_STATE positions are 16-bit; a large world only fits them per client (largeworld.go).
+			http.Error(w, "format=binary does not fit a world this large, use json", http.StatusBadRequest)
+			return
+		}
+		metrics.WorldViewRequests.WithLabelValues("binary").Inc()
+		snap := s.gameWorld.AcquireSnapshot()
+		data := s.protocol.EncodeGameState(snap.Players, snap.Tick)
+		snap.Release()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(data)
+	default:
+		http.Error(w, "format must be json or binary", http.StatusBadRequest)
+	}
+}
+
+// handleWorldStream streams the world snapshot as Server-Sent Events.
+func (s *Server) handleWorldStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	limit := int32(s.cfg.Server.WorldViewMaxStreams)
+	if n := atomic.AddInt32(&s.worldViewStreams, 1); limit > 0 && n > limit {
+		atomic.AddInt32(&s.worldViewStreams, -1)
+		http.Error(w, "Too many world streams", http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt32(&s.worldViewStreams, -1)
+	metrics.WorldViewRequests.WithLabelValues("stream").Inc()
+	metrics.WorldViewStreams.Inc()
+	defer metrics.WorldViewStreams.Dec()
+
+	interval := s.cfg.Server.WorldViewInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	// The client's EventSource reconnects after this delay if the stream drops.
+	fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := fmt.Fprintf(w, "event: world\ndata: %s\n\n", s.worldViewJSON()); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pixi_game_server/internal/protocol"
+)
+
+func TestHandleWorld(t *testing.T) {
+	s := newSessionServer(t, "")
+	c, _ := joinAccount(s, "")
+	s.gameWorld.Step()
+
+	rec := httptest.NewRecorder()
+	s.handleWorld(rec, httptest.NewRequest(http.MethodGet, "/world", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var view WorldView
+	if err := json.Unmarshal(rec.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	if len(view.Players) != 1 || view.Players[0].ID != c.player.ID || view.Players[0].State != "idle" {
+		t.Errorf("players = %+v, want the joined player idle", view.Players)
+	}
+	if view.Width != s.cfg.World.Width || view.Height != s.cfg.World.Height {
+		t.Errorf("world size = %dx%d", view.Width, view.Height)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleWorld(rec, httptest.NewRequest(http.MethodGet, "/world?format=binary", nil))
+	if body := rec.Body.Bytes(); len(body) == 0 || body[0] != protocol.MessageGameState {
+		t.Errorf("binary body = % x, want GAME_STATE", body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleWorld(rec, httptest.NewRequest(http.MethodGet, "/world?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown format status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"math"
+	"time"
+
+	"pixi_game_server/internal/config"
+)
+
+// Write batching per message class.
+//
+// The write loop of a connection coalesces queued jobs into one writev. How long it
+// may hold them back depends on what they are: a MOVEMENT_ACK can wait a few
+// milliseconds for the next world-state frame, a PLAYER_JOINED must not. Each class
+// has its own limits (NetworkConfig.StateBatch / MovementBatch / EventBatch): the loop
+// flushes everything it holds once any class reaches its Size or the oldest job of a
+// class has waited its Timeout. With every Timeout at 0 (the default) the loop never
+// waits and only takes what is already queued — the behaviour before classes existed.
+//
+// A world-state frame stays "pending" while the loop holds it, so newer snapshots are
+// shed (see enqueueBroadcastJob) — the state class is best left without a timeout.
+//
+// A batch of several frames totalling at most Net.WriteCoalesceBytes is copied into
+// one buffer and written with a single Write instead of writev. writev only exists for
+// plain TCP: on a TLS connection net.Buffers falls back to one Write — one TLS record,
+// one syscall — per header and per payload. Even on plain TCP copying a few small
+// frames is cheaper than the kernel walking their iovecs: BenchmarkWriteCoalesce
+// measured about 2× on loopback TCP and 10–20× on TLS for eight 40-byte messages. Every
+// message keeps its own WebSocket frame: the seq and CRC headers, and the client's
+// decoder, work per message.
+
+// writeClass — класс исходящего сообщения. Нулевое значение — event: никогда не ждёт.
+type writeClass uint8
+
+const (
+	writeClassEvent    writeClass = iota // joins, leaves, world events, control frames, everything else
+	writeClassState                      // world-state frames (tick broadcast)
+	writeClassMovement                   // MOVEMENT_ACK
+	numWriteClasses
+)
+
+// writeBatchLimits — лимиты классов, индекс — writeClass.
+type writeBatchLimits [numWriteClasses]config.BatchConfig
+
+func newWriteBatchLimits(net *config.NetworkConfig) writeBatchLimits {
+	var l writeBatchLimits
+	l[writeClassEvent] = net.EventBatch
+	l[writeClassState] = net.StateBatch
+	l[writeClassMovement] = net.MovementBatch
+	for i := range l {
+		l[i].Size = min(max(l[i].Size, 1), maxWriteBatchSizeLimit)
+		l[i].Timeout = max(l[i].Timeout, 0)
+	}
+	return l
+}
+
+// capacity — сколько jobs write loop может держать: наибольший Size класса.
+func (l *writeBatchLimits) capacity() int {
+	n := 1
+	for i := range l {
+		n = max(n, l[i].Size)
+	}
+	return n
+}
+
+// waits сообщает, может ли write loop вообще ждать (есть класс с Timeout > 0).
+func (l *writeBatchLimits) waits() bool {
+	for i := range l {
+		if l[i].Timeout > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBatch — учёт jobs, которые write loop держит, по классам. Только write loop.
+type writeBatch struct {
+	limits *writeBatchLimits
+	count  [numWriteClasses]int
+	oldest [numWriteClasses]int64 // UnixNano первого job класса в батче
+}
+
+// add учитывает job класса class, поставленный в батч в nowNs. true — батч пора писать:
+// класс набрал Size или не ждёт вовсе.
+func (b *writeBatch) add(class writeClass, nowNs int64) bool {
+	if b.count[class] == 0 {
+		b.oldest[class] = nowNs
+	}
+	b.count[class]++
+	l := &b.limits[class]
+	return b.count[class] >= l.Size || l.Timeout <= 0
+}
+
+// wait возвращает, сколько ещё батч может ждать новых jobs; <= 0 — писать сейчас.
+func (b *writeBatch) wait(nowNs int64) time.Duration {
+	wait := time.Duration(math.MaxInt64)
+	for c := range b.count {
+		if b.count[c] > 0 {
+			wait = min(wait, b.limits[c].Timeout-time.Duration(nowNs-b.oldest[c]))
+		}
+	}
+	return wait
+}
+
+func (b *writeBatch) reset() {
+	b.count = [numWriteClasses]int{}
+}
+
+// coalesceFrames appends frames to flat, growing it as needed, and returns it.
+func coalesceFrames(flat []byte, frames [][]byte) []byte {
+	for _, f := range frames {
+		flat = append(flat, f...)
+	}
+	return flat
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/testutil"
+)
+
+func TestWriteBatchFlushesPerClass(t *testing.T) {
+	limits := newWriteBatchLimits(&config.NetworkConfig{
+		StateBatch:    config.BatchConfig{Size: 4},
+		MovementBatch: config.BatchConfig{Size: 3, Timeout: 10 * time.Millisecond},
+		EventBatch:    config.BatchConfig{Size: 8},
+	})
+	if got := limits.capacity(); got != 8 {
+		t.Fatalf("capacity = %d, want the largest class size 8", got)
+	}
+	b := writeBatch{limits: &limits}
+
+	now := time.Now().UnixNano()
+	if b.add(writeClassMovement, now) || b.add(writeClassMovement, now+int64(4*time.Millisecond)) {
+		t.Fatal("movement batch flushed before its size or timeout")
+	}
+	if wait := b.wait(now + int64(4*time.Millisecond)); wait != 6*time.Millisecond {
+		t.Fatalf("wait = %v, want the rest of the oldest job's timeout", wait)
+	}
+	if !b.add(writeClassMovement, now) {
+		t.Fatal("movement batch did not flush at its size")
+	}
+
+	b.reset()
+	b.add(writeClassMovement, now)
+	if !b.add(writeClassEvent, now) {
+		t.Fatal("an event must flush the batch at once")
+	}
+}
+
+func TestWriteLoopHoldsMovementUntilEvent(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.Net.MovementBatch = config.BatchConfig{Size: 8, Timeout: time.Hour}
+	s := &Server{
+		cfg:                cfg,
+		ctx:                context.Background(),
+		rh:                 nopReadHandler{},
+		directWriteTimeout: time.Second,
+		writeBatches:       newWriteBatchLimits(&cfg.Net),
+	}
+	s.writeBatchSize = s.writeBatches.capacity()
+	fake := testutil.NewFakeConn()
+	c := s.createConnection(fake)
+	defer c.cancel()
+
+	s.sendDirectClass(c, []byte{0x0B}, writeClassMovement)
+	time.Sleep(20 * time.Millisecond)
+	if n := len(fake.Written()); n != 0 {
+		t.Fatalf("wrote %d bytes, want the movement message held back", n)
+	}
+
+	s.sendDirect(c, []byte{0x0C})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		frames, err := fake.Frames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) == 2 {
+			checkDataFrame(t, frames[0], 1, []byte{0x0B})
+			checkDataFrame(t, frames[1], 2, []byte{0x0C})
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("wrote %d frames, want the held movement message and the event", len(frames))
+		}
+	}
+}
+
+func TestWriteCoalesceKeepsFrames(t *testing.T) {
+	written := func(coalesce int) []byte {
+		cfg := testutil.Config()
+		cfg.Net.MovementBatch = config.BatchConfig{Size: 8, Timeout: time.Hour}
+		cfg.Net.WriteCoalesceBytes = coalesce
+		s := &Server{
+			cfg:                cfg,
+			ctx:                context.Background(),
+			rh:                 nopReadHandler{},
+			directWriteTimeout: time.Second,
+			writeBatches:       newWriteBatchLimits(&cfg.Net),
+		}
+		s.writeBatchSize = s.writeBatches.capacity()
+		fake := testutil.NewFakeConn()
+		c := s.createConnection(fake)
+		defer c.cancel()
+
+		s.sendDirectClass(c, []byte{0x0B, 1}, writeClassMovement)
+		s.sendDirectClass(c, []byte{0x0B, 2}, writeClassMovement)
+		s.sendDirect(c, []byte{0x0C})
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+			if frames, err := fake.Frames(); err != nil || len(frames) == 3 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return fake.Written()
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("WRITE_COALESCE_BYTES=%d: the batch of three messages was not written", coalesce)
+			}
+		}
+	}
+	if writev, copied := written(0), written(4096); string(writev) != string(copied) {
+		t.Errorf("coalesced batch % x, want the frames writev sends: % x", copied, writev)
+	}
+}
+
+// BenchmarkWriteCoalesce compares a typical batch — eight small messages, each a
+// frame header and a payload — written with writev against the batch copied into one
+// buffer (WRITE_COALESCE_BYTES), over loopback TCP and TLS.
+func BenchmarkWriteCoalesce(b *testing.B) {
+	var frames [][]byte
+	for i := range 8 {
+		payload := make([]byte, 40)
+		frames = append(frames, appendFrameHeader(nil, len(payload), uint32(i), false, 0), payload)
+	}
+	scratch := make(net.Buffers, 0, len(frames))
+	modes := []struct {
+		name  string
+		write func(conn net.Conn, flat []byte) ([]byte, error)
+	}{
+		{"writev", func(conn net.Conn, flat []byte) ([]byte, error) {
+			buffers := append(scratch[:0], frames...) // WriteTo consumes the slices it writes
+			_, err := buffers.WriteTo(conn)
+			return flat, err
+		}},
+		{"copy", func(conn net.Conn, flat []byte) ([]byte, error) {
+			flat = coalesceFrames(flat[:0], frames)
+			_, err := conn.Write(flat)
+			return flat, err
+		}},
+	}
+	for _, transport := range []string{"tcp", "tls"} {
+		for _, mode := range modes {
+			b.Run(transport+"/"+mode.name, func(b *testing.B) {
+				conn := benchConn(b, transport == "tls")
+				b.SetBytes(int64(buffersLen(frames)))
+				var flat []byte
+				var err error
+				for b.Loop() {
+					if flat, err = mode.write(conn, flat); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// benchConn returns the client end of a loopback connection whose server end
+// discards what it reads.
+func benchConn(b *testing.B, useTLS bool) net.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ln.Close() })
+	if useTLS {
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{benchCert(b)}})
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, conn)
+		conn.Close()
+	}()
+	var conn net.Conn
+	if useTLS {
+		conn, err = tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = net.Dial("tcp", ln.Addr().String())
+	}
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// benchCert returns a self-signed certificate for 127.0.0.1.
+func benchCert(b *testing.B) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,254 @@
+// Package social keeps the friend lists of player accounts.
+//
+// A friendship is two requests: an account asks another one, and it is a friendship
+// once the other asks back (accepts). Until then the request is outgoing for the asker
+// and incoming for the other account. Removing ends the friendship or the request in
+// both directions, so it also declines an incoming request.
+//
+// The storage is a log like the moderation audit log: every change is appended as one
+// JSON line to the log file and fsynced before it takes effect, and Open replays the
+// file. Accounts are the IDs of internal/auth; anonymous players have no friends.
+package social
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pixi_game_server/internal/auth"
+	"pixi_game_server/internal/jsonlog"
+)
+
+// Errors of the Store operations; the text is shown to the player.
+var (
+	ErrAccount = errors.New("not a valid account name")
+	ErrSelf    = errors.New("you cannot befriend yourself")
+	ErrLimit   = errors.New("too many friends and requests")
+	ErrNone    = errors.New("not a friend, and no request")
+)
+
+// Kind — what another account is to an account.
+type Kind string
+
+const (
+	KindFriend   Kind = "friend"   // both asked
+	KindIncoming Kind = "incoming" // the other account asked, not answered yet
+	KindOutgoing Kind = "outgoing" // the account asked, not answered yet
+)
+
+// Relation — another account and what it is to the account asked about.
+type Relation struct {
+	Account string `json:"account"`
+	Kind    Kind   `json:"kind"`
+}
+
+// Entry — one log record.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // "add" or "remove"
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+}
+
+// Store — friend requests of every account. Safe for concurrent use.
+type Store struct {
+	maxRelations int
+
+	mu   sync.RWMutex
+	log  *jsonlog.Log[Entry]            // nil: in memory only
+	asks map[string]map[string]struct{} // asker → accounts asked
+	// askedBy mirrors asks: account → accounts that asked it.
+	askedBy map[string]map[string]struct{}
+}
+
+// Open loads the log at path and keeps it open for appending. An account has at most
+// maxRelations friends and requests, both directions counted (0 = no limit). An
+// empty path gives a store that lives in memory only.
+func Open(path string, maxRelations int) (*Store, error) {
+	s := &Store{
+		maxRelations: maxRelations,
+		asks:         make(map[string]map[string]struct{}),
+		askedBy:      make(map[string]map[string]struct{}),
+	}
+	if path == "" {
+		return s, nil
+	}
+	log, err := jsonlog.Open(path, func(e Entry) {
+		s.apply(e)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("social: %w", err)
+	}
+	s.log = log
+	return s, nil
+}
+
+// Close closes the log file.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// Reopen opens the log at path for appending again after Close. The store keeps its
+// requests: the log holds nothing they do not.
+func (s *Store) Reopen(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil || path == "" {
+		return nil
+	}
+	log, err := jsonlog.Open(path, func(Entry) {})
+	if err != nil {
+		return fmt.Errorf("social: %w", err)
+	}
+	s.log = log
+	return nil
+}
+
+// Add records that from asks to to be friends: a new request, or the acceptance of
+// to's request. It returns what to is to from now; asking again changes nothing.
+func (s *Store) Add(from, to string) (Kind, error) {
+	if !auth.ValidAccount(from) || !auth.ValidAccount(to) {
+		return "", ErrAccount
+	}
+	if from == to {
+		return "", ErrSelf
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.asks[from][to]; ok {
+		return s.kind(from, to), nil
+	}
+	if _, accepting := s.asks[to][from]; !accepting && s.maxRelations > 0 &&
+		(s.count(from) >= s.maxRelations || s.count(to) >= s.maxRelations) {
+		return "", ErrLimit
+	}
+	if err := s.record(Entry{Time: time.Now().UTC(), Action: "add", From: from, To: to}); err != nil {
+		return "", err
+	}
+	return s.kind(from, to), nil
+}
+
+// Remove ends the friendship of a and b, or a request between them either way.
+func (s *Store) Remove(a, b string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ab := s.asks[a][b]
+	_, ba := s.asks[b][a]
+	if !ab && !ba {
+		return ErrNone
+	}
+	return s.record(Entry{Time: time.Now().UTC(), Action: "remove", From: a, To: b})
+}
+
+// Relations returns the friends and requests of account, sorted by account.
+func (s *Store) Relations(account string) []Relation {
+	s.mu.RLock()
+	out := make([]Relation, 0, s.count(account))
+	for other := range s.asks[account] {
+		out = append(out, Relation{Account: other, Kind: s.kind(account, other)})
+	}
+	for other := range s.askedBy[account] {
+		if _, asked := s.asks[account][other]; !asked {
+			out = append(out, Relation{Account: other, Kind: KindIncoming})
+		}
+	}
+	s.mu.RUnlock()
+	slices.SortFunc(out, func(a, b Relation) int { return strings.Compare(a.Account, b.Account) })
+	return out
+}
+
+// Friends returns the accounts that are friends of account, in no particular order.
+func (s *Store) Friends(account string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []string
+	for other := range s.asks[account] {
+		if _, ok := s.asks[other][account]; ok {
+			out = append(out, other)
+		}
+	}
+	return out
+}
+
+// Kind returns what b is to a; "" = nothing.
+func (s *Store) Kind(a, b string) Kind {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.kind(a, b)
+}
+
+func (s *Store) kind(a, b string) Kind {
+	_, ab := s.asks[a][b]
+	_, ba := s.asks[b][a]
+	switch {
+	case ab && ba:
+		return KindFriend
+	case ab:
+		return KindOutgoing
+	case ba:
+		return KindIncoming
+	}
+	return ""
+}
+
+// count returns the number of accounts account is related to. Caller holds mu.
+func (s *Store) count(account string) int {
+	n := len(s.asks[account])
+	for other := range s.askedBy[account] {
+		if _, asked := s.asks[account][other]; !asked {
+			n++
+		}
+	}
+	return n
+}
+
+// record appends e to the log and applies it. Caller holds mu.
+func (s *Store) record(e Entry) error {
+	if s.log != nil {
+		if err := s.log.Append(e); err != nil {
+			return err
+		}
+	}
+	s.apply(e)
+	return nil
+}
+
+// apply updates the requests with e. Caller holds mu (or owns s).
+func (s *Store) apply(e Entry) {
+	switch e.Action {
+	case "add":
+		link(s.asks, e.From, e.To)
+		link(s.askedBy, e.To, e.From)
+	case "remove":
+		unlink(s.asks, e.From, e.To)
+		unlink(s.asks, e.To, e.From)
+		unlink(s.askedBy, e.From, e.To)
+		unlink(s.askedBy, e.To, e.From)
+	}
+}
+
+func link(m map[string]map[string]struct{}, a, b string) {
+	set := m[a]
+	if set == nil {
+		set = make(map[string]struct{})
+		m[a] = set
+	}
+	set[b] = struct{}{}
+}
+
+func unlink(m map[string]map[string]struct{}, a, b string) {
+	delete(m[a], b)
+	if len(m[a]) == 0 {
+		delete(m, a)
+	}
+}
@@ -0,0 +1,67 @@
+package social
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "friends.jsonl")
+	s, err := Open(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("alice", "alice"); !errors.Is(err, ErrSelf) {
+		t.Errorf("self request: %v", err)
+	}
+	if _, err := s.Add("alice", "bad name"); !errors.Is(err, ErrAccount) {
+		t.Errorf("request to an invalid account: %v", err)
+	}
+	if kind, err := s.Add("alice", "bob"); err != nil || kind != KindOutgoing {
+		t.Fatalf("request = %q, %v", kind, err)
+	}
+	if kind := s.Kind("bob", "alice"); kind != KindIncoming {
+		t.Errorf("bob sees alice as %q, want incoming", kind)
+	}
+	if kind, err := s.Add("bob", "alice"); err != nil || kind != KindFriend {
+		t.Fatalf("accept = %q, %v", kind, err)
+	}
+	if _, err := s.Add("carol", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("alice", "dave"); !errors.Is(err, ErrLimit) {
+		t.Errorf("request over the limit: %v", err)
+	}
+	if _, err := s.Add("dave", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("carol", "dave"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove("carol", "dave"); !errors.Is(err, ErrNone) {
+		t.Errorf("second remove: %v", err)
+	}
+	s.Close()
+
+	s, err = Open(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	want := []Relation{{"bob", KindFriend}, {"carol", KindIncoming}}
+	if got := s.Relations("alice"); !reflect.DeepEqual(got, want) {
+		t.Errorf("alice's relations after reopen = %+v, want %+v", got, want)
+	}
+	if got := s.Friends("bob"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("bob's friends = %v", got)
+	}
+	if got := s.Relations("dave"); len(got) != 0 {
+		t.Errorf("dave's declined request kept: %+v", got)
+	}
+	// Accepting is allowed at the limit.
+	if kind, err := s.Add("alice", "carol"); err != nil || kind != KindFriend {
+		t.Errorf("accept at the limit = %q, %v", kind, err)
+	}
+}
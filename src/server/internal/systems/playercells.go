@@ -0,0 +1,59 @@
+package systems
+
+import "sync"
+
+// playerCellShards — число шардов playerCellMap (степень двойки). ID игроков идут
+// подряд, так что младшие биты раскладывают их по шардам равномерно.
+const playerCellShards = 64
+
+// playerCellMap — playerID → ячейка сетки, шардированный map под RWMutex.
+//
+// Заменяет sync.Map: тот упаковывает каждое значение в interface и выделяет память на
+// каждый Store, а MovePlayer пишет при каждом переходе игрока в другую ячейку — из
+// всех tick worker'ов сразу. Здесь значения лежат в map по месту, а worker'ы
+// расходятся по разным шардам.
+type playerCellMap struct {
+	shards [playerCellShards]playerCellShard
+}
+
+type playerCellShard struct {
+	mu    sync.RWMutex
+	cells map[uint32]playerCell
+	_     [32]byte // соседние шарды — в разных cache line
+}
+
+func (m *playerCellMap) shard(playerID uint32) *playerCellShard {
+	return &m.shards[playerID&(playerCellShards-1)]
+}
+
+// load возвращает ячейку игрока.
+func (m *playerCellMap) load(playerID uint32) (playerCell, bool) {
+	s := m.shard(playerID)
+	s.mu.RLock()
+	pc, ok := s.cells[playerID]
+	s.mu.RUnlock()
+	return pc, ok
+}
+
+// swap записывает ячейку игрока и возвращает прежнюю.
+func (m *playerCellMap) swap(playerID uint32, pc playerCell) (old playerCell, loaded bool) {
+	s := m.shard(playerID)
+	s.mu.Lock()
+	if s.cells == nil {
+		s.cells = make(map[uint32]playerCell)
+	}
+	old, loaded = s.cells[playerID]
+	s.cells[playerID] = pc
+	s.mu.Unlock()
+	return old, loaded
+}
+
+// loadAndDelete удаляет игрока и возвращает его ячейку.
+func (m *playerCellMap) loadAndDelete(playerID uint32) (playerCell, bool) {
+	s := m.shard(playerID)
+	s.mu.Lock()
+	pc, ok := s.cells[playerID]
+	delete(s.cells, playerID)
+	s.mu.Unlock()
+	return pc, ok
+}
@@ -2,6 +2,7 @@ package systems
 
 import (
 	"log/slog"
+	"sort"
 	"sync"
 )
 
@@ -15,23 +16,23 @@ type gridCell struct {
 
 // playerCell хранит текущую ячейку игрока, чтобы знать откуда его убирать при движении.
 type playerCell struct {
-	gridX, gridY uint16
+	gridX, gridY uint32
 }
 
 // VisibilityManager управляет пространственной сеткой для O(1) поиска соседей.
 // Вместо O(N) перебора всех игроков — проверяются только ячейки в пределах viewport.
 type VisibilityManager struct {
-	gridSize   uint16
-	gridWidth  uint16
-	gridHeight uint16
+	gridSize   uint32
+	gridWidth  uint32
+	gridHeight uint32
 	cells      []gridCell // flat array: cells[gy*gridWidth + gx]
 
-	// playerCells: playerID → текущая ячейка (для перемещения)
-	playerCells sync.Map
+	// playerCells: playerID → текущая ячейка (для перемещения), см. playercells.go
+	playerCells playerCellMap
 }
 
 // NewVisibilityManager создает менеджер видимости.
-func NewVisibilityManager(worldWidth, worldHeight, gridSize uint16) *VisibilityManager {
+func NewVisibilityManager(worldWidth, worldHeight, gridSize uint32) *VisibilityManager {
 	gridW := (worldWidth + gridSize - 1) / gridSize
 	gridH := (worldHeight + gridSize - 1) / gridSize
 
@@ -46,7 +47,7 @@ func NewVisibilityManager(worldWidth, worldHeight, gridSize uint16) *VisibilityM
 	return vm
 }
 
-func (vm *VisibilityManager) worldToGrid(x, y uint16) (uint16, uint16) {
+func (vm *VisibilityManager) worldToGrid(x, y uint32) (uint32, uint32) {
 	gx := x / vm.gridSize
 	gy := y / vm.gridSize
 	if gx >= vm.gridWidth {
@@ -58,55 +59,142 @@ func (vm *VisibilityManager) worldToGrid(x, y uint16) (uint16, uint16) {
 	return gx, gy
 }
 
-func (vm *VisibilityManager) cellIndex(gx, gy uint16) int {
+func (vm *VisibilityManager) cellIndex(gx, gy uint32) int {
 	return int(gy)*int(vm.gridWidth) + int(gx)
 }
 
 // AddPlayer регистрирует игрока в сетке при подключении.
-func (vm *VisibilityManager) AddPlayer(playerID uint32, x, y uint16) {
+func (vm *VisibilityManager) AddPlayer(playerID uint32, x, y uint32) {
 	gx, gy := vm.worldToGrid(x, y)
 	vm.addToCell(gx, gy, playerID)
-	vm.playerCells.Store(playerID, playerCell{gx, gy})
+	vm.playerCells.swap(playerID, playerCell{gx, gy})
 }
 
 // RemovePlayer удаляет игрока из сетки при отключении.
 func (vm *VisibilityManager) RemovePlayer(playerID uint32) {
-	if val, ok := vm.playerCells.LoadAndDelete(playerID); ok {
-		pc := val.(playerCell)
+	if pc, ok := vm.playerCells.loadAndDelete(playerID); ok {
 		vm.removeFromCell(pc.gridX, pc.gridY, playerID)
 	}
 }
 
 // MovePlayer обновляет позицию игрока в сетке.
 // Вызывается только когда позиция реально изменилась — не каждый тик.
-func (vm *VisibilityManager) MovePlayer(playerID uint32, newX, newY uint16) {
+func (vm *VisibilityManager) MovePlayer(playerID uint32, newX, newY uint32) {
 	newGX, newGY := vm.worldToGrid(newX, newY)
 
-	val, ok := vm.playerCells.Load(playerID)
-	if !ok {
-		vm.addToCell(newGX, newGY, playerID)
-		vm.playerCells.Store(playerID, playerCell{newGX, newGY})
-		return
-	}
-
-	pc := val.(playerCell)
-	if pc.gridX == newGX && pc.gridY == newGY {
+	next := playerCell{newGX, newGY}
+	if pc, ok := vm.playerCells.load(playerID); ok && pc == next {
 		return // Остались в той же ячейке — ничего не делаем
 	}
 
-	vm.removeFromCell(pc.gridX, pc.gridY, playerID)
+	if pc, ok := vm.playerCells.swap(playerID, next); ok {
+		vm.removeFromCell(pc.gridX, pc.gridY, playerID)
+	}
 	vm.addToCell(newGX, newGY, playerID)
-	vm.playerCells.Store(playerID, playerCell{newGX, newGY})
 }
 
-func (vm *VisibilityManager) addToCell(gx, gy uint16, playerID uint32) {
+// CellSize возвращает сторону ячейки сетки в мировых единицах.
+func (vm *VisibilityManager) CellSize() uint32 {
+	return vm.gridSize
+}
+
+// CellSpan возвращает, сколько ячеек по каждой оси нужно, чтобы покрыть область
+// width×height: размер области интереса (AOI) в ячейках сетки, округлённый вверх.
+func (vm *VisibilityManager) CellSpan(width, height uint32) (cols, rows int) {
+	size := int(vm.gridSize)
+	return (int(width) + size - 1) / size, (int(height) + size - 1) / size
+}
+
+// CellPopulation возвращает число игроков в ячейке, содержащей точку (x, y).
+func (vm *VisibilityManager) CellPopulation(x, y uint32) int {
+	gx, gy := vm.worldToGrid(x, y)
+	cell := &vm.cells[vm.cellIndex(gx, gy)]
+	cell.mu.RLock()
+	n := len(cell.players)
+	cell.mu.RUnlock()
+	return n
+}
+
+// CellLoad — население одной ячейки сетки; X, Y — мировые координаты её левого верхнего угла.
+type CellLoad struct {
+	X       uint32 `json:"x"`
+	Y       uint32 `json:"y"`
+	Size    uint32 `json:"size"`
+	Players int    `json:"players"`
+}
+
+// TopCells возвращает до n самых населённых непустых ячеек по убыванию населения.
+// Обходит всю сетку — для отчётов, не для горячего пути.
+func (vm *VisibilityManager) TopCells(n int) []CellLoad {
+	var loads []CellLoad
+	for i := range vm.cells {
+		cell := &vm.cells[i]
+		cell.mu.RLock()
+		count := len(cell.players)
+		cell.mu.RUnlock()
+		if count == 0 {
+			continue
+		}
+		loads = append(loads, CellLoad{
+			X:       uint32(i%int(vm.gridWidth)) * vm.gridSize,
+			Y:       uint32(i/int(vm.gridWidth)) * vm.gridSize,
+			Size:    vm.gridSize,
+			Players: count,
+		})
+	}
+	sort.Slice(loads, func(a, b int) bool { return loads[a].Players > loads[b].Players })
+	if len(loads) > n {
+		loads = loads[:n]
+	}
+	return loads
+}
+
+// CellsIn вызывает fn для каждой ячейки сетки, пересекающейся с прямоугольником
+// [minX, maxX) × [minY, maxY). Ячейки локируются по одной, так что снимок не атомарен.
+func (vm *VisibilityManager) CellsIn(minX, minY, maxX, maxY uint32, fn func(CellLoad)) {
+	if minX >= maxX || minY >= maxY {
+		return
+	}
+	gx0, gy0 := vm.worldToGrid(minX, minY)
+	gx1, gy1 := vm.worldToGrid(maxX-1, maxY-1)
+	for gy := gy0; gy <= gy1; gy++ {
+		for gx := gx0; gx <= gx1; gx++ {
+			cell := &vm.cells[vm.cellIndex(gx, gy)]
+			cell.mu.RLock()
+			count := len(cell.players)
+			cell.mu.RUnlock()
+			fn(CellLoad{X: gx * vm.gridSize, Y: gy * vm.gridSize, Size: vm.gridSize, Players: count})
+		}
+	}
+}
+
+// AppendPlayersIn добавляет к dst игроков из ячеек, пересекающихся с прямоугольником
+// [minX, maxX) × [minY, maxY). Ячейки берутся целиком: точную позицию проверяет вызывающий.
+func (vm *VisibilityManager) AppendPlayersIn(dst []uint32, minX, minY, maxX, maxY uint32) []uint32 {
+	if minX >= maxX || minY >= maxY {
+		return dst
+	}
+	gx0, gy0 := vm.worldToGrid(minX, minY)
+	gx1, gy1 := vm.worldToGrid(maxX-1, maxY-1)
+	for gy := gy0; gy <= gy1; gy++ {
+		for gx := gx0; gx <= gx1; gx++ {
+			cell := &vm.cells[vm.cellIndex(gx, gy)]
+			cell.mu.RLock()
+			dst = append(dst, cell.players...)
+			cell.mu.RUnlock()
+		}
+	}
+	return dst
+}
+
+func (vm *VisibilityManager) addToCell(gx, gy uint32, playerID uint32) {
 	cell := &vm.cells[vm.cellIndex(gx, gy)]
 	cell.mu.Lock()
 	cell.players = append(cell.players, playerID)
 	cell.mu.Unlock()
 }
 
-func (vm *VisibilityManager) removeFromCell(gx, gy uint16, playerID uint32) {
+func (vm *VisibilityManager) removeFromCell(gx, gy uint32, playerID uint32) {
 	cell := &vm.cells[vm.cellIndex(gx, gy)]
 	cell.mu.Lock()
 	players := cell.players
@@ -0,0 +1,94 @@
+package systems_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"pixi_game_server/internal/systems"
+	"pixi_game_server/internal/testutil"
+)
+
+// Spatial grid benchmarks at the 10K-player target: MovePlayer runs for every
+// position change in a tick, CellsIn over the spawn area for every spawn.
+
+const (
+	benchWorldSize = 8192
+	benchCellSize  = 100 // game.visibilityCellSize
+	benchPlayers   = 10000
+)
+
+func newBenchGrid(b *testing.B) *systems.VisibilityManager {
+	b.Helper()
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(prev)
+
+	vm := systems.NewVisibilityManager(benchWorldSize, benchWorldSize, benchCellSize)
+	for _, p := range testutil.Players(benchPlayers, benchWorldSize, benchWorldSize) {
+		vm.AddPlayer(p.ID, p.X, p.Y)
+	}
+	return vm
+}
+
+// BenchmarkMovePlayer alternates each player between two cells, so every call moves
+// it across a cell border (the expensive case).
+func BenchmarkMovePlayer(b *testing.B) {
+	vm := newBenchGrid(b)
+	players := testutil.Players(benchPlayers, benchWorldSize, benchWorldSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := players[i%len(players)]
+		x := p.X
+		if (i/len(players))%2 == 0 {
+			x = (x + benchCellSize) % benchWorldSize
+		}
+		vm.MovePlayer(p.ID, x, p.Y)
+	}
+}
+
+// BenchmarkMovePlayerParallel is MovePlayer from concurrent tick workers.
+func BenchmarkMovePlayerParallel(b *testing.B) {
+	vm := newBenchGrid(b)
+	players := testutil.Players(benchPlayers, benchWorldSize, benchWorldSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			p := players[i%len(players)]
+			vm.MovePlayer(p.ID, (p.X+uint32(i%2)*benchCellSize)%benchWorldSize, p.Y)
+			i++
+		}
+	})
+}
+
+func BenchmarkCellPopulation(b *testing.B) {
+	vm := newBenchGrid(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.CellPopulation(uint32(i*37)%benchWorldSize, uint32(i*91)%benchWorldSize)
+	}
+}
+
+// BenchmarkCellsIn scans a 1000×1000 spawn area (100 cells), as pickSpawnPoint does.
+func BenchmarkCellsIn(b *testing.B) {
+	vm := newBenchGrid(b)
+	var players int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.CellsIn(3000, 3000, 4000, 4000, func(c systems.CellLoad) { players += c.Players })
+	}
+}
+
+func BenchmarkTopCells(b *testing.B) {
+	vm := newBenchGrid(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vm.TopCells(10)
+	}
+}
@@ -0,0 +1,192 @@
+// Package tenant describes the other games one server process hosts next to its own
+// world, for embedding the server as a service.
+//
+// A tenant is a namespace: an API key, config overrides and quotas. The server gives
+// every tenant a world of its own — its own players and player IDs, bots, parties,
+// moderation, friend, inventory and player record logs (under TENANT_DATA_DIR/<name>)
+// and leaderboard keys — built from the host's config with the tenant's overrides on
+// top (config.LoadTenant). Clients reach it with /ws?key=<API key>. The API key is
+// public — the web client takes it from the page URL — so it never opens the admin
+// API: that takes the tenant's admin_key, its ADMIN_TOKEN, which reaches the tenant's
+// admin API and nothing else. Without an admin_key only the host's ADMIN_TOKEN
+// administers the tenant.
+//
+// The tenants file is JSON:
+//
+//	[{"name": "acme", "api_key": "…", "admin_key": "…", "config": {"TICK_RATE": "30"},
+//	  "quotas": {"max_connections": 500, "max_bots": 20, "max_tick_share": 0.5}}]
+//
+// Settings of the process — listeners, TLS, logs, runtime tuning, handover, the world
+// map — are the host's; a tenant overriding one of them is an error.
+package tenant
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"pixi_game_server/internal/config"
+)
+
+// MinKeyLength — shorter API and admin keys are refused.
+const MinKeyLength = 16
+
+// Host — the name the host's own world goes by in usage reports; no tenant has it.
+const Host = "host"
+
+// Tenant — one hosted namespace.
+type Tenant struct {
+	Name     string            `json:"name"`
+	APIKey   string            `json:"api_key"`
+	AdminKey string            `json:"admin_key,omitempty"` // tenant's ADMIN_TOKEN; empty = host-administered only
+	Config   map[string]string `json:"config,omitempty"`    // env-style overrides
+	Quotas   Quotas            `json:"quotas"`
+}
+
+// Quotas cap what a tenant's config may ask for; 0 = the config's own value.
+// MaxTickShare is a fair-use limit instead: while the tenant's world took more than
+// this fraction of all worlds' tick time in the last usage period, its new
+// connections are refused; 0 = no limit.
+type Quotas struct {
+	MaxConnections int     `json:"max_connections"`
+	MaxBots        int     `json:"max_bots"`
+	MaxTickShare   float64 `json:"max_tick_share"`
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// hostOnly — settings of the process that tenants share and cannot override.
+var hostOnly = map[string]bool{
+	"HOST": true, "PORT": true, "WORKERS": true, "REUSEPORT_LISTENERS": true, "ADMIN_TOKEN": true,
+	"ADMIN_ADDR": true, "ADMIN_TLS_CERT_FILE": true, "ADMIN_TLS_KEY_FILE": true,
+	"STATIC_ADDR": true, "STATIC_DIR": true, "STATIC_EMBEDDED": true,
+	"STATIC_TLS_CERT_FILE": true, "STATIC_TLS_KEY_FILE": true,
+	"TLS_CERT_FILE": true, "TLS_KEY_FILE": true, "REQUIRE_TLS": true, "HSTS_MAX_AGE_SEC": true,
+	"PID_FILE": true, "LOG_FILE": true, "LOG_LEVEL": true,
+	"GOGC": true, "MEMORY_LIMIT_MB": true, "HEAP_BALLAST_MB": true,
+	"HANDOVER_SOCKET": true, "HANDOVER_LISTENER": true, "RESTART_SCHEDULE": true,
+	"DIRECTORY_URL": true, "PUBLIC_ADDRESS": true,
+	"MODERATION_LOG": true, "FRIENDS_LOG": true, "INVENTORY_LOG": true,
+	"PLAYER_RECORDS_LOG": true, "MAINTENANCE_SNAPSHOT_PATH": true,
+	"TENANTS_FILE": true, "TENANT_DATA_DIR": true, "USAGE_REPORT_SEC": true,
+	"MAP_FILE": true, "WORLD_SEED": true, "WORLD_WIDTH": true, "WORLD_HEIGHT": true,
+}
+
+// Load reads and checks the tenants file.
+func Load(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("tenant: %s: %w", path, err)
+	}
+	names := make(map[string]bool, len(tenants))
+	keys := make(map[string]bool, len(tenants))
+	for _, t := range tenants {
+		if err := t.check(); err != nil {
+			return nil, fmt.Errorf("tenant: %s: %w", path, err)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant: %s: duplicate name %q", path, t.Name)
+		}
+		if keys[t.APIKey] || keys[t.AdminKey] {
+			return nil, fmt.Errorf("tenant: %s: %q reuses another tenant's key", path, t.Name)
+		}
+		names[t.Name], keys[t.APIKey] = true, true
+		if t.AdminKey != "" {
+			keys[t.AdminKey] = true
+		}
+	}
+	return tenants, nil
+}
+
+func (t Tenant) check() error {
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("name %q must be 1-32 of a-z, 0-9, _ and -", t.Name)
+	}
+	if t.Name == Host {
+		return fmt.Errorf("name %q is reserved for the host's own world", t.Name)
+	}
+	if len(t.APIKey) < MinKeyLength {
+		return fmt.Errorf("%q: api_key must be at least %d characters", t.Name, MinKeyLength)
+	}
+	if t.AdminKey != "" && len(t.AdminKey) < MinKeyLength {
+		return fmt.Errorf("%q: admin_key must be at least %d characters", t.Name, MinKeyLength)
+	}
+	if t.AdminKey == t.APIKey {
+		return fmt.Errorf("%q: admin_key must differ from the public api_key", t.Name)
+	}
+	for key := range t.Config {
+		if hostOnly[key] {
+			return fmt.Errorf("%q: %s is a setting of the host and cannot be overridden", t.Name, key)
+		}
+	}
+	if t.Quotas.MaxConnections < 0 || t.Quotas.MaxBots < 0 {
+		return fmt.Errorf("%q: quotas cannot be negative", t.Name)
+	}
+	if q := t.Quotas.MaxTickShare; q < 0 || q > 1 {
+		return fmt.Errorf("%q: max_tick_share must be 0-1, got %v", t.Name, q)
+	}
+	return nil
+}
+
+// ServerConfig builds the tenant's config: the host's profile and environment with
+// the tenant's overrides on top, logs under the tenant's data directory, the admin key
+// as admin token and the quotas applied. The result is validated.
+func (t Tenant) ServerConfig(host *config.Config) (*config.Config, error) {
+	cfg, err := config.LoadTenant(host.Profile, t.Config)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %q: %w", t.Name, err)
+	}
+	// Host-only keys read the same environment, so listeners, TLS and runtime
+	// settings already match the host's; only what belongs to the process itself
+	// is switched off.
+	s := &cfg.Server
+	s.TenantsFile, s.HandoverSocket, s.RestartSchedule, s.DirectoryURL, s.PIDFile = "", "", "", "", ""
+	// Without an admin key the token is a random one nobody holds: the tenant's admin
+	// routes exist for the host's ?tenant= requests only.
+	s.AdminToken = t.AdminKey
+	if s.AdminToken == "" {
+		s.AdminToken = rand.Text()
+	}
+	s.LeaderboardRedisPrefix += t.Name + ":"
+
+	dir := filepath.Join(host.Server.TenantDataDir, t.Name)
+	hs := &host.Server
+	for _, log := range []struct {
+		path *string
+		host string
+	}{
+		{&s.ModerationLog, hs.ModerationLog},
+		{&s.FriendsLog, hs.FriendsLog},
+		{&s.InventoryLog, hs.InventoryLog},
+		{&s.PlayerRecordsLog, hs.PlayerRecordsLog},
+		{&s.MaintenanceSnapshotPath, hs.MaintenanceSnapshotPath},
+	} {
+		*log.path = ""
+		if log.host != "" {
+			*log.path = filepath.Join(dir, filepath.Base(log.host))
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("tenant %q: %w", t.Name, err)
+	}
+
+	if q := t.Quotas.MaxConnections; q > 0 {
+		cfg.Net.MaxConnections = min(cfg.Net.MaxConnections, q)
+	}
+	if q := t.Quotas.MaxBots; q > 0 {
+		cfg.Game.BotMax = min(cfg.Game.BotMax, q)
+	}
+	cfg.Game.BotCount = min(cfg.Game.BotCount, cfg.Game.BotMax)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("tenant %q: %w", t.Name, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pixi_game_server/internal/config"
+)
+
+const key = "acme-key-0123456789"
+
+func writeTenants(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tenants, err := Load(writeTenants(t, `[{"name": "acme", "api_key": "`+key+`", "config": {"TICK_RATE": "20"}, "quotas": {"max_connections": 50}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 1 || tenants[0].Name != "acme" || tenants[0].Config["TICK_RATE"] != "20" || tenants[0].Quotas.MaxConnections != 50 {
+		t.Fatalf("loaded %+v", tenants)
+	}
+
+	for name, body := range map[string]string{
+		"bad name":     `[{"name": "Acme!", "api_key": "` + key + `"}]`,
+		"short key":    `[{"name": "acme", "api_key": "short"}]`,
+		"host setting": `[{"name": "acme", "api_key": "` + key + `", "config": {"PORT": "9000"}}]`,
+		"same name":    `[{"name": "acme", "api_key": "` + key + `"}, {"name": "acme", "api_key": "` + key + `x"}]`,
+		"same key":     `[{"name": "acme", "api_key": "` + key + `"}, {"name": "globex", "api_key": "` + key + `"}]`,
+		"admin is api": `[{"name": "acme", "api_key": "` + key + `", "admin_key": "` + key + `"}]`,
+		"admin reused": `[{"name": "acme", "api_key": "` + key + `"}, {"name": "globex", "api_key": "globex-key-0123456789", "admin_key": "` + key + `"}]`,
+		"short admin":  `[{"name": "acme", "api_key": "` + key + `", "admin_key": "short"}]`,
+		"negative":     `[{"name": "acme", "api_key": "` + key + `", "quotas": {"max_bots": -1}}]`,
+		"reserved":     `[{"name": "host", "api_key": "` + key + `"}]`,
+		"tick share":   `[{"name": "acme", "api_key": "` + key + `", "quotas": {"max_tick_share": 1.5}}]`,
+		"not a list":   `{"name": "acme"}`,
+	} {
+		if _, err := Load(writeTenants(t, body)); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	t.Setenv("TICK_RATE", "60")
+	t.Setenv("ADMIN_TOKEN", "host-token")
+	host := config.Load()
+	host.Server.TenantDataDir = t.TempDir()
+	host.Server.FriendsLog = ""
+
+	ten := Tenant{Name: "acme", APIKey: key, AdminKey: "acme-admin-0123456789", Config: map[string]string{"TICK_RATE": "20", "BOT_COUNT": "50"},
+		Quotas: Quotas{MaxConnections: 10, MaxBots: 5}}
+	cfg, err := ten.ServerConfig(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Game.TickRate != 20 {
+		t.Errorf("TICK_RATE = %d, the tenant's override must win over the environment", cfg.Game.TickRate)
+	}
+	if cfg.Server.AdminToken != ten.AdminKey {
+		t.Errorf("admin token %q, want the admin key", cfg.Server.AdminToken)
+	}
+	ten.AdminKey = ""
+	if cfg, err := ten.ServerConfig(host); err != nil || cfg.Server.AdminToken == "" || cfg.Server.AdminToken == key {
+		t.Errorf("admin token without an admin key: %q, %v; want a random one", cfg.Server.AdminToken, err)
+	}
+	if cfg.Net.MaxConnections != 10 || cfg.Game.BotMax != 5 || cfg.Game.BotCount != 5 {
+		t.Errorf("quotas not applied: connections=%d bot_max=%d bots=%d", cfg.Net.MaxConnections, cfg.Game.BotMax, cfg.Game.BotCount)
+	}
+	dir := filepath.Join(host.Server.TenantDataDir, "acme")
+	if cfg.Server.InventoryLog != filepath.Join(dir, "inventory.jsonl") {
+		t.Errorf("inventory log %q, want it under %s", cfg.Server.InventoryLog, dir)
+	}
+	if cfg.Server.FriendsLog != "" {
+		t.Errorf("friends log %q, want in memory like the host's", cfg.Server.FriendsLog)
+	}
+	if !strings.HasSuffix(cfg.Server.LeaderboardRedisPrefix, "acme:") {
+		t.Errorf("leaderboard prefix %q not namespaced", cfg.Server.LeaderboardRedisPrefix)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("data directory: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package testutil
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// FakeConn is an in-memory net.Conn. Reads drain the bytes passed to Feed and then
+// return io.EOF; writes are recorded and can be decoded with Frames. Deadlines are
+// accepted and ignored.
+type FakeConn struct {
+	mu     sync.Mutex
+	in     bytes.Buffer
+	out    bytes.Buffer
+	closed bool
+}
+
+// NewFakeConn returns an empty FakeConn.
+func NewFakeConn() *FakeConn {
+	return &FakeConn{}
+}
+
+// Feed queues data for Read.
+func (c *FakeConn) Feed(data []byte) {
+	c.mu.Lock()
+	c.in.Write(data)
+	c.mu.Unlock()
+}
+
+// Written returns a copy of everything written so far.
+func (c *FakeConn) Written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.out.Bytes())
+}
+
+// Closed reports whether Close was called.
+func (c *FakeConn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *FakeConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	return c.in.Read(p)
+}
+
+func (c *FakeConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	return c.out.Write(p)
+}
+
+func (c *FakeConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *FakeConn) LocalAddr() net.Addr                { return fakeAddr("local") }
+func (c *FakeConn) RemoteAddr() net.Addr               { return fakeAddr("remote") }
+func (c *FakeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *FakeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *FakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "fake" }
+func (a fakeAddr) String() string  { return string(a) }
+
+// Frame is one WS frame written to a FakeConn. Payload is inflated when the frame
+// was sent with permessage-deflate (Compressed).
+type Frame struct {
+	OpCode     ws.OpCode
+	Compressed bool
+	Payload    []byte
+}
+
+// Frames decodes everything written so far as server → client WS frames.
+func (c *FakeConn) Frames() ([]Frame, error) {
+	r := bytes.NewReader(c.Written())
+	var frames []Frame
+	for r.Len() > 0 {
+		f, err := ReadFrame(r)
+		if err != nil {
+			return frames, fmt.Errorf("frame %d: %w", len(frames), err)
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// inflate decodes a permessage-deflate payload (RFC 7692: the sync-flush tail is omitted).
+func inflate(p []byte) ([]byte, error) {
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader([]byte{0, 0, 0xff, 0xff})))
+	out, err := io.ReadAll(r)
+	if err == io.ErrUnexpectedEOF {
+		err = nil // the message ends at a sync flush, not a final block
+	}
+	return out, err
+}
@@ -0,0 +1,45 @@
+// Package testutil holds test helpers shared by the server packages: golden files
+// for wire-format tests, an in-memory net.Conn that decodes the WS frames written to
+// it, a deterministic GameWorld driver and synthetic player populations for benchmarks.
+package testutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites golden files instead of comparing: go test ./internal/... -update
+var update = flag.Bool("update", false, "rewrite golden files in testdata/")
+
+// Golden compares got with testdata/<name>.golden, stored as a hex dump so byte
+// layout changes show up readably in diffs.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	GoldenText(t, name, hex.Dump(got))
+}
+
+// GoldenText compares got with testdata/<name>.golden as text.
+func GoldenText(t testing.TB, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(want, []byte(got)) {
+		t.Errorf("%s mismatch (run with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package testutil
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+// PipeBufferSize — bytes a PipeConn buffers in each direction before Write blocks,
+// about what a socket buffer holds.
+const PipeBufferSize = 256 << 10
+
+// PipeConn is one end of an in-memory connection from Pipe. Unlike net.Pipe, writes
+// are buffered: Write returns as soon as p fits in the PipeBufferSize bytes the other
+// end has not read yet, so a reader that falls behind blocks the writer the way a
+// full socket buffer would. Deadlines are honoured and expire with
+// os.ErrDeadlineExceeded, a Write cut short by one included. After Close the other
+// end reads what is left and then io.EOF; what it writes is discarded.
+type PipeConn struct {
+	in, out    *pipeBuffer
+	closed     chan struct{} // this end
+	peerClosed chan struct{}
+	closeOnce  sync.Once
+	name, peer fakeAddr
+
+	readDeadline, writeDeadline pipeDeadline
+}
+
+// pipeBuffer — the bytes in flight in one direction. The channels wake a blocked
+// reader or writer; each holds at most one pending signal.
+type pipeBuffer struct {
+	mu       sync.Mutex
+	data     []byte // unread from data[off:]
+	off      int
+	readable chan struct{}
+	writable chan struct{}
+}
+
+func newPipeBuffer() *pipeBuffer {
+	return &pipeBuffer{readable: make(chan struct{}, 1), writable: make(chan struct{}, 1)}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Pipe returns the two ends of an in-memory connection; what is written to one is
+// read from the other.
+func Pipe() (client, server *PipeConn) {
+	c2s, s2c := newPipeBuffer(), newPipeBuffer()
+	cDone, sDone := make(chan struct{}), make(chan struct{})
+	client = &PipeConn{in: s2c, out: c2s, closed: cDone, peerClosed: sDone, name: "client", peer: "server",
+		readDeadline: newPipeDeadline(), writeDeadline: newPipeDeadline()}
+	server = &PipeConn{in: c2s, out: s2c, closed: sDone, peerClosed: cDone, name: "server", peer: "client",
+		readDeadline: newPipeDeadline(), writeDeadline: newPipeDeadline()}
+	return client, server
+}
+
+func (c *PipeConn) Read(p []byte) (int, error) {
+	for {
+		select {
+		case <-c.closed:
+			return 0, net.ErrClosed
+		case <-c.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		default:
+		}
+		b := c.in
+		b.mu.Lock()
+		if b.off < len(b.data) {
+			n := copy(p, b.data[b.off:])
+			if b.off += n; b.off == len(b.data) {
+				b.data, b.off = b.data[:0], 0
+			}
+			b.mu.Unlock()
+			signal(b.writable)
+			return n, nil
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.readable:
+		case <-c.closed:
+			return 0, net.ErrClosed
+		case <-c.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		case <-c.peerClosed:
+			b.mu.Lock()
+			empty := b.off == len(b.data)
+			b.mu.Unlock()
+			if empty {
+				return 0, io.EOF
+			}
+		}
+	}
+}
+
+func (c *PipeConn) Write(p []byte) (int, error) {
+	var n int
+	for {
+		select {
+		case <-c.closed:
+			return n, net.ErrClosed
+		case <-c.peerClosed:
+			return len(p), nil // lost, as on a socket the peer has shut down
+		case <-c.writeDeadline.wait():
+			return n, os.ErrDeadlineExceeded
+		default:
+		}
+		b := c.out
+		b.mu.Lock()
+		if b.off > 0 && b.off >= len(b.data)-b.off {
+			b.data, b.off = b.data[:copy(b.data, b.data[b.off:])], 0
+		}
+		if room := PipeBufferSize - (len(b.data) - b.off); room > 0 {
+			take := min(room, len(p)-n)
+			b.data = append(b.data, p[n:n+take]...)
+			n += take
+			signal(b.readable)
+		}
+		b.mu.Unlock()
+		if n == len(p) {
+			return n, nil
+		}
+		select {
+		case <-b.writable:
+		case <-c.closed:
+		case <-c.peerClosed:
+		case <-c.writeDeadline.wait():
+		}
+	}
+}
+
+// Close closes this end; it is safe to call more than once.
+func (c *PipeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// Closed reports whether Close was called on this end.
+func (c *PipeConn) Closed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *PipeConn) LocalAddr() net.Addr  { return c.name }
+func (c *PipeConn) RemoteAddr() net.Addr { return c.peer }
+
+func (c *PipeConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *PipeConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *PipeConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// pipeDeadline — a deadline whose channel is closed once it passes (as in net.Pipe).
+type pipeDeadline struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+func newPipeDeadline() pipeDeadline {
+	return pipeDeadline{cancel: make(chan struct{})}
+}
+
+func (d *pipeDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // the timer fired: wait for it to close cancel
+	}
+	d.timer = nil
+	expired := isClosed(d.cancel)
+	if t.IsZero() || time.Until(t) > 0 {
+		if expired {
+			d.cancel = make(chan struct{})
+		}
+		if !t.IsZero() {
+			cancel := d.cancel
+			d.timer = time.AfterFunc(time.Until(t), func() { close(cancel) })
+		}
+		return
+	}
+	if !expired {
+		close(d.cancel)
+	}
+}
+
+func (d *pipeDeadline) wait() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancel
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteClientFrame writes payload as one masked binary frame, as a browser would.
+func WriteClientFrame(w io.Writer, payload []byte) error {
+	return ws.WriteFrame(w, ws.MaskFrameInPlace(ws.NewBinaryFrame(append([]byte(nil), payload...))))
+}
+
+// ReadFrame reads one server → client frame from r (see FakeConn.Frames).
+func ReadFrame(r io.Reader) (Frame, error) {
+	hdr, err := ws.ReadHeader(r)
+	if err != nil {
+		return Frame{}, err
+	}
+	payload := make([]byte, hdr.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return Frame{}, fmt.Errorf("frame payload: %w", err)
+	}
+	f := Frame{OpCode: hdr.OpCode, Compressed: hdr.Rsv1(), Payload: payload}
+	if f.Compressed {
+		if f.Payload, err = inflate(payload); err != nil {
+			return Frame{}, err
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,26 @@
+package testutil
+
+import (
+	"math/rand"
+
+	"pixi_game_server/internal/types"
+)
+
+// Players returns n player states spread over a width×height world, deterministic
+// for a given n. IDs start at 1001 like real clients.
+func Players(n int, width, height uint32) []types.PlayerState {
+	rng := rand.New(rand.NewSource(int64(n)))
+	players := make([]types.PlayerState, n)
+	for i := range players {
+		players[i] = types.PlayerState{
+			ID:          uint32(1001 + i),
+			X:           uint32(rng.Intn(int(width))),
+			Y:           uint32(rng.Intn(int(height))),
+			VX:          int8(rng.Intn(3) - 1),
+			VY:          int8(rng.Intn(3) - 1),
+			FacingRight: rng.Intn(2) == 0,
+			State:       uint8(rng.Intn(2)),
+		}
+	}
+	return players
+}
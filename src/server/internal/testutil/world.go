@@ -0,0 +1,128 @@
+package testutil
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+	"time"
+
+	"pixi_game_server/internal/config"
+	"pixi_game_server/internal/game"
+	"pixi_game_server/internal/types"
+)
+
+// Config returns the default config with everything time- or randomness-driven that
+// would make a simulation test flaky switched off.
+func Config() *config.Config {
+	cfg := config.Load()
+	cfg.Game.TickRate = 1 // the real game loop must not tick before NewWorld pauses it
+	cfg.Game.SyncInterval = time.Hour
+	cfg.Game.BatchInterval = 0
+	cfg.Game.InputTimeoutTicks = 0
+	cfg.Game.SpawnProtection = 0
+	cfg.Game.WorldEvents = nil
+	cfg.Game.BotCount = 0
+	cfg.World.ZoneCols, cfg.World.ZoneRows = 0, 0
+	cfg.Net.MinimapInterval = 0     // tests call sendMinimap directly
+	cfg.Net.LeaderboardInterval = 0 // tests call sendLeaderboard directly
+	cfg.Net.FriendPresenceInterval = 0
+	cfg.Server.ModerationLog = ""
+	cfg.Server.FriendsLog = ""
+	cfg.Server.InventoryLog = ""
+	cfg.Server.PlayerRecordsLog = ""
+	return cfg
+}
+
+// Tick is what one world tick broadcast (copied; the world reuses its buffers).
+type Tick struct {
+	Broadcast bool // false: nothing changed, no broadcast
+	FullSync  bool
+	All       []types.PlayerState
+	Changed   []types.PlayerState // nil on a full sync
+}
+
+// World drives a game.GameWorld deterministically: the world is paused right after
+// creation, starts with a fixed population and advances only on Step. The first Step
+// is a full sync, later ones are deltas.
+type World struct {
+	*game.GameWorld
+	cfg  *config.Config
+	last Tick
+}
+
+// NewWorld creates a paused world with players (nil cfg = Config()); it is stopped
+// when the test ends.
+func NewWorld(t testing.TB, cfg *config.Config, players ...game.ExportedPlayer) *World {
+	t.Helper()
+	if cfg == nil {
+		cfg = Config()
+	}
+	w := &World{GameWorld: game.NewGameWorld(cfg, nil), cfg: cfg}
+	w.SetPaused(true)
+	t.Cleanup(w.Stop)
+
+	w.SetTickBroadcaster(func(all, changed []types.PlayerState, fullSync bool) {
+		w.last = Tick{
+			Broadcast: true,
+			FullSync:  fullSync,
+			All:       sortedCopy(all),
+			Changed:   sortedCopy(changed),
+		}
+	})
+
+	st := &game.ExportedState{Version: game.ExportedStateVersion, Players: players}
+	for _, p := range players {
+		st.NextPlayerID = max(st.NextPlayerID, p.ID)
+	}
+	if _, err := w.ImportState(st); err != nil {
+		t.Fatalf("seed world: %v", err)
+	}
+	return w
+}
+
+// Config returns the config the world was created with.
+func (w *World) Config() *config.Config {
+	return w.cfg
+}
+
+// Step runs one tick and returns its broadcast.
+func (w *World) Step() Tick {
+	w.last = Tick{}
+	w.GameWorld.Step()
+	return w.last
+}
+
+// CatchUp runs n ticks the way a game loop that fell behind does and returns the
+// broadcast of the last one, the only one that publishes.
+func (w *World) CatchUp(n int) Tick {
+	w.last = Tick{}
+	w.GameWorld.CatchUp(n)
+	return w.last
+}
+
+// Move sets a player's movement vector as a MOVE would.
+func (w *World) Move(id uint32, dx, dy int8) {
+	w.ProcessEvent(types.GameEvent{PlayerID: id, Type: types.EventMove, VectorX: dx, VectorY: dy})
+}
+
+// Player returns the player's state as of the last Step.
+func (w *World) Player(id uint32) (types.PlayerState, bool) {
+	snap := w.AcquireSnapshot()
+	defer snap.Release()
+	for _, p := range snap.Players {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return types.PlayerState{}, false
+}
+
+// sortedCopy copies players ordered by ID (the world iterates a map).
+func sortedCopy(players []types.PlayerState) []types.PlayerState {
+	if players == nil {
+		return nil
+	}
+	out := slices.Clone(players)
+	slices.SortFunc(out, func(a, b types.PlayerState) int { return cmp.Compare(a.ID, b.ID) })
+	return out
+}
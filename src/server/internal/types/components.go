@@ -0,0 +1,448 @@
+package types
+
+import (
+	"sync/atomic"
+
+	"pixi_game_server/internal/ecs"
+)
+
+// Компоненты сущностей мира. Живут в колонках Entities (по строке на сущность), так
+// что tick обходит плотные массивы; Player — лишь ручка с указателями на свои строки.
+// Все поля atomic: их пишут tick worker'ы, epoll-обработчики и боты одновременно.
+
+// Position — позиция сущности в фиксированной точке (Fixed): скорость меньше единицы
+// за тик не теряется, а X и Y — каждая одно atomic слово вместе с долей.
+type Position struct {
+	X          uint32 // Atomic Fixed
+	Y          uint32 // Atomic Fixed
+	LastUpdate int64  // Atomic UnixNano последнего перемещения
+}
+
+// Fixed — координата в фиксированной точке 24.8: целые единицы мира в старших битах,
+// доля единицы (1/SubUnits) в младших FracBits.
+type Fixed uint32
+
+// FracBits — биты доли единицы в Fixed.
+const FracBits = 8
+
+// MaxCoord — наибольшая координата мира, которую вмещает Fixed.
+const MaxCoord = 1<<(32-FracBits) - 1
+
+// SubUnits — число долей в единице мира для позиции и скорости (game/physics.go).
+const SubUnits = 1 << FracBits
+
+// FixedOf возвращает Fixed целой координаты v.
+func FixedOf(v uint32) Fixed {
+	return Fixed(v) << FracBits
+}
+
+// Units возвращает целые единицы мира (доля отбрасывается).
+func (f Fixed) Units() uint32 {
+	return uint32(f >> FracBits)
+}
+
+// Frac возвращает долю единицы сверх Units, в 1/SubUnits.
+func (f Fixed) Frac() uint8 {
+	return uint8(f)
+}
+
+// Velocity — вектор движения и ввод, который его задал.
+type Velocity struct {
+	VX           uint32 // Atomic access (stores int8: -1, 0, 1)
+	VY           uint32 // Atomic access (stores int8: -1, 0, 1)
+	ClientTick   uint32 // Atomic client tick for reconciliation
+	LastActivity int64  // Atomic UnixNano последнего ввода (input timeout)
+	SpeedPercent uint32 // Atomic множитель скорости, заданный администратором; 0 = 100%
+
+	// Аналоговый ввод: величина оси VX/VY, 1..127; 0 = полная (цифровой ввод, боты).
+	ThrottleX uint32 // Atomic (stores uint8 value)
+	ThrottleY uint32 // Atomic (stores uint8 value)
+
+	// Скорость после интегрирования физики, в 1/SubUnits единицы за тик.
+	SubVX uint32 // Atomic (stores int32 value)
+	SubVY uint32 // Atomic (stores int32 value)
+}
+
+// Facing — направление взгляда.
+type Facing struct {
+	Right uint32 // Atomic bool (0/1)
+}
+
+// Combat — состояние игрока (State*) и его таймеры.
+type Combat struct {
+	State           uint32 // Atomic player state
+	AttackStartTime int64  // Atomic UnixNano start of the last attack (0 = none); cooldown runs from it
+	StunnedUntil    int64  // Atomic UnixNano end of StateStunned (0 = not stunned)
+
+	// Spawn protection: UnixNano, до которого игрок неуязвим после спавна (0 = не защищён).
+	// Сбрасывается tick worker'ом по истечении, см. StateFlagSpawnProtected.
+	SpawnProtectedUntil int64
+
+	// Флаги администратора (game/adminops.go): заморожен — не двигается и не атакует;
+	// неуязвим — Stun и Kill не действуют, клиентам уходит как защита после спавна.
+	Frozen       uint32 // Atomic bool (0/1)
+	Invulnerable uint32 // Atomic bool (0/1)
+
+	// Игрок в безопасной зоне (game/safezones.go): не атакует и не получает попаданий.
+	// Пересчитывается tick worker'ом каждый тик, см. StateFlagSafeZone.
+	SafeZone uint32 // Atomic bool (0/1)
+
+	// Здоровье (game/combat.go): HP падает от попаданий атак; на 0 игрок умирает и
+	// в RespawnAt (UnixNano) возрождается с полным HP. 0 = возрождения не ждёт.
+	HP        uint32 // Atomic (stores uint16 value)
+	RespawnAt int64
+}
+
+// Motion — перемещение сущности на текущем тике: фаза movement пишет цель, фаза
+// collision её разрешает. Трогают только tick worker'ы, а между фазами барьер, поэтому
+// поля не atomic.
+type Motion struct {
+	ToX, ToY Fixed
+	Moving   bool
+}
+
+// AI — состояние поведения серверной сущности (бота). У игроков нулевое.
+type AI struct {
+	NextDecision int64 // Atomic UnixNano следующего решения
+}
+
+// GetX возвращает X в целых единицах мира.
+func (c *Position) GetX() uint32 {
+	return Fixed(atomic.LoadUint32(&c.X)).Units()
+}
+
+// SetX ставит X в целую единицу, обнуляя долю.
+func (c *Position) SetX(x uint32) {
+	atomic.StoreUint32(&c.X, uint32(FixedOf(x)))
+}
+
+func (c *Position) GetY() uint32 {
+	return Fixed(atomic.LoadUint32(&c.Y)).Units()
+}
+
+func (c *Position) SetY(y uint32) {
+	atomic.StoreUint32(&c.Y, uint32(FixedOf(y)))
+}
+
+// GetFixed возвращает позицию вместе с долями единицы.
+func (c *Position) GetFixed() (x, y Fixed) {
+	return Fixed(atomic.LoadUint32(&c.X)), Fixed(atomic.LoadUint32(&c.Y))
+}
+
+func (c *Position) SetFixed(x, y Fixed) {
+	atomic.StoreUint32(&c.X, uint32(x))
+	atomic.StoreUint32(&c.Y, uint32(y))
+}
+
+func (c *Position) GetLastUpdate() int64 {
+	return atomic.LoadInt64(&c.LastUpdate)
+}
+
+func (c *Position) SetLastUpdate(timestamp int64) {
+	atomic.StoreInt64(&c.LastUpdate, timestamp)
+}
+
+func (c *Velocity) GetVX() int8 {
+	return int8(atomic.LoadUint32(&c.VX))
+}
+
+func (c *Velocity) SetVX(vx int8) {
+	atomic.StoreUint32(&c.VX, uint32(vx))
+}
+
+func (c *Velocity) GetVY() int8 {
+	return int8(atomic.LoadUint32(&c.VY))
+}
+
+func (c *Velocity) SetVY(vy int8) {
+	atomic.StoreUint32(&c.VY, uint32(vy))
+}
+
+// Moving сообщает, задан ли ненулевой вектор движения.
+func (c *Velocity) Moving() bool {
+	return atomic.LoadUint32(&c.VX) != 0 || atomic.LoadUint32(&c.VY) != 0
+}
+
+// GetThrottle возвращает величину осей ввода, 1..127; 0 = полная.
+func (c *Velocity) GetThrottle() (x, y uint8) {
+	return uint8(atomic.LoadUint32(&c.ThrottleX)), uint8(atomic.LoadUint32(&c.ThrottleY))
+}
+
+func (c *Velocity) SetThrottle(x, y uint8) {
+	atomic.StoreUint32(&c.ThrottleX, uint32(x))
+	atomic.StoreUint32(&c.ThrottleY, uint32(y))
+}
+
+// WireVector — вектор движения для клиентов: направление ввода, а без ввода —
+// направление скорости, пока игрок скользит по инерции.
+func (c *Velocity) WireVector() (vx, vy int8) {
+	vx, vy = c.GetVX(), c.GetVY()
+	if vx != 0 || vy != 0 {
+		return vx, vy
+	}
+	svx, svy := c.GetSubV()
+	return sign32(svx), sign32(svy)
+}
+
+func sign32(v int32) int8 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// GetSubV возвращает скорость в 1/SubUnits единицы за тик.
+func (c *Velocity) GetSubV() (x, y int32) {
+	return int32(atomic.LoadUint32(&c.SubVX)), int32(atomic.LoadUint32(&c.SubVY))
+}
+
+func (c *Velocity) SetSubV(x, y int32) {
+	atomic.StoreUint32(&c.SubVX, uint32(x))
+	atomic.StoreUint32(&c.SubVY, uint32(y))
+}
+
+func (c *Velocity) GetClientTick() uint32 {
+	return atomic.LoadUint32(&c.ClientTick)
+}
+
+func (c *Velocity) SetClientTick(tick uint32) {
+	atomic.StoreUint32(&c.ClientTick, tick)
+}
+
+func (c *Velocity) GetLastActivity() int64 {
+	return atomic.LoadInt64(&c.LastActivity)
+}
+
+func (c *Velocity) SetLastActivity(timestamp int64) {
+	atomic.StoreInt64(&c.LastActivity, timestamp)
+}
+
+// GetSpeedPercent возвращает множитель скорости в процентах (100 = норма).
+func (c *Velocity) GetSpeedPercent() int32 {
+	if pct := atomic.LoadUint32(&c.SpeedPercent); pct != 0 {
+		return int32(pct)
+	}
+	return 100
+}
+
+func (c *Velocity) SetSpeedPercent(pct int32) {
+	atomic.StoreUint32(&c.SpeedPercent, uint32(pct))
+}
+
+func (c *Facing) GetRight() bool {
+	return atomic.LoadUint32(&c.Right) == 1
+}
+
+func (c *Facing) SetRight(facing bool) {
+	var val uint32
+	if facing {
+		val = 1
+	}
+	atomic.StoreUint32(&c.Right, val)
+}
+
+func (c *Combat) GetState() uint8 {
+	return uint8(atomic.LoadUint32(&c.State))
+}
+
+func (c *Combat) SetState(state uint8) {
+	atomic.StoreUint32(&c.State, uint32(state))
+}
+
+// CompareAndSwapState меняет состояние, только если оно всё ещё равно old.
+func (c *Combat) CompareAndSwapState(old, state uint8) bool {
+	return atomic.CompareAndSwapUint32(&c.State, uint32(old), uint32(state))
+}
+
+func (c *Combat) GetAttackStartTime() int64 {
+	return atomic.LoadInt64(&c.AttackStartTime)
+}
+
+func (c *Combat) SetAttackStartTime(t int64) {
+	atomic.StoreInt64(&c.AttackStartTime, t)
+}
+
+func (c *Combat) GetStunnedUntil() int64 {
+	return atomic.LoadInt64(&c.StunnedUntil)
+}
+
+func (c *Combat) SetStunnedUntil(t int64) {
+	atomic.StoreInt64(&c.StunnedUntil, t)
+}
+
+func (c *Combat) GetSpawnProtectedUntil() int64 {
+	return atomic.LoadInt64(&c.SpawnProtectedUntil)
+}
+
+func (c *Combat) SetSpawnProtectedUntil(t int64) {
+	atomic.StoreInt64(&c.SpawnProtectedUntil, t)
+}
+
+func (c *Combat) GetFrozen() bool {
+	return atomic.LoadUint32(&c.Frozen) == 1
+}
+
+func (c *Combat) SetFrozen(frozen bool) {
+	atomic.StoreUint32(&c.Frozen, boolToUint32(frozen))
+}
+
+func (c *Combat) GetInvulnerable() bool {
+	return atomic.LoadUint32(&c.Invulnerable) == 1
+}
+
+func (c *Combat) SetInvulnerable(invulnerable bool) {
+	atomic.StoreUint32(&c.Invulnerable, boolToUint32(invulnerable))
+}
+
+func (c *Combat) GetSafeZone() bool {
+	return atomic.LoadUint32(&c.SafeZone) == 1
+}
+
+func (c *Combat) SetSafeZone(safe bool) {
+	atomic.StoreUint32(&c.SafeZone, boolToUint32(safe))
+}
+
+func (c *Combat) GetHP() uint16 {
+	return uint16(atomic.LoadUint32(&c.HP))
+}
+
+func (c *Combat) SetHP(hp uint16) {
+	atomic.StoreUint32(&c.HP, uint32(hp))
+}
+
+func (c *Combat) GetRespawnAt() int64 {
+	return atomic.LoadInt64(&c.RespawnAt)
+}
+
+func (c *Combat) SetRespawnAt(t int64) {
+	atomic.StoreInt64(&c.RespawnAt, t)
+}
+
+func boolToUint32(v bool) uint32 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// WireState — State с флагами защиты (после спавна или неуязвимость) и безопасной
+// зоны, как он уходит клиентам.
+func (c *Combat) WireState() uint8 {
+	state := c.GetState()
+	if c.GetSpawnProtectedUntil() != 0 || c.GetInvulnerable() {
+		state |= StateFlagSpawnProtected
+	}
+	if c.GetSafeZone() {
+		state |= StateFlagSafeZone
+	}
+	return state
+}
+
+func (c *AI) GetNextDecision() int64 {
+	return atomic.LoadInt64(&c.NextDecision)
+}
+
+func (c *AI) SetNextDecision(t int64) {
+	atomic.StoreInt64(&c.NextDecision, t)
+}
+
+// Entities — компоненты всех сущностей мира, по колонке на тип (struct of arrays).
+//
+// Spawn и Despawn сериализует владелец (GameWorld под playersMu), как и Recycle —
+// которую он же вызывает, пока никто не обходит строки. Строки и колонки можно читать
+// параллельно.
+type Entities struct {
+	table    *ecs.Table
+	Position *ecs.Column[Position]
+	Velocity *ecs.Column[Velocity]
+	Facing   *ecs.Column[Facing]
+	Combat   *ecs.Column[Combat]
+	AI       *ecs.Column[AI]
+	Motion   *ecs.Column[Motion]
+	handles  *ecs.Column[atomic.Pointer[Player]]
+}
+
+// NewEntities создаёт пустое хранилище.
+func NewEntities() *Entities {
+	t := ecs.NewTable()
+	return &Entities{
+		table:    t,
+		Position: ecs.NewColumn[Position](t),
+		Velocity: ecs.NewColumn[Velocity](t),
+		Facing:   ecs.NewColumn[Facing](t),
+		Combat:   ecs.NewColumn[Combat](t),
+		AI:       ecs.NewColumn[AI](t),
+		Motion:   ecs.NewColumn[Motion](t),
+		handles:  ecs.NewColumn[atomic.Pointer[Player]](t),
+	}
+}
+
+// Spawn создаёт сущность id и вызывает init на её ручке до того, как строка станет
+// видна обходу. nil — id уже занят.
+func (e *Entities) Spawn(id uint32, init func(*Player)) *Player {
+	p := &Player{ID: id}
+	_, ok := e.table.Add(ecs.Entity(id), func(r ecs.Row) {
+		p.attach(e.Position.At(r), e.Velocity.At(r), e.Facing.At(r), e.Combat.At(r), e.AI.At(r))
+		if init != nil {
+			init(p)
+		}
+		e.handles.At(r).Store(p)
+	})
+	if !ok {
+		return nil
+	}
+	return p
+}
+
+// Despawn удаляет сущность p. Ручка переезжает на собственную копию компонентов и
+// остаётся рабочей для тех, кто ещё держит её (отключившееся соединение), не трогая
+// строку, которую после Recycle займёт другая сущность.
+func (e *Entities) Despawn(p *Player) bool {
+	if _, ok := e.table.Remove(ecs.Entity(p.ID)); !ok {
+		return false
+	}
+	p.detach()
+	return true
+}
+
+// Recycle — см. ecs.Table.Recycle.
+func (e *Entities) Recycle() {
+	e.table.Recycle()
+}
+
+// Len — число сущностей.
+func (e *Entities) Len() int {
+	return e.table.Len()
+}
+
+// Rows — граница обхода строк, см. ecs.Table.Rows.
+func (e *Entities) Rows() ecs.Row {
+	return e.table.Rows()
+}
+
+// Owner возвращает ID сущности строки r; 0 — строка свободна.
+func (e *Entities) Owner(r ecs.Row) uint32 {
+	return uint32(e.table.Owner(r))
+}
+
+// Player возвращает ручку сущности строки r (nil для свободной строки).
+func (e *Entities) Player(r ecs.Row) *Player {
+	return e.handles.At(r).Load()
+}
+
+// State собирает PlayerState сущности id из строки r.
+func (e *Entities) State(id uint32, r ecs.Row) PlayerState {
+	pos, vel := e.Position.At(r), e.Velocity.At(r)
+	st := PlayerState{
+		ID:          id,
+		FacingRight: e.Facing.At(r).GetRight(),
+		State:       e.Combat.At(r).WireState(),
+		ClientTick:  vel.GetClientTick(),
+	}
+	st.SetPosition(pos.GetFixed())
+	st.VX, st.VY = vel.WireVector()
+	return st
+}
@@ -5,36 +5,130 @@ import (
 	"time"
 )
 
-// Player представляет игрока в системе
+// Player представляет игрока в системе — ручка его сущности. Симулируемое состояние
+// живёт в компонентах (components.go): пока игрок в мире, это строки Entities, после
+// Despawn — собственные копии.
 type Player struct {
-	ID              uint32 // Atomic access
-	X               uint32 // Atomic access (stores uint16 value)
-	Y               uint32 // Atomic access (stores uint16 value)
-	VX              uint32 // Atomic access (stores int8: -1, 0, 1)
-	VY              uint32 // Atomic access (stores int8: -1, 0, 1)
-	FacingRight     uint32 // Atomic bool (0/1)
-	State           uint32 // Atomic player state
-	ClientTick      uint32 // Atomic client tick for reconciliation
-	AttackStartTime int64  // Atomic nanosecond timestamp of attack start (0 = not attacking)
-
-	// Timestamps для performance tracking
-	LastUpdate   int64 // Atomic timestamp
-	LastActivity int64 // Atomic timestamp
-	JoinTime     time.Time
+	ID       uint32
+	JoinTime time.Time
+
+	position atomic.Pointer[Position]
+	velocity atomic.Pointer[Velocity]
+	facing   atomic.Pointer[Facing]
+	combat   atomic.Pointer[Combat]
+	ai       atomic.Pointer[AI]
+
+	// Viewport (AOI): размеры, присланные клиентом, и вычисленные границы в мировых координатах.
+	ViewW uint32 // Atomic (stores uint16 value); 0 = viewport not reported
+	ViewH uint32 // Atomic (stores uint16 value)
+	// Границы viewport: угол (MinX, MinY) и (MaxX, MaxY) — по atomic слову на угол.
+	// Слова пишутся порознь, так что читатель может увидеть углы двух соседних
+	// обновлений: прямоугольник, сдвинутый на одно перемещение.
+	ViewportMin uint64 // Atomic, packed by packPoint
+	ViewportMax uint64 // Atomic, packed by packPoint
+
+	// Команда (WorldConfig.TeamBases, 1-based); 0 = без команды.
+	Team uint32 // Atomic (stores uint8 value)
 
 	// Metrics
 	MessageCount uint64 // Atomic counter
 }
 
+// NewPlayer создаёт игрока вне мира, с собственными компонентами (тесты, инструменты).
+// Игроки мира создаются через Entities.Spawn.
+func NewPlayer(id uint32) *Player {
+	p := &Player{ID: id}
+	p.attach(new(Position), new(Velocity), new(Facing), new(Combat), new(AI))
+	return p
+}
+
+func (p *Player) attach(pos *Position, vel *Velocity, facing *Facing, combat *Combat, ai *AI) {
+	p.position.Store(pos)
+	p.velocity.Store(vel)
+	p.facing.Store(facing)
+	p.combat.Store(combat)
+	p.ai.Store(ai)
+}
+
+// detach переносит компоненты в собственные копии (см. Entities.Despawn).
+func (p *Player) detach() {
+	pos, vel, facing, combat, ai := p.Position(), p.Velocity(), p.Facing(), p.Combat(), p.AI()
+	p.attach(
+		&Position{X: atomic.LoadUint32(&pos.X), Y: atomic.LoadUint32(&pos.Y), LastUpdate: pos.GetLastUpdate()},
+		&Velocity{VX: atomic.LoadUint32(&vel.VX), VY: atomic.LoadUint32(&vel.VY), ClientTick: vel.GetClientTick(), LastActivity: vel.GetLastActivity(), SpeedPercent: atomic.LoadUint32(&vel.SpeedPercent),
+			ThrottleX: atomic.LoadUint32(&vel.ThrottleX), ThrottleY: atomic.LoadUint32(&vel.ThrottleY), SubVX: atomic.LoadUint32(&vel.SubVX), SubVY: atomic.LoadUint32(&vel.SubVY)},
+		&Facing{Right: atomic.LoadUint32(&facing.Right)},
+		&Combat{State: atomic.LoadUint32(&combat.State), AttackStartTime: combat.GetAttackStartTime(), StunnedUntil: combat.GetStunnedUntil(), SpawnProtectedUntil: combat.GetSpawnProtectedUntil(),
+			Frozen: atomic.LoadUint32(&combat.Frozen), Invulnerable: atomic.LoadUint32(&combat.Invulnerable), SafeZone: atomic.LoadUint32(&combat.SafeZone), HP: atomic.LoadUint32(&combat.HP), RespawnAt: combat.GetRespawnAt()},
+		&AI{NextDecision: ai.GetNextDecision()},
+	)
+}
+
+// Компоненты игрока.
+func (p *Player) Position() *Position { return p.position.Load() }
+func (p *Player) Velocity() *Velocity { return p.velocity.Load() }
+func (p *Player) Facing() *Facing     { return p.facing.Load() }
+func (p *Player) Combat() *Combat     { return p.combat.Load() }
+func (p *Player) AI() *AI             { return p.ai.Load() }
+
+// ViewportBounds — прямоугольник мира, видимый игроку (включительно).
+type ViewportBounds struct {
+	MinX uint32
+	MinY uint32
+	MaxX uint32
+	MaxY uint32
+}
+
+// Contains проверяет, попадает ли точка в границы.
+func (b ViewportBounds) Contains(x, y uint32) bool {
+	return x >= b.MinX && x <= b.MaxX && y >= b.MinY && y <= b.MaxY
+}
+
+// packPoint упаковывает точку в одно слово для атомарного хранения.
+func packPoint(x, y uint32) uint64 {
+	return uint64(x)<<32 | uint64(y)
+}
+
+func unpackPoint(v uint64) (x, y uint32) {
+	return uint32(v >> 32), uint32(v)
+}
+
+// Состояния игрока — младшие биты (0-4) PlayerState.State и wire flags. Переходы между
+// ними проверяет game.CanTransition. 0 и 1 сохраняют прежний смысл для старых клиентов.
+const (
+	StateIdle      uint8 = 0
+	StateAttacking uint8 = 1
+	StateMoving    uint8 = 2
+	StateStunned   uint8 = 3 // не двигается и не атакует до StunnedUntil
+	StateDead      uint8 = 4 // до Revive
+)
+
+// StateFlagSpawnProtected — бит в PlayerState.State (и в wire flags), выставленный,
+// пока действует защита после спавна или неуязвимость от администратора. Младшие биты остаются кодом состояния (State*).
+const StateFlagSpawnProtected uint8 = 0x40
+
+// StateFlagSafeZone — бит в PlayerState.State (и в wire flags), выставленный, пока игрок
+// стоит в безопасной зоне. Коды состояния занимают биты 0-4.
+const StateFlagSafeZone uint8 = 0x20
+
+// StateFlags — все флаги в PlayerState.State поверх кода состояния.
+const StateFlags = StateFlagSpawnProtected | StateFlagSafeZone
+
 // GameEvent представляет игровое событие
 type GameEvent struct {
 	PlayerID    uint32
 	Type        EventType
 	VectorX     int8
 	VectorY     int8
+	ThrottleX   uint8 // EventMove: аналоговая величина VectorX, 1..127; 0 = полная
+	ThrottleY   uint8
 	FacingRight bool
 	ClientTick  uint32
 	Timestamp   int64
+
+	// Операции администратора (game/adminops.go)
+	X, Y  uint32 // EventTeleport: точка назначения
+	Value int32  // EventFreeze, EventInvulnerable: 1 = вкл, 0 = выкл; EventSpeed: проценты
 }
 
 // EventType определяет тип события
@@ -44,13 +138,19 @@ const (
 	EventMove EventType = iota
 	EventAttack
 	EventFace
+	EventTeleport
+	EventFreeze
+	EventSpeed
+	EventInvulnerable
 )
 
 // PlayerState содержит состояние игрока для сериализации
 type PlayerState struct {
 	ID          uint32
-	X           uint16
-	Y           uint16
+	X           uint32
+	Y           uint32
+	FracX       uint8 // доля единицы сверх X, в 1/SubUnits (см. Fixed)
+	FracY       uint8
 	VX          int8
 	VY          int8
 	FacingRight bool
@@ -58,6 +158,17 @@ type PlayerState struct {
 	ClientTick  uint32
 }
 
+// SetPosition задаёт X, Y и их доли из позиции в фиксированной точке.
+func (s *PlayerState) SetPosition(x, y Fixed) {
+	s.X, s.FracX = x.Units(), x.Frac()
+	s.Y, s.FracY = y.Units(), y.Frac()
+}
+
+// Fixed возвращает позицию в фиксированной точке.
+func (s *PlayerState) Fixed() (x, y Fixed) {
+	return FixedOf(s.X) | Fixed(s.FracX), FixedOf(s.Y) | Fixed(s.FracY)
+}
+
 // PerformanceMetrics содержит метрики производительности
 type PerformanceMetrics struct {
 	ConnectedPlayers uint32
@@ -65,72 +176,81 @@ type PerformanceMetrics struct {
 }
 
 // Atomic операции для Player
-func (p *Player) GetX() uint16 {
-	return uint16(atomic.LoadUint32(&p.X))
+func (p *Player) GetX() uint32 {
+	return p.Position().GetX()
 }
 
-func (p *Player) SetX(x uint16) {
-	atomic.StoreUint32(&p.X, uint32(x))
+func (p *Player) SetX(x uint32) {
+	p.Position().SetX(x)
 }
 
-func (p *Player) GetY() uint16 {
-	return uint16(atomic.LoadUint32(&p.Y))
+func (p *Player) GetY() uint32 {
+	return p.Position().GetY()
 }
 
-func (p *Player) SetY(y uint16) {
-	atomic.StoreUint32(&p.Y, uint32(y))
+func (p *Player) SetY(y uint32) {
+	p.Position().SetY(y)
 }
 
 func (p *Player) GetFacingRight() bool {
-	return atomic.LoadUint32(&p.FacingRight) == 1
+	return p.Facing().GetRight()
 }
 
 func (p *Player) SetFacingRight(facing bool) {
-	var val uint32
-	if facing {
-		val = 1
-	}
-	atomic.StoreUint32(&p.FacingRight, val)
+	p.Facing().SetRight(facing)
 }
 
 func (p *Player) GetState() uint8 {
-	return uint8(atomic.LoadUint32(&p.State))
+	return p.Combat().GetState()
 }
 
 func (p *Player) SetState(state uint8) {
-	atomic.StoreUint32(&p.State, uint32(state))
+	p.Combat().SetState(state)
+}
+
+// CompareAndSwapState меняет состояние, только если оно всё ещё равно old.
+func (p *Player) CompareAndSwapState(old, state uint8) bool {
+	return p.Combat().CompareAndSwapState(old, state)
 }
 
 func (p *Player) GetVX() int8 {
-	return int8(atomic.LoadUint32(&p.VX))
+	return p.Velocity().GetVX()
 }
 
 func (p *Player) SetVX(vx int8) {
-	atomic.StoreUint32(&p.VX, uint32(vx))
+	p.Velocity().SetVX(vx)
 }
 
 func (p *Player) GetVY() int8 {
-	return int8(atomic.LoadUint32(&p.VY))
+	return p.Velocity().GetVY()
 }
 
 func (p *Player) SetVY(vy int8) {
-	atomic.StoreUint32(&p.VY, uint32(vy))
+	p.Velocity().SetVY(vy)
 }
 
 func (p *Player) GetClientTick() uint32 {
-	return atomic.LoadUint32(&p.ClientTick)
+	return p.Velocity().GetClientTick()
 }
 
 func (p *Player) SetClientTick(tick uint32) {
-	atomic.StoreUint32(&p.ClientTick, tick)
+	p.Velocity().SetClientTick(tick)
 }
 
 func (p *Player) GetLastUpdate() int64 {
-	return atomic.LoadInt64(&p.LastUpdate)
+	return p.Position().GetLastUpdate()
 }
 
 func (p *Player) SetLastUpdate(timestamp int64) {
-	atomic.StoreInt64(&p.LastUpdate, timestamp)
+	p.Position().SetLastUpdate(timestamp)
+}
+
+func (p *Player) GetLastActivity() int64 {
+	return p.Velocity().GetLastActivity()
+}
+
+func (p *Player) SetLastActivity(timestamp int64) {
+	p.Velocity().SetLastActivity(timestamp)
 }
 
 func (p *Player) IncrementMessageCount() uint64 {
@@ -141,24 +261,72 @@ func (p *Player) GetMessageCount() uint64 {
 	return atomic.LoadUint64(&p.MessageCount)
 }
 
+func (p *Player) GetViewSize() (w, h uint16) {
+	return uint16(atomic.LoadUint32(&p.ViewW)), uint16(atomic.LoadUint32(&p.ViewH))
+}
+
+func (p *Player) SetViewSize(w, h uint16) {
+	atomic.StoreUint32(&p.ViewH, uint32(h))
+	atomic.StoreUint32(&p.ViewW, uint32(w))
+}
+
+func (p *Player) GetTeam() uint8 {
+	return uint8(atomic.LoadUint32(&p.Team))
+}
+
+func (p *Player) SetTeam(team uint8) {
+	atomic.StoreUint32(&p.Team, uint32(team))
+}
+
+func (p *Player) GetSpawnProtectedUntil() int64 {
+	return p.Combat().GetSpawnProtectedUntil()
+}
+
+func (p *Player) SetSpawnProtectedUntil(t int64) {
+	p.Combat().SetSpawnProtectedUntil(t)
+}
+
+// GetViewport возвращает границы viewport; ok=false, если клиент его не присылал.
+func (p *Player) GetViewport() (bounds ViewportBounds, ok bool) {
+	if atomic.LoadUint32(&p.ViewW) == 0 {
+		return ViewportBounds{}, false
+	}
+	bounds.MinX, bounds.MinY = unpackPoint(atomic.LoadUint64(&p.ViewportMin))
+	bounds.MaxX, bounds.MaxY = unpackPoint(atomic.LoadUint64(&p.ViewportMax))
+	return bounds, true
+}
+
+func (p *Player) SetViewport(bounds ViewportBounds) {
+	atomic.StoreUint64(&p.ViewportMin, packPoint(bounds.MinX, bounds.MinY))
+	atomic.StoreUint64(&p.ViewportMax, packPoint(bounds.MaxX, bounds.MaxY))
+}
+
 func (p *Player) GetAttackStartTime() int64 {
-	return atomic.LoadInt64(&p.AttackStartTime)
+	return p.Combat().GetAttackStartTime()
 }
 
 func (p *Player) SetAttackStartTime(t int64) {
-	atomic.StoreInt64(&p.AttackStartTime, t)
+	p.Combat().SetAttackStartTime(t)
+}
+
+func (p *Player) GetStunnedUntil() int64 {
+	return p.Combat().GetStunnedUntil()
+}
+
+func (p *Player) SetStunnedUntil(t int64) {
+	p.Combat().SetStunnedUntil(t)
 }
 
 // ToState преобразует Player в PlayerState для сериализации
 func (p *Player) ToState() PlayerState {
-	return PlayerState{
+	pos, vel := p.Position(), p.Velocity()
+	st := PlayerState{
 		ID:          p.ID,
-		X:           p.GetX(),
-		Y:           p.GetY(),
-		VX:          p.GetVX(),
-		VY:          p.GetVY(),
-		FacingRight: p.GetFacingRight(),
-		State:       p.GetState(),
-		ClientTick:  p.GetClientTick(),
+		FacingRight: p.Facing().GetRight(),
+		State:       p.Combat().WireState(),
+		ClientTick:  vel.GetClientTick(),
 	}
+	st.SetPosition(pos.GetFixed())
+	st.VX, st.VY = vel.WireVector()
+	return st
 }
@@ -0,0 +1,183 @@
+package worldmap
+
+import "fmt"
+
+// Procedural layouts (WORLD_SEED).
+//
+// Generate builds a layout from a seed for worlds without a hand-authored map. The
+// same seed and world size give the same map everywhere: the server sends the seed in
+// CONFIG, and a client that ports the steps below renders decoration matching it.
+// Everything is integer arithmetic on a 32-bit PRNG, in this order:
+//
+//  1. PRNG: mulberry32 over uint32 state = seed; intn(n) = next() % n.
+//  2. Grid: GeneratedTileSize tiles, cols = ceil(width/tile), rows = ceil(height/tile).
+//  3. Town: a max(cols/8, 3) × max(rows/8, 3) tile rectangle in the middle of the
+//     grid, the spawn area and the first safe zone.
+//  4. Outposts: max(cols*rows/1024, 1) tries, each a 3×3 tile safe zone at
+//     (intn(cols-2), intn(rows-2)), dropped if it comes within a tile of another zone.
+//  5. Obstacles: cols*rows/12 tries, each w = 1+intn(3), h = 1+intn(3) tiles at
+//     (intn(cols-w+1), intn(rows-h+1)), dropped if it comes within a tile of a zone
+//     or another obstacle. Obstacles never touch, so the open ground stays connected.
+//  6. Item spawns: max(cols*rows/100, 1) tries at (intn(cols), intn(rows)), dropped on
+//     an obstacle, in a zone or on a tile taken; the point is the tile centre.
+
+// GeneratedTileSize — tile side of generated layouts, world units.
+const GeneratedTileSize = 64
+
+const (
+	minGeneratedTiles = 16      // a side, so the town and some open ground fit
+	maxGeneratedTiles = 1 << 22 // cols × rows: 4 MB of collision grid
+)
+
+// Generate builds a width × height layout from seed (see above).
+func Generate(seed uint32, width, height uint32) (*Map, error) {
+	const tile = GeneratedTileSize
+	cols, rows := int((width+tile-1)/tile), int((height+tile-1)/tile)
+	if cols < minGeneratedTiles || rows < minGeneratedTiles {
+		return nil, fmt.Errorf("worldmap: a generated world must be at least %d×%d", minGeneratedTiles*tile, minGeneratedTiles*tile)
+	}
+	if cols*rows > maxGeneratedTiles {
+		return nil, fmt.Errorf("worldmap: a %d×%d world is too large to generate (%d tiles of %d units, at most %d)", width, height, cols*rows, tile, maxGeneratedTiles)
+	}
+	g := generator{
+		rng: mulberry32{state: seed},
+		m: &Map{
+			Width: width, Height: height,
+			TileWidth: tile, TileHeight: tile,
+			Cols: cols, Rows: rows,
+			blocked: make([]bool, cols*rows),
+			Seed:    seed,
+		},
+	}
+	g.layZones()
+	g.placeObstacles()
+	g.placeItems()
+	return g.m, nil
+}
+
+// mulberry32 — a small PRNG that is simple to port to JavaScript (Math.imul).
+type mulberry32 struct {
+	state uint32
+}
+
+func (r *mulberry32) next() uint32 {
+	r.state += 0x6D2B79F5
+	z := r.state
+	z = (z ^ z>>15) * (z | 1)
+	z ^= z + (z^z>>7)*(z|61)
+	return z ^ z>>14
+}
+
+func (r *mulberry32) intn(n int) int {
+	return int(r.next() % uint32(n))
+}
+
+// tileRect — a rectangle of tiles, [col, col+w) × [row, row+h).
+type tileRect struct {
+	col, row, w, h int
+}
+
+// near reports whether r and o overlap or touch, diagonally included.
+func (r tileRect) near(o tileRect) bool {
+	return r.col <= o.col+o.w && o.col <= r.col+r.w && r.row <= o.row+o.h && o.row <= r.row+r.h
+}
+
+func (r tileRect) contains(col, row int) bool {
+	return col >= r.col && col < r.col+r.w && row >= r.row && row < r.row+r.h
+}
+
+type generator struct {
+	rng   mulberry32
+	m     *Map
+	zones []tileRect // town first
+}
+
+// rect converts r to world units, clipped to the world.
+func (g *generator) rect(r tileRect) Rect {
+	tw, th := g.m.TileWidth, g.m.TileHeight
+	return Rect{
+		MinX: uint32(r.col) * tw,
+		MinY: uint32(r.row) * th,
+		MaxX: min(uint32(r.col+r.w)*tw, g.m.Width),
+		MaxY: min(uint32(r.row+r.h)*th, g.m.Height),
+	}
+}
+
+func (g *generator) inZone(col, row int) bool {
+	for _, z := range g.zones {
+		if z.contains(col, row) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) layZones() {
+	cols, rows := g.m.Cols, g.m.Rows
+	tw, th := max(cols/8, 3), max(rows/8, 3)
+	town := tileRect{col: (cols - tw) / 2, row: (rows - th) / 2, w: tw, h: th}
+	g.zones = append(g.zones, town)
+	g.m.SpawnAreas = append(g.m.SpawnAreas, g.rect(town))
+	g.m.SafeZones = append(g.m.SafeZones, g.rect(town))
+
+	for range max(cols*rows/1024, 1) {
+		outpost := tileRect{col: g.rng.intn(cols - 2), row: g.rng.intn(rows - 2), w: 3, h: 3}
+		if g.nearZone(outpost) {
+			continue
+		}
+		g.zones = append(g.zones, outpost)
+		g.m.SafeZones = append(g.m.SafeZones, g.rect(outpost))
+	}
+}
+
+func (g *generator) nearZone(r tileRect) bool {
+	for _, z := range g.zones {
+		if r.near(z) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) placeObstacles() {
+	cols, rows := g.m.Cols, g.m.Rows
+	for range cols * rows / 12 {
+		w, h := 1+g.rng.intn(3), 1+g.rng.intn(3)
+		r := tileRect{col: g.rng.intn(cols - w + 1), row: g.rng.intn(rows - h + 1), w: w, h: h}
+		if g.nearZone(r) || g.nearObstacle(r) {
+			continue
+		}
+		for row := r.row; row < r.row+h; row++ {
+			for col := r.col; col < r.col+w; col++ {
+				g.m.blocked[row*cols+col] = true
+			}
+		}
+	}
+}
+
+// nearObstacle reports whether a blocked tile lies in r or next to it.
+func (g *generator) nearObstacle(r tileRect) bool {
+	for row := max(r.row-1, 0); row < min(r.row+r.h+1, g.m.Rows); row++ {
+		for col := max(r.col-1, 0); col < min(r.col+r.w+1, g.m.Cols); col++ {
+			if g.m.blocked[row*g.m.Cols+col] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *generator) placeItems() {
+	cols, rows := g.m.Cols, g.m.Rows
+	taken := make(map[int]bool)
+	for range max(cols*rows/100, 1) {
+		col, row := g.rng.intn(cols), g.rng.intn(rows)
+		i := row*cols + col
+		if g.m.blocked[i] || taken[i] || g.inZone(col, row) {
+			continue
+		}
+		taken[i] = true
+		r := g.rect(tileRect{col: col, row: row, w: 1, h: 1})
+		g.m.ItemSpawns = append(g.m.ItemSpawns, Point{X: (r.MinX + r.MaxX) / 2, Y: (r.MinY + r.MaxY) / 2})
+	}
+}
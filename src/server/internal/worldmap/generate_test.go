@@ -0,0 +1,124 @@
+package worldmap
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	a, err := Generate(42, 6000, 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Generate(42, 6000, 3000)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("same seed, different layouts")
+	}
+	if c, _ := Generate(43, 6000, 3000); reflect.DeepEqual(a.blocked, c.blocked) {
+		t.Error("seeds 42 and 43 gave the same obstacles")
+	}
+	if a.Seed != 42 || len(a.SpawnAreas) != 1 || len(a.SafeZones) < 2 || len(a.ItemSpawns) == 0 {
+		t.Errorf("seed %d, %d spawn areas, %d safe zones, %d item spawns", a.Seed, len(a.SpawnAreas), len(a.SafeZones), len(a.ItemSpawns))
+	}
+}
+
+// TestGeneratePinned pins the PRNG and the layout of one seed: clients port the
+// generator to render matching decoration, so any change to it is a protocol change.
+func TestGeneratePinned(t *testing.T) {
+	// The reference JavaScript mulberry32 gives these for seed 42.
+	r := mulberry32{state: 42}
+	for _, want := range []uint32{2581720956, 1925393290, 3661312704} {
+		if got := r.next(); got != want {
+			t.Fatalf("mulberry32(42) = %d, want %d", got, want)
+		}
+	}
+
+	m, err := Generate(42, 6000, 3000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := fnv.New64a()
+	for _, b := range m.blocked {
+		if b {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	for _, z := range m.SafeZones {
+		binary.Write(h, binary.LittleEndian, z)
+	}
+	binary.Write(h, binary.LittleEndian, m.ItemSpawns)
+	const want uint64 = 0xaa3ba4ab4d7b27e4
+	if got := h.Sum64(); got != want {
+		t.Errorf("layout of seed 42 hashes to %#x, want %#x", got, want)
+	}
+}
+
+func TestGenerateLayout(t *testing.T) {
+	for seed := uint32(1); seed <= 20; seed++ {
+		m, err := Generate(seed, 3000+seed*37, 1500+seed*53)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.ItemSpawns {
+			if m.Blocked(p.X, p.Y) || p.X >= m.Width || p.Y >= m.Height {
+				t.Fatalf("seed %d: item spawn %+v blocked or outside the world", seed, p)
+			}
+		}
+		for _, z := range append(m.SafeZones, m.SpawnAreas...) {
+			for y := z.MinY; y < z.MaxY; y += m.TileHeight {
+				for x := z.MinX; x < z.MaxX; x += m.TileWidth {
+					if m.Blocked(x, y) {
+						t.Fatalf("seed %d: obstacle at (%d, %d) in zone %+v", seed, x, y, z)
+					}
+				}
+			}
+		}
+		if open, reached := openGround(m); reached != open {
+			t.Errorf("seed %d: %d of %d open tiles reachable from the town", seed, reached, open)
+		}
+	}
+}
+
+// openGround counts the open tiles of m and those reachable from the spawn area.
+func openGround(m *Map) (open, reached int) {
+	seen := make([]bool, len(m.blocked))
+	town := m.SpawnAreas[0]
+	start := int(town.MinY/m.TileHeight)*m.Cols + int(town.MinX/m.TileWidth)
+	queue := []int{start}
+	seen[start] = true
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		reached++
+		col, row := i%m.Cols, i/m.Cols
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			c, r := col+d[0], row+d[1]
+			if c < 0 || r < 0 || c >= m.Cols || r >= m.Rows {
+				continue
+			}
+			if j := r*m.Cols + c; !seen[j] && !m.blocked[j] {
+				seen[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	for _, b := range m.blocked {
+		if !b {
+			open++
+		}
+	}
+	return open, reached
+}
+
+func TestGenerateSize(t *testing.T) {
+	if _, err := Generate(1, 900, 3000); err == nil {
+		t.Error("a 900-unit wide world generated")
+	}
+	if _, err := Generate(1, 1<<20, 1<<20); err == nil {
+		t.Error("a 2^20-unit world generated")
+	}
+}
@@ -0,0 +1,437 @@
+package worldmap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rawMap — format-independent view of a Tiled map, filled by readJSON / readTMX.
+type rawMap struct {
+	width, height         int // tiles
+	tileWidth, tileHeight int
+	tileLayers            []rawTileLayer
+	objects               []rawObject
+}
+
+type rawTileLayer struct {
+	name      string
+	collision bool // collision=true property
+	gids      []uint32
+}
+
+type rawObject struct {
+	name, kind          string
+	x, y, width, height float64
+	props               map[string]string
+}
+
+// Tiled stores flip flags in the top bits of each gid.
+const gidFlagsMask = 0xF0000000
+
+// build validates raw and converts it to a Map.
+func (raw *rawMap) build() (*Map, error) {
+	if raw.width <= 0 || raw.height <= 0 || raw.tileWidth <= 0 || raw.tileHeight <= 0 {
+		return nil, errors.New("map and tile sizes must be positive")
+	}
+	if raw.width*raw.tileWidth > maxCoord || raw.height*raw.tileHeight > maxCoord {
+		return nil, errors.New("map does not fit into 24-bit world coordinates")
+	}
+
+	m := &Map{
+		Width:      uint32(raw.width * raw.tileWidth),
+		Height:     uint32(raw.height * raw.tileHeight),
+		TileWidth:  uint32(raw.tileWidth),
+		TileHeight: uint32(raw.tileHeight),
+		Cols:       raw.width,
+		Rows:       raw.height,
+	}
+
+	for _, l := range raw.tileLayers {
+		if !l.collision && !strings.EqualFold(l.name, "collision") {
+			continue
+		}
+		if len(l.gids) != raw.width*raw.height {
+			return nil, fmt.Errorf("collision layer %q has %d tiles, want %d", l.name, len(l.gids), raw.width*raw.height)
+		}
+		if m.blocked == nil {
+			m.blocked = make([]bool, len(l.gids))
+		}
+		for i, gid := range l.gids {
+			if gid&^gidFlagsMask != 0 {
+				m.blocked[i] = true
+			}
+		}
+	}
+
+	named := make(map[string]rawObject, len(raw.objects))
+	for _, o := range raw.objects {
+		if o.name != "" {
+			named[o.name] = o
+		}
+	}
+
+	for _, o := range raw.objects {
+		switch strings.ToLower(o.kind) {
+		case "spawn":
+			area, err := m.objectRect(o)
+			if err != nil {
+				return nil, err
+			}
+			m.SpawnAreas = append(m.SpawnAreas, area)
+		case "safe":
+			area, err := m.objectRect(o)
+			if err != nil {
+				return nil, err
+			}
+			m.SafeZones = append(m.SafeZones, area)
+		case "portal":
+			area, err := m.objectRect(o)
+			if err != nil {
+				return nil, err
+			}
+			p := Portal{Name: o.name, Area: area}
+			if p.DestX, p.DestY, err = m.portalDest(o, named); err != nil {
+				return nil, err
+			}
+			m.Portals = append(m.Portals, p)
+		case "item":
+			m.ItemSpawns = append(m.ItemSpawns, Point{
+				X: clampCoord(o.x+o.width/2, m.Width),
+				Y: clampCoord(o.y+o.height/2, m.Height),
+			})
+		}
+	}
+
+	// A destination inside a portal would bounce the player every tick.
+	for _, p := range m.Portals {
+		if m.PortalAt(p.DestX, p.DestY) != nil {
+			return nil, fmt.Errorf("portal %q leads into another portal", p.Name)
+		}
+		if m.Blocked(p.DestX, p.DestY) {
+			return nil, fmt.Errorf("portal %q leads onto a collision tile", p.Name)
+		}
+	}
+	return m, nil
+}
+
+// objectRect converts an object to a rectangle clamped to the map.
+func (m *Map) objectRect(o rawObject) (Rect, error) {
+	if o.width <= 0 || o.height <= 0 {
+		return Rect{}, fmt.Errorf("%s object %q must be a rectangle", o.kind, o.name)
+	}
+	r := Rect{
+		MinX: clampCoord(o.x, m.Width),
+		MinY: clampCoord(o.y, m.Height),
+		MaxX: clampCoord(o.x+o.width, m.Width),
+		MaxY: clampCoord(o.y+o.height, m.Height),
+	}
+	if r.MinX >= r.MaxX || r.MinY >= r.MaxY {
+		return Rect{}, fmt.Errorf("%s object %q lies outside the map", o.kind, o.name)
+	}
+	return r, nil
+}
+
+// portalDest resolves a portal's destination from "target" or targetX/targetY.
+func (m *Map) portalDest(o rawObject, named map[string]rawObject) (uint32, uint32, error) {
+	if target := o.props["target"]; target != "" {
+		t, ok := named[target]
+		if !ok {
+			return 0, 0, fmt.Errorf("portal %q: unknown target %q", o.name, target)
+		}
+		return clampCoord(t.x+t.width/2, m.Width), clampCoord(t.y+t.height/2, m.Height), nil
+	}
+	x, errX := strconv.ParseFloat(o.props["targetX"], 64)
+	y, errY := strconv.ParseFloat(o.props["targetY"], 64)
+	if errX != nil || errY != nil {
+		return 0, 0, fmt.Errorf("portal %q needs a target or targetX/targetY property", o.name)
+	}
+	return clampCoord(x, m.Width), clampCoord(y, m.Height), nil
+}
+
+func clampCoord(v float64, limit uint32) uint32 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= float64(limit) {
+		return limit
+	}
+	return uint32(v)
+}
+
+// ── JSON (.json / .tmj) ───────────────────────────────────────────────────────
+
+type jsonProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type jsonObject struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`  // Tiled < 1.9
+	Class      string         `json:"class"` // Tiled ≥ 1.9
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Properties []jsonProperty `json:"properties"`
+}
+
+type jsonLayer struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []jsonObject    `json:"objects"`
+	Layers      []jsonLayer     `json:"layers"` // group layers
+	Properties  []jsonProperty  `json:"properties"`
+}
+
+type jsonMap struct {
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	TileWidth  int         `json:"tilewidth"`
+	TileHeight int         `json:"tileheight"`
+	Infinite   bool        `json:"infinite"`
+	Layers     []jsonLayer `json:"layers"`
+}
+
+func readJSON(path string) (*rawMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("worldmap: %w", err)
+	}
+	var jm jsonMap
+	if err := json.Unmarshal(data, &jm); err != nil {
+		return nil, fmt.Errorf("worldmap: %s: %w", path, err)
+	}
+	if jm.Infinite {
+		return nil, fmt.Errorf("worldmap: %s: infinite maps are not supported", path)
+	}
+	raw := &rawMap{width: jm.Width, height: jm.Height, tileWidth: jm.TileWidth, tileHeight: jm.TileHeight}
+	if err := raw.addJSONLayers(jm.Layers); err != nil {
+		return nil, fmt.Errorf("worldmap: %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+func (raw *rawMap) addJSONLayers(layers []jsonLayer) error {
+	for _, l := range layers {
+		switch l.Type {
+		case "group":
+			if err := raw.addJSONLayers(l.Layers); err != nil {
+				return err
+			}
+		case "tilelayer":
+			tl := rawTileLayer{name: l.Name, collision: jsonProps(l.Properties)["collision"] == "true"}
+			if l.Encoding == "base64" {
+				var s string
+				if err := json.Unmarshal(l.Data, &s); err != nil {
+					return fmt.Errorf("layer %q: %w", l.Name, err)
+				}
+				gids, err := decodeBase64GIDs(s, l.Compression)
+				if err != nil {
+					return fmt.Errorf("layer %q: %w", l.Name, err)
+				}
+				tl.gids = gids
+			} else if err := json.Unmarshal(l.Data, &tl.gids); err != nil {
+				return fmt.Errorf("layer %q: %w", l.Name, err)
+			}
+			raw.tileLayers = append(raw.tileLayers, tl)
+		case "objectgroup":
+			for _, o := range l.Objects {
+				kind := o.Class
+				if kind == "" {
+					kind = o.Type
+				}
+				raw.objects = append(raw.objects, rawObject{
+					name: o.Name, kind: kind,
+					x: o.X, y: o.Y, width: o.Width, height: o.Height,
+					props: jsonProps(o.Properties),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+func jsonProps(props []jsonProperty) map[string]string {
+	out := make(map[string]string, len(props))
+	for _, p := range props {
+		out[p.Name] = fmt.Sprint(p.Value)
+	}
+	return out
+}
+
+// ── TMX (.tmx) ────────────────────────────────────────────────────────────────
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxObject struct {
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxLayer struct {
+	Name string `xml:"name,attr"`
+	Data struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Content     string `xml:",chardata"`
+	} `xml:"data"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxObjectGroup struct {
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxGroup struct {
+	Layers       []tmxLayer       `xml:"layer"`
+	ObjectGroups []tmxObjectGroup `xml:"objectgroup"`
+	Groups       []tmxGroup       `xml:"group"`
+}
+
+type tmxMap struct {
+	Width      int  `xml:"width,attr"`
+	Height     int  `xml:"height,attr"`
+	TileWidth  int  `xml:"tilewidth,attr"`
+	TileHeight int  `xml:"tileheight,attr"`
+	Infinite   bool `xml:"infinite,attr"`
+	tmxGroup
+}
+
+func readTMX(path string) (*rawMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("worldmap: %w", err)
+	}
+	var tm tmxMap
+	if err := xml.Unmarshal(data, &tm); err != nil {
+		return nil, fmt.Errorf("worldmap: %s: %w", path, err)
+	}
+	if tm.Infinite {
+		return nil, fmt.Errorf("worldmap: %s: infinite maps are not supported", path)
+	}
+	raw := &rawMap{width: tm.Width, height: tm.Height, tileWidth: tm.TileWidth, tileHeight: tm.TileHeight}
+	if err := raw.addTMXGroup(&tm.tmxGroup); err != nil {
+		return nil, fmt.Errorf("worldmap: %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+func (raw *rawMap) addTMXGroup(g *tmxGroup) error {
+	for _, l := range g.Layers {
+		tl := rawTileLayer{name: l.Name, collision: tmxProps(l.Properties)["collision"] == "true"}
+		var err error
+		switch l.Data.Encoding {
+		case "csv":
+			tl.gids, err = decodeCSVGIDs(l.Data.Content)
+		case "base64":
+			tl.gids, err = decodeBase64GIDs(l.Data.Content, l.Data.Compression)
+		default:
+			err = fmt.Errorf("unsupported tile encoding %q (use CSV or Base64)", l.Data.Encoding)
+		}
+		if err != nil {
+			return fmt.Errorf("layer %q: %w", l.Name, err)
+		}
+		raw.tileLayers = append(raw.tileLayers, tl)
+	}
+	for _, og := range g.ObjectGroups {
+		for _, o := range og.Objects {
+			kind := o.Class
+			if kind == "" {
+				kind = o.Type
+			}
+			raw.objects = append(raw.objects, rawObject{
+				name: o.Name, kind: kind,
+				x: o.X, y: o.Y, width: o.Width, height: o.Height,
+				props: tmxProps(o.Properties),
+			})
+		}
+	}
+	for i := range g.Groups {
+		if err := raw.addTMXGroup(&g.Groups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tmxProps(props []tmxProperty) map[string]string {
+	out := make(map[string]string, len(props))
+	for _, p := range props {
+		out[p.Name] = p.Value
+	}
+	return out
+}
+
+// ── Tile data decoding ────────────────────────────────────────────────────────
+
+func decodeCSVGIDs(s string) ([]uint32, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+	gids := make([]uint32, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		gids[i] = uint32(v)
+	}
+	return gids, nil
+}
+
+func decodeBase64GIDs(s, compression string) ([]uint32, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	switch compression {
+	case "":
+		r = bytes.NewReader(data)
+	case "zlib":
+		if r, err = zlib.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	case "gzip":
+		if r, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression %q (use none, zlib or gzip)", compression)
+	}
+	if data, err = io.ReadAll(r); err != nil {
+		return nil, err
+	}
+	if len(data)%4 != 0 {
+		return nil, errors.New("base64 tile data is not a whole number of gids")
+	}
+	gids := make([]uint32, len(data)/4)
+	for i := range gids {
+		gids[i] = binary.LittleEndian.Uint32(data[i*4:])
+	}
+	return gids, nil
+}
@@ -0,0 +1,143 @@
+// Package worldmap loads world layouts exported from the Tiled map editor
+// (https://www.mapeditor.org) in either JSON (.json/.tmj) or TMX (.tmx) format.
+//
+// Map conventions (one unit = one world unit, so a map should match WORLD_WIDTH ×
+// WORLD_HEIGHT in pixels):
+//
+//   - Tile layer named "collision" (or with bool property collision=true): every
+//     non-empty tile blocks movement.
+//   - Objects of type/class "spawn": rectangles new players are spawned in.
+//   - Objects of type/class "safe": safe zones, where players neither attack nor get
+//     hit.
+//   - Objects of type/class "portal": entering the rectangle teleports the player to
+//     the centre of the object named by the string property "target", or to the
+//     int properties targetX/targetY.
+//   - Objects of type/class "item": item spawn locations — the point, or the centre of
+//     the rectangle.
+//
+// Without a map file a layout can be generated from a seed instead (Generate).
+package worldmap
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxCoord — the largest world coordinate (24.8 fixed point positions, types.Fixed).
+const maxCoord = 1<<24 - 1
+
+// Rect — axis-aligned rectangle in world units, [MinX, MaxX) × [MinY, MaxY).
+type Rect struct {
+	MinX, MinY, MaxX, MaxY uint32
+}
+
+// Contains reports whether (x, y) lies inside r.
+func (r Rect) Contains(x, y uint32) bool {
+	return x >= r.MinX && x < r.MaxX && y >= r.MinY && y < r.MaxY
+}
+
+// Point — a location in world units.
+type Point struct {
+	X, Y uint32
+}
+
+// Portal teleports players entering Area to (DestX, DestY).
+type Portal struct {
+	Name         string
+	Area         Rect
+	DestX, DestY uint32
+}
+
+// Map is the collision grid, spawn areas, safe zones, portals and item spawns of a
+// world layout.
+type Map struct {
+	Width, Height         uint32 // world units
+	TileWidth, TileHeight uint32
+	Cols, Rows            int
+	blocked               []bool // Cols × Rows, row-major; nil = no collision layer
+
+	SpawnAreas []Rect
+	SafeZones  []Rect
+	Portals    []Portal
+	ItemSpawns []Point
+
+	Seed uint32 // the layout was generated from this seed (Generate); 0 = loaded from a file
+}
+
+// Load reads a Tiled export, choosing the format by file extension.
+func Load(path string) (*Map, error) {
+	var (
+		raw *rawMap
+		err error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".tmj":
+		raw, err = readJSON(path)
+	case ".tmx":
+		raw, err = readTMX(path)
+	default:
+		return nil, fmt.Errorf("worldmap: unsupported map format %q (want .json, .tmj or .tmx)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := raw.build()
+	if err != nil {
+		return nil, fmt.Errorf("worldmap: %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Blocked reports whether (x, y) lies on a collision tile. Points outside the map
+// are not blocked; world bounds are enforced separately.
+func (m *Map) Blocked(x, y uint32) bool {
+	if m.blocked == nil {
+		return false
+	}
+	col := int(x / m.TileWidth)
+	row := int(y / m.TileHeight)
+	if col >= m.Cols || row >= m.Rows {
+		return false
+	}
+	return m.blocked[row*m.Cols+col]
+}
+
+// TileBlocked reports whether tile (col, row) blocks movement. Tiles outside the grid
+// are blocked.
+func (m *Map) TileBlocked(col, row int) bool {
+	if col < 0 || row < 0 || col >= m.Cols || row >= m.Rows {
+		return true
+	}
+	return m.blocked != nil && m.blocked[row*m.Cols+col]
+}
+
+// SetBlocked marks tile (col, row) as blocked or open; tiles outside the grid are
+// ignored. For layouts built in code.
+func (m *Map) SetBlocked(col, row int, blocked bool) {
+	if col < 0 || row < 0 || col >= m.Cols || row >= m.Rows {
+		return
+	}
+	if m.blocked == nil {
+		if !blocked {
+			return
+		}
+		m.blocked = make([]bool, m.Cols*m.Rows)
+	}
+	m.blocked[row*m.Cols+col] = blocked
+}
+
+// HasCollision reports whether the map has a collision grid at all.
+func (m *Map) HasCollision() bool {
+	return m.blocked != nil
+}
+
+// PortalAt returns the portal whose area contains (x, y), or nil.
+func (m *Map) PortalAt(x, y uint32) *Portal {
+	for i := range m.Portals {
+		if m.Portals[i].Area.Contains(x, y) {
+			return &m.Portals[i]
+		}
+	}
+	return nil
+}
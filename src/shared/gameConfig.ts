@@ -6,6 +6,9 @@ export interface GameConfig {
   };
   movement: {
     playerSpeedPerTick: number;
+    // units/s² towards the input speed and towards a stop; 0 = at once
+    acceleration?: number;
+    friction?: number;
   };
   world: {
     virtualSize: {
@@ -32,6 +35,22 @@ export interface GameConfig {
   game: {
     debugMode: boolean;
   };
+  // Scheduled by the server (cron schedule); the client only reacts to WORLD_EVENT
+  worldEvents?: Array<{
+    name: string;
+    schedule: string;
+    kind: 'night' | 'storm' | 'announcement' | string;
+    durationSec?: number;
+    speedPercent?: number;
+    text?: string;
+  }>;
+  // EMOTE catalog: the server accepts these ids only, then waits cooldownMs
+  // (or its EMOTE_COOLDOWN_MS) before the next emote
+  emotes?: Array<{
+    id: number;
+    name: string;
+    cooldownMs?: number;
+  }>;
   colors: {
     worldBackground: string;
   };
@@ -47,3 +66,31 @@ export const WORLD = gameConfig.world;
 export const PLAYER = gameConfig.player;
 export const COLORS = gameConfig.colors;
 export const GAME = gameConfig.game;
+export const EMOTES = gameConfig.emotes ?? [];
+
+// The bundled values above are only defaults: after JOIN the server sends CONFIG
+// with its authoritative constants, applied here in place so every module reading
+// NETWORK / MOVEMENT / WORLD sees the server's values.
+export interface ServerGameConfig {
+  tickRate: number;
+  playerSpeedPerTick: number;
+  worldWidth: number;
+  worldHeight: number;
+  acceleration?: number; // absent from older servers
+  friction?: number;
+}
+
+export function applyServerConfig(cfg: ServerGameConfig): void {
+  NETWORK.tickRate = cfg.tickRate;
+  MOVEMENT.playerSpeedPerTick = cfg.playerSpeedPerTick;
+  MOVEMENT.acceleration = cfg.acceleration ?? 0;
+  MOVEMENT.friction = cfg.friction ?? 0;
+  applyWorldSize(cfg.worldWidth, cfg.worldHeight);
+}
+
+// Also called on WORLD_UPDATE, when the server resizes the world at runtime.
+export function applyWorldSize(width: number, height: number): void {
+  WORLD.virtualSize.width = width;
+  WORLD.virtualSize.height = height;
+  WORLD.boundaries = { minX: 0, maxX: width, minY: 0, maxY: height };
+}